@@ -0,0 +1,151 @@
+// Package accesslog tracks per-request details for structured access logging.
+package accesslog
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// HeaderRequestID is the header carrying the request ID in both directions
+const HeaderRequestID = "X-Request-Id"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// Entry collects the details of a single client request for the access log.
+// A nil *Entry is valid and ignores all calls, so callers need no checks.
+type Entry struct {
+	ID    string
+	Start time.Time
+
+	resolvedModel  string
+	conversationID string
+	firstToken     time.Time
+	streaming      bool
+	mu             sync.Mutex
+}
+
+// NewEntry starts an entry, reusing the client's request ID when it is usable
+func NewEntry(requestID string) *Entry {
+	if !validRequestID(requestID) {
+		requestID = uuid.New().String()
+	}
+	return &Entry{ID: requestID, Start: time.Now()}
+}
+
+// validRequestID accepts short IDs of printable ASCII so they are safe to log and echo
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+type contextKey struct{}
+
+// WithEntry returns a context carrying the entry
+func WithEntry(ctx context.Context, e *Entry) context.Context {
+	if e == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, e)
+}
+
+// FromContext returns the entry stored in ctx, or nil
+func FromContext(ctx context.Context) *Entry {
+	e, _ := ctx.Value(contextKey{}).(*Entry)
+	return e
+}
+
+// SetResolvedModel records the internal Kiro model ID the request was sent to
+func (e *Entry) SetResolvedModel(model string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.resolvedModel = model
+}
+
+// SetConversationID records the Kiro conversation ID the request was sent with
+func (e *Entry) SetConversationID(id string) {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.conversationID = id
+}
+
+// MarkFirstToken records when the first upstream chunk arrived. Only the first call counts.
+func (e *Entry) MarkFirstToken() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.firstToken.IsZero() {
+		e.firstToken = time.Now()
+	}
+}
+
+// MarkStreaming records that the response is being streamed to the client
+func (e *Entry) MarkStreaming() {
+	if e == nil {
+		return
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.streaming = true
+}
+
+// Streaming reports whether MarkStreaming was called
+func (e *Entry) Streaming() bool {
+	if e == nil {
+		return false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.streaming
+}
+
+// ResolvedModel returns the internal model ID, or "" if none was set
+func (e *Entry) ResolvedModel() string {
+	if e == nil {
+		return ""
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.resolvedModel
+}
+
+// ConversationID returns the Kiro conversation ID, or "" if none was set
+func (e *Entry) ConversationID() string {
+	if e == nil {
+		return ""
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.conversationID
+}
+
+// FirstTokenLatency returns the time from request start to the first upstream chunk
+func (e *Entry) FirstTokenLatency() (time.Duration, bool) {
+	if e == nil {
+		return 0, false
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.firstToken.IsZero() {
+		return 0, false
+	}
+	return e.firstToken.Sub(e.Start), true
+}
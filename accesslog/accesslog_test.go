@@ -0,0 +1,78 @@
+// Package accesslog provides tests for per-request access log details.
+package accesslog
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestNewEntry
+// =============================================================================
+
+func TestNewEntry(t *testing.T) {
+	t.Run("keeps a valid client request ID", func(t *testing.T) {
+		assert.Equal(t, "abc-123", NewEntry("abc-123").ID)
+	})
+
+	t.Run("generates an ID when missing or unsafe", func(t *testing.T) {
+		for _, id := range []string{"", "has space", "line\nbreak", strings.Repeat("x", 129)} {
+			entry := NewEntry(id)
+			assert.NotEqual(t, id, entry.ID)
+			assert.Len(t, entry.ID, 36)
+		}
+	})
+}
+
+// =============================================================================
+// TestEntry
+// =============================================================================
+
+func TestEntry(t *testing.T) {
+	t.Run("records resolved model and first token", func(t *testing.T) {
+		entry := NewEntry("")
+		ctx := WithEntry(context.Background(), entry)
+
+		_, ok := entry.FirstTokenLatency()
+		assert.False(t, ok)
+
+		FromContext(ctx).SetResolvedModel("CLAUDE_SONNET_4")
+		FromContext(ctx).MarkFirstToken()
+		first, ok := entry.FirstTokenLatency()
+		assert.True(t, ok)
+
+		time.Sleep(time.Millisecond)
+		FromContext(ctx).MarkFirstToken()
+		second, _ := entry.FirstTokenLatency()
+
+		assert.Equal(t, "CLAUDE_SONNET_4", entry.ResolvedModel())
+		assert.Equal(t, first, second)
+
+		assert.False(t, entry.Streaming())
+		FromContext(ctx).MarkStreaming()
+		assert.True(t, entry.Streaming())
+
+		assert.Empty(t, entry.ConversationID())
+		FromContext(ctx).SetConversationID("conv-1")
+		assert.Equal(t, "conv-1", entry.ConversationID())
+	})
+
+	t.Run("nil entry is a no-op", func(t *testing.T) {
+		entry := FromContext(context.Background())
+
+		entry.SetResolvedModel("model")
+		entry.MarkFirstToken()
+		entry.MarkStreaming()
+		entry.SetConversationID("conv-1")
+
+		assert.Nil(t, entry)
+		assert.False(t, entry.Streaming())
+		assert.Empty(t, entry.ResolvedModel())
+		_, ok := entry.FirstTokenLatency()
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,164 @@
+// Package activity tracks live request activity for the status dashboard:
+// throughput, in-flight requests and streams, and recent errors.
+package activity
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/accesslog"
+)
+
+const (
+	// throughputWindow is the number of seconds of per-second request counts kept
+	throughputWindow = 60
+	// maxRecentErrors is the number of failed requests kept
+	maxRecentErrors = 50
+)
+
+// ActiveRequest is a request that has not finished yet
+type ActiveRequest struct {
+	ID         string `json:"id"`
+	Method     string `json:"method"`
+	Path       string `json:"path"`
+	Model      string `json:"model,omitempty"`
+	Streaming  bool   `json:"streaming"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Error is a request that finished with a 4xx or 5xx status
+type Error struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// Snapshot is the current activity
+type Snapshot struct {
+	RequestsTotal      int64 `json:"requests_total"`
+	RequestsLastMinute int   `json:"requests_last_minute"`
+	// PerSecond holds the requests finished in each of the last 60 seconds, oldest first
+	PerSecond      []int           `json:"per_second"`
+	ActiveRequests int             `json:"active_requests"`
+	ActiveStreams  int             `json:"active_streams"`
+	Active         []ActiveRequest `json:"active"`
+	// RecentErrors lists the latest failed requests, newest first
+	RecentErrors []Error `json:"recent_errors"`
+}
+
+// activeRequest is the state of an in-flight request
+type activeRequest struct {
+	method string
+	path   string
+}
+
+// Tracker records request activity. It keeps only recent history in memory.
+type Tracker struct {
+	now func() time.Time
+
+	total int64
+	// counts[i] is the number of requests finished in the unix second seconds[i]
+	counts  [throughputWindow]int
+	seconds [throughputWindow]int64
+	active  map[*accesslog.Entry]activeRequest
+	// errors is a ring of the latest errors; next is the slot written next
+	errors []Error
+	next   int
+
+	mu sync.Mutex
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{
+		now:    time.Now,
+		active: make(map[*accesslog.Entry]activeRequest),
+	}
+}
+
+// Begin records a request as in flight. Call the returned function when it has
+// finished, with the response status and, for errors, a message.
+func (t *Tracker) Begin(entry *accesslog.Entry, method, path string) func(status int, message string) {
+	t.mu.Lock()
+	t.active[entry] = activeRequest{method: method, path: path}
+	t.mu.Unlock()
+
+	return func(status int, message string) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		delete(t.active, entry)
+		t.total++
+		now := t.now()
+		second := now.Unix()
+		slot := second % throughputWindow
+		if t.seconds[slot] != second {
+			t.seconds[slot] = second
+			t.counts[slot] = 0
+		}
+		t.counts[slot]++
+
+		if status < 400 {
+			return
+		}
+		e := Error{Time: now, RequestID: entry.ID, Method: method, Path: path, Status: status, Message: message}
+		if len(t.errors) < maxRecentErrors {
+			t.errors = append(t.errors, e)
+		} else {
+			t.errors[t.next] = e
+		}
+		t.next = (t.next + 1) % maxRecentErrors
+	}
+}
+
+// Snapshot returns the current activity
+func (t *Tracker) Snapshot() Snapshot {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	snapshot := Snapshot{
+		RequestsTotal:  t.total,
+		PerSecond:      make([]int, throughputWindow),
+		ActiveRequests: len(t.active),
+		Active:         make([]ActiveRequest, 0, len(t.active)),
+		RecentErrors:   make([]Error, 0, len(t.errors)),
+	}
+
+	second := now.Unix()
+	for i := range snapshot.PerSecond {
+		s := second - throughputWindow + 1 + int64(i)
+		if slot := s % throughputWindow; t.seconds[slot] == s {
+			snapshot.PerSecond[i] = t.counts[slot]
+			snapshot.RequestsLastMinute += t.counts[slot]
+		}
+	}
+
+	for entry, r := range t.active {
+		streaming := entry.Streaming()
+		if streaming {
+			snapshot.ActiveStreams++
+		}
+		snapshot.Active = append(snapshot.Active, ActiveRequest{
+			ID:         entry.ID,
+			Method:     r.method,
+			Path:       r.path,
+			Model:      entry.ResolvedModel(),
+			Streaming:  streaming,
+			DurationMs: now.Sub(entry.Start).Milliseconds(),
+		})
+	}
+	// Longest running first
+	sort.Slice(snapshot.Active, func(i, j int) bool {
+		return snapshot.Active[i].DurationMs > snapshot.Active[j].DurationMs
+	})
+
+	for i := 1; i <= len(t.errors); i++ {
+		snapshot.RecentErrors = append(snapshot.RecentErrors, t.errors[(t.next-i+len(t.errors))%len(t.errors)])
+	}
+	return snapshot
+}
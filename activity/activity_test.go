@@ -0,0 +1,93 @@
+// Package activity provides tests for live request activity tracking.
+package activity
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/accesslog"
+)
+
+// newTestTracker creates a tracker with a controllable clock
+func newTestTracker() (*Tracker, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	t := NewTracker()
+	t.now = func() time.Time { return now }
+	return t, &now
+}
+
+// =============================================================================
+// TestTracker
+// =============================================================================
+
+func TestTracker(t *testing.T) {
+	t.Run("tracks in-flight requests and streams", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+
+		request := accesslog.NewEntry("req-1")
+		streamed := accesslog.NewEntry("req-2")
+		streamed.SetResolvedModel("claude-sonnet-4.5")
+		streamed.MarkStreaming()
+		doneRequest := tracker.Begin(request, "POST", "/v1/messages")
+		doneStream := tracker.Begin(streamed, "POST", "/v1/chat/completions")
+
+		snapshot := tracker.Snapshot()
+		assert.Equal(t, 2, snapshot.ActiveRequests)
+		assert.Equal(t, 1, snapshot.ActiveStreams)
+		assert.Len(t, snapshot.Active, 2)
+		for _, active := range snapshot.Active {
+			if active.ID == "req-2" {
+				assert.True(t, active.Streaming)
+				assert.Equal(t, "claude-sonnet-4.5", active.Model)
+			}
+		}
+
+		doneRequest(200, "")
+		doneStream(200, "")
+		snapshot = tracker.Snapshot()
+		assert.Zero(t, snapshot.ActiveRequests)
+		assert.Empty(t, snapshot.Active)
+		assert.Equal(t, int64(2), snapshot.RequestsTotal)
+	})
+
+	t.Run("counts throughput over the last minute", func(t *testing.T) {
+		tracker, now := newTestTracker()
+
+		tracker.Begin(accesslog.NewEntry(""), "GET", "/v1/models")(200, "")
+		*now = now.Add(10 * time.Second)
+		tracker.Begin(accesslog.NewEntry(""), "GET", "/v1/models")(200, "")
+		tracker.Begin(accesslog.NewEntry(""), "GET", "/v1/models")(200, "")
+
+		snapshot := tracker.Snapshot()
+		assert.Equal(t, 3, snapshot.RequestsLastMinute)
+		assert.Len(t, snapshot.PerSecond, throughputWindow)
+		assert.Equal(t, 2, snapshot.PerSecond[throughputWindow-1])
+		assert.Equal(t, 1, snapshot.PerSecond[throughputWindow-11])
+
+		// Older seconds drop out, even when their slot is not reused
+		*now = now.Add(55 * time.Second)
+		snapshot = tracker.Snapshot()
+		assert.Equal(t, 2, snapshot.RequestsLastMinute)
+		*now = now.Add(time.Hour)
+		assert.Zero(t, tracker.Snapshot().RequestsLastMinute)
+		assert.Equal(t, int64(3), tracker.Snapshot().RequestsTotal)
+	})
+
+	t.Run("keeps the latest errors newest first", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+
+		for i := 0; i < maxRecentErrors+5; i++ {
+			tracker.Begin(accesslog.NewEntry(fmt.Sprintf("req-%d", i)), "POST", "/v1/messages")(500, fmt.Sprintf("error %d", i))
+		}
+		tracker.Begin(accesslog.NewEntry("ok"), "POST", "/v1/messages")(200, "")
+
+		errors := tracker.Snapshot().RecentErrors
+		assert.Len(t, errors, maxRecentErrors)
+		assert.Equal(t, fmt.Sprintf("req-%d", maxRecentErrors+4), errors[0].RequestID)
+		assert.Equal(t, 500, errors[0].Status)
+		assert.Equal(t, fmt.Sprintf("error %d", maxRecentErrors+4), errors[0].Message)
+		assert.Equal(t, "req-5", errors[len(errors)-1].RequestID)
+	})
+}
@@ -0,0 +1,179 @@
+// Package agent runs the builtin tools of agentic chat completions. When a request
+// declares builtin tools, the proxy executes their calls itself and sends the
+// results back to Kiro instead of returning the calls to the client.
+package agent
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/imagefetch"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// builtinTool is a tool the proxy runs itself
+type builtinTool struct {
+	description string
+	parameters  map[string]interface{}
+	run         func(r *Runner, ctx context.Context, args map[string]interface{}) (string, error)
+}
+
+var builtinTools = map[string]builtinTool{
+	converter.BuiltinToolWebFetch: {
+		description: "Fetch a public web page or text document over HTTP(S) and return its text content.",
+		parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"url": map[string]interface{}{"type": "string", "description": "The http or https URL to fetch"},
+			},
+			"required": []interface{}{"url"},
+		},
+		run: (*Runner).webFetch,
+	},
+	converter.BuiltinToolShell: {
+		description: "Run a shell command with sh -c and return its combined output and exit status.",
+		parameters: map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"command": map[string]interface{}{"type": "string", "description": "The command to run"},
+			},
+			"required": []interface{}{"command"},
+		},
+		run: (*Runner).shell,
+	},
+}
+
+// Runner executes builtin tool calls with the limits of AGENTIC_TOOL_TIMEOUT and
+// AGENTIC_TOOL_MAX_OUTPUT
+type Runner struct {
+	enabled   map[string]bool
+	client    *http.Client
+	timeout   time.Duration
+	maxOutput int
+}
+
+// NewRunner creates a runner for the tools enabled in AGENTIC_TOOLS
+func NewRunner(cfg *config.Config) *Runner {
+	return newRunner(cfg, imagefetch.CheckPublicAddress)
+}
+
+func newRunner(cfg *config.Config, checkAddress func(ip net.IP) error) *Runner {
+	enabled := make(map[string]bool, len(cfg.AgenticTools))
+	for _, name := range cfg.AgenticTools {
+		if _, ok := builtinTools[name]; !ok {
+			log.Warnf("Ignoring unknown agentic tool '%s'", name)
+			continue
+		}
+		enabled[name] = true
+	}
+
+	return &Runner{
+		enabled: enabled,
+		client: &http.Client{
+			Transport: &http.Transport{
+				// No proxy: the address check must apply to the fetched host itself
+				Proxy:       nil,
+				DialContext: imagefetch.NewDialer(checkAddress).DialContext,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return checkScheme(req.URL)
+			},
+		},
+		timeout:   time.Duration(cfg.AgenticToolTimeout * float64(time.Second)),
+		maxOutput: cfg.AgenticToolMaxOutput,
+	}
+}
+
+// Tools returns tools with each builtin tool replaced by its function definition.
+// It fails when a builtin tool is not enabled or a function has a builtin tool's name.
+func (r *Runner) Tools(tools []converter.OpenAITool) ([]converter.OpenAITool, error) {
+	declared := make(map[string]bool)
+	for _, tool := range tools {
+		if converter.IsBuiltinTool(tool.Type) {
+			declared[tool.Type] = true
+		}
+	}
+
+	expanded := make([]converter.OpenAITool, 0, len(tools))
+	for _, tool := range tools {
+		if !converter.IsBuiltinTool(tool.Type) {
+			if declared[tool.Function.Name] {
+				return nil, fmt.Errorf("function '%s' has the name of a builtin tool", tool.Function.Name)
+			}
+			expanded = append(expanded, tool)
+			continue
+		}
+		if !r.enabled[tool.Type] {
+			return nil, fmt.Errorf("builtin tool '%s' is not enabled", tool.Type)
+		}
+		builtin := builtinTools[tool.Type]
+		expanded = append(expanded, converter.OpenAITool{
+			Type: "function",
+			Function: converter.OpenAIFunctionDef{
+				Name:        tool.Type,
+				Description: builtin.description,
+				Parameters:  builtin.parameters,
+			},
+		})
+	}
+	return expanded, nil
+}
+
+// Run executes a builtin tool call and returns the result to send to the model.
+// Failures are reported in the result, so the model can react to them.
+func (r *Runner) Run(ctx context.Context, name, arguments string) string {
+	builtin, ok := builtinTools[name]
+	if !ok || !r.enabled[name] {
+		return fmt.Sprintf("Error: unknown tool '%s'", name)
+	}
+
+	args := map[string]interface{}{}
+	if strings.TrimSpace(arguments) != "" {
+		if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+			return fmt.Sprintf("Error: invalid arguments: %v", err)
+		}
+	}
+
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	log.Infof("Running builtin tool '%s'", name)
+	output, err := builtin.run(r, ctx, args)
+	if err != nil {
+		log.Warnf("Builtin tool '%s' failed: %v", name, err)
+		return fmt.Sprintf("Error: %v", err)
+	}
+	return r.truncate(output)
+}
+
+// truncate cuts output to the configured maximum
+func (r *Runner) truncate(output string) string {
+	if r.maxOutput <= 0 || len(output) <= r.maxOutput {
+		return output
+	}
+	// The cut may split a multi-byte character
+	return strings.ToValidUTF8(output[:r.maxOutput], "") + "\n[output truncated]"
+}
+
+// stringArg returns the non-empty string argument key
+func stringArg(args map[string]interface{}, key string) (string, error) {
+	value, _ := args[key].(string)
+	if strings.TrimSpace(value) == "" {
+		return "", fmt.Errorf("argument '%s' is required", key)
+	}
+	return value, nil
+}
@@ -0,0 +1,164 @@
+// Package agent provides tests for builtin tool execution.
+package agent
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/imagefetch"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestRunner allows loopback addresses so httptest servers are reachable
+func newTestRunner(tools ...string) *Runner {
+	return newRunner(&config.Config{
+		AgenticTools:         tools,
+		AgenticToolTimeout:   5,
+		AgenticToolMaxOutput: 1000,
+	}, func(net.IP) error { return nil })
+}
+
+func newPageServer(contentType, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+}
+
+// =============================================================================
+// TestTools
+// =============================================================================
+
+func TestTools(t *testing.T) {
+	t.Run("replaces builtin tools with function definitions", func(t *testing.T) {
+		tools := []converter.OpenAITool{
+			{Type: "function", Function: converter.OpenAIFunctionDef{Name: "get_weather"}},
+			{Type: converter.BuiltinToolWebFetch},
+		}
+
+		expanded, err := newTestRunner("web_fetch").Tools(tools)
+
+		assert.NoError(t, err)
+		assert.Len(t, expanded, 2)
+		assert.Equal(t, "get_weather", expanded[0].Function.Name)
+		assert.Equal(t, "function", expanded[1].Type)
+		assert.Equal(t, "web_fetch", expanded[1].Function.Name)
+		assert.Equal(t, []interface{}{"url"}, expanded[1].Function.Parameters["required"])
+	})
+
+	t.Run("rejects tools that are not enabled", func(t *testing.T) {
+		_, err := newTestRunner("web_fetch").Tools([]converter.OpenAITool{{Type: converter.BuiltinToolShell}})
+
+		assert.EqualError(t, err, "builtin tool 'shell' is not enabled")
+	})
+
+	t.Run("rejects functions named like a declared builtin tool", func(t *testing.T) {
+		tools := []converter.OpenAITool{
+			{Type: converter.BuiltinToolWebFetch},
+			{Type: "function", Function: converter.OpenAIFunctionDef{Name: "web_fetch"}},
+		}
+
+		_, err := newTestRunner("web_fetch").Tools(tools)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("builtin tools are disabled by default", func(t *testing.T) {
+		cfg, err := config.Load()
+		assert.NoError(t, err)
+		runner := NewRunner(cfg)
+
+		assert.Empty(t, runner.enabled)
+	})
+}
+
+// =============================================================================
+// TestRun
+// =============================================================================
+
+func TestRun(t *testing.T) {
+	t.Run("fetches text", func(t *testing.T) {
+		server := newPageServer("text/plain; charset=utf-8", "Plain text")
+		defer server.Close()
+
+		output := newTestRunner("web_fetch").Run(context.Background(), "web_fetch", `{"url": "`+server.URL+`"}`)
+
+		assert.Equal(t, "Plain text", output)
+	})
+
+	t.Run("reduces HTML to visible text", func(t *testing.T) {
+		server := newPageServer("text/html", `<html><head><title>T</title><style>p {}</style></head>
+			<body><script>alert(1)</script><h1>Title</h1><p>Fish &amp; chips</p></body></html>`)
+		defer server.Close()
+
+		output := newTestRunner("web_fetch").Run(context.Background(), "web_fetch", `{"url": "`+server.URL+`"}`)
+
+		assert.Equal(t, "Title\nFish & chips", output)
+	})
+
+	t.Run("reports fetch errors to the model", func(t *testing.T) {
+		server := newPageServer("image/png", "PNG")
+		defer server.Close()
+		runner := newTestRunner("web_fetch")
+
+		assert.Equal(t, `Error: content type "image/png" is not text`, runner.Run(context.Background(), "web_fetch", `{"url": "`+server.URL+`"}`))
+		assert.Equal(t, `Error: unsupported URL scheme "file"`, runner.Run(context.Background(), "web_fetch", `{"url": "file:///etc/passwd"}`))
+		assert.Equal(t, "Error: argument 'url' is required", runner.Run(context.Background(), "web_fetch", `{}`))
+	})
+
+	t.Run("blocks private addresses", func(t *testing.T) {
+		server := newPageServer("text/plain", "internal")
+		defer server.Close()
+		runner := newRunner(&config.Config{AgenticTools: []string{"web_fetch"}}, imagefetch.CheckPublicAddress)
+
+		output := runner.Run(context.Background(), "web_fetch", `{"url": "`+server.URL+`"}`)
+
+		assert.Contains(t, output, "address is not publicly routable")
+	})
+
+	t.Run("truncates long output", func(t *testing.T) {
+		server := newPageServer("text/plain", strings.Repeat("a", 2000))
+		defer server.Close()
+
+		output := newTestRunner("web_fetch").Run(context.Background(), "web_fetch", `{"url": "`+server.URL+`"}`)
+
+		assert.Equal(t, strings.Repeat("a", 1000)+"\n[output truncated]", output)
+	})
+
+	t.Run("runs shell commands with their exit status", func(t *testing.T) {
+		runner := newTestRunner("shell")
+
+		assert.Equal(t, "hello\n", runner.Run(context.Background(), "shell", `{"command": "echo hello"}`))
+		assert.Equal(t, "oops\n\n[exit status 3]", runner.Run(context.Background(), "shell", `{"command": "echo oops; exit 3"}`))
+	})
+
+	t.Run("keeps the gateway's secrets out of shell commands", func(t *testing.T) {
+		t.Setenv("PROXY_API_KEY", "secret-key")
+		t.Setenv("REFRESH_TOKEN", "secret-token")
+
+		output := newTestRunner("shell").Run(context.Background(), "shell", `{"command": "env"}`)
+
+		assert.NotContains(t, output, "secret-key")
+		assert.NotContains(t, output, "secret-token")
+		assert.Contains(t, output, "PATH=")
+	})
+
+	t.Run("bounds shell output while the command runs", func(t *testing.T) {
+		output := newTestRunner("shell").Run(context.Background(), "shell", `{"command": "yes | head -c 1000000"}`)
+
+		assert.Equal(t, strings.Repeat("y\n", 500)+"\n[output truncated]", output)
+	})
+
+	t.Run("refuses tools that are not enabled", func(t *testing.T) {
+		output := newTestRunner("web_fetch").Run(context.Background(), "shell", `{"command": "echo hello"}`)
+
+		assert.Equal(t, "Error: unknown tool 'shell'", output)
+	})
+}
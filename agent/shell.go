@@ -0,0 +1,79 @@
+package agent
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"time"
+)
+
+// shellEnvKeys are the environment variables commands inherit. The gateway's
+// own environment holds API keys, refresh tokens and credential paths, which a
+// prompt-injected command must not be able to read.
+var shellEnvKeys = []string{"PATH", "HOME", "LANG", "LC_ALL", "TMPDIR", "TZ"}
+
+// shell runs a command with sh -c and returns its combined output. A non-zero
+// exit status is part of the result rather than an error.
+func (r *Runner) shell(ctx context.Context, args map[string]interface{}) (string, error) {
+	command, err := stringArg(args, "command")
+	if err != nil {
+		return "", err
+	}
+
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = shellEnv()
+	// Background children may hold the output open after sh is killed
+	cmd.WaitDelay = time.Second
+	// Keep a byte past the limit so truncation is still reported
+	output := &limitedBuffer{limit: r.maxOutput + 1}
+	if r.maxOutput <= 0 {
+		output.limit = -1
+	}
+	cmd.Stdout, cmd.Stderr = output, output
+	err = cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("command timed out after %s", r.timeout)
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Sprintf("%s\n[exit status %d]", output, exitErr.ExitCode()), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return output.String(), nil
+}
+
+// shellEnv returns the scrubbed environment of a command
+func shellEnv() []string {
+	env := []string{}
+	for _, key := range shellEnvKeys {
+		if value, ok := os.LookupEnv(key); ok {
+			env = append(env, key+"="+value)
+		}
+	}
+	if _, ok := os.LookupEnv("PATH"); !ok {
+		env = append(env, "PATH=/usr/local/bin:/usr/bin:/bin")
+	}
+	return env
+}
+
+// limitedBuffer keeps the first limit bytes written to it (all of them when
+// limit is negative) and drops the rest, so a noisy command cannot fill memory
+type limitedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.limit >= 0 {
+		if room := b.limit - b.Len(); room < len(p) {
+			b.Buffer.Write(p[:max(room, 0)])
+			return len(p), nil
+		}
+	}
+	return b.Buffer.Write(p)
+}
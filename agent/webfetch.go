@@ -0,0 +1,107 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// maxRedirects is the number of redirects web_fetch follows before giving up
+const maxRedirects = 5
+
+var (
+	htmlHiddenBlocks = regexp.MustCompile(`(?is)<(script|style|noscript|head)\b.*?</(script|style|noscript|head)\s*>`)
+	htmlTags         = regexp.MustCompile(`(?s)<[^>]*>`)
+	horizontalSpace  = regexp.MustCompile(`[ \t\r\f\v]+`)
+	blankLines       = regexp.MustCompile(`\n\s*\n\s*`)
+)
+
+// webFetch downloads a public http(s) URL and returns its text. HTML pages are
+// reduced to their visible text.
+func (r *Runner) webFetch(ctx context.Context, args map[string]interface{}) (string, error) {
+	rawURL, err := stringArg(args, "url")
+	if err != nil {
+		return "", err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL: %w", err)
+	}
+	if err := checkScheme(u); err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "text/html,text/plain,application/json;q=0.9,*/*;q=0.5")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	mediaType = strings.ToLower(mediaType)
+	if !isTextMediaType(mediaType) {
+		return "", fmt.Errorf("content type %q is not text", mediaType)
+	}
+
+	// Read a little past the limit so truncation is still reported
+	limit := int64(r.maxOutput)
+	if mediaType == "text/html" {
+		// Markup is dropped, so allow more of the page
+		limit *= 4
+	}
+	reader := io.Reader(resp.Body)
+	if limit > 0 {
+		reader = io.LimitReader(resp.Body, limit+1)
+	}
+	body, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %w", err)
+	}
+
+	if mediaType == "text/html" {
+		return htmlText(string(body)), nil
+	}
+	return string(body), nil
+}
+
+// isTextMediaType reports whether web_fetch returns content of mediaType. A
+// missing content type is assumed to be text.
+func isTextMediaType(mediaType string) bool {
+	return mediaType == "" ||
+		strings.HasPrefix(mediaType, "text/") ||
+		mediaType == "application/json" || mediaType == "application/xml" ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml")
+}
+
+// htmlText returns the visible text of an HTML page
+func htmlText(page string) string {
+	text := htmlHiddenBlocks.ReplaceAllString(page, "")
+	text = htmlTags.ReplaceAllString(text, "\n")
+	text = html.UnescapeString(text)
+	text = horizontalSpace.ReplaceAllString(text, " ")
+	text = blankLines.ReplaceAllString(text, "\n")
+	return strings.TrimSpace(text)
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	return nil
+}
@@ -0,0 +1,319 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/auth"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+	"kiro-go-proxy/parser"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// setupAdminRoutes registers the /admin group, authenticated with ADMIN_API_KEY
+func (s *Server) setupAdminRoutes(r *gin.Engine) {
+	admin := r.Group("/admin")
+	admin.Use(s.AdminAuthMiddleware())
+	{
+		admin.GET("/auth", s.AdminAuthStatusHandler)
+		admin.POST("/auth/refresh", s.AdminRefreshTokensHandler)
+		admin.POST("/models/reload", s.AdminReloadModelsHandler)
+		admin.GET("/models/aliases", s.AdminListAliasesHandler)
+		admin.PUT("/models/aliases/:name", s.AdminSetAliasHandler)
+		admin.DELETE("/models/aliases/:name", s.AdminDeleteAliasHandler)
+		admin.GET("/models/hidden", s.AdminListHiddenModelsHandler)
+		admin.PUT("/models/hidden/:name", s.AdminSetHiddenModelHandler)
+		admin.DELETE("/models/hidden/:name", s.AdminDeleteHiddenModelHandler)
+		admin.GET("/fake-reasoning", s.AdminFakeReasoningHandler)
+		admin.PUT("/fake-reasoning", s.AdminSetFakeReasoningHandler)
+		admin.POST("/reload", s.AdminReloadConfigHandler)
+		admin.GET("/audit", s.AdminAuditHandler)
+	}
+}
+
+// AdminAuthMiddleware validates the admin API key. The admin API is disabled
+// unless ADMIN_API_KEY is set.
+func (s *Server) AdminAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminAPIKey := s.currentConfig().AdminAPIKey
+		if adminAPIKey == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": gin.H{
+					"message": "Admin API is disabled. Set ADMIN_API_KEY to enable it",
+					"type":    "permission_error",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		if s.rejectLockedOut(c) {
+			return
+		}
+
+		apiKey := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !keysEqual(apiKey, adminAPIKey) {
+			s.recordAuthFailure(c)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"message": "Invalid admin API key",
+					"type":    "invalid_request_error",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		s.AuthLockout.Success(c.ClientIP())
+		c.Next()
+	}
+}
+
+// adminAccountStatus extends pool health with token state
+type adminAccountStatus struct {
+	auth.AccountStatus
+	TokenExpiresAt *time.Time `json:"token_expires_at,omitempty"`
+	TokenExpired   bool       `json:"token_expired"`
+}
+
+// AdminAuthStatusHandler handles GET /admin/auth
+func (s *Server) AdminAuthStatusHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"accounts": s.adminAccounts(),
+	})
+}
+
+// adminAccounts returns the pool health and token state of every account
+// without refreshing tokens
+func (s *Server) adminAccounts() []adminAccountStatus {
+	statuses := s.CredentialPool.Status()
+	managers := s.CredentialPool.Managers()
+
+	accounts := make([]adminAccountStatus, 0, len(statuses))
+	for i, status := range statuses {
+		account := adminAccountStatus{AccountStatus: status}
+		if i < len(managers) {
+			if expiresAt := managers[i].ExpiresAt(); !expiresAt.IsZero() {
+				account.TokenExpiresAt = &expiresAt
+			}
+			account.TokenExpired = managers[i].IsTokenExpired()
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// AdminRefreshTokensHandler handles POST /admin/auth/refresh, forcing a token refresh on every account
+func (s *Server) AdminRefreshTokensHandler(c *gin.Context) {
+	statuses := s.CredentialPool.Status()
+	results := make([]gin.H, 0, len(statuses))
+
+	for i, manager := range s.CredentialPool.Managers() {
+		result := gin.H{"name": statuses[i].Name, "refreshed": true}
+		if _, err := manager.ForceRefresh(); err != nil {
+			log.Warnf("Admin token refresh failed for '%s': %v", statuses[i].Name, err)
+			result["refreshed"] = false
+			result["error"] = err.Error()
+		} else {
+			result["token_expires_at"] = manager.ExpiresAt()
+		}
+		results = append(results, result)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"object":   "list",
+		"accounts": results,
+	})
+}
+
+// AdminReloadModelsHandler handles POST /admin/models/reload
+func (s *Server) AdminReloadModelsHandler(c *gin.Context) {
+	count, added, removed, err := s.ModelRefresher.Refresh()
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Failed to reload models: %v", err),
+				"type":    "api_error",
+			},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"loaded":  count,
+		"added":   added,
+		"removed": removed,
+		"models":  s.ModelResolver.GetAvailableModels(),
+	})
+}
+
+// fetchModels fetches the model list from the Kiro API
+func (s *Server) fetchModels() ([]model.Info, error) {
+	return s.HttpClient.ListModels(context.Background())
+}
+
+// AdminListAliasesHandler handles GET /admin/models/aliases
+func (s *Server) AdminListAliasesHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"aliases": s.ModelResolver.Aliases()})
+}
+
+// AdminSetAliasHandler handles PUT /admin/models/aliases/:name with body {"target": "..."}
+func (s *Server) AdminSetAliasHandler(c *gin.Context) {
+	var body struct {
+		Target string `json:"target"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.Target == "" {
+		adminBadRequest(c, `Request body must be {"target": "<model>"}`)
+		return
+	}
+
+	name := c.Param("name")
+	s.ModelResolver.SetAlias(name, body.Target)
+	log.Infof("Admin set model alias '%s' -> '%s'", name, body.Target)
+
+	c.JSON(http.StatusOK, gin.H{"aliases": s.ModelResolver.Aliases()})
+}
+
+// AdminDeleteAliasHandler handles DELETE /admin/models/aliases/:name
+func (s *Server) AdminDeleteAliasHandler(c *gin.Context) {
+	name := c.Param("name")
+	if !s.ModelResolver.DeleteAlias(name) {
+		adminNotFound(c, fmt.Sprintf("Alias '%s' not found", name))
+		return
+	}
+	log.Infof("Admin removed model alias '%s'", name)
+
+	c.JSON(http.StatusOK, gin.H{"aliases": s.ModelResolver.Aliases()})
+}
+
+// AdminListHiddenModelsHandler handles GET /admin/models/hidden
+func (s *Server) AdminListHiddenModelsHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"hidden_models": s.ModelResolver.HiddenModels()})
+}
+
+// AdminSetHiddenModelHandler handles PUT /admin/models/hidden/:name with body {"internal_id": "..."}
+func (s *Server) AdminSetHiddenModelHandler(c *gin.Context) {
+	var body struct {
+		InternalID string `json:"internal_id"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || body.InternalID == "" {
+		adminBadRequest(c, `Request body must be {"internal_id": "<kiro model id>"}`)
+		return
+	}
+
+	name := c.Param("name")
+	s.ModelResolver.SetHiddenModel(name, body.InternalID)
+	log.Infof("Admin set hidden model '%s' -> '%s'", name, body.InternalID)
+
+	c.JSON(http.StatusOK, gin.H{"hidden_models": s.ModelResolver.HiddenModels()})
+}
+
+// AdminDeleteHiddenModelHandler handles DELETE /admin/models/hidden/:name
+func (s *Server) AdminDeleteHiddenModelHandler(c *gin.Context) {
+	name := c.Param("name")
+	if !s.ModelResolver.DeleteHiddenModel(name) {
+		adminNotFound(c, fmt.Sprintf("Hidden model '%s' not found", name))
+		return
+	}
+	log.Infof("Admin removed hidden model '%s'", name)
+
+	c.JSON(http.StatusOK, gin.H{"hidden_models": s.ModelResolver.HiddenModels()})
+}
+
+// AdminFakeReasoningHandler handles GET /admin/fake-reasoning
+func (s *Server) AdminFakeReasoningHandler(c *gin.Context) {
+	cfg := s.currentConfig()
+	c.JSON(http.StatusOK, gin.H{
+		"enabled":    cfg.FakeReasoningEnabled,
+		"max_tokens": cfg.FakeReasoningMaxTokens,
+		"handling":   cfg.FakeReasoningHandling,
+		"tags":       cfg.FakeReasoningOpenTags,
+	})
+}
+
+// AdminSetFakeReasoningHandler handles PUT /admin/fake-reasoning with body
+// {"enabled": bool, "tags": [...]}; either field may be left out, and empty tags
+// restore the default ones. The change applies to requests started afterwards.
+func (s *Server) AdminSetFakeReasoningHandler(c *gin.Context) {
+	var body struct {
+		Enabled *bool    `json:"enabled"`
+		Tags    []string `json:"tags"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil || (body.Enabled == nil && body.Tags == nil) {
+		adminBadRequest(c, `Request body must be {"enabled": true|false, "tags": ["<open>", "<open>|<close>", ...]}`)
+		return
+	}
+	for _, spec := range body.Tags {
+		if _, _, err := parser.ParseThinkingTag(spec); err != nil {
+			adminBadRequest(c, err.Error())
+			return
+		}
+	}
+	if body.Tags != nil && len(body.Tags) == 0 {
+		body.Tags = append([]string(nil), parser.DefaultThinkingTags...)
+	}
+
+	s.updateConfig(func(cfg *config.Config) {
+		if body.Enabled != nil {
+			cfg.FakeReasoningEnabled = *body.Enabled
+		}
+		if body.Tags != nil {
+			cfg.FakeReasoningOpenTags = body.Tags
+		}
+	})
+	if body.Enabled != nil {
+		log.Infof("Admin set fake reasoning enabled=%v", *body.Enabled)
+	}
+	if body.Tags != nil {
+		log.Infof("Admin set fake reasoning tags=%v", body.Tags)
+	}
+
+	s.AdminFakeReasoningHandler(c)
+}
+
+// AdminReloadConfigHandler handles POST /admin/reload, re-reading the config file and
+// credentials like SIGHUP. Model alias and hidden model changes made through the admin
+// API are replaced by the reloaded configuration.
+func (s *Server) AdminReloadConfigHandler(c *gin.Context) {
+	changes, err := s.ReloadConfig()
+	if err != nil {
+		log.Errorf("Admin config reload failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Failed to reload configuration: %v", err),
+				"type":    "internal_error",
+			},
+		})
+		return
+	}
+
+	if changes == nil {
+		changes = []string{}
+	}
+	c.JSON(http.StatusOK, gin.H{"changes": changes})
+}
+
+func adminBadRequest(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    "invalid_request_error",
+		},
+	})
+}
+
+func adminNotFound(c *gin.Context, message string) {
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": gin.H{
+			"message": message,
+			"type":    "not_found_error",
+		},
+	})
+}
@@ -0,0 +1,216 @@
+// Package api provides tests for the admin API.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"kiro-go-proxy/parser"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newAdminTestServer creates a test server with the admin API enabled
+func newAdminTestServer() (*Server, *gin.Engine) {
+	server, router := newTestServer("test-key")
+	server.Cfg.AdminAPIKey = "admin-key"
+	return server, router
+}
+
+func adminRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer admin-key")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// =============================================================================
+// TestAdminAuthMiddleware
+// =============================================================================
+
+func TestAdminAuthMiddleware(t *testing.T) {
+	t.Run("disabled without admin key", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := adminRequest(router, "GET", "/admin/auth", "")
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), "ADMIN_API_KEY")
+	})
+
+	t.Run("rejects proxy key", func(t *testing.T) {
+		_, router := newAdminTestServer()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/admin/auth", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("accepts admin key", func(t *testing.T) {
+		_, router := newAdminTestServer()
+
+		w := adminRequest(router, "GET", "/admin/auth", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"token_expired":true`)
+	})
+}
+
+// =============================================================================
+// TestAdminModelAliases
+// =============================================================================
+
+func TestAdminModelAliases(t *testing.T) {
+	t.Run("sets, resolves and deletes alias", func(t *testing.T) {
+		server, router := newAdminTestServer()
+
+		w := adminRequest(router, "PUT", "/admin/models/aliases/fast", `{"target": "claude-haiku-4.5"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "claude-haiku-4.5", server.ModelResolver.Resolve("fast").InternalID)
+
+		w = adminRequest(router, "GET", "/admin/models/aliases", "")
+		var body struct {
+			Aliases map[string]string `json:"aliases"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "claude-haiku-4.5", body.Aliases["fast"])
+
+		w = adminRequest(router, "DELETE", "/admin/models/aliases/fast", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, server.ModelResolver.Aliases(), "fast")
+	})
+
+	t.Run("rejects missing target", func(t *testing.T) {
+		_, router := newAdminTestServer()
+
+		w := adminRequest(router, "PUT", "/admin/models/aliases/fast", `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("returns 404 for unknown alias", func(t *testing.T) {
+		_, router := newAdminTestServer()
+
+		w := adminRequest(router, "DELETE", "/admin/models/aliases/unknown", "")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+// =============================================================================
+// TestAdminHiddenModels
+// =============================================================================
+
+func TestAdminHiddenModels(t *testing.T) {
+	server, router := newAdminTestServer()
+
+	w := adminRequest(router, "PUT", "/admin/models/hidden/secret-model", `{"internal_id": "SECRET_MODEL_V1"}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "SECRET_MODEL_V1", server.ModelResolver.Resolve("secret-model").InternalID)
+	assert.Contains(t, server.ModelResolver.GetAvailableModels(), "secret-model")
+
+	w = adminRequest(router, "DELETE", "/admin/models/hidden/secret-model", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, server.ModelResolver.HiddenModels(), "secret-model")
+}
+
+// =============================================================================
+// TestAdminFakeReasoning
+// =============================================================================
+
+func TestAdminFakeReasoning(t *testing.T) {
+	t.Run("toggles fake reasoning", func(t *testing.T) {
+		server, router := newAdminTestServer()
+		server.Cfg.FakeReasoningEnabled = true
+
+		w := adminRequest(router, "PUT", "/admin/fake-reasoning", `{"enabled": false}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.False(t, server.Cfg.FakeReasoningEnabled)
+		assert.Contains(t, w.Body.String(), `"enabled":false`)
+	})
+
+	t.Run("sets thinking tags", func(t *testing.T) {
+		server, router := newAdminTestServer()
+		server.Cfg.FakeReasoningEnabled = true
+
+		w := adminRequest(router, "PUT", "/admin/fake-reasoning", `{"tags": ["<think>", "[[R]]|[[/R]]"]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, []string{"<think>", "[[R]]|[[/R]]"}, server.Cfg.FakeReasoningOpenTags)
+		assert.True(t, server.Cfg.FakeReasoningEnabled)
+	})
+
+	t.Run("restores default tags", func(t *testing.T) {
+		server, router := newAdminTestServer()
+		server.Cfg.FakeReasoningOpenTags = []string{"<think>"}
+
+		w := adminRequest(router, "PUT", "/admin/fake-reasoning", `{"tags": []}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, parser.DefaultThinkingTags, server.Cfg.FakeReasoningOpenTags)
+	})
+
+	t.Run("rejects invalid tags", func(t *testing.T) {
+		server, router := newAdminTestServer()
+		server.Cfg.FakeReasoningOpenTags = []string{"<think>"}
+
+		w := adminRequest(router, "PUT", "/admin/fake-reasoning", `{"tags": ["<think>|"]}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, []string{"<think>"}, server.Cfg.FakeReasoningOpenTags)
+	})
+
+	t.Run("requires a setting", func(t *testing.T) {
+		_, router := newAdminTestServer()
+
+		w := adminRequest(router, "PUT", "/admin/fake-reasoning", `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
+
+// =============================================================================
+// TestAdminReloadConfig
+// =============================================================================
+
+func TestAdminReloadConfig(t *testing.T) {
+	for _, key := range []string{"PROXY_API_KEY", "ADMIN_API_KEY", "CONFIG_FILE"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	t.Run("reloads and lists changes", func(t *testing.T) {
+		server, router := newAdminTestServer()
+		server.ConfigFile = writeReloadConfig(t, "proxy_api_key: test-key\nadmin_api_key: admin-key\nhidden_models:\n  display-name: internal-id\n")
+
+		w := adminRequest(router, "POST", "/admin/reload", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			Changes []string `json:"changes"`
+		}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.Contains(t, body.Changes, "hidden_models[display-name]: added 'internal-id'")
+		assert.Equal(t, "internal-id", server.ModelResolver.HiddenModels()["display-name"])
+	})
+
+	t.Run("reports invalid config file", func(t *testing.T) {
+		server, router := newAdminTestServer()
+		server.ConfigFile = writeReloadConfig(t, "server_port: eighty\n")
+
+		w := adminRequest(router, "POST", "/admin/reload", "")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), "server_port")
+	})
+}
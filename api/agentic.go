@@ -0,0 +1,144 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/convstore"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/transcript"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// agenticStepsHeader reports how many Kiro requests an agentic chat completion took
+const agenticStepsHeader = "x-kiro-agentic-steps"
+
+// handleAgenticChatCompletion answers a chat completion that declares builtin
+// tools. Calls of builtin tools are run by the proxy and their results sent back
+// to Kiro until the model answers without one, at most AGENTIC_MAX_STEPS times;
+// only that final assistant message is returned. A response that also calls one
+// of the client's functions is returned as is.
+func (s *Server) handleAgenticChatCompletion(c *gin.Context, req *converter.OpenAIRequest) {
+	ctx := c.Request.Context()
+	if req.Stream {
+		c.JSON(http.StatusBadRequest, openAIValidationBody(&converter.FieldError{Field: "stream", Message: "builtin tools are not supported with streaming"}))
+		return
+	}
+	if req.Choices() > 1 {
+		c.JSON(http.StatusBadRequest, openAIValidationBody(&converter.FieldError{Field: "n", Message: "builtin tools support a single choice"}))
+		return
+	}
+
+	tools, err := s.Agent.Tools(req.Tools)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, openAIValidationBody(&converter.FieldError{Field: "tools", Message: err.Error()}))
+		return
+	}
+	builtin := make(map[string]bool)
+	for _, name := range req.BuiltinTools() {
+		builtin[name] = true
+	}
+
+	// Each step resends the conversation so far, with the tool calls and results
+	loopReq := *req
+	loopReq.Tools = tools
+	loopReq.Messages = append([]converter.OpenAIMessage(nil), req.Messages...)
+
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+	maxSteps := s.currentConfig().AgenticMaxSteps
+	var conversationID string
+	var totalUsage converter.OpenAIUsage
+
+	for step := 1; step <= maxSteps; step++ {
+		prepared, status, errBody := s.prepareChatCompletion(ctx, &loopReq)
+		if prepared == nil {
+			c.JSON(status, errBody)
+			return
+		}
+		if conversationID == "" {
+			conversationID = prepared.conversationID
+		}
+
+		result, status, errBody := s.collectFormattedCompletion(ctx, prepared.cfg, apiURL, prepared.payload, prepared.limits, req.ResponseFormat)
+		if result == nil {
+			c.JSON(status, errBody)
+			return
+		}
+
+		// Every step is a Kiro request of its own and counts towards usage
+		completionTokens := stream.CountCompletionTokens(result.Content, result.ThinkingContent, result.ToolCalls)
+		promptTokens, totalTokens, _, _ := stream.CalculateTokensFromContextUsage(
+			result.ContextUsagePercentage,
+			completionTokens,
+			prepared.promptTokens,
+			s.ModelCache,
+			req.Model,
+		)
+		usage.FromContext(ctx).AddTokens(promptTokens, completionTokens)
+		totalUsage.PromptTokens += promptTokens
+		totalUsage.CompletionTokens += completionTokens
+		totalUsage.TotalTokens += totalTokens
+
+		toolCalls := convertParserToolCalls(result.ToolCalls)
+		if !onlyBuiltinCalls(toolCalls, builtin) {
+			content, reasoning := replyContent(prepared.cfg, result)
+			convstore.FromContext(ctx).SetReply(result.Content, result.ToolCalls)
+			transcript.FromContext(ctx).SetReply(content, reasoning, result.ToolCalls)
+			response := converter.CreateOpenAIResponse(
+				conversationID,
+				req.Model,
+				content,
+				toolCalls,
+				stream.OpenAIFinishReason(result.StopReason),
+				&totalUsage,
+			)
+			response.Choices[0].Message.ReasoningContent = reasoning
+			response.KiroCredits = requestCredits(ctx)
+			response.KiroModel = servedModel(ctx, response.Model)
+			c.Header(agenticStepsHeader, strconv.Itoa(step))
+			c.JSON(http.StatusOK, response)
+			return
+		}
+
+		assistant := converter.OpenAIMessage{Role: "assistant", Content: result.Content}
+		for _, call := range toolCalls {
+			assistant.ToolCalls = append(assistant.ToolCalls, converter.OpenAIToolCall{
+				ID:       call.ID,
+				Type:     "function",
+				Function: converter.OpenAIFunction{Name: call.Function.Name, Arguments: call.Function.Arguments},
+			})
+		}
+		loopReq.Messages = append(loopReq.Messages, assistant)
+		for _, call := range toolCalls {
+			loopReq.Messages = append(loopReq.Messages, converter.OpenAIMessage{
+				Role:       "tool",
+				ToolCallID: call.ID,
+				Content:    s.Agent.Run(ctx, call.Function.Name, call.Function.Arguments),
+			})
+		}
+		log.Debugf("Agentic step %d ran %d builtin tool call(s)", step, len(toolCalls))
+	}
+
+	c.JSON(http.StatusBadGateway, gin.H{
+		"error": gin.H{
+			"message": fmt.Sprintf("Model did not finish within %d agentic steps", maxSteps),
+			"type":    "invalid_response_error",
+		},
+	})
+}
+
+// onlyBuiltinCalls reports whether there are tool calls and all of them call
+// builtin tools
+func onlyBuiltinCalls(calls []converter.ToolCall, builtin map[string]bool) bool {
+	for _, call := range calls {
+		if !builtin[call.Function.Name] {
+			return false
+		}
+	}
+	return len(calls) > 0
+}
@@ -0,0 +1,121 @@
+// Package api provides tests for agentic chat completions.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kiro-go-proxy/agent"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newAgenticTestServer returns a test server with the shell tool enabled and the
+// given Kiro responses queued
+func newAgenticTestServer(responses ...clienttest.Response) (*clienttest.Fake, http.Handler) {
+	server, router := newTestServer("test-key")
+	server.Cfg.AgenticMaxSteps = 3
+	server.Agent = agent.NewRunner(&config.Config{AgenticTools: []string{"shell"}, AgenticToolTimeout: 5})
+	fake := clienttest.NewFake(responses...)
+	server.HttpClient = fake
+	return fake, router
+}
+
+func shellCallStream(id, command string) clienttest.Response {
+	input := mustMarshal(map[string]string{"command": command})
+	return clienttest.Stream(
+		`{"name":"shell","toolUseId":"`+id+`"}`,
+		`{"input":`+string(mustMarshal(string(input)))+`}`,
+		`{"stop":true}`,
+	)
+}
+
+func mustMarshal(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// =============================================================================
+// TestAgenticChatCompletion
+// =============================================================================
+
+func TestAgenticChatCompletion(t *testing.T) {
+	t.Run("runs builtin tools until the final answer", func(t *testing.T) {
+		fake, router := newAgenticTestServer(
+			shellCallStream("toolu_1", "echo 42"),
+			clienttest.Stream(`{"content":"The answer is 42."}`),
+		)
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Run echo 42"}],"tools":[{"type":"shell"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "2", w.Header().Get(agenticStepsHeader))
+		var resp converter.OpenAIResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "The answer is 42.", resp.Choices[0].Message.Content)
+		assert.Empty(t, resp.Choices[0].Message.ToolCalls)
+		assert.Equal(t, "stop", resp.Choices[0].FinishReason)
+
+		requests := fake.Requests()
+		assert.Len(t, requests, 2)
+		first := requests[0].Payload.(*converter.KiroPayload).ConversationState.CurrentMessage.UserInputMessage.UserInputMessageContext
+		assert.Equal(t, "shell", first.Tools[0]["toolSpecification"].(map[string]interface{})["name"])
+		second := requests[1].Payload.(*converter.KiroPayload).ConversationState.CurrentMessage.UserInputMessage.UserInputMessageContext
+		assert.Equal(t, "toolu_1", second.ToolResults[0]["toolUseId"])
+		assert.Contains(t, string(mustMarshal(second.ToolResults[0]["content"])), `42\n`)
+	})
+
+	t.Run("returns calls of client functions", func(t *testing.T) {
+		fake, router := newAgenticTestServer(clienttest.Stream(
+			`{"name":"get_weather","toolUseId":"toolu_1"}`,
+			`{"input":"{\"city\": \"Paris\"}"}`,
+			`{"stop":true}`,
+		))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Weather?"}],
+			"tools":[{"type":"shell"},{"type":"function","function":{"name":"get_weather","parameters":{"type":"object"}}}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp converter.OpenAIResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "get_weather", resp.Choices[0].Message.ToolCalls[0].Function.Name)
+		assert.Equal(t, "tool_calls", resp.Choices[0].FinishReason)
+		assert.Len(t, fake.Requests(), 1)
+	})
+
+	t.Run("stops after AGENTIC_MAX_STEPS", func(t *testing.T) {
+		fake, router := newAgenticTestServer(
+			shellCallStream("toolu_1", "true"),
+			shellCallStream("toolu_2", "true"),
+			shellCallStream("toolu_3", "true"),
+		)
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Loop"}],"tools":[{"type":"shell"}]}`)
+
+		assert.Equal(t, http.StatusBadGateway, w.Code)
+		assert.Len(t, fake.Requests(), 3)
+	})
+
+	t.Run("rejects tools that are not enabled", func(t *testing.T) {
+		fake, router := newAgenticTestServer()
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Fetch it"}],"tools":[{"type":"web_fetch"}]}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "builtin tool 'web_fetch' is not enabled")
+		assert.Empty(t, fake.Requests())
+	})
+
+	t.Run("rejects streaming", func(t *testing.T) {
+		_, router := newAgenticTestServer()
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"Hi"}],"tools":[{"type":"shell"}]}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"param":"stream"`)
+	})
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/audit"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/piifilter"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// AuditMiddleware writes the client request and response of each request to
+// the audit log, at the redaction level of the request's API key. The response
+// is hashed as it streams and kept in memory only at the pii and full levels.
+// The record is written after the response has been sent, so a failed write
+// cannot fail the request: it is logged and the record is lost.
+func (s *Server) AuditMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cfg := s.currentConfig()
+		apiKey := c.GetString(apiKeyContextKey)
+		level := cfg.AuditRedactionFor(apiKey)
+		if s.Audit == nil || level == audit.LevelOff {
+			c.Next()
+			return
+		}
+		if level == "" {
+			level = audit.LevelMetadata
+		}
+
+		prompt, err := io.ReadAll(c.Request.Body)
+		if err == nil {
+			c.Request.Body = io.NopCloser(bytes.NewReader(prompt))
+		}
+		writer := &auditWriter{ResponseWriter: c.Writer, completion: audit.NewCompletion(level)}
+		c.Writer = writer
+
+		c.Next()
+
+		entry := accesslog.FromContext(c.Request.Context())
+		record := &audit.Record{
+			Time:          entry.Start,
+			RequestID:     entry.ID,
+			KeyID:         usage.KeyID(apiKey),
+			Method:        c.Request.Method,
+			Path:          c.Request.URL.Path,
+			Status:        c.Writer.Status(),
+			ResolvedModel: entry.ResolvedModel(),
+			DurationMs:    time.Since(entry.Start).Milliseconds(),
+		}
+		model, totals := usage.FromContext(c.Request.Context()).Result()
+		record.Model = model
+		record.PromptTokens = totals.PromptTokens
+		record.CompletionTokens = totals.CompletionTokens
+
+		var filter *piifilter.Filter
+		if level == audit.LevelPII {
+			filter = s.auditFilter.get(cfg)
+		}
+		record.SetContent(level, prompt, writer.completion, filter)
+		if err := s.Audit.Append(record); err != nil {
+			log.Errorf("Failed to write audit record, request %s is not in the audit log: %v", entry.ID, err)
+		}
+	}
+}
+
+// auditWriter passes the client response to the audit record as it is written
+type auditWriter struct {
+	gin.ResponseWriter
+	completion *audit.Completion
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	w.completion.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(data string) (int, error) {
+	w.completion.Write([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *auditWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// auditFilterCache holds the PII filter of the audit log, built once per config
+type auditFilterCache struct {
+	mu     sync.Mutex
+	cfg    *config.Config
+	filter *piifilter.Filter
+}
+
+// get returns the filter for cfg, building it when the config has been replaced
+func (f *auditFilterCache) get(cfg *config.Config) *piifilter.Filter {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.cfg != cfg {
+		patterns := cfg.PIIFilterPatterns
+		if len(patterns) == 0 {
+			patterns = piifilter.BuiltinPatterns()
+		}
+		// Patterns are validated with the config, so the filter always builds
+		f.filter, _ = piifilter.New(patterns, cfg.PIIFilterCustomPatterns)
+		f.cfg = cfg
+	}
+	return f.filter
+}
+
+// AdminAuditHandler handles GET /admin/audit. Records can be filtered with
+// from and to (RFC 3339, to is exclusive), key_id (as in /v1/usage) and limit.
+// The hash chain of the whole log is verified with every query.
+func (s *Server) AdminAuditHandler(c *gin.Context) {
+	if s.Audit == nil {
+		adminNotFound(c, "Audit log is disabled. Set AUDIT_FILE to enable it")
+		return
+	}
+
+	query := audit.Query{KeyID: c.Query("key_id")}
+	for name, field := range map[string]*time.Time{"from": &query.From, "to": &query.To} {
+		if value := c.Query(name); value != "" {
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				adminBadRequest(c, fmt.Sprintf("'%s' must be an RFC 3339 time, got '%s'", name, value))
+				return
+			}
+			*field = t
+		}
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 0 {
+			adminBadRequest(c, fmt.Sprintf("'limit' must be a non-negative integer, got '%s'", value))
+			return
+		}
+		query.Limit = limit
+	}
+
+	records, verification, err := s.Audit.Search(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Failed to read audit log: %v", err),
+				"type":    "internal_error",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   records,
+		"chain":  verification,
+	})
+}
@@ -0,0 +1,122 @@
+// Package api provides tests for the audit log.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"kiro-go-proxy/audit"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newAuditTestServer returns a test server with the admin API and an audit log
+// at the given redaction level
+func newAuditTestServer(t *testing.T, level string) (*Server, *gin.Engine) {
+	server, router := newAdminTestServer()
+	server.Cfg.AuditFile = filepath.Join(t.TempDir(), "audit.jsonl")
+	server.Cfg.AuditRedaction = level
+	server.Audit = audit.NewLog(server.Cfg)
+	server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Sent to jane@example.com"}`))
+	return server, router
+}
+
+type auditListResponse struct {
+	Data  []audit.Record     `json:"data"`
+	Chain audit.Verification `json:"chain"`
+}
+
+// =============================================================================
+// TestAudit
+// =============================================================================
+
+func TestAudit(t *testing.T) {
+	chat := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Email jane@example.com"}]}`
+
+	t.Run("records requests with redacted content", func(t *testing.T) {
+		_, router := newAuditTestServer(t, "pii")
+
+		assert.Equal(t, http.StatusOK, postJSON(router, "/v1/chat/completions", chat).Code)
+		w := adminRequest(router, "GET", "/admin/audit", "")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp auditListResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, resp.Chain.Valid)
+		assert.Len(t, resp.Data, 1)
+		record := resp.Data[0]
+		assert.Equal(t, usage.KeyID("test-key"), record.KeyID)
+		assert.Equal(t, "/v1/chat/completions", record.Path)
+		assert.Equal(t, http.StatusOK, record.Status)
+		assert.Equal(t, "claude-sonnet-4", record.Model)
+		assert.Equal(t, "pii", record.Redaction)
+		assert.Contains(t, record.Prompt, "Email [REDACTED:email]")
+		assert.Contains(t, record.Completion, "Sent to [REDACTED:email]")
+		assert.NotContains(t, w.Body.String(), "jane@example.com")
+	})
+
+	t.Run("builds the PII filter once per config", func(t *testing.T) {
+		server, router := newAuditTestServer(t, "pii")
+		postJSON(router, "/v1/chat/completions", chat)
+		filter := server.auditFilter.get(server.Cfg)
+
+		postJSON(router, "/v1/chat/completions", chat)
+		assert.Same(t, filter, server.auditFilter.get(server.Cfg))
+
+		reloaded := *server.Cfg
+		assert.NotSame(t, filter, server.auditFilter.get(&reloaded))
+	})
+
+	t.Run("uses the level of the API key", func(t *testing.T) {
+		server, router := newAuditTestServer(t, "full")
+		server.Cfg.AuditKeys = map[string]string{"test-key": "off"}
+
+		postJSON(router, "/v1/chat/completions", chat)
+		records, _, err := server.Audit.Search(audit.Query{})
+
+		assert.NoError(t, err)
+		assert.Empty(t, records)
+	})
+
+	t.Run("filters by time and key", func(t *testing.T) {
+		_, router := newAuditTestServer(t, "metadata")
+		postJSON(router, "/v1/chat/completions", chat)
+
+		w := adminRequest(router, "GET", "/admin/audit?from=2000-01-01T00:00:00Z&to=2001-01-01T00:00:00Z", "")
+		var resp auditListResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Empty(t, resp.Data)
+
+		w = adminRequest(router, "GET", "/admin/audit?key_id="+usage.KeyID("test-key"), "")
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Len(t, resp.Data, 1)
+		assert.Empty(t, resp.Data[0].Prompt)
+		assert.NotEmpty(t, resp.Data[0].PromptSHA256)
+	})
+
+	t.Run("rejects invalid times", func(t *testing.T) {
+		_, router := newAuditTestServer(t, "metadata")
+
+		w := adminRequest(router, "GET", "/admin/audit?from=yesterday", "")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "RFC 3339")
+	})
+
+	t.Run("returns 404 when disabled", func(t *testing.T) {
+		server, router := newAdminTestServer()
+		server.Audit = audit.NewLog(&config.Config{})
+
+		w := adminRequest(router, "GET", "/admin/audit", "")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "AUDIT_FILE")
+	})
+}
@@ -0,0 +1,254 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kiro-go-proxy/batch"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// batchSlotPoll is how often a batch request retries for a free concurrency slot
+// of its API key
+const batchSlotPoll = 100 * time.Millisecond
+
+// List page sizes of GET /v1/messages/batches
+const (
+	defaultBatchListLimit = 20
+	maxBatchListLimit     = 1000
+)
+
+// setupBatchRoutes registers the Anthropic message batches API on the /v1 group
+func (s *Server) setupBatchRoutes(v1 *gin.RouterGroup) {
+	batches := v1.Group("/messages/batches")
+	{
+		batches.POST("", s.IdempotencyMiddleware(), s.UsageMiddleware(), s.CreateBatchHandler)
+		batches.GET("", s.ListBatchesHandler)
+		batches.GET("/:id", s.GetBatchHandler)
+		batches.GET("/:id/results", s.BatchResultsHandler)
+		batches.POST("/:id/cancel", s.CancelBatchHandler)
+		batches.DELETE("/:id", s.DeleteBatchHandler)
+	}
+}
+
+// CreateBatchHandler handles POST /v1/messages/batches
+func (s *Server) CreateBatchHandler(c *gin.Context) {
+	var body struct {
+		Requests []batch.Request `json:"requests"`
+	}
+	if err := c.ShouldBindJSON(&body); err != nil {
+		anthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	// Reject bad params now rather than as errored results later
+	cfg := s.currentConfig()
+	for i, req := range body.Requests {
+		var params converter.AnthropicRequest
+		err := decodeRequest(cfg, req.Params, &params, converter.AnthropicRequestFields)
+		if err == nil && params.Stream {
+			err = errors.New("stream is not supported in batches")
+		}
+		if err != nil {
+			anthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("requests.%d.params: %v", i, err))
+			return
+		}
+	}
+
+	created, err := s.Batches.Create(c.GetString(apiKeyContextKey), body.Requests)
+	var validationErr *batch.ValidationError
+	if errors.As(err, &validationErr) {
+		anthropicError(c, http.StatusBadRequest, "invalid_request_error", validationErr.Message)
+		return
+	}
+	if err != nil {
+		anthropicError(c, http.StatusInternalServerError, "api_error", err.Error())
+		return
+	}
+	c.JSON(http.StatusOK, created)
+}
+
+// ListBatchesHandler handles GET /v1/messages/batches, most recent first
+func (s *Server) ListBatchesHandler(c *gin.Context) {
+	limit := defaultBatchListLimit
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed < 1 || parsed > maxBatchListLimit {
+			anthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("limit must be between 1 and %d", maxBatchListLimit))
+			return
+		}
+		limit = parsed
+	}
+
+	batches, hasMore := s.Batches.List(c.GetString(apiKeyContextKey), limit, c.Query("before_id"), c.Query("after_id"))
+	response := gin.H{
+		"data":     batches,
+		"has_more": hasMore,
+		"first_id": nil,
+		"last_id":  nil,
+	}
+	if len(batches) > 0 {
+		response["first_id"] = batches[0].ID
+		response["last_id"] = batches[len(batches)-1].ID
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetBatchHandler handles GET /v1/messages/batches/:id
+func (s *Server) GetBatchHandler(c *gin.Context) {
+	found, err := s.Batches.Get(c.GetString(apiKeyContextKey), c.Param("id"))
+	if err != nil {
+		batchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, found)
+}
+
+// BatchResultsHandler handles GET /v1/messages/batches/:id/results, one JSON
+// result per line in request order
+func (s *Server) BatchResultsHandler(c *gin.Context) {
+	lines, err := s.Batches.Results(c.GetString(apiKeyContextKey), c.Param("id"))
+	if err != nil {
+		batchError(c, err)
+		return
+	}
+
+	c.Header("Content-Type", "application/x-jsonl")
+	c.Status(http.StatusOK)
+	enc := json.NewEncoder(c.Writer)
+	for _, line := range lines {
+		if err := enc.Encode(line); err != nil {
+			log.Warnf("Failed to write batch results: %v", err)
+			return
+		}
+	}
+}
+
+// CancelBatchHandler handles POST /v1/messages/batches/:id/cancel
+func (s *Server) CancelBatchHandler(c *gin.Context) {
+	canceled, err := s.Batches.Cancel(c.GetString(apiKeyContextKey), c.Param("id"))
+	if err != nil {
+		batchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, canceled)
+}
+
+// DeleteBatchHandler handles DELETE /v1/messages/batches/:id
+func (s *Server) DeleteBatchHandler(c *gin.Context) {
+	id := c.Param("id")
+	if err := s.Batches.Delete(c.GetString(apiKeyContextKey), id); err != nil {
+		batchError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": id, "type": "message_batch_deleted"})
+}
+
+// batchError reports a batch.Manager lookup or state error
+func batchError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, batch.ErrNotFound):
+		anthropicError(c, http.StatusNotFound, "not_found_error", err.Error())
+	case errors.Is(err, batch.ErrNotEnded):
+		anthropicError(c, http.StatusBadRequest, "invalid_request_error", err.Error())
+	default:
+		anthropicError(c, http.StatusInternalServerError, "api_error", err.Error())
+	}
+}
+
+// processBatchRequest runs one batch request through the Messages pipeline,
+// counting towards the API key's RPM, holding one of its concurrency slots and
+// recording its usage
+func (s *Server) processBatchRequest(ctx context.Context, apiKey string, params json.RawMessage) batch.Result {
+	var req converter.AnthropicRequest
+	if err := decodeRequest(s.currentConfig(), params, &req, converter.AnthropicRequestFields); err != nil {
+		return batch.Errored("invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+	}
+	if err := s.Usage.CheckQuota(apiKey); err != nil {
+		return batch.Errored("rate_limit_error", err.Error())
+	}
+	ctx = withoutHTTPRequest(ctx, apiKey)
+
+	if err := s.waitBatchTurn(ctx, apiKey); err != nil {
+		return batch.Errored("api_error", err.Error())
+	}
+	release, err := s.acquireBatchSlot(ctx, apiKey)
+	if err != nil {
+		return batch.Errored("api_error", err.Error())
+	}
+	defer release()
+
+	request := usage.NewRequest()
+	ctx = usage.WithRequest(ctx, request)
+	defer func() {
+		if model, totals := request.Result(); model != "" {
+			s.Usage.Record(apiKey, model, totals)
+		}
+	}()
+
+	prepared, status, errBody := s.prepareMessages(ctx, &req)
+	if prepared == nil {
+		return batchErrorResult(status, errBody)
+	}
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+	response, status, errBody := s.createMessage(ctx, prepared.cfg, apiURL, prepared.payload, req.Model, prepared.conversationID, prepared.promptTokens, prepared.limits, prepared.prefill)
+	if response == nil {
+		return batchErrorResult(status, errBody)
+	}
+	return batch.Succeeded(response)
+}
+
+// waitBatchTurn waits until the API key's RATE_LIMIT_RPM allows another
+// request, so batches cannot exceed the rate of interactive requests
+func (s *Server) waitBatchTurn(ctx context.Context, apiKey string) error {
+	for {
+		ok, retryAfter := s.RateLimiter.Allow(apiKey)
+		if ok {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryAfter):
+		}
+	}
+}
+
+// acquireBatchSlot waits for a free concurrency slot of the API key, so batches
+// share RATE_LIMIT_CONCURRENT with the key's interactive requests
+func (s *Server) acquireBatchSlot(ctx context.Context, apiKey string) (func(), error) {
+	for {
+		if release, ok := s.RateLimiter.Acquire(apiKey); ok {
+			return release, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(batchSlotPoll):
+		}
+	}
+}
+
+// batchErrorResult converts a pipeline error response to an errored batch result
+func batchErrorResult(status int, body gin.H) batch.Result {
+	message := http.StatusText(status)
+	errType := anthropicErrorType(status)
+	if inner, ok := body["error"].(gin.H); ok {
+		if text, ok := inner["message"].(string); ok {
+			message = text
+		}
+		if text, ok := inner["type"].(string); ok {
+			errType = text
+		}
+	}
+	return batch.Errored(errType, message)
+}
@@ -0,0 +1,177 @@
+// Package api provides tests for the message batches endpoints.
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+)
+
+// batchRequest sends an authenticated request to the batches API
+func batchRequest(router *gin.Engine, method, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, path, bytes.NewBufferString(body))
+	req.Header.Set("x-api-key", "test-key")
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// waitBatchEnded polls the batch until processing has ended
+func waitBatchEnded(t *testing.T, router *gin.Engine, id string) map[string]interface{} {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		var b map[string]interface{}
+		json.Unmarshal(batchRequest(router, "GET", "/v1/messages/batches/"+id, "").Body.Bytes(), &b)
+		if b["processing_status"] == "ended" {
+			return b
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("batch %s did not end", id)
+	return nil
+}
+
+// =============================================================================
+// TestMessageBatches
+// =============================================================================
+
+func TestMessageBatches(t *testing.T) {
+	t.Run("processes requests and serves results", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(
+			clienttest.Stream(`{"content":"Hello"}`),
+			clienttest.Response{StatusCode: http.StatusBadRequest, Body: "Improperly formed request"},
+		)
+
+		w := batchRequest(router, "POST", "/v1/messages/batches", `{"requests":[
+			{"custom_id":"first","params":{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}},
+			{"custom_id":"second","params":{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Bye"}]}}]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var created map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &created)
+		assert.Equal(t, "message_batch", created["type"])
+		id := created["id"].(string)
+
+		ended := waitBatchEnded(t, router, id)
+		assert.Equal(t, map[string]interface{}{
+			"processing": float64(0), "succeeded": float64(1), "errored": float64(1), "canceled": float64(0), "expired": float64(0),
+		}, ended["request_counts"])
+		assert.Equal(t, "/v1/messages/batches/"+id+"/results", ended["results_url"])
+
+		w = batchRequest(router, "GET", "/v1/messages/batches/"+id+"/results", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "application/x-jsonl", w.Header().Get("Content-Type"))
+
+		var lines []map[string]interface{}
+		scanner := bufio.NewScanner(w.Body)
+		for scanner.Scan() {
+			var line map[string]interface{}
+			json.Unmarshal(scanner.Bytes(), &line)
+			lines = append(lines, line)
+		}
+		assert.Len(t, lines, 2)
+
+		assert.Equal(t, "first", lines[0]["custom_id"])
+		first := lines[0]["result"].(map[string]interface{})
+		assert.Equal(t, "succeeded", first["type"])
+		message := first["message"].(map[string]interface{})
+		assert.Equal(t, "message", message["type"])
+		assert.Equal(t, "Hello", message["content"].([]interface{})[0].(map[string]interface{})["text"])
+
+		second := lines[1]["result"].(map[string]interface{})
+		assert.Equal(t, "errored", second["type"])
+		errBody := second["error"].(map[string]interface{})["error"].(map[string]interface{})
+		assert.Equal(t, "invalid_request_error", errBody["type"])
+		assert.Equal(t, "Improperly formed request", errBody["message"])
+
+		// Batch usage is recorded for the API key
+		assert.Equal(t, 2, server.Usage.Total("test-key").Requests)
+	})
+
+	t.Run("lists, cancels and deletes", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+
+		w := batchRequest(router, "POST", "/v1/messages/batches", `{"requests":[
+			{"custom_id":"only","params":{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}}]}`)
+		var created map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &created)
+		id := created["id"].(string)
+		waitBatchEnded(t, router, id)
+
+		var list map[string]interface{}
+		json.Unmarshal(batchRequest(router, "GET", "/v1/messages/batches?limit=10", "").Body.Bytes(), &list)
+		assert.Len(t, list["data"], 1)
+		assert.Equal(t, id, list["first_id"])
+		assert.Equal(t, false, list["has_more"])
+
+		// Cancelling an ended batch leaves it unchanged
+		w = batchRequest(router, "POST", "/v1/messages/batches/"+id+"/cancel", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"processing_status":"ended"`)
+
+		w = batchRequest(router, "DELETE", "/v1/messages/batches/"+id, "")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"id":"`+id+`","type":"message_batch_deleted"}`, w.Body.String())
+
+		w = batchRequest(router, "GET", "/v1/messages/batches/"+id, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.JSONEq(t, `{"type":"error","error":{"type":"not_found_error","message":"batch not found"}}`, w.Body.String())
+	})
+
+	t.Run("rejects invalid batches", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		for _, body := range []string{
+			`{"requests":[]}`,
+			`{"requests":[{"custom_id":"a","params":{"model":"claude-sonnet-4","max_tokens":100,"messages":[]}}]}`,
+			`{"requests":[{"custom_id":"a","params":{"model":"claude-sonnet-4","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"Hi"}]}}]}`,
+			`{"requests":[{"custom_id":"bad id","params":{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}}]}`,
+		} {
+			w := batchRequest(router, "POST", "/v1/messages/batches", body)
+			assert.Equal(t, http.StatusBadRequest, w.Code, body)
+			assert.Contains(t, w.Body.String(), `"type":"invalid_request_error"`, body)
+		}
+
+		w := batchRequest(router, "GET", "/v1/messages/batches?limit=0", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("waits for the request rate of the API key", func(t *testing.T) {
+		server, _ := newTestServer("test-key")
+		server.Cfg.RateLimitRPM = 1
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+		server.HttpClient = fake
+		server.RateLimiter.Allow("test-key")
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		result := server.processBatchRequest(ctx, "test-key", json.RawMessage(`{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`))
+
+		assert.Equal(t, "errored", result.Type)
+		assert.Equal(t, context.DeadlineExceeded.Error(), result.Error.Error.Message)
+		assert.Empty(t, fake.Requests())
+	})
+
+	t.Run("requires API key", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/messages/batches", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "authentication_error")
+	})
+}
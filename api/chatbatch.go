@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxChatBatchItems is the most requests one POST /v1/chat/completions/batch takes
+const maxChatBatchItems = 100
+
+// chatBatchResult is the outcome of one request of a chat completion batch:
+// the response, or the status and OpenAI error body it would have failed with
+type chatBatchResult struct {
+	Index    int                       `json:"index"`
+	Status   int                       `json:"status"`
+	Response *converter.OpenAIResponse `json:"response,omitempty"`
+	Error    interface{}               `json:"error,omitempty"`
+}
+
+// ChatCompletionBatchHandler handles POST /v1/chat/completions/batch. The body
+// is a JSON array of non-streaming chat completion requests; they run
+// concurrently, and the response lists one result per request in request
+// order. The first request runs on the batch's RATE_LIMIT_CONCURRENT slot and
+// the rest alongside it as far as the API key has free slots; every request
+// after the first also counts towards RATE_LIMIT_RPM and quotas, failing on its
+// own with a 429 result when over them.
+func (s *Server) ChatCompletionBatchHandler(c *gin.Context) {
+	var items []json.RawMessage
+	body, err := c.GetRawData()
+	if err == nil {
+		err = json.Unmarshal(body, &items)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, openAIValidationBody(fmt.Errorf("body must be an array of chat completion requests: %v", err)))
+		return
+	}
+	if len(items) == 0 || len(items) > maxChatBatchItems {
+		c.JSON(http.StatusBadRequest, openAIValidationBody(fmt.Errorf("a batch takes 1 to %d requests, got %d", maxChatBatchItems, len(items))))
+		return
+	}
+
+	// Requests run side by side, so none of them may set headers on the batch response
+	apiKey := c.GetString(apiKeyContextKey)
+	ctx := withoutHTTPRequest(c.Request.Context(), apiKey)
+
+	indexes := make(chan int, len(items))
+	for i := range items {
+		indexes <- i
+	}
+	close(indexes)
+
+	results := make([]chatBatchResult, len(items))
+	var wg sync.WaitGroup
+	worker := func(release func()) {
+		defer wg.Done()
+		defer release()
+		for i := range indexes {
+			results[i] = s.processChatBatchItem(ctx, apiKey, items[i], i > 0)
+			results[i].Index = i
+		}
+	}
+
+	wg.Add(1)
+	go worker(func() {})
+	for i := 1; i < len(items); i++ {
+		release, ok := s.RateLimiter.Acquire(apiKey)
+		if !ok {
+			break
+		}
+		wg.Add(1)
+		go worker(release)
+	}
+	wg.Wait()
+
+	c.JSON(http.StatusOK, gin.H{"object": "list", "data": results})
+}
+
+// processChatBatchItem runs one request of a chat completion batch through the
+// chat completions pipeline, recording its usage. checkRate applies the API
+// key's request rate, which the batch request itself already passed.
+func (s *Server) processChatBatchItem(ctx context.Context, apiKey string, params json.RawMessage, checkRate bool) chatBatchResult {
+	cfg := s.currentConfig()
+	var req converter.OpenAIRequest
+	if err := decodeRequest(cfg, params, &req, converter.OpenAIRequestFields); err != nil {
+		return chatBatchError(http.StatusBadRequest, openAIValidationBody(err))
+	}
+	if req.Stream {
+		return chatBatchError(http.StatusBadRequest, openAIValidationBody(&converter.FieldError{Field: "stream", Message: "streaming is not supported in batches"}))
+	}
+	if len(req.BuiltinTools()) > 0 {
+		return chatBatchError(http.StatusBadRequest, openAIValidationBody(&converter.FieldError{Field: "tools", Message: "builtin tools are not supported in batches"}))
+	}
+	if unsupported := req.UnsupportedParams(); len(unsupported) > 0 && cfg.UnsupportedParams == "reject" {
+		return chatBatchError(http.StatusBadRequest, openAIValidationBody(unsupportedParamsError(unsupported)))
+	}
+
+	if checkRate {
+		if ok, retryAfter := s.RateLimiter.Allow(apiKey); !ok {
+			return chatBatchError(http.StatusTooManyRequests, gin.H{"error": gin.H{
+				"message": fmt.Sprintf("Rate limit exceeded, retry in %d seconds", int(math.Ceil(retryAfter.Seconds()))),
+				"type":    "rate_limit_error",
+			}})
+		}
+	}
+	if err := s.Usage.CheckQuota(apiKey); err != nil {
+		return chatBatchError(http.StatusTooManyRequests, gin.H{"error": gin.H{"message": err.Error(), "type": "insufficient_quota"}})
+	}
+
+	request := usage.NewRequest()
+	ctx = usage.WithRequest(ctx, request)
+	defer func() {
+		if model, totals := request.Result(); model != "" {
+			s.Usage.Record(apiKey, model, totals)
+		}
+	}()
+
+	prepared, status, errBody := s.prepareChatCompletion(ctx, &req)
+	if prepared == nil {
+		return chatBatchError(status, errBody)
+	}
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+	response, status, errBody := s.createChatCompletion(ctx, apiKey, prepared.cfg, apiURL, prepared.payload, req.Model, prepared.conversationID, prepared.promptTokens, prepared.limits, req.ResponseFormat, req.Choices(), req.Logprobs)
+	if response == nil {
+		return chatBatchError(status, errBody)
+	}
+	return chatBatchResult{Status: http.StatusOK, Response: response}
+}
+
+// chatBatchError is a failed batch result with the error of an OpenAI error body
+func chatBatchError(status int, body gin.H) chatBatchResult {
+	return chatBatchResult{Status: status, Error: body["error"]}
+}
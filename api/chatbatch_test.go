@@ -0,0 +1,99 @@
+// Package api provides tests for chat completion batches.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/usage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type chatBatchResponse struct {
+	Object string            `json:"object"`
+	Data   []chatBatchResult `json:"data"`
+}
+
+func decodeChatBatch(t *testing.T, body []byte) chatBatchResponse {
+	var resp chatBatchResponse
+	assert.NoError(t, json.Unmarshal(body, &resp))
+	return resp
+}
+
+// =============================================================================
+// TestChatCompletionBatch
+// =============================================================================
+
+func TestChatCompletionBatch(t *testing.T) {
+	item := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`
+
+	t.Run("returns a result per request in order", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`), clienttest.Stream(`{"content":"Hello"}`))
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/chat/completions/batch", `[`+item+`,{"model":"claude-sonnet-4","messages":"Hi"},`+item+`]`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp := decodeChatBatch(t, w.Body.Bytes())
+		assert.Equal(t, "list", resp.Object)
+		assert.Len(t, resp.Data, 3)
+		for i, result := range resp.Data {
+			assert.Equal(t, i, result.Index)
+		}
+		assert.Equal(t, http.StatusOK, resp.Data[0].Status)
+		assert.Equal(t, "Hello", resp.Data[0].Response.Choices[0].Message.Content)
+		assert.Equal(t, http.StatusBadRequest, resp.Data[1].Status)
+		assert.Nil(t, resp.Data[1].Response)
+		assert.Contains(t, w.Body.String(), "invalid_request_error")
+		assert.Equal(t, http.StatusOK, resp.Data[2].Status)
+		assert.Len(t, fake.Requests(), 2)
+		assert.Equal(t, 2, server.Usage.Total("test-key").Requests)
+	})
+
+	t.Run("rejects streaming requests", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := postJSON(router, "/v1/chat/completions/batch", `[{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"Hi"}]}]`)
+
+		resp := decodeChatBatch(t, w.Body.Bytes())
+		assert.Equal(t, http.StatusBadRequest, resp.Data[0].Status)
+		assert.Contains(t, w.Body.String(), `"param":"stream"`)
+	})
+
+	t.Run("applies the request rate to every request", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.RateLimitRPM = 1
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+
+		w := postJSON(router, "/v1/chat/completions/batch", `[`+item+`,`+item+`]`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		resp := decodeChatBatch(t, w.Body.Bytes())
+		assert.Equal(t, http.StatusOK, resp.Data[0].Status)
+		assert.Equal(t, http.StatusTooManyRequests, resp.Data[1].Status)
+		assert.Contains(t, w.Body.String(), "rate_limit_error")
+	})
+
+	t.Run("applies quotas to every request", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.QuotaRequests = 1
+		server.Usage.Record("test-key", "claude-sonnet-4", usage.Totals{Requests: 1})
+
+		w := postJSON(router, "/v1/chat/completions/batch", `[`+item+`]`)
+
+		resp := decodeChatBatch(t, w.Body.Bytes())
+		assert.Equal(t, http.StatusTooManyRequests, resp.Data[0].Status)
+		assert.Contains(t, w.Body.String(), "insufficient_quota")
+	})
+
+	t.Run("rejects bodies that are not a batch", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		assert.Equal(t, http.StatusBadRequest, postJSON(router, "/v1/chat/completions/batch", item).Code)
+		assert.Equal(t, http.StatusBadRequest, postJSON(router, "/v1/chat/completions/batch", `[]`).Code)
+	})
+}
@@ -0,0 +1,85 @@
+package api
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/usage"
+	"kiro-go-proxy/utils"
+
+	"github.com/google/uuid"
+)
+
+// ConversationIDHeader carries a client's conversation ID. In the header and auto
+// CONVERSATION_ID_MODE it is read from requests and echoed on responses.
+const ConversationIDHeader = "x-conversation-id"
+
+// CONVERSATION_ID_MODE values that reuse conversation IDs; any other mode
+// starts a new conversation per request
+const (
+	conversationModeHeader = "header"
+	conversationModeAuto   = "auto"
+)
+
+// maxConversationIDLength bounds client conversation IDs; longer values are ignored
+const maxConversationIDLength = 256
+
+// conversationNamespace scopes the name-based UUIDs sent to Kiro as conversation IDs
+var conversationNamespace = uuid.MustParse("5b1e7c2a-8f43-4d6e-9a0b-3c7d2e1f4a96")
+
+// resolveConversationID returns the conversation ID sent to Kiro for a request.
+//
+// In the default random mode every request starts a new conversation. In header
+// mode a client's x-conversation-id is reused across turns; auto mode also derives
+// one from the system prompt and first user message when the header is absent.
+// Client values are hashed with the API key into a UUID, so clients cannot reach
+// conversations started under another key. Requests without an HTTP request in
+// ctx, such as batch requests, always get a random ID.
+func resolveConversationID(ctx context.Context, cfg *config.Config, messages []converter.UnifiedMessage, systemPrompt string) string {
+	scope := scopeOf(ctx)
+	if scope.setHeader == nil || (cfg.ConversationIDMode != conversationModeHeader && cfg.ConversationIDMode != conversationModeAuto) {
+		return utils.GenerateConversationID()
+	}
+
+	clientID := clientConversationID(scope)
+	if clientID == "" && cfg.ConversationIDMode == conversationModeAuto {
+		clientID = derivedConversationID(messages, systemPrompt)
+	}
+	if clientID == "" {
+		return utils.GenerateConversationID()
+	}
+
+	scope.setHeader(ConversationIDHeader, clientID)
+	return scopedConversationID(scope.apiKey, clientID)
+}
+
+// clientConversationID returns the request's x-conversation-id, or "" if it is
+// absent or too long
+func clientConversationID(scope *requestScope) string {
+	if len(scope.conversationID) > maxConversationIDLength {
+		return ""
+	}
+	return scope.conversationID
+}
+
+// scopedConversationID hashes a client conversation ID with the API key
+func scopedConversationID(apiKey, clientID string) string {
+	name := usage.KeyID(apiKey) + "\x00" + clientID
+	return uuid.NewSHA1(conversationNamespace, []byte(name)).String()
+}
+
+// derivedConversationID identifies a conversation by its system prompt and first
+// user message, which stay the same as a chat grows
+func derivedConversationID(messages []converter.UnifiedMessage, systemPrompt string) string {
+	for _, msg := range messages {
+		if msg.Role != "user" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(systemPrompt + "\x00" + utils.ExtractTextContent(msg.Content)))
+		return "auto-" + hex.EncodeToString(sum[:16])
+	}
+	return ""
+}
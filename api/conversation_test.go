@@ -0,0 +1,92 @@
+// Package api provides tests for conversation ID continuity.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/converter"
+)
+
+// =============================================================================
+// TestConversationID
+// =============================================================================
+
+func TestConversationID(t *testing.T) {
+	// chat sends a chat completion and returns the response and the Kiro conversation ID
+	chat := func(t *testing.T, mode, apiKey, header, body string) (*httptest.ResponseRecorder, string) {
+		server, router := newTestServer(apiKey)
+		server.Cfg.ConversationIDMode = mode
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+		server.HttpClient = fake
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+		req.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			req.Header.Set(ConversationIDHeader, header)
+		}
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		payload := fake.Requests()[0].Payload.(*converter.KiroPayload)
+		return w, payload.ConversationState.ConversationID
+	}
+
+	first := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Plan a trip"}]}`
+	second := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Plan a trip"},{"role":"assistant","content":"Where to?"},{"role":"user","content":"Japan"}]}`
+
+	t.Run("random mode starts a new conversation per request", func(t *testing.T) {
+		w, id1 := chat(t, "random", "key-a", "chat-1", first)
+		_, id2 := chat(t, "random", "key-a", "chat-1", first)
+
+		assert.NotEqual(t, id1, id2)
+		assert.Empty(t, w.Header().Get(ConversationIDHeader))
+	})
+
+	t.Run("header mode reuses the client's ID", func(t *testing.T) {
+		w, id1 := chat(t, "header", "key-a", "chat-1", first)
+		_, id2 := chat(t, "header", "key-a", "chat-1", second)
+		_, other := chat(t, "header", "key-a", "chat-2", second)
+		_, none := chat(t, "header", "key-a", "", second)
+
+		assert.Equal(t, id1, id2)
+		assert.NotEqual(t, id1, other)
+		assert.NotEqual(t, id1, none)
+		assert.Equal(t, "chat-1", w.Header().Get(ConversationIDHeader))
+	})
+
+	t.Run("IDs are scoped to the API key", func(t *testing.T) {
+		_, idA := chat(t, "header", "key-a", "chat-1", first)
+		_, idB := chat(t, "header", "key-b", "chat-1", first)
+
+		assert.NotEqual(t, idA, idB)
+	})
+
+	t.Run("auto mode derives the ID from the first user message", func(t *testing.T) {
+		w, id1 := chat(t, "auto", "key-a", "", first)
+		_, id2 := chat(t, "auto", "key-a", "", second)
+		_, echoed := chat(t, "auto", "key-a", w.Header().Get(ConversationIDHeader), second)
+
+		assert.Equal(t, id1, id2)
+		assert.Equal(t, id1, echoed)
+		assert.True(t, strings.HasPrefix(w.Header().Get(ConversationIDHeader), "auto-"))
+	})
+
+	t.Run("response IDs stay unique", func(t *testing.T) {
+		w1, _ := chat(t, "header", "key-a", "chat-1", first)
+		w2, _ := chat(t, "header", "key-a", "chat-1", first)
+
+		var r1, r2 map[string]interface{}
+		json.Unmarshal(w1.Body.Bytes(), &r1)
+		json.Unmarshal(w2.Body.Bytes(), &r2)
+		assert.NotEmpty(t, r1["id"])
+		assert.NotEqual(t, r1["id"], r2["id"])
+	})
+}
@@ -0,0 +1,124 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/convstore"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// ConversationStoreMiddleware saves the turn of a request to a stored
+// conversation once it has been answered. Failed requests leave the stored
+// history as it was, so a client can retry its message.
+func (s *Server) ConversationStoreMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Conversations == nil {
+			c.Next()
+			return
+		}
+
+		turn := convstore.NewTurn()
+		defer turn.Release()
+		c.Request = c.Request.WithContext(convstore.WithTurn(c.Request.Context(), turn))
+
+		c.Next()
+
+		if c.Writer.Status() != http.StatusOK {
+			return
+		}
+		if id, messages := turn.Result(); id != "" {
+			if err := s.Conversations.Save(id, messages); err != nil {
+				log.Warnf("Failed to save conversation %s: %v", id, err)
+			}
+		}
+	}
+}
+
+// withStoredHistory puts the stored history of the request's x-conversation-id
+// ahead of messages, dropping its oldest turns when the request would exceed
+// its limits or modelID's input tokens. A request that has assistant messages
+// carries its own history and is stored as sent. Requests without the header,
+// or without an HTTP request such as batch requests, are left alone.
+//
+// The turn holds the conversation from here until the middleware saves it, so
+// concurrent requests to one conversation take turns.
+func (s *Server) withStoredHistory(ctx context.Context, cfg *config.Config, modelID string, messages []converter.UnifiedMessage, systemPrompt string, tools []converter.UnifiedTool) []converter.UnifiedMessage {
+	turn := convstore.FromContext(ctx)
+	scope := scopeOf(ctx)
+	if turn == nil || scope.setHeader == nil {
+		return messages
+	}
+	clientID := clientConversationID(scope)
+	if clientID == "" {
+		return messages
+	}
+
+	history, loaded := turn.History()
+	if !loaded {
+		id := scopedConversationID(scope.apiKey, clientID)
+		unlock, err := s.Conversations.Lock(ctx, id)
+		if err != nil {
+			return messages
+		}
+		if !hasAssistantMessage(messages) {
+			history = converter.FitHistory(s.Conversations.Load(id), messages, systemPrompt, tools, s.ModelCache.GetMaxInputTokens(modelID), cfg)
+		}
+		turn.SetHistory(id, history, unlock)
+	}
+	messages = append(append([]converter.UnifiedMessage(nil), history...), messages...)
+	turn.SetMessages(messages)
+	return messages
+}
+
+func hasAssistantMessage(messages []converter.UnifiedMessage) bool {
+	for _, msg := range messages {
+		if msg.Role == "assistant" {
+			return true
+		}
+	}
+	return false
+}
+
+// DeleteConversationHandler handles DELETE /v1/conversations/:id, removing the
+// stored history of one of the API key's conversations
+func (s *Server) DeleteConversationHandler(c *gin.Context) {
+	if s.Conversations == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": "Conversation store is disabled. Set CONVERSATION_STORE_DIR to enable it",
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	clientID := c.Param("id")
+	deleted, err := s.Conversations.Delete(scopedConversationID(c.GetString(apiKeyContextKey), clientID))
+	if err != nil {
+		log.Errorf("Failed to delete conversation: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Failed to delete conversation",
+				"type":    "internal_error",
+			},
+		})
+		return
+	}
+	if !deleted {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Conversation '%s' not found", clientID),
+				"type":    "invalid_request_error",
+				"code":    "conversation_not_found",
+			},
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"id": clientID, "object": "conversation.deleted", "deleted": true})
+}
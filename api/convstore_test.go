@@ -0,0 +1,188 @@
+// Package api provides tests for server-side conversation histories.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/convstore"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newConversationStoreTestServer returns a test server with the conversation
+// store enabled and the given Kiro responses queued
+func newConversationStoreTestServer(t *testing.T, responses ...clienttest.Response) (*Server, *clienttest.Fake, *gin.Engine) {
+	server, router := newTestServer("test-key")
+	server.Conversations = convstore.NewStore(&config.Config{ConversationStoreDir: t.TempDir(), ConversationStoreTTL: 3600})
+	fake := clienttest.NewFake(responses...)
+	server.HttpClient = fake
+	return server, fake, router
+}
+
+// postConversation sends an authenticated JSON request in conversation id
+func postConversation(router http.Handler, path, id, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set(ConversationIDHeader, id)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// sentHistory returns the history of the i-th Kiro request as JSON
+func sentHistory(fake *clienttest.Fake, i int) string {
+	return string(mustMarshal(fake.Requests()[i].Payload.(*converter.KiroPayload).ConversationState.History))
+}
+
+// =============================================================================
+// TestConversationStore
+// =============================================================================
+
+func TestConversationStore(t *testing.T) {
+	t.Run("merges the stored history into later requests", func(t *testing.T) {
+		_, fake, router := newConversationStoreTestServer(t,
+			clienttest.Stream(`{"content":"Hello, Ada!"}`),
+			clienttest.Stream(`{"content":"Your name is Ada."}`),
+		)
+
+		w := postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"I am Ada"}]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"What is my name?"}]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Empty(t, fake.Requests()[0].Payload.(*converter.KiroPayload).ConversationState.History)
+		history := sentHistory(fake, 1)
+		assert.Contains(t, history, "I am Ada")
+		assert.Contains(t, history, "Hello, Ada!")
+	})
+
+	t.Run("stores streamed replies and Anthropic messages", func(t *testing.T) {
+		_, fake, router := newConversationStoreTestServer(t,
+			clienttest.Stream(`{"content":"Hello, Ada!"}`),
+			clienttest.Stream(`{"content":"Ada."}`),
+		)
+
+		w := postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"I am Ada"}]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		w = postConversation(router, "/v1/messages", "chat-1", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"What is my name?"}]}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		assert.Contains(t, sentHistory(fake, 1), "Hello, Ada!")
+	})
+
+	t.Run("requests with their own history are not merged", func(t *testing.T) {
+		_, fake, router := newConversationStoreTestServer(t,
+			clienttest.Stream(`{"content":"Hello, Ada!"}`),
+			clienttest.Stream(`{"content":"Ada."}`),
+		)
+
+		postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"I am Ada"}]}`)
+		postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[
+			{"role":"user","content":"I am Grace"},{"role":"assistant","content":"Hello, Grace!"},{"role":"user","content":"What is my name?"}]}`)
+
+		history := sentHistory(fake, 1)
+		assert.Contains(t, history, "I am Grace")
+		assert.NotContains(t, history, "I am Ada")
+	})
+
+	t.Run("failed requests are not stored", func(t *testing.T) {
+		_, fake, router := newConversationStoreTestServer(t,
+			clienttest.Response{StatusCode: http.StatusBadRequest, Body: `{"message":"bad request"}`},
+			clienttest.Stream(`{"content":"Hello!"}`),
+		)
+
+		w := postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"First"}]}`)
+		assert.NotEqual(t, http.StatusOK, w.Code)
+		postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Second"}]}`)
+
+		assert.Empty(t, fake.Requests()[1].Payload.(*converter.KiroPayload).ConversationState.History)
+	})
+
+	t.Run("drops the oldest stored turns beyond the request limits", func(t *testing.T) {
+		server, fake, router := newConversationStoreTestServer(t,
+			clienttest.Stream(`{"content":"Hello, Ada!"}`),
+			clienttest.Stream(`{"content":"Hello, Grace!"}`),
+			clienttest.Stream(`{"content":"Grace."}`),
+		)
+		server.Cfg.MaxMessages = 3
+
+		for _, content := range []string{"I am Ada", "I am Grace", "What is my name?"} {
+			w := postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"`+content+`"}]}`)
+			assert.Equal(t, http.StatusOK, w.Code)
+		}
+
+		history := sentHistory(fake, 2)
+		assert.NotContains(t, history, "I am Ada")
+		assert.Contains(t, history, "I am Grace")
+		assert.Contains(t, history, "Hello, Grace!")
+	})
+
+	t.Run("conversations are scoped to the API key", func(t *testing.T) {
+		server, fake, router := newConversationStoreTestServer(t,
+			clienttest.Stream(`{"content":"Hello, Ada!"}`),
+			clienttest.Stream(`{"content":"I don't know."}`),
+		)
+
+		postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"I am Ada"}]}`)
+		server.Cfg.ProxyAPIKey = "other-key"
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{"model":"claude-sonnet-4","messages":[{"role":"user","content":"What is my name?"}]}`))
+		req.Header.Set("Authorization", "Bearer other-key")
+		req.Header.Set(ConversationIDHeader, "chat-1")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, fake.Requests()[1].Payload.(*converter.KiroPayload).ConversationState.History)
+	})
+}
+
+// =============================================================================
+// TestDeleteConversationHandler
+// =============================================================================
+
+func TestDeleteConversationHandler(t *testing.T) {
+	deleteConversation := func(router http.Handler, id string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("DELETE", "/v1/conversations/"+id, nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("deletes a stored conversation", func(t *testing.T) {
+		_, fake, router := newConversationStoreTestServer(t,
+			clienttest.Stream(`{"content":"Hello, Ada!"}`),
+			clienttest.Stream(`{"content":"I don't know."}`),
+		)
+		postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"I am Ada"}]}`)
+
+		w := deleteConversation(router, "chat-1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "chat-1", body["id"])
+		assert.Equal(t, true, body["deleted"])
+		assert.Equal(t, http.StatusNotFound, deleteConversation(router, "chat-1").Code)
+
+		postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"What is my name?"}]}`)
+		assert.Empty(t, fake.Requests()[1].Payload.(*converter.KiroPayload).ConversationState.History)
+	})
+
+	t.Run("reports a disabled store", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := deleteConversation(router, "chat-1")
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "CONVERSATION_STORE_DIR")
+	})
+}
@@ -0,0 +1,53 @@
+package api
+
+import (
+	"context"
+	"strconv"
+
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// creditsHeader reports the Kiro credits a streaming request consumed. Kiro
+// reports credits at the end of its stream, so the header is sent as a trailer.
+const creditsHeader = "x-kiro-credits"
+
+// requestCredits returns the Kiro credits consumed so far by the request in ctx,
+// over all its Kiro requests (retries, fallbacks, n > 1 and agentic steps)
+func requestCredits(ctx context.Context) float64 {
+	return usage.FromContext(ctx).Credits()
+}
+
+// announceStreamTrailers declares the credits and served model trailers. It must
+// be called before the response is written.
+func announceStreamTrailers(c *gin.Context) {
+	c.Header("Trailer", creditsHeader+", "+servedModelHeader)
+}
+
+// writeStreamTrailers sets the trailers once the stream of a response for model
+// has ended
+func writeStreamTrailers(c *gin.Context, model string) {
+	ctx := c.Request.Context()
+	c.Writer.Header().Set(creditsHeader, strconv.FormatFloat(requestCredits(ctx), 'f', -1, 64))
+	if served := servedModel(ctx, model); served != "" {
+		c.Writer.Header().Set(servedModelHeader, served)
+	}
+}
+
+// anthropicKiroMetadata returns the metadata of an Anthropic message for model
+// reporting its Kiro credits and the model that served it, or nil if there is
+// nothing to report
+func anthropicKiroMetadata(ctx context.Context, model string) map[string]interface{} {
+	metadata := map[string]interface{}{}
+	if credits := requestCredits(ctx); credits != 0 {
+		metadata["kiro_credits"] = credits
+	}
+	if served := servedModel(ctx, model); served != "" {
+		metadata["kiro_model"] = served
+	}
+	if len(metadata) == 0 {
+		return nil
+	}
+	return metadata
+}
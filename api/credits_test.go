@@ -0,0 +1,95 @@
+// Package api provides tests for reporting Kiro credits to clients.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestKiroCredits
+// =============================================================================
+
+func TestKiroCredits(t *testing.T) {
+	t.Run("OpenAI responses report x_kiro_credits", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`, `{"usage":3}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, float64(3), body["x_kiro_credits"])
+	})
+
+	t.Run("Anthropic messages report credits in metadata", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`, `{"usage":2}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, map[string]interface{}{"kiro_credits": float64(2)}, body["metadata"])
+	})
+
+	t.Run("streams send a credits trailer", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`, `{"usage":5}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "5", w.Result().Trailer.Get(creditsHeader))
+	})
+
+	t.Run("OpenAI streams report credits in the final chunk", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`, `{"usage":0.75}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"x_kiro_credits":0.75`)
+		assert.Equal(t, "0.75", w.Result().Trailer.Get(creditsHeader))
+	})
+
+	t.Run("Anthropic streams report credits in message_delta", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`, `{"usage":1.5}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"metadata":{"kiro_credits":1.5}`)
+	})
+
+	t.Run("credits count towards usage", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(
+			clienttest.Stream(`{"content":"Hello!"}`, `{"usage":0.5}`),
+			clienttest.Stream(`{"content":"Hello!"}`, `{"usage":0.5}`),
+		)
+
+		postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+		postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, 1.0, server.Usage.Total("test-key").Credits)
+	})
+
+	t.Run("omitted without usage events", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.NotContains(t, w.Body.String(), "metadata")
+	})
+}
@@ -0,0 +1,336 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxErrorBodyCapture bounds the error response bytes kept to extract the message
+const maxErrorBodyCapture = 4096
+
+// untrackedRoutes are left out of the dashboard activity so probes, scrapes and
+// the dashboard's own polling do not show up as traffic
+var untrackedRoutes = map[string]bool{
+	"/":                  true,
+	"/health":            true,
+	"/health/ready":      true,
+	"/livez":             true,
+	"/readyz":            true,
+	"/startupz":          true,
+	"/metrics":           true,
+	"/docs":              true,
+	"/docs/redoc":        true,
+	"/docs/openapi.json": true,
+	"/docs/assets/:file": true,
+	"/dashboard":         true,
+	"/dashboard/status":  true,
+}
+
+// setupDashboardRoutes registers the /dashboard page and its status API,
+// authenticated with ADMIN_API_KEY
+func (s *Server) setupDashboardRoutes(r *gin.Engine) {
+	dashboard := r.Group("/dashboard")
+	dashboard.Use(s.DashboardAuthMiddleware())
+	{
+		dashboard.GET("", s.DashboardHandler)
+		dashboard.GET("/status", s.DashboardStatusHandler)
+	}
+}
+
+// ActivityMiddleware records each request in the dashboard activity: throughput,
+// in-flight requests and streams, and recent errors with their message
+func (s *Server) ActivityMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry := accesslog.FromContext(c.Request.Context())
+		if entry == nil || untrackedRoutes[c.FullPath()] {
+			c.Next()
+			return
+		}
+
+		done := s.Activity.Begin(entry, c.Request.Method, c.Request.URL.Path)
+		writer := &errorCaptureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		done(c.Writer.Status(), errorMessage(writer.body))
+	}
+}
+
+// errorCaptureWriter keeps the start of 4xx and 5xx response bodies
+type errorCaptureWriter struct {
+	gin.ResponseWriter
+	body []byte
+}
+
+func (w *errorCaptureWriter) Write(data []byte) (int, error) {
+	w.capture(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *errorCaptureWriter) WriteString(data string) (int, error) {
+	w.capture([]byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *errorCaptureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *errorCaptureWriter) capture(data []byte) {
+	if w.Status() < 400 || len(w.body) >= maxErrorBodyCapture {
+		return
+	}
+	if room := maxErrorBodyCapture - len(w.body); len(data) > room {
+		data = data[:room]
+	}
+	w.body = append(w.body, data...)
+}
+
+// errorMessage extracts the message of an error body in any of the supported
+// API formats: {"error": {"message": ...}} or Ollama's {"error": "..."}
+func errorMessage(body []byte) string {
+	var parsed struct {
+		Error json.RawMessage `json:"error"`
+	}
+	if len(body) == 0 || json.Unmarshal(body, &parsed) != nil || parsed.Error == nil {
+		return ""
+	}
+	var message string
+	if json.Unmarshal(parsed.Error, &message) == nil {
+		return message
+	}
+	var detail struct {
+		Message string `json:"message"`
+	}
+	json.Unmarshal(parsed.Error, &detail)
+	return detail.Message
+}
+
+// DashboardAuthMiddleware validates ADMIN_API_KEY like the admin API, also
+// accepting it as the HTTP Basic auth password so browsers can sign in
+func (s *Server) DashboardAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		adminAPIKey := s.currentConfig().AdminAPIKey
+		if adminAPIKey == "" {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error": gin.H{
+					"message": "Dashboard is disabled. Set ADMIN_API_KEY to enable it",
+					"type":    "permission_error",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		if s.rejectLockedOut(c) {
+			return
+		}
+
+		apiKey := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if _, password, ok := c.Request.BasicAuth(); ok {
+			apiKey = password
+		}
+		if !keysEqual(apiKey, adminAPIKey) {
+			// Browsers ask for credentials on the first visit; only a wrong key counts as a failure
+			if apiKey != "" {
+				s.recordAuthFailure(c)
+			}
+			c.Header("WWW-Authenticate", `Basic realm="Kiro Gateway dashboard", charset="UTF-8"`)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"error": gin.H{
+					"message": "Invalid admin API key",
+					"type":    "invalid_request_error",
+				},
+			})
+			c.Abort()
+			return
+		}
+
+		s.AuthLockout.Success(c.ClientIP())
+		c.Next()
+	}
+}
+
+// keyUsage is the usage of one API key, identified by its fingerprint
+type keyUsage struct {
+	KeyID string `json:"key_id"`
+	usage.Totals
+}
+
+// DashboardStatusHandler handles GET /dashboard/status, the JSON behind the dashboard
+func (s *Server) DashboardStatusHandler(c *gin.Context) {
+	keys := s.Usage.Keys()
+	keyUsages := make([]keyUsage, 0, len(keys))
+	for id, totals := range keys {
+		keyUsages = append(keyUsages, keyUsage{KeyID: id, Totals: totals})
+	}
+	// Busiest keys first
+	sort.Slice(keyUsages, func(i, j int) bool {
+		if keyUsages[i].Tokens() != keyUsages[j].Tokens() {
+			return keyUsages[i].Tokens() > keyUsages[j].Tokens()
+		}
+		return keyUsages[i].KeyID < keyUsages[j].KeyID
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"object":          "dashboard.status",
+		"timestamp":       time.Now().UTC().Format(time.RFC3339),
+		"version":         config.AppVersion,
+		"activity":        s.Activity.Snapshot(),
+		"latency":         s.Latency.Snapshot(),
+		"accounts":        s.adminAccounts(),
+		"model_cache":     s.modelCacheStatus(),
+		"models":          stream.CreateOpenAIModelsResponse(s.ModelResolver.GetAvailableModelDetails()).Data,
+		"usage":           keyUsages,
+		"circuit_breaker": s.Breaker.Status(),
+	})
+}
+
+// DashboardHandler handles GET /dashboard, a page that polls /dashboard/status
+func (s *Server) DashboardHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(dashboardPage))
+}
+
+// dashboardPage is self-contained so the dashboard works without internet access
+const dashboardPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Kiro Gateway Dashboard</title>
+<style>
+  body { font-family: system-ui, sans-serif; margin: 0; background: #f5f6f8; color: #1d2330; }
+  header { background: #1d2330; color: #fff; padding: 12px 24px; display: flex; justify-content: space-between; align-items: baseline; }
+  header h1 { font-size: 18px; margin: 0; }
+  header span { font-size: 13px; opacity: 0.7; }
+  main { padding: 16px 24px; display: grid; gap: 16px; grid-template-columns: repeat(auto-fit, minmax(420px, 1fr)); }
+  section { background: #fff; border-radius: 6px; padding: 12px 16px; box-shadow: 0 1px 2px rgba(0,0,0,0.08); overflow-x: auto; }
+  h2 { font-size: 14px; text-transform: uppercase; letter-spacing: 0.04em; color: #5a6275; margin: 0 0 8px; }
+  .stats { display: flex; gap: 24px; flex-wrap: wrap; }
+  .stat b { display: block; font-size: 24px; }
+  .stat small { color: #5a6275; }
+  table { border-collapse: collapse; width: 100%; font-size: 13px; }
+  th, td { text-align: left; padding: 4px 8px 4px 0; border-bottom: 1px solid #eceef2; white-space: nowrap; }
+  th { color: #5a6275; font-weight: 600; }
+  .ok { color: #1a7f37; } .bad { color: #cf222e; } .warn { color: #9a6700; }
+  .empty { color: #8a91a1; font-size: 13px; }
+  svg { width: 100%; height: 60px; margin-top: 8px; }
+</style>
+</head>
+<body>
+<header><h1>Kiro Gateway</h1><span id="updated">Loading…</span></header>
+<main>
+  <section><h2>Throughput</h2><div class="stats" id="traffic"></div><svg id="spark" viewBox="0 0 60 20" preserveAspectRatio="none"></svg></section>
+  <section><h2>Accounts</h2><div id="accounts"></div></section>
+  <section><h2>In-flight requests</h2><div id="active"></div></section>
+  <section><h2>Latency (last 5 min)</h2><div id="latency"></div></section>
+  <section><h2>Recent errors</h2><div id="errors"></div></section>
+  <section><h2>Usage by API key</h2><div id="usage"></div></section>
+  <section><h2>Model cache</h2><div id="models"></div></section>
+</main>
+<script>
+let accounts = [];
+const $ = id => document.getElementById(id);
+const esc = v => String(v == null ? "" : v).replace(/[&<>"']/g, c => ({"&": "&amp;", "<": "&lt;", ">": "&gt;", '"': "&quot;", "'": "&#39;"}[c]));
+const num = n => Number(n || 0).toLocaleString();
+const ms = p => p ? [p.p50_ms, p.p95_ms, p.p99_ms].map(v => (v / 1000).toFixed(2) + "s").join(" / ") : "";
+
+function table(headers, rows, empty) {
+  if (!rows.length) return '<p class="empty">' + empty + '</p>';
+  return "<table><tr>" + headers.map(h => "<th>" + h + "</th>").join("") + "</tr>" +
+    rows.map(r => "<tr>" + r.map(c => "<td>" + c + "</td>").join("") + "</tr>").join("") + "</table>";
+}
+
+function countdown(expiresAt) {
+  if (!expiresAt) return '<span class="empty">unknown</span>';
+  let s = Math.round((new Date(expiresAt) - Date.now()) / 1000);
+  if (s <= 0) return '<span class="bad">expired</span>';
+  const cls = s < 300 ? "warn" : "ok";
+  const h = Math.floor(s / 3600), m = Math.floor(s % 3600 / 60);
+  s = s % 60;
+  return '<span class="' + cls + '">' + (h ? h + "h " : "") + (h || m ? m + "m " : "") + s + "s</span>";
+}
+
+function renderAccounts() {
+  $("accounts").innerHTML = table(["Account", "Status", "Token expires in", "Requests", "Failures", "Last error"],
+    accounts.map(a => [esc(a.name), a.healthy ? '<span class="ok">healthy</span>' : '<span class="bad">cooling down</span>',
+      countdown(a.token_expires_at), num(a.total_requests), num(a.total_failures), esc(a.last_error)]),
+    "No accounts");
+}
+
+function render(s) {
+  const a = s.activity;
+  const breaker = s.circuit_breaker.state;
+  $("traffic").innerHTML = [
+    [num(a.requests_last_minute), "requests / min"],
+    [num(a.active_requests), "in flight"],
+    [num(a.active_streams), "streams"],
+    [num(a.requests_total), "total since start"],
+    ['<span class="' + (breaker === "closed" ? "ok" : "bad") + '">' + esc(breaker) + "</span>", "circuit breaker"],
+  ].map(([v, l]) => '<div class="stat"><b>' + v + "</b><small>" + l + "</small></div>").join("");
+
+  const max = Math.max(1, ...a.per_second);
+  $("spark").innerHTML = '<polyline fill="none" stroke="#2f6fed" stroke-width="0.5" points="' +
+    a.per_second.map((n, i) => i + "," + (20 - n / max * 19)).join(" ") + '"/>';
+
+  accounts = s.accounts;
+  renderAccounts();
+
+  $("active").innerHTML = table(["Request", "Route", "Model", "Type", "Running"],
+    a.active.map(r => [esc(r.id.slice(0, 8)), esc(r.method + " " + r.path), esc(r.model), r.streaming ? "stream" : "request",
+      (r.duration_ms / 1000).toFixed(1) + "s"]),
+    "Idle");
+
+  $("latency").innerHTML = table(["Model", "Requests", "First token p50 / p95 / p99", "Total p50 / p95 / p99"],
+    s.latency.filter(l => l.requests).map(l => [esc(l.model), num(l.requests), ms(l.first_token), ms(l.total)]),
+    "No requests in the last 5 minutes");
+
+  $("errors").innerHTML = table(["Time", "Route", "Status", "Message"],
+    a.recent_errors.slice(0, 20).map(e => [new Date(e.time).toLocaleTimeString(), esc(e.method + " " + e.path),
+      '<span class="bad">' + e.status + "</span>", esc(e.message)]),
+    "No errors");
+
+  $("usage").innerHTML = table(["Key", "Requests", "Prompt tokens", "Completion tokens", "Credits"],
+    s.usage.map(u => [esc(u.key_id), num(u.requests), num(u.prompt_tokens), num(u.completion_tokens), num(u.credits)]),
+    "No usage recorded");
+
+  const cache = s.model_cache;
+  $("models").innerHTML = '<p class="empty">' + cache.count + " cached" +
+    (cache.age_seconds != null ? ", updated " + Math.round(cache.age_seconds / 60) + " min ago" : ", never loaded from Kiro") +
+    (cache.stale ? ' <span class="warn">(stale)</span>' : "") + "</p>" +
+    table(["Model", "Context window", "Max output", "Vision", "Tools"],
+      s.models.map(m => [esc(m.id), num(m.context_window), num(m.max_output_tokens), m.supports_vision ? "yes" : "", m.supports_tools ? "yes" : ""]),
+      "No models");
+
+  $("updated").textContent = "Updated " + new Date(s.timestamp).toLocaleTimeString() + " · v" + s.version;
+}
+
+async function poll() {
+  try {
+    const resp = await fetch(location.pathname.replace(/\/?$/, "/") + "status", {credentials: "same-origin"});
+    if (!resp.ok) throw new Error("HTTP " + resp.status);
+    render(await resp.json());
+  } catch (err) {
+    $("updated").innerHTML = '<span class="bad">Update failed: ' + esc(err.message) + "</span>";
+  }
+}
+
+poll();
+setInterval(poll, 2000);
+setInterval(renderAccounts, 1000);
+</script>
+</body>
+</html>
+`
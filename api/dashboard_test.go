@@ -0,0 +1,145 @@
+// Package api provides tests for the status dashboard.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/activity"
+	"kiro-go-proxy/latency"
+	"kiro-go-proxy/model"
+	"kiro-go-proxy/usage"
+)
+
+// =============================================================================
+// TestDashboardAuth
+// =============================================================================
+
+func TestDashboardAuth(t *testing.T) {
+	t.Run("disabled without an admin key", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/dashboard", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusForbidden, w.Code)
+	})
+
+	t.Run("asks browsers for credentials", func(t *testing.T) {
+		_, router := newAdminTestServer()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/dashboard", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Header().Get("WWW-Authenticate"), "Basic")
+	})
+
+	t.Run("rejects the proxy key", func(t *testing.T) {
+		_, router := newAdminTestServer()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/dashboard/status", nil)
+		req.SetBasicAuth("admin", "test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("accepts the admin key as Basic password or Bearer token", func(t *testing.T) {
+		_, router := newAdminTestServer()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/dashboard", nil)
+		req.SetBasicAuth("", "admin-key")
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+		assert.Contains(t, w.Body.String(), "Kiro Gateway Dashboard")
+
+		w = adminRequest(router, "GET", "/dashboard/status", "")
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
+
+// =============================================================================
+// TestDashboardStatus
+// =============================================================================
+
+func TestDashboardStatus(t *testing.T) {
+	server, router := newAdminTestServer()
+	server.ModelCache.Update([]model.Info{{ModelID: "claude-sonnet-4.5"}})
+	server.Usage.Record("test-key", "claude-sonnet-4", usage.Totals{Requests: 1, PromptTokens: 10, CompletionTokens: 5})
+	server.Latency.Observe("claude-sonnet-4", 300*time.Millisecond, true, 2*time.Second)
+
+	// A failed API request shows up in recent errors with its message
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/v1/models/no-such-model", nil)
+	req.Header.Set("Authorization", "Bearer test-key")
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	// Probes are not traffic
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/health", nil)
+	router.ServeHTTP(w, req)
+
+	w = adminRequest(router, "GET", "/dashboard/status", "")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var status struct {
+		Activity activity.Snapshot    `json:"activity"`
+		Latency  []latency.ModelStats `json:"latency"`
+		Accounts []interface{}        `json:"accounts"`
+		Models   []struct {
+			ID string `json:"id"`
+		} `json:"models"`
+		ModelCache map[string]interface{} `json:"model_cache"`
+		Usage      []struct {
+			KeyID            string `json:"key_id"`
+			Requests         int    `json:"requests"`
+			CompletionTokens int    `json:"completion_tokens"`
+		} `json:"usage"`
+		CircuitBreaker map[string]interface{} `json:"circuit_breaker"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+
+	assert.Equal(t, int64(1), status.Activity.RequestsTotal)
+	assert.Len(t, status.Activity.RecentErrors, 1)
+	recent := status.Activity.RecentErrors[0]
+	assert.Equal(t, "/v1/models/no-such-model", recent.Path)
+	assert.Equal(t, http.StatusNotFound, recent.Status)
+	assert.Contains(t, recent.Message, "does not exist")
+
+	assert.Len(t, status.Latency, 1)
+	assert.Equal(t, "claude-sonnet-4", status.Latency[0].Model)
+	assert.Equal(t, int64(300), status.Latency[0].FirstToken.P95)
+	assert.Equal(t, int64(2000), status.Latency[0].Total.P99)
+
+	assert.Len(t, status.Accounts, 1)
+	assert.Len(t, status.Models, 1)
+	assert.Equal(t, "claude-sonnet-4.5", status.Models[0].ID)
+	assert.Equal(t, float64(1), status.ModelCache["count"])
+	assert.Len(t, status.Usage, 1)
+	assert.Equal(t, usage.KeyID("test-key"), status.Usage[0].KeyID)
+	assert.Equal(t, 5, status.Usage[0].CompletionTokens)
+	assert.Equal(t, "closed", status.CircuitBreaker["state"])
+}
+
+// =============================================================================
+// TestErrorMessage
+// =============================================================================
+
+func TestErrorMessage(t *testing.T) {
+	assert.Equal(t, "bad model", errorMessage([]byte(`{"error":{"message":"bad model","type":"invalid_request_error"}}`)))
+	assert.Equal(t, "bad model", errorMessage([]byte(`{"type":"error","error":{"type":"not_found_error","message":"bad model"}}`)))
+	assert.Equal(t, "model not found", errorMessage([]byte(`{"error":"model not found"}`)))
+	assert.Empty(t, errorMessage([]byte(`{"error":{"message":"trunc`)))
+	assert.Empty(t, errorMessage(nil))
+}
@@ -0,0 +1,291 @@
+package api
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"sync"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/openapi"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// go generate downloads Swagger UI 5.17.14 and Redoc 2.1.5 into docsui
+//
+//go:generate sh -c "curl -fsSL -o docsui/swagger-ui.css https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14/swagger-ui.css && curl -fsSL -o docsui/swagger-ui-bundle.js https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14/swagger-ui-bundle.js && curl -fsSL -o docsui/redoc.standalone.js https://cdn.jsdelivr.net/npm/redoc@2.1.5/bundles/redoc.standalone.js"
+
+// docsFiles holds the Swagger UI and Redoc files, which are served from
+// /docs/assets so the docs pages load no third-party scripts
+//
+//go:embed docsui
+var docsFiles embed.FS
+
+// docsUI is the file system the docs pages are served from
+var docsUI fs.FS = docsFiles
+
+// docsAssets are the files of docsUI served under /docs/assets, by content type
+var docsAssets = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+	"redoc.standalone.js":  "text/javascript; charset=utf-8",
+}
+
+// apiSpec is the OpenAPI document, built once from the converter types
+var apiSpec = sync.OnceValue(func() []byte {
+	data, err := json.Marshal(buildAPISpec())
+	if err != nil {
+		log.Errorf("Failed to build OpenAPI spec: %v", err)
+	}
+	return data
+})
+
+// buildAPISpec describes the OpenAI- and Anthropic-compatible endpoints
+func buildAPISpec() *openapi.Spec {
+	spec := openapi.New("Kiro Gateway", config.AppVersion)
+	spec.Description = "OpenAI- and Anthropic-compatible API in front of Kiro (Amazon Q Developer / AWS CodeWhisperer)."
+	// The API root relative to /docs/openapi.json, so the spec also works under a mount prefix
+	spec.Servers = []string{"../"}
+	spec.SecuritySchemes["bearerAuth"] = openapi.Schema{"type": "http", "scheme": "bearer", "description": "PROXY_API_KEY"}
+	spec.SecuritySchemes["apiKeyAuth"] = openapi.Schema{"type": "apiKey", "in": "header", "name": "x-api-key", "description": "PROXY_API_KEY"}
+	security := []string{"bearerAuth", "apiKeyAuth"}
+
+	// Anthropic content is a string or an array of blocks
+	spec.Override(converter.AnthropicContent{}, openapi.Schema{
+		"oneOf": []openapi.Schema{
+			{"type": "string"},
+			{"type": "array", "items": spec.Schema(converter.AnthropicContentBlock{})},
+		},
+	})
+
+	// Responses API input and message content are a string or an array
+	spec.Override(converter.ResponsesInput{}, openapi.Schema{
+		"oneOf": []openapi.Schema{
+			{"type": "string"},
+			{"type": "array", "items": spec.Schema(converter.ResponsesInputItem{})},
+		},
+	})
+	spec.Override(converter.ResponsesContent{}, openapi.Schema{
+		"oneOf": []openapi.Schema{
+			{"type": "string"},
+			{"type": "array", "items": spec.Schema(converter.ResponsesContentPart{})},
+		},
+	})
+
+	errorBody := openapi.Schema{
+		"type": "object",
+		"properties": openapi.Schema{
+			"type": openapi.Schema{"type": "string", "description": `"error" on Anthropic routes`},
+			"error": openapi.Schema{
+				"type": "object",
+				"properties": openapi.Schema{
+					"message": openapi.Schema{"type": "string"},
+					"type":    openapi.Schema{"type": "string"},
+				},
+			},
+		},
+		"required": []string{"error"},
+	}
+
+	spec.Add("GET", "/v1/models", openapi.Operation{
+		Summary: "List models",
+		Tags:    []string{"OpenAI"},
+		Params: []openapi.Param{
+			{Name: "family", In: "query", Description: "Only models of a family: haiku, sonnet or opus"},
+			{Name: "verified", In: "query", Description: "true hides names that are only passed through to Kiro"},
+			{Name: "limit", In: "query", Description: "Page size (1-1000)"},
+			{Name: "after", In: "query", Description: "Return the models after this ID (last_id of the previous page)"},
+		},
+		Response: converter.OpenAIModelsResponse{},
+		Error:    errorBody,
+		Security: security,
+	})
+	spec.Add("GET", "/v1/models/{id}", openapi.Operation{
+		Summary:  "Get a model",
+		Tags:     []string{"OpenAI"},
+		Params:   []openapi.Param{{Name: "id", In: "path", Description: "Model ID or alias"}},
+		Response: converter.OpenAIModelData{},
+		Error:    errorBody,
+		Security: security,
+	})
+	spec.Add("POST", "/v1/chat/completions", openapi.Operation{
+		Summary:   "Create a chat completion",
+		Tags:      []string{"OpenAI"},
+		Request:   converter.OpenAIRequest{},
+		Response:  converter.OpenAIResponse{},
+		Streaming: true,
+		Error:     errorBody,
+		Security:  security,
+	})
+	spec.Add("POST", "/v1/responses", openapi.Operation{
+		Summary:     "Create a response",
+		Description: "OpenAI Responses API. Streaming sends semantic events such as response.output_text.delta and response.completed. Responses are not stored.",
+		Tags:        []string{"OpenAI"},
+		Request:     converter.ResponsesRequest{},
+		Response:    converter.ResponsesResponse{},
+		Streaming:   true,
+		Error:       errorBody,
+		Security:    security,
+	})
+	spec.Add("POST", "/v1/tokenize", openapi.Operation{
+		Summary:     "Count prompt tokens",
+		Description: "Estimates the prompt tokens of a chat completion request as the request would count them. Nothing is sent to Kiro.",
+		Tags:        []string{"OpenAI"},
+		Request:     converter.OpenAIRequest{},
+		Response: openapi.Schema{
+			"type": "object",
+			"properties": openapi.Schema{
+				"object":           openapi.Schema{"type": "string", "enum": []string{"tokenize"}},
+				"model":            openapi.Schema{"type": "string"},
+				"prompt_tokens":    openapi.Schema{"type": "integer"},
+				"max_input_tokens": openapi.Schema{"type": "integer"},
+				"fits_context":     openapi.Schema{"type": "boolean"},
+			},
+			"required": []string{"object", "model", "prompt_tokens", "max_input_tokens", "fits_context"},
+		},
+		Error:    errorBody,
+		Security: security,
+	})
+
+	spec.Add("POST", "/v1/messages", openapi.Operation{
+		Summary:   "Create a message",
+		Tags:      []string{"Anthropic"},
+		Params:    []openapi.Param{{Name: "anthropic-version", In: "header", Description: "Accepted and ignored"}},
+		Request:   converter.AnthropicRequest{},
+		Response:  anthropicMessageSchema(spec),
+		Streaming: true,
+		Error:     errorBody,
+		Security:  security,
+	})
+	spec.Add("POST", "/v1/messages/count_tokens", openapi.Operation{
+		Summary: "Count input tokens",
+		Tags:    []string{"Anthropic"},
+		Request: converter.AnthropicRequest{},
+		Response: openapi.Schema{
+			"type":       "object",
+			"properties": openapi.Schema{"input_tokens": openapi.Schema{"type": "integer"}},
+			"required":   []string{"input_tokens"},
+		},
+		Error:    errorBody,
+		Security: security,
+	})
+
+	return spec
+}
+
+// anthropicMessageSchema describes the non-streaming /v1/messages response
+// built by createMessage
+func anthropicMessageSchema(spec *openapi.Spec) openapi.Schema {
+	return openapi.Schema{
+		"type": "object",
+		"properties": openapi.Schema{
+			"id":            openapi.Schema{"type": "string"},
+			"type":          openapi.Schema{"type": "string", "enum": []string{"message"}},
+			"role":          openapi.Schema{"type": "string", "enum": []string{"assistant"}},
+			"model":         openapi.Schema{"type": "string"},
+			"content":       openapi.Schema{"type": "array", "items": spec.Schema(converter.AnthropicContentBlock{})},
+			"stop_reason":   openapi.Schema{"type": "string", "nullable": true},
+			"stop_sequence": openapi.Schema{"type": "string", "nullable": true},
+			"usage": openapi.Schema{
+				"type": "object",
+				"properties": openapi.Schema{
+					"input_tokens":                openapi.Schema{"type": "integer"},
+					"output_tokens":               openapi.Schema{"type": "integer"},
+					"cache_creation_input_tokens": openapi.Schema{"type": "integer"},
+					"cache_read_input_tokens":     openapi.Schema{"type": "integer"},
+				},
+			},
+		},
+		"required": []string{"id", "type", "role", "model", "content", "stop_reason", "usage"},
+	}
+}
+
+// OpenAPIHandler handles GET /docs/openapi.json
+func (s *Server) OpenAPIHandler(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json", apiSpec())
+}
+
+// DocsHandler handles GET /docs with Swagger UI for the OpenAPI spec
+func (s *Server) DocsHandler(c *gin.Context) {
+	docsPage(c, swaggerUIPage, "docs/openapi.json", "swagger-ui-bundle.js", "swagger-ui.css")
+}
+
+// RedocHandler handles GET /docs/redoc with a Redoc view of the OpenAPI spec
+func (s *Server) RedocHandler(c *gin.Context) {
+	docsPage(c, redocPage, "openapi.json", "redoc.standalone.js")
+}
+
+// DocsAssetHandler handles GET /docs/assets/:file with the embedded UI files
+func (s *Server) DocsAssetHandler(c *gin.Context) {
+	contentType, ok := docsAssets[c.Param("file")]
+	data, err := fs.ReadFile(docsUI, "docsui/"+c.Param("file"))
+	if !ok || err != nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// docsPage writes page, or a notice linking the spec at specURL when the
+// binary was built without running go generate and lacks the UI files the
+// page needs
+func docsPage(c *gin.Context, page, specURL string, assets ...string) {
+	for _, name := range assets {
+		if _, err := fs.Stat(docsUI, "docsui/"+name); err != nil {
+			page = fmt.Sprintf(docsUIMissingPage, specURL)
+			break
+		}
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(page))
+}
+
+// The pages load the UI from /docs/assets and the spec relative to their own
+// path, so they work under a mount prefix too
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Kiro Gateway API</title>
+  <link rel="stylesheet" href="docs/assets/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="docs/assets/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({url: location.pathname.replace(/\/?$/, "/") + "openapi.json", dom_id: "#swagger-ui"});
+  </script>
+</body>
+</html>
+`
+
+const redocPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Kiro Gateway API</title>
+</head>
+<body>
+  <redoc spec-url="openapi.json"></redoc>
+  <script src="assets/redoc.standalone.js"></script>
+</body>
+</html>
+`
+
+const docsUIMissingPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Kiro Gateway API</title>
+</head>
+<body>
+  <p>This build does not include the documentation UI. Run <code>go generate ./api</code> and rebuild,
+  or open the <a href="%s">OpenAPI spec</a> in any OpenAPI viewer.</p>
+</body>
+</html>
+`
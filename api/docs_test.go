@@ -0,0 +1,111 @@
+// Package api provides tests for the API docs endpoints.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withDocsUI serves the docs pages from stand-ins for the generated UI files
+func withDocsUI(t *testing.T) {
+	docsUI = fstest.MapFS{
+		"docsui/swagger-ui.css":       {Data: []byte("body {}")},
+		"docsui/swagger-ui-bundle.js": {Data: []byte("window.SwaggerUIBundle = {}")},
+		"docsui/redoc.standalone.js":  {Data: []byte("window.Redoc = {}")},
+		"docsui/README.md":            {Data: []byte("# Documentation UI")},
+	}
+	t.Cleanup(func() { docsUI = docsFiles })
+}
+
+// =============================================================================
+// TestDocs
+// =============================================================================
+
+func TestDocs(t *testing.T) {
+	t.Run("serves the UIs without authentication", func(t *testing.T) {
+		withDocsUI(t)
+		_, router := newTestServer("test-key")
+
+		for path, ui := range map[string]string{"/docs": "swagger-ui", "/docs/redoc": "redoc"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code, path)
+			assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+			assert.Contains(t, w.Body.String(), ui)
+			assert.NotContains(t, w.Body.String(), "https://")
+		}
+	})
+
+	t.Run("serves the embedded UI files", func(t *testing.T) {
+		withDocsUI(t)
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/docs/assets/swagger-ui-bundle.js", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/javascript")
+		assert.Equal(t, "window.SwaggerUIBundle = {}", w.Body.String())
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/docs/assets/README.md", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("links the spec when the UI files are missing", func(t *testing.T) {
+		docsUI = fstest.MapFS{}
+		t.Cleanup(func() { docsUI = docsFiles })
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/docs/redoc", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "go generate ./api")
+		assert.Contains(t, w.Body.String(), `href="openapi.json"`)
+	})
+
+	t.Run("spec documents the OpenAI and Anthropic routes", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/docs/openapi.json", nil)
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var spec struct {
+			OpenAPI    string                            `json:"openapi"`
+			Paths      map[string]map[string]interface{} `json:"paths"`
+			Components struct {
+				Schemas map[string]struct {
+					Properties map[string]interface{} `json:"properties"`
+					Required   []string               `json:"required"`
+				} `json:"schemas"`
+			} `json:"components"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &spec))
+		assert.Equal(t, "3.0.3", spec.OpenAPI)
+
+		for _, path := range []string{"/v1/models", "/v1/models/{id}", "/v1/chat/completions", "/v1/responses", "/v1/tokenize", "/v1/messages", "/v1/messages/count_tokens"} {
+			assert.Contains(t, spec.Paths, path)
+		}
+		assert.Contains(t, spec.Paths["/v1/chat/completions"], "post")
+
+		// Schemas follow the converter structs
+		openAI := spec.Components.Schemas["OpenAIRequest"]
+		assert.Contains(t, openAI.Properties, "max_completion_tokens")
+		assert.Contains(t, openAI.Properties, "response_format")
+		assert.ElementsMatch(t, []string{"model", "messages"}, openAI.Required)
+		assert.Contains(t, spec.Components.Schemas["AnthropicRequest"].Properties, "thinking")
+		assert.Contains(t, spec.Components.Schemas["AnthropicContentBlock"].Properties, "tool_use_id")
+	})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"strconv"
+
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/model"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/utils"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// draftHeader reports how draft routing answered a chat completion: "draft"
+// when the draft model's answer was returned, "escalated" when the requested
+// model answered instead
+const draftHeader = "x-kiro-draft"
+
+// escalateHeader lets a client skip the draft, e.g. when it retries a request
+// whose draft answer it had low confidence in
+const escalateHeader = "x-kiro-escalate"
+
+// draftChatCompletion answers a non-streaming chat completion with DRAFT_MODEL
+// when draft routing applies to it: the API key has it enabled, the requested
+// model is a sonnet or opus model and the prompt is at most
+// DRAFT_MAX_PROMPT_TOKENS. It returns nil when the draft does not apply or
+// fails, including JSON mode output that does not validate, so the caller
+// escalates to the requested model.
+func (s *Server) draftChatCompletion(c *gin.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, modelName string, promptTokens int, limits stream.Limits, responseFormat *converter.OpenAIResponseFormat, n int, logprobs bool) *converter.OpenAIResponse {
+	apiKey := c.GetString(apiKeyContextKey)
+	if !cfg.DraftRoutingFor(apiKey) || cfg.DraftModel == "" {
+		return nil
+	}
+	if family := model.ExtractModelFamily(payload.ModelID()); family != "sonnet" && family != "opus" {
+		return nil
+	}
+	if cfg.DraftMaxPromptTokens > 0 && promptTokens > cfg.DraftMaxPromptTokens {
+		return nil
+	}
+	if escalate, _ := strconv.ParseBool(c.GetHeader(escalateHeader)); escalate {
+		c.Header(draftHeader, "escalated")
+		return nil
+	}
+
+	draftID := s.ModelResolver.Resolve(cfg.DraftModel).InternalID
+	draft := payload.Clone()
+	draft.SetModelID(draftID)
+	draft.ConversationState.ConversationID = utils.GenerateConversationID()
+
+	// One attempt only: output the draft model cannot get right is escalated
+	draftCfg := *cfg
+	draftCfg.JSONModeMaxRetries = 0
+
+	ctx := c.Request.Context()
+	response, status, errBody := s.createChatCompletion(ctx, apiKey, &draftCfg, apiURL, draft, modelName, draft.ConversationState.ConversationID, promptTokens, limits, responseFormat, n, logprobs)
+	if response == nil {
+		log.Infof("Draft by %s failed (%d: %v), escalating to %s", draftID, status, errBody["error"], payload.ModelID())
+		c.Header(draftHeader, "escalated")
+		return nil
+	}
+
+	log.Debugf("Draft by %s answered the request for %s", draftID, payload.ModelID())
+	accesslog.FromContext(ctx).SetResolvedModel(draftID)
+	c.Header(draftHeader, "draft")
+	return response
+}
@@ -0,0 +1,110 @@
+// Package api provides tests for draft routing.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/converter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newDraftTestServer returns a test server with draft routing on and the given
+// Kiro responses queued
+func newDraftTestServer(responses ...clienttest.Response) (*Server, *clienttest.Fake, http.Handler) {
+	server, router := newTestServer("test-key")
+	server.Cfg.DraftRouting = true
+	server.Cfg.DraftModel = "claude-haiku-4.5"
+	server.Cfg.JSONModeMaxRetries = 1
+	fake := clienttest.NewFake(responses...)
+	server.HttpClient = fake
+	return server, fake, router
+}
+
+func requestedModels(fake *clienttest.Fake) []string {
+	var models []string
+	for _, request := range fake.Requests() {
+		models = append(models, request.Payload.(*converter.KiroPayload).ModelID())
+	}
+	return models
+}
+
+// =============================================================================
+// TestDraftRouting
+// =============================================================================
+
+func TestDraftRouting(t *testing.T) {
+	chat := `{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Complete: fmt.Print"}]}`
+	schema := `{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Name?"}],
+		"response_format":{"type":"json_schema","json_schema":{"name":"n","schema":{"type":"object","required":["name"]}}}}`
+
+	t.Run("answers with the draft model", func(t *testing.T) {
+		_, fake, router := newDraftTestServer(clienttest.Stream(`{"content":"ln(\"hi\")"}`))
+
+		w := postJSON(router, "/v1/chat/completions", chat)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "draft", w.Header().Get(draftHeader))
+		var resp converter.OpenAIResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, `ln("hi")`, resp.Choices[0].Message.Content)
+		assert.Equal(t, []string{"claude-haiku-4.5"}, requestedModels(fake))
+	})
+
+	t.Run("escalates drafts failing schema validation", func(t *testing.T) {
+		_, fake, router := newDraftTestServer(
+			clienttest.Stream(`{"content":"{\"title\": \"x\"}"}`),
+			clienttest.Stream(`{"content":"{\"name\": \"Ada\"}"}`),
+		)
+
+		w := postJSON(router, "/v1/chat/completions", schema)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "escalated", w.Header().Get(draftHeader))
+		assert.Contains(t, w.Body.String(), `Ada`)
+		assert.Equal(t, []string{"claude-haiku-4.5", "claude-sonnet-4.5"}, requestedModels(fake))
+	})
+
+	t.Run("escalates when the client asks", func(t *testing.T) {
+		_, fake, router := newDraftTestServer(clienttest.Stream(`{"content":"Println"}`))
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(chat))
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set(escalateHeader, "true")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "escalated", w.Header().Get(draftHeader))
+		assert.Equal(t, []string{"claude-sonnet-4.5"}, requestedModels(fake))
+	})
+
+	t.Run("skips large prompts and haiku requests", func(t *testing.T) {
+		server, fake, router := newDraftTestServer(clienttest.Stream(`{"content":"A"}`), clienttest.Stream(`{"content":"B"}`))
+		server.Cfg.DraftMaxPromptTokens = 1
+
+		w := postJSON(router, "/v1/chat/completions", chat)
+		assert.Empty(t, w.Header().Get(draftHeader))
+
+		server.Cfg.DraftMaxPromptTokens = 0
+		w = postJSON(router, "/v1/chat/completions", `{"model":"claude-haiku-4.5","messages":[{"role":"user","content":"Hi"}]}`)
+		assert.Empty(t, w.Header().Get(draftHeader))
+
+		assert.Equal(t, []string{"claude-sonnet-4.5", "claude-haiku-4.5"}, requestedModels(fake))
+	})
+
+	t.Run("follows the API key setting", func(t *testing.T) {
+		server, fake, router := newDraftTestServer(clienttest.Stream(`{"content":"A"}`))
+		server.Cfg.DraftRoutingKeys = map[string]bool{"test-key": false}
+
+		w := postJSON(router, "/v1/chat/completions", chat)
+
+		assert.Empty(t, w.Header().Get(draftHeader))
+		assert.Equal(t, []string{"claude-sonnet-4.5"}, requestedModels(fake))
+	})
+}
@@ -0,0 +1,164 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+
+	"kiro-go-proxy/embeddings"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// maxEmbeddingInputs matches the OpenAI limit on inputs per request
+const maxEmbeddingInputs = 2048
+
+// embeddingsRequest is an OpenAI embeddings request
+type embeddingsRequest struct {
+	Model          string          `json:"model"`
+	Input          json.RawMessage `json:"input"`
+	EncodingFormat string          `json:"encoding_format"`
+	Dimensions     int             `json:"dimensions"`
+	User           string          `json:"user"`
+}
+
+// EmbeddingsHandler handles POST /v1/embeddings with the backend selected by
+// EMBEDDINGS_BACKEND
+func (s *Server) EmbeddingsHandler(c *gin.Context) {
+	if s.Embeddings == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message": "Embeddings are not enabled. Set EMBEDDINGS_BACKEND to \"proxy\" or \"lexical\"",
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	var req embeddingsRequest
+	err := c.ShouldBindJSON(&req)
+	var inputs []string
+	if err == nil {
+		inputs, err = parseEmbeddingInput(req.Input)
+	}
+	if err == nil && req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64" {
+		err = fmt.Errorf("encoding_format must be \"float\" or \"base64\"")
+	}
+	if err == nil && req.Dimensions < 0 {
+		err = fmt.Errorf("dimensions must be positive")
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": fmt.Sprintf("Invalid request: %v", err),
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	result, err := s.Embeddings.Embed(c.Request.Context(), req.Model, inputs, req.Dimensions)
+	if err != nil {
+		s.embeddingsError(c, err)
+		return
+	}
+
+	if u := usage.FromContext(c.Request.Context()); u != nil {
+		u.SetModel(result.Model)
+		u.AddTokens(result.PromptTokens, 0)
+	}
+
+	data := make([]gin.H, len(result.Vectors))
+	for i, vector := range result.Vectors {
+		var embedding interface{} = vector
+		if req.EncodingFormat == "base64" {
+			embedding = encodeEmbedding(vector)
+		}
+		data[i] = gin.H{"object": "embedding", "index": i, "embedding": embedding}
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"object": "list",
+		"data":   data,
+		"model":  result.Model,
+		"usage": gin.H{
+			"prompt_tokens": result.PromptTokens,
+			"total_tokens":  result.PromptTokens,
+		},
+	})
+}
+
+// embeddingsError reports a backend failure. Provider 4xx responses are passed
+// through so clients see why their request was rejected; anything else is a 502.
+func (s *Server) embeddingsError(c *gin.Context, err error) {
+	var upstream *embeddings.UpstreamError
+	if errors.As(err, &upstream) && upstream.StatusCode >= 400 && upstream.StatusCode < 500 {
+		if json.Valid([]byte(upstream.Body)) {
+			c.Data(upstream.StatusCode, "application/json", []byte(upstream.Body))
+			return
+		}
+		c.JSON(upstream.StatusCode, gin.H{
+			"error": gin.H{
+				"message": upstream.Body,
+				"type":    "invalid_request_error",
+			},
+		})
+		return
+	}
+
+	log.Errorf("Embeddings request failed: %v", err)
+	c.JSON(http.StatusBadGateway, gin.H{
+		"error": gin.H{
+			"message": err.Error(),
+			"type":    "api_error",
+		},
+	})
+}
+
+// parseEmbeddingInput accepts a string or an array of strings. Token arrays are
+// rejected: neither backend shares a tokenizer with the client.
+func parseEmbeddingInput(raw json.RawMessage) ([]string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, fmt.Errorf("input is required")
+	}
+
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		if single == "" {
+			return nil, fmt.Errorf("input cannot be an empty string")
+		}
+		return []string{single}, nil
+	}
+
+	var inputs []string
+	if err := json.Unmarshal(raw, &inputs); err != nil {
+		return nil, fmt.Errorf("input must be a string or an array of strings; token arrays are not supported")
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("input cannot be an empty array")
+	}
+	if len(inputs) > maxEmbeddingInputs {
+		return nil, fmt.Errorf("input cannot contain more than %d items", maxEmbeddingInputs)
+	}
+	for i, input := range inputs {
+		if input == "" {
+			return nil, fmt.Errorf("input[%d] cannot be an empty string", i)
+		}
+	}
+	return inputs, nil
+}
+
+// encodeEmbedding returns the base64 of the vector's little-endian float32 bytes,
+// the encoding_format=base64 representation
+func encodeEmbedding(vector []float32) string {
+	buf := make([]byte, 4*len(vector))
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(buf[4*i:], math.Float32bits(v))
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
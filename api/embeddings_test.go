@@ -0,0 +1,138 @@
+// Package api provides tests for the embeddings endpoint.
+package api
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/embeddings"
+)
+
+// postEmbeddings sends an embeddings request and decodes the JSON response
+func postEmbeddings(router *gin.Engine, body string) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/embeddings", bytes.NewBufferString(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+
+	var resp map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+// =============================================================================
+// TestEmbeddingsHandler
+// =============================================================================
+
+func TestEmbeddingsHandler(t *testing.T) {
+	newLexicalServer := func() *gin.Engine {
+		server, router := newTestServer("test-key")
+		server.Embeddings = embeddings.NewBackend(&config.Config{EmbeddingsBackend: embeddings.BackendLexical, EmbeddingsDimensions: 16})
+		return router
+	}
+
+	t.Run("disabled without backend", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		code, resp := postEmbeddings(router, `{"model":"m","input":"hello"}`)
+		assert.Equal(t, http.StatusNotFound, code)
+		assert.Contains(t, resp["error"].(map[string]interface{})["message"], "EMBEDDINGS_BACKEND")
+	})
+
+	t.Run("requires API key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/embeddings", bytes.NewBufferString(`{"input":"hello"}`))
+		newLexicalServer().ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("OpenAI response shape", func(t *testing.T) {
+		code, resp := postEmbeddings(newLexicalServer(), `{"model":"text-embedding-3-small","input":["hello world","goodbye"]}`)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "list", resp["object"])
+		assert.Equal(t, "text-embedding-3-small", resp["model"])
+
+		data := resp["data"].([]interface{})
+		assert.Len(t, data, 2)
+		second := data[1].(map[string]interface{})
+		assert.Equal(t, "embedding", second["object"])
+		assert.Equal(t, float64(1), second["index"])
+		assert.Len(t, second["embedding"], 16)
+
+		usage := resp["usage"].(map[string]interface{})
+		assert.Greater(t, usage["prompt_tokens"], float64(0))
+		assert.Equal(t, usage["prompt_tokens"], usage["total_tokens"])
+	})
+
+	t.Run("base64 encoding and dimensions", func(t *testing.T) {
+		code, resp := postEmbeddings(newLexicalServer(), `{"input":"hello","encoding_format":"base64","dimensions":4}`)
+		assert.Equal(t, http.StatusOK, code)
+
+		encoded := resp["data"].([]interface{})[0].(map[string]interface{})["embedding"].(string)
+		raw, err := base64.StdEncoding.DecodeString(encoded)
+		assert.NoError(t, err)
+		assert.Len(t, raw, 16)
+
+		var norm float64
+		for i := 0; i < 4; i++ {
+			v := math.Float32frombits(binary.LittleEndian.Uint32(raw[4*i:]))
+			norm += float64(v) * float64(v)
+		}
+		assert.InDelta(t, 1.0, norm, 1e-5)
+	})
+
+	t.Run("rejects invalid input", func(t *testing.T) {
+		router := newLexicalServer()
+		for _, body := range []string{
+			`{"model":"m"}`,
+			`{"input":""}`,
+			`{"input":[]}`,
+			`{"input":["ok",""]}`,
+			`{"input":[1,2,3]}`,
+			`{"input":"x","encoding_format":"binary"}`,
+		} {
+			code, resp := postEmbeddings(router, body)
+			assert.Equal(t, http.StatusBadRequest, code, body)
+			assert.Equal(t, "invalid_request_error", resp["error"].(map[string]interface{})["type"], body)
+		}
+	})
+
+	t.Run("passes provider client errors through", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"model not found","type":"invalid_request_error"}}`))
+		}))
+		defer upstream.Close()
+
+		server, router := newTestServer("test-key")
+		server.Embeddings = embeddings.NewBackend(&config.Config{EmbeddingsBackend: embeddings.BackendProxy, EmbeddingsURL: upstream.URL, EmbeddingsTimeout: 5})
+
+		code, resp := postEmbeddings(router, `{"model":"nope","input":"hello"}`)
+		assert.Equal(t, http.StatusBadRequest, code)
+		assert.Equal(t, "model not found", resp["error"].(map[string]interface{})["message"])
+	})
+
+	t.Run("provider failure is a bad gateway", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusInternalServerError)
+		}))
+		defer upstream.Close()
+
+		server, router := newTestServer("test-key")
+		server.Embeddings = embeddings.NewBackend(&config.Config{EmbeddingsBackend: embeddings.BackendProxy, EmbeddingsURL: upstream.URL, EmbeddingsTimeout: 5})
+
+		code, resp := postEmbeddings(router, `{"model":"m","input":"hello"}`)
+		assert.Equal(t, http.StatusBadGateway, code)
+		assert.Equal(t, "api_error", resp["error"].(map[string]interface{})["type"])
+	})
+}
@@ -0,0 +1,161 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/client"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/model"
+	"kiro-go-proxy/piifilter"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// modelFallbackHeader names the model that answered when the resolved model failed
+const modelFallbackHeader = "x-kiro-fallback-model"
+
+// postStream sends payload to Kiro. When Kiro rejects the request and
+// model_fallback_chains has fallbacks for the payload's model, the request is sent
+// again with each fallback in turn until one is accepted. The payload is left on
+// the model that answered, so follow-up requests (continuations, JSON mode
+// retries) use it too. While the circuit breaker is open the error is a
+// *client.CircuitOpenError and Retry-After is set on the response. A response
+// other than 200 is returned as a *client.UpstreamError. The PII filter runs
+// first and may redact the payload or block the request.
+func (s *Server) postStream(ctx context.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload) (*http.Response, error) {
+	if err := filterPII(ctx, cfg, payload); err != nil {
+		return nil, err
+	}
+	resp, err := s.HttpClient.PostStream(ctx, apiURL, payload)
+	if err != nil {
+		var open *client.CircuitOpenError
+		if errors.As(err, &open) {
+			setResponseHeader(ctx, "Retry-After", strconv.Itoa(client.RetryAfterSeconds(open.RetryAfter)))
+		}
+		return nil, err
+	}
+	if !shouldFallback(resp.StatusCode) {
+		return upstreamResult(ctx, resp, payload.ModelID())
+	}
+
+	for _, fallback := range model.FallbackChain(payload.ModelID(), cfg.ModelFallbackChains) {
+		if ctx.Err() != nil {
+			break
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		log.Warnf("Model %s failed with status %d, retrying with %s: %.200s", payload.ModelID(), resp.StatusCode, fallback, body)
+
+		payload.SetModelID(fallback)
+		resp, err = s.HttpClient.PostStream(ctx, apiURL, payload)
+		if err != nil {
+			// The breaker guards Kiro as a whole, so an open one fails every fallback too
+			return nil, err
+		}
+		if !shouldFallback(resp.StatusCode) {
+			accesslog.FromContext(ctx).SetResolvedModel(fallback)
+			setResponseHeader(ctx, modelFallbackHeader, fallback)
+			break
+		}
+	}
+	return upstreamResult(ctx, resp, payload.ModelID())
+}
+
+// upstreamResult returns resp when Kiro accepted the request for modelID, else
+// its error. An accepted model is recorded as serving the request unless Kiro
+// names another in its stream.
+func upstreamResult(ctx context.Context, resp *http.Response, modelID string) (*http.Response, error) {
+	if resp.StatusCode != http.StatusOK {
+		err := client.ReadUpstreamError(resp)
+		log.Warnf("Kiro rejected the request: %v", err)
+		return nil, err
+	}
+	usage.FromContext(ctx).SetServedModel(modelID)
+	return resp, nil
+}
+
+// shouldFallback reports whether an upstream status may succeed with another
+// model: an unknown model, a throttled one or a server error. Other client
+// errors, such as a malformed request or failed authentication, affect every
+// model alike.
+func shouldFallback(status int) bool {
+	return status == http.StatusNotFound || status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+}
+
+// requestFailedStatus returns the status and OpenAI error type for a failed Kiro
+// request: the translated Kiro error, 503 while the circuit breaker is open, else 500
+func requestFailedStatus(err error) (int, string) {
+	var timeout *stream.RequestTimeoutError
+	if errors.As(err, &timeout) {
+		return http.StatusGatewayTimeout, "timeout_error"
+	}
+	var upstream *client.UpstreamError
+	if errors.As(err, &upstream) {
+		return upstream.Status(), upstream.ErrorType()
+	}
+	var open *client.CircuitOpenError
+	if errors.As(err, &open) {
+		return http.StatusServiceUnavailable, "service_unavailable"
+	}
+	var blocked *piifilter.BlockedError
+	if errors.As(err, &blocked) {
+		return http.StatusBadRequest, "invalid_request_error"
+	}
+	return http.StatusInternalServerError, "internal_error"
+}
+
+// requestFailedMessage is the error message for a failed Kiro request. Kiro's own
+// message is passed on as it is; the status and type already classify it.
+func requestFailedMessage(err error) string {
+	var upstream *client.UpstreamError
+	if errors.As(err, &upstream) {
+		return upstream.Message
+	}
+	var timeout *stream.RequestTimeoutError
+	if errors.As(err, &timeout) {
+		return fmt.Sprintf("Request timed out after %v", timeout.Timeout)
+	}
+	var blocked *piifilter.BlockedError
+	if errors.As(err, &blocked) {
+		return blocked.Error()
+	}
+	return fmt.Sprintf("Request failed: %v", err)
+}
+
+// streamFailedStatus returns the status, OpenAI error type and message for a Kiro
+// response that failed while being read: a 504 when the request ran out of time,
+// else a 500
+func streamFailedStatus(err error) (int, string, string) {
+	var timeout *stream.RequestTimeoutError
+	if errors.As(err, &timeout) {
+		status, errType := requestFailedStatus(err)
+		return status, errType, requestFailedMessage(err)
+	}
+	return http.StatusInternalServerError, "internal_error", fmt.Sprintf("Stream processing failed: %v", err)
+}
+
+// requestFailedBody returns the status and OpenAI error body for a failed Kiro
+// request, with the error code (e.g. context_length_exceeded) when Kiro's error
+// has one
+func requestFailedBody(err error) (int, gin.H) {
+	status, errType := requestFailedStatus(err)
+	body := gin.H{
+		"message": requestFailedMessage(err),
+		"type":    errType,
+	}
+	var upstream *client.UpstreamError
+	if errors.As(err, &upstream) && upstream.Code() != "" {
+		body["code"] = upstream.Code()
+	}
+	return status, gin.H{"error": body}
+}
@@ -0,0 +1,90 @@
+// Package api provides tests for model fallback on upstream failure.
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/converter"
+)
+
+// =============================================================================
+// TestModelFallback
+// =============================================================================
+
+func TestModelFallback(t *testing.T) {
+	body := `{"model":"claude-opus-4.5","messages":[{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello"},{"role":"user","content":"Bye"}]}`
+
+	chat := func(router http.Handler) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("retries with the fallback chain", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.ModelFallbackChains = map[string][]string{"claude-opus-*": {"claude-sonnet-4.5", "claude-haiku-4.5"}}
+		fake := clienttest.NewFake(
+			clienttest.Response{StatusCode: http.StatusInternalServerError, Body: "model unavailable"},
+			clienttest.Response{StatusCode: http.StatusTooManyRequests, Body: "throttled"},
+			clienttest.Stream(`{"content":"Hello"}`),
+		)
+		server.HttpClient = fake
+
+		w := chat(router)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "claude-haiku-4.5", w.Header().Get(modelFallbackHeader))
+		assert.Contains(t, w.Body.String(), "Hello")
+
+		requests := fake.Requests()
+		assert.Len(t, requests, 3)
+		last := requests[2].Payload.(*converter.KiroPayload)
+		assert.Equal(t, "claude-haiku-4.5", last.ModelID())
+		history := last.ConversationState.History[0].(map[string]interface{})["userInputMessage"].(map[string]interface{})
+		assert.Equal(t, "claude-haiku-4.5", history["modelId"])
+	})
+
+	t.Run("returns the last error when every model fails", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.ModelFallbackChains = map[string][]string{"*": {"claude-sonnet-4.5"}}
+		server.HttpClient = clienttest.NewFake(
+			clienttest.Response{StatusCode: http.StatusInternalServerError, Body: "model unavailable"},
+			clienttest.Response{StatusCode: http.StatusServiceUnavailable, Body: "overloaded"},
+		)
+
+		w := chat(router)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), "overloaded")
+		assert.Empty(t, w.Header().Get(modelFallbackHeader))
+	})
+
+	t.Run("no fallback without a chain or on client errors", func(t *testing.T) {
+		for _, tt := range []struct {
+			chains map[string][]string
+			status int
+			want   int
+		}{
+			{nil, http.StatusInternalServerError, http.StatusInternalServerError},
+			// Kiro rejecting the proxy's token is not the client's fault
+			{map[string][]string{"*": {"claude-sonnet-4.5"}}, http.StatusForbidden, http.StatusBadGateway},
+			// A malformed request fails on every model
+			{map[string][]string{"*": {"claude-sonnet-4.5"}}, http.StatusBadRequest, http.StatusBadRequest},
+		} {
+			server, router := newTestServer("test-key")
+			server.Cfg.ModelFallbackChains = tt.chains
+			fake := clienttest.NewFake(clienttest.Response{StatusCode: tt.status, Body: "failed"})
+			server.HttpClient = fake
+
+			w := chat(router)
+			assert.Equal(t, tt.want, w.Code)
+			assert.Len(t, fake.Requests(), 1)
+		}
+	})
+}
@@ -0,0 +1,248 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/debug"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/tracing"
+	"kiro-go-proxy/usage"
+	"kiro-go-proxy/utils"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// setupGeminiRoutes registers the Gemini-compatible /v1beta routes
+func (s *Server) setupGeminiRoutes(r *gin.Engine) {
+	v1beta := r.Group("/v1beta")
+	v1beta.Use(s.AuthMiddleware(), s.RateLimitMiddleware(), s.RequestTimeoutMiddleware())
+	{
+		// The model and method share a path segment: /models/{model}:{method}
+		v1beta.POST("/models/*action", s.IdempotencyMiddleware(), s.UsageMiddleware(), s.DebugCaptureMiddleware(), s.AuditMiddleware(), s.GeminiHandler)
+	}
+}
+
+// GeminiHandler handles POST /v1beta/models/{model}:generateContent,
+// :streamGenerateContent and :countTokens (Gemini-compatible)
+func (s *Server) GeminiHandler(c *gin.Context) {
+	action := strings.TrimPrefix(c.Param("action"), "/")
+	sep := strings.LastIndex(action, ":")
+	if sep <= 0 {
+		geminiError(c, http.StatusNotFound, fmt.Sprintf("Unknown method for %s", action))
+		return
+	}
+	modelName, method := action[:sep], action[sep+1:]
+
+	switch method {
+	case "generateContent", "streamGenerateContent", "countTokens":
+	default:
+		geminiError(c, http.StatusNotFound, fmt.Sprintf("Method '%s' is not supported", method))
+		return
+	}
+
+	var req converter.GeminiRequest
+	if err := s.bindRequest(c, &req, converter.GeminiRequestFields); err != nil {
+		geminiValidationError(c, err)
+		return
+	}
+
+	// Convert Gemini request to unified format
+	unifiedMessages, systemPrompt := converter.ConvertGeminiToUnified(&req)
+	unifiedTools := converter.ConvertGeminiToolsToUnified(req.Tools)
+
+	// Estimate prompt tokens for usage reporting
+	promptTokens := converter.EstimateInputTokens(unifiedMessages, systemPrompt, unifiedTools)
+
+	if method == "countTokens" {
+		c.JSON(http.StatusOK, gin.H{
+			"totalTokens": promptTokens,
+		})
+		return
+	}
+
+	// Settings stay fixed for this request even if the config is reloaded
+	cfg := s.currentConfig()
+
+	if err := converter.CheckRequestLimits(unifiedMessages, systemPrompt, unifiedTools, cfg); err != nil {
+		geminiError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Resolve model
+	resolution := s.ModelResolver.Resolve(modelName)
+	log.Debugf("Model resolution: %s -> %s (source: %s)", modelName, resolution.InternalID, resolution.Source)
+	cfg = converter.ApplyModelProfile(cfg, resolution.InternalID)
+
+	// Response IDs are per request; the Kiro conversation may continue across requests
+	conversationID := utils.GenerateConversationID()
+	kiroConversationID := resolveConversationID(c.Request.Context(), cfg, unifiedMessages, systemPrompt)
+	debug.FromContext(c.Request.Context()).SetConversationID(kiroConversationID)
+	accesslog.FromContext(c.Request.Context()).SetConversationID(kiroConversationID)
+	usage.FromContext(c.Request.Context()).SetModel(modelName)
+	accesslog.FromContext(c.Request.Context()).SetResolvedModel(resolution.InternalID)
+
+	// Build Kiro payload
+	_, buildSpan := tracing.StartSpan(c.Request.Context(), "converter.BuildKiroPayload", tracing.SpanKindInternal)
+	payload, err := converter.BuildKiroPayload(
+		unifiedMessages,
+		systemPrompt,
+		resolution.InternalID,
+		unifiedTools,
+		kiroConversationID,
+		s.AuthManager.ProfileArn(),
+		s.ModelCache.GetMaxInputTokens(resolution.InternalID),
+		cfg,
+	)
+	buildSpan.End()
+
+	var contextErr *converter.ContextLengthError
+	if errors.As(err, &contextErr) {
+		geminiError(c, http.StatusBadRequest, contextErr.Error())
+		return
+	}
+	if err != nil {
+		geminiError(c, http.StatusInternalServerError, "Failed to build request payload")
+		return
+	}
+
+	// Forward sampling settings and emulate maxOutputTokens/stopSequences on the response
+	inference, limits := geminiInferenceSettings(&req)
+	applyProfileMaxTokens(cfg, resolution.InternalID, inference, &limits)
+	converter.ApplyInferenceConfig(payload, inference, cfg)
+	limits.ToolNames = payload.ToolNames
+
+	// Build URL
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+
+	if method == "streamGenerateContent" {
+		s.handleStreamingGemini(c, cfg, apiURL, payload, modelName, conversationID, promptTokens, limits)
+	} else {
+		s.handleNonStreamingGemini(c, cfg, apiURL, payload, modelName, conversationID, promptTokens, limits)
+	}
+}
+
+// geminiInferenceSettings extracts maxOutputTokens, temperature, topP and stopSequences from a Gemini request
+func geminiInferenceSettings(req *converter.GeminiRequest) (*converter.InferenceConfiguration, stream.Limits) {
+	inference := &converter.InferenceConfiguration{}
+	limits := stream.Limits{}
+
+	if gc := req.GenerationConfig; gc != nil {
+		inference.Temperature = gc.Temperature
+		inference.TopP = gc.TopP
+		limits.StopSequences = gc.StopSequences
+
+		if gc.MaxOutputTokens > 0 {
+			maxTokens := gc.MaxOutputTokens
+			inference.MaxTokens = &maxTokens
+			limits.MaxTokens = maxTokens
+		}
+	}
+
+	return inference, limits
+}
+
+func (s *Server) handleStreamingGemini(c *gin.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, model, conversationID string, promptTokens int, limits stream.Limits) {
+	// A failed write to the client cancels the request
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	resp, err := s.postStream(ctx, cfg, apiURL, payload)
+	if err != nil {
+		status, _ := requestFailedStatus(err)
+		geminiError(c, status, requestFailedMessage(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	w, ok := newSSEWriter(c, cfg, cancel)
+	if !ok {
+		geminiError(c, http.StatusInternalServerError, "Streaming not supported")
+		return
+	}
+
+	// alt=sse selects SSE framing; otherwise the chunks form one JSON array
+	sse := c.Query("alt") == "sse"
+	keepAlive := stream.GeminiJSONKeepAlive
+	if sse {
+		c.Header("Content-Type", "text/event-stream")
+		keepAlive = stream.OpenAIKeepAlive
+	} else {
+		c.Header("Content-Type", "application/json")
+	}
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	events := stream.StreamToGemini(ctx, resp, model, conversationID, cfg.FirstTokenTimeout, true, cfg, s.ModelCache, promptTokens, limits, sse)
+	events = stream.WithKeepAlive(events, keepAliveInterval(cfg), keepAlive)
+
+	writeEvents(ctx, w, events)
+}
+
+func (s *Server) handleNonStreamingGemini(c *gin.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, model, conversationID string, promptTokens int, limits stream.Limits) {
+	ctx := c.Request.Context()
+	resp, err := s.postStream(ctx, cfg, apiURL, payload)
+	if err != nil {
+		status, _ := requestFailedStatus(err)
+		geminiError(c, status, requestFailedMessage(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	// Collect stream result
+	result, err := stream.CollectStreamResult(ctx, resp, cfg.FirstTokenTimeout, true, cfg, limits)
+	if err != nil {
+		status, _, message := streamFailedStatus(err)
+		geminiError(c, status, message)
+		return
+	}
+	result = s.continueTruncated(ctx, cfg, apiURL, payload, limits, result)
+
+	// Calculate token usage
+	completionTokens := stream.CountCompletionTokens(result.Content, result.ThinkingContent, result.ToolCalls)
+	promptTokens, totalTokens, _, _ := stream.CalculateTokensFromContextUsage(
+		result.ContextUsagePercentage,
+		completionTokens,
+		promptTokens,
+		s.ModelCache,
+		model,
+	)
+	usage.FromContext(ctx).AddTokens(promptTokens, completionTokens)
+
+	response := converter.CreateGeminiResponse(
+		conversationID,
+		model,
+		result.Content,
+		convertParserToolCalls(result.ToolCalls),
+		stream.GeminiFinishReason(result.StopReason),
+		&converter.GeminiUsageMetadata{
+			PromptTokenCount:     promptTokens,
+			CandidatesTokenCount: completionTokens,
+			TotalTokenCount:      totalTokens,
+		},
+	)
+
+	c.JSON(http.StatusOK, response)
+}
+
+// isGeminiRoute reports whether the request targets a Gemini-compatible endpoint
+func isGeminiRoute(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, "/v1beta/")
+}
+
+// geminiError writes an error in the Google API error format
+func geminiError(c *gin.Context, code int, message string) {
+	c.JSON(code, gin.H{
+		"error": gin.H{
+			"code":    code,
+			"message": message,
+			"status":  stream.GeminiErrorStatus(code),
+		},
+	})
+}
@@ -0,0 +1,154 @@
+// Package api provides tests for Gemini-compatible routes.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// geminiErrorBody decodes a Google API error response
+func geminiErrorBody(t *testing.T, w *httptest.ResponseRecorder) map[string]interface{} {
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	return body["error"].(map[string]interface{})
+}
+
+// =============================================================================
+// TestGeminiAuth
+// =============================================================================
+
+func TestGeminiAuth(t *testing.T) {
+	body := `{"contents": [{"parts": [{"text": "Hi"}]}]}`
+
+	t.Run("accepts Google key locations", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		tests := []struct {
+			name  string
+			path  string
+			setup func(req *http.Request)
+		}{
+			{"x-goog-api-key header", "/v1beta/models/gemini-pro:countTokens", func(req *http.Request) {
+				req.Header.Set("x-goog-api-key", "test-key")
+			}},
+			{"key query parameter", "/v1beta/models/gemini-pro:countTokens?key=test-key", func(req *http.Request) {}},
+			{"bearer token", "/v1beta/models/gemini-pro:countTokens", func(req *http.Request) {
+				req.Header.Set("Authorization", "Bearer test-key")
+			}},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", tt.path, strings.NewReader(body))
+				tt.setup(req)
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusOK, w.Code)
+			})
+		}
+	})
+
+	t.Run("returns Google errors", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		tests := []struct {
+			name    string
+			path    string
+			message string
+		}{
+			{"missing key", "/v1beta/models/gemini-pro:generateContent", "API key is required"},
+			{"invalid key", "/v1beta/models/gemini-pro:generateContent?key=wrong", "API key not valid"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", tt.path, strings.NewReader(body))
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+				errBody := geminiErrorBody(t, w)
+				assert.Equal(t, float64(http.StatusUnauthorized), errBody["code"])
+				assert.Equal(t, "UNAUTHENTICATED", errBody["status"])
+				assert.Equal(t, tt.message, errBody["message"])
+			})
+		}
+	})
+
+	t.Run("ignores the key query parameter on other routes", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/models?key=test-key", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// =============================================================================
+// TestGeminiHandler
+// =============================================================================
+
+func TestGeminiHandler(t *testing.T) {
+	post := func(router http.Handler, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("x-goog-api-key", "test-key")
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("countTokens returns totalTokens", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := post(router, "/v1beta/models/gemini-pro:countTokens", `{
+			"systemInstruction": {"parts": [{"text": "Be brief."}]},
+			"contents": [{"role": "user", "parts": [{"text": "Hello, world!"}]}]
+		}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Greater(t, resp["totalTokens"].(float64), float64(0))
+	})
+
+	t.Run("rejects unknown methods", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		for _, path := range []string{"/v1beta/models/gemini-pro:embedContent", "/v1beta/models/gemini-pro"} {
+			w := post(router, path, `{}`)
+
+			assert.Equal(t, http.StatusNotFound, w.Code, path)
+			assert.Equal(t, "NOT_FOUND", geminiErrorBody(t, w)["status"])
+		}
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		tests := []struct {
+			name string
+			body string
+		}{
+			{"malformed JSON", `{"contents": `},
+			{"empty contents", `{"contents": []}`},
+			{"unknown role", `{"contents": [{"role": "system", "parts": [{"text": "Hi"}]}]}`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := post(router, "/v1beta/models/gemini-pro:generateContent", tt.body)
+
+				assert.Equal(t, http.StatusBadRequest, w.Code)
+				errBody := geminiErrorBody(t, w)
+				assert.Equal(t, "INVALID_ARGUMENT", errBody["status"])
+				assert.Contains(t, errBody["message"], "Invalid request")
+			})
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler returns every gateway route as a standard http.Handler, with panic
+// recovery, CORS and TRUSTED_PROXIES applied, so the proxy can run on any
+// net/http server or be mounted under an existing mux:
+//
+//	mux.Handle("/kiro/", http.StripPrefix("/kiro", server.Handler()))
+//
+// Each call builds a new handler. Background work stays with the caller, as in
+// main: ModelRefresher.Refresh and Start, CredentialPool.StartRefreshers,
+// CredentialPool.StartWatchers and MarkStarted once serving, then the matching Stop calls on shutdown.
+func (s *Server) Handler() http.Handler {
+	router := gin.New()
+	// Client IPs feed access logs and the auth lockout; only configured proxies may set them
+	if err := router.SetTrustedProxies(s.Cfg.TrustedProxies); err != nil {
+		log.Errorf("Invalid TRUSTED_PROXIES, using connection addresses: %v", err)
+		router.SetTrustedProxies(nil)
+	}
+	router.Use(gin.Recovery())
+	router.Use(corsMiddleware())
+
+	s.SetupRoutes(router)
+	return router
+}
+
+// corsMiddleware allows browser clients from any origin and answers preflight requests
+func corsMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Access-Control-Allow-Origin", "*")
+		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-Requested-With, Accept, X-Request-Id, X-Api-Key, Anthropic-Version, X-Goog-Api-Key")
+		c.Header("Access-Control-Expose-Headers", "X-Request-Id")
+		c.Header("Access-Control-Allow-Credentials", "true")
+
+		if c.Request.Method == "OPTIONS" {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+
+		c.Next()
+	}
+}
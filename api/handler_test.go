@@ -0,0 +1,78 @@
+// Package api provides tests for the net/http handler export.
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/auth"
+	"kiro-go-proxy/config"
+)
+
+// =============================================================================
+// TestHandler
+// =============================================================================
+
+func TestHandler(t *testing.T) {
+	newHandler := func(cfg *config.Config) http.Handler {
+		cfg.ProxyAPIKey = "test-key"
+		return NewServer(cfg, &auth.Manager{}).Handler()
+	}
+
+	t.Run("mounts under an existing mux", func(t *testing.T) {
+		mux := http.NewServeMux()
+		mux.Handle("/kiro/", http.StripPrefix("/kiro", newHandler(&config.Config{})))
+		mux.HandleFunc("/other", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusTeapot)
+		})
+
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/kiro/health", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotEmpty(t, w.Header().Get("X-Request-Id"))
+
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/kiro/v1/models", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		mux.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"object":"list"`)
+
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", "/other", nil))
+		assert.Equal(t, http.StatusTeapot, w.Code)
+	})
+
+	t.Run("answers CORS preflight", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		newHandler(&config.Config{}).ServeHTTP(w, httptest.NewRequest("OPTIONS", "/v1/chat/completions", nil))
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	})
+
+	t.Run("only trusted proxies set the client IP", func(t *testing.T) {
+		for _, tt := range []struct {
+			proxies []string
+			want    string
+		}{
+			{nil, "192.0.2.1"},
+			{[]string{"192.0.2.0/24"}, "203.0.113.7"},
+		} {
+			cfg := &config.Config{TrustedProxies: tt.proxies, AuthMaxFailures: 1, AuthFailureWindow: 60, AuthLockoutDuration: 60}
+			server := NewServer(cfg, &auth.Manager{})
+			handler := server.Handler()
+
+			req := httptest.NewRequest("GET", "/v1/models", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", "203.0.113.7")
+			req.Header.Set("Authorization", "Bearer wrong-key")
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			locked, _ := server.AuthLockout.Locked(tt.want)
+			assert.True(t, locked, tt.want)
+		}
+	})
+}
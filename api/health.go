@@ -0,0 +1,208 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Readiness probe timing: probes run every few seconds, so the token checks and
+// the ListAvailableModels call are reused for readinessProbeTTL
+const (
+	readinessProbeTTL    = 30 * time.Second
+	upstreamProbeTimeout = 5 * time.Second
+)
+
+// readinessProbe caches the result of the last account and Kiro reachability check
+type readinessProbe struct {
+	checkedAt time.Time
+	accounts  []accountHealth
+	latency   time.Duration
+	err       error
+
+	mu sync.Mutex
+}
+
+// accountHealth is the token state of one pool account
+type accountHealth struct {
+	Name             string     `json:"name"`
+	AuthType         string     `json:"auth_type"`
+	Region           string     `json:"region"`
+	Healthy          bool       `json:"healthy"`
+	TokenValid       bool       `json:"token_valid"`
+	TokenExpiresAt   *time.Time `json:"token_expires_at,omitempty"`
+	LastRefresh      *time.Time `json:"last_refresh,omitempty"`
+	LastRefreshError string     `json:"last_refresh_error,omitempty"`
+	Error            string     `json:"error,omitempty"`
+}
+
+// MarkStarted records that bootstrap (config, credentials, model load, listener) has
+// completed, which /startupz and /readyz wait for
+func (s *Server) MarkStarted() {
+	s.started.Store(true)
+}
+
+// LivezHandler handles GET /livez, the Kubernetes liveness probe. It answers 200 as
+// long as the process can serve HTTP and never checks dependencies, so a Kiro outage
+// does not get the pod restarted.
+func (s *Server) LivezHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// StartupzHandler handles GET /startupz, the Kubernetes startup probe: 503 until
+// MarkStarted is called
+func (s *Server) StartupzHandler(c *gin.Context) {
+	if !s.started.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "starting"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// ReadyzHandler handles GET /readyz, the Kubernetes readiness probe. It answers 503
+// until startup has completed, an account has obtained an access token and the model
+// list has been loaded from Kiro. Once all three have happened it stays ready;
+// /health/ready reports later failures.
+func (s *Server) ReadyzHandler(c *gin.Context) {
+	if s.ready.Load() {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+
+	started := s.started.Load()
+	modelsLoaded := !s.ModelCache.LastUpdateTime().IsZero()
+	tokenFetched := false
+	for _, manager := range s.CredentialPool.Managers() {
+		if _, err := manager.GetAccessToken(); err == nil {
+			tokenFetched = true
+			break
+		}
+	}
+
+	if started && tokenFetched && modelsLoaded {
+		s.ready.Store(true)
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+		return
+	}
+	c.JSON(http.StatusServiceUnavailable, gin.H{
+		"status": "not_ready",
+		"checks": gin.H{"startup": started, "token": tokenFetched, "models": modelsLoaded},
+	})
+}
+
+// ReadinessHandler handles GET /health/ready and GET /health?deep=1. It reports
+// token state per account, model cache age and Kiro reachability, answering 503
+// unless at least one account has a valid token and Kiro is reachable. Callers
+// without a valid API key only get the aggregate status: account names and
+// upstream errors are not for the open internet.
+func (s *Server) ReadinessHandler(c *gin.Context) {
+	checkedAt, accounts, latency, err := s.probeReadiness(c.Request.Context())
+	usable := 0
+	for _, account := range accounts {
+		if account.TokenValid {
+			usable++
+		}
+	}
+
+	status, code := "ok", http.StatusOK
+	if usable == 0 || err != nil {
+		status, code = "unavailable", http.StatusServiceUnavailable
+	}
+
+	if c.GetString(apiKeyContextKey) == "" {
+		c.JSON(code, gin.H{
+			"status":    status,
+			"timestamp": time.Now().UTC().Format(time.RFC3339),
+			"accounts":  gin.H{"total": len(accounts), "usable": usable},
+			"upstream":  gin.H{"reachable": err == nil},
+		})
+		return
+	}
+
+	upstream := gin.H{}
+	upstream["reachable"] = err == nil
+	upstream["checked_at"] = checkedAt.UTC().Format(time.RFC3339)
+	upstream["latency_ms"] = latency.Milliseconds()
+	if err != nil {
+		upstream["error"] = err.Error()
+	}
+	c.JSON(code, gin.H{
+		"status":          status,
+		"timestamp":       time.Now().UTC().Format(time.RFC3339),
+		"version":         config.AppVersion,
+		"accounts":        accounts,
+		"models":          s.modelCacheStatus(),
+		"upstream":        upstream,
+		"circuit_breaker": s.Breaker.Status(),
+	})
+}
+
+// modelCacheStatus returns the size, staleness and age of the model cache
+func (s *Server) modelCacheStatus() gin.H {
+	models := gin.H{
+		"count": s.ModelCache.Size(),
+		"stale": s.ModelCache.IsStale(),
+	}
+	if lastUpdate := s.ModelCache.LastUpdateTime(); !lastUpdate.IsZero() {
+		models["last_update"] = lastUpdate.UTC().Format(time.RFC3339)
+		models["age_seconds"] = int(time.Since(lastUpdate).Seconds())
+	}
+	return models
+}
+
+// accountHealth returns the token state of every account, refreshing tokens that
+// are missing or about to expire like a request would
+func (s *Server) accountHealth() []accountHealth {
+	statuses := s.CredentialPool.Status()
+	accounts := make([]accountHealth, 0, len(statuses))
+	for i, manager := range s.CredentialPool.Managers() {
+		account := accountHealth{
+			Name:     statuses[i].Name,
+			AuthType: statuses[i].AuthType,
+			Region:   statuses[i].Region,
+			Healthy:  statuses[i].Healthy,
+		}
+
+		if _, err := manager.GetAccessToken(); err != nil {
+			account.Error = err.Error()
+		}
+		if expiresAt := manager.ExpiresAt(); !expiresAt.IsZero() {
+			account.TokenExpiresAt = &expiresAt
+		}
+		// A failed refresh can leave the previous token in place until it expires
+		account.TokenValid = manager.AccessToken() != "" && !manager.IsTokenExpired()
+
+		if at, refreshErr := manager.LastRefresh(); !at.IsZero() {
+			account.LastRefresh = &at
+			account.LastRefreshError = refreshErr
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts
+}
+
+// probeReadiness checks the account tokens and calls ListAvailableModels unless
+// a result younger than readinessProbeTTL is cached
+func (s *Server) probeReadiness(ctx context.Context) (time.Time, []accountHealth, time.Duration, error) {
+	p := &s.readiness
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.checkedAt.IsZero() || time.Since(p.checkedAt) >= readinessProbeTTL {
+		p.accounts = s.accountHealth()
+
+		ctx, cancel := context.WithTimeout(ctx, upstreamProbeTimeout)
+		defer cancel()
+
+		start := time.Now()
+		_, p.err = s.HttpClient.ListModels(ctx)
+		p.latency = time.Since(start)
+		p.checkedAt = time.Now()
+	}
+	return p.checkedAt, p.accounts, p.latency, p.err
+}
@@ -0,0 +1,205 @@
+// Package api provides tests for the readiness endpoint.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/auth"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+)
+
+// newReadyTestServer creates a server whose account has a token valid until 2099
+func newReadyTestServer(t *testing.T) (*Server, *gin.Engine, *clienttest.Fake) {
+	path := filepath.Join(t.TempDir(), "credentials.json")
+	os.WriteFile(path, []byte(`{"refreshToken":"refresh","accessToken":"access","expiresAt":"2099-01-01T00:00:00Z"}`), 0600)
+
+	cfg := &config.Config{ProxyAPIKey: "test-key", KiroCredsFile: path, Region: "us-east-1", TokenRefreshThreshold: 600}
+	server := NewServerWithPool(cfg, auth.NewPool(cfg))
+	fake := clienttest.NewFake()
+	fake.Models = []model.Info{{ModelID: "claude-sonnet-4"}}
+	server.HttpClient = fake
+
+	router := gin.New()
+	server.SetupRoutes(router)
+	return server, router, fake
+}
+
+// getHealth requests path without an API key and decodes the JSON body
+func getHealth(router *gin.Engine, path string) (int, map[string]interface{}) {
+	return getHealthWithKey(router, path, "")
+}
+
+// getHealthWithKey requests path with apiKey, if set, and decodes the JSON body
+func getHealthWithKey(router *gin.Engine, path, apiKey string) (int, map[string]interface{}) {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", path, nil)
+	if apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	router.ServeHTTP(w, req)
+
+	var body map[string]interface{}
+	json.Unmarshal(w.Body.Bytes(), &body)
+	return w.Code, body
+}
+
+// =============================================================================
+// TestReadinessHandler
+// =============================================================================
+
+func TestReadinessHandler(t *testing.T) {
+	t.Run("ready with valid token and reachable Kiro", func(t *testing.T) {
+		_, router, _ := newReadyTestServer(t)
+
+		for _, path := range []string{"/health/ready", "/health?deep=1"} {
+			code, body := getHealthWithKey(router, path, "test-key")
+			assert.Equal(t, http.StatusOK, code, path)
+			assert.Equal(t, "ok", body["status"], path)
+
+			account := body["accounts"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, true, account["token_valid"])
+			assert.Contains(t, account["token_expires_at"], "2099-01-01")
+			assert.Equal(t, true, body["upstream"].(map[string]interface{})["reachable"])
+			assert.Contains(t, body["models"], "count")
+		}
+	})
+
+	t.Run("basic health skips checks", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		code, body := getHealth(router, "/health")
+		assert.Equal(t, http.StatusOK, code)
+		assert.NotContains(t, body, "accounts")
+	})
+
+	t.Run("unavailable without usable token", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake()
+
+		code, body := getHealthWithKey(router, "/health/ready", "test-key")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "unavailable", body["status"])
+
+		account := body["accounts"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, false, account["token_valid"])
+		assert.Contains(t, account["error"], "refresh token is not set")
+		assert.Contains(t, account["last_refresh_error"], "refresh token is not set")
+		assert.NotEmpty(t, account["last_refresh"])
+	})
+
+	t.Run("unavailable when Kiro is unreachable", func(t *testing.T) {
+		_, router, fake := newReadyTestServer(t)
+		fake.ModelsErr = errors.New("connection refused")
+
+		code, body := getHealthWithKey(router, "/health/ready", "test-key")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		upstream := body["upstream"].(map[string]interface{})
+		assert.Equal(t, false, upstream["reachable"])
+		assert.Equal(t, "connection refused", upstream["error"])
+	})
+
+	t.Run("only reports the aggregate status without an API key", func(t *testing.T) {
+		_, router, fake := newReadyTestServer(t)
+		fake.ModelsErr = errors.New("connection refused to 10.0.0.1")
+
+		code, body := getHealth(router, "/health/ready")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, map[string]interface{}{"total": float64(1), "usable": float64(1)}, body["accounts"])
+		assert.Equal(t, map[string]interface{}{"reachable": false}, body["upstream"])
+		assert.NotContains(t, body, "models")
+	})
+
+	t.Run("reuses recent token and upstream checks", func(t *testing.T) {
+		server, router, fake := newReadyTestServer(t)
+
+		code, _ := getHealth(router, "/health/ready")
+		assert.Equal(t, http.StatusOK, code)
+
+		fake.ModelsErr = errors.New("connection refused")
+		code, _ = getHealth(router, "/health/ready")
+		assert.Equal(t, http.StatusOK, code)
+
+		// Tokens are not checked again either
+		checkedAt, accounts, _, _ := server.probeReadiness(context.Background())
+		again, cached, _, _ := server.probeReadiness(context.Background())
+		assert.Equal(t, checkedAt, again)
+		assert.Same(t, &accounts[0], &cached[0])
+	})
+}
+
+// =============================================================================
+// TestKubernetesProbes
+// =============================================================================
+
+func TestKubernetesProbes(t *testing.T) {
+	t.Run("livez always passes", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake()
+
+		code, _ := getHealth(router, "/livez")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("startupz waits for MarkStarted", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+
+		code, body := getHealth(router, "/startupz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "starting", body["status"])
+
+		server.MarkStarted()
+		code, _ = getHealth(router, "/startupz")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("readyz waits for startup, token and models", func(t *testing.T) {
+		server, router, _ := newReadyTestServer(t)
+
+		code, body := getHealth(router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, map[string]interface{}{"startup": false, "token": true, "models": false}, body["checks"])
+
+		server.MarkStarted()
+		_, _, _, err := server.ModelRefresher.Refresh()
+		assert.NoError(t, err)
+
+		code, _ = getHealth(router, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("readyz fails without token", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = &clienttest.Fake{Models: []model.Info{{ModelID: "claude-sonnet-4"}}}
+		server.MarkStarted()
+		server.ModelRefresher.Refresh()
+
+		code, body := getHealth(router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, false, body["checks"].(map[string]interface{})["token"])
+	})
+
+	t.Run("readyz stays ready once passed", func(t *testing.T) {
+		server, router, _ := newReadyTestServer(t)
+		server.MarkStarted()
+		server.ModelRefresher.Refresh()
+
+		code, _ := getHealth(router, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+
+		// Later token failures are reported by /health/ready only
+		server.CredentialPool = auth.NewPoolFromManagers(&auth.Manager{})
+		code, _ = getHealth(router, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+	})
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/idempotency"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// idempotencyKeyHeader carries the client's key for a request it may retry
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// idempotentReplayedHeader marks a response replayed for a repeated key
+const idempotentReplayedHeader = "Idempotent-Replayed"
+
+// IdempotencyMiddleware replays the stored response when a non-streaming request
+// is sent again with the same Idempotency-Key, scoped to the API key. Reusing a
+// key for a different request is a 422. Streaming requests are not covered, and
+// responses a retry may change (408, 429 and server errors) are not stored.
+func (s *Server) IdempotencyMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		if s.Idempotency == nil || key == "" {
+			c.Next()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		if err != nil || streamRequested(c, body) {
+			c.Next()
+			return
+		}
+
+		scoped := usage.KeyID(c.GetString(apiKeyContextKey)) + ":" + key
+		fingerprint := idempotency.Fingerprint(c.Request.Method, c.Request.URL.Path, body)
+		stored, err := s.Idempotency.Begin(c.Request.Context(), scoped, fingerprint)
+		if errors.Is(err, idempotency.ErrKeyReused) {
+			routeError(c, http.StatusUnprocessableEntity, "invalid_request_error", "Idempotency-Key was already used for a different request")
+			c.Abort()
+			return
+		}
+		if err != nil {
+			// The client went away while a request with its key was running
+			c.Abort()
+			return
+		}
+		if stored != nil {
+			log.Debugf("Replaying the response for Idempotency-Key on %s", c.Request.URL.Path)
+			c.Header(idempotentReplayedHeader, "true")
+			c.Data(stored.Status, stored.ContentType, stored.Body)
+			c.Abort()
+			return
+		}
+
+		completed := false
+		defer func() {
+			if !completed {
+				s.Idempotency.Release(scoped)
+			}
+		}()
+		writer := &copyWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+
+		c.Next()
+
+		status := writer.Status()
+		contentType := writer.Header().Get("Content-Type")
+		if retryableStatus(status) || strings.HasPrefix(contentType, "text/event-stream") {
+			return
+		}
+		s.Idempotency.Complete(scoped, &idempotency.Response{
+			Status:      status,
+			ContentType: contentType,
+			Body:        writer.body.Bytes(),
+		})
+		completed = true
+	}
+}
+
+// streamRequested reports whether a request asks for a stream: Gemini streams
+// with its own method, Ollama unless "stream" is false, and the other routes
+// when "stream" is true
+func streamRequested(c *gin.Context, body []byte) bool {
+	if isGeminiRoute(c) {
+		return strings.HasSuffix(c.Request.URL.Path, ":streamGenerateContent")
+	}
+
+	var req struct {
+		Stream *bool `json:"stream"`
+	}
+	if json.Unmarshal(body, &req) != nil {
+		return false
+	}
+	if isOllamaRoute(c) {
+		return converter.OllamaStreaming(req.Stream)
+	}
+	return req.Stream != nil && *req.Stream
+}
+
+// retryableStatus reports whether a retry of a request that got status may
+// succeed
+func retryableStatus(status int) bool {
+	return status >= http.StatusInternalServerError || status == http.StatusTooManyRequests || status == http.StatusRequestTimeout
+}
+
+// copyWriter keeps a copy of the client response for idempotent replays
+type copyWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *copyWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *copyWriter) WriteString(data string) (int, error) {
+	w.body.WriteString(data)
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *copyWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,154 @@
+// Package api provides tests for replaying requests with an Idempotency-Key.
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/idempotency"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// newIdempotencyTestServer returns a test server with idempotency keys enabled
+// and the given Kiro responses queued
+func newIdempotencyTestServer(responses ...clienttest.Response) (*clienttest.Fake, *gin.Engine) {
+	server, router := newTestServer("test-key")
+	server.Idempotency = idempotency.NewStore(&config.Config{IdempotencyTTL: 60, IdempotencyMaxEntries: 10})
+	fake := clienttest.NewFake(responses...)
+	server.HttpClient = fake
+	return fake, router
+}
+
+// postIdempotent sends an authenticated JSON request with an Idempotency-Key
+func postIdempotent(router http.Handler, path, key, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("x-api-key", "test-key")
+	req.Header.Set(idempotencyKeyHeader, key)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// =============================================================================
+// TestIdempotencyMiddleware
+// =============================================================================
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	chat := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`
+
+	t.Run("replays the response for a repeated key", func(t *testing.T) {
+		fake, router := newIdempotencyTestServer(
+			clienttest.Stream(`{"content":"Hello!"}`),
+			clienttest.Stream(`{"content":"Hello again!"}`),
+		)
+
+		first := postIdempotent(router, "/v1/chat/completions", "key-1", chat)
+		second := postIdempotent(router, "/v1/chat/completions", "key-1", chat)
+
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Empty(t, first.Header().Get(idempotentReplayedHeader))
+		assert.Equal(t, "true", second.Header().Get(idempotentReplayedHeader))
+		assert.Len(t, fake.Requests(), 1)
+	})
+
+	t.Run("different keys run again", func(t *testing.T) {
+		fake, router := newIdempotencyTestServer(
+			clienttest.Stream(`{"content":"Hello!"}`),
+			clienttest.Stream(`{"content":"Hello again!"}`),
+		)
+
+		postIdempotent(router, "/v1/messages", "key-1", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`)
+		w := postIdempotent(router, "/v1/messages", "key-2", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Contains(t, w.Body.String(), "Hello again!")
+		assert.Len(t, fake.Requests(), 2)
+	})
+
+	t.Run("rejects a key reused for another request", func(t *testing.T) {
+		_, router := newIdempotencyTestServer(clienttest.Stream(`{"content":"Hello!"}`))
+
+		postIdempotent(router, "/v1/chat/completions", "key-1", chat)
+		w := postIdempotent(router, "/v1/chat/completions", "key-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Bye"}]}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "Idempotency-Key")
+	})
+
+	t.Run("server errors are not replayed", func(t *testing.T) {
+		fake, router := newIdempotencyTestServer(
+			clienttest.Response{StatusCode: http.StatusInternalServerError, Body: `{"message":"internal error"}`},
+			clienttest.Stream(`{"content":"Hello!"}`),
+		)
+
+		first := postIdempotent(router, "/v1/chat/completions", "key-1", chat)
+		second := postIdempotent(router, "/v1/chat/completions", "key-1", chat)
+
+		assert.NotEqual(t, http.StatusOK, first.Code)
+		assert.Equal(t, http.StatusOK, second.Code)
+		assert.Contains(t, second.Body.String(), "Hello!")
+		assert.Len(t, fake.Requests(), 2)
+	})
+
+	t.Run("streaming requests are not covered", func(t *testing.T) {
+		fake, router := newIdempotencyTestServer(
+			clienttest.Stream(`{"content":"Hello!"}`),
+			clienttest.Stream(`{"content":"Hello!"}`),
+		)
+		stream := `{"model":"claude-sonnet-4","stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+
+		postIdempotent(router, "/v1/chat/completions", "key-1", stream)
+		w := postIdempotent(router, "/v1/chat/completions", "key-1", stream)
+
+		assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+		assert.Len(t, fake.Requests(), 2)
+	})
+
+	t.Run("covers Gemini generateContent but not streamGenerateContent", func(t *testing.T) {
+		fake, router := newIdempotencyTestServer(
+			clienttest.Stream(`{"content":"Hello!"}`),
+			clienttest.Stream(`{"content":"Hello!"}`),
+			clienttest.Stream(`{"content":"Hello!"}`),
+		)
+		gemini := `{"contents":[{"role":"user","parts":[{"text":"Hi"}]}]}`
+
+		postIdempotent(router, "/v1beta/models/claude-sonnet-4:generateContent", "key-1", gemini)
+		w := postIdempotent(router, "/v1beta/models/claude-sonnet-4:generateContent", "key-1", gemini)
+		assert.Equal(t, "true", w.Header().Get(idempotentReplayedHeader))
+		assert.Len(t, fake.Requests(), 1)
+
+		postIdempotent(router, "/v1beta/models/claude-sonnet-4:streamGenerateContent", "key-2", gemini)
+		w = postIdempotent(router, "/v1beta/models/claude-sonnet-4:streamGenerateContent", "key-2", gemini)
+		assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+		assert.Len(t, fake.Requests(), 3)
+	})
+
+	t.Run("covers Ollama only with stream false", func(t *testing.T) {
+		fake, router := newIdempotencyTestServer(
+			clienttest.Stream(`{"content":"Hello!"}`),
+			clienttest.Stream(`{"content":"Hello!"}`),
+			clienttest.Stream(`{"content":"Hello!"}`),
+		)
+		ollama := `{"model":"claude-sonnet-4","stream":false,"messages":[{"role":"user","content":"Hi"}]}`
+
+		postIdempotent(router, "/api/chat", "key-1", ollama)
+		w := postIdempotent(router, "/api/chat", "key-1", ollama)
+		assert.Equal(t, "true", w.Header().Get(idempotentReplayedHeader))
+		assert.Len(t, fake.Requests(), 1)
+
+		// Ollama streams when "stream" is omitted
+		streamed := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`
+		postIdempotent(router, "/api/chat", "key-2", streamed)
+		w = postIdempotent(router, "/api/chat", "key-2", streamed)
+		assert.Empty(t, w.Header().Get(idempotentReplayedHeader))
+		assert.Len(t, fake.Requests(), 3)
+	})
+}
@@ -0,0 +1,74 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// BodyLimitMiddleware rejects request bodies larger than MAX_REQUEST_BODY_BYTES with
+// a 413 before any handler parses them. Accepted bodies are buffered, which every
+// handler does anyway when binding JSON.
+func (s *Server) BodyLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := int64(s.currentConfig().MaxRequestBodyBytes)
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			bodyTooLarge(c, limit)
+			return
+		}
+
+		data, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, limit))
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			bodyTooLarge(c, limit)
+			return
+		}
+		if err != nil {
+			routeError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Failed to read request body: %v", err))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+
+		c.Next()
+	}
+}
+
+// bodyTooLarge writes a 413 for a body over limit and aborts the request
+func bodyTooLarge(c *gin.Context, limit int64) {
+	errType := "invalid_request_error"
+	if isAnthropicRoute(c) {
+		errType = "request_too_large"
+	}
+	routeError(c, http.StatusRequestEntityTooLarge, errType, fmt.Sprintf("Request body exceeds the limit of %d bytes", limit))
+	c.Abort()
+}
+
+// routeError writes an error in the format of the route's API family. errType is
+// used by the OpenAI and Anthropic formats.
+func routeError(c *gin.Context, status int, errType, message string) {
+	switch {
+	case isAnthropicRoute(c):
+		anthropicError(c, status, errType, message)
+	case isGeminiRoute(c):
+		geminiError(c, status, message)
+	case isOllamaRoute(c):
+		ollamaError(c, status, message)
+	default:
+		c.JSON(status, gin.H{
+			"error": gin.H{
+				"message": message,
+				"type":    errType,
+			},
+		})
+	}
+}
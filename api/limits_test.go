@@ -0,0 +1,149 @@
+// Package api provides tests for request size limits.
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestBodyLimitMiddleware
+// =============================================================================
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	body := `{"model":"claude-sonnet-4.5","max_tokens":10,"messages":[{"role":"user","content":"` + strings.Repeat("x", 200) + `"}]}`
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		contains string
+	}{
+		{"openai", "/v1/chat/completions", "Authorization", `"type":"invalid_request_error"`},
+		{"anthropic", "/v1/messages", "x-api-key", `"type":"request_too_large"`},
+		{"gemini", "/v1beta/models/gemini-pro:generateContent", "x-goog-api-key", `"status":"INVALID_ARGUMENT"`},
+		{"ollama", "/api/chat", "Authorization", `"error":"Request body exceeds`},
+	}
+	for _, tt := range tests {
+		t.Run("rejects oversized "+tt.name+" body", func(t *testing.T) {
+			server, router := newTestServer("test-key")
+			server.Cfg.MaxRequestBodyBytes = 100
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, strings.NewReader(body))
+			if tt.header == "Authorization" {
+				req.Header.Set("Authorization", "Bearer test-key")
+			} else {
+				req.Header.Set(tt.header, "test-key")
+			}
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+			assert.Contains(t, w.Body.String(), tt.contains)
+		})
+	}
+
+	t.Run("rejects body without Content-Length", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.MaxRequestBodyBytes = 100
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.ContentLength = -1
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
+
+// =============================================================================
+// TestRequestLimits
+// =============================================================================
+
+func TestRequestLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		body     string
+		contains string
+	}{
+		{"openai", "/v1/chat/completions", "Authorization",
+			`{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello"},{"role":"user","content":"Bye"}]}`,
+			`"type":"invalid_request_error"`},
+		{"anthropic", "/v1/messages", "x-api-key",
+			`{"model":"claude-sonnet-4.5","max_tokens":10,"messages":[{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello"},{"role":"user","content":"Bye"}]}`,
+			`"type":"invalid_request_error"`},
+		{"gemini", "/v1beta/models/gemini-pro:generateContent", "x-goog-api-key",
+			`{"contents":[{"role":"user","parts":[{"text":"Hi"}]},{"role":"model","parts":[{"text":"Hello"}]},{"role":"user","parts":[{"text":"Bye"}]}]}`,
+			`"status":"INVALID_ARGUMENT"`},
+		{"ollama", "/api/chat", "Authorization",
+			`{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello"},{"role":"user","content":"Bye"}]}`,
+			`"error":"Request has 3 messages`},
+	}
+	for _, tt := range tests {
+		t.Run("rejects too many "+tt.name+" messages", func(t *testing.T) {
+			server, router := newTestServer("test-key")
+			server.Cfg.MaxMessages = 2
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			if tt.header == "Authorization" {
+				req.Header.Set("Authorization", "Bearer test-key")
+			} else {
+				req.Header.Set(tt.header, "test-key")
+			}
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.contains)
+			assert.Contains(t, w.Body.String(), "the limit is 2")
+		})
+	}
+}
+
+// =============================================================================
+// TestContextLengthExceeded
+// =============================================================================
+
+func TestContextLengthExceeded(t *testing.T) {
+	body := `{"model":"claude-sonnet-4.5","max_tokens":10,"messages":[{"role":"user","content":"` + strings.Repeat("word ", 200) + `"}]}`
+
+	tests := []struct {
+		name     string
+		path     string
+		header   string
+		contains string
+	}{
+		{"openai", "/v1/chat/completions", "Authorization", `"code":"context_length_exceeded"`},
+		{"anthropic", "/v1/messages", "x-api-key", `"type":"invalid_request_error"`},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name+" request over the model limit", func(t *testing.T) {
+			server, router := newTestServer("test-key")
+			server.Cfg.ContextTrimStrategy = "drop"
+			server.ModelCache.SetMaxInputTokens("claude-sonnet-4.5", 50)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, strings.NewReader(body))
+			if tt.header == "Authorization" {
+				req.Header.Set("Authorization", "Bearer test-key")
+			} else {
+				req.Header.Set(tt.header, "test-key")
+			}
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Contains(t, w.Body.String(), tt.contains)
+			assert.Contains(t, w.Body.String(), "prompt is too long")
+		})
+	}
+}
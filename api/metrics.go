@@ -0,0 +1,113 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kiro-go-proxy/auth"
+	"kiro-go-proxy/client"
+	"kiro-go-proxy/latency"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsHandler handles GET /metrics in the Prometheus text exposition format
+func (s *Server) MetricsHandler(c *gin.Context) {
+	var b strings.Builder
+
+	breaker := s.Breaker.Status()
+	writeMetric(&b, "kiro_circuit_breaker_state", "gauge", "Circuit breaker state around the Kiro API (1 for the current state)")
+	for _, state := range []client.BreakerState{client.BreakerClosed, client.BreakerOpen, client.BreakerHalfOpen} {
+		value := 0
+		if breaker.State == state {
+			value = 1
+		}
+		fmt.Fprintf(&b, "kiro_circuit_breaker_state{state=%q} %d\n", state, value)
+	}
+	writeMetric(&b, "kiro_circuit_breaker_window_requests", "gauge", "Kiro requests finished within the circuit breaker window")
+	fmt.Fprintf(&b, "kiro_circuit_breaker_window_requests %d\n", breaker.Requests)
+	writeMetric(&b, "kiro_circuit_breaker_window_failures", "gauge", "Failed or slow Kiro requests within the circuit breaker window")
+	fmt.Fprintf(&b, "kiro_circuit_breaker_window_failures %d\n", breaker.Failures)
+	writeMetric(&b, "kiro_circuit_breaker_opens_total", "counter", "Times the circuit breaker has opened")
+	fmt.Fprintf(&b, "kiro_circuit_breaker_opens_total %d\n", breaker.Opens)
+	writeMetric(&b, "kiro_circuit_breaker_rejected_total", "counter", "Requests rejected while the circuit breaker was open")
+	fmt.Fprintf(&b, "kiro_circuit_breaker_rejected_total %d\n", breaker.Rejected)
+
+	writeRefreshMetrics(&b, s.CredentialPool)
+	writeLatencyMetrics(&b, s.Latency.Snapshot())
+
+	c.Data(http.StatusOK, "text/plain; version=0.0.4; charset=utf-8", []byte(b.String()))
+}
+
+// writeRefreshMetrics writes the token refresh counters of every pool account
+func writeRefreshMetrics(b *strings.Builder, pool *auth.Pool) {
+	statuses := pool.Status()
+	managers := pool.Managers()
+	stats := make([]auth.RefreshStats, len(managers))
+	for i, manager := range managers {
+		stats[i] = manager.RefreshStats()
+	}
+
+	writeMetric(b, "kiro_token_refreshes_total", "counter", "Token refresh requests sent to the auth service")
+	for i, stat := range stats {
+		fmt.Fprintf(b, "kiro_token_refreshes_total{account=%q} %d\n", statuses[i].Name, stat.Refreshes)
+	}
+	writeMetric(b, "kiro_token_refresh_failures_total", "counter", "Token refresh requests that failed")
+	for i, stat := range stats {
+		fmt.Fprintf(b, "kiro_token_refresh_failures_total{account=%q} %d\n", statuses[i].Name, stat.Failures)
+	}
+	writeMetric(b, "kiro_token_refresh_shared_total", "counter", "Callers that waited on another caller's token refresh instead of sending their own")
+	for i, stat := range stats {
+		fmt.Fprintf(b, "kiro_token_refresh_shared_total{account=%q} %d\n", statuses[i].Name, stat.Shared)
+	}
+}
+
+// writeLatencyMetrics writes the latency histograms of every model since start and
+// their percentiles over the last five minutes
+func writeLatencyMetrics(b *strings.Builder, stats []latency.ModelStats) {
+	writeMetric(b, "kiro_request_duration_seconds", "histogram", "Time from receiving a model request to finishing its response")
+	for _, stat := range stats {
+		writeHistogram(b, "kiro_request_duration_seconds", stat.Model, stat.TotalHistogram)
+	}
+	writeMetric(b, "kiro_first_token_seconds", "histogram", "Time from receiving a model request to the first upstream token")
+	for _, stat := range stats {
+		writeHistogram(b, "kiro_first_token_seconds", stat.Model, stat.FirstTokenHistogram)
+	}
+
+	writeMetric(b, "kiro_request_duration_window_seconds", "summary", "Request duration percentiles over the last five minutes")
+	for _, stat := range stats {
+		writePercentiles(b, "kiro_request_duration_window_seconds", stat.Model, stat.Total)
+	}
+	writeMetric(b, "kiro_first_token_window_seconds", "summary", "First token latency percentiles over the last five minutes")
+	for _, stat := range stats {
+		if stat.FirstToken != nil {
+			writePercentiles(b, "kiro_first_token_window_seconds", stat.Model, *stat.FirstToken)
+		}
+	}
+}
+
+func writeHistogram(b *strings.Builder, name, model string, h latency.Histogram) {
+	for i, bound := range latency.Buckets {
+		fmt.Fprintf(b, "%s_bucket{model=%q,le=\"%g\"} %d\n", name, model, bound, h.Counts[i])
+	}
+	fmt.Fprintf(b, "%s_bucket{model=%q,le=\"+Inf\"} %d\n", name, model, h.Count)
+	fmt.Fprintf(b, "%s_sum{model=%q} %g\n", name, model, h.Sum)
+	fmt.Fprintf(b, "%s_count{model=%q} %d\n", name, model, h.Count)
+}
+
+func writePercentiles(b *strings.Builder, name, model string, p latency.Percentiles) {
+	for _, q := range []struct {
+		quantile string
+		ms       int64
+	}{{"0.5", p.P50}, {"0.95", p.P95}, {"0.99", p.P99}} {
+		fmt.Fprintf(b, "%s{model=%q,quantile=%q} %g\n", name, model, q.quantile, float64(q.ms)/1000)
+	}
+	fmt.Fprintf(b, "%s_sum{model=%q} %g\n", name, model, p.Sum)
+	fmt.Fprintf(b, "%s_count{model=%q} %d\n", name, model, p.Count)
+}
+
+// writeMetric writes the HELP and TYPE lines of a metric
+func writeMetric(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
@@ -0,0 +1,139 @@
+// Package api provides tests for the metrics endpoint and circuit breaker errors.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/model"
+)
+
+// =============================================================================
+// TestMetricsHandler
+// =============================================================================
+
+func TestMetricsHandler(t *testing.T) {
+	t.Run("reports circuit breaker state", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Header().Get("Content-Type"), "text/plain")
+		body := w.Body.String()
+		assert.Contains(t, body, "# TYPE kiro_circuit_breaker_state gauge")
+		assert.Contains(t, body, `kiro_circuit_breaker_state{state="closed"} 1`)
+		assert.Contains(t, body, `kiro_circuit_breaker_state{state="open"} 0`)
+		assert.Contains(t, body, "kiro_circuit_breaker_opens_total 0")
+		assert.Contains(t, body, "# TYPE kiro_token_refreshes_total counter")
+		assert.Contains(t, body, "# TYPE kiro_token_refresh_shared_total counter")
+	})
+
+	t.Run("reports latency per model", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-sonnet-4"}})
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+		postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		router.ServeHTTP(w, req)
+
+		body := w.Body.String()
+		assert.Contains(t, body, "# TYPE kiro_request_duration_seconds histogram")
+		assert.Contains(t, body, `kiro_request_duration_seconds_bucket{model="claude-sonnet-4",le="+Inf"} 1`)
+		assert.Contains(t, body, `kiro_request_duration_seconds_count{model="claude-sonnet-4"} 1`)
+		assert.Contains(t, body, `kiro_first_token_seconds_count{model="claude-sonnet-4"} 1`)
+		assert.Contains(t, body, "# TYPE kiro_request_duration_window_seconds summary")
+		assert.Contains(t, body, `kiro_request_duration_window_seconds{model="claude-sonnet-4",quantile="0.99"}`)
+		assert.Contains(t, body, `kiro_request_duration_window_seconds_count{model="claude-sonnet-4"} 1`)
+	})
+
+	t.Run("reports unknown models as other", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-sonnet-4"}})
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+		postJSON(router, "/v1/chat/completions", `{"model":"made-up-model-1","messages":[{"role":"user","content":"Hi"}]}`)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/metrics", nil)
+		router.ServeHTTP(w, req)
+
+		body := w.Body.String()
+		assert.Contains(t, body, `kiro_request_duration_seconds_count{model="other"} 1`)
+		assert.NotContains(t, body, "made-up-model-1")
+	})
+
+	t.Run("health includes circuit breaker state", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/health", nil)
+		router.ServeHTTP(w, req)
+
+		var body struct {
+			CircuitBreaker client.BreakerStatus `json:"circuit_breaker"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, client.BreakerClosed, body.CircuitBreaker.State)
+	})
+}
+
+// =============================================================================
+// TestCircuitOpen
+// =============================================================================
+
+func TestCircuitOpen(t *testing.T) {
+	open := clienttest.Response{Err: &client.CircuitOpenError{RetryAfter: 12 * time.Second}}
+
+	tests := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"openai", "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`},
+		{"anthropic", "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`},
+		{"gemini", "/v1beta/models/claude-sonnet-4:generateContent", `{"contents":[{"role":"user","parts":[{"text":"Hi"}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+" answers 503 with Retry-After", func(t *testing.T) {
+			server, router := newTestServer("test-key")
+			server.HttpClient = clienttest.NewFake(open)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-key")
+			req.Header.Set("x-api-key", "test-key")
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+			assert.Equal(t, "12", w.Header().Get("Retry-After"))
+			assert.Contains(t, w.Body.String(), "circuit breaker open")
+		})
+	}
+
+	t.Run("fallback models are not tried", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.ModelFallbackChains = map[string][]string{"*": {"claude-haiku-4.5"}}
+		fake := clienttest.NewFake(open, clienttest.Stream(`{"content":"Hello"}`))
+		server.HttpClient = fake
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(tests[0].body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Len(t, fake.Requests(), 1)
+	})
+}
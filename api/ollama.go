@@ -0,0 +1,282 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/debug"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/tracing"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// setupOllamaRoutes registers the Ollama-compatible /api routes
+func (s *Server) setupOllamaRoutes(r *gin.Engine) {
+	ollama := r.Group("/api")
+	ollama.Use(s.AuthMiddleware(), s.RateLimitMiddleware(), s.RequestTimeoutMiddleware())
+	{
+		ollama.GET("/tags", s.OllamaTagsHandler)
+		ollama.POST("/chat", s.IdempotencyMiddleware(), s.UsageMiddleware(), s.DebugCaptureMiddleware(), s.AuditMiddleware(), s.OllamaChatHandler)
+		ollama.POST("/generate", s.IdempotencyMiddleware(), s.UsageMiddleware(), s.DebugCaptureMiddleware(), s.AuditMiddleware(), s.OllamaGenerateHandler)
+	}
+}
+
+// ollamaCompletion is a chat or generate request converted to unified format
+type ollamaCompletion struct {
+	model        string
+	messages     []converter.UnifiedMessage
+	systemPrompt string
+	tools        []converter.UnifiedTool
+	options      *converter.OllamaOptions
+	format       *converter.OpenAIResponseFormat
+	stream       bool
+	generate     bool
+}
+
+// OllamaTagsHandler handles GET /api/tags, listing the Kiro models as installed models
+func (s *Server) OllamaTagsHandler(c *gin.Context) {
+	models := s.ModelResolver.GetAvailableModelDetails()
+	c.JSON(http.StatusOK, stream.CreateOllamaTagsResponse(models))
+}
+
+// OllamaChatHandler handles POST /api/chat (Ollama-compatible)
+func (s *Server) OllamaChatHandler(c *gin.Context) {
+	var req converter.OllamaChatRequest
+	if err := s.bindRequest(c, &req, converter.OllamaChatRequestFields); err != nil {
+		ollamaError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	unifiedMessages, systemPrompt := converter.ConvertOllamaToUnified(req.Messages)
+	s.handleOllamaCompletion(c, req.Format, req.Think, &ollamaCompletion{
+		model:        req.Model,
+		messages:     unifiedMessages,
+		systemPrompt: systemPrompt,
+		tools:        converter.ConvertOpenAIToolsToUnified(req.Tools),
+		options:      req.Options,
+		stream:       converter.OllamaStreaming(req.Stream),
+	})
+}
+
+// OllamaGenerateHandler handles POST /api/generate (Ollama-compatible)
+func (s *Server) OllamaGenerateHandler(c *gin.Context) {
+	var req converter.OllamaGenerateRequest
+	if err := s.bindRequest(c, &req, converter.OllamaGenerateRequestFields); err != nil {
+		ollamaError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	unifiedMessages, systemPrompt := converter.ConvertOllamaGenerateToUnified(&req)
+	s.handleOllamaCompletion(c, req.Format, req.Think, &ollamaCompletion{
+		model:        req.Model,
+		messages:     unifiedMessages,
+		systemPrompt: systemPrompt,
+		options:      req.Options,
+		stream:       converter.OllamaStreaming(req.Stream),
+		generate:     true,
+	})
+}
+
+// handleOllamaCompletion runs a converted chat or generate request through the Kiro pipeline
+func (s *Server) handleOllamaCompletion(c *gin.Context, format, think []byte, req *ollamaCompletion) {
+	start := time.Now()
+
+	var err error
+	req.format, err = converter.OllamaResponseFormat(format)
+	if err != nil {
+		ollamaError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	// think overrides the global fake reasoning settings for this request
+	effort, err := converter.OllamaReasoningEffort(think)
+	if err != nil {
+		ollamaError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+	cfg, err := converter.ApplyReasoningEffort(s.currentConfig(), effort)
+	if err != nil {
+		ollamaError(c, http.StatusBadRequest, fmt.Sprintf("Invalid request: %v", err))
+		return
+	}
+
+	if err := converter.CheckRequestLimits(req.messages, req.systemPrompt, req.tools, cfg); err != nil {
+		ollamaError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Resolve model
+	resolution := s.ModelResolver.Resolve(converter.OllamaModelName(req.model))
+	log.Debugf("Model resolution: %s -> %s (source: %s)", req.model, resolution.InternalID, resolution.Source)
+	cfg = converter.ApplyModelProfile(cfg, resolution.InternalID)
+
+	// Instruct the model to answer in JSON when format asks for it
+	systemPrompt := req.systemPrompt
+	if formatAddition := converter.GetResponseFormatSystemPromptAddition(req.format); formatAddition != "" {
+		if systemPrompt != "" {
+			systemPrompt += formatAddition
+		} else {
+			systemPrompt = strings.TrimSpace(formatAddition)
+		}
+	}
+
+	// Estimate prompt tokens for usage reporting
+	promptTokens := converter.EstimateInputTokens(req.messages, systemPrompt, req.tools)
+
+	// The Kiro conversation may continue across requests
+	kiroConversationID := resolveConversationID(c.Request.Context(), cfg, req.messages, systemPrompt)
+	debug.FromContext(c.Request.Context()).SetConversationID(kiroConversationID)
+	accesslog.FromContext(c.Request.Context()).SetConversationID(kiroConversationID)
+	usage.FromContext(c.Request.Context()).SetModel(req.model)
+	accesslog.FromContext(c.Request.Context()).SetResolvedModel(resolution.InternalID)
+
+	// Build Kiro payload
+	_, buildSpan := tracing.StartSpan(c.Request.Context(), "converter.BuildKiroPayload", tracing.SpanKindInternal)
+	payload, err := converter.BuildKiroPayload(
+		req.messages,
+		systemPrompt,
+		resolution.InternalID,
+		req.tools,
+		kiroConversationID,
+		s.AuthManager.ProfileArn(),
+		s.ModelCache.GetMaxInputTokens(resolution.InternalID),
+		cfg,
+	)
+	buildSpan.End()
+
+	var contextErr *converter.ContextLengthError
+	if errors.As(err, &contextErr) {
+		ollamaError(c, http.StatusBadRequest, contextErr.Error())
+		return
+	}
+	if err != nil {
+		ollamaError(c, http.StatusInternalServerError, "Failed to build request payload")
+		return
+	}
+
+	// Forward sampling settings and emulate num_predict/stop on the response
+	inference := converter.OllamaInferenceConfig(req.options)
+	var limits stream.Limits
+	if req.options != nil {
+		limits.StopSequences = req.options.Stop
+	}
+	applyProfileMaxTokens(cfg, resolution.InternalID, inference, &limits)
+	converter.ApplyInferenceConfig(payload, inference, cfg)
+	limits.ToolNames = payload.ToolNames
+
+	// Build URL
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+
+	// A failed write to a streaming client cancels the request
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	resp, err := s.postStream(ctx, cfg, apiURL, payload)
+	if err != nil {
+		status, _ := requestFailedStatus(err)
+		ollamaError(c, status, requestFailedMessage(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	if req.stream {
+		w, ok := newSSEWriter(c, cfg, cancel)
+		if !ok {
+			ollamaError(c, http.StatusInternalServerError, "Streaming not supported")
+			return
+		}
+
+		c.Header("Content-Type", "application/x-ndjson")
+		c.Header("Cache-Control", "no-cache")
+
+		// No keep-alives: blank lines would break JSON-lines readers
+		events := stream.StreamToOllama(ctx, resp, req.model, cfg.FirstTokenTimeout, true, cfg, s.ModelCache, promptTokens, limits, req.generate)
+		writeEvents(ctx, w, events)
+		return
+	}
+
+	result, err := stream.CollectStreamResult(ctx, resp, cfg.FirstTokenTimeout, true, cfg, limits)
+	if err != nil {
+		status, _, message := streamFailedStatus(err)
+		ollamaError(c, status, message)
+		return
+	}
+	result = s.continueTruncated(ctx, cfg, apiURL, payload, limits, result)
+
+	// Clean up JSON output on a best-effort basis
+	content := result.Content
+	if req.format.RequiresJSON() && len(result.ToolCalls) == 0 {
+		if repaired, err := converter.RepairJSONResponse(content, req.format); err == nil {
+			content = repaired
+		} else {
+			log.Warnf("Ollama format output invalid: %v", err)
+		}
+	}
+
+	// Calculate token usage
+	completionTokens := stream.CountCompletionTokens(result.Content, result.ThinkingContent, result.ToolCalls)
+	promptTokens, _, _, _ = stream.CalculateTokensFromContextUsage(
+		result.ContextUsagePercentage,
+		completionTokens,
+		promptTokens,
+		s.ModelCache,
+		req.model,
+	)
+	usage.FromContext(ctx).AddTokens(promptTokens, completionTokens)
+
+	var thinking string
+	if cfg.FakeReasoningHandling == "as_reasoning_content" {
+		thinking = result.ThinkingContent
+	}
+	done := converter.OllamaDone{
+		Done:            true,
+		DoneReason:      stream.OllamaDoneReason(result.StopReason),
+		TotalDuration:   time.Since(start).Nanoseconds(),
+		PromptEvalCount: promptTokens,
+		EvalCount:       completionTokens,
+	}
+	createdAt := time.Now().UTC().Format(time.RFC3339Nano)
+
+	if req.generate {
+		c.JSON(http.StatusOK, &converter.OllamaGenerateResponse{
+			Model:      req.model,
+			CreatedAt:  createdAt,
+			Response:   content,
+			Thinking:   thinking,
+			OllamaDone: done,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, &converter.OllamaChatResponse{
+		Model:     req.model,
+		CreatedAt: createdAt,
+		Message: &converter.OllamaMessage{
+			Role:      "assistant",
+			Content:   content,
+			Thinking:  thinking,
+			ToolCalls: converter.OllamaToolCallsFromUnified(convertParserToolCalls(result.ToolCalls)),
+		},
+		OllamaDone: done,
+	})
+}
+
+// isOllamaRoute reports whether the request targets an Ollama-compatible endpoint
+func isOllamaRoute(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, "/api/")
+}
+
+// ollamaError writes an error in the Ollama format
+func ollamaError(c *gin.Context, code int, message string) {
+	c.JSON(code, gin.H{
+		"error": message,
+	})
+}
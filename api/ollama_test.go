@@ -0,0 +1,105 @@
+// Package api provides tests for Ollama-compatible routes.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/model"
+)
+
+// =============================================================================
+// TestOllamaAuth
+// =============================================================================
+
+func TestOllamaAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		header  string
+		message string
+	}{
+		{"missing key", "", "Missing Authorization header"},
+		{"invalid key", "Bearer wrong-key", "Invalid API key"},
+	}
+	for _, tt := range tests {
+		t.Run("returns Ollama errors for "+tt.name, func(t *testing.T) {
+			_, router := newTestServer("test-key")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/api/tags", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			var body map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &body)
+			assert.Equal(t, tt.message, body["error"])
+		})
+	}
+}
+
+// =============================================================================
+// TestOllamaTagsHandler
+// =============================================================================
+
+func TestOllamaTagsHandler(t *testing.T) {
+	t.Run("lists models in Ollama format", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-haiku-4.5"}})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/api/tags", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		models := resp["models"].([]interface{})
+		assert.Len(t, models, 1)
+		first := models[0].(map[string]interface{})
+		assert.Equal(t, "claude-haiku-4.5", first["name"])
+		assert.Contains(t, first, "details")
+	})
+}
+
+// =============================================================================
+// TestOllamaValidation
+// =============================================================================
+
+func TestOllamaValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+		body string
+		want string
+	}{
+		{"chat malformed JSON", "/api/chat", `{"model": `, "Invalid request"},
+		{"chat without messages", "/api/chat", `{"model": "claude-sonnet-4.5", "messages": []}`, "messages"},
+		{"chat bad format", "/api/chat", `{"model": "claude-sonnet-4.5", "messages": [{"role": "user", "content": "Hi"}], "format": "xml"}`, "format"},
+		{"chat bad think", "/api/chat", `{"model": "claude-sonnet-4.5", "messages": [{"role": "user", "content": "Hi"}], "think": "max"}`, "think"},
+		{"generate without prompt", "/api/generate", `{"model": "claude-sonnet-4.5"}`, "prompt"},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			_, router := newTestServer("test-key")
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", tt.path, strings.NewReader(tt.body))
+			req.Header.Set("Authorization", "Bearer test-key")
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Contains(t, body["error"], tt.want)
+		})
+	}
+}
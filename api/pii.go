@@ -0,0 +1,61 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/piifilter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// filterPII applies the PII filter policy of the request's API key to payload
+// before it is sent to Kiro: matches are redacted in place, only logged, or
+// stop the request with a *piifilter.BlockedError.
+func filterPII(ctx context.Context, cfg *config.Config, payload *converter.KiroPayload) error {
+	policy := cfg.PIIPolicyFor(requestAPIKey(ctx))
+	if policy.Mode == "" || policy.Mode == piifilter.ModeOff {
+		return nil
+	}
+	filter, err := piifilter.New(policy.Patterns, cfg.PIIFilterCustomPatterns)
+	if err != nil {
+		// Patterns are validated with the config, so this is not expected
+		return err
+	}
+
+	found := make(map[string]int)
+	payload.RewriteText(func(text string) string {
+		redacted := filter.Redact(text, found)
+		if policy.Mode == piifilter.ModeRedact {
+			return redacted
+		}
+		return text
+	})
+	if len(found) == 0 {
+		return nil
+	}
+
+	switch policy.Mode {
+	case piifilter.ModeBlock:
+		log.Warnf("PII filter blocked a request containing %s", describeFindings(found))
+		return &piifilter.BlockedError{Patterns: piifilter.Names(found)}
+	case piifilter.ModeRedact:
+		log.Infof("PII filter redacted %s", describeFindings(found))
+	default:
+		log.Warnf("PII filter found %s", describeFindings(found))
+	}
+	return nil
+}
+
+// describeFindings formats the match counts of a filtered request for the log,
+// e.g. "credit_card x1, email x2"
+func describeFindings(found map[string]int) string {
+	var parts []string
+	for _, name := range piifilter.Names(found) {
+		parts = append(parts, fmt.Sprintf("%s x%d", name, found[name]))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,78 @@
+// Package api provides tests for the outbound PII filter.
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestPIIFilter
+// =============================================================================
+
+func TestPIIFilter(t *testing.T) {
+	chat := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Email jane@example.com about it"}]}`
+	patterns := []string{"email", "credit_card"}
+
+	t.Run("redacts content before it is sent", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.PIIFilterMode = "redact"
+		server.Cfg.PIIFilterPatterns = patterns
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Done"}`))
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/chat/completions", chat)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		content := fake.Requests()[0].Payload.(*converter.KiroPayload).ConversationState.CurrentMessage.UserInputMessage.Content
+		assert.Contains(t, content, "Email [REDACTED:email] about it")
+		assert.NotContains(t, content, "jane@example.com")
+	})
+
+	t.Run("blocks requests", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.PIIFilterMode = "block"
+		server.Cfg.PIIFilterPatterns = patterns
+		fake := clienttest.NewFake()
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Card 4111-1111-1111-1111"}]}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "invalid_request_error")
+		assert.Contains(t, w.Body.String(), "sensitive data (credit_card)")
+		assert.Empty(t, fake.Requests())
+	})
+
+	t.Run("log mode sends the request unchanged", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.PIIFilterMode = "log"
+		server.Cfg.PIIFilterPatterns = patterns
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Done"}`))
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/chat/completions", chat)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, fake.Requests()[0].Payload.(*converter.KiroPayload).ConversationState.CurrentMessage.UserInputMessage.Content, "jane@example.com")
+	})
+
+	t.Run("applies the policy of the API key", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.PIIFilterMode = "off"
+		server.Cfg.PIIFilterPatterns = patterns
+		server.Cfg.PIIFilterKeys = map[string]config.PIIPolicy{"test-key": {Mode: "block"}}
+		server.HttpClient = clienttest.NewFake()
+
+		w := postJSON(router, "/v1/chat/completions", chat)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "sensitive data (email)")
+	})
+}
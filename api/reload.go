@@ -0,0 +1,66 @@
+package api
+
+import (
+	"strings"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/redact"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// currentConfig returns the active configuration. Handlers read it once per request,
+// so a reload never changes the settings of a request that is already streaming.
+func (s *Server) currentConfig() *config.Config {
+	s.cfgMu.RLock()
+	defer s.cfgMu.RUnlock()
+	return s.Cfg
+}
+
+// updateConfig replaces the active configuration with a modified copy
+func (s *Server) updateConfig(update func(cfg *config.Config)) {
+	s.cfgMu.Lock()
+	defer s.cfgMu.Unlock()
+	next := *s.Cfg
+	update(&next)
+	s.Cfg = &next
+}
+
+// ReloadConfig re-reads the config file and account credentials and applies the
+// reloadable settings (API keys, log level, model aliases and hidden models, fake
+// reasoning). It returns a description of each changed setting. Requests already in
+// flight keep the configuration they started with.
+func (s *Server) ReloadConfig() ([]string, error) {
+	loaded, err := config.LoadFile(s.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cfgMu.Lock()
+	next, changes := s.Cfg.Reload(loaded)
+	s.Cfg = next
+	s.cfgMu.Unlock()
+
+	s.ModelResolver.Reload(next)
+	log.SetLevel(parseLogLevel(next.LogLevel))
+	redact.SetParanoid(next.ParanoidLogging)
+	accounts := s.CredentialPool.ReloadCredentials()
+
+	if len(changes) == 0 {
+		log.Info("Configuration reloaded: no changes")
+	} else {
+		log.Infof("Configuration reloaded: %s", strings.Join(changes, "; "))
+	}
+	log.Infof("Reloaded credentials for %d account(s)", accounts)
+
+	return changes, nil
+}
+
+// parseLogLevel maps LOG_LEVEL (DEBUG/INFO/WARNING/ERROR) to a logrus level, defaulting to info
+func parseLogLevel(level string) log.Level {
+	parsed, err := log.ParseLevel(level)
+	if err != nil {
+		return log.InfoLevel
+	}
+	return parsed
+}
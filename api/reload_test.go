@@ -0,0 +1,82 @@
+// Package api provides tests for configuration reload.
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// writeReloadConfig writes a config file for ReloadConfig and returns its path
+func writeReloadConfig(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// =============================================================================
+// TestReloadConfig
+// Tests for applying a reloaded config file to a running server
+// =============================================================================
+
+func TestReloadConfig(t *testing.T) {
+	for _, key := range []string{"PROXY_API_KEY", "ADMIN_API_KEY", "LOG_LEVEL", "FAKE_REASONING", "CONFIG_FILE"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+	defer log.SetLevel(log.GetLevel())
+
+	t.Run("applies reloadable settings", func(t *testing.T) {
+		server, router := newTestServer("old-key")
+		server.ConfigFile = writeReloadConfig(t, `
+proxy_api_key: new-key
+log_level: DEBUG
+fake_reasoning: false
+model_aliases:
+  fast: claude-haiku-4.5
+`)
+		inFlight := server.currentConfig()
+
+		changes, err := server.ReloadConfig()
+
+		assert.NoError(t, err)
+		assert.Contains(t, changes, "proxy_api_key: changed")
+		assert.Contains(t, changes, "model_aliases[fast]: added 'claude-haiku-4.5'")
+		assert.Equal(t, log.DebugLevel, log.GetLevel())
+		assert.False(t, server.currentConfig().FakeReasoningEnabled)
+		assert.Equal(t, "claude-haiku-4.5", server.ModelResolver.Resolve("fast").InternalID)
+
+		// Requests that started before the reload keep their settings
+		assert.Equal(t, "old-key", inFlight.ProxyAPIKey)
+
+		for key, status := range map[string]int{"old-key": http.StatusUnauthorized, "new-key": http.StatusOK} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/v1/models", nil)
+			req.Header.Set("Authorization", "Bearer "+key)
+			router.ServeHTTP(w, req)
+			assert.Equal(t, status, w.Code, key)
+		}
+	})
+
+	t.Run("keeps current settings when the file is invalid", func(t *testing.T) {
+		server, _ := newTestServer("old-key")
+		server.ConfigFile = writeReloadConfig(t, "proxy_api_key: new-key\nunknown_setting: 1\n")
+
+		_, err := server.ReloadConfig()
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown_setting")
+		assert.Equal(t, "old-key", server.currentConfig().ProxyAPIKey)
+	})
+
+	t.Run("parses log levels", func(t *testing.T) {
+		assert.Equal(t, log.WarnLevel, parseLogLevel("WARNING"))
+		assert.Equal(t, log.ErrorLevel, parseLogLevel("ERROR"))
+		assert.Equal(t, log.InfoLevel, parseLogLevel("bogus"))
+	})
+}
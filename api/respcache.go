@@ -0,0 +1,60 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"kiro-go-proxy/respcache"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// cacheHeader tells clients whether a response came from the response cache
+const cacheHeader = "x-kiro-cache"
+
+// responseCacheKey returns the cache key for a non-streaming request, or "" when the
+// cache is disabled or the client asked to bypass it with Cache-Control: no-cache.
+// Responses are cached per API key, so one key never gets another key's answers.
+func (s *Server) responseCacheKey(c *gin.Context, req interface{}) string {
+	if s.ResponseCache == nil {
+		return ""
+	}
+	control := strings.ToLower(c.GetHeader("Cache-Control"))
+	if strings.Contains(control, "no-cache") || strings.Contains(control, "no-store") {
+		return ""
+	}
+	return respcache.Key(usage.KeyID(c.GetString(apiKeyContextKey)), c.Request.URL.Path, req)
+}
+
+// serveCachedResponse writes the cached response for key and reports whether there was one
+func (s *Server) serveCachedResponse(c *gin.Context, key string) bool {
+	if key == "" {
+		return false
+	}
+
+	body, ok := s.ResponseCache.Get(key)
+	if !ok {
+		c.Header(cacheHeader, "miss")
+		return false
+	}
+
+	log.Debugf("Response cache hit for %s", c.Request.URL.Path)
+	c.Header(cacheHeader, "hit")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	return true
+}
+
+// writeCachedJSON writes a successful response and stores it under key
+func (s *Server) writeCachedJSON(c *gin.Context, key string, response interface{}) {
+	body, err := json.Marshal(response)
+	if err != nil || key == "" {
+		c.JSON(http.StatusOK, response)
+		return
+	}
+
+	s.ResponseCache.Put(key, body)
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
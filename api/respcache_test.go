@@ -0,0 +1,136 @@
+// Package api provides tests for the response cache.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/respcache"
+	"kiro-go-proxy/usage"
+)
+
+// newCachingTestServer creates a test server with the response cache enabled
+func newCachingTestServer() (*Server, http.Handler) {
+	server, router := newTestServer("test-key")
+	server.ResponseCache = respcache.NewCache(&config.Config{ResponseCacheTTL: 60, ResponseCacheMaxEntries: 10})
+	return server, router
+}
+
+// =============================================================================
+// TestResponseCache
+// =============================================================================
+
+func TestResponseCache(t *testing.T) {
+	t.Run("serves cached chat completion", func(t *testing.T) {
+		server, router := newCachingTestServer()
+		body := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hello"}]}`
+
+		var req converter.OpenAIRequest
+		assert.NoError(t, json.Unmarshal([]byte(body), &req))
+		server.ResponseCache.Put(respcache.Key(usage.KeyID("test-key"), "/v1/chat/completions", &req), []byte(`{"id":"cached"}`))
+
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, httpReq)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "hit", w.Header().Get("x-kiro-cache"))
+		assert.JSONEq(t, `{"id":"cached"}`, w.Body.String())
+	})
+
+	t.Run("serves cached message", func(t *testing.T) {
+		server, router := newCachingTestServer()
+		body := `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hello"}]}`
+
+		var req converter.AnthropicRequest
+		assert.NoError(t, json.Unmarshal([]byte(body), &req))
+		server.ResponseCache.Put(respcache.Key(usage.KeyID("test-key"), "/v1/messages", &req), []byte(`{"id":"cached"}`))
+
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, httpReq)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "hit", w.Header().Get("x-kiro-cache"))
+		assert.JSONEq(t, `{"id":"cached"}`, w.Body.String())
+	})
+
+	t.Run("no key when cache disabled", func(t *testing.T) {
+		server, _ := newTestServer("test-key")
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", "/v1/messages", nil)
+
+		assert.Empty(t, server.responseCacheKey(c, map[string]string{"model": "claude"}))
+	})
+
+	t.Run("no key when client sends Cache-Control no-cache", func(t *testing.T) {
+		server, _ := newCachingTestServer()
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", "/v1/messages", nil)
+
+		assert.NotEmpty(t, server.responseCacheKey(c, map[string]string{"model": "claude"}))
+
+		c.Request.Header.Set("Cache-Control", "no-cache")
+		assert.Empty(t, server.responseCacheKey(c, map[string]string{"model": "claude"}))
+	})
+
+	t.Run("keys responses by API key", func(t *testing.T) {
+		server, _ := newCachingTestServer()
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request, _ = http.NewRequest("POST", "/v1/messages", nil)
+		req := map[string]string{"model": "claude"}
+
+		c.Set(apiKeyContextKey, "test-key")
+		key := server.responseCacheKey(c, req)
+		c.Set(apiKeyContextKey, "other-key")
+
+		assert.NotEqual(t, key, server.responseCacheKey(c, req))
+	})
+
+	t.Run("does not serve another API key's response", func(t *testing.T) {
+		server, router := newCachingTestServer()
+		server.Cfg.ProxyAPIKeys = []string{"other-key"}
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Fresh"}`))
+		body := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hello"}]}`
+
+		var req converter.OpenAIRequest
+		assert.NoError(t, json.Unmarshal([]byte(body), &req))
+		server.ResponseCache.Put(respcache.Key(usage.KeyID("test-key"), "/v1/chat/completions", &req), []byte(`{"id":"cached"}`))
+
+		w := httptest.NewRecorder()
+		httpReq, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		httpReq.Header.Set("Content-Type", "application/json")
+		httpReq.Header.Set("Authorization", "Bearer other-key")
+		router.ServeHTTP(w, httpReq)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "miss", w.Header().Get("x-kiro-cache"))
+		assert.Contains(t, w.Body.String(), "Fresh")
+	})
+
+	t.Run("stores written response", func(t *testing.T) {
+		server, _ := newCachingTestServer()
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+
+		server.writeCachedJSON(c, "key", gin.H{"id": "msg_1"})
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"id":"msg_1"}`, w.Body.String())
+		cached, ok := server.ResponseCache.Get("key")
+		assert.True(t, ok)
+		assert.JSONEq(t, `{"id":"msg_1"}`, string(cached))
+	})
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/convstore"
+	"kiro-go-proxy/parser"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/transcript"
+	"kiro-go-proxy/usage"
+	"kiro-go-proxy/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ResponsesHandler handles POST /v1/responses (OpenAI Responses API). The request
+// is converted to a chat completion and runs through the same pipeline.
+func (s *Server) ResponsesHandler(c *gin.Context) {
+	var req converter.ResponsesRequest
+	if err := s.bindRequest(c, &req, converter.ResponsesRequestFields); err != nil {
+		c.JSON(http.StatusBadRequest, openAIValidationBody(err))
+		return
+	}
+
+	chatReq := converter.ConvertResponsesToOpenAI(&req)
+	prepared, status, errBody := s.prepareChatCompletion(c.Request.Context(), chatReq)
+	if prepared == nil {
+		c.JSON(status, errBody)
+		return
+	}
+
+	// Build URL
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+
+	responseID := utils.GenerateResponsesID("resp")
+	createdAt := time.Now().Unix()
+	if req.Stream {
+		s.handleStreamingResponse(c, prepared, apiURL, &req, responseID, createdAt)
+	} else {
+		s.handleNonStreamingResponse(c, prepared, apiURL, &req, chatReq.ResponseFormat, responseID, createdAt)
+	}
+}
+
+func (s *Server) handleStreamingResponse(c *gin.Context, prepared *chatCompletion, apiURL string, req *converter.ResponsesRequest, responseID string, createdAt int64) {
+	// Make request; a failed write to the client cancels it
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	resp, err := s.postStream(ctx, prepared.cfg, apiURL, prepared.payload)
+	if err != nil {
+		c.JSON(requestFailedBody(err))
+		return
+	}
+	defer resp.Body.Close()
+
+	w, ok := newSSEWriter(c, prepared.cfg, cancel)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": gin.H{
+				"message": "Streaming not supported",
+				"type":    "internal_error",
+			},
+		})
+		return
+	}
+
+	// Set SSE headers
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	announceStreamTrailers(c)
+
+	// The Responses API has no [DONE] marker: response.completed ends the stream
+	cfg := prepared.cfg
+	events := stream.StreamToResponses(ctx, resp, req, responseID, createdAt, cfg.FirstTokenTimeout, true, cfg, s.ModelCache, prepared.promptTokens, prepared.limits)
+	events = stream.WithKeepAlive(events, keepAliveInterval(cfg), stream.OpenAIKeepAlive)
+	writeEvents(ctx, w, events)
+	writeStreamTrailers(c, req.Model)
+}
+
+func (s *Server) handleNonStreamingResponse(c *gin.Context, prepared *chatCompletion, apiURL string, req *converter.ResponsesRequest, responseFormat *converter.OpenAIResponseFormat, responseID string, createdAt int64) {
+	cfg := prepared.cfg
+	result, errStatus, errBody := s.collectFormattedCompletion(c.Request.Context(), cfg, apiURL, prepared.payload, prepared.limits, responseFormat)
+	if result == nil {
+		c.JSON(errStatus, errBody)
+		return
+	}
+
+	// Calculate token usage
+	completionTokens := stream.CountCompletionTokens(result.Content, result.ThinkingContent, result.ToolCalls)
+	promptTokens, totalTokens, _, _ := stream.CalculateTokensFromContextUsage(
+		result.ContextUsagePercentage,
+		completionTokens,
+		prepared.promptTokens,
+		s.ModelCache,
+		req.Model,
+	)
+	usage.FromContext(c.Request.Context()).AddTokens(promptTokens, completionTokens)
+	convstore.FromContext(c.Request.Context()).SetReply(result.Content, result.ToolCalls)
+	thinking := result.ThinkingContent
+	if cfg.FakeReasoningHandling != "as_reasoning_content" {
+		thinking = ""
+	}
+	transcript.FromContext(c.Request.Context()).SetReply(result.Content, thinking, result.ToolCalls)
+
+	status := stream.ResponsesStatus(result.StopReason)
+	output := responsesOutput(cfg, thinking, result.Content, result.ToolCalls)
+	response := converter.NewResponsesResponse(responseID, createdAt, status, req, output, &converter.ResponsesUsage{
+		InputTokens:  promptTokens,
+		OutputTokens: completionTokens,
+		TotalTokens:  totalTokens,
+	})
+	response.KiroCredits = requestCredits(c.Request.Context())
+	response.KiroModel = servedModel(c.Request.Context(), response.Model)
+	c.JSON(http.StatusOK, response)
+}
+
+// responsesOutput builds the output items of a collected response: reasoning
+// (when exposed), the assistant message and one item per function call
+func responsesOutput(cfg *config.Config, thinking, content string, toolCalls []parser.ToolCall) []interface{} {
+	var output []interface{}
+	if thinking != "" && cfg.FakeReasoningHandling == "as_reasoning_content" {
+		output = append(output, converter.NewResponsesReasoningItem(utils.GenerateResponsesID("rs"), thinking))
+	}
+	if content != "" {
+		output = append(output, converter.NewResponsesMessageItem(utils.GenerateResponsesID("msg"), "completed", content))
+	}
+	for _, call := range toolCalls {
+		output = append(output, &converter.ResponsesFunctionCallItem{
+			Type:      "function_call",
+			ID:        utils.GenerateResponsesID("fc"),
+			CallID:    call.ID,
+			Name:      call.Function.Name,
+			Arguments: call.Function.Arguments,
+			Status:    "completed",
+		})
+	}
+	return output
+}
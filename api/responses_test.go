@@ -0,0 +1,135 @@
+// Package api provides tests for the Responses API route.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/converter"
+)
+
+// postResponses sends a POST /v1/responses request
+func postResponses(router http.Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/v1/responses", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// =============================================================================
+// TestResponsesHandler
+// =============================================================================
+
+func TestResponsesHandler(t *testing.T) {
+	t.Run("returns a response object", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+		server.HttpClient = fake
+
+		w := postResponses(router, `{"model": "claude-sonnet-4.5", "instructions": "Be brief.", "input": "Hi"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.True(t, strings.HasPrefix(resp["id"].(string), "resp_"))
+		assert.Equal(t, "response", resp["object"])
+		assert.Equal(t, "completed", resp["status"])
+		assert.Equal(t, "claude-sonnet-4.5", resp["model"])
+		message := resp["output"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "message", message["type"])
+		assert.Equal(t, "Hello", message["content"].([]interface{})[0].(map[string]interface{})["text"])
+		assert.Contains(t, resp["usage"], "input_tokens")
+
+		payload := fake.Requests()[0].Payload.(*converter.KiroPayload)
+		content := payload.ConversationState.CurrentMessage.UserInputMessage.Content
+		assert.Contains(t, content, "Be brief.")
+		assert.Contains(t, content, "Hi")
+	})
+
+	t.Run("returns function calls as output items", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(
+			`{"name":"get_weather","toolUseId":"toolu_1"}`,
+			`{"input":"{\"city\":\"Paris\"}"}`,
+			`{"stop":true}`,
+		))
+
+		w := postResponses(router, `{
+			"model": "claude-sonnet-4.5",
+			"input": "Weather in Paris?",
+			"tools": [{"type": "function", "name": "get_weather", "parameters": {"type": "object"}}]
+		}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		call := resp["output"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "function_call", call["type"])
+		assert.Equal(t, "toolu_1", call["call_id"])
+		assert.Equal(t, `{"city":"Paris"}`, call["arguments"])
+	})
+
+	t.Run("streams semantic events", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+
+		w := postResponses(router, `{"model": "claude-sonnet-4.5", "input": "Hi", "stream": true}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+		body := w.Body.String()
+		assert.Contains(t, body, "event: response.created\n")
+		assert.Contains(t, body, "event: response.output_text.delta\n")
+		assert.Contains(t, body, `"delta":"Hello"`)
+		assert.True(t, strings.HasPrefix(body[strings.LastIndex(body, "event: "):], "event: response.completed\n"))
+		assert.NotContains(t, body, "[DONE]")
+	})
+
+	t.Run("passes upstream errors through", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Response{StatusCode: http.StatusBadRequest, Body: "bad request"})
+
+		w := postResponses(router, `{"model": "claude-sonnet-4.5", "input": "Hi"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "bad request")
+	})
+}
+
+// =============================================================================
+// TestResponsesValidation
+// =============================================================================
+
+func TestResponsesValidation(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"malformed JSON", `{"model": `, "Invalid request"},
+		{"missing input", `{"model": "claude-sonnet-4.5"}`, "input"},
+		{"previous_response_id", `{"model": "claude-sonnet-4.5", "input": "Hi", "previous_response_id": "resp_1"}`, "previous_response_id"},
+		{"built-in tools", `{"model": "claude-sonnet-4.5", "input": "Hi", "tools": [{"type": "file_search"}]}`, "function tools"},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			_, router := newTestServer("test-key")
+
+			w := postResponses(router, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			errBody := body["error"].(map[string]interface{})
+			assert.Equal(t, "invalid_request_error", errBody["type"])
+			assert.Contains(t, errBody["message"], tt.want)
+		})
+	}
+}
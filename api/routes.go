@@ -2,21 +2,46 @@
 package api
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/activity"
+	"kiro-go-proxy/agent"
+	"kiro-go-proxy/audit"
 	"kiro-go-proxy/auth"
+	"kiro-go-proxy/batch"
 	"kiro-go-proxy/client"
 	"kiro-go-proxy/config"
 	"kiro-go-proxy/converter"
+	"kiro-go-proxy/convstore"
+	"kiro-go-proxy/debug"
+	"kiro-go-proxy/embeddings"
+	"kiro-go-proxy/fixtures"
+	"kiro-go-proxy/idempotency"
+	"kiro-go-proxy/imagefetch"
+	"kiro-go-proxy/latency"
 	"kiro-go-proxy/model"
 	"kiro-go-proxy/parser"
+	"kiro-go-proxy/ratelimit"
+	"kiro-go-proxy/respcache"
 	"kiro-go-proxy/stream"
+	"kiro-go-proxy/tracing"
+	"kiro-go-proxy/transcript"
+	"kiro-go-proxy/usage"
 	"kiro-go-proxy/utils"
 
 	"github.com/gin-gonic/gin"
@@ -25,127 +50,836 @@ import (
 
 // Server holds the API server dependencies
 type Server struct {
-	Cfg           *config.Config
-	AuthManager   *auth.Manager
-	HttpClient    *client.Client
-	ModelCache    *model.Cache
-	ModelResolver *model.Resolver
+	Cfg            *config.Config
+	AuthManager    *auth.Manager
+	CredentialPool *auth.Pool
+	HttpClient     client.KiroClient
+	Breaker        *client.Breaker
+	ModelCache     *model.Cache
+	ModelResolver  *model.Resolver
+	ModelRefresher *model.Refresher
+	ImageFetcher   *imagefetch.Fetcher
+	Agent          *agent.Runner
+	RateLimiter    *ratelimit.Limiter
+	AuthLockout    *ratelimit.Lockout
+	Usage          *usage.Tracker
+	ResponseCache  *respcache.Cache
+	Summaries      *respcache.Cache
+	Idempotency    *idempotency.Store
+	Embeddings     embeddings.Backend
+	Batches        *batch.Manager
+	Activity       *activity.Tracker
+	Latency        *latency.Tracker
+	Audit          *audit.Log
+	Conversations  *convstore.Store
+	Transcripts    *transcript.Writer
+
+	// ConfigFile is the config file re-read by ReloadConfig ("" uses CONFIG_FILE)
+	ConfigFile string
+
+	// cfgMu guards Cfg, which ReloadConfig and the admin API replace rather than modify
+	cfgMu sync.RWMutex
+
+	// auditFilter is the PII filter of audit records at the pii level
+	auditFilter auditFilterCache
+
+	// readiness caches the account and Kiro checks of the readiness endpoint
+	readiness readinessProbe
+
+	// Kubernetes probe state: started is set by MarkStarted, ready latches once /readyz passes
+	started atomic.Bool
+	ready   atomic.Bool
 }
 
-// NewServer creates a new API server
+// NewServer creates a new API server with a single account
 func NewServer(cfg *config.Config, authManager *auth.Manager) *Server {
-	httpClient := client.NewClient(cfg, authManager)
+	return NewServerWithPool(cfg, auth.NewPoolFromManagers(authManager))
+}
+
+// NewServerWithPool creates a new API server backed by a credential pool
+func NewServerWithPool(cfg *config.Config, pool *auth.Pool) *Server {
+	kiroClient := client.NewClient(cfg, pool)
+	var httpClient client.KiroClient = kiroClient
+	if cfg.FixtureRecordDir != "" {
+		httpClient = fixtures.NewRecorder(httpClient, cfg.FixtureRecordDir)
+	}
 	modelCache := model.NewCache(cfg)
 	modelResolver := model.NewResolver(modelCache, cfg)
 
-	return &Server{
-		Cfg:           cfg,
-		AuthManager:   authManager,
-		HttpClient:    httpClient,
-		ModelCache:    modelCache,
-		ModelResolver: modelResolver,
+	s := &Server{
+		Cfg:            cfg,
+		AuthManager:    pool.Primary(),
+		CredentialPool: pool,
+		HttpClient:     httpClient,
+		Breaker:        kiroClient.Breaker(),
+		ModelCache:     modelCache,
+		ModelResolver:  modelResolver,
+		ImageFetcher:   imagefetch.NewFetcher(cfg),
+		Agent:          agent.NewRunner(cfg),
+		RateLimiter:    ratelimit.NewLimiter(cfg),
+		AuthLockout:    ratelimit.NewLockout(cfg),
+		Usage:          usage.NewTracker(cfg),
+		ResponseCache:  respcache.NewCache(cfg),
+		Summaries:      respcache.New(summaryCacheTTL, summaryCacheEntries),
+		Idempotency:    idempotency.NewStore(cfg),
+		Embeddings:     embeddings.NewBackend(cfg),
+		Activity:       activity.NewTracker(),
+		Latency:        latency.NewTracker(),
+		Audit:          audit.NewLog(cfg),
+		Conversations:  convstore.NewStore(cfg),
+		Transcripts:    transcript.NewWriter(cfg),
 	}
+	s.ModelRefresher = model.NewRefresher(modelCache, s.fetchModels)
+	s.Batches = batch.NewManager(cfg, s.processBatchRequest)
+	return s
 }
 
 // SetupRoutes sets up all API routes
 func (s *Server) SetupRoutes(r *gin.Engine) {
-	// Health check
-	r.GET("/", s.HealthHandler)
-	r.GET("/health", s.HealthHandler)
+	// Request IDs, access logging and tracing for every route
+	r.Use(s.RequestLogMiddleware(), s.ActivityMiddleware(), s.TracingMiddleware(), s.BodyLimitMiddleware(), s.RequestScopeMiddleware())
+
+	// Health check, open unless HEALTH_AUTH is set
+	r.GET("/", s.AuthMiddleware(), s.HealthHandler)
+	r.GET("/health", s.AuthMiddleware(), s.HealthHandler)
+	r.GET("/health/ready", s.AuthMiddleware(), s.ReadinessHandler)
+	r.GET("/metrics", s.MetricsHandler)
+
+	// API docs
+	r.GET("/docs", s.DocsHandler)
+	r.GET("/docs/redoc", s.RedocHandler)
+	r.GET("/docs/openapi.json", s.OpenAPIHandler)
+	r.GET("/docs/assets/:file", s.DocsAssetHandler)
+
+	// Kubernetes probes
+	r.GET("/livez", s.LivezHandler)
+	r.GET("/readyz", s.ReadyzHandler)
+	r.GET("/startupz", s.StartupzHandler)
 
 	// OpenAI-compatible routes
 	v1 := r.Group("/v1")
-	v1.Use(s.AuthMiddleware())
+	v1.Use(s.AuthMiddleware(), s.RateLimitMiddleware(), s.RequestTimeoutMiddleware())
 	{
 		v1.GET("/models", s.ListModelsHandler)
-		v1.POST("/chat/completions", s.ChatCompletionsHandler)
+		v1.GET("/models/:id", s.GetModelHandler)
+		v1.POST("/chat/completions", s.IdempotencyMiddleware(), s.UsageMiddleware(), s.DebugCaptureMiddleware(), s.AuditMiddleware(), s.ConversationStoreMiddleware(), s.TranscriptMiddleware(), s.ChatCompletionsHandler)
+		v1.POST("/chat/completions/batch", s.IdempotencyMiddleware(), s.AuditMiddleware(), s.ChatCompletionBatchHandler)
+		v1.POST("/responses", s.IdempotencyMiddleware(), s.UsageMiddleware(), s.DebugCaptureMiddleware(), s.AuditMiddleware(), s.ConversationStoreMiddleware(), s.TranscriptMiddleware(), s.ResponsesHandler)
+		v1.POST("/embeddings", s.IdempotencyMiddleware(), s.UsageMiddleware(), s.EmbeddingsHandler)
+		v1.POST("/tokenize", s.TokenizeHandler)
+		v1.GET("/accounts", s.AccountsStatusHandler)
+		v1.GET("/usage", s.UsageHandler)
+		v1.DELETE("/conversations/:id", s.DeleteConversationHandler)
 	}
 
 	// Anthropic-compatible routes
-	v1.POST("/messages", s.MessagesHandler)
+	v1.POST("/messages", s.IdempotencyMiddleware(), s.UsageMiddleware(), s.DebugCaptureMiddleware(), s.AuditMiddleware(), s.ConversationStoreMiddleware(), s.TranscriptMiddleware(), s.MessagesHandler)
+	v1.POST("/messages/count_tokens", s.CountTokensHandler)
+	s.setupBatchRoutes(v1)
+
+	// WebSocket streaming for clients behind proxies that buffer SSE
+	ws := r.Group("/ws")
+	ws.Use(s.AuthMiddleware(), s.RateLimitMiddleware())
+	{
+		ws.GET("/chat", s.ChatWebSocketHandler)
+	}
+
+	// Gemini-compatible routes
+	s.setupGeminiRoutes(r)
+
+	// Ollama-compatible routes
+	s.setupOllamaRoutes(r)
+
+	// Runtime management routes
+	s.setupAdminRoutes(r)
+
+	// Live status dashboard
+	s.setupDashboardRoutes(r)
+}
+
+// RequestLogMiddleware assigns a request ID, returned in X-Request-Id, and
+// writes one structured log line per request
+func (s *Server) RequestLogMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		entry := accesslog.NewEntry(c.GetHeader(accesslog.HeaderRequestID))
+		c.Header(accesslog.HeaderRequestID, entry.ID)
+		c.Request = c.Request.WithContext(accesslog.WithEntry(c.Request.Context(), entry))
+
+		c.Next()
+
+		duration := time.Since(entry.Start)
+		fields := log.Fields{
+			"request_id":  entry.ID,
+			"method":      c.Request.Method,
+			"path":        c.Request.URL.Path,
+			"status":      c.Writer.Status(),
+			"duration_ms": duration.Milliseconds(),
+			"client_ip":   c.ClientIP(),
+		}
+		if model, totals := usage.FromContext(c.Request.Context()).Result(); model != "" {
+			fields["model"] = model
+			fields["prompt_tokens"] = totals.PromptTokens
+			fields["completion_tokens"] = totals.CompletionTokens
+		}
+		if resolvedModel := entry.ResolvedModel(); resolvedModel != "" {
+			fields["resolved_model"] = resolvedModel
+		}
+		firstToken, hasFirstToken := entry.FirstTokenLatency()
+		if hasFirstToken {
+			fields["first_token_ms"] = firstToken.Milliseconds()
+		}
+		if traceID := tracing.FromContext(c.Request.Context()).TraceID(); traceID != "" {
+			fields["trace_id"] = traceID
+		}
+		log.WithFields(fields).Info("Request completed")
+
+		s.Latency.Observe(s.latencyModel(entry.ResolvedModel()), firstToken, hasFirstToken, duration)
+		if threshold := s.currentConfig().SlowRequestThreshold; threshold > 0 && duration.Seconds() > threshold {
+			logSlowRequest(c, entry, fields)
+		}
+	}
+}
+
+// latencyModel returns the latency series of a resolved model: the model itself
+// when Kiro lists it or it is a configured hidden model, else
+// latency.OtherModel, so passthrough names cannot create series without bound
+func (s *Server) latencyModel(resolvedModel string) string {
+	if resolvedModel == "" || s.ModelCache.IsValidModel(resolvedModel) {
+		return resolvedModel
+	}
+	for _, internalID := range s.ModelResolver.HiddenModels() {
+		if internalID == resolvedModel {
+			return resolvedModel
+		}
+	}
+	return latency.OtherModel
+}
+
+// logSlowRequest warns about a request that took longer than SLOW_REQUEST_THRESHOLD,
+// adding what helps to tell a slow model from a large payload
+func logSlowRequest(c *gin.Context, entry *accesslog.Entry, fields log.Fields) {
+	if conversationID := entry.ConversationID(); conversationID != "" {
+		fields["conversation_id"] = conversationID
+	}
+	if c.Request.ContentLength >= 0 {
+		fields["request_bytes"] = c.Request.ContentLength
+	}
+	if size := c.Writer.Size(); size >= 0 {
+		fields["response_bytes"] = size
+	}
+	fields["streaming"] = entry.Streaming()
+	log.WithFields(fields).Warn("Slow request")
+}
+
+// TracingMiddleware starts the server span of each request when tracing is enabled
+func (s *Server) TracingMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = c.Request.URL.Path
+		}
+		ctx, span := tracing.StartServerSpan(c.Request.Context(), c.Request.Method+" "+route, c.Request.Header)
+		if span == nil {
+			c.Next()
+			return
+		}
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		span.SetAttribute("http.method", c.Request.Method)
+		span.SetAttribute("http.route", route)
+		span.SetAttribute("http.status_code", c.Writer.Status())
+		span.SetAttribute("request_id", accesslog.FromContext(ctx).ID)
+		if model := accesslog.FromContext(ctx).ResolvedModel(); model != "" {
+			span.SetAttribute("kiro.model", model)
+		}
+		if c.Writer.Status() >= http.StatusInternalServerError {
+			span.RecordError(fmt.Errorf("HTTP %d", c.Writer.Status()))
+		}
+	}
+}
+
+type requestScopeKey struct{}
+
+// requestScope is what request preparation needs from the HTTP request it
+// serves: the API key, the client's x-conversation-id and a way to set response
+// headers. It is carried in the request context because preparation is shared
+// with batch requests, which have no HTTP request of their own; the pooled
+// *gin.Context itself must not outlive its handler.
+type requestScope struct {
+	apiKey         string
+	conversationID string
+	setHeader      func(key, value string) // nil without an HTTP response
+}
+
+// RequestScopeMiddleware puts the request's scope in its context. AuthMiddleware
+// adds the API key once it is validated.
+func (s *Server) RequestScopeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := &requestScope{
+			conversationID: c.GetHeader(ConversationIDHeader),
+			setHeader:      c.Writer.Header().Set,
+		}
+		c.Request = c.Request.WithContext(context.WithValue(c.Request.Context(), requestScopeKey{}, scope))
+		c.Next()
+	}
+}
+
+// scopeOf returns the request scope of ctx, or an empty one if there is none
+func scopeOf(ctx context.Context) *requestScope {
+	if scope, ok := ctx.Value(requestScopeKey{}).(*requestScope); ok {
+		return scope
+	}
+	return &requestScope{}
+}
+
+// setAPIKey records the validated API key of the request
+func setAPIKey(c *gin.Context, apiKey string) {
+	c.Set(apiKeyContextKey, apiKey)
+	if scope, ok := c.Request.Context().Value(requestScopeKey{}).(*requestScope); ok {
+		scope.apiKey = apiKey
+	}
+}
+
+// withoutHTTPRequest returns a context for a request run on behalf of apiKey
+// without an HTTP request of its own: it has no x-conversation-id and cannot
+// set response headers
+func withoutHTTPRequest(ctx context.Context, apiKey string) context.Context {
+	return context.WithValue(ctx, requestScopeKey{}, &requestScope{apiKey: apiKey})
 }
 
-// AuthMiddleware validates API key
+// requestAPIKey returns the API key the request in ctx was made with
+func requestAPIKey(ctx context.Context) string {
+	return scopeOf(ctx).apiKey
+}
+
+// setResponseHeader sets a header on the response of the request in ctx, if it
+// has one
+func setResponseHeader(ctx context.Context, key, value string) {
+	if scope := scopeOf(ctx); scope.setHeader != nil {
+		scope.setHeader(key, value)
+	}
+}
+
+// AuthMiddleware validates the API key, sent either as "Authorization: Bearer <key>"
+// (OpenAI) or in the x-api-key header (Anthropic). Errors on Anthropic routes use the
+// Anthropic error shape.
 func (s *Server) AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// Skip auth for health endpoints
-		if c.Request.URL.Path == "/" || c.Request.URL.Path == "/health" {
+		if s.authExempt(c) {
+			// A valid key on an open route still gets its per-key settings
+			if apiKey := clientAPIKey(c); apiKey != "" && validAPIKey(s.currentConfig(), apiKey) {
+				setAPIKey(c, apiKey)
+			}
 			c.Next()
 			return
 		}
 
-		// Get authorization header
-		authHeader := c.GetHeader("Authorization")
-		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": gin.H{
-					"message": "Missing Authorization header",
-					"type":    "invalid_request_error",
-				},
-			})
+		if s.rejectLockedOut(c) {
+			return
+		}
+
+		apiKey := clientAPIKey(c)
+		if apiKey == "" {
+			if isAnthropicRoute(c) {
+				anthropicAuthError(c, "x-api-key header is required")
+			} else if isGeminiRoute(c) {
+				geminiError(c, http.StatusUnauthorized, "API key is required")
+			} else if isOllamaRoute(c) {
+				ollamaError(c, http.StatusUnauthorized, "Missing Authorization header")
+			} else {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": gin.H{
+						"message": "Missing Authorization header",
+						"type":    "invalid_request_error",
+					},
+				})
+			}
 			c.Abort()
 			return
 		}
 
-		// Extract API key
-		var apiKey string
+		// Validate API key
+		if !validAPIKey(s.currentConfig(), apiKey) {
+			s.recordAuthFailure(c)
+			if isAnthropicRoute(c) {
+				anthropicAuthError(c, "invalid x-api-key")
+			} else if isGeminiRoute(c) {
+				geminiError(c, http.StatusUnauthorized, "API key not valid")
+			} else if isOllamaRoute(c) {
+				ollamaError(c, http.StatusUnauthorized, "Invalid API key")
+			} else {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"error": gin.H{
+						"message": "Invalid API key",
+						"type":    "invalid_request_error",
+					},
+				})
+			}
+			c.Abort()
+			return
+		}
+
+		s.AuthLockout.Success(c.ClientIP())
+		setAPIKey(c, apiKey)
+		c.Next()
+	}
+}
+
+// healthRoutes are open without an API key unless HEALTH_AUTH is set
+var healthRoutes = map[string]bool{
+	"/":             true,
+	"/health":       true,
+	"/health/ready": true,
+}
+
+// authExempt reports whether the route is open without an API key: a health
+// route, or one matching AUTH_EXEMPT_PATHS by its registered path, its request
+// path or a "*" prefix
+func (s *Server) authExempt(c *gin.Context) bool {
+	cfg := s.currentConfig()
+	if healthRoutes[c.FullPath()] {
+		return !cfg.HealthAuth
+	}
+	for _, path := range cfg.AuthExemptPaths {
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			if strings.HasPrefix(c.Request.URL.Path, prefix) {
+				return true
+			}
+		} else if path == c.FullPath() || path == c.Request.URL.Path {
+			return true
+		}
+	}
+	return false
+}
+
+// clientAPIKey extracts the request's API key, preferring x-api-key as sent by
+// Anthropic SDKs
+func clientAPIKey(c *gin.Context) string {
+	apiKey := c.GetHeader("x-api-key")
+	if apiKey == "" && isGeminiRoute(c) {
+		// Google SDKs send x-goog-api-key or the key query parameter
+		apiKey = c.GetHeader("x-goog-api-key")
+		if apiKey == "" {
+			apiKey = c.Query("key")
+		}
+	}
+	if apiKey == "" {
+		authHeader := c.GetHeader("Authorization")
 		if strings.HasPrefix(authHeader, "Bearer ") {
 			apiKey = strings.TrimPrefix(authHeader, "Bearer ")
 		} else {
 			apiKey = authHeader
 		}
+	}
+	return apiKey
+}
 
-		// Validate API key
-		if apiKey != s.Cfg.ProxyAPIKey {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": gin.H{
-					"message": "Invalid API key",
-					"type":    "invalid_request_error",
-				},
-			})
+// validAPIKey reports whether apiKey is one of the client API keys. Every key is
+// compared, so the time taken does not reveal which one matched.
+func validAPIKey(cfg *config.Config, apiKey string) bool {
+	valid := false
+	for _, key := range cfg.APIKeys() {
+		if keysEqual(apiKey, key) {
+			valid = true
+		}
+	}
+	return valid
+}
+
+// keysEqual compares API keys in constant time. Both are hashed first so the
+// comparison does not reveal the configured key's length either.
+func keysEqual(a, b string) bool {
+	ha, hb := sha256.Sum256([]byte(a)), sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// rejectLockedOut answers 429 with Retry-After when the client IP is locked out
+// after repeated authentication failures, even if this request has a valid key
+func (s *Server) rejectLockedOut(c *gin.Context) bool {
+	locked, retryAfter := s.AuthLockout.Locked(c.ClientIP())
+	if !locked {
+		return false
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	routeError(c, http.StatusTooManyRequests, "rate_limit_error",
+		fmt.Sprintf("Too many failed authentication attempts, retry in %d seconds", seconds))
+	c.Abort()
+	return true
+}
+
+// recordAuthFailure counts a rejected key against the client IP. Every failure
+// is logged with the source IP so tools like fail2ban can ban repeat offenders.
+func (s *Server) recordAuthFailure(c *gin.Context) {
+	ip := c.ClientIP()
+	failures, locked := s.AuthLockout.Failure(ip)
+	entry := log.WithFields(log.Fields{"client_ip": ip, "path": c.Request.URL.Path, "failures": failures})
+	if locked {
+		entry.Warnf("Authentication failure from %s, locked out for %ds after %d failures",
+			ip, s.currentConfig().AuthLockoutDuration, failures)
+		return
+	}
+	entry.Warnf("Authentication failure from %s", ip)
+}
+
+// isAnthropicRoute reports whether the request targets an Anthropic-compatible endpoint
+func isAnthropicRoute(c *gin.Context) bool {
+	return strings.HasPrefix(c.Request.URL.Path, "/v1/messages")
+}
+
+// anthropicAuthError writes a 401 in the Anthropic error format
+func anthropicAuthError(c *gin.Context, message string) {
+	anthropicError(c, http.StatusUnauthorized, "authentication_error", message)
+}
+
+// anthropicError writes an error in the Anthropic error format
+func anthropicError(c *gin.Context, status int, errType, message string) {
+	c.JSON(status, anthropicErrorBody(status, errType, message))
+}
+
+// anthropicErrorBody is an error in the Anthropic error format. Types Anthropic
+// SDKs do not know (internal_error, insufficient_quota, ...) are replaced by the
+// type for the status.
+func anthropicErrorBody(status int, errType, message string) gin.H {
+	switch errType {
+	case "invalid_request_error", "authentication_error", "permission_error", "not_found_error",
+		"request_too_large", "rate_limit_error", "api_error", "overloaded_error":
+	default:
+		errType = anthropicErrorType(status)
+	}
+	return gin.H{
+		"type": "error",
+		"error": gin.H{
+			"type":    errType,
+			"message": message,
+		},
+	}
+}
+
+// anthropicErrorType returns the Anthropic error type for an HTTP status
+func anthropicErrorType(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "invalid_request_error"
+	case http.StatusUnauthorized:
+		return "authentication_error"
+	case http.StatusForbidden:
+		return "permission_error"
+	case http.StatusNotFound:
+		return "not_found_error"
+	case http.StatusRequestEntityTooLarge:
+		return "request_too_large"
+	case http.StatusTooManyRequests:
+		return "rate_limit_error"
+	case http.StatusServiceUnavailable, 529:
+		return "overloaded_error"
+	default:
+		return "api_error"
+	}
+}
+
+// apiKeyContextKey is the gin context key holding the authenticated API key
+const apiKeyContextKey = "apiKey"
+
+// RateLimitMiddleware enforces per API key request rate and concurrency limits
+func (s *Server) RateLimitMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetString(apiKeyContextKey)
+
+		if ok, retryAfter := s.RateLimiter.Allow(apiKey); !ok {
+			seconds := int(math.Ceil(retryAfter.Seconds()))
+			c.Header("Retry-After", strconv.Itoa(seconds))
+			routeError(c, http.StatusTooManyRequests, "rate_limit_error", fmt.Sprintf("Rate limit exceeded, retry in %d seconds", seconds))
+			c.Abort()
+			return
+		}
+
+		release, ok := s.RateLimiter.Acquire(apiKey)
+		if !ok {
+			c.Header("Retry-After", "1")
+			routeError(c, http.StatusTooManyRequests, "rate_limit_error", "Too many concurrent requests for this API key")
+			c.Abort()
+			return
+		}
+		defer release()
+
+		c.Next()
+	}
+}
+
+// UsageMiddleware rejects requests from keys over their quota and records the usage of the rest
+func (s *Server) UsageMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		apiKey := c.GetString(apiKeyContextKey)
+
+		if err := s.Usage.CheckQuota(apiKey); err != nil {
+			routeError(c, http.StatusTooManyRequests, "insufficient_quota", err.Error())
 			c.Abort()
 			return
 		}
 
+		request := usage.NewRequest()
+		c.Request = c.Request.WithContext(usage.WithRequest(c.Request.Context(), request))
+
 		c.Next()
+
+		if model, totals := request.Result(); model != "" {
+			s.Usage.Record(apiKey, model, totals)
+		}
 	}
 }
 
-// HealthHandler handles health check requests
+// DebugCaptureMiddleware records the client request and response when DEBUG_MODE is enabled
+func (s *Server) DebugCaptureMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		capture := debug.NewCapture(s.currentConfig())
+		if capture == nil {
+			c.Next()
+			return
+		}
+
+		if body, err := io.ReadAll(c.Request.Body); err == nil {
+			capture.Write(debug.ClientRequestFile, body)
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		c.Request = c.Request.WithContext(debug.WithCapture(c.Request.Context(), capture))
+		c.Writer = &captureWriter{ResponseWriter: c.Writer, capture: capture}
+
+		c.Next()
+
+		capture.Finish(c.Writer.Status())
+	}
+}
+
+// captureWriter tees the client response into a debug capture
+type captureWriter struct {
+	gin.ResponseWriter
+	capture *debug.Capture
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.capture.Write(debug.ClientResponseFile, data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(data string) (int, error) {
+	w.capture.Write(debug.ClientResponseFile, []byte(data))
+	return w.ResponseWriter.WriteString(data)
+}
+
+// Unwrap lets http.ResponseController reach the connection
+func (w *captureWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// HealthHandler handles health check requests. It only reports that the process is up;
+// ?deep=1 runs the readiness checks instead.
 func (s *Server) HealthHandler(c *gin.Context) {
+	if deep, _ := strconv.ParseBool(c.Query("deep")); deep {
+		s.ReadinessHandler(c)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"status":          "ok",
+		"timestamp":       time.Now().UTC().Format(time.RFC3339),
+		"version":         config.AppVersion,
+		"circuit_breaker": s.Breaker.Status(),
+	})
+}
+
+// AccountsStatusHandler handles GET /v1/accounts with per-account health
+func (s *Server) AccountsStatusHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
-		"status":    "ok",
-		"timestamp": time.Now().UTC().Format(time.RFC3339),
-		"version":   config.AppVersion,
+		"object":   "list",
+		"accounts": s.CredentialPool.Status(),
 	})
 }
 
-// ListModelsHandler handles GET /v1/models
+// UsageHandler reports the calling key's usage and quotas
+func (s *Server) UsageHandler(c *gin.Context) {
+	apiKey := c.GetString(apiKeyContextKey)
+	cfg := s.currentConfig()
+
+	c.JSON(http.StatusOK, gin.H{
+		"object": "usage",
+		"key_id": usage.KeyID(apiKey),
+		"total":  s.Usage.Total(apiKey),
+		"models": s.Usage.Models(apiKey),
+		"quota": gin.H{
+			"requests": cfg.QuotaRequests,
+			"tokens":   cfg.QuotaTokens,
+			"credits":  cfg.QuotaCredits,
+		},
+	})
+}
+
+// maxModelListLimit is the largest page size of GET /v1/models
+const maxModelListLimit = 1000
+
+// ListModelsHandler handles GET /v1/models. The list can be narrowed to a model
+// family (?family=sonnet) and to verified models (?verified=true, hiding names
+// that are only passed through to Kiro), and paged with limit and after.
 func (s *Server) ListModelsHandler(c *gin.Context) {
-	models := s.ModelResolver.GetAvailableModels()
+	models := s.ModelResolver.GetAvailableModelDetails()
+
+	family := strings.ToLower(c.Query("family"))
+	var verified *bool
+	if raw := c.Query("verified"); raw != "" {
+		value, err := strconv.ParseBool(raw)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, openAIValidationBody(&converter.FieldError{Field: "verified", Message: "must be true or false"}))
+			return
+		}
+		verified = &value
+	}
+	filtered := models[:0]
+	for _, details := range models {
+		if (family == "" || details.Family == family) && (verified == nil || details.Verified == *verified) {
+			filtered = append(filtered, details)
+		}
+	}
+	models = filtered
+
+	if after := c.Query("after"); after != "" {
+		index := -1
+		for i, details := range models {
+			if details.ID == after {
+				index = i
+				break
+			}
+		}
+		if index < 0 {
+			c.JSON(http.StatusBadRequest, openAIValidationBody(&converter.FieldError{Field: "after", Message: fmt.Sprintf("no model '%s' in the list", after)}))
+			return
+		}
+		models = models[index+1:]
+	}
+	hasMore := false
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit < 1 || limit > maxModelListLimit {
+			c.JSON(http.StatusBadRequest, openAIValidationBody(&converter.FieldError{Field: "limit", Message: fmt.Sprintf("must be between 1 and %d", maxModelListLimit)}))
+			return
+		}
+		if len(models) > limit {
+			models, hasMore = models[:limit], true
+		}
+	}
+
 	response := stream.CreateOpenAIModelsResponse(models)
+	if len(models) > 0 {
+		response.FirstID = models[0].ID
+		response.LastID = models[len(models)-1].ID
+	}
+	response.HasMore = hasMore
 	c.JSON(http.StatusOK, response)
 }
 
+// GetModelHandler handles GET /v1/models/:id
+func (s *Server) GetModelHandler(c *gin.Context) {
+	modelID := c.Param("id")
+	details, ok := s.ModelResolver.GetModelDetails(modelID)
+	if !ok {
+		message := fmt.Sprintf("The model '%s' does not exist", modelID)
+		suggestions := s.ModelResolver.GetSuggestionsForModel(modelID)
+		if len(suggestions) > 0 {
+			message += fmt.Sprintf(". Did you mean one of: %s?", strings.Join(suggestions, ", "))
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": gin.H{
+				"message":     message,
+				"type":        "invalid_request_error",
+				"code":        "model_not_found",
+				"suggestions": suggestions,
+			},
+		})
+		return
+	}
+
+	// The model's effective request profile, as model_profiles applies it
+	data := stream.CreateOpenAIModelData(details)
+	resolution := s.ModelResolver.Resolve(modelID)
+	data.Profile = converter.EffectiveModelProfile(s.currentConfig(), resolution.InternalID, details.MaxOutputTokens, details.SupportsVision)
+	c.JSON(http.StatusOK, data)
+}
+
 // ChatCompletionsHandler handles POST /v1/chat/completions
 func (s *Server) ChatCompletionsHandler(c *gin.Context) {
 	var req converter.OpenAIRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+	if err := s.bindRequest(c, &req, converter.OpenAIRequestFields); err != nil {
+		c.JSON(http.StatusBadRequest, openAIValidationBody(err))
+		return
+	}
+	if !checkUnsupportedParams(c, s.currentConfig(), &req) {
+		return
+	}
+
+	// Builtin tools run server-side; their results vary, so they are never cached
+	if len(req.BuiltinTools()) > 0 {
+		s.handleAgenticChatCompletion(c, &req)
+		return
+	}
+
+	// Identical non-streaming requests may be answered from the response cache
+	var cacheKey string
+	if !req.Stream {
+		cacheKey = s.responseCacheKey(c, &req)
+		if s.serveCachedResponse(c, cacheKey) {
+			return
+		}
+	}
+
+	prepared, status, errBody := s.prepareChatCompletion(c.Request.Context(), &req)
+	if prepared == nil {
+		c.JSON(status, errBody)
+		return
+	}
+
+	// Build URL
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+
+	// Handle streaming vs non-streaming
+	if req.Stream {
+		s.handleStreamingChatCompletion(c, prepared.cfg, apiURL, prepared.payload, req.Model, prepared.conversationID, prepared.promptTokens, prepared.limits)
+	} else {
+		s.handleNonStreamingChatCompletion(c, prepared.cfg, apiURL, prepared.payload, req.Model, prepared.conversationID, prepared.promptTokens, prepared.limits, req.ResponseFormat, req.Choices(), req.Logprobs, cacheKey)
+	}
+}
+
+// chatCompletion is an OpenAI request converted to a Kiro payload
+type chatCompletion struct {
+	cfg            *config.Config
+	payload        *converter.KiroPayload
+	conversationID string
+	promptTokens   int
+	limits         stream.Limits
+
+	// prefill is the client's final assistant message, prepended to the reply
+	prefill string
+}
+
+// prepareChatCompletion converts an OpenAI request to a Kiro payload. On failure it
+// returns nil with the HTTP status and OpenAI error body to send to the client.
+func (s *Server) prepareChatCompletion(ctx context.Context, req *converter.OpenAIRequest) (*chatCompletion, int, gin.H) {
+	// reasoning_effort overrides the global fake reasoning settings for this request
+	cfg, err := converter.ApplyReasoningEffort(s.currentConfig(), req.ReasoningEffort)
+	if err != nil {
+		return nil, http.StatusBadRequest, gin.H{
 			"error": gin.H{
 				"message": fmt.Sprintf("Invalid request: %v", err),
 				"type":    "invalid_request_error",
 			},
-		})
-		return
+		}
 	}
 
 	// Resolve model
 	resolution := s.ModelResolver.Resolve(req.Model)
 	log.Debugf("Model resolution: %s -> %s (source: %s)", req.Model, resolution.InternalID, resolution.Source)
-
-	// Convert messages to unified format
-	unifiedMessages, systemPrompt := converter.ConvertOpenAIToUnified(req.Messages)
+	cfg = converter.ApplyModelProfile(cfg, resolution.InternalID)
 
 	// Convert tools to unified format
 	var unifiedTools []converter.UnifiedTool
@@ -153,77 +887,137 @@ func (s *Server) ChatCompletionsHandler(c *gin.Context) {
 		unifiedTools = converter.ConvertOpenAIToolsToUnified(req.Tools)
 	}
 
-	// Generate conversation ID
+	// Convert messages to unified format, with the history of a stored conversation
+	unifiedMessages, systemPrompt := converter.ConvertOpenAIToUnified(req.Messages)
+	unifiedMessages = s.withStoredHistory(ctx, cfg, resolution.InternalID, unifiedMessages, systemPrompt, unifiedTools)
+
+	// Remote images are not downloaded yet; IMAGE_FETCH_MAX_BYTES bounds them
+	if err := converter.CheckRequestLimits(unifiedMessages, systemPrompt, unifiedTools, cfg); err != nil {
+		return nil, http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": err.Error(),
+				"type":    "invalid_request_error",
+			},
+		}
+	}
+
+	// Download remote image_url images (dropped when image fetching is disabled)
+	s.ImageFetcher.ResolveImages(ctx, unifiedMessages)
+
+	// Instruct the model to answer in JSON when response_format asks for it
+	systemPrompt = withResponseFormatPrompt(systemPrompt, req.ResponseFormat)
+
+	// Estimate prompt tokens for usage reporting
+	promptTokens := converter.EstimateInputTokens(unifiedMessages, systemPrompt, unifiedTools)
+
+	// Response IDs are per request; the Kiro conversation may continue across requests
 	conversationID := utils.GenerateConversationID()
+	kiroConversationID := resolveConversationID(ctx, cfg, unifiedMessages, systemPrompt)
+	debug.FromContext(ctx).SetConversationID(kiroConversationID)
+	accesslog.FromContext(ctx).SetConversationID(kiroConversationID)
+	usage.FromContext(ctx).SetModel(req.Model)
+	accesslog.FromContext(ctx).SetResolvedModel(resolution.InternalID)
+	transcript.FromContext(ctx).SetMessages(unifiedMessages)
+
+	// Near the context limit, replace the older turns by a summary
+	if summarized := s.summarizeHistory(ctx, cfg, resolution.InternalID, unifiedMessages, systemPrompt, unifiedTools); len(summarized) != len(unifiedMessages) {
+		unifiedMessages = summarized
+		promptTokens = converter.EstimateInputTokens(unifiedMessages, systemPrompt, unifiedTools)
+	}
 
 	// Build Kiro payload
-	payload := converter.BuildKiroPayload(
+	_, buildSpan := tracing.StartSpan(ctx, "converter.BuildKiroPayload", tracing.SpanKindInternal)
+	payload, err := converter.BuildKiroPayload(
 		unifiedMessages,
 		systemPrompt,
 		resolution.InternalID,
 		unifiedTools,
-		conversationID,
+		kiroConversationID,
 		s.AuthManager.ProfileArn(),
-		s.Cfg,
+		s.ModelCache.GetMaxInputTokens(resolution.InternalID),
+		cfg,
 	)
+	buildSpan.End()
 
-	if payload == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+	var contextErr *converter.ContextLengthError
+	if errors.As(err, &contextErr) {
+		return nil, http.StatusBadRequest, gin.H{
+			"error": gin.H{
+				"message": contextErr.Error(),
+				"type":    "invalid_request_error",
+				"code":    "context_length_exceeded",
+			},
+		}
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, gin.H{
 			"error": gin.H{
 				"message": "Failed to build request payload",
 				"type":    "internal_error",
 			},
-		})
-		return
+		}
 	}
 
-	// Build URL
-	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+	// Forward sampling settings and emulate max_tokens/stop on the response
+	inference := &converter.InferenceConfiguration{
+		MaxTokens:   req.GetMaxTokens(),
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+	}
+	limits := stream.Limits{StopSequences: converter.ParseStopSequences(req.Stop)}
+	applyProfileMaxTokens(cfg, resolution.InternalID, inference, &limits)
+	converter.ApplyInferenceConfig(payload, inference, cfg)
+	limits.ToolNames = payload.ToolNames
+	// Kiro may return several tool calls; parallel_tool_calls=false keeps only the first
+	if req.ParallelToolCalls != nil && !*req.ParallelToolCalls {
+		limits.MaxToolCalls = 1
+	}
 
-	// Handle streaming vs non-streaming
-	if req.Stream {
-		s.handleStreamingChatCompletion(c, apiURL, payload, req.Model, conversationID)
-	} else {
-		s.handleNonStreamingChatCompletion(c, apiURL, payload, req.Model, conversationID)
+	return &chatCompletion{
+		cfg:            cfg,
+		payload:        payload,
+		conversationID: conversationID,
+		promptTokens:   promptTokens,
+		limits:         limits,
+	}, http.StatusOK, nil
+}
+
+// withResponseFormatPrompt appends the response_format instruction, if any, to
+// the system prompt
+func withResponseFormatPrompt(systemPrompt string, format *converter.OpenAIResponseFormat) string {
+	formatAddition := converter.GetResponseFormatSystemPromptAddition(format)
+	if formatAddition == "" {
+		return systemPrompt
 	}
+	if systemPrompt == "" {
+		return strings.TrimSpace(formatAddition)
+	}
+	return systemPrompt + formatAddition
 }
 
-func (s *Server) handleStreamingChatCompletion(c *gin.Context, apiURL string, payload *converter.KiroPayload, model, conversationID string) {
-	// Make request
-	ctx := context.Background()
-	resp, err := s.HttpClient.PostStream(ctx, apiURL, payload)
+func (s *Server) handleStreamingChatCompletion(c *gin.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, model, conversationID string, promptTokens int, limits stream.Limits) {
+	// Make request; a failed write to the client cancels it
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	resp, err := s.postStream(ctx, cfg, apiURL, payload)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": fmt.Sprintf("Request failed: %v", err),
-				"type":    "internal_error",
-			},
-		})
+		c.JSON(requestFailedBody(err))
 		return
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{
-			"error": gin.H{
-				"message": string(body),
-				"type":    "api_error",
-			},
-		})
-		return
-	}
-
 	// Set SSE headers
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
 	c.Header("Transfer-Encoding", "chunked")
+	announceStreamTrailers(c)
 
 	// Stream response
-	events := stream.StreamToOpenAI(resp, model, conversationID, s.Cfg.FirstTokenTimeout, true, s.Cfg)
+	events := stream.StreamToOpenAI(ctx, resp, model, conversationID, cfg.FirstTokenTimeout, true, cfg, s.ModelCache, promptTokens, limits)
+	events = stream.WithKeepAlive(events, keepAliveInterval(cfg), stream.OpenAIKeepAlive)
 
-	flusher, ok := c.Writer.(http.Flusher)
+	w, ok := newSSEWriter(c, cfg, cancel)
 	if !ok {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": gin.H{
@@ -234,587 +1028,501 @@ func (s *Server) handleStreamingChatCompletion(c *gin.Context, apiURL string, pa
 		return
 	}
 
-	for event := range events {
-		c.Writer.WriteString(event)
-		flusher.Flush()
-	}
+	writeEvents(ctx, w, events)
 
 	// Send [DONE] marker
-	c.Writer.WriteString("data: [DONE]\n\n")
-	flusher.Flush()
+	w.WriteEvent("data: [DONE]\n\n")
+	w.Flush()
+	writeStreamTrailers(c, model)
 }
 
-func (s *Server) handleNonStreamingChatCompletion(c *gin.Context, apiURL string, payload *converter.KiroPayload, model, conversationID string) {
-	ctx := context.Background()
-	resp, err := s.HttpClient.PostStream(ctx, apiURL, payload)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": fmt.Sprintf("Request failed: %v", err),
-				"type":    "internal_error",
-			},
-		})
+func (s *Server) handleNonStreamingChatCompletion(c *gin.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, model, conversationID string, promptTokens int, limits stream.Limits, responseFormat *converter.OpenAIResponseFormat, n int, logprobs bool, cacheKey string) {
+	// Drafts are not cached, so a client escalating a draft gets a new answer
+	if response := s.draftChatCompletion(c, cfg, apiURL, payload, model, promptTokens, limits, responseFormat, n, logprobs); response != nil {
+		c.JSON(http.StatusOK, response)
 		return
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{
-			"error": gin.H{
-				"message": string(body),
-				"type":    "api_error",
-			},
-		})
+	response, status, errBody := s.createChatCompletion(c.Request.Context(), c.GetString(apiKeyContextKey), cfg, apiURL, payload, model, conversationID, promptTokens, limits, responseFormat, n, logprobs)
+	if response == nil {
+		c.JSON(status, errBody)
 		return
 	}
+	s.writeCachedJSON(c, cacheKey, response)
+}
 
-	// Collect stream result
-	result, err := stream.CollectStreamResult(resp, s.Cfg.FirstTokenTimeout, true, s.Cfg)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": fmt.Sprintf("Stream processing failed: %v", err),
-				"type":    "internal_error",
-			},
-		})
-		return
+// createChatCompletion collects the choices of a non-streaming chat completion
+// and builds the OpenAI response. On failure it returns nil with the HTTP status
+// and OpenAI error body to send to the client.
+func (s *Server) createChatCompletion(ctx context.Context, apiKey string, cfg *config.Config, apiURL string, payload *converter.KiroPayload, model, conversationID string, promptTokens int, limits stream.Limits, responseFormat *converter.OpenAIResponseFormat, n int, logprobs bool) (*converter.OpenAIResponse, int, gin.H) {
+	results, status, errBody := s.collectChoices(ctx, apiKey, cfg, apiURL, payload, limits, responseFormat, n)
+	if results == nil {
+		return nil, status, errBody
 	}
 
-	// Calculate token usage
-	completionTokens := len(result.Content) / 4 // Rough estimate
+	// Calculate token usage: the prompt once, as OpenAI reports it, and the
+	// completions of every choice
+	completionTokens := 0
+	for _, result := range results {
+		completionTokens += stream.CountCompletionTokens(result.Content, result.ThinkingContent, result.ToolCalls)
+	}
 	promptTokens, totalTokens, _, _ := stream.CalculateTokensFromContextUsage(
-		result.ContextUsagePercentage,
+		results[0].ContextUsagePercentage,
 		completionTokens,
+		promptTokens,
 		s.ModelCache,
 		model,
 	)
+	usage.FromContext(ctx).AddTokens(promptTokens, completionTokens)
+	content, reasoning := replyContent(cfg, results[0])
+	convstore.FromContext(ctx).SetReply(results[0].Content, results[0].ToolCalls)
+	transcript.FromContext(ctx).SetReply(content, reasoning, results[0].ToolCalls)
 
 	// Build response
 	response := converter.CreateOpenAIResponse(
 		conversationID,
 		model,
-		result.Content,
-		convertParserToolCalls(result.ToolCalls),
-		"stop",
+		content,
+		convertParserToolCalls(results[0].ToolCalls),
+		stream.OpenAIFinishReason(results[0].StopReason),
 		&converter.OpenAIUsage{
 			PromptTokens:     promptTokens,
 			CompletionTokens: completionTokens,
 			TotalTokens:      totalTokens,
 		},
 	)
+	response.Choices[0].Message.ReasoningContent = reasoning
+	for i, result := range results[1:] {
+		content, reasoning := replyContent(cfg, result)
+		response.AddChoice(content, convertParserToolCalls(result.ToolCalls), stream.OpenAIFinishReason(result.StopReason))
+		response.Choices[i+1].Message.ReasoningContent = reasoning
+	}
+	if logprobs {
+		response.StubLogprobs()
+	}
+	response.KiroCredits = requestCredits(ctx)
+	response.KiroModel = servedModel(ctx, response.Model)
+	return response, http.StatusOK, nil
+}
 
-	c.JSON(http.StatusOK, response)
+// collectChoices collects n independent completions of the payload, each its own
+// Kiro request. The first runs on the request's RATE_LIMIT_CONCURRENT slot; the
+// rest run alongside it only as far as the API key has free slots, and otherwise
+// wait their turn. If any choice fails, its error is returned.
+func (s *Server) collectChoices(ctx context.Context, apiKey string, cfg *config.Config, apiURL string, payload *converter.KiroPayload, limits stream.Limits, responseFormat *converter.OpenAIResponseFormat, n int) ([]*stream.StreamResult, int, gin.H) {
+	if n <= 1 {
+		result, status, errBody := s.collectFormattedCompletion(ctx, cfg, apiURL, payload, limits, responseFormat)
+		if result == nil {
+			return nil, status, errBody
+		}
+		return []*stream.StreamResult{result}, http.StatusOK, nil
+	}
+
+	// Concurrent requests must not share a payload: sending it sets the account's
+	// profile ARN and fallbacks switch its model. Each choice is its own conversation.
+	payloads := make([]*converter.KiroPayload, n)
+	payloads[0] = payload
+	for i := 1; i < n; i++ {
+		payloads[i] = payload.Clone()
+		payloads[i].ConversationState.ConversationID = utils.GenerateConversationID()
+	}
+
+	indexes := make(chan int, n)
+	for i := 0; i < n; i++ {
+		indexes <- i
+	}
+	close(indexes)
+
+	results := make([]*stream.StreamResult, n)
+	statuses := make([]int, n)
+	errBodies := make([]gin.H, n)
+	var wg sync.WaitGroup
+	worker := func(release func()) {
+		defer wg.Done()
+		defer release()
+		for i := range indexes {
+			results[i], statuses[i], errBodies[i] = s.collectFormattedCompletion(ctx, cfg, apiURL, payloads[i], limits, responseFormat)
+		}
+	}
+
+	wg.Add(1)
+	go worker(func() {})
+	for i := 1; i < n; i++ {
+		release, ok := s.RateLimiter.Acquire(apiKey)
+		if !ok {
+			break
+		}
+		wg.Add(1)
+		go worker(release)
+	}
+	wg.Wait()
+
+	for i, result := range results {
+		if result == nil {
+			return nil, statuses[i], errBodies[i]
+		}
+	}
+	return results, http.StatusOK, nil
 }
 
-// MessagesHandler handles POST /v1/messages (Anthropic-compatible)
-func (s *Server) MessagesHandler(c *gin.Context) {
-	var req map[string]interface{}
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+// collectFormattedCompletion collects the full response, retrying when JSON mode
+// output cannot be repaired. On failure it returns nil with the HTTP status and
+// OpenAI error body to send to the client.
+func (s *Server) collectFormattedCompletion(ctx context.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, limits stream.Limits, responseFormat *converter.OpenAIResponseFormat) (*stream.StreamResult, int, gin.H) {
+	var result *stream.StreamResult
+	attempts := 1
+	if responseFormat.RequiresJSON() && cfg.JSONModeMaxRetries > 0 {
+		attempts += cfg.JSONModeMaxRetries
+	}
+
+	for attempt := 1; attempt <= attempts; attempt++ {
+		var status int
+		var errBody gin.H
+		result, status, errBody = s.collectChatCompletion(ctx, cfg, apiURL, payload, limits)
+		if result == nil {
+			return nil, status, errBody
+		}
+
+		if !responseFormat.RequiresJSON() || len(result.ToolCalls) > 0 {
+			break
+		}
+
+		repaired, err := converter.RepairJSONResponse(result.Content, responseFormat)
+		if err == nil {
+			result.Content = repaired
+			break
+		}
+
+		log.Warnf("JSON mode output invalid (attempt %d/%d): %v", attempt, attempts, err)
+		if attempt == attempts {
+			return nil, http.StatusBadGateway, gin.H{
+				"error": gin.H{
+					"message": fmt.Sprintf("Model failed to produce valid JSON after %d attempts: %v", attempts, err),
+					"type":    "invalid_response_error",
+				},
+			}
+		}
+	}
+
+	return result, http.StatusOK, nil
+}
+
+// keepAliveInterval returns how long a stream may stay silent before a keep-alive is sent
+func keepAliveInterval(cfg *config.Config) time.Duration {
+	return time.Duration(cfg.StreamingKeepAliveInterval * float64(time.Second))
+}
+
+// collectChatCompletion sends the payload and collects the full response. On
+// failure it returns nil with the HTTP status and OpenAI error body.
+func (s *Server) collectChatCompletion(ctx context.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, limits stream.Limits) (*stream.StreamResult, int, gin.H) {
+	resp, err := s.postStream(ctx, cfg, apiURL, payload)
+	if err != nil {
+		status, errBody := requestFailedBody(err)
+		return nil, status, errBody
+	}
+	defer resp.Body.Close()
+
+	// Collect stream result
+	result, err := stream.CollectStreamResult(ctx, resp, cfg.FirstTokenTimeout, true, cfg, limits)
+	if err != nil {
+		status, errType, message := streamFailedStatus(err)
+		return nil, status, gin.H{
 			"error": gin.H{
-				"message": fmt.Sprintf("Invalid request: %v", err),
-				"type":    "invalid_request_error",
+				"message": message,
+				"type":    errType,
 			},
-		})
+		}
+	}
+
+	return s.continueTruncated(ctx, cfg, apiURL, payload, limits, result), http.StatusOK, nil
+}
+
+// maxContinuations bounds the follow-up requests made for one truncated response
+const maxContinuations = 2
+
+// continueTruncated asks Kiro to continue a response cut off by its output limit and
+// stitches the parts together. If a continuation fails, the response so far is returned.
+func (s *Server) continueTruncated(ctx context.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, limits stream.Limits, result *stream.StreamResult) *stream.StreamResult {
+	for attempt := 1; attempt <= maxContinuations && result.Truncated; attempt++ {
+		log.Infof("Response truncated by output limit, requesting continuation (%d/%d)", attempt, maxContinuations)
+
+		resp, err := s.HttpClient.PostStream(ctx, apiURL, converter.BuildContinuationPayload(payload, result.Content))
+		if err != nil {
+			log.Warnf("Continuation request failed: %v", err)
+			return result
+		}
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			log.Warnf("Continuation request failed with status %d", resp.StatusCode)
+			return result
+		}
+
+		next, err := stream.CollectStreamResult(ctx, resp, cfg.FirstTokenTimeout, true, cfg, stream.ContinuationLimits(limits, result))
+		resp.Body.Close()
+		if err != nil {
+			log.Warnf("Continuation stream failed: %v", err)
+			return result
+		}
+		result = stream.MergeContinuation(result, next)
+	}
+	return result
+}
+
+// MessagesHandler handles POST /v1/messages (Anthropic-compatible)
+func (s *Server) MessagesHandler(c *gin.Context) {
+	req, ok := s.bindAnthropicRequest(c)
+	if !ok {
+		return
+	}
+
+	// Identical non-streaming requests may be answered from the response cache
+	var cacheKey string
+	if !req.Stream {
+		cacheKey = s.responseCacheKey(c, req)
+		if s.serveCachedResponse(c, cacheKey) {
+			return
+		}
+	}
+
+	prepared, status, errBody := s.prepareMessages(c.Request.Context(), req)
+	if prepared == nil {
+		c.JSON(status, errBody)
 		return
 	}
 
-	// Extract model
-	modelName, _ := req["model"].(string)
+	// Build URL
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+
+	if req.Stream {
+		s.handleStreamingMessages(c, prepared.cfg, apiURL, prepared.payload, req.Model, prepared.conversationID, prepared.promptTokens, prepared.limits, prepared.prefill)
+	} else {
+		s.handleNonStreamingMessages(c, prepared.cfg, apiURL, prepared.payload, req.Model, prepared.conversationID, prepared.promptTokens, prepared.limits, prepared.prefill, cacheKey)
+	}
+}
+
+// anthropicMessage is an Anthropic request converted to a Kiro payload
+type anthropicMessage struct {
+	cfg            *config.Config
+	payload        *converter.KiroPayload
+	conversationID string
+	promptTokens   int
+	limits         stream.Limits
+
+	// prefill is the client's final assistant message, prepended to the reply
+	prefill string
+}
+
+// prepareMessages converts an Anthropic request to a Kiro payload. On failure it
+// returns nil with the HTTP status and error body to send to the client.
+func (s *Server) prepareMessages(ctx context.Context, req *converter.AnthropicRequest) (*anthropicMessage, int, gin.H) {
+	// Settings stay fixed for this request even if the config is reloaded;
+	// thinking overrides the global fake reasoning settings for this request
+	cfg := converter.ApplyAnthropicThinking(s.currentConfig(), req.Thinking)
+
+	// Resolve model
+	modelName := req.Model
 	resolution := s.ModelResolver.Resolve(modelName)
 	log.Debugf("Model resolution: %s -> %s (source: %s)", modelName, resolution.InternalID, resolution.Source)
+	cfg = converter.ApplyModelProfile(cfg, resolution.InternalID)
 
 	// Convert Anthropic request to unified format
-	unifiedMessages, systemPrompt := convertAnthropicRequest(req)
+	unifiedMessages, systemPrompt := converter.ConvertAnthropicToUnified(req)
+	unifiedMessages, prefill := converter.SplitAssistantPrefill(unifiedMessages)
+	unifiedTools := converter.ConvertAnthropicToolsToUnified(req.Tools)
+	unifiedMessages = s.withStoredHistory(ctx, cfg, resolution.InternalID, unifiedMessages, systemPrompt, unifiedTools)
 
-	// Extract tools
-	var unifiedTools []converter.UnifiedTool
-	if tools, ok := req["tools"].([]interface{}); ok {
-		for _, t := range tools {
-			if toolMap, ok := t.(map[string]interface{}); ok {
-				if toolMap["type"] == "function" || toolMap["name"] != nil {
-					// Anthropic format
-					name, _ := toolMap["name"].(string)
-					desc, _ := toolMap["description"].(string)
-					inputSchema, _ := toolMap["input_schema"].(map[string]interface{})
-
-					if name != "" {
-						unifiedTools = append(unifiedTools, converter.UnifiedTool{
-							Name:        name,
-							Description: desc,
-							InputSchema: inputSchema,
-						})
-					}
-				}
-			}
-		}
+	if err := converter.CheckRequestLimits(unifiedMessages, systemPrompt, unifiedTools, cfg); err != nil {
+		return nil, http.StatusBadRequest, anthropicErrorBody(http.StatusBadRequest, "invalid_request_error", err.Error())
 	}
 
-	// Generate conversation ID
+	// Estimate prompt tokens for usage reporting
+	promptTokens := converter.EstimateInputTokens(unifiedMessages, systemPrompt, unifiedTools)
+
+	// Response IDs are per request; the Kiro conversation may continue across requests
 	conversationID := utils.GenerateConversationID()
+	kiroConversationID := resolveConversationID(ctx, cfg, unifiedMessages, systemPrompt)
+	debug.FromContext(ctx).SetConversationID(kiroConversationID)
+	accesslog.FromContext(ctx).SetConversationID(kiroConversationID)
+	usage.FromContext(ctx).SetModel(modelName)
+	accesslog.FromContext(ctx).SetResolvedModel(resolution.InternalID)
+	transcript.FromContext(ctx).SetMessages(unifiedMessages)
+
+	// Near the context limit, replace the older turns by a summary
+	if summarized := s.summarizeHistory(ctx, cfg, resolution.InternalID, unifiedMessages, systemPrompt, unifiedTools); len(summarized) != len(unifiedMessages) {
+		unifiedMessages = summarized
+		promptTokens = converter.EstimateInputTokens(unifiedMessages, systemPrompt, unifiedTools)
+	}
 
 	// Build Kiro payload
-	payload := converter.BuildKiroPayload(
+	_, buildSpan := tracing.StartSpan(ctx, "converter.BuildKiroPayload", tracing.SpanKindInternal)
+	payload, err := converter.BuildKiroPayload(
 		unifiedMessages,
 		systemPrompt,
 		resolution.InternalID,
 		unifiedTools,
-		conversationID,
+		kiroConversationID,
 		s.AuthManager.ProfileArn(),
-		s.Cfg,
+		s.ModelCache.GetMaxInputTokens(resolution.InternalID),
+		cfg,
 	)
+	buildSpan.End()
 
-	if payload == nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": "Failed to build request payload",
-				"type":    "internal_error",
-			},
-		})
+	var contextErr *converter.ContextLengthError
+	if errors.As(err, &contextErr) {
+		return nil, http.StatusBadRequest, anthropicErrorBody(http.StatusBadRequest, "invalid_request_error", contextErr.Error())
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, anthropicErrorBody(http.StatusInternalServerError, "api_error", "Failed to build request payload")
+	}
+
+	// Forward sampling settings and emulate max_tokens/stop_sequences on the response
+	inference, limits := anthropicInferenceSettings(req)
+	applyProfileMaxTokens(cfg, resolution.InternalID, inference, &limits)
+	converter.ApplyInferenceConfig(payload, inference, cfg)
+	limits.ToolNames = payload.ToolNames
+
+	return &anthropicMessage{
+		cfg:            cfg,
+		payload:        payload,
+		conversationID: conversationID,
+		promptTokens:   promptTokens,
+		limits:         limits,
+		prefill:        prefill,
+	}, 0, nil
+}
+
+// CountTokensHandler handles POST /v1/messages/count_tokens (Anthropic-compatible)
+func (s *Server) CountTokensHandler(c *gin.Context) {
+	req, ok := s.bindAnthropicRequest(c)
+	if !ok {
 		return
 	}
 
-	// Build URL
-	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+	unifiedMessages, systemPrompt := converter.ConvertAnthropicToUnified(req)
+	unifiedTools := converter.ConvertAnthropicToolsToUnified(req.Tools)
 
-	// Check if streaming
-	streaming, _ := req["stream"].(bool)
+	inputTokens := converter.EstimateInputTokens(unifiedMessages, systemPrompt, unifiedTools)
 
-	if streaming {
-		s.handleStreamingMessages(c, apiURL, payload, modelName, conversationID)
-	} else {
-		s.handleNonStreamingMessages(c, apiURL, payload, modelName, conversationID)
-	}
-}
-
-func convertAnthropicRequest(req map[string]interface{}) ([]converter.UnifiedMessage, string) {
-	var messages []converter.UnifiedMessage
-	var systemPrompt string
-
-	// Extract system prompt
-	if sys, ok := req["system"]; ok {
-		switch v := sys.(type) {
-		case string:
-			systemPrompt = v
-		case []interface{}:
-			// Handle list of content blocks
-			var parts []string
-			for _, block := range v {
-				if blockMap, ok := block.(map[string]interface{}); ok {
-					if blockMap["type"] == "text" {
-						if text, ok := blockMap["text"].(string); ok {
-							parts = append(parts, text)
-						}
-					}
-				}
-			}
-			systemPrompt = strings.Join(parts, "\n")
-		}
-	}
-
-	// Convert messages
-	if msgList, ok := req["messages"].([]interface{}); ok {
-		for _, msg := range msgList {
-			if msgMap, ok := msg.(map[string]interface{}); ok {
-				role, _ := msgMap["role"].(string)
-				content := msgMap["content"]
-
-				unifiedMsg := converter.UnifiedMessage{
-					Role:    role,
-					Content: content,
-				}
-
-				// Handle tool use in content
-				if contentList, ok := content.([]interface{}); ok {
-					for _, block := range contentList {
-						if blockMap, ok := block.(map[string]interface{}); ok {
-							blockType, _ := blockMap["type"].(string)
-
-							if blockType == "tool_use" {
-								name, _ := blockMap["name"].(string)
-								id, _ := blockMap["id"].(string)
-								input := blockMap["input"]
-
-								var args string
-								if input != nil {
-									b, _ := json.Marshal(input)
-									args = string(b)
-								}
-
-								unifiedMsg.ToolCalls = append(unifiedMsg.ToolCalls, converter.ToolCall{
-									ID:   id,
-									Type: "function",
-									Function: struct {
-										Name      string `json:"name"`
-										Arguments string `json:"arguments"`
-									}{
-										Name:      name,
-										Arguments: args,
-									},
-								})
-							}
-
-							if blockType == "tool_result" {
-								toolUseID, _ := blockMap["tool_use_id"].(string)
-								unifiedMsg.ToolResults = append(unifiedMsg.ToolResults, converter.ToolResult{
-									ToolUseID: toolUseID,
-									Content:   blockMap["content"],
-								})
-							}
-
-							if blockType == "image" {
-								// Extract image
-								if source, ok := blockMap["source"].(map[string]interface{}); ok {
-									if source["type"] == "base64" {
-										mediaType, _ := source["media_type"].(string)
-										data, _ := source["data"].(string)
-										unifiedMsg.Images = append(unifiedMsg.Images, map[string]interface{}{
-											"media_type": mediaType,
-											"data":       data,
-										})
-									}
-								}
-							}
-						}
-					}
-				}
-
-				messages = append(messages, unifiedMsg)
-			}
-		}
+	c.JSON(http.StatusOK, gin.H{
+		"input_tokens": inputTokens,
+	})
+}
+
+// bindAnthropicRequest decodes and validates an Anthropic request, replying with a 400 on failure
+func (s *Server) bindAnthropicRequest(c *gin.Context) (*converter.AnthropicRequest, bool) {
+	var req converter.AnthropicRequest
+	if err := s.bindRequest(c, &req, converter.AnthropicRequestFields); err != nil {
+		anthropicError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid request: %v", err))
+		return nil, false
+	}
+	return &req, true
+}
+
+// anthropicInferenceSettings extracts max_tokens, temperature, top_p and stop_sequences from an Anthropic request
+func anthropicInferenceSettings(req *converter.AnthropicRequest) (*converter.InferenceConfiguration, stream.Limits) {
+	inference := &converter.InferenceConfiguration{
+		Temperature: req.Temperature,
+		TopP:        req.TopP,
+	}
+	limits := stream.Limits{StopSequences: req.StopSequences}
+
+	if req.MaxTokens > 0 {
+		maxTokens := req.MaxTokens
+		inference.MaxTokens = &maxTokens
+		limits.MaxTokens = maxTokens
 	}
 
-	return messages, systemPrompt
+	return inference, limits
 }
 
-func (s *Server) handleStreamingMessages(c *gin.Context, apiURL string, payload *converter.KiroPayload, model, conversationID string) {
-	ctx := context.Background()
-	resp, err := s.HttpClient.PostStream(ctx, apiURL, payload)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": fmt.Sprintf("Request failed: %v", err),
-				"type":    "internal_error",
-			},
-		})
-		return
+// applyProfileMaxTokens sets the output budget of inference and limits from the
+// client's max tokens and the model's profile (see converter.ProfileMaxTokens)
+func applyProfileMaxTokens(cfg *config.Config, modelID string, inference *converter.InferenceConfiguration, limits *stream.Limits) {
+	inference.MaxTokens = converter.ProfileMaxTokens(cfg, modelID, inference.MaxTokens)
+	if inference.MaxTokens != nil {
+		limits.MaxTokens = *inference.MaxTokens
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{
-			"error": gin.H{
-				"message": string(body),
-				"type":    "api_error",
-			},
-		})
+func (s *Server) handleStreamingMessages(c *gin.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, model, conversationID string, promptTokens int, limits stream.Limits, prefill string) {
+	// A failed write to the client cancels the request
+	ctx, cancel := context.WithCancel(c.Request.Context())
+	defer cancel()
+	resp, err := s.postStream(ctx, cfg, apiURL, payload)
+	if err != nil {
+		// Anthropic error types follow from the status
+		status, _ := requestFailedStatus(err)
+		anthropicError(c, status, "", requestFailedMessage(err))
 		return
 	}
+	defer resp.Body.Close()
 
 	// Set SSE headers
 	c.Header("Content-Type", "text/event-stream")
 	c.Header("Cache-Control", "no-cache")
 	c.Header("Connection", "keep-alive")
+	announceStreamTrailers(c)
 
-	flusher, ok := c.Writer.(http.Flusher)
+	w, ok := newSSEWriter(c, cfg, cancel)
 	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": "Streaming not supported",
-				"type":    "internal_error",
-			},
-		})
+		anthropicError(c, http.StatusInternalServerError, "api_error", "Streaming not supported")
 		return
 	}
 
 	// Stream in Anthropic format
-	events, errs := stream.ParseKiroStream(resp, s.Cfg.FirstTokenTimeout, true, s.Cfg)
-
-	// Send message_start event
-	messageStart := map[string]interface{}{
-		"type": "message_start",
-		"message": map[string]interface{}{
-			"id":         conversationID,
-			"type":       "message",
-			"role":       "assistant",
-			"content":    []interface{}{},
-			"model":      model,
-			"stop_reason": nil,
-			"usage": map[string]interface{}{
-				"input_tokens":  0,
-				"output_tokens": 0,
-			},
-		},
-	}
-	b, _ := json.Marshal(messageStart)
-	c.Writer.WriteString("event: message_start\ndata: " + string(b) + "\n\n")
-	flusher.Flush()
-
-	// Track content blocks
-	contentIndex := 0
-	textBlockStarted := false
-	thinkingBlockStarted := false
-	var textBlockIndex int
-	var thinkingBlockIndex int
-	var outputTokens int
-
-	for {
-		select {
-		case event, ok := <-events:
-			if !ok {
-				// Close any open blocks
-				if thinkingBlockStarted {
-					c.Writer.WriteString(fmt.Sprintf("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n", thinkingBlockIndex))
-					flusher.Flush()
-				}
-				if textBlockStarted {
-					c.Writer.WriteString(fmt.Sprintf("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n", textBlockIndex))
-					flusher.Flush()
-				}
-
-				// Send message_delta with final usage
-				messageDelta := map[string]interface{}{
-					"type": "message_delta",
-					"delta": map[string]interface{}{
-						"stop_reason": "end_turn",
-					},
-					"usage": map[string]interface{}{
-						"output_tokens": outputTokens,
-					},
-				}
-				b, _ := json.Marshal(messageDelta)
-				c.Writer.WriteString("event: message_delta\ndata: " + string(b) + "\n\n")
-				flusher.Flush()
-
-				// Send message_stop
-				c.Writer.WriteString("event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
-				flusher.Flush()
-				return
-			}
+	events := stream.StreamToAnthropic(ctx, resp, model, conversationID, cfg.FirstTokenTimeout, true, cfg, s.ModelCache, promptTokens, limits, prefill)
+	events = stream.WithKeepAlive(events, keepAliveInterval(cfg), stream.AnthropicKeepAlive)
 
-			switch event.Type {
-			case "content":
-				if event.Content != "" {
-					// Start text block if not started
-					if !textBlockStarted {
-						textBlockIndex = contentIndex
-						contentIndex++
-						textBlockStarted = true
-						startBlock := map[string]interface{}{
-							"type":  "content_block_start",
-							"index": textBlockIndex,
-							"content_block": map[string]interface{}{
-								"type": "text",
-								"text": "",
-							},
-						}
-						b, _ := json.Marshal(startBlock)
-						c.Writer.WriteString("event: content_block_start\ndata: " + string(b) + "\n\n")
-						flusher.Flush()
-					}
-
-					// Send text delta
-					contentBlock := map[string]interface{}{
-						"type":  "content_block_delta",
-						"index": textBlockIndex,
-						"delta": map[string]interface{}{
-							"type": "text_delta",
-							"text": event.Content,
-						},
-					}
-					b, _ := json.Marshal(contentBlock)
-					c.Writer.WriteString("event: content_block_delta\ndata: " + string(b) + "\n\n")
-					flusher.Flush()
-					outputTokens += len(event.Content) / 4
-				}
-
-			case "thinking":
-				if event.ThinkingContent != "" {
-					// Start thinking block if not started
-					if !thinkingBlockStarted {
-						thinkingBlockIndex = contentIndex
-						contentIndex++
-						thinkingBlockStarted = true
-						startBlock := map[string]interface{}{
-							"type":  "content_block_start",
-							"index": thinkingBlockIndex,
-							"content_block": map[string]interface{}{
-								"type":     "thinking",
-								"thinking": "",
-							},
-						}
-						b, _ := json.Marshal(startBlock)
-						c.Writer.WriteString("event: content_block_start\ndata: " + string(b) + "\n\n")
-						flusher.Flush()
-					}
-
-					// Send thinking delta
-					contentBlock := map[string]interface{}{
-						"type":  "content_block_delta",
-						"index": thinkingBlockIndex,
-						"delta": map[string]interface{}{
-							"type":     "thinking_delta",
-							"thinking": event.ThinkingContent,
-						},
-					}
-					b, _ := json.Marshal(contentBlock)
-					c.Writer.WriteString("event: content_block_delta\ndata: " + string(b) + "\n\n")
-					flusher.Flush()
-					outputTokens += len(event.ThinkingContent) / 4
-				}
-
-			case "tool_use":
-				if event.ToolUse != nil {
-					// Close thinking block if open
-					if thinkingBlockStarted {
-						c.Writer.WriteString(fmt.Sprintf("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n", thinkingBlockIndex))
-						flusher.Flush()
-						thinkingBlockStarted = false
-					}
-
-					// Close text block if open
-					if textBlockStarted {
-						c.Writer.WriteString(fmt.Sprintf("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n", textBlockIndex))
-						flusher.Flush()
-						textBlockStarted = false
-					}
-
-					tool := event.ToolUse
-					toolID, _ := tool["id"].(string)
-					if toolID == "" {
-						toolID = utils.GenerateToolUseID()
-					}
-
-					// Get tool name and input
-					var toolName string
-					var toolInput interface{}
-
-					if fn, ok := tool["function"].(map[string]interface{}); ok {
-						toolName, _ = fn["name"].(string)
-						toolInput = fn["arguments"]
-					} else if name, ok := tool["name"].(string); ok {
-						toolName = name
-						toolInput = tool["input"]
-					}
-
-					// Parse input if string
-					if inputStr, ok := toolInput.(string); ok && inputStr != "" {
-						var parsed interface{}
-						if err := json.Unmarshal([]byte(inputStr), &parsed); err == nil {
-							toolInput = parsed
-						}
-					}
-					if toolInput == nil {
-						toolInput = map[string]interface{}{}
-					}
-
-					// Send content_block_start for tool_use
-					toolBlockIndex := contentIndex
-					contentIndex++
-					startBlock := map[string]interface{}{
-						"type":  "content_block_start",
-						"index": toolBlockIndex,
-						"content_block": map[string]interface{}{
-							"type":  "tool_use",
-							"id":    toolID,
-							"name":  toolName,
-							"input": map[string]interface{}{},
-						},
-					}
-					b, _ := json.Marshal(startBlock)
-					c.Writer.WriteString("event: content_block_start\ndata: " + string(b) + "\n\n")
-					flusher.Flush()
-
-					// Send content_block_delta with input_json_delta
-					inputJSON, _ := json.Marshal(toolInput)
-					deltaBlock := map[string]interface{}{
-						"type":  "content_block_delta",
-						"index": toolBlockIndex,
-						"delta": map[string]interface{}{
-							"type":         "input_json_delta",
-							"partial_json": string(inputJSON),
-						},
-					}
-					b, _ = json.Marshal(deltaBlock)
-					c.Writer.WriteString("event: content_block_delta\ndata: " + string(b) + "\n\n")
-					flusher.Flush()
-
-					// Send content_block_stop
-					c.Writer.WriteString(fmt.Sprintf("event: content_block_stop\ndata: {\"type\":\"content_block_stop\",\"index\":%d}\n\n", toolBlockIndex))
-					flusher.Flush()
-
-					outputTokens += len(toolName) / 2
-				}
-
-			case "context_usage":
-				// Context usage info - used for token calculation
-				// Will be used in message_delta if available
-			}
+	writeEvents(ctx, w, events)
+	writeStreamTrailers(c, model)
+}
 
-		case err := <-errs:
-			if err != nil {
-				errorBlock := map[string]interface{}{
-					"type": "error",
-					"error": map[string]interface{}{
-						"type":    "internal_error",
-						"message": err.Error(),
-					},
-				}
-				b, _ := json.Marshal(errorBlock)
-				c.Writer.WriteString("event: error\ndata: " + string(b) + "\n\n")
-				flusher.Flush()
-				return
-			}
-		}
+func (s *Server) handleNonStreamingMessages(c *gin.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, model, conversationID string, promptTokens int, limits stream.Limits, prefill, cacheKey string) {
+	response, status, errBody := s.createMessage(c.Request.Context(), cfg, apiURL, payload, model, conversationID, promptTokens, limits, prefill)
+	if response == nil {
+		c.JSON(status, errBody)
+		return
 	}
+	s.writeCachedJSON(c, cacheKey, response)
 }
 
-func (s *Server) handleNonStreamingMessages(c *gin.Context, apiURL string, payload *converter.KiroPayload, model, conversationID string) {
-	ctx := context.Background()
-	resp, err := s.HttpClient.PostStream(ctx, apiURL, payload)
+// createMessage sends the payload and builds the Anthropic message from the full
+// response, starting with the client's prefill if any. On failure it returns nil
+// with the HTTP status and error body.
+func (s *Server) createMessage(ctx context.Context, cfg *config.Config, apiURL string, payload *converter.KiroPayload, model, conversationID string, promptTokens int, limits stream.Limits, prefill string) (map[string]interface{}, int, gin.H) {
+	resp, err := s.postStream(ctx, cfg, apiURL, payload)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": fmt.Sprintf("Request failed: %v", err),
-				"type":    "internal_error",
-			},
-		})
-		return
+		status, _ := requestFailedStatus(err)
+		return nil, status, anthropicErrorBody(status, "", requestFailedMessage(err))
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		c.JSON(resp.StatusCode, gin.H{
-			"error": gin.H{
-				"message": string(body),
-				"type":    "api_error",
-			},
-		})
-		return
-	}
-
 	// Collect stream result
-	result, err := stream.CollectStreamResult(resp, s.Cfg.FirstTokenTimeout, true, s.Cfg)
+	result, err := stream.CollectStreamResult(ctx, resp, cfg.FirstTokenTimeout, true, cfg, limits)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": gin.H{
-				"message": fmt.Sprintf("Stream processing failed: %v", err),
-				"type":    "internal_error",
-			},
-		})
-		return
+		status, _, message := streamFailedStatus(err)
+		return nil, status, anthropicErrorBody(status, "api_error", message)
 	}
+	result = s.continueTruncated(ctx, cfg, apiURL, payload, limits, result)
 
 	// Build Anthropic-style response
 	var content []map[string]interface{}
 
-	if result.Content != "" {
+	// Thinking comes first, as with extended thinking, following the same
+	// FAKE_REASONING_HANDLING rule as streams
+	text, thinking := replyContent(cfg, result)
+	if thinking != "" {
+		content = append(content, map[string]interface{}{
+			"type":      "thinking",
+			"thinking":  thinking,
+			"signature": stream.AnthropicThinkingSignature(),
+		})
+	}
+
+	if text := prefill + text; text != "" {
 		content = append(content, map[string]interface{}{
 			"type": "text",
-			"text": result.Content,
+			"text": text,
 		})
 	}
 
@@ -827,20 +1535,49 @@ func (s *Server) handleNonStreamingMessages(c *gin.Context, apiURL string, paylo
 		})
 	}
 
+	// Calculate token usage
+	outputTokens := stream.CountCompletionTokens(result.Content, result.ThinkingContent, result.ToolCalls)
+	inputTokens, _, _, _ := stream.CalculateTokensFromContextUsage(
+		result.ContextUsagePercentage,
+		outputTokens,
+		promptTokens,
+		s.ModelCache,
+		model,
+	)
+	usage.FromContext(ctx).AddTokens(inputTokens, outputTokens)
+	convstore.FromContext(ctx).SetReply(result.Content, result.ToolCalls)
+	transcript.FromContext(ctx).SetReply(prefill+text, thinking, result.ToolCalls)
+
 	response := map[string]interface{}{
 		"id":    conversationID,
 		"type":  "message",
 		"role":  "assistant",
 		"model": model,
 		"content": content,
-		"stop_reason": "end_turn",
-		"usage": map[string]interface{}{
-			"input_tokens":  0,
-			"output_tokens": len(result.Content) / 4,
-		},
+		"stop_reason": stream.AnthropicStopReason(result.StopReason),
+		"stop_sequence": nilIfEmpty(result.StopSequence),
+		"usage": stream.AnthropicUsage(inputTokens, outputTokens),
+	}
+	if metadata := anthropicKiroMetadata(ctx, model); metadata != nil {
+		response["metadata"] = metadata
 	}
 
-	c.JSON(http.StatusOK, response)
+	return response, 0, nil
+}
+
+// nilIfEmpty returns nil for an empty string so it serializes as JSON null
+func nilIfEmpty(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
+
+// replyContent returns the answer and the reasoning of a collected completion,
+// such as an OpenAI reasoning_content or an Anthropic thinking block, as
+// FAKE_REASONING_HANDLING sends them
+func replyContent(cfg *config.Config, result *stream.StreamResult) (string, string) {
+	return stream.SplitThinking(parser.ThinkingHandlingMode(cfg.FakeReasoningHandling), result.Content, result.ThinkingContent)
 }
 
 // convertParserToolCalls converts parser.ToolCall to converter.ToolCall
@@ -3,15 +3,25 @@ package api
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"kiro-go-proxy/auth"
+	"kiro-go-proxy/client/clienttest"
 	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/fixtures"
+	"kiro-go-proxy/model"
+	"kiro-go-proxy/usage"
 )
 
 func init() {
@@ -98,6 +108,192 @@ func TestAuthMiddleware(t *testing.T) {
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
+
+	t.Run("accepts x-api-key header", func(t *testing.T) {
+		_, router := newTestServer("test-api-key")
+
+		for _, path := range []string{"/v1/models", "/v1/messages/count_tokens"} {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", path, nil)
+			req.Header.Set("x-api-key", "test-api-key")
+			req.Header.Set("anthropic-version", "2023-06-01")
+			router.ServeHTTP(w, req)
+
+			assert.NotEqual(t, http.StatusUnauthorized, w.Code, path)
+		}
+	})
+
+	t.Run("returns Anthropic errors on messages routes", func(t *testing.T) {
+		_, router := newTestServer("test-api-key")
+
+		tests := []struct {
+			name    string
+			apiKey  string
+			message string
+		}{
+			{"missing key", "", "x-api-key header is required"},
+			{"invalid key", "wrong-key", "invalid x-api-key"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				w := httptest.NewRecorder()
+				req, _ := http.NewRequest("POST", "/v1/messages", strings.NewReader(`{}`))
+				if tt.apiKey != "" {
+					req.Header.Set("x-api-key", tt.apiKey)
+				}
+				router.ServeHTTP(w, req)
+
+				assert.Equal(t, http.StatusUnauthorized, w.Code)
+				var body map[string]interface{}
+				json.Unmarshal(w.Body.Bytes(), &body)
+				assert.Equal(t, "error", body["type"])
+				errBody := body["error"].(map[string]interface{})
+				assert.Equal(t, "authentication_error", errBody["type"])
+				assert.Equal(t, tt.message, errBody["message"])
+			})
+		}
+	})
+
+	t.Run("keeps OpenAI errors on OpenAI routes", func(t *testing.T) {
+		_, router := newTestServer("test-api-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{}`))
+		req.Header.Set("x-api-key", "wrong-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		var body map[string]interface{}
+		json.Unmarshal(w.Body.Bytes(), &body)
+		assert.NotContains(t, body, "type")
+		assert.Equal(t, "invalid_request_error", body["error"].(map[string]interface{})["type"])
+	})
+}
+
+// =============================================================================
+// TestAuthLockout
+// =============================================================================
+
+func TestAuthLockout(t *testing.T) {
+	get := func(router *gin.Engine, path, key, ip string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		req.RemoteAddr = ip + ":12345"
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("locks out an IP after repeated failures", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.AuthMaxFailures = 3
+		server.Cfg.AuthFailureWindow = 300
+		server.Cfg.AuthLockoutDuration = 900
+		hook := logtest.NewGlobal()
+		defer hook.Reset()
+
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusUnauthorized, get(router, "/v1/models", "wrong-key", "203.0.113.7").Code)
+		}
+
+		// Even the right key is refused while locked out
+		w := get(router, "/v1/models", "test-key", "203.0.113.7")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "900", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "Too many failed authentication attempts")
+
+		// Other clients are unaffected
+		assert.Equal(t, http.StatusOK, get(router, "/v1/models", "test-key", "198.51.100.2").Code)
+
+		failures := 0
+		for _, e := range hook.AllEntries() {
+			if e.Data["client_ip"] == "203.0.113.7" && strings.Contains(e.Message, "Authentication failure from 203.0.113.7") {
+				failures++
+			}
+		}
+		assert.Equal(t, 3, failures)
+	})
+
+	t.Run("successful authentication resets failures", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.AuthMaxFailures = 2
+		server.Cfg.AuthFailureWindow = 300
+		server.Cfg.AuthLockoutDuration = 900
+
+		get(router, "/v1/models", "wrong-key", "203.0.113.7")
+		get(router, "/v1/models", "test-key", "203.0.113.7")
+		get(router, "/v1/models", "wrong-key", "203.0.113.7")
+		assert.Equal(t, http.StatusOK, get(router, "/v1/models", "test-key", "203.0.113.7").Code)
+	})
+
+	t.Run("admin key failures count too", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.AdminAPIKey = "admin-key"
+		server.Cfg.AuthMaxFailures = 1
+		server.Cfg.AuthFailureWindow = 300
+		server.Cfg.AuthLockoutDuration = 60
+
+		assert.Equal(t, http.StatusUnauthorized, get(router, "/admin/auth", "wrong-key", "203.0.113.7").Code)
+		assert.Equal(t, http.StatusTooManyRequests, get(router, "/v1/models", "test-key", "203.0.113.7").Code)
+	})
+}
+
+// =============================================================================
+// TestAuthExemptions
+// =============================================================================
+
+func TestAuthExemptions(t *testing.T) {
+	get := func(router *gin.Engine, path, key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		if key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("exempt paths are open without a key", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.AuthExemptPaths = []string{"/v1/models"}
+
+		assert.Equal(t, http.StatusOK, get(router, "/v1/models", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, get(router, "/v1/models/claude-haiku-4.5", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, get(router, "/v1/usage", "").Code)
+	})
+
+	t.Run("matches registered paths and prefixes", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-haiku-4.5"}})
+		server.Cfg.AuthExemptPaths = []string{"/v1/models/:id"}
+		assert.Equal(t, http.StatusOK, get(router, "/v1/models/claude-haiku-4.5", "").Code)
+
+		server.Cfg.AuthExemptPaths = []string{"/v1/models*"}
+		assert.Equal(t, http.StatusOK, get(router, "/v1/models", "").Code)
+		assert.Equal(t, http.StatusOK, get(router, "/v1/models/claude-haiku-4.5", "").Code)
+	})
+
+	t.Run("HEALTH_AUTH protects health routes", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.HealthAuth = true
+
+		assert.Equal(t, http.StatusUnauthorized, get(router, "/health", "").Code)
+		assert.Equal(t, http.StatusUnauthorized, get(router, "/", "").Code)
+		assert.Equal(t, http.StatusOK, get(router, "/health", "test-key").Code)
+		// Kubernetes probes stay open
+		assert.Equal(t, http.StatusOK, get(router, "/livez", "").Code)
+	})
+}
+
+// =============================================================================
+// TestKeysEqual
+// =============================================================================
+
+func TestKeysEqual(t *testing.T) {
+	assert.True(t, keysEqual("test-key", "test-key"))
+	assert.False(t, keysEqual("test-key", "test-kez"))
+	assert.False(t, keysEqual("test-key", "test-key-longer"))
+	assert.False(t, keysEqual("", "test-key"))
 }
 
 // =============================================================================
@@ -168,6 +364,119 @@ func TestListModelsHandler(t *testing.T) {
 
 		assert.Equal(t, http.StatusUnauthorized, w.Code)
 	})
+
+	listModels := func(t *testing.T, query string) (int, converter.OpenAIModelsResponse, []string) {
+		server, router := newTestServer("test-key")
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-haiku-4.5"}, {ModelID: "claude-opus-4.5"}, {ModelID: "claude-sonnet-4.5"}})
+		server.ModelResolver.SetAlias("experimental", "claude-sonnet-9")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/models"+query, nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		var resp converter.OpenAIModelsResponse
+		json.Unmarshal(w.Body.Bytes(), &resp)
+		var ids []string
+		for _, data := range resp.Data {
+			ids = append(ids, data.ID)
+		}
+		return w.Code, resp, ids
+	}
+
+	t.Run("filters by family", func(t *testing.T) {
+		code, _, ids := listModels(t, "?family=Sonnet")
+
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, []string{"claude-sonnet-4.5", "experimental"}, ids)
+	})
+
+	t.Run("hides passthrough names", func(t *testing.T) {
+		_, _, ids := listModels(t, "?verified=true")
+		assert.Equal(t, []string{"claude-haiku-4.5", "claude-opus-4.5", "claude-sonnet-4.5"}, ids)
+
+		_, _, ids = listModels(t, "?verified=false")
+		assert.Equal(t, []string{"experimental"}, ids)
+	})
+
+	t.Run("pages with limit and after", func(t *testing.T) {
+		_, resp, ids := listModels(t, "?limit=2")
+		assert.Equal(t, []string{"claude-haiku-4.5", "claude-opus-4.5"}, ids)
+		assert.True(t, resp.HasMore)
+		assert.Equal(t, "claude-haiku-4.5", resp.FirstID)
+		assert.Equal(t, "claude-opus-4.5", resp.LastID)
+
+		_, resp, ids = listModels(t, "?limit=2&after="+resp.LastID)
+		assert.Equal(t, []string{"claude-sonnet-4.5", "experimental"}, ids)
+		assert.False(t, resp.HasMore)
+	})
+
+	t.Run("rejects invalid parameters", func(t *testing.T) {
+		for _, query := range []string{"?limit=0", "?limit=x", "?verified=maybe", "?after=no-such-model"} {
+			code, _, _ := listModels(t, query)
+			assert.Equal(t, http.StatusBadRequest, code, query)
+		}
+	})
+}
+
+// =============================================================================
+// TestGetModelHandler
+// =============================================================================
+
+func TestGetModelHandler(t *testing.T) {
+	t.Run("returns model details", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-haiku-4.5"}})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/models/claude-haiku-4.5", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "claude-haiku-4.5", body["id"])
+		assert.Equal(t, float64(200000), body["context_window"])
+		assert.Equal(t, true, body["supports_tools"])
+	})
+
+	t.Run("shows the effective model profile", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-haiku-4.5"}})
+		thinking := true
+		server.Cfg.ModelProfiles = map[string]config.ModelProfile{
+			"claude-haiku-*": {MaxOutputTokens: 4096, Thinking: &thinking, DisableImages: true},
+		}
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/models/claude-haiku-4.5", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body struct {
+			MaxOutputTokens int                           `json:"max_output_tokens"`
+			Profile         *converter.OpenAIModelProfile `json:"profile"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, 64000, body.MaxOutputTokens)
+		assert.Equal(t, &converter.OpenAIModelProfile{Match: "claude-haiku-*", MaxOutputTokens: 4096, Thinking: true}, body.Profile)
+	})
+
+	t.Run("returns 404 with suggestions", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-haiku-4.5"}})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/models/claude-haiku-9", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "model_not_found")
+		assert.Contains(t, w.Body.String(), "claude-haiku-4.5")
+	})
 }
 
 // =============================================================================
@@ -191,6 +500,101 @@ func TestErrorFormat(t *testing.T) {
 	})
 }
 
+// =============================================================================
+// TestAnthropicErrorFormat
+// =============================================================================
+
+func TestAnthropicErrorFormat(t *testing.T) {
+	body := `{"model": "claude-sonnet-4.5", "max_tokens": 10, "messages": [{"role": "user", "content": "Hi"}]}`
+
+	send := func(router http.Handler, body string) (int, map[string]interface{}) {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/messages", strings.NewReader(body))
+		req.Header.Set("x-api-key", "test-key")
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		var parsed map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &parsed))
+		return w.Code, parsed
+	}
+	assertAnthropicError := func(t *testing.T, parsed map[string]interface{}, errType string) {
+		assert.Equal(t, "error", parsed["type"])
+		errBody := parsed["error"].(map[string]interface{})
+		assert.Equal(t, errType, errBody["type"])
+		assert.NotEmpty(t, errBody["message"])
+	}
+
+	upstream := []struct {
+		name    string
+		status  int
+		want    int
+		errType string
+	}{
+		{"bad request", http.StatusBadRequest, http.StatusBadRequest, "invalid_request_error"},
+		{"forbidden", http.StatusForbidden, http.StatusBadGateway, "api_error"},
+		{"rate limited", http.StatusTooManyRequests, http.StatusTooManyRequests, "rate_limit_error"},
+		{"server error", http.StatusInternalServerError, http.StatusInternalServerError, "api_error"},
+		{"unavailable", http.StatusServiceUnavailable, http.StatusServiceUnavailable, "overloaded_error"},
+	}
+	for _, tt := range upstream {
+		for _, streaming := range []bool{false, true} {
+			name := fmt.Sprintf("upstream %s (stream=%v)", tt.name, streaming)
+			t.Run(name, func(t *testing.T) {
+				server, router := newTestServer("test-key")
+				server.HttpClient = clienttest.NewFake(clienttest.Response{StatusCode: tt.status, Body: "upstream failed"})
+
+				reqBody := body
+				if streaming {
+					reqBody = strings.Replace(body, `"max_tokens"`, `"stream": true, "max_tokens"`, 1)
+				}
+				status, parsed := send(router, reqBody)
+
+				assert.Equal(t, tt.want, status)
+				assertAnthropicError(t, parsed, tt.errType)
+			})
+		}
+	}
+
+	t.Run("quota exceeded", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.QuotaRequests = 1
+		server.Usage.Record("test-key", "claude-sonnet-4", usage.Totals{Requests: 1})
+
+		status, parsed := send(router, body)
+
+		assert.Equal(t, http.StatusTooManyRequests, status)
+		assertAnthropicError(t, parsed, "rate_limit_error")
+	})
+
+	t.Run("rate limited", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.RateLimitRPM = 1
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+
+		send(router, body)
+		status, parsed := send(router, body)
+
+		assert.Equal(t, http.StatusTooManyRequests, status)
+		assertAnthropicError(t, parsed, "rate_limit_error")
+	})
+
+	t.Run("invalid request", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		status, parsed := send(router, `{"model": "claude-sonnet-4.5", "messages": []}`)
+
+		assert.Equal(t, http.StatusBadRequest, status)
+		assertAnthropicError(t, parsed, "invalid_request_error")
+	})
+
+	t.Run("replaces non-Anthropic error types", func(t *testing.T) {
+		assert.Equal(t, "api_error", anthropicErrorBody(http.StatusInternalServerError, "internal_error", "x")["error"].(gin.H)["type"])
+		assert.Equal(t, "overloaded_error", anthropicErrorBody(http.StatusServiceUnavailable, "service_unavailable", "x")["error"].(gin.H)["type"])
+		assert.Equal(t, "authentication_error", anthropicErrorBody(http.StatusUnauthorized, "authentication_error", "x")["error"].(gin.H)["type"])
+	})
+}
+
 // =============================================================================
 // TestChatCompletionsValidation
 // Original: /code/github/kiro-gateway/tests/unit/test_routes_openai.py::TestChatCompletionsValidation
@@ -658,3 +1062,811 @@ func TestRouterIntegration(t *testing.T) {
 		assert.NotEqual(t, http.StatusMethodNotAllowed, w.Code)
 	})
 }
+
+// =============================================================================
+// TestCountTokensHandler
+// Tests for Anthropic count_tokens endpoint
+// =============================================================================
+
+func TestCountTokensHandler(t *testing.T) {
+	t.Run("returns input_tokens", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		body := `{"model": "claude-sonnet-4.5", "system": "Be brief.", "messages": [{"role": "user", "content": "Hello, world!"}]}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		assert.Greater(t, resp["input_tokens"].(float64), float64(0))
+	})
+
+	t.Run("tools increase token count", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		count := func(body string) float64 {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader(body))
+			req.Header.Set("Authorization", "Bearer test-key")
+			req.Header.Set("Content-Type", "application/json")
+			router.ServeHTTP(w, req)
+
+			var resp map[string]interface{}
+			json.Unmarshal(w.Body.Bytes(), &resp)
+			return resp["input_tokens"].(float64)
+		}
+
+		without := count(`{"model": "claude-sonnet-4.5", "messages": [{"role": "user", "content": "Hi"}]}`)
+		with := count(`{"model": "claude-sonnet-4.5", "messages": [{"role": "user", "content": "Hi"}],
+			"tools": [{"name": "get_weather", "description": "Get weather", "input_schema": {"type": "object"}}]}`)
+
+		assert.Greater(t, with, without)
+	})
+
+	t.Run("rejects invalid JSON", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader("not json"))
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("rejects invalid request", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		body := `{"model": "claude-sonnet-4.5", "messages": [{"role": "system", "content": "Hi"}]}`
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "messages.0.role")
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader("{}"))
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// =============================================================================
+// TestAccountsStatusHandler
+// Tests for credential pool status endpoint
+// =============================================================================
+
+func TestAccountsStatusHandler(t *testing.T) {
+	t.Run("returns account health", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/accounts", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp map[string]interface{}
+		err := json.Unmarshal(w.Body.Bytes(), &resp)
+		assert.NoError(t, err)
+		accounts := resp["accounts"].([]interface{})
+		assert.Len(t, accounts, 1)
+		assert.Equal(t, true, accounts[0].(map[string]interface{})["healthy"])
+	})
+
+	t.Run("requires authentication", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/accounts", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+}
+
+// =============================================================================
+// TestAnthropicInferenceSettings
+// =============================================================================
+
+func TestAnthropicInferenceSettings(t *testing.T) {
+	t.Run("extracts limits and sampling params", func(t *testing.T) {
+		temperature, topP := 0.5, 0.9
+		req := &converter.AnthropicRequest{
+			MaxTokens:     256,
+			Temperature:   &temperature,
+			TopP:          &topP,
+			StopSequences: []string{"\n\nHuman:"},
+		}
+
+		inference, limits := anthropicInferenceSettings(req)
+
+		assert.Equal(t, 256, *inference.MaxTokens)
+		assert.Equal(t, 0.5, *inference.Temperature)
+		assert.Equal(t, 0.9, *inference.TopP)
+		assert.Equal(t, 256, limits.MaxTokens)
+		assert.Equal(t, []string{"\n\nHuman:"}, limits.StopSequences)
+	})
+
+	t.Run("handles missing params", func(t *testing.T) {
+		inference, limits := anthropicInferenceSettings(&converter.AnthropicRequest{})
+
+		assert.True(t, inference.IsEmpty())
+		assert.Equal(t, 0, limits.MaxTokens)
+		assert.Nil(t, limits.StopSequences)
+	})
+}
+
+// =============================================================================
+// TestDebugCaptureMiddleware
+// =============================================================================
+
+func TestDebugCaptureMiddleware(t *testing.T) {
+	t.Run("captures failed request and response", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.DebugMode = "errors"
+		server.Cfg.DebugDir = t.TempDir()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{invalid`))
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		entries, err := os.ReadDir(server.Cfg.DebugDir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		captureDir := filepath.Join(server.Cfg.DebugDir, entries[0].Name())
+		request, _ := os.ReadFile(filepath.Join(captureDir, "client_request.json"))
+		response, _ := os.ReadFile(filepath.Join(captureDir, "client_response.txt"))
+		assert.Equal(t, `{invalid`, string(request))
+		assert.Contains(t, string(response), "invalid_request_error")
+	})
+
+	t.Run("writes nothing when disabled", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.DebugMode = "off"
+		server.Cfg.DebugDir = t.TempDir()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{invalid`))
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		entries, _ := os.ReadDir(server.Cfg.DebugDir)
+		assert.Empty(t, entries)
+	})
+}
+
+// =============================================================================
+// TestRateLimitMiddleware
+// =============================================================================
+
+func TestRateLimitMiddleware(t *testing.T) {
+	t.Run("returns 429 with Retry-After when over the rate", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.RateLimitRPM = 1
+
+		send := func() *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer test-key")
+			router.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.NotEqual(t, http.StatusTooManyRequests, send().Code)
+
+		w := send()
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "60", w.Header().Get("Retry-After"))
+		assert.Contains(t, w.Body.String(), "rate_limit_error")
+	})
+
+	t.Run("limits each key of PROXY_API_KEYS on its own", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.ProxyAPIKeys = []string{"team-key"}
+		server.Cfg.RateLimitKeys = map[string]config.RateLimit{"team-key": {RPM: 1}}
+
+		send := func(apiKey string) int {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer "+apiKey)
+			router.ServeHTTP(w, req)
+			return w.Code
+		}
+
+		assert.NotEqual(t, http.StatusUnauthorized, send("team-key"))
+		assert.Equal(t, http.StatusTooManyRequests, send("team-key"))
+		assert.NotEqual(t, http.StatusTooManyRequests, send("test-key"))
+		assert.NotEqual(t, http.StatusTooManyRequests, send("test-key"))
+		assert.Equal(t, http.StatusUnauthorized, send("other-key"))
+	})
+
+	t.Run("does not limit when disabled", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		for i := 0; i < 5; i++ {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader(`{}`))
+			req.Header.Set("Authorization", "Bearer test-key")
+			router.ServeHTTP(w, req)
+			assert.NotEqual(t, http.StatusTooManyRequests, w.Code)
+		}
+	})
+}
+
+// =============================================================================
+// TestUsage
+// =============================================================================
+
+func TestUsage(t *testing.T) {
+	t.Run("rejects requests over quota", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.QuotaRequests = 1
+		server.Usage.Record("test-key", "claude-sonnet-4", usage.Totals{Requests: 1})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Contains(t, w.Body.String(), "insufficient_quota")
+		assert.Contains(t, w.Body.String(), "1/1 requests")
+	})
+
+	t.Run("does not count requests that never reach Kiro", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/messages", strings.NewReader(`{invalid`))
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Equal(t, 0, server.Usage.Total("test-key").Requests)
+	})
+
+	t.Run("reports usage for the calling key", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.QuotaTokens = 1000
+		server.Usage.Record("test-key", "claude-sonnet-4", usage.Totals{Requests: 2, PromptTokens: 30, CompletionTokens: 12, Credits: 1})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/v1/usage", nil)
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			KeyID  string                  `json:"key_id"`
+			Total  usage.Totals            `json:"total"`
+			Models map[string]usage.Totals `json:"models"`
+			Quota  map[string]int          `json:"quota"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, usage.KeyID("test-key"), body.KeyID)
+		assert.Equal(t, 42, body.Total.Tokens())
+		assert.Equal(t, 2, body.Models["claude-sonnet-4"].Requests)
+		assert.Equal(t, 1000, body.Quota["tokens"])
+	})
+}
+
+// =============================================================================
+// TestRequestLogMiddleware
+// =============================================================================
+
+func TestRequestLogMiddleware(t *testing.T) {
+	t.Run("generates and returns a request ID", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/health", nil)
+		router.ServeHTTP(w, req)
+
+		assert.Len(t, w.Header().Get("X-Request-Id"), 36)
+	})
+
+	t.Run("echoes client request ID", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/health", nil)
+		req.Header.Set("X-Request-Id", "client-id-123")
+		router.ServeHTTP(w, req)
+
+		assert.Equal(t, "client-id-123", w.Header().Get("X-Request-Id"))
+	})
+
+	t.Run("logs structured fields", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+		hook := logtest.NewGlobal()
+		defer hook.Reset()
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/messages/count_tokens", strings.NewReader(`{}`))
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set("X-Request-Id", "log-test")
+		router.ServeHTTP(w, req)
+
+		var entry map[string]interface{}
+		for _, e := range hook.AllEntries() {
+			if e.Data["request_id"] == "log-test" {
+				entry = e.Data
+			}
+		}
+		assert.NotNil(t, entry)
+		assert.Equal(t, "POST", entry["method"])
+		assert.Equal(t, "/v1/messages/count_tokens", entry["path"])
+		assert.Equal(t, w.Code, entry["status"])
+		assert.Contains(t, entry, "duration_ms")
+	})
+
+	t.Run("logs slow requests with conversation ID and payload sizes", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.SlowRequestThreshold = 0.000001
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+		hook := logtest.NewGlobal()
+		defer hook.Reset()
+
+		body := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`
+		w := postJSON(router, "/v1/chat/completions", body)
+
+		var slow *log.Entry
+		for _, e := range hook.AllEntries() {
+			if e.Message == "Slow request" {
+				slow = e
+			}
+		}
+		assert.NotNil(t, slow)
+		assert.Equal(t, log.WarnLevel, slow.Level)
+		assert.NotEmpty(t, slow.Data["conversation_id"])
+		assert.Equal(t, int64(len(body)), slow.Data["request_bytes"])
+		assert.Equal(t, w.Body.Len(), slow.Data["response_bytes"])
+		assert.Equal(t, "claude-sonnet-4", slow.Data["resolved_model"])
+	})
+
+	t.Run("does not log requests under the slow threshold", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.SlowRequestThreshold = 60
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+		hook := logtest.NewGlobal()
+		defer hook.Reset()
+
+		postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		for _, e := range hook.AllEntries() {
+			assert.NotEqual(t, "Slow request", e.Message)
+		}
+	})
+}
+
+// =============================================================================
+// TestHandlersWithFakeKiro
+// End-to-end handler tests against recorded Kiro streams
+// =============================================================================
+
+func TestHandlersWithFakeKiro(t *testing.T) {
+	// postJSON sends an authenticated JSON request to the router
+	postJSON := func(router *gin.Engine, path, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("chat completion", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`, `{"content":" there!"}`))
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp converter.OpenAIResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Hello there!", resp.Choices[0].Message.Content)
+		assert.Equal(t, "stop", resp.Choices[0].FinishReason)
+
+		requests := fake.Requests()
+		assert.Len(t, requests, 1)
+		assert.Equal(t, "claude-sonnet-4", requests[0].Payload.(*converter.KiroPayload).ConversationState.CurrentMessage.UserInputMessage.ModelID)
+	})
+
+	t.Run("message with tool call", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(
+			`{"name":"get_weather","toolUseId":"toolu_1"}`,
+			`{"input":"{\"city\": \"Paris\"}"}`,
+			`{"stop":true}`,
+		))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Weather?"}],"tools":[{"name":"get_weather","input_schema":{"type":"object"}}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "tool_use", resp["stop_reason"])
+		block := resp["content"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "get_weather", block["name"])
+		assert.Equal(t, map[string]interface{}{"city": "Paris"}, block["input"])
+	})
+
+	t.Run("message with a tool name Kiro rejects", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(clienttest.Stream(
+			`{"name":"mcp_fs_read","toolUseId":"toolu_1"}`,
+			`{"input":"{\"path\": \"a.txt\"}"}`,
+			`{"stop":true}`,
+		))
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Read a.txt"}],"tools":[{"name":"mcp.fs/read","input_schema":{"type":"object"}}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		block := resp["content"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "mcp.fs/read", block["name"])
+
+		tools := fake.Requests()[0].Payload.(*converter.KiroPayload).ConversationState.CurrentMessage.UserInputMessage.UserInputMessageContext.Tools
+		assert.Equal(t, "mcp_fs_read", tools[0]["toolSpecification"].(map[string]interface{})["name"])
+	})
+
+	t.Run("message with thinking", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.FakeReasoningEnabled = true
+		server.Cfg.FakeReasoningHandling = "as_reasoning_content"
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Plan it.</thinking>"}`, `{"content":"Answer"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		blocks := resp["content"].([]interface{})
+		assert.Len(t, blocks, 2)
+		thinking := blocks[0].(map[string]interface{})
+		assert.Equal(t, "thinking", thinking["type"])
+		assert.Equal(t, "Plan it.", thinking["thinking"])
+		assert.NotEmpty(t, thinking["signature"])
+		assert.Equal(t, "Answer", blocks[1].(map[string]interface{})["text"])
+	})
+
+	t.Run("message without thinking blocks when thinking is removed", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.FakeReasoningEnabled = true
+		server.Cfg.FakeReasoningHandling = "remove"
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Plan it.</thinking>"}`, `{"content":"Answer"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		blocks := resp["content"].([]interface{})
+		assert.Len(t, blocks, 1)
+		assert.Equal(t, "text", blocks[0].(map[string]interface{})["type"])
+	})
+
+	t.Run("message with thinking inline when thinking is passed", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.FakeReasoningEnabled = true
+		server.Cfg.FakeReasoningHandling = "pass"
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Plan it.</thinking>"}`, `{"content":"Answer"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		blocks := resp["content"].([]interface{})
+		assert.Len(t, blocks, 1)
+		assert.Equal(t, "<thinking>Plan it.</thinking>Answer", blocks[0].(map[string]interface{})["text"])
+	})
+
+	t.Run("streamed message with a signed thinking block", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.FakeReasoningEnabled = true
+		server.Cfg.FakeReasoningHandling = "as_reasoning_content"
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Plan it.</thinking>"}`, `{"content":"Answer"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		body := w.Body.String()
+		assert.Contains(t, body, `"thinking":"Plan it."`)
+		assert.Contains(t, body, `"type":"signature_delta"`)
+		assert.Less(t, strings.Index(body, "signature_delta"), strings.Index(body, `"text":"Answer"`))
+	})
+
+	t.Run("streamed message without thinking blocks when thinking is removed", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.FakeReasoningEnabled = true
+		server.Cfg.FakeReasoningHandling = "remove"
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Plan it.</thinking>"}`, `{"content":"Answer"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "thinking")
+		assert.Contains(t, w.Body.String(), `"text":"Answer"`)
+	})
+
+	t.Run("message with cache_control hints", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Summary."}`))
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,
+			"system":[{"type":"text","text":"Be brief.","cache_control":{"type":"ephemeral"}}],
+			"messages":[{"role":"user","content":[
+				{"type":"text","text":"Long document. ","cache_control":{"type":"ephemeral","ttl":"1h"}},
+				{"type":"text","text":"Summarize it."}]}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, float64(0), resp["usage"].(map[string]interface{})["cache_creation_input_tokens"])
+		assert.Equal(t, float64(0), resp["usage"].(map[string]interface{})["cache_read_input_tokens"])
+
+		// Hints are not sent to Kiro; the text blocks are
+		payload, err := json.Marshal(fake.Requests()[0].Payload)
+		assert.NoError(t, err)
+		assert.NotContains(t, string(payload), "cache_control")
+		assert.Contains(t, fake.Requests()[0].Payload.(*converter.KiroPayload).ConversationState.CurrentMessage.UserInputMessage.Content, "Long document. Summarize it.")
+	})
+
+	t.Run("message with assistant prefill", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":" \"blue\"}"}`))
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"messages":[
+			{"role":"user","content":"Favorite color as JSON?"},
+			{"role":"assistant","content":"{\"color\":"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		block := resp["content"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, `{"color": "blue"}`, block["text"])
+
+		// The prefill goes with the user turn instead of a synthetic "Continue"
+		payload := fake.Requests()[0].Payload.(*converter.KiroPayload)
+		assert.Empty(t, payload.ConversationState.History)
+		content := payload.ConversationState.CurrentMessage.UserInputMessage.Content
+		assert.Contains(t, content, "Favorite color as JSON?")
+		assert.Contains(t, content, converter.PrefillPrompt+"\n\n{\"color\":")
+	})
+
+	t.Run("Kiro error status", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Response{StatusCode: http.StatusBadRequest, Body: "Improperly formed request"})
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "Improperly formed request")
+	})
+
+	t.Run("continues a truncated response", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.TruncationRecovery = true
+		fake := clienttest.NewFake(
+			clienttest.Stream(`{"content":"{\"reasons\": [\"speed\","}`),
+			clienttest.Stream(`{"content":" \"simplicity\"]}"}`, `{"contextUsagePercentage":1.5}`),
+		)
+		server.HttpClient = fake
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Why Go?"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp converter.OpenAIResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, `{"reasons": ["speed", "simplicity"]}`, resp.Choices[0].Message.Content)
+
+		requests := fake.Requests()
+		assert.Len(t, requests, 2)
+		continuation := requests[1].Payload.(*converter.KiroPayload)
+		assert.Equal(t, converter.ContinuationPrompt, continuation.ConversationState.CurrentMessage.UserInputMessage.Content)
+	})
+
+	t.Run("records and replays fixtures", func(t *testing.T) {
+		dir := t.TempDir()
+		body := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`
+
+		server, router := newTestServer("test-key")
+		server.HttpClient = fixtures.NewRecorder(clienttest.NewFake(clienttest.Stream(`{"content":"Recorded reply."}`)), dir)
+		w := postJSON(router, "/v1/chat/completions", body)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		replayer, err := fixtures.NewReplayer(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, replayer.Len())
+
+		server, router = newTestServer("test-key")
+		server.HttpClient = replayer
+		w = postJSON(router, "/v1/chat/completions", body)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var resp converter.OpenAIResponse
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "Recorded reply.", resp.Choices[0].Message.Content)
+	})
+}
+
+// =============================================================================
+// TestChatCompletionChoices
+// =============================================================================
+
+func TestChatCompletionChoices(t *testing.T) {
+	chat := func(router *gin.Engine, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer test-key")
+		router.ServeHTTP(w, req)
+		return w
+	}
+	body := `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}],"n":3}`
+
+	for _, concurrent := range []int{0, 1} {
+		t.Run(fmt.Sprintf("returns n choices with concurrency limit %d", concurrent), func(t *testing.T) {
+			server, router := newTestServer("test-key")
+			server.Cfg.RateLimitConcurrent = concurrent
+			fake := clienttest.NewFake(
+				clienttest.Stream(`{"content":"One"}`),
+				clienttest.Stream(`{"content":"Two"}`),
+				clienttest.Stream(`{"content":"Three"}`),
+			)
+			server.HttpClient = fake
+
+			w := chat(router, body)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var resp converter.OpenAIResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Len(t, resp.Choices, 3)
+			var contents []interface{}
+			for i, choice := range resp.Choices {
+				assert.Equal(t, i, choice.Index)
+				contents = append(contents, choice.Message.Content)
+			}
+			assert.ElementsMatch(t, []interface{}{"One", "Two", "Three"}, contents)
+
+			// Each choice is its own Kiro conversation
+			conversations := map[string]bool{}
+			for _, request := range fake.Requests() {
+				conversations[request.Payload.(*converter.KiroPayload).ConversationState.ConversationID] = true
+			}
+			assert.Len(t, conversations, 3)
+		})
+	}
+
+	t.Run("fails when a choice fails", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(
+			clienttest.Stream(`{"content":"One"}`),
+			clienttest.Response{StatusCode: http.StatusTooManyRequests, Body: "slow down"},
+			clienttest.Stream(`{"content":"Three"}`),
+		)
+
+		w := chat(router, body)
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	})
+
+	t.Run("rejects several streamed choices", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := chat(router, `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}],"n":2,"stream":true}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"param":"n"`)
+	})
+}
+
+// =============================================================================
+// TestChatCompletionReasoning
+// =============================================================================
+
+func TestChatCompletionReasoning(t *testing.T) {
+	for _, tt := range []struct {
+		handling  string
+		content   string
+		reasoning string
+	}{
+		{"as_reasoning_content", "Answer", "Plan it."},
+		{"pass", "<thinking>Plan it.</thinking>Answer", ""},
+		{"strip_tags", "Plan it.Answer", ""},
+		{"remove", "Answer", ""},
+	} {
+		t.Run(tt.handling, func(t *testing.T) {
+			server, router := newTestServer("test-key")
+			server.Cfg.FakeReasoningEnabled = true
+			server.Cfg.FakeReasoningHandling = tt.handling
+			server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Plan it.</thinking>"}`, `{"content":"Answer"}`))
+
+			w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			var resp converter.OpenAIResponse
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, tt.content, resp.Choices[0].Message.Content)
+			assert.Equal(t, tt.reasoning, resp.Choices[0].Message.ReasoningContent)
+		})
+	}
+
+	t.Run("omits reasoning_content without thinking", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Answer"}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "reasoning_content")
+	})
+}
+
+// =============================================================================
+// TestChatCompletionModelProfile
+// =============================================================================
+
+func TestChatCompletionModelProfile(t *testing.T) {
+	server, router := newTestServer("test-key")
+	server.Cfg.ForwardInferenceConfig = true
+	server.Cfg.ModelProfiles = map[string]config.ModelProfile{
+		"claude-sonnet-*": {DefaultMaxTokens: 1024, MaxOutputTokens: 2048},
+	}
+
+	for _, tt := range []struct {
+		name      string
+		maxTokens string
+		want      int
+	}{
+		{"default when the client sets none", "", 1024},
+		{"client value within the cap", `,"max_tokens":500`, 500},
+		{"client value clamped", `,"max_tokens":100000`, 2048},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+			server.HttpClient = fake
+
+			w := httptest.NewRecorder()
+			body := `{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Hi"}]` + tt.maxTokens + `}`
+			req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer test-key")
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			payload := fake.Requests()[0].Payload.(*converter.KiroPayload)
+			inference := payload.ConversationState.CurrentMessage.UserInputMessage.InferenceConfiguration
+			if assert.NotNil(t, inference) && assert.NotNil(t, inference.MaxTokens) {
+				assert.Equal(t, tt.want, *inference.MaxTokens)
+			}
+		})
+	}
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"context"
+
+	"kiro-go-proxy/usage"
+)
+
+// servedModelHeader names the Kiro model that answered a streaming request. Kiro
+// may name it within its stream, so the header is sent as a trailer.
+const servedModelHeader = "x-kiro-model"
+
+// servedModel returns the Kiro model that answered the request in ctx, or "" if
+// it is the model the response already names. Requests for "auto" or an alias,
+// and those answered by a fallback or draft model, report it.
+func servedModel(ctx context.Context, model string) string {
+	served := usage.FromContext(ctx).ServedModel()
+	if served == model {
+		return ""
+	}
+	return served
+}
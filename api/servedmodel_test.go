@@ -0,0 +1,75 @@
+// Package api provides tests for reporting the Kiro model that served a request.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestServedModel
+// =============================================================================
+
+func TestServedModel(t *testing.T) {
+	t.Run("OpenAI responses report the model Kiro named", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"modelId":"claude-sonnet-4.5"}`, `{"content":"Hello!"}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"auto","messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "auto", body["model"])
+		assert.Equal(t, "claude-sonnet-4.5", body["x_kiro_model"])
+	})
+
+	t.Run("Anthropic messages report it in metadata", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"modelId":"claude-sonnet-4.5"}`, `{"content":"Hello!"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"auto-kiro","max_tokens":100,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, map[string]interface{}{"kiro_model": "claude-sonnet-4.5"}, body["metadata"])
+	})
+
+	t.Run("aliases report the resolved model", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`))
+		server.ModelResolver.SetAlias("auto-kiro", "auto")
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"auto-kiro","messages":[{"role":"user","content":"Hi"}]}`)
+
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "auto", body["x_kiro_model"])
+	})
+
+	t.Run("streams send a model trailer", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`, `{"modelId":"claude-haiku-4.5"}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"auto","stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "claude-haiku-4.5", w.Result().Trailer.Get(servedModelHeader))
+	})
+
+	t.Run("omitted when the requested model served", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello!"}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), "x_kiro_model")
+	})
+}
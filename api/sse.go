@@ -0,0 +1,118 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/tracing"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// eventWriter delivers stream events to the client over a particular transport.
+// Events may be buffered until Flush.
+type eventWriter interface {
+	WriteEvent(event string)
+	Flush()
+}
+
+// sseWriter writes events to the HTTP response. After the first failed write,
+// or a flush the client does not take within STREAMING_WRITE_TIMEOUT, it cancels
+// the request, so the Kiro stream is abandoned, and drops the remaining events.
+type sseWriter struct {
+	writer     gin.ResponseWriter
+	controller *http.ResponseController
+	cancel     context.CancelFunc
+	timeout    time.Duration
+	failed     atomic.Bool
+}
+
+// newSSEWriter returns the writer for a streaming response; cancel cancels the
+// request's context. It reports false when the response cannot be streamed.
+func newSSEWriter(c *gin.Context, cfg *config.Config, cancel context.CancelFunc) (*sseWriter, bool) {
+	if _, ok := c.Writer.(http.Flusher); !ok {
+		return nil, false
+	}
+	return &sseWriter{
+		writer:     c.Writer,
+		controller: http.NewResponseController(c.Writer),
+		cancel:     cancel,
+		timeout:    time.Duration(cfg.StreamingWriteTimeout * float64(time.Second)),
+	}, true
+}
+
+func (w *sseWriter) WriteEvent(event string) {
+	if w.failed.Load() {
+		return
+	}
+	if _, err := w.writer.WriteString(event); err != nil {
+		w.fail(err)
+	}
+}
+
+func (w *sseWriter) Flush() {
+	if w.failed.Load() {
+		return
+	}
+
+	// A client that stopped reading leaves the flush blocked on a full socket
+	// buffer; the expired write deadline makes it fail instead
+	if w.timeout > 0 {
+		stall := time.AfterFunc(w.timeout, func() {
+			w.fail(errWriteStalled)
+			w.controller.SetWriteDeadline(time.Now())
+		})
+		defer stall.Stop()
+	}
+	if err := w.controller.Flush(); err != nil {
+		w.fail(err)
+	}
+}
+
+// errWriteStalled is the failure of a flush that exceeded STREAMING_WRITE_TIMEOUT
+var errWriteStalled = errors.New("client stopped reading the stream")
+
+// fail cancels the request on the first write failure
+func (w *sseWriter) fail(err error) {
+	if w.failed.Swap(true) {
+		return
+	}
+	log.Debugf("Stream write failed, cancelling request: %v", err)
+	w.cancel()
+}
+
+// writeEvents writes stream events to the client as they are produced. Events
+// that queued up while the client was slow go out together with one flush, so
+// a slow client gets fewer, larger writes rather than holding up the stream.
+func writeEvents(ctx context.Context, w eventWriter, events <-chan string) {
+	_, span := tracing.StartSpan(ctx, "response.write", tracing.SpanKindInternal)
+	defer span.End()
+	accesslog.FromContext(ctx).MarkStreaming()
+
+	count := 0
+	for event := range events {
+		w.WriteEvent(event)
+		count++
+	queued:
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					break queued
+				}
+				w.WriteEvent(event)
+				count++
+			default:
+				break queued
+			}
+		}
+		w.Flush()
+	}
+	span.SetAttribute("sse.events", count)
+}
@@ -0,0 +1,123 @@
+// Package api provides tests for writing streamed responses.
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// failingWriter is a response writer whose client has gone away
+type failingWriter struct {
+	*httptest.ResponseRecorder
+}
+
+func (w *failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+func (w *failingWriter) WriteString(string) (int, error) {
+	return 0, errors.New("broken pipe")
+}
+
+// stalledWriter is a response writer whose client stopped reading: flushes block
+// until a write deadline is set
+type stalledWriter struct {
+	*httptest.ResponseRecorder
+	unblock chan struct{}
+}
+
+func (w *stalledWriter) Flush() {
+	<-w.unblock
+}
+
+func (w *stalledWriter) SetWriteDeadline(time.Time) error {
+	close(w.unblock)
+	return nil
+}
+
+// recordingWriter records the events and flushes of writeEvents
+type recordingWriter struct {
+	events  []string
+	flushes int
+}
+
+func (w *recordingWriter) WriteEvent(event string) { w.events = append(w.events, event) }
+func (w *recordingWriter) Flush()                  { w.flushes++ }
+
+// =============================================================================
+// TestSSEWriter
+// =============================================================================
+
+func TestSSEWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("cancels the request on a failed write", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(&failingWriter{ResponseRecorder: httptest.NewRecorder()})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		w, ok := newSSEWriter(c, &config.Config{}, cancel)
+		assert.True(t, ok)
+		w.WriteEvent("data: one\n\n")
+		w.Flush()
+
+		assert.Error(t, ctx.Err())
+		assert.True(t, w.failed.Load())
+	})
+
+	t.Run("cancels the request when the client stops reading", func(t *testing.T) {
+		c, _ := gin.CreateTestContext(&stalledWriter{ResponseRecorder: httptest.NewRecorder(), unblock: make(chan struct{})})
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		w, _ := newSSEWriter(c, &config.Config{StreamingWriteTimeout: 0.01}, cancel)
+		w.WriteEvent("data: one\n\n")
+		w.Flush()
+
+		assert.Error(t, ctx.Err())
+		w.WriteEvent("data: two\n\n")
+		w.Flush()
+	})
+
+	t.Run("writes and flushes a healthy stream", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(recorder)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		w, _ := newSSEWriter(c, &config.Config{StreamingWriteTimeout: 30}, cancel)
+		w.WriteEvent("data: one\n\n")
+		w.Flush()
+
+		assert.NoError(t, ctx.Err())
+		assert.True(t, recorder.Flushed)
+		assert.Equal(t, "data: one\n\n", recorder.Body.String())
+	})
+}
+
+// =============================================================================
+// TestWriteEvents
+// =============================================================================
+
+func TestWriteEvents(t *testing.T) {
+	t.Run("flushes queued events together", func(t *testing.T) {
+		events := make(chan string, 3)
+		events <- "a"
+		events <- "b"
+		events <- "c"
+		close(events)
+
+		w := &recordingWriter{}
+		writeEvents(context.Background(), w, events)
+
+		assert.Equal(t, []string{"a", "b", "c"}, w.events)
+		assert.Equal(t, 1, w.flushes)
+	})
+}
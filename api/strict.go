@@ -0,0 +1,81 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/stream"
+
+	"github.com/gin-gonic/gin"
+)
+
+// decodeRequest decodes a JSON request body into req and runs its Validate. With
+// STRICT_VALIDATION on, fields the API does not define are rejected first and
+// ValidateStrict checks the values that are otherwise passed through.
+func decodeRequest(cfg *config.Config, body []byte, req interface{}, known converter.FieldSet) error {
+	if err := json.Unmarshal(body, req); err != nil {
+		return err
+	}
+	if cfg.StrictValidation {
+		if err := converter.CheckUnknownFields(body, known); err != nil {
+			return err
+		}
+	}
+	if v, ok := req.(interface{ Validate() error }); ok {
+		if err := v.Validate(); err != nil {
+			return err
+		}
+	}
+	if v, ok := req.(interface{ ValidateStrict() error }); ok && cfg.StrictValidation {
+		return v.ValidateStrict()
+	}
+	return nil
+}
+
+// bindRequest reads the request body and decodes it with decodeRequest
+func (s *Server) bindRequest(c *gin.Context, req interface{}, known converter.FieldSet) error {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	return decodeRequest(s.currentConfig(), body, req, known)
+}
+
+// openAIValidationBody is the OpenAI error body for an invalid request. Field
+// errors name the offending field in param, as OpenAI does.
+func openAIValidationBody(err error) gin.H {
+	body := gin.H{
+		"message": fmt.Sprintf("Invalid request: %v", err),
+		"type":    "invalid_request_error",
+	}
+	var fieldErr *converter.FieldError
+	if errors.As(err, &fieldErr) {
+		body["param"] = fieldErr.Field
+	}
+	return gin.H{"error": body}
+}
+
+// geminiValidationError writes a 400 in the Google API error format. Field errors
+// are also reported as a google.rpc.BadRequest field violation.
+func geminiValidationError(c *gin.Context, err error) {
+	body := gin.H{
+		"code":    http.StatusBadRequest,
+		"message": fmt.Sprintf("Invalid request: %v", err),
+		"status":  stream.GeminiErrorStatus(http.StatusBadRequest),
+	}
+	var fieldErr *converter.FieldError
+	if errors.As(err, &fieldErr) {
+		body["details"] = []gin.H{{
+			"@type": "type.googleapis.com/google.rpc.BadRequest",
+			"fieldViolations": []gin.H{{
+				"field":       fieldErr.Field,
+				"description": fieldErr.Message,
+			}},
+		}}
+	}
+	c.JSON(http.StatusBadRequest, gin.H{"error": body})
+}
@@ -0,0 +1,122 @@
+// Package api provides tests for strict request validation.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+)
+
+// postJSON sends an authenticated POST with a JSON body
+func postJSON(router http.Handler, path, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer test-key")
+	req.Header.Set("x-api-key", "test-key")
+	req.Header.Set("x-goog-api-key", "test-key")
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// =============================================================================
+// TestStrictValidation
+// =============================================================================
+
+func TestStrictValidation(t *testing.T) {
+	t.Run("ignores unknown fields by default", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model": "claude-sonnet-4.5", "temprature": 0.5, "messages": [{"role": "user", "content": "Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("accepts valid requests", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.StrictValidation = true
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+
+		w := postJSON(router, "/v1/chat/completions", `{"model": "claude-sonnet-4.5", "user": "u1", "messages": [{"role": "user", "content": "Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("accepts unknown Anthropic content block types", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.StrictValidation = true
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model": "claude-sonnet-4.5", "max_tokens": 10, "messages": [{"role": "user", "content": [
+			{"type": "container_upload", "file_id": "file_1"}, {"type": "text", "text": "Hi"}]}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("names the field in param for OpenAI", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.StrictValidation = true
+
+		w := postJSON(router, "/v1/chat/completions", `{"model": "claude-sonnet-4.5", "messages": [{"role": "human", "content": "Hi"}]}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		errBody := body["error"].(map[string]interface{})
+		assert.Equal(t, "invalid_request_error", errBody["type"])
+		assert.Equal(t, "messages[0].role", errBody["param"])
+		assert.Contains(t, errBody["message"], "got 'human'")
+	})
+
+	t.Run("reports a Gemini field violation", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.StrictValidation = true
+
+		w := postJSON(router, "/v1beta/models/gemini-pro:generateContent", `{"contents": [{"parts": [{"text": "Hi"}]}], "system_instruction": {}}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		errBody := body["error"].(map[string]interface{})
+		assert.Equal(t, "INVALID_ARGUMENT", errBody["status"])
+		violation := errBody["details"].([]interface{})[0].(map[string]interface{})["fieldViolations"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "system_instruction", violation["field"])
+		assert.Contains(t, violation["description"], "did you mean 'systemInstruction'?")
+	})
+
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		contains []string
+	}{
+		{"anthropic", "/v1/messages",
+			`{"model": "claude-sonnet-4.5", "max_tokens": 10, "stopSequences": ["x"], "messages": [{"role": "user", "content": "Hi"}]}`,
+			[]string{`"type":"error"`, `"type":"invalid_request_error"`, "stopSequences: unknown field, did you mean 'stop_sequences'?"}},
+		{"responses", "/v1/responses",
+			`{"model": "claude-sonnet-4.5", "input": "Hi", "temperature": 3}`,
+			[]string{`"param":"temperature"`}},
+		{"ollama", "/api/chat",
+			`{"model": "claude-sonnet-4.5", "messages": [{"role": "user", "content": "Hi"}], "tools": [{"type": "function", "function": {}}]}`,
+			[]string{`"error":"Invalid request: tools[0].function.name: field required"`}},
+	}
+	for _, tt := range tests {
+		t.Run("rejects malformed "+tt.name+" requests", func(t *testing.T) {
+			server, router := newTestServer("test-key")
+			server.Cfg.StrictValidation = true
+
+			w := postJSON(router, tt.path, tt.body)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			for _, s := range tt.contains {
+				assert.Contains(t, w.Body.String(), s)
+			}
+		})
+	}
+}
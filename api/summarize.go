@@ -0,0 +1,115 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/respcache"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/usage"
+	"kiro-go-proxy/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// summarizedHeader reports how many earlier messages were replaced by a summary
+// because the prompt neared the model's context limit
+const summarizedHeader = "x-kiro-summarized"
+
+// Summaries are reused for summaryCacheTTL, so the retries, continuations and
+// agentic steps of a long conversation do not summarize the same turns again
+const (
+	summaryCacheTTL     = time.Hour
+	summaryCacheEntries = 256
+)
+
+// summarizeHistory replaces the older messages by a summary from SUMMARIZE_MODEL
+// when the estimated prompt exceeds SUMMARIZE_THRESHOLD percent of the model's
+// max input tokens. The last SUMMARIZE_KEEP_MESSAGES messages are kept verbatim.
+// Summaries are cached by API key, SUMMARIZE_MODEL and the summarized messages.
+// If summarization fails the messages are returned unchanged, leaving the
+// context trim strategy to handle them.
+func (s *Server) summarizeHistory(ctx context.Context, cfg *config.Config, modelID string, messages []converter.UnifiedMessage, systemPrompt string, tools []converter.UnifiedTool) []converter.UnifiedMessage {
+	if cfg.SummarizeThreshold <= 0 || cfg.SummarizeModel == "" {
+		return messages
+	}
+	maxInput := s.ModelCache.GetMaxInputTokens(modelID)
+	promptTokens := converter.EstimateInputTokens(messages, systemPrompt, tools)
+	if float64(promptTokens) <= float64(maxInput)*cfg.SummarizeThreshold/100 {
+		return messages
+	}
+	split := converter.SummarySplit(messages, cfg.SummarizeKeepMessages)
+	if split == 0 {
+		return messages
+	}
+
+	key := respcache.Key(usage.KeyID(requestAPIKey(ctx)), cfg.SummarizeModel, messages[:split])
+	cached, ok := s.Summaries.Get(key)
+	summary := string(cached)
+	if !ok {
+		var err error
+		summary, err = s.summarize(ctx, cfg, messages[:split])
+		if err != nil {
+			log.Warnf("History summarization failed, sending the full history: %v", err)
+			return messages
+		}
+		s.Summaries.Put(key, []byte(summary))
+	}
+	log.Infof("Summarized %d of %d messages (%d of %d max input tokens)", split, len(messages), promptTokens, maxInput)
+	setResponseHeader(ctx, summarizedHeader, strconv.Itoa(split))
+	return converter.WithSummary(messages, split, summary)
+}
+
+// summarize asks SUMMARIZE_MODEL for a summary of messages in a conversation of
+// its own. The credits it costs count towards the request.
+func (s *Server) summarize(ctx context.Context, cfg *config.Config, messages []converter.UnifiedMessage) (string, error) {
+	summaryCfg := *cfg
+	summaryCfg.FakeReasoningEnabled = false
+	summaryCfg.ContextTrimStrategy = converter.TrimOff
+
+	modelID := s.ModelResolver.Resolve(cfg.SummarizeModel).InternalID
+	payload, err := converter.BuildKiroPayload(
+		[]converter.UnifiedMessage{{Role: "user", Content: converter.SummaryPrompt(messages)}},
+		"",
+		modelID,
+		nil,
+		utils.GenerateConversationID(),
+		s.AuthManager.ProfileArn(),
+		0,
+		&summaryCfg,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	summaryCtx, cancel := summaryContext(ctx)
+	defer cancel()
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+	result, status, errBody := s.collectChatCompletion(summaryCtx, &summaryCfg, apiURL, payload, stream.Limits{})
+	if result == nil {
+		return "", fmt.Errorf("%s returned %d: %v", modelID, status, errBody["error"])
+	}
+	if result.Content == "" {
+		return "", errors.New(modelID + " returned an empty summary")
+	}
+	return result.Content, nil
+}
+
+// summaryContext returns a context for the summary request that is canceled
+// with ctx but is not the client's request: it does not set response headers,
+// mark the first token in the access log or get captured for debugging. Its
+// credits are still added to the request's usage.
+func summaryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached := withoutHTTPRequest(usage.WithRequest(context.Background(), usage.FromContext(ctx)), requestAPIKey(ctx))
+	detached, cancel := context.WithCancel(detached)
+	stop := context.AfterFunc(ctx, cancel)
+	return detached, func() {
+		stop()
+		cancel()
+	}
+}
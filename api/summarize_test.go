@@ -0,0 +1,128 @@
+// Package api provides tests for history summarization near the context limit.
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/converter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// summaryConversation has enough turns to summarize the first four messages
+const summaryConversation = `"messages":[
+	{"role":"user","content":"I am planning a trip to Paris."},
+	{"role":"assistant","content":"Great, how can I help?"},
+	{"role":"user","content":"I arrive on Friday."},
+	{"role":"assistant","content":"Noted."},
+	{"role":"user","content":"What should I pack?"}]`
+
+// =============================================================================
+// TestSummarizeHistory
+// =============================================================================
+
+func TestSummarizeHistory(t *testing.T) {
+	t.Run("replaces older turns by a summary", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(
+			clienttest.Stream(`{"content":"The user travels to Paris on Friday."}`),
+			clienttest.Stream(`{"content":"Pack light layers."}`),
+		)
+		server.HttpClient = fake
+		server.Cfg.SummarizeThreshold = 0.01
+		server.Cfg.SummarizeModel = "claude-haiku-4.5"
+		server.Cfg.SummarizeKeepMessages = 1
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4",`+summaryConversation+`}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "4", w.Header().Get(summarizedHeader))
+		requests := fake.Requests()
+		assert.Len(t, requests, 2)
+		summaryPayload := requests[0].Payload.(*converter.KiroPayload)
+		assert.Equal(t, "claude-haiku-4.5", summaryPayload.ModelID())
+		assert.Contains(t, string(mustMarshal(summaryPayload)), "I arrive on Friday.")
+
+		sent := string(mustMarshal(requests[1].Payload.(*converter.KiroPayload).ConversationState))
+		assert.Contains(t, sent, "The user travels to Paris on Friday.")
+		assert.Contains(t, sent, "What should I pack?")
+		assert.NotContains(t, sent, "I arrive on Friday.")
+		assert.Contains(t, w.Body.String(), "Pack light layers.")
+	})
+
+	t.Run("reuses the summary of the same turns", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(
+			clienttest.Stream(`{"content":"The user travels to Paris on Friday."}`),
+			clienttest.Stream(`{"content":"Pack light layers."}`),
+			clienttest.Stream(`{"content":"Pack an umbrella."}`),
+		)
+		server.HttpClient = fake
+		server.Cfg.SummarizeThreshold = 0.01
+		server.Cfg.SummarizeModel = "claude-haiku-4.5"
+		server.Cfg.SummarizeKeepMessages = 1
+
+		postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4",`+summaryConversation+`}`)
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4",`+summaryConversation+`}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "4", w.Header().Get(summarizedHeader))
+		requests := fake.Requests()
+		assert.Len(t, requests, 3)
+		assert.Contains(t, string(mustMarshal(requests[2].Payload)), "The user travels to Paris on Friday.")
+		assert.Contains(t, w.Body.String(), "Pack an umbrella.")
+	})
+
+	t.Run("applies to Anthropic messages", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(
+			clienttest.Stream(`{"content":"The user travels to Paris on Friday."}`),
+			clienttest.Stream(`{"content":"Pack light layers."}`),
+		)
+		server.HttpClient = fake
+		server.Cfg.SummarizeThreshold = 0.01
+		server.Cfg.SummarizeModel = "claude-haiku-4.5"
+		server.Cfg.SummarizeKeepMessages = 1
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,`+summaryConversation+`}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "4", w.Header().Get(summarizedHeader))
+		assert.NotContains(t, string(mustMarshal(fake.Requests()[1].Payload)), "I arrive on Friday.")
+	})
+
+	t.Run("keeps the full history when summarization fails", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(
+			clienttest.Response{StatusCode: http.StatusBadRequest, Body: `{"message":"bad request"}`},
+			clienttest.Stream(`{"content":"Pack light layers."}`),
+		)
+		server.HttpClient = fake
+		server.Cfg.SummarizeThreshold = 0.01
+		server.Cfg.SummarizeModel = "claude-haiku-4.5"
+		server.Cfg.SummarizeKeepMessages = 1
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4",`+summaryConversation+`}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(summarizedHeader))
+		assert.Contains(t, string(mustMarshal(fake.Requests()[1].Payload)), "I arrive on Friday.")
+	})
+
+	t.Run("short prompts are sent as they are", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Pack light layers."}`))
+		server.HttpClient = fake
+		server.Cfg.SummarizeThreshold = 80
+		server.Cfg.SummarizeModel = "claude-haiku-4.5"
+		server.Cfg.SummarizeKeepMessages = 1
+
+		w := postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4",`+summaryConversation+`}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(summarizedHeader))
+		assert.Len(t, fake.Requests(), 1)
+	})
+}
@@ -0,0 +1,52 @@
+package api
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"kiro-go-proxy/stream"
+
+	"github.com/gin-gonic/gin"
+)
+
+// requestTimeoutHeader sets a request's total time budget in seconds
+const requestTimeoutHeader = "x-request-timeout"
+
+// maxRequestTimeoutSeconds bounds x-request-timeout, well below the duration
+// overflow that would leave the request without a deadline
+const maxRequestTimeoutSeconds = 86400
+
+// RequestTimeoutMiddleware gives the request a deadline from x-request-timeout,
+// or REQUEST_TIMEOUT when the client sends none. A client may shorten but not
+// extend REQUEST_TIMEOUT. Kiro requests and stream parsing run on the request
+// context, so a request out of time fails with a 504 (or an error event once
+// streaming) instead of hanging until the server write timeout.
+func (s *Server) RequestTimeoutMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		timeout := time.Duration(s.currentConfig().RequestTimeout * float64(time.Second))
+		if value := c.GetHeader(requestTimeoutHeader); value != "" {
+			seconds, err := strconv.ParseFloat(value, 64)
+			if err != nil || seconds <= 0 || math.IsInf(seconds, 0) || math.IsNaN(seconds) || seconds > maxRequestTimeoutSeconds {
+				routeError(c, http.StatusBadRequest, "invalid_request_error", fmt.Sprintf("Invalid %s header: expected a positive number of seconds up to %d, got %q", requestTimeoutHeader, maxRequestTimeoutSeconds, value))
+				c.Abort()
+				return
+			}
+			if requested := time.Duration(seconds * float64(time.Second)); timeout <= 0 || requested < timeout {
+				timeout = requested
+			}
+		}
+		if timeout <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := stream.WithRequestTimeout(c.Request.Context(), timeout)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+	}
+}
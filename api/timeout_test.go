@@ -0,0 +1,87 @@
+// Package api provides tests for the per-request timeout budget.
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+)
+
+// =============================================================================
+// TestRequestTimeout
+// =============================================================================
+
+func TestRequestTimeout(t *testing.T) {
+	chat := func(router http.Handler, body, timeout string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set("Content-Type", "application/json")
+		if timeout != "" {
+			req.Header.Set(requestTimeoutHeader, timeout)
+		}
+		router.ServeHTTP(w, req)
+		return w
+	}
+	body := `{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Hi"}]}`
+	streamBody := `{"model":"claude-sonnet-4.5","stream":true,"messages":[{"role":"user","content":"Hi"}]}`
+
+	t.Run("stalled response fails with 504", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Response{Hang: true})
+
+		w := chat(router, body, "0.05")
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), "timeout_error")
+		assert.Contains(t, w.Body.String(), "Request timed out after 50ms")
+	})
+
+	t.Run("config default applies without the header", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.RequestTimeout = 0.05
+		server.HttpClient = clienttest.NewFake(clienttest.Response{Hang: true})
+
+		w := chat(router, body, "")
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	})
+
+	t.Run("stalled stream ends with an error chunk", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.HttpClient = clienttest.NewFake(clienttest.Response{Body: `{"content":"Hel"}`, Hang: true})
+
+		w := chat(router, streamBody, "0.05")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Hel")
+		assert.Contains(t, w.Body.String(), "request timed out after 50ms")
+		assert.True(t, strings.HasSuffix(w.Body.String(), "data: [DONE]\n\n"))
+	})
+
+	t.Run("header cannot extend the config timeout", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		server.Cfg.RequestTimeout = 0.05
+		server.HttpClient = clienttest.NewFake(clienttest.Response{Hang: true})
+
+		w := chat(router, body, "3600")
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), "Request timed out after 50ms")
+	})
+
+	t.Run("rejects an invalid header", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		for _, value := range []string{"soon", "0", "-1", "NaN", "Inf", "86401", "1e300"} {
+			w := chat(router, body, value)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code, value)
+			assert.Contains(t, w.Body.String(), requestTimeoutHeader, value)
+		}
+	})
+}
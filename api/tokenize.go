@@ -0,0 +1,40 @@
+package api
+
+import (
+	"net/http"
+
+	"kiro-go-proxy/converter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TokenizeHandler handles POST /v1/tokenize: it estimates the prompt tokens of
+// an OpenAI chat completion request the way the request itself would count them,
+// so clients can check it against the model's context before sending it. Nothing
+// is sent to Kiro.
+func (s *Server) TokenizeHandler(c *gin.Context) {
+	var req converter.OpenAIRequest
+	if err := s.bindRequest(c, &req, converter.OpenAIRequestFields); err != nil {
+		c.JSON(http.StatusBadRequest, openAIValidationBody(err))
+		return
+	}
+
+	unifiedMessages, systemPrompt := converter.ConvertOpenAIToUnified(req.Messages)
+	var unifiedTools []converter.UnifiedTool
+	if len(req.Tools) > 0 {
+		unifiedTools = converter.ConvertOpenAIToolsToUnified(req.Tools)
+	}
+	systemPrompt = withResponseFormatPrompt(systemPrompt, req.ResponseFormat)
+	promptTokens := converter.EstimateInputTokens(unifiedMessages, systemPrompt, unifiedTools)
+
+	resolution := s.ModelResolver.Resolve(req.Model)
+	maxInputTokens := s.ModelCache.GetMaxInputTokens(resolution.InternalID)
+
+	c.JSON(http.StatusOK, gin.H{
+		"object":           "tokenize",
+		"model":            req.Model,
+		"prompt_tokens":    promptTokens,
+		"max_input_tokens": maxInputTokens,
+		"fits_context":     promptTokens <= maxInputTokens,
+	})
+}
@@ -0,0 +1,68 @@
+// Package api provides tests for the OpenAI token estimation endpoint.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/model"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestTokenizeHandler
+// =============================================================================
+
+func TestTokenizeHandler(t *testing.T) {
+	promptTokens := func(t *testing.T, router *gin.Engine, body string) float64 {
+		w := postJSON(router, "/v1/tokenize", body)
+		assert.Equal(t, http.StatusOK, w.Code)
+		var parsed map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &parsed))
+		tokens, _ := parsed["prompt_tokens"].(float64)
+		return tokens
+	}
+
+	t.Run("estimates prompt tokens without calling Kiro", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		fake := clienttest.NewFake()
+		server.HttpClient = fake
+		server.ModelCache.Sync([]model.Info{{ModelID: "claude-haiku-4.5"}})
+
+		w := postJSON(router, "/v1/tokenize", `{"model":"claude-haiku-4.5","messages":[{"role":"system","content":"Be brief."},{"role":"user","content":"What is the capital of France?"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "tokenize", body["object"])
+		assert.Equal(t, "claude-haiku-4.5", body["model"])
+		assert.Greater(t, body["prompt_tokens"], float64(0))
+		assert.Equal(t, float64(200000), body["max_input_tokens"])
+		assert.Equal(t, true, body["fits_context"])
+		assert.Empty(t, fake.Requests())
+	})
+
+	t.Run("counts tools and response_format", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+		messages := `"messages":[{"role":"user","content":"Weather in Paris?"}]`
+
+		plain := promptTokens(t, router, `{"model":"claude-sonnet-4",`+messages+`}`)
+		withTools := promptTokens(t, router, `{"model":"claude-sonnet-4",`+messages+`,"tools":[{"type":"function","function":{"name":"get_weather","description":"Get the current weather for a city","parameters":{"type":"object","properties":{"city":{"type":"string"}}}}}]}`)
+		withFormat := promptTokens(t, router, `{"model":"claude-sonnet-4",`+messages+`,"response_format":{"type":"json_object"}}`)
+
+		assert.Greater(t, withTools, plain)
+		assert.Greater(t, withFormat, plain)
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		_, router := newTestServer("test-key")
+
+		w := postJSON(router, "/v1/tokenize", `{"model":"claude-sonnet-4","messages":"Hi"}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
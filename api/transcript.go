@@ -0,0 +1,44 @@
+package api
+
+import (
+	"net/http"
+
+	"kiro-go-proxy/accesslog"
+	"kiro-go-proxy/transcript"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+)
+
+// TranscriptMiddleware appends an answered request's prompt and reply to the
+// transcript of its Kiro conversation when TRANSCRIPT_DIR is set. Requests
+// without a conversation of their own are recorded under their request ID.
+func (s *Server) TranscriptMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if s.Transcripts == nil {
+			c.Next()
+			return
+		}
+
+		turn := transcript.NewTurn()
+		c.Request = c.Request.WithContext(transcript.WithTurn(c.Request.Context(), turn))
+
+		c.Next()
+
+		rec := turn.Record()
+		if rec == nil || c.Writer.Status() != http.StatusOK {
+			return
+		}
+		entry := accesslog.FromContext(c.Request.Context())
+		rec.Time = entry.Start
+		rec.ConversationID = entry.ConversationID()
+		rec.RequestID = entry.ID
+		rec.KeyID = usage.KeyID(c.GetString(apiKeyContextKey))
+		rec.Path = c.Request.URL.Path
+		rec.Model, _ = usage.FromContext(c.Request.Context()).Result()
+		if err := s.Transcripts.Write(rec); err != nil {
+			log.Warnf("Failed to write transcript: %v", err)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Package api provides tests for writing conversation transcripts.
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/transcript"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// transcriptFiles returns the contents of the transcript files written to dir
+func transcriptFiles(t *testing.T, dir string) map[string]string {
+	files := map[string]string{}
+	paths, _ := filepath.Glob(filepath.Join(dir, "*", "*"))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		files[filepath.Base(path)] = string(data)
+	}
+	return files
+}
+
+// =============================================================================
+// TestTranscriptMiddleware
+// =============================================================================
+
+func TestTranscriptMiddleware(t *testing.T) {
+	t.Run("records chat completions per conversation", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		dir := t.TempDir()
+		server.Transcripts = transcript.NewWriter(&config.Config{TranscriptDir: dir, TranscriptFormat: transcript.FormatJSONL})
+		server.Cfg.ConversationIDMode = "header"
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello, Ada!"}`))
+		server.HttpClient = fake
+
+		w := postConversation(router, "/v1/chat/completions", "chat-1", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"I am Ada"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		files := transcriptFiles(t, dir)
+		assert.Len(t, files, 1)
+		kiroID := fake.Requests()[0].Payload.(*converter.KiroPayload).ConversationState.ConversationID
+		for name, content := range files {
+			assert.Equal(t, kiroID+".jsonl", name)
+			assert.Contains(t, content, `"prompt":"I am Ada"`)
+			assert.Contains(t, content, `"content":"Hello, Ada!"`)
+			assert.Contains(t, content, `"model":"claude-sonnet-4"`)
+		}
+	})
+
+	t.Run("records streamed Anthropic messages with thinking", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		dir := t.TempDir()
+		server.Transcripts = transcript.NewWriter(&config.Config{TranscriptDir: dir, TranscriptFormat: transcript.FormatMarkdown})
+		server.Cfg.FakeReasoningEnabled = true
+		server.Cfg.FakeReasoningHandling = "as_reasoning_content"
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Greet back.</thinking>Hello!"}`))
+
+		w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"stream":true,"messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		for _, content := range transcriptFiles(t, dir) {
+			assert.Contains(t, content, "**User**\n\nHi")
+			assert.Contains(t, content, "> Greet back.")
+			assert.Contains(t, content, "**Assistant**\n\nHello!")
+		}
+	})
+
+	t.Run("records the Anthropic prefill and inline thinking as sent", func(t *testing.T) {
+		for _, streaming := range []string{"false", "true"} {
+			server, router := newTestServer("test-key")
+			dir := t.TempDir()
+			server.Transcripts = transcript.NewWriter(&config.Config{TranscriptDir: dir, TranscriptFormat: transcript.FormatJSONL})
+			server.Cfg.FakeReasoningEnabled = true
+			server.Cfg.FakeReasoningHandling = "strip_tags"
+			server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Greet back.</thinking>there!"}`))
+
+			w := postJSON(router, "/v1/messages", `{"model":"claude-sonnet-4","max_tokens":100,"stream":`+streaming+`,
+				"messages":[{"role":"user","content":"Hi"},{"role":"assistant","content":"Hello "}]}`)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			files := transcriptFiles(t, dir)
+			assert.Len(t, files, 1)
+			for _, content := range files {
+				assert.Contains(t, content, `"content":"Hello Greet back.there!"`, "stream: %s", streaming)
+				assert.NotContains(t, content, `"thinking"`, "stream: %s", streaming)
+			}
+		}
+	})
+
+	t.Run("does not record thinking that was not sent", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		dir := t.TempDir()
+		server.Transcripts = transcript.NewWriter(&config.Config{TranscriptDir: dir, TranscriptFormat: transcript.FormatJSONL})
+		server.Cfg.FakeReasoningEnabled = true
+		server.Cfg.FakeReasoningHandling = "pass"
+		server.HttpClient = clienttest.NewFake(clienttest.Stream(`{"content":"<thinking>Greet back.</thinking>Hello!"}`))
+
+		w := postJSON(router, "/v1/responses", `{"model":"claude-sonnet-4","stream":true,"input":"Hi"}`)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		files := transcriptFiles(t, dir)
+		assert.Len(t, files, 1)
+		for _, content := range files {
+			assert.Contains(t, content, `"content":"Hello!"`)
+			assert.NotContains(t, content, "Greet back.")
+		}
+	})
+
+	t.Run("failed requests are not recorded", func(t *testing.T) {
+		server, router := newTestServer("test-key")
+		dir := t.TempDir()
+		server.Transcripts = transcript.NewWriter(&config.Config{TranscriptDir: dir})
+		server.HttpClient = clienttest.NewFake(clienttest.Response{StatusCode: http.StatusBadRequest, Body: `{"message":"bad request"}`})
+
+		postJSON(router, "/v1/chat/completions", `{"model":"claude-sonnet-4","messages":[{"role":"user","content":"Hi"}]}`)
+
+		assert.Empty(t, transcriptFiles(t, dir))
+	})
+}
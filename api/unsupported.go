@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ignoredParamsHeader lists the request parameters Kiro could not honor when
+// UNSUPPORTED_PARAMS is "warn"
+const ignoredParamsHeader = "x-kiro-ignored-params"
+
+// checkUnsupportedParams applies UNSUPPORTED_PARAMS to the parameters of req that
+// Kiro cannot honor. It returns false after answering 400 in "reject" mode; in
+// "warn" mode the parameters are listed in the x-kiro-ignored-params header.
+func checkUnsupportedParams(c *gin.Context, cfg *config.Config, req *converter.OpenAIRequest) bool {
+	params := req.UnsupportedParams()
+	if len(params) == 0 {
+		return true
+	}
+
+	switch cfg.UnsupportedParams {
+	case "reject":
+		c.JSON(http.StatusBadRequest, openAIValidationBody(unsupportedParamsError(params)))
+		return false
+	case "warn":
+		c.Header(ignoredParamsHeader, strings.Join(params, ","))
+	}
+	return true
+}
+
+// unsupportedParamsError is the validation error of a request rejected for
+// parameters Kiro cannot honor
+func unsupportedParamsError(params []string) error {
+	return &converter.FieldError{
+		Field:   params[0],
+		Message: fmt.Sprintf("not supported by Kiro (unsupported parameters: %s)", strings.Join(params, ", ")),
+	}
+}
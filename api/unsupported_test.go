@@ -0,0 +1,69 @@
+// Package api provides tests for handling parameters Kiro cannot honor.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+)
+
+// =============================================================================
+// TestUnsupportedParams
+// =============================================================================
+
+func TestUnsupportedParams(t *testing.T) {
+	body := `{"model":"claude-sonnet-4.5","messages":[{"role":"user","content":"Hi"}],"logprobs":true,"seed":7}`
+
+	chat := func(mode string) (*httptest.ResponseRecorder, *clienttest.Fake) {
+		server, router := newTestServer("test-key")
+		server.Cfg.UnsupportedParams = mode
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+		server.HttpClient = fake
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer test-key")
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+		return w, fake
+	}
+
+	t.Run("silent answers with stub logprobs", func(t *testing.T) {
+		w, _ := chat("silent")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get(ignoredParamsHeader))
+		var response struct {
+			Choices []struct {
+				LogProbs map[string]interface{} `json:"logprobs"`
+			} `json:"choices"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		if assert.Len(t, response.Choices, 1) {
+			assert.Contains(t, response.Choices[0].LogProbs, "content")
+			assert.Nil(t, response.Choices[0].LogProbs["content"])
+		}
+	})
+
+	t.Run("warn lists the ignored parameters", func(t *testing.T) {
+		w, _ := chat("warn")
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "logprobs,seed", w.Header().Get(ignoredParamsHeader))
+		assert.Contains(t, w.Body.String(), `"logprobs":{"content":null}`)
+	})
+
+	t.Run("reject answers 400 before calling Kiro", func(t *testing.T) {
+		w, fake := chat("reject")
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"param":"logprobs"`)
+		assert.Contains(t, w.Body.String(), "logprobs, seed")
+		assert.Empty(t, fake.Requests())
+	})
+}
@@ -0,0 +1,185 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/stream"
+	"kiro-go-proxy/usage"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/websocket"
+)
+
+// wsDone ends the response to one request, like the SSE [DONE] marker
+const wsDone = "[DONE]"
+
+// ChatWebSocketHandler handles GET /ws/chat. Each text message from the client is a
+// chat completion request; the response is streamed back as one chat.completion.chunk
+// JSON message per delta, followed by "[DONE]". Requests on a socket run one at a time,
+// and each counts against the key's rate limit and quota. A socket idle for
+// WEBSOCKET_IDLE_TIMEOUT is closed, giving up its concurrency slot.
+func (s *Server) ChatWebSocketHandler(c *gin.Context) {
+	apiKey := c.GetString(apiKeyContextKey)
+	server := websocket.Server{
+		Handshake: func(_ *websocket.Config, r *http.Request) error {
+			return checkWebSocketOrigin(s.currentConfig(), r)
+		},
+		Handler: func(conn *websocket.Conn) {
+			defer conn.Close()
+
+			cfg := s.currentConfig()
+			// Frames share the HTTP body limit; 0 keeps the library default
+			conn.MaxPayloadBytes = cfg.MaxRequestBodyBytes
+			idle := time.Duration(cfg.WebSocketIdleTimeout * float64(time.Second))
+
+			for {
+				// The server's read/write timeouts would otherwise cut long-lived
+				// sockets; only waiting for the next request is bounded
+				if idle > 0 {
+					conn.SetDeadline(time.Now().Add(idle))
+				} else {
+					conn.SetDeadline(time.Time{})
+				}
+
+				var message string
+				if err := websocket.Message.Receive(conn, &message); err != nil {
+					if err != io.EOF {
+						log.Debugf("WebSocket receive failed: %v", err)
+					}
+					return
+				}
+				conn.SetDeadline(time.Time{})
+				s.handleWebSocketChat(c.Request.Context(), conn, apiKey, message)
+			}
+		},
+	}
+	server.ServeHTTP(c.Writer, c.Request)
+}
+
+// checkWebSocketOrigin allows sockets from WEBSOCKET_ORIGINS and from the
+// gateway's own origin. Requests without an Origin header are not from a browser
+// and are allowed: the API key authenticates them.
+func checkWebSocketOrigin(cfg *config.Config, r *http.Request) error {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return nil
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return nil
+	}
+	for _, allowed := range cfg.WebSocketOrigins {
+		if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+			return nil
+		}
+	}
+	return fmt.Errorf("origin %q is not allowed", origin)
+}
+
+// handleWebSocketChat runs one chat completion request received on conn, with the
+// rate limit, quota and usage accounting of an HTTP request
+func (s *Server) handleWebSocketChat(parent context.Context, conn *websocket.Conn, apiKey, message string) {
+	ctx, cancel := context.WithCancel(parent)
+	defer cancel()
+	w := &wsWriter{conn: conn, cancel: cancel}
+
+	if ok, retryAfter := s.RateLimiter.Allow(apiKey); !ok {
+		w.sendJSON(gin.H{"error": gin.H{
+			"message": fmt.Sprintf("Rate limit exceeded, retry in %d seconds", int(math.Ceil(retryAfter.Seconds()))),
+			"type":    "rate_limit_error",
+		}})
+		return
+	}
+	if err := s.Usage.CheckQuota(apiKey); err != nil {
+		w.sendJSON(gin.H{"error": gin.H{"message": err.Error(), "type": "insufficient_quota"}})
+		return
+	}
+	request := usage.NewRequest()
+	ctx = usage.WithRequest(ctx, request)
+	defer func() {
+		if model, totals := request.Result(); model != "" {
+			s.Usage.Record(apiKey, model, totals)
+		}
+	}()
+
+	var req converter.OpenAIRequest
+	if err := decodeRequest(s.currentConfig(), []byte(message), &req, converter.OpenAIRequestFields); err != nil {
+		w.sendJSON(openAIValidationBody(err))
+		return
+	}
+
+	prepared, _, errBody := s.prepareChatCompletion(ctx, &req)
+	if prepared == nil {
+		w.sendJSON(errBody)
+		return
+	}
+	cfg := prepared.cfg
+
+	apiURL := fmt.Sprintf("%s/generateAssistantResponse", s.AuthManager.APIHost())
+	resp, err := s.postStream(ctx, prepared.cfg, apiURL, prepared.payload)
+	if err != nil {
+		_, body := requestFailedBody(err)
+		w.sendJSON(body)
+		return
+	}
+	defer resp.Body.Close()
+
+	// Each chunk is its own message, so no SSE framing; keep-alives become ping frames
+	events := stream.StreamToOpenAIFramed(ctx, resp, req.Model, prepared.conversationID, cfg.FirstTokenTimeout, true, cfg, s.ModelCache, prepared.promptTokens, prepared.limits, stream.RawJSON)
+	events = stream.WithKeepAlive(events, keepAliveInterval(cfg), wsPing)
+
+	writeEvents(ctx, w, events)
+	w.WriteEvent(wsDone)
+}
+
+// wsPing is the keep-alive event that wsWriter sends as a ping frame
+const wsPing = ""
+
+// wsWriter sends stream events as WebSocket text messages. After the first failed
+// write it cancels the request and drops the remaining events.
+type wsWriter struct {
+	conn   *websocket.Conn
+	cancel context.CancelFunc
+	failed bool
+}
+
+func (w *wsWriter) WriteEvent(event string) {
+	if w.failed {
+		return
+	}
+
+	var err error
+	if event == wsPing {
+		w.conn.PayloadType = websocket.PingFrame
+		_, err = w.conn.Write(nil)
+		w.conn.PayloadType = websocket.TextFrame
+	} else {
+		err = websocket.Message.Send(w.conn, event)
+	}
+
+	if err != nil {
+		log.Debugf("WebSocket send failed, cancelling request: %v", err)
+		w.failed = true
+		w.cancel()
+	}
+}
+
+// Flush is a no-op: every event is sent as its own message
+func (w *wsWriter) Flush() {}
+
+// sendJSON sends an error or other JSON body followed by the end marker
+func (w *wsWriter) sendJSON(body gin.H) {
+	b, _ := json.Marshal(body)
+	w.WriteEvent(string(b))
+	w.WriteEvent(wsDone)
+}
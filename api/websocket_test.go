@@ -0,0 +1,147 @@
+// Package api provides tests for the WebSocket streaming transport.
+package api
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/ratelimit"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/websocket"
+)
+
+// dialChatWebSocket connects to /ws/chat on a test server with the given API key
+func dialChatWebSocket(t *testing.T, apiKey string) (*websocket.Conn, error) {
+	return dialChatWebSocketWith(t, apiKey, "", nil)
+}
+
+// dialChatWebSocketWith is dialChatWebSocket from origin (the server's own when
+// empty) with setup applied to the server's config first
+func dialChatWebSocketWith(t *testing.T, apiKey, origin string, setup func(cfg *config.Config)) (*websocket.Conn, error) {
+	server, router := newTestServer("test-key")
+	if setup != nil {
+		setup(server.Cfg)
+		server.RateLimiter = ratelimit.NewLimiter(server.Cfg)
+	}
+	srv := httptest.NewServer(router)
+	t.Cleanup(srv.Close)
+
+	if origin == "" {
+		origin = srv.URL
+	}
+	cfg, err := websocket.NewConfig("ws"+strings.TrimPrefix(srv.URL, "http")+"/ws/chat", origin)
+	assert.NoError(t, err)
+	if apiKey != "" {
+		cfg.Header.Set("Authorization", "Bearer "+apiKey)
+	}
+	return websocket.DialConfig(cfg)
+}
+
+// receiveUntilDone collects messages until the [DONE] marker
+func receiveUntilDone(t *testing.T, conn *websocket.Conn) []string {
+	var messages []string
+	for {
+		var message string
+		if !assert.NoError(t, websocket.Message.Receive(conn, &message)) {
+			return messages
+		}
+		if message == wsDone {
+			return messages
+		}
+		messages = append(messages, message)
+	}
+}
+
+// =============================================================================
+// TestChatWebSocketHandler
+// =============================================================================
+
+func TestChatWebSocketHandler(t *testing.T) {
+	t.Run("requires authentication", func(t *testing.T) {
+		_, err := dialChatWebSocket(t, "")
+		assert.Error(t, err)
+
+		_, err = dialChatWebSocket(t, "wrong-key")
+		assert.Error(t, err)
+	})
+
+	t.Run("reports invalid requests and keeps the socket open", func(t *testing.T) {
+		conn, err := dialChatWebSocket(t, "test-key")
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		requests := []struct {
+			body string
+			want string
+		}{
+			{`{"model": `, "Invalid request"},
+			{`{"model": "claude-sonnet-4.5", "messages": [{"role": "user", "content": "Hi"}], "reasoning_effort": "extreme"}`, "reasoning_effort"},
+		}
+		for _, r := range requests {
+			assert.NoError(t, websocket.Message.Send(conn, r.body))
+
+			messages := receiveUntilDone(t, conn)
+			assert.Len(t, messages, 1)
+
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(messages[0]), &body))
+			errBody := body["error"].(map[string]interface{})
+			assert.Equal(t, "invalid_request_error", errBody["type"])
+			assert.Contains(t, errBody["message"], r.want)
+		}
+	})
+
+	t.Run("only allows its own and configured origins", func(t *testing.T) {
+		_, err := dialChatWebSocketWith(t, "test-key", "https://evil.example", nil)
+		assert.Error(t, err)
+
+		conn, err := dialChatWebSocketWith(t, "test-key", "https://app.example", func(cfg *config.Config) {
+			cfg.WebSocketOrigins = []string{"https://app.example"}
+		})
+		if assert.NoError(t, err) {
+			conn.Close()
+		}
+	})
+
+	t.Run("rate limits each message", func(t *testing.T) {
+		// Opening the socket is the first request of the minute
+		conn, err := dialChatWebSocketWith(t, "test-key", "", func(cfg *config.Config) {
+			cfg.RateLimitRPM = 2
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		var types []string
+		for i := 0; i < 2; i++ {
+			assert.NoError(t, websocket.Message.Send(conn, `{"model": `))
+			messages := receiveUntilDone(t, conn)
+			var body map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(messages[0]), &body))
+			types = append(types, body["error"].(map[string]interface{})["type"].(string))
+		}
+		assert.Equal(t, []string{"invalid_request_error", "rate_limit_error"}, types)
+	})
+
+	t.Run("closes idle sockets", func(t *testing.T) {
+		conn, err := dialChatWebSocketWith(t, "test-key", "", func(cfg *config.Config) {
+			cfg.WebSocketIdleTimeout = 0.05
+		})
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		var message string
+		assert.Error(t, websocket.Message.Receive(conn, &message))
+	})
+}
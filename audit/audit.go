@@ -0,0 +1,285 @@
+// Package audit keeps an append-only log of prompts and completions for
+// compliance reviews.
+//
+// With AUDIT_FILE set, every chat request is written to the file as one JSON
+// line. Each record carries the hash of the record before it, so editing or
+// removing a line breaks the chain from that point on (see Search). How much of
+// the prompt and completion a record keeps is the redaction level of the API
+// key: nothing but their SHA-256 ("metadata"), the text with PII patterns
+// redacted ("pii"), or the full text ("full").
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/piifilter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Redaction levels
+const (
+	LevelOff      = "off"
+	LevelMetadata = "metadata"
+	LevelPII      = "pii"
+	LevelFull     = "full"
+)
+
+// maxLineBytes bounds a record line when the file is read back
+const maxLineBytes = 64 * 1024 * 1024
+
+// Record is one audited request
+type Record struct {
+	Seq              int64     `json:"seq"`
+	Time             time.Time `json:"time"`
+	RequestID        string    `json:"request_id,omitempty"`
+	KeyID            string    `json:"key_id"`
+	Method           string    `json:"method"`
+	Path             string    `json:"path"`
+	Status           int       `json:"status"`
+	Model            string    `json:"model,omitempty"`
+	ResolvedModel    string    `json:"resolved_model,omitempty"`
+	PromptTokens     int       `json:"prompt_tokens"`
+	CompletionTokens int       `json:"completion_tokens"`
+	DurationMs       int64     `json:"duration_ms"`
+	Redaction        string    `json:"redaction"`
+	PromptSHA256     string    `json:"prompt_sha256"`
+	CompletionSHA256 string    `json:"completion_sha256"`
+	Prompt           string    `json:"prompt,omitempty"`
+	Completion       string    `json:"completion,omitempty"`
+	PrevHash         string    `json:"prev_hash"`
+	Hash             string    `json:"hash"`
+}
+
+// Completion receives the client response as it is written. It hashes every
+// chunk and keeps the text only at the levels that store it, so a metadata
+// record of a long stream costs no more memory than its digest.
+type Completion struct {
+	hash hash.Hash
+	text *bytes.Buffer
+}
+
+// NewCompletion returns a Completion for a record at the given redaction level
+func NewCompletion(level string) *Completion {
+	c := &Completion{hash: sha256.New()}
+	if level == LevelPII || level == LevelFull {
+		c.text = &bytes.Buffer{}
+	}
+	return c
+}
+
+func (c *Completion) Write(data []byte) (int, error) {
+	c.hash.Write(data)
+	if c.text != nil {
+		c.text.Write(data)
+	}
+	return len(data), nil
+}
+
+// SetContent stores the prompt and completion (the client's request and
+// response bodies) at the given redaction level. The digests are of the
+// original text, so a disputed prompt can be checked against a metadata record.
+// filter is used at LevelPII.
+func (r *Record) SetContent(level string, prompt []byte, completion *Completion, filter *piifilter.Filter) {
+	r.Redaction = level
+	r.PromptSHA256 = digest(prompt)
+	r.CompletionSHA256 = hex.EncodeToString(completion.hash.Sum(nil))
+
+	switch level {
+	case LevelFull:
+		r.Prompt, r.Completion = string(prompt), completion.text.String()
+	case LevelPII:
+		found := make(map[string]int)
+		r.Prompt = filter.Redact(string(prompt), found)
+		r.Completion = filter.Redact(completion.text.String(), found)
+	}
+}
+
+// hash returns the chain hash of the record: the SHA-256 of its JSON encoding
+// without the hash itself, which includes the previous record's hash
+func (r Record) hash() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	return digest(data)
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log appends records to AUDIT_FILE. A nil *Log is valid and ignores all
+// calls, so callers need no checks when auditing is disabled.
+type Log struct {
+	path string
+	seq  int64
+	last string
+	mu   sync.Mutex
+}
+
+// NewLog opens the audit log at AUDIT_FILE, continuing the chain of the
+// records already in it, or returns nil when AUDIT_FILE is empty
+func NewLog(cfg *config.Config) *Log {
+	if cfg.AuditFile == "" {
+		return nil
+	}
+	l := &Log{path: cfg.AuditFile}
+	if err := l.load(); err != nil {
+		log.Warnf("Failed to read audit log %s, continuing its chain may fail verification: %v", l.path, err)
+	}
+	return l
+}
+
+// load restores the sequence number and hash of the last record
+func (l *Log) load() error {
+	return l.scan(-1, func(r *Record) {
+		l.seq, l.last = r.Seq, r.Hash
+	})
+}
+
+// size returns the length of the file, 0 when it does not exist yet
+func (l *Log) size() (int64, error) {
+	info, err := os.Stat(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// scan calls fn with every record in the first size bytes of the file, oldest
+// first. A negative size reads the whole file.
+func (l *Log) scan(size int64, fn func(*Record)) error {
+	f, err := os.Open(l.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var in io.Reader = f
+	if size >= 0 {
+		in = io.LimitReader(f, size)
+	}
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), maxLineBytes)
+	for line := 1; scanner.Scan(); line++ {
+		var r Record
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		fn(&r)
+	}
+	return scanner.Err()
+}
+
+// Append chains r to the log and writes it. On an error nothing is written
+// and the chain is unchanged, so the record is lost but the log stays valid.
+func (l *Log) Append(r *Record) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq + 1
+	r.Time = r.Time.UTC()
+	r.PrevHash = l.last
+	r.Hash = r.hash()
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(l.path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(l.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	l.seq, l.last = r.Seq, r.Hash
+	return nil
+}
+
+// Query selects records. Zero fields do not filter.
+type Query struct {
+	From  time.Time
+	To    time.Time
+	KeyID string
+	// Limit keeps the first records that match
+	Limit int
+}
+
+func (q Query) matches(r *Record) bool {
+	if !q.From.IsZero() && r.Time.Before(q.From) {
+		return false
+	}
+	if !q.To.IsZero() && !r.Time.Before(q.To) {
+		return false
+	}
+	return q.KeyID == "" || r.KeyID == q.KeyID
+}
+
+// Verification is the result of checking the hash chain
+type Verification struct {
+	Valid bool `json:"valid"`
+	// BrokenAt is the sequence number of the first record that does not chain
+	BrokenAt int64 `json:"broken_at,omitempty"`
+}
+
+// Search returns the records matching q, oldest first, and verifies the
+// chain of the whole log while reading it
+func (l *Log) Search(q Query) ([]Record, Verification, error) {
+	records := []Record{}
+	verification := Verification{Valid: true}
+	if l == nil {
+		return records, verification, nil
+	}
+
+	// Append writes whole records under the lock, so the file ends at a record
+	// here; reading up to that point does not hold up appends
+	l.mu.Lock()
+	size, err := l.size()
+	l.mu.Unlock()
+	if err != nil {
+		return records, verification, err
+	}
+
+	var prev string
+	var seq int64
+	err = l.scan(size, func(r *Record) {
+		seq++
+		if verification.Valid && (r.Seq != seq || r.PrevHash != prev || r.Hash != r.hash()) {
+			verification = Verification{Valid: false, BrokenAt: seq}
+		}
+		prev = r.Hash
+		if q.matches(r) && (q.Limit <= 0 || len(records) < q.Limit) {
+			records = append(records, *r)
+		}
+	})
+	return records, verification, err
+}
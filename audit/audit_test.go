@@ -0,0 +1,192 @@
+// Package audit provides tests for the audit log.
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/piifilter"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestLog(t *testing.T) *Log {
+	return NewLog(&config.Config{AuditFile: filepath.Join(t.TempDir(), "audit", "audit.jsonl")})
+}
+
+func appendRecord(t *testing.T, l *Log, keyID string, at time.Time) {
+	assert.NoError(t, l.Append(&Record{Time: at, KeyID: keyID, Method: "POST", Path: "/v1/chat/completions", Status: 200}))
+}
+
+// =============================================================================
+// TestLog
+// =============================================================================
+
+func TestLog(t *testing.T) {
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("disabled without AUDIT_FILE", func(t *testing.T) {
+		l := NewLog(&config.Config{})
+
+		assert.Nil(t, l)
+		assert.NoError(t, l.Append(&Record{}))
+		records, verification, err := l.Search(Query{})
+		assert.NoError(t, err)
+		assert.Empty(t, records)
+		assert.True(t, verification.Valid)
+	})
+
+	t.Run("chains records", func(t *testing.T) {
+		l := newTestLog(t)
+		appendRecord(t, l, "a", start)
+		appendRecord(t, l, "b", start.Add(time.Minute))
+
+		records, verification, err := l.Search(Query{})
+
+		assert.NoError(t, err)
+		assert.True(t, verification.Valid)
+		assert.Len(t, records, 2)
+		assert.Equal(t, int64(1), records[0].Seq)
+		assert.Equal(t, "", records[0].PrevHash)
+		assert.Equal(t, records[0].Hash, records[1].PrevHash)
+		assert.Len(t, records[1].Hash, 64)
+
+		info, err := os.Stat(l.path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("continues the chain after reopening", func(t *testing.T) {
+		l := newTestLog(t)
+		appendRecord(t, l, "a", start)
+
+		reopened := NewLog(&config.Config{AuditFile: l.path})
+		appendRecord(t, reopened, "a", start.Add(time.Minute))
+
+		records, verification, err := reopened.Search(Query{})
+		assert.NoError(t, err)
+		assert.True(t, verification.Valid)
+		assert.Equal(t, int64(2), records[1].Seq)
+	})
+
+	t.Run("detects edited records", func(t *testing.T) {
+		l := newTestLog(t)
+		appendRecord(t, l, "a", start)
+		appendRecord(t, l, "a", start.Add(time.Minute))
+		appendRecord(t, l, "a", start.Add(2*time.Minute))
+
+		data, _ := os.ReadFile(l.path)
+		lines := strings.SplitAfter(string(data), "\n")
+		lines[1] = strings.Replace(lines[1], `"status":200`, `"status":500`, 1)
+		assert.NoError(t, os.WriteFile(l.path, []byte(strings.Join(lines, "")), 0600))
+
+		_, verification, err := l.Search(Query{})
+		assert.NoError(t, err)
+		assert.False(t, verification.Valid)
+		assert.Equal(t, int64(2), verification.BrokenAt)
+	})
+
+	t.Run("detects removed records", func(t *testing.T) {
+		l := newTestLog(t)
+		appendRecord(t, l, "a", start)
+		appendRecord(t, l, "a", start.Add(time.Minute))
+
+		data, _ := os.ReadFile(l.path)
+		lines := strings.SplitAfter(string(data), "\n")
+		assert.NoError(t, os.WriteFile(l.path, []byte(lines[1]), 0600))
+
+		_, verification, _ := l.Search(Query{})
+		assert.False(t, verification.Valid)
+		assert.Equal(t, int64(1), verification.BrokenAt)
+	})
+
+	t.Run("filters by time range, key and limit", func(t *testing.T) {
+		l := newTestLog(t)
+		appendRecord(t, l, "a", start)
+		appendRecord(t, l, "b", start.Add(time.Minute))
+		appendRecord(t, l, "a", start.Add(2*time.Minute))
+		appendRecord(t, l, "a", start.Add(3*time.Minute))
+
+		records, _, _ := l.Search(Query{From: start.Add(time.Minute), To: start.Add(3 * time.Minute)})
+		assert.Len(t, records, 2)
+		assert.Equal(t, int64(2), records[0].Seq)
+
+		records, _, _ = l.Search(Query{KeyID: "a", Limit: 2})
+		assert.Len(t, records, 2)
+		assert.Equal(t, int64(3), records[1].Seq)
+	})
+
+	t.Run("searches while records are appended", func(t *testing.T) {
+		l := newTestLog(t)
+		appendRecord(t, l, "a", start)
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 1; i <= 50; i++ {
+				appendRecord(t, l, "a", start.Add(time.Duration(i)*time.Minute))
+			}
+		}()
+		for i := 0; i < 20; i++ {
+			records, verification, err := l.Search(Query{})
+			assert.NoError(t, err)
+			assert.True(t, verification.Valid)
+			assert.NotEmpty(t, records)
+		}
+		wg.Wait()
+	})
+}
+
+// =============================================================================
+// TestSetContent
+// =============================================================================
+
+func TestSetContent(t *testing.T) {
+	prompt := []byte(`{"messages":[{"role":"user","content":"Mail jane@example.com"}]}`)
+	completion := []byte(`{"content":"Done"}`)
+	filter, _ := piifilter.New([]string{"email"}, nil)
+	written := func(level string) *Completion {
+		c := NewCompletion(level)
+		c.Write(completion[:5])
+		c.Write(completion[5:])
+		return c
+	}
+
+	t.Run("metadata keeps only digests", func(t *testing.T) {
+		var r Record
+		r.SetContent(LevelMetadata, prompt, written(LevelMetadata), filter)
+
+		assert.Equal(t, "metadata", r.Redaction)
+		assert.Equal(t, digest(prompt), r.PromptSHA256)
+		assert.Equal(t, digest(completion), r.CompletionSHA256)
+		assert.Empty(t, r.Prompt)
+		assert.Empty(t, r.Completion)
+	})
+
+	t.Run("metadata does not buffer the completion", func(t *testing.T) {
+		assert.Nil(t, written(LevelMetadata).text)
+	})
+
+	t.Run("pii redacts the text", func(t *testing.T) {
+		var r Record
+		r.SetContent(LevelPII, prompt, written(LevelPII), filter)
+
+		assert.Contains(t, r.Prompt, "Mail [REDACTED:email]")
+		assert.Equal(t, string(completion), r.Completion)
+		assert.Equal(t, digest(prompt), r.PromptSHA256)
+	})
+
+	t.Run("full keeps the text", func(t *testing.T) {
+		var r Record
+		r.SetContent(LevelFull, prompt, written(LevelFull), filter)
+
+		assert.Equal(t, string(prompt), r.Prompt)
+		assert.Equal(t, string(completion), r.Completion)
+	})
+}
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math/rand"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -15,6 +16,8 @@ import (
 	"time"
 
 	"kiro-go-proxy/config"
+	"kiro-go-proxy/redact"
+	"kiro-go-proxy/transport"
 
 	"github.com/google/uuid"
 	log "github.com/sirupsen/logrus"
@@ -77,6 +80,16 @@ type Manager struct {
 	region       string
 	credsFile    string
 	sqliteDB     string
+	awsProfile   string
+
+	// Token file of awsProfile's `aws sso login` session
+	ssoCacheFile string
+
+	// Encryption of credsFile at rest (nil stores plaintext). When the key could
+	// not be obtained credsCipherErr is set and the file is never rewritten in
+	// plaintext.
+	credsCipher    *CredsCipher
+	credsCipherErr error
 
 	// AWS SSO OIDC specific
 	clientID     string
@@ -91,12 +104,19 @@ type Manager struct {
 	accessToken string
 	expiresAt   time.Time
 
+	// Result of the last refresh attempt, for health reporting
+	lastRefresh      time.Time
+	lastRefreshError string
+
 	// Auth type
 	authType AuthType
 
 	// Tracking which SQLite key was used
 	sqliteTokenKey string
 
+	// Version of the SQLite database last loaded or saved, for the watcher
+	sqliteStamp [2]fileStamp
+
 	// URLs
 	refreshURL string
 	apiHost    string
@@ -105,10 +125,35 @@ type Manager struct {
 	// Fingerprint
 	fingerprint string
 
+	// HTTP client for token refresh, backed by the shared transport
+	httpClient *http.Client
+
+	// Background refresh scheduler and kiro-cli database watcher
+	stopCh           chan struct{}
+	workers          sync.WaitGroup
+	refresherStarted bool
+	watcherStarted   bool
+	stopOnce         sync.Once
+
 	// Mutex for thread-safe operations
 	mu sync.RWMutex
+
+	// Token refresh shared by concurrent callers, guarded by flightMu
+	flightMu      sync.Mutex
+	flight        *refreshFlight
+	generation    uint64
+	lastFlightErr error
+	refreshStats  RefreshStats
 }
 
+// Background refresh timing
+const (
+	refreshRetryBaseDelay = 5 * time.Second
+	refreshRetryMaxDelay  = 5 * time.Minute
+	refreshMaxSleep       = 5 * time.Minute
+	refreshMinSleep       = time.Second
+)
+
 // NewManager creates a new authentication manager
 func NewManager(cfg *config.Config) *Manager {
 	m := &Manager{
@@ -118,7 +163,9 @@ func NewManager(cfg *config.Config) *Manager {
 		region:       cfg.Region,
 		credsFile:    cfg.KiroCredsFile,
 		sqliteDB:     cfg.KiroCLIDBFile,
+		awsProfile:   cfg.AWSSSOProfile,
 		fingerprint:  generateFingerprint(),
+		httpClient:   transport.NewClient(cfg, 30*time.Second),
 	}
 
 	// Set URLs
@@ -130,7 +177,13 @@ func NewManager(cfg *config.Config) *Manager {
 	if m.sqliteDB != "" {
 		m.loadCredentialsFromSQLite(m.sqliteDB)
 	} else if m.credsFile != "" {
+		m.credsCipher, m.credsCipherErr = NewCredsCipher(cfg)
+		if m.credsCipherErr != nil {
+			log.Errorf("Credentials encryption unavailable: %v", m.credsCipherErr)
+		}
 		m.loadCredentialsFromFile(m.credsFile)
+	} else if m.awsProfile != "" {
+		m.loadCredentialsFromAWSProfile(m.awsProfile)
 	}
 
 	// Detect auth type
@@ -160,6 +213,7 @@ func (m *Manager) loadCredentialsFromSQLite(dbPath string) {
 		log.Warnf("SQLite database not found: %s", dbPath)
 		return
 	}
+	m.sqliteStamp = credentialsDBStamp(path)
 
 	db, err := sql.Open("sqlite3", path)
 	if err != nil {
@@ -238,7 +292,8 @@ func (m *Manager) loadCredentialsFromSQLite(dbPath string) {
 	log.Infof("Credentials loaded from SQLite database: %s", dbPath)
 }
 
-// loadCredentialsFromFile loads credentials from a JSON file
+// loadCredentialsFromFile loads credentials from a JSON file, decrypting it when
+// it is encrypted. A plaintext file is left as is; `kiro-gateway encrypt` migrates it.
 func (m *Manager) loadCredentialsFromFile(filePath string) {
 	path := expandPath(filePath)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
@@ -246,7 +301,7 @@ func (m *Manager) loadCredentialsFromFile(filePath string) {
 		return
 	}
 
-	data, err := os.ReadFile(path)
+	data, _, err := readCredentialsFile(path, m.credsCipher)
 	if err != nil {
 		log.Errorf("Error reading credentials file: %v", err)
 		return
@@ -300,6 +355,7 @@ func (m *Manager) loadCredentialsFromFile(filePath string) {
 	}
 
 	log.Infof("Credentials loaded from %s", filePath)
+
 }
 
 // loadEnterpriseDeviceRegistration loads device registration for Enterprise Kiro IDE
@@ -352,14 +408,49 @@ func (m *Manager) IsTokenExpired() bool {
 	return time.Now().After(m.expiresAt)
 }
 
-// GetAccessToken returns a valid access token, refreshing if necessary
+// ExpiresAt returns when the current access token expires (zero if unknown)
+func (m *Manager) ExpiresAt() time.Time {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return m.expiresAt
+}
+
+// GetAccessToken returns a valid access token, refreshing if necessary.
+// Concurrent callers finding the token expired share a single refresh.
 func (m *Manager) GetAccessToken() (string, error) {
+	generation := m.RefreshGeneration()
+
+	if token, ok := m.validAccessToken(); ok {
+		return token, nil
+	}
+
+	// Try to refresh
+	err := m.refreshSince(generation)
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	if err != nil {
+		// Graceful degradation for SQLite mode
+		if m.sqliteDB != "" && m.accessToken != "" && !m.isTokenExpiredUnlocked() {
+			log.Warn("Token refresh failed, using existing token until it expires")
+			return m.accessToken, nil
+		}
+		return "", fmt.Errorf("failed to obtain access token: %w", err)
+	}
+
+	return m.accessToken, nil
+}
+
+// validAccessToken returns the current token if it is not expiring soon,
+// reloading it from SQLite first in SQLite mode
+func (m *Manager) validAccessToken() (string, bool) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	// Token is valid and not expiring soon
 	if m.accessToken != "" && !m.isTokenExpiringSoonUnlocked() {
-		return m.accessToken, nil
+		return m.accessToken, true
 	}
 
 	// SQLite mode: reload credentials first
@@ -368,32 +459,143 @@ func (m *Manager) GetAccessToken() (string, error) {
 		m.loadCredentialsFromSQLite(m.sqliteDB)
 		if m.accessToken != "" && !m.isTokenExpiringSoonUnlocked() {
 			log.Debug("SQLite reload provided fresh token, no refresh needed")
-			return m.accessToken, nil
+			return m.accessToken, true
 		}
 	}
+	return "", false
+}
 
-	// Try to refresh
-	if err := m.refreshTokenRequest(); err != nil {
-		// Graceful degradation for SQLite mode
-		if m.sqliteDB != "" && m.accessToken != "" && !m.isTokenExpiredUnlocked() {
-			log.Warn("Token refresh failed, using existing token until it expires")
-			return m.accessToken, nil
+// StartRefresher starts a background goroutine that refreshes the token
+// before it expires, so requests never wait on a lazy refresh.
+func (m *Manager) StartRefresher() {
+	if m.startWorker(&m.refresherStarted, m.refreshLoop) {
+		log.Debug("Background token refresher started")
+	}
+}
+
+// startWorker runs fn in the background until Stop unless started is already
+// set, reporting whether it started it
+func (m *Manager) startWorker(started *bool, fn func()) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if *started {
+		return false
+	}
+	*started = true
+	if m.stopCh == nil {
+		m.stopCh = make(chan struct{})
+	}
+
+	m.workers.Add(1)
+	go func() {
+		defer m.workers.Done()
+		fn()
+	}()
+	return true
+}
+
+// Stop stops the background refresher and watcher and waits for them to exit
+func (m *Manager) Stop() {
+	m.mu.RLock()
+	stopCh := m.stopCh
+	m.mu.RUnlock()
+
+	if stopCh == nil {
+		return
+	}
+
+	m.stopOnce.Do(func() {
+		close(stopCh)
+	})
+	m.workers.Wait()
+}
+
+func (m *Manager) refreshLoop() {
+	failures := 0
+	for {
+		select {
+		case <-time.After(m.nextRefreshDelay(failures)):
+		case <-m.stopCh:
+			log.Debug("Background token refresher stopped")
+			return
 		}
-		return "", fmt.Errorf("failed to obtain access token: %w", err)
+
+		if !m.IsTokenExpiringSoon() {
+			failures = 0
+			continue
+		}
+
+		log.Debug("Token expiring soon, refreshing in background")
+		if _, err := m.GetAccessToken(); err != nil {
+			failures++
+			log.Warnf("Background token refresh failed (attempt %d): %v", failures, err)
+			continue
+		}
+
+		// Degraded mode may return the old token without refreshing
+		if m.IsTokenExpiringSoon() {
+			failures++
+			continue
+		}
+		failures = 0
 	}
+}
 
-	return m.accessToken, nil
+// nextRefreshDelay returns how long to sleep before the next refresh check.
+// Failures back off exponentially; otherwise the loop wakes when the token
+// enters the refresh threshold. Jitter spreads refreshes across pool accounts.
+func (m *Manager) nextRefreshDelay(failures int) time.Duration {
+	var delay time.Duration
+	if failures > 0 {
+		delay = refreshRetryBaseDelay << uint(failures-1)
+		if delay > refreshRetryMaxDelay || delay <= 0 {
+			delay = refreshRetryMaxDelay
+		}
+	} else {
+		m.mu.RLock()
+		expiresAt := m.expiresAt
+		m.mu.RUnlock()
+
+		threshold := time.Duration(m.cfg.TokenRefreshThreshold) * time.Second
+		delay = time.Until(expiresAt.Add(-threshold))
+		if delay > refreshMaxSleep {
+			delay = refreshMaxSleep
+		}
+	}
+
+	if delay < refreshMinSleep {
+		delay = refreshMinSleep
+	}
+
+	// Add up to 10% jitter
+	return delay + time.Duration(rand.Int63n(int64(delay)/10+1))
 }
 
-// ForceRefresh forces a token refresh
+// ForceRefresh forces a token refresh, or waits for the one already in progress
 func (m *Manager) ForceRefresh() (string, error) {
+	return m.RefreshSince(m.RefreshGeneration())
+}
+
+// ReloadCredentials re-reads the credentials file, kiro-cli database or AWS SSO
+// token cache, picking up
+// tokens rotated outside the gateway. It reports whether there was a source to read.
+func (m *Manager) ReloadCredentials() bool {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	if err := m.refreshTokenRequest(); err != nil {
-		return "", err
+	if m.sqliteDB != "" {
+		m.loadCredentialsFromSQLite(m.sqliteDB)
+	} else if m.credsFile != "" {
+		m.loadCredentialsFromFile(m.credsFile)
+	} else if m.awsProfile != "" {
+		m.loadCredentialsFromAWSProfile(m.awsProfile)
+	} else {
+		return false
 	}
-	return m.accessToken, nil
+
+	m.detectAuthType()
+	return true
 }
 
 func (m *Manager) isTokenExpiringSoonUnlocked() bool {
@@ -411,12 +613,29 @@ func (m *Manager) isTokenExpiredUnlocked() bool {
 	return time.Now().After(m.expiresAt)
 }
 
-// refreshTokenRequest performs a token refresh request
+// refreshTokenRequest performs a token refresh request and records its outcome
 func (m *Manager) refreshTokenRequest() error {
+	var err error
 	if m.authType == AuthTypeAWSSSOOIDC {
-		return m.refreshTokenAWSSSOOIDC()
+		err = m.refreshTokenAWSSSOOIDC()
+	} else {
+		err = m.refreshTokenKiroDesktop()
+	}
+
+	m.lastRefresh = time.Now()
+	m.lastRefreshError = ""
+	if err != nil {
+		m.lastRefreshError = err.Error()
 	}
-	return m.refreshTokenKiroDesktop()
+	return err
+}
+
+// LastRefresh returns when a token refresh was last attempted and its error, if it failed.
+// The time is zero when no refresh has been attempted.
+func (m *Manager) LastRefresh() (time.Time, string) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.lastRefresh, m.lastRefreshError
 }
 
 // refreshTokenKiroDesktop refreshes token using Kiro Desktop Auth
@@ -438,8 +657,7 @@ func (m *Manager) refreshTokenKiroDesktop() error {
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", fmt.Sprintf("KiroIDE-0.7.45-%s", m.fingerprint))
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := m.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -447,7 +665,7 @@ func (m *Manager) refreshTokenKiroDesktop() error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+		return fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, redact.Message(string(body)))
 	}
 
 	var result struct {
@@ -478,12 +696,7 @@ func (m *Manager) refreshTokenKiroDesktop() error {
 
 	log.Infof("Token refreshed via Kiro Desktop Auth, expires: %s", m.expiresAt.Format(time.RFC3339))
 
-	// Save credentials
-	if m.sqliteDB != "" {
-		m.saveCredentialsToSQLite()
-	} else {
-		m.saveCredentialsToFile()
-	}
+	m.saveCredentials()
 
 	return nil
 }
@@ -527,8 +740,7 @@ func (m *Manager) refreshTokenAWSSSOOIDC() error {
 	log.Debugf("AWS SSO OIDC refresh request: url=%s, sso_region=%s, api_region=%s",
 		refreshURL, ssoRegion, m.region)
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+	resp, err := m.httpClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -536,8 +748,8 @@ func (m *Manager) refreshTokenAWSSSOOIDC() error {
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		log.Errorf("AWS SSO OIDC refresh failed: status=%d, body=%s", resp.StatusCode, string(body))
-		return fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, string(body))
+		log.Errorf("AWS SSO OIDC refresh failed: status=%d, body=%s", resp.StatusCode, redact.Message(string(body)))
+		return fmt.Errorf("token refresh failed with status %d: %s", resp.StatusCode, redact.Message(string(body)))
 	}
 
 	var result struct {
@@ -563,17 +775,25 @@ func (m *Manager) refreshTokenAWSSSOOIDC() error {
 
 	log.Infof("Token refreshed via AWS SSO OIDC, expires: %s", m.expiresAt.Format(time.RFC3339))
 
-	// Save credentials
-	if m.sqliteDB != "" {
+	m.saveCredentials()
+
+	return nil
+}
+
+// saveCredentials writes refreshed tokens back to the source they were loaded from
+func (m *Manager) saveCredentials() {
+	switch {
+	case m.sqliteDB != "":
 		m.saveCredentialsToSQLite()
-	} else {
+	case m.awsProfile != "":
+		m.saveCredentialsToSSOCache()
+	default:
 		m.saveCredentialsToFile()
 	}
-
-	return nil
 }
 
-// saveCredentialsToFile saves credentials to JSON file
+// saveCredentialsToFile saves credentials to JSON file, encrypted when it already
+// is, or when it is new and CREDS_ENCRYPTION_KEY or CREDS_ENCRYPTION_KEYRING is set
 func (m *Manager) saveCredentialsToFile() {
 	if m.credsFile == "" {
 		return
@@ -581,10 +801,24 @@ func (m *Manager) saveCredentialsToFile() {
 
 	path := expandPath(m.credsFile)
 
-	// Read existing data
+	// Read existing data. The file keeps its format, so a plaintext file shared
+	// with Kiro Desktop or kiro-cli stays readable by them; only a new file is
+	// encrypted.
 	existingData := make(map[string]interface{})
-	if data, err := os.ReadFile(path); err == nil {
+	c := m.credsCipher
+	data, encrypted, err := readCredentialsFile(path, m.credsCipher)
+	switch {
+	case err == nil:
 		json.Unmarshal(data, &existingData)
+		if !encrypted {
+			c = nil
+		}
+	case encrypted:
+		log.Errorf("Not overwriting encrypted credentials file: %v", err)
+		return
+	case m.credsCipherErr != nil:
+		log.Errorf("Not saving credentials in plaintext: %v", m.credsCipherErr)
+		return
 	}
 
 	// Update data
@@ -599,7 +833,7 @@ func (m *Manager) saveCredentialsToFile() {
 
 	// Save
 	jsonData, _ := json.MarshalIndent(existingData, "", "  ")
-	if err := os.WriteFile(path, jsonData, 0600); err != nil {
+	if err := writeCredentialsFile(path, jsonData, c); err != nil {
 		log.Errorf("Error saving credentials: %v", err)
 		return
 	}
@@ -618,6 +852,8 @@ func (m *Manager) saveCredentialsToSQLite() {
 		log.Warnf("SQLite database not found for writing: %s", m.sqliteDB)
 		return
 	}
+	// Our own write is not a rotation for the watcher to reload
+	defer func() { m.sqliteStamp = credentialsDBStamp(path) }()
 
 	db, err := sql.Open("sqlite3", path)
 	if err != nil {
@@ -2,7 +2,10 @@
 package auth
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"kiro-go-proxy/config"
@@ -205,4 +208,119 @@ func TestManagerMethods(t *testing.T) {
 		// Default should be KiroDesktop
 		assert.Equal(t, AuthTypeKiroDesktop, authType)
 	})
+
+	t.Run("LastRefresh records failed refresh", func(t *testing.T) {
+		manager := NewManager(&config.Config{})
+
+		at, errMsg := manager.LastRefresh()
+		assert.True(t, at.IsZero())
+		assert.Empty(t, errMsg)
+
+		_, err := manager.GetAccessToken()
+		assert.Error(t, err)
+		at, errMsg = manager.LastRefresh()
+		assert.False(t, at.IsZero())
+		assert.Equal(t, "refresh token is not set", errMsg)
+	})
+}
+
+// =============================================================================
+// TestBackgroundRefresher
+// Tests for the proactive token refresh scheduler
+// =============================================================================
+
+func TestBackgroundRefresher(t *testing.T) {
+	t.Run("start and stop", func(t *testing.T) {
+		manager := NewManager(&config.Config{TokenRefreshThreshold: 600})
+
+		manager.StartRefresher()
+		manager.StartRefresher() // idempotent
+
+		done := make(chan struct{})
+		go func() {
+			manager.Stop()
+			manager.Stop() // idempotent
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Stop did not return")
+		}
+	})
+
+	t.Run("stop without start is a no-op", func(t *testing.T) {
+		manager := NewManager(&config.Config{})
+		manager.Stop()
+	})
+
+	t.Run("delay waits until refresh threshold", func(t *testing.T) {
+		manager := NewManager(&config.Config{TokenRefreshThreshold: 60})
+		manager.expiresAt = time.Now().Add(3 * time.Minute)
+
+		delay := manager.nextRefreshDelay(0)
+
+		// 3m - 60s threshold = ~2m, plus up to 10% jitter
+		assert.GreaterOrEqual(t, delay, 110*time.Second)
+		assert.LessOrEqual(t, delay, 133*time.Second)
+	})
+
+	t.Run("delay is capped for long-lived tokens", func(t *testing.T) {
+		manager := NewManager(&config.Config{TokenRefreshThreshold: 60})
+		manager.expiresAt = time.Now().Add(24 * time.Hour)
+
+		delay := manager.nextRefreshDelay(0)
+
+		assert.LessOrEqual(t, delay, refreshMaxSleep+refreshMaxSleep/10+1)
+	})
+
+	t.Run("expired token waits minimum delay", func(t *testing.T) {
+		manager := NewManager(&config.Config{TokenRefreshThreshold: 600})
+
+		delay := manager.nextRefreshDelay(0)
+
+		assert.GreaterOrEqual(t, delay, refreshMinSleep)
+		assert.Less(t, delay, 2*refreshMinSleep)
+	})
+
+	t.Run("failures back off exponentially", func(t *testing.T) {
+		manager := NewManager(&config.Config{})
+
+		first := manager.nextRefreshDelay(1)
+		third := manager.nextRefreshDelay(3)
+		many := manager.nextRefreshDelay(20)
+
+		assert.GreaterOrEqual(t, first, refreshRetryBaseDelay)
+		assert.GreaterOrEqual(t, third, 4*refreshRetryBaseDelay)
+		assert.GreaterOrEqual(t, many, refreshRetryMaxDelay)
+		assert.LessOrEqual(t, many, refreshRetryMaxDelay+refreshRetryMaxDelay/10+1)
+	})
+}
+
+// =============================================================================
+// TestReloadCredentials
+// Tests for re-reading rotated credentials
+// =============================================================================
+
+func TestReloadCredentials(t *testing.T) {
+	t.Run("re-reads the credentials file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte(`{"refreshToken": "refresh-1", "accessToken": "access-1"}`), 0600)
+		manager := NewManager(&config.Config{KiroCredsFile: path, Region: "us-east-1"})
+		assert.Equal(t, "access-1", manager.AccessToken())
+
+		os.WriteFile(path, []byte(`{"refreshToken": "refresh-2", "accessToken": "access-2"}`), 0600)
+
+		assert.True(t, manager.ReloadCredentials())
+		assert.Equal(t, "access-2", manager.AccessToken())
+		assert.Equal(t, "refresh-2", manager.RefreshToken())
+	})
+
+	t.Run("refresh token accounts have nothing to reload", func(t *testing.T) {
+		manager := NewManager(&config.Config{RefreshToken: "refresh", Region: "us-east-1"})
+
+		assert.False(t, manager.ReloadCredentials())
+		assert.Equal(t, "refresh", manager.RefreshToken())
+	})
 }
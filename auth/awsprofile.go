@@ -0,0 +1,223 @@
+// Package auth provides authentication management for Kiro API.
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// awsSSOCacheToken is a token file under ~/.aws/sso/cache, as written by
+// `aws sso login`
+type awsSSOCacheToken struct {
+	StartURL     string `json:"startUrl"`
+	Region       string `json:"region"`
+	AccessToken  string `json:"accessToken"`
+	ExpiresAt    string `json:"expiresAt"`
+	RefreshToken string `json:"refreshToken"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
+
+// awsSSOProfile is the SSO part of an ~/.aws/config profile
+type awsSSOProfile struct {
+	// Session is the sso-session the profile refers to, empty for legacy profiles
+	Session  string
+	StartURL string
+	Region   string
+}
+
+// awsDir returns the ~/.aws directory
+func awsDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".aws")
+}
+
+// awsConfigFile returns the AWS CLI config file, honoring AWS_CONFIG_FILE
+func awsConfigFile() string {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return expandPath(path)
+	}
+	return filepath.Join(awsDir(), "config")
+}
+
+// parseAWSConfig parses an AWS CLI config file into its sections, keyed by the
+// section header without brackets ("default", "profile dev", "sso-session corp")
+func parseAWSConfig(data []byte) map[string]map[string]string {
+	sections := make(map[string]map[string]string)
+	var current map[string]string
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			name := strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+			current = make(map[string]string)
+			sections[name] = current
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || current == nil {
+			continue
+		}
+		current[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return sections
+}
+
+// resolveAWSSSOProfile looks up the SSO settings of a profile in the AWS CLI
+// config, following its sso_session when it has one
+func resolveAWSSSOProfile(data []byte, profile string) (*awsSSOProfile, error) {
+	sections := parseAWSConfig(data)
+
+	section, ok := sections["profile "+profile]
+	if !ok && profile == "default" {
+		section, ok = sections["default"]
+	}
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found", profile)
+	}
+
+	if session := section["sso_session"]; session != "" {
+		sessionSection, ok := sections["sso-session "+session]
+		if !ok {
+			return nil, fmt.Errorf("sso-session %q of profile %q not found", session, profile)
+		}
+		return &awsSSOProfile{
+			Session:  session,
+			StartURL: sessionSection["sso_start_url"],
+			Region:   sessionSection["sso_region"],
+		}, nil
+	}
+
+	if section["sso_start_url"] == "" {
+		return nil, fmt.Errorf("profile %q is not an SSO profile (no sso_session or sso_start_url)", profile)
+	}
+	return &awsSSOProfile{StartURL: section["sso_start_url"], Region: section["sso_region"]}, nil
+}
+
+// cacheFile returns the token file `aws sso login` writes for the profile: the
+// SHA-1 of the session name, or of the start URL for legacy profiles
+func (p *awsSSOProfile) cacheFile(cacheDir string) string {
+	key := p.Session
+	if key == "" {
+		key = p.StartURL
+	}
+	sum := sha1.Sum([]byte(key))
+	return filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json")
+}
+
+// AWSSSOCacheFile returns the token file under ~/.aws/sso/cache that
+// `aws sso login` writes for a profile in ~/.aws/config
+func AWSSSOCacheFile(profile string) (string, error) {
+	_, cacheFile, err := lookupAWSSSOProfile(profile)
+	return cacheFile, err
+}
+
+// lookupAWSSSOProfile reads a profile's SSO settings from the AWS CLI config
+// and returns them with the profile's token cache file
+func lookupAWSSSOProfile(profile string) (*awsSSOProfile, string, error) {
+	data, err := os.ReadFile(awsConfigFile())
+	if err != nil {
+		return nil, "", fmt.Errorf("reading AWS config: %w", err)
+	}
+	sso, err := resolveAWSSSOProfile(data, profile)
+	if err != nil {
+		return nil, "", err
+	}
+	return sso, sso.cacheFile(filepath.Join(awsDir(), "sso", "cache")), nil
+}
+
+// loadCredentialsFromAWSProfile loads the token of an `aws sso login` session
+// for AWS_SSO_PROFILE from ~/.aws/sso/cache
+func (m *Manager) loadCredentialsFromAWSProfile(profile string) {
+	sso, cacheFile, err := lookupAWSSSOProfile(profile)
+	if err != nil {
+		log.Errorf("Error reading AWS profile: %v", err)
+		return
+	}
+	m.ssoCacheFile = cacheFile
+
+	data, err := os.ReadFile(m.ssoCacheFile)
+	if os.IsNotExist(err) {
+		log.Warnf("No cached SSO token for AWS profile '%s', run: aws sso login --profile %s", profile, profile)
+		return
+	}
+	if err != nil {
+		log.Errorf("Error reading SSO token cache: %v", err)
+		return
+	}
+
+	var token awsSSOCacheToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		log.Errorf("Error parsing SSO token cache %s: %v", m.ssoCacheFile, err)
+		return
+	}
+
+	if token.AccessToken != "" {
+		m.accessToken = token.AccessToken
+	}
+	if token.RefreshToken != "" {
+		m.refreshToken = token.RefreshToken
+	}
+	if token.ClientID != "" {
+		m.clientID = token.ClientID
+	}
+	if token.ClientSecret != "" {
+		m.clientSecret = token.ClientSecret
+	}
+	m.ssoRegion = sso.Region
+	if token.Region != "" {
+		m.ssoRegion = token.Region
+	}
+	if token.ExpiresAt != "" {
+		if t, err := parseTime(token.ExpiresAt); err == nil {
+			m.expiresAt = t
+		}
+	}
+
+	if m.refreshToken == "" {
+		log.Warnf("AWS profile '%s' has no refresh token (legacy sso_start_url profile); "+
+			"configure an sso_session to let the gateway refresh it", profile)
+	}
+	log.Infof("Credentials loaded from AWS profile '%s' (%s)", profile, m.ssoCacheFile)
+}
+
+// saveCredentialsToSSOCache writes the refreshed token back to the SSO cache,
+// as the AWS CLI does, so both keep using the same refresh token
+func (m *Manager) saveCredentialsToSSOCache() {
+	if m.ssoCacheFile == "" {
+		return
+	}
+
+	existingData := make(map[string]interface{})
+	if data, err := os.ReadFile(m.ssoCacheFile); err == nil {
+		json.Unmarshal(data, &existingData)
+	}
+
+	existingData["accessToken"] = m.accessToken
+	existingData["refreshToken"] = m.refreshToken
+	if !m.expiresAt.IsZero() {
+		existingData["expiresAt"] = m.expiresAt.UTC().Format(time.RFC3339)
+	}
+
+	jsonData, _ := json.MarshalIndent(existingData, "", "  ")
+	if err := os.WriteFile(m.ssoCacheFile, jsonData, 0600); err != nil {
+		log.Errorf("Error saving SSO token cache: %v", err)
+		return
+	}
+
+	log.Debugf("Credentials saved to %s", m.ssoCacheFile)
+}
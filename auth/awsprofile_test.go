@@ -0,0 +1,156 @@
+// Package auth provides tests for AWS SSO profile credentials.
+package auth
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+const testAWSConfig = `
+[default]
+region = us-east-1
+
+[profile kiro]
+sso_session = corp
+sso_account_id = 123456789012
+
+[profile legacy]
+sso_start_url = https://legacy.awsapps.com/start
+sso_region = us-west-2
+
+[profile plain]
+region = eu-west-1
+
+[sso-session corp]
+sso_start_url = https://corp.awsapps.com/start
+sso_region = eu-central-1
+sso_registration_scopes = codewhisperer:completions
+`
+
+// writeAWSHome creates ~/.aws/config and, when token is set, the cache file of
+// profile kiro under a temporary HOME, returning the cache file path
+func writeAWSHome(t *testing.T, token string) string {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("AWS_CONFIG_FILE", "")
+
+	cacheDir := filepath.Join(home, ".aws", "sso", "cache")
+	assert.NoError(t, os.MkdirAll(cacheDir, 0700))
+	assert.NoError(t, os.WriteFile(filepath.Join(home, ".aws", "config"), []byte(testAWSConfig), 0600))
+
+	sum := sha1.Sum([]byte("corp"))
+	cacheFile := filepath.Join(cacheDir, hex.EncodeToString(sum[:])+".json")
+	if token != "" {
+		assert.NoError(t, os.WriteFile(cacheFile, []byte(token), 0600))
+	}
+	return cacheFile
+}
+
+// =============================================================================
+// TestResolveAWSSSOProfile
+// =============================================================================
+
+func TestResolveAWSSSOProfile(t *testing.T) {
+	t.Run("follows the sso-session", func(t *testing.T) {
+		sso, err := resolveAWSSSOProfile([]byte(testAWSConfig), "kiro")
+		assert.NoError(t, err)
+		assert.Equal(t, "corp", sso.Session)
+		assert.Equal(t, "https://corp.awsapps.com/start", sso.StartURL)
+		assert.Equal(t, "eu-central-1", sso.Region)
+
+		sum := sha1.Sum([]byte("corp"))
+		assert.Equal(t, filepath.Join("cache", hex.EncodeToString(sum[:])+".json"), sso.cacheFile("cache"))
+	})
+
+	t.Run("legacy profile is keyed by start URL", func(t *testing.T) {
+		sso, err := resolveAWSSSOProfile([]byte(testAWSConfig), "legacy")
+		assert.NoError(t, err)
+		assert.Empty(t, sso.Session)
+		assert.Equal(t, "us-west-2", sso.Region)
+
+		sum := sha1.Sum([]byte("https://legacy.awsapps.com/start"))
+		assert.Equal(t, filepath.Join("cache", hex.EncodeToString(sum[:])+".json"), sso.cacheFile("cache"))
+	})
+
+	t.Run("rejects unknown and non-SSO profiles", func(t *testing.T) {
+		_, err := resolveAWSSSOProfile([]byte(testAWSConfig), "missing")
+		assert.ErrorContains(t, err, "not found")
+
+		_, err = resolveAWSSSOProfile([]byte(testAWSConfig), "plain")
+		assert.ErrorContains(t, err, "not an SSO profile")
+
+		_, err = resolveAWSSSOProfile([]byte("[profile broken]\nsso_session = gone\n"), "broken")
+		assert.ErrorContains(t, err, `sso-session "gone"`)
+	})
+}
+
+// =============================================================================
+// TestAWSProfileCredentials
+// =============================================================================
+
+func TestAWSProfileCredentials(t *testing.T) {
+	token := `{
+  "startUrl": "https://corp.awsapps.com/start",
+  "region": "eu-central-1",
+  "accessToken": "sso-access",
+  "expiresAt": "2099-01-01T00:00:00Z",
+  "refreshToken": "sso-refresh",
+  "clientId": "client-id",
+  "clientSecret": "client-secret"
+}`
+
+	t.Run("loads the aws sso login token", func(t *testing.T) {
+		writeAWSHome(t, token)
+		manager := NewManager(&config.Config{AWSSSOProfile: "kiro", Region: "us-east-1"})
+
+		assert.Equal(t, "sso-access", manager.AccessToken())
+		assert.Equal(t, "sso-refresh", manager.RefreshToken())
+		assert.Equal(t, AuthTypeAWSSSOOIDC, manager.AuthType())
+		assert.Equal(t, "eu-central-1", manager.ssoRegion)
+		assert.Equal(t, "us-east-1", manager.Region())
+		assert.False(t, manager.IsTokenExpired())
+	})
+
+	t.Run("saves refreshed tokens back to the cache", func(t *testing.T) {
+		cacheFile := writeAWSHome(t, token)
+		manager := NewManager(&config.Config{AWSSSOProfile: "kiro", Region: "us-east-1"})
+
+		manager.accessToken = "new-access"
+		manager.refreshToken = "new-refresh"
+		manager.expiresAt = time.Date(2099, 2, 1, 0, 0, 0, 0, time.UTC)
+		manager.saveCredentials()
+
+		data, err := os.ReadFile(cacheFile)
+		assert.NoError(t, err)
+		var saved map[string]string
+		assert.NoError(t, json.Unmarshal(data, &saved))
+		assert.Equal(t, "new-access", saved["accessToken"])
+		assert.Equal(t, "new-refresh", saved["refreshToken"])
+		assert.Equal(t, "2099-02-01T00:00:00Z", saved["expiresAt"])
+		assert.Equal(t, "client-id", saved["clientId"])
+	})
+
+	t.Run("missing cache leaves the account without a token", func(t *testing.T) {
+		writeAWSHome(t, "")
+		manager := NewManager(&config.Config{AWSSSOProfile: "kiro", Region: "us-east-1"})
+
+		assert.Empty(t, manager.AccessToken())
+		assert.True(t, manager.ReloadCredentials())
+	})
+
+	t.Run("pool names the account after the profile", func(t *testing.T) {
+		writeAWSHome(t, token)
+		pool := NewPool(&config.Config{AWSSSOProfile: "kiro", Region: "us-east-1"})
+
+		assert.Equal(t, 1, pool.Size())
+		assert.Equal(t, "aws_profile:kiro", pool.Status()[0].Name)
+	})
+}
@@ -0,0 +1,285 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+
+	"kiro-go-proxy/config"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/crypto/scrypt"
+)
+
+// credsEncryptionScheme identifies the envelope of an encrypted credentials file
+const credsEncryptionScheme = "scrypt-aes-256-gcm"
+
+// scrypt parameters for deriving the file key from CREDS_ENCRYPTION_KEY
+const (
+	scryptN       = 1 << 15
+	scryptR       = 8
+	scryptP       = 1
+	scryptKeyLen  = 32
+	scryptSaltLen = 16
+)
+
+// Keychain entry holding the key when CREDS_ENCRYPTION_KEYRING is set
+const (
+	keyringService = "kiro-gateway"
+	keyringAccount = "creds-encryption-key"
+)
+
+// ErrCredsKeyMissing is returned when an encrypted credentials file is read
+// without CREDS_ENCRYPTION_KEY or CREDS_ENCRYPTION_KEYRING
+var ErrCredsKeyMissing = errors.New("credentials file is encrypted; set CREDS_ENCRYPTION_KEY or CREDS_ENCRYPTION_KEYRING")
+
+// encryptedCredentials is the JSON envelope written in place of the plaintext
+// credentials. Byte fields are base64 encoded by encoding/json.
+type encryptedCredentials struct {
+	Encryption string `json:"encryption"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+// CredsCipher encrypts and decrypts credentials files with a passphrase
+type CredsCipher struct {
+	passphrase []byte
+}
+
+// NewCredsCipher returns the cipher configured by CREDS_ENCRYPTION_KEY, or by the
+// OS keychain when CREDS_ENCRYPTION_KEYRING is set. It returns nil when
+// credentials files are stored in plaintext.
+func NewCredsCipher(cfg *config.Config) (*CredsCipher, error) {
+	if cfg.CredsEncryptionKey != "" {
+		return &CredsCipher{passphrase: []byte(cfg.CredsEncryptionKey)}, nil
+	}
+	if !cfg.CredsEncryptionKeyring {
+		return nil, nil
+	}
+	key, err := keyringKey()
+	if err != nil {
+		return nil, err
+	}
+	return &CredsCipher{passphrase: []byte(key)}, nil
+}
+
+// Encrypt seals plaintext in an encrypted credentials envelope
+func (c *CredsCipher) Encrypt(plaintext []byte) ([]byte, error) {
+	salt := make([]byte, scryptSaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	aead, err := c.aead(salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(encryptedCredentials{
+		Encryption: credsEncryptionScheme,
+		Salt:       salt,
+		Nonce:      nonce,
+		Ciphertext: aead.Seal(nil, nonce, plaintext, nil),
+	}, "", "  ")
+}
+
+// Decrypt opens an envelope written by Encrypt
+func (c *CredsCipher) Decrypt(data []byte) ([]byte, error) {
+	var envelope encryptedCredentials
+	if err := json.Unmarshal(data, &envelope); err != nil {
+		return nil, err
+	}
+	if envelope.Encryption != credsEncryptionScheme {
+		return nil, fmt.Errorf("unsupported credentials encryption %q", envelope.Encryption)
+	}
+
+	aead, err := c.aead(envelope.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != aead.NonceSize() {
+		return nil, fmt.Errorf("invalid credentials nonce")
+	}
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt credentials (wrong key?)")
+	}
+	return plaintext, nil
+}
+
+// aead derives the AES-256-GCM cipher for a salt
+func (c *CredsCipher) aead(salt []byte) (cipher.AEAD, error) {
+	key, err := scrypt.Key(c.passphrase, salt, scryptN, scryptR, scryptP, scryptKeyLen)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// IsEncryptedCredentials reports whether data is an encrypted credentials envelope
+func IsEncryptedCredentials(data []byte) bool {
+	var envelope struct {
+		Encryption string `json:"encryption"`
+	}
+	return json.Unmarshal(data, &envelope) == nil && envelope.Encryption != ""
+}
+
+// readCredentialsFile reads a credentials file, decrypting it when it is
+// encrypted. It also reports whether the file was encrypted.
+func readCredentialsFile(path string, c *CredsCipher) ([]byte, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	if !IsEncryptedCredentials(data) {
+		return data, false, nil
+	}
+	if c == nil {
+		return nil, true, ErrCredsKeyMissing
+	}
+	plaintext, err := c.Decrypt(data)
+	return plaintext, true, err
+}
+
+// writeCredentialsFile writes a credentials file, encrypted when c is set
+func writeCredentialsFile(path string, data []byte, c *CredsCipher) error {
+	if c != nil {
+		encrypted, err := c.Encrypt(data)
+		if err != nil {
+			return err
+		}
+		data = encrypted
+	}
+	return os.WriteFile(path, data, 0600)
+}
+
+// EncryptCredentialsFile encrypts a plaintext credentials file in place. It
+// reports false when the file is already encrypted.
+func EncryptCredentialsFile(filePath string, c *CredsCipher) (bool, error) {
+	path := expandPath(filePath)
+	data, encrypted, err := readCredentialsFile(path, c)
+	if err != nil || encrypted {
+		return false, err
+	}
+	if !json.Valid(data) {
+		return false, fmt.Errorf("%s is not a JSON credentials file", filePath)
+	}
+	return true, writeCredentialsFile(path, data, c)
+}
+
+// keyringCommand runs an OS keychain tool; replaced in tests
+var keyringCommand = func(stdin string, name string, args ...string) (string, error) {
+	cmd := exec.Command(name, args...)
+	if stdin != "" {
+		cmd.Stdin = strings.NewReader(stdin)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%s: %w: %s", name, err, msg)
+		}
+		return "", fmt.Errorf("%s: %w", name, err)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// keyringCache keeps the keychain key so every account does not prompt again
+var keyringCache struct {
+	sync.Mutex
+	key string
+}
+
+// keyringKey returns the key stored in the OS keychain (macOS Keychain or the
+// Secret Service on Linux), generating and storing a random one on first use
+func keyringKey() (string, error) {
+	keyringCache.Lock()
+	defer keyringCache.Unlock()
+
+	if keyringCache.key != "" {
+		return keyringCache.key, nil
+	}
+
+	key, err := keyringLookup()
+	if err != nil {
+		return "", fmt.Errorf("failed to read credentials key from the OS keychain: %w", err)
+	}
+	if key == "" {
+		generated := make([]byte, scryptKeyLen)
+		if _, err := rand.Read(generated); err != nil {
+			return "", err
+		}
+		key = base64.StdEncoding.EncodeToString(generated)
+		if err := keyringStore(key); err != nil {
+			return "", fmt.Errorf("failed to store credentials key in the OS keychain: %w", err)
+		}
+		log.Infof("Generated a credentials encryption key in the OS keychain (%s/%s)", keyringService, keyringAccount)
+	}
+
+	keyringCache.key = key
+	return key, nil
+}
+
+// keyringLookup reads the key from the OS keychain. A missing entry returns ""
+// without an error; a locked or unavailable keychain is an error, so a new key
+// never replaces one that could not be read.
+func keyringLookup() (string, error) {
+	var key string
+	var err error
+	notFound := 0
+	switch runtime.GOOS {
+	case "darwin":
+		// security exits with errSecItemNotFound (44) when there is no entry
+		key, err = keyringCommand("", "security", "find-generic-password", "-s", keyringService, "-a", keyringAccount, "-w")
+		notFound = 44
+	case "linux":
+		// secret-tool exits with 1 and prints nothing when there is no entry
+		key, err = keyringCommand("", "secret-tool", "lookup", "service", keyringService, "account", keyringAccount)
+		notFound = 1
+	default:
+		return "", fmt.Errorf("OS keychain is not supported on %s; set CREDS_ENCRYPTION_KEY", runtime.GOOS)
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == notFound {
+		return "", nil
+	}
+	return key, err
+}
+
+// keyringStore saves the key in the OS keychain
+func keyringStore(key string) error {
+	var err error
+	switch runtime.GOOS {
+	case "darwin":
+		// security -i reads the command from stdin, keeping the key out of argv
+		_, err = keyringCommand(
+			fmt.Sprintf("add-generic-password -U -s %s -a %s -w %q\n", keyringService, keyringAccount, key),
+			"security", "-i",
+		)
+	case "linux":
+		_, err = keyringCommand(key, "secret-tool", "store", "--label=Kiro Gateway credentials key", "service", keyringService, "account", keyringAccount)
+	default:
+		err = fmt.Errorf("OS keychain is not supported on %s; set CREDS_ENCRYPTION_KEY", runtime.GOOS)
+	}
+	return err
+}
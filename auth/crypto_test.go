@@ -0,0 +1,227 @@
+// Package auth provides tests for credentials file encryption.
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+const plaintextCreds = `{"refreshToken":"refresh-123","accessToken":"access-456","expiresAt":"2099-01-01T00:00:00Z","extra":"kept"}`
+
+// =============================================================================
+// TestCredsCipher
+// =============================================================================
+
+func TestCredsCipher(t *testing.T) {
+	c := &CredsCipher{passphrase: []byte("secret")}
+
+	t.Run("round trips", func(t *testing.T) {
+		encrypted, err := c.Encrypt([]byte(plaintextCreds))
+		assert.NoError(t, err)
+		assert.True(t, IsEncryptedCredentials(encrypted))
+		assert.NotContains(t, string(encrypted), "refresh-123")
+
+		plaintext, err := c.Decrypt(encrypted)
+		assert.NoError(t, err)
+		assert.Equal(t, plaintextCreds, string(plaintext))
+	})
+
+	t.Run("rejects the wrong key", func(t *testing.T) {
+		encrypted, _ := c.Encrypt([]byte(plaintextCreds))
+		_, err := (&CredsCipher{passphrase: []byte("other")}).Decrypt(encrypted)
+		assert.Error(t, err)
+	})
+
+	t.Run("plaintext is not encrypted", func(t *testing.T) {
+		assert.False(t, IsEncryptedCredentials([]byte(plaintextCreds)))
+		assert.False(t, IsEncryptedCredentials([]byte("not json")))
+	})
+
+	t.Run("disabled without a key", func(t *testing.T) {
+		cipher, err := NewCredsCipher(&config.Config{})
+		assert.NoError(t, err)
+		assert.Nil(t, cipher)
+	})
+}
+
+// =============================================================================
+// TestEncryptedCredentialsFile
+// =============================================================================
+
+func TestEncryptedCredentialsFile(t *testing.T) {
+	newManager := func(path, key string) *Manager {
+		return NewManager(&config.Config{KiroCredsFile: path, Region: "us-east-1", CredsEncryptionKey: key})
+	}
+
+	encryptFile := func(path string) {
+		migrated, err := EncryptCredentialsFile(path, &CredsCipher{passphrase: []byte("secret")})
+		assert.NoError(t, err)
+		assert.True(t, migrated)
+	}
+
+	t.Run("leaves a plaintext file as is", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte(plaintextCreds), 0600)
+
+		manager := newManager(path, "secret")
+		assert.Equal(t, "access-456", manager.AccessToken())
+
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, plaintextCreds, string(data))
+	})
+
+	t.Run("encrypts a file explicitly", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte(plaintextCreds), 0600)
+
+		encryptFile(path)
+		data, _ := os.ReadFile(path)
+		assert.True(t, IsEncryptedCredentials(data))
+
+		// Encrypting again is a no-op
+		migrated, err := EncryptCredentialsFile(path, &CredsCipher{passphrase: []byte("secret")})
+		assert.NoError(t, err)
+		assert.False(t, migrated)
+
+		// Later loads decrypt it transparently
+		manager := newManager(path, "secret")
+		assert.Equal(t, "access-456", manager.AccessToken())
+	})
+
+	t.Run("does not encrypt a file that is not JSON", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte("not json"), 0600)
+
+		_, err := EncryptCredentialsFile(path, &CredsCipher{passphrase: []byte("secret")})
+		assert.Error(t, err)
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, "not json", string(data))
+	})
+
+	t.Run("saves encrypted and keeps other keys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte(plaintextCreds), 0600)
+		encryptFile(path)
+
+		manager := newManager(path, "secret")
+		manager.accessToken = "rotated"
+		manager.saveCredentialsToFile()
+
+		data, encrypted, err := readCredentialsFile(path, manager.credsCipher)
+		assert.NoError(t, err)
+		assert.True(t, encrypted)
+		var saved map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &saved))
+		assert.Equal(t, "rotated", saved["accessToken"])
+		assert.Equal(t, "kept", saved["extra"])
+	})
+
+	t.Run("saves a plaintext file in plaintext", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte(plaintextCreds), 0600)
+
+		manager := newManager(path, "secret")
+		manager.accessToken = "rotated"
+		manager.saveCredentialsToFile()
+
+		data, _ := os.ReadFile(path)
+		assert.False(t, IsEncryptedCredentials(data))
+		assert.Contains(t, string(data), "rotated")
+	})
+
+	t.Run("encrypted file needs the key", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte(plaintextCreds), 0600)
+		encryptFile(path)
+
+		manager := newManager(path, "")
+		assert.Empty(t, manager.AccessToken())
+		_, _, err := readCredentialsFile(path, nil)
+		assert.ErrorIs(t, err, ErrCredsKeyMissing)
+
+		// Nor is it overwritten without the key
+		before, _ := os.ReadFile(path)
+		manager.accessToken = "rotated"
+		manager.saveCredentialsToFile()
+		after, _ := os.ReadFile(path)
+		assert.Equal(t, before, after)
+	})
+
+	t.Run("without a key the file stays plaintext", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte(plaintextCreds), 0600)
+
+		newManager(path, "")
+		data, _ := os.ReadFile(path)
+		assert.Equal(t, plaintextCreds, string(data))
+	})
+}
+
+// =============================================================================
+// TestKeyringKey
+// =============================================================================
+
+func TestKeyringKey(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("keychain commands are stubbed for secret-tool")
+	}
+
+	original := keyringCommand
+	defer func() {
+		keyringCommand = original
+		keyringCache.key = ""
+	}()
+
+	// notFound makes an error like secret-tool's when there is no entry
+	notFound := func() error {
+		return fmt.Errorf("secret-tool: %w", exec.Command("sh", "-c", "exit 1").Run())
+	}
+
+	t.Run("generates and stores a key on first use", func(t *testing.T) {
+		keyringCache.key = ""
+		var stored string
+		keyringCommand = func(stdin string, name string, args ...string) (string, error) {
+			if args[0] == "store" {
+				stored = stdin
+				return "", nil
+			}
+			return "", notFound()
+		}
+
+		key, err := keyringKey()
+		assert.NoError(t, err)
+		assert.NotEmpty(t, key)
+		assert.Equal(t, key, stored)
+	})
+
+	t.Run("reuses the stored key", func(t *testing.T) {
+		keyringCache.key = ""
+		keyringCommand = func(stdin string, name string, args ...string) (string, error) {
+			assert.Equal(t, "lookup", args[0])
+			return "stored-key", nil
+		}
+
+		key, err := keyringKey()
+		assert.NoError(t, err)
+		assert.Equal(t, "stored-key", key)
+	})
+
+	t.Run("never replaces a key it cannot read", func(t *testing.T) {
+		keyringCache.key = ""
+		keyringCommand = func(stdin string, name string, args ...string) (string, error) {
+			assert.NotEqual(t, "store", args[0])
+			return "", fmt.Errorf("secret-tool: %w", exec.Command("sh", "-c", "exit 2").Run())
+		}
+
+		_, err := keyringKey()
+		assert.Error(t, err)
+	})
+}
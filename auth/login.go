@@ -0,0 +1,325 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/redact"
+	"kiro-go-proxy/transport"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BuilderIDStartURL is the AWS Builder ID start URL, used when no IAM Identity Center URL is given
+const BuilderIDStartURL = "https://view.awsapps.com/start"
+
+// loginClientName identifies the OIDC client registered by the login command
+const loginClientName = "kiro-gateway"
+
+// loginScopes are the CodeWhisperer scopes requested by kiro-cli
+var loginScopes = []string{
+	"codewhisperer:completions",
+	"codewhisperer:analysis",
+	"codewhisperer:conversations",
+}
+
+// SQLite keys written by the login command, the AWS SSO OIDC keys kiro-cli uses
+const (
+	sqliteLoginTokenKey        = "kirocli:odic:token"
+	sqliteLoginRegistrationKey = "kirocli:odic:device-registration"
+)
+
+// Polling limits for the token endpoint
+const (
+	defaultPollInterval = 5 * time.Second
+	slowDownIncrement   = 5 * time.Second
+)
+
+// DeviceAuthorization is the code the user confirms in the browser
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"deviceCode"`
+	UserCode                string `json:"userCode"`
+	VerificationURI         string `json:"verificationUri"`
+	VerificationURIComplete string `json:"verificationUriComplete"`
+	ExpiresIn               int    `json:"expiresIn"`
+	Interval                int    `json:"interval"`
+}
+
+// LoginResult is the client registration and token obtained by a device login
+type LoginResult struct {
+	Registration DeviceRegistration
+	Token        TokenData
+}
+
+// DeviceLogin runs the AWS SSO OIDC device authorization flow: register a public
+// client, start a device authorization, then poll the token endpoint until the
+// user approves the code in the browser
+type DeviceLogin struct {
+	Region   string
+	StartURL string
+	// Prompt shows the verification URL and user code; required
+	Prompt func(auth *DeviceAuthorization)
+
+	oidcURL    string
+	httpClient *http.Client
+	sleep      func(ctx context.Context, d time.Duration) error
+	now        func() time.Time
+}
+
+// NewDeviceLogin creates a device login against the OIDC endpoint of region.
+// An empty startURL logs in with AWS Builder ID.
+func NewDeviceLogin(cfg *config.Config, region, startURL string) *DeviceLogin {
+	if startURL == "" {
+		startURL = BuilderIDStartURL
+	}
+	return &DeviceLogin{
+		Region:     region,
+		StartURL:   startURL,
+		oidcURL:    config.GetAWSSSOOIDCHostForRegion(region),
+		httpClient: transport.NewClient(cfg, 30*time.Second),
+		sleep:      sleepContext,
+		now:        time.Now,
+	}
+}
+
+// Run performs the whole flow and returns the new credentials
+func (l *DeviceLogin) Run(ctx context.Context) (*LoginResult, error) {
+	reg, err := l.register(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("client registration failed: %w", err)
+	}
+
+	auth, err := l.authorize(ctx, reg)
+	if err != nil {
+		return nil, fmt.Errorf("device authorization failed: %w", err)
+	}
+	l.Prompt(auth)
+
+	token, err := l.poll(ctx, reg, auth)
+	if err != nil {
+		return nil, err
+	}
+	return &LoginResult{Registration: reg, Token: token}, nil
+}
+
+// register registers a public OIDC client for this device
+func (l *DeviceLogin) register(ctx context.Context) (DeviceRegistration, error) {
+	var result struct {
+		ClientID     string `json:"clientId"`
+		ClientSecret string `json:"clientSecret"`
+	}
+	_, err := l.post(ctx, "/client/register", map[string]interface{}{
+		"clientName": loginClientName,
+		"clientType": "public",
+		"scopes":     loginScopes,
+	}, &result)
+	if err != nil {
+		return DeviceRegistration{}, err
+	}
+	if result.ClientID == "" || result.ClientSecret == "" {
+		return DeviceRegistration{}, fmt.Errorf("response does not contain clientId/clientSecret")
+	}
+	return DeviceRegistration{ClientID: result.ClientID, ClientSecret: result.ClientSecret, Region: l.Region}, nil
+}
+
+// authorize starts a device authorization for the registered client
+func (l *DeviceLogin) authorize(ctx context.Context, reg DeviceRegistration) (*DeviceAuthorization, error) {
+	var auth DeviceAuthorization
+	_, err := l.post(ctx, "/device_authorization", map[string]string{
+		"clientId":     reg.ClientID,
+		"clientSecret": reg.ClientSecret,
+		"startUrl":     l.StartURL,
+	}, &auth)
+	if err != nil {
+		return nil, err
+	}
+	if auth.DeviceCode == "" {
+		return nil, fmt.Errorf("response does not contain deviceCode")
+	}
+	return &auth, nil
+}
+
+// poll waits for the user to approve the device code and exchanges it for a token
+func (l *DeviceLogin) poll(ctx context.Context, reg DeviceRegistration, auth *DeviceAuthorization) (TokenData, error) {
+	interval := time.Duration(auth.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+	deadline := l.now().Add(time.Duration(auth.ExpiresIn) * time.Second)
+
+	for {
+		if err := l.sleep(ctx, interval); err != nil {
+			return TokenData{}, err
+		}
+
+		var result struct {
+			AccessToken  string `json:"accessToken"`
+			RefreshToken string `json:"refreshToken"`
+			ExpiresIn    int    `json:"expiresIn"`
+		}
+		code, err := l.post(ctx, "/token", map[string]string{
+			"clientId":     reg.ClientID,
+			"clientSecret": reg.ClientSecret,
+			"grantType":    "urn:ietf:params:oauth:grant-type:device_code",
+			"deviceCode":   auth.DeviceCode,
+		}, &result)
+
+		switch code {
+		case "":
+		case "authorization_pending":
+			if auth.ExpiresIn > 0 && l.now().After(deadline) {
+				return TokenData{}, fmt.Errorf("device code expired before it was approved")
+			}
+			continue
+		case "slow_down":
+			interval += slowDownIncrement
+			continue
+		case "expired_token":
+			return TokenData{}, fmt.Errorf("device code expired before it was approved")
+		case "access_denied":
+			return TokenData{}, fmt.Errorf("login was denied in the browser")
+		}
+		if err != nil {
+			return TokenData{}, fmt.Errorf("token request failed: %w", err)
+		}
+		if result.AccessToken == "" || result.RefreshToken == "" {
+			return TokenData{}, fmt.Errorf("token response does not contain accessToken/refreshToken")
+		}
+
+		return TokenData{
+			AccessToken:  result.AccessToken,
+			RefreshToken: result.RefreshToken,
+			Region:       l.Region,
+			ExpiresAt:    l.now().Add(time.Duration(result.ExpiresIn-60) * time.Second).Format(time.RFC3339),
+			Scopes:       loginScopes,
+		}, nil
+	}
+}
+
+// post sends a JSON request to the OIDC endpoint and decodes a successful response into out.
+// On failure it returns the OAuth error code from the response body, if any.
+func (l *DeviceLogin) post(ctx context.Context, path string, payload interface{}, out interface{}) (string, error) {
+	jsonData, _ := json.Marshal(payload)
+	req, err := http.NewRequestWithContext(ctx, "POST", l.oidcURL+path, strings.NewReader(string(jsonData)))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		var oauthErr struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal(body, &oauthErr)
+		return oauthErr.Error, fmt.Errorf("request failed with status %d: %s", resp.StatusCode, redact.Message(string(body)))
+	}
+	return "", json.Unmarshal(body, out)
+}
+
+// sleepContext waits for d or until ctx is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// SaveLoginToFile stores the login in a KIRO_CREDS_FILE credentials file, keeping
+// unrelated keys already in it. The file is encrypted when cipher is set.
+func SaveLoginToFile(filePath string, result *LoginResult, cipher *CredsCipher) error {
+	path := expandPath(filePath)
+
+	existingData := make(map[string]interface{})
+	if data, _, err := readCredentialsFile(path, cipher); err == nil {
+		json.Unmarshal(data, &existingData)
+	}
+
+	existingData["accessToken"] = result.Token.AccessToken
+	existingData["refreshToken"] = result.Token.RefreshToken
+	existingData["expiresAt"] = result.Token.ExpiresAt
+	existingData["region"] = result.Registration.Region
+	existingData["clientId"] = result.Registration.ClientID
+	existingData["clientSecret"] = result.Registration.ClientSecret
+	// An enterprise registration would replace the new client credentials on load
+	delete(existingData, "clientIdHash")
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	jsonData, _ := json.MarshalIndent(existingData, "", "  ")
+	if err := writeCredentialsFile(path, jsonData, cipher); err != nil {
+		return err
+	}
+
+	log.Infof("Credentials saved to %s", filePath)
+	return nil
+}
+
+// SaveLoginToSQLite stores the login in a KIRO_CLI_DB_FILE database under the
+// kiro-cli AWS SSO OIDC keys. A social login token is removed, since the Manager
+// would otherwise keep preferring it.
+func SaveLoginToSQLite(dbPath string, result *LoginResult) error {
+	path := expandPath(dbPath)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	tokenJSON, _ := json.Marshal(result.Token)
+	regJSON, _ := json.Marshal(result.Registration)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	statements := []struct {
+		query string
+		args  []interface{}
+	}{
+		{"CREATE TABLE IF NOT EXISTS auth_kv (key TEXT PRIMARY KEY, value TEXT)", nil},
+		{"INSERT OR REPLACE INTO auth_kv (key, value) VALUES (?, ?)", []interface{}{sqliteLoginTokenKey, string(tokenJSON)}},
+		{"INSERT OR REPLACE INTO auth_kv (key, value) VALUES (?, ?)", []interface{}{sqliteLoginRegistrationKey, string(regJSON)}},
+		{"DELETE FROM auth_kv WHERE key = ?", []interface{}{sqliteTokenKeys[0]}},
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt.query, stmt.args...); err != nil {
+			return err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	log.Infof("Credentials saved to SQLite database: %s", dbPath)
+	return nil
+}
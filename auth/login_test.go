@@ -0,0 +1,152 @@
+// Package auth provides tests for the device authorization login.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// newTestLogin creates a device login against a fake OIDC server. tokenResponses
+// are returned by the token endpoint in order, as status and body.
+func newTestLogin(t *testing.T, tokenResponses ...[2]interface{}) (*DeviceLogin, *[]time.Duration) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/client/register", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "public", body["clientType"])
+		w.Write([]byte(`{"clientId":"client-1","clientSecret":"secret-1"}`))
+	})
+	mux.HandleFunc("/device_authorization", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "client-1", body["clientId"])
+		assert.Equal(t, BuilderIDStartURL, body["startUrl"])
+		w.Write([]byte(`{"deviceCode":"device-1","userCode":"ABCD-EFGH","verificationUri":"https://device.sso/","verificationUriComplete":"https://device.sso/?user_code=ABCD-EFGH","expiresIn":600,"interval":1}`))
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		json.NewDecoder(r.Body).Decode(&body)
+		assert.Equal(t, "device-1", body["deviceCode"])
+		assert.Equal(t, "urn:ietf:params:oauth:grant-type:device_code", body["grantType"])
+
+		next := tokenResponses[0]
+		tokenResponses = tokenResponses[1:]
+		w.WriteHeader(next[0].(int))
+		w.Write([]byte(next[1].(string)))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var sleeps []time.Duration
+	login := NewDeviceLogin(&config.Config{}, "us-east-1", "")
+	login.oidcURL = server.URL
+	login.httpClient = server.Client()
+	login.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	login.Prompt = func(*DeviceAuthorization) {}
+	return login, &sleeps
+}
+
+// =============================================================================
+// TestDeviceLogin
+// =============================================================================
+
+func TestDeviceLogin(t *testing.T) {
+	ctx := context.Background()
+	success := [2]interface{}{http.StatusOK, `{"accessToken":"access-1","refreshToken":"refresh-1","expiresIn":3600}`}
+
+	t.Run("polls until approved", func(t *testing.T) {
+		login, sleeps := newTestLogin(t,
+			[2]interface{}{http.StatusBadRequest, `{"error":"authorization_pending"}`},
+			success,
+		)
+		var prompted *DeviceAuthorization
+		login.Prompt = func(auth *DeviceAuthorization) { prompted = auth }
+
+		result, err := login.Run(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "ABCD-EFGH", prompted.UserCode)
+		assert.Equal(t, "client-1", result.Registration.ClientID)
+		assert.Equal(t, "secret-1", result.Registration.ClientSecret)
+		assert.Equal(t, "access-1", result.Token.AccessToken)
+		assert.Equal(t, "refresh-1", result.Token.RefreshToken)
+		assert.Equal(t, []time.Duration{time.Second, time.Second}, *sleeps)
+	})
+
+	t.Run("backs off on slow_down", func(t *testing.T) {
+		login, sleeps := newTestLogin(t,
+			[2]interface{}{http.StatusBadRequest, `{"error":"slow_down"}`},
+			success,
+		)
+
+		_, err := login.Run(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, []time.Duration{time.Second, 6 * time.Second}, *sleeps)
+	})
+
+	t.Run("fails when denied or expired", func(t *testing.T) {
+		login, _ := newTestLogin(t, [2]interface{}{http.StatusBadRequest, `{"error":"access_denied"}`})
+		_, err := login.Run(ctx)
+		assert.ErrorContains(t, err, "denied")
+
+		login, _ = newTestLogin(t, [2]interface{}{http.StatusBadRequest, `{"error":"expired_token"}`})
+		_, err = login.Run(ctx)
+		assert.ErrorContains(t, err, "expired")
+	})
+
+	t.Run("stops when cancelled", func(t *testing.T) {
+		login, _ := newTestLogin(t)
+		login.sleep = sleepContext
+
+		cancelled, cancel := context.WithCancel(ctx)
+		cancel()
+		_, err := login.Run(cancelled)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// =============================================================================
+// TestSaveLoginToFile
+// =============================================================================
+
+func TestSaveLoginToFile(t *testing.T) {
+	result := &LoginResult{
+		Registration: DeviceRegistration{ClientID: "client-1", ClientSecret: "secret-1", Region: "us-east-1"},
+		Token:        TokenData{AccessToken: "access-1", RefreshToken: "refresh-1", ExpiresAt: "2030-01-01T00:00:00Z"},
+	}
+
+	t.Run("is loaded as AWS SSO OIDC credentials", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds", "credentials.json")
+		assert.NoError(t, SaveLoginToFile(path, result, nil))
+
+		m := NewManager(&config.Config{KiroCredsFile: path, Region: "us-east-1"})
+		assert.Equal(t, AuthTypeAWSSSOOIDC, m.AuthType())
+		assert.Equal(t, "access-1", m.AccessToken())
+		assert.Equal(t, "refresh-1", m.RefreshToken())
+		assert.False(t, m.IsTokenExpired())
+	})
+
+	t.Run("keeps other keys and drops enterprise hash", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "credentials.json")
+		os.WriteFile(path, []byte(`{"profileArn":"arn:profile","clientIdHash":"abc"}`), 0600)
+		assert.NoError(t, SaveLoginToFile(path, result, nil))
+
+		var saved map[string]interface{}
+		data, _ := os.ReadFile(path)
+		json.Unmarshal(data, &saved)
+		assert.Equal(t, "arn:profile", saved["profileArn"])
+		assert.NotContains(t, saved, "clientIdHash")
+		assert.Equal(t, "client-1", saved["clientId"])
+	})
+}
@@ -0,0 +1,301 @@
+// Package auth provides authentication management for Kiro API.
+package auth
+
+import (
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxAccountCooldown caps the exponential cooldown applied to failing accounts
+const maxAccountCooldown = 15 * time.Minute
+
+// Account is a single Kiro credential source managed by a Pool
+type Account struct {
+	Name    string
+	Manager *Manager
+
+	// Health tracking, guarded by Pool.mu
+	totalRequests       int
+	totalFailures       int
+	consecutiveFailures int
+	lastError           string
+	lastFailure         time.Time
+	cooldownUntil       time.Time
+}
+
+// AccountStatus is a snapshot of an account's health for status reporting
+type AccountStatus struct {
+	Name                string     `json:"name"`
+	AuthType            string     `json:"auth_type"`
+	Region              string     `json:"region"`
+	Healthy             bool       `json:"healthy"`
+	TotalRequests       int        `json:"total_requests"`
+	TotalFailures       int        `json:"total_failures"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error,omitempty"`
+	LastFailure         *time.Time `json:"last_failure,omitempty"`
+	CooldownUntil       *time.Time `json:"cooldown_until,omitempty"`
+}
+
+// Pool rotates requests across several Kiro accounts with failover
+type Pool struct {
+	accounts []*Account
+	cooldown time.Duration
+	next     int
+
+	mu sync.Mutex
+}
+
+// NewPool creates a credential pool from all configured credential sources
+func NewPool(cfg *config.Config) *Pool {
+	var accounts []*Account
+
+	// Primary credential source (REFRESH_TOKEN / KIRO_CREDS_FILE / KIRO_CLI_DB_FILE / AWS_SSO_PROFILE)
+	if cfg.RefreshToken != "" || cfg.KiroCredsFile != "" || cfg.KiroCLIDBFile != "" || cfg.AWSSSOProfile != "" {
+		accounts = append(accounts, &Account{
+			Name:    primaryAccountName(cfg),
+			Manager: NewManager(cfg),
+		})
+	}
+
+	// Additional accounts
+	for i, token := range cfg.RefreshTokens {
+		accountCfg := accountConfig(cfg)
+		accountCfg.RefreshToken = token
+		accounts = append(accounts, &Account{
+			Name:    fmt.Sprintf("refresh_token#%d", i+1),
+			Manager: NewManager(accountCfg),
+		})
+	}
+	for _, file := range cfg.KiroCredsFiles {
+		accountCfg := accountConfig(cfg)
+		accountCfg.KiroCredsFile = file
+		accounts = append(accounts, &Account{
+			Name:    "creds_file:" + filepath.Base(file),
+			Manager: NewManager(accountCfg),
+		})
+	}
+	for _, db := range cfg.KiroCLIDBFiles {
+		accountCfg := accountConfig(cfg)
+		accountCfg.KiroCLIDBFile = db
+		accounts = append(accounts, &Account{
+			Name:    "sqlite:" + filepath.Base(db),
+			Manager: NewManager(accountCfg),
+		})
+	}
+
+	if len(accounts) > 1 {
+		log.Infof("Credential pool initialized with %d accounts", len(accounts))
+	}
+
+	return &Pool{
+		accounts: accounts,
+		cooldown: time.Duration(cfg.AccountCooldown) * time.Second,
+	}
+}
+
+// NewPoolFromManagers creates a credential pool from existing managers
+func NewPoolFromManagers(managers ...*Manager) *Pool {
+	var accounts []*Account
+	for i, m := range managers {
+		accounts = append(accounts, &Account{
+			Name:    fmt.Sprintf("account#%d", i+1),
+			Manager: m,
+		})
+	}
+
+	cooldown := time.Minute
+	if len(managers) > 0 && managers[0].cfg != nil && managers[0].cfg.AccountCooldown > 0 {
+		cooldown = time.Duration(managers[0].cfg.AccountCooldown) * time.Second
+	}
+
+	return &Pool{
+		accounts: accounts,
+		cooldown: cooldown,
+	}
+}
+
+// accountConfig returns a copy of cfg with all credential sources cleared
+func accountConfig(cfg *config.Config) *config.Config {
+	accountCfg := *cfg
+	accountCfg.RefreshToken = ""
+	accountCfg.KiroCredsFile = ""
+	accountCfg.KiroCLIDBFile = ""
+	accountCfg.AWSSSOProfile = ""
+	return &accountCfg
+}
+
+func primaryAccountName(cfg *config.Config) string {
+	switch {
+	case cfg.KiroCLIDBFile != "":
+		return "sqlite:" + filepath.Base(cfg.KiroCLIDBFile)
+	case cfg.KiroCredsFile != "":
+		return "creds_file:" + filepath.Base(cfg.KiroCredsFile)
+	case cfg.AWSSSOProfile != "":
+		return "aws_profile:" + cfg.AWSSSOProfile
+	default:
+		return "refresh_token"
+	}
+}
+
+// Size returns the number of accounts in the pool
+func (p *Pool) Size() int {
+	return len(p.accounts)
+}
+
+// Primary returns the first account's manager, or nil for an empty pool
+func (p *Pool) Primary() *Manager {
+	if len(p.accounts) == 0 {
+		return nil
+	}
+	return p.accounts[0].Manager
+}
+
+// Managers returns the managers of all accounts in the pool
+func (p *Pool) Managers() []*Manager {
+	managers := make([]*Manager, len(p.accounts))
+	for i, a := range p.accounts {
+		managers[i] = a.Manager
+	}
+	return managers
+}
+
+// StartRefreshers starts background token refresh for every account
+func (p *Pool) StartRefreshers() {
+	for _, a := range p.accounts {
+		a.Manager.StartRefresher()
+	}
+}
+
+// StartWatchers starts watching the kiro-cli database of every database backed
+// account for credentials rotated outside the gateway
+func (p *Pool) StartWatchers() {
+	for _, a := range p.accounts {
+		a.Manager.StartWatcher()
+	}
+}
+
+// Stop stops background token refresh and database watching for every account
+func (p *Pool) Stop() {
+	for _, a := range p.accounts {
+		a.Manager.Stop()
+	}
+}
+
+// ReloadCredentials re-reads credentials for every file or database backed account,
+// returning how many were reloaded. Adding or removing accounts requires a restart.
+func (p *Pool) ReloadCredentials() int {
+	reloaded := 0
+	for _, a := range p.accounts {
+		if a.Manager.ReloadCredentials() {
+			reloaded++
+		}
+	}
+	return reloaded
+}
+
+// Next returns the next healthy account in round-robin order.
+// If every account is cooling down, the one that recovers soonest is returned.
+func (p *Pool) Next() *Account {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(p.accounts) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	var soonest *Account
+	for i := 0; i < len(p.accounts); i++ {
+		idx := (p.next + i) % len(p.accounts)
+		account := p.accounts[idx]
+		if !now.Before(account.cooldownUntil) {
+			p.next = (idx + 1) % len(p.accounts)
+			return account
+		}
+		if soonest == nil || account.cooldownUntil.Before(soonest.cooldownUntil) {
+			soonest = account
+		}
+	}
+
+	log.Warnf("All %d accounts are cooling down, using '%s'", len(p.accounts), soonest.Name)
+	return soonest
+}
+
+// MarkSuccess records a successful request and clears the account's failure state
+func (p *Pool) MarkSuccess(account *Account) {
+	if account == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	account.totalRequests++
+	account.consecutiveFailures = 0
+	account.cooldownUntil = time.Time{}
+}
+
+// MarkFailure records a failed request and puts the account into cooldown
+func (p *Pool) MarkFailure(account *Account, reason string) {
+	if account == nil {
+		return
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	account.totalRequests++
+	account.totalFailures++
+	account.consecutiveFailures++
+	account.lastError = reason
+	account.lastFailure = time.Now()
+
+	// Exponential cooldown: cooldown * 2^(failures-1), capped
+	cooldown := p.cooldown << uint(account.consecutiveFailures-1)
+	if cooldown > maxAccountCooldown || cooldown <= 0 {
+		cooldown = maxAccountCooldown
+	}
+	account.cooldownUntil = account.lastFailure.Add(cooldown)
+
+	if len(p.accounts) > 1 {
+		log.Warnf("Account '%s' failed (%s), cooling down for %v", account.Name, reason, cooldown)
+	}
+}
+
+// Status returns a health snapshot of all accounts
+func (p *Pool) Status() []AccountStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	statuses := make([]AccountStatus, 0, len(p.accounts))
+	for _, a := range p.accounts {
+		status := AccountStatus{
+			Name:                a.Name,
+			AuthType:            a.Manager.AuthType().String(),
+			Region:              a.Manager.Region(),
+			Healthy:             !now.Before(a.cooldownUntil),
+			TotalRequests:       a.totalRequests,
+			TotalFailures:       a.totalFailures,
+			ConsecutiveFailures: a.consecutiveFailures,
+			LastError:           a.lastError,
+		}
+		if !a.lastFailure.IsZero() {
+			lastFailure := a.lastFailure
+			status.LastFailure = &lastFailure
+		}
+		if now.Before(a.cooldownUntil) {
+			cooldownUntil := a.cooldownUntil
+			status.CooldownUntil = &cooldownUntil
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
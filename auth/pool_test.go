@@ -0,0 +1,165 @@
+// Package auth provides tests for the credential pool.
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// newTestPool creates a pool with refresh-token accounts
+func newTestPool(tokens ...string) *Pool {
+	cfg := &config.Config{
+		Region:          "us-east-1",
+		RefreshTokens:   tokens,
+		AccountCooldown: 60,
+	}
+	return NewPool(cfg)
+}
+
+// =============================================================================
+// TestNewPool
+// Tests for credential pool construction
+// =============================================================================
+
+func TestNewPool(t *testing.T) {
+	t.Run("loads primary and additional accounts", func(t *testing.T) {
+		cfg := &config.Config{
+			RefreshToken:  "primary",
+			RefreshTokens: []string{"second", "third"},
+			Region:        "us-east-1",
+		}
+		pool := NewPool(cfg)
+
+		assert.Equal(t, 3, pool.Size())
+		assert.Equal(t, "primary", pool.Primary().RefreshToken())
+		assert.Equal(t, "second", pool.Managers()[1].RefreshToken())
+		assert.Equal(t, "third", pool.Managers()[2].RefreshToken())
+	})
+
+	t.Run("additional accounts only", func(t *testing.T) {
+		pool := newTestPool("a", "b")
+
+		assert.Equal(t, 2, pool.Size())
+		assert.Equal(t, "a", pool.Primary().RefreshToken())
+	})
+
+	t.Run("empty pool has no primary", func(t *testing.T) {
+		pool := NewPool(&config.Config{})
+
+		assert.Equal(t, 0, pool.Size())
+		assert.Nil(t, pool.Primary())
+		assert.Nil(t, pool.Next())
+	})
+
+	t.Run("from existing managers", func(t *testing.T) {
+		manager := &Manager{}
+		pool := NewPoolFromManagers(manager)
+
+		assert.Equal(t, 1, pool.Size())
+		assert.Same(t, manager, pool.Primary())
+	})
+}
+
+// =============================================================================
+// TestPoolRotation
+// Tests for round-robin and failover
+// =============================================================================
+
+func TestPoolRotation(t *testing.T) {
+	t.Run("rotates round-robin", func(t *testing.T) {
+		pool := newTestPool("a", "b", "c")
+
+		assert.Equal(t, "refresh_token#1", pool.Next().Name)
+		assert.Equal(t, "refresh_token#2", pool.Next().Name)
+		assert.Equal(t, "refresh_token#3", pool.Next().Name)
+		assert.Equal(t, "refresh_token#1", pool.Next().Name)
+	})
+
+	t.Run("skips failed accounts", func(t *testing.T) {
+		pool := newTestPool("a", "b", "c")
+
+		first := pool.Next()
+		pool.MarkFailure(first, "429 rate limited")
+
+		for i := 0; i < 4; i++ {
+			assert.NotEqual(t, first.Name, pool.Next().Name)
+		}
+	})
+
+	t.Run("success clears cooldown", func(t *testing.T) {
+		pool := newTestPool("a", "b")
+
+		first := pool.Next()
+		pool.MarkFailure(first, "403 forbidden")
+		pool.MarkSuccess(first)
+
+		assert.Equal(t, "refresh_token#2", pool.Next().Name)
+		assert.Equal(t, "refresh_token#1", pool.Next().Name)
+	})
+
+	t.Run("returns soonest account when all are cooling down", func(t *testing.T) {
+		pool := newTestPool("a", "b")
+
+		a := pool.Next()
+		b := pool.Next()
+		pool.MarkFailure(a, "429 rate limited")
+		pool.MarkFailure(a, "429 rate limited")
+		pool.MarkFailure(b, "429 rate limited")
+
+		assert.Equal(t, b.Name, pool.Next().Name)
+	})
+}
+
+// =============================================================================
+// TestPoolStatus
+// Tests for per-account health reporting
+// =============================================================================
+
+func TestPoolStatus(t *testing.T) {
+	t.Run("reports health per account", func(t *testing.T) {
+		pool := newTestPool("a", "b")
+
+		a := pool.Next()
+		b := pool.Next()
+		pool.MarkSuccess(a)
+		pool.MarkFailure(b, "429 rate limited")
+
+		status := pool.Status()
+
+		assert.Len(t, status, 2)
+		assert.True(t, status[0].Healthy)
+		assert.Equal(t, 1, status[0].TotalRequests)
+		assert.Equal(t, 0, status[0].TotalFailures)
+		assert.Nil(t, status[0].CooldownUntil)
+
+		assert.False(t, status[1].Healthy)
+		assert.Equal(t, 1, status[1].TotalFailures)
+		assert.Equal(t, 1, status[1].ConsecutiveFailures)
+		assert.Equal(t, "429 rate limited", status[1].LastError)
+		assert.NotNil(t, status[1].CooldownUntil)
+		assert.Equal(t, "kiro_desktop", status[1].AuthType)
+	})
+}
+
+// =============================================================================
+// TestPoolReloadCredentials
+// Tests for reloading credentials across the pool
+// =============================================================================
+
+func TestPoolReloadCredentials(t *testing.T) {
+	t.Run("counts only file backed accounts", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "creds.json")
+		os.WriteFile(path, []byte(`{"refreshToken": "refresh-1"}`), 0600)
+		pool := NewPool(&config.Config{
+			Region:         "us-east-1",
+			RefreshTokens:  []string{"a"},
+			KiroCredsFiles: []string{path},
+		})
+
+		assert.Equal(t, 1, pool.ReloadCredentials())
+	})
+}
@@ -0,0 +1,87 @@
+// Package auth provides authentication management for Kiro API.
+package auth
+
+// refreshFlight is a token refresh in progress. Callers that find one running
+// wait for its result instead of sending their own refresh request.
+type refreshFlight struct {
+	done chan struct{}
+	err  error
+}
+
+// RefreshStats counts an account's token refreshes for metrics
+type RefreshStats struct {
+	// Refreshes is the number of refresh requests sent to the auth service
+	Refreshes int64 `json:"refreshes"`
+	// Failures is how many of those refreshes failed
+	Failures int64 `json:"failures"`
+	// Shared is the number of callers that took the result of another caller's
+	// refresh instead of sending their own
+	Shared int64 `json:"shared"`
+}
+
+// RefreshGeneration returns the number of refreshes finished so far. Pass it to
+// RefreshSince before using the token so that a refresh triggered by a rejected
+// token is not repeated once another request has already done it.
+func (m *Manager) RefreshGeneration() uint64 {
+	m.flightMu.Lock()
+	defer m.flightMu.Unlock()
+	return m.generation
+}
+
+// RefreshSince refreshes the token unless a refresh has finished since
+// generation (see RefreshGeneration), in which case that refresh's result is
+// returned. A refresh already in progress is waited for rather than repeated.
+func (m *Manager) RefreshSince(generation uint64) (string, error) {
+	if err := m.refreshSince(generation); err != nil {
+		return "", err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.accessToken, nil
+}
+
+// RefreshStats returns the account's token refresh counters
+func (m *Manager) RefreshStats() RefreshStats {
+	m.flightMu.Lock()
+	defer m.flightMu.Unlock()
+	return m.refreshStats
+}
+
+// refreshSince is RefreshSince without the token: only one refresh request is
+// sent per expiry no matter how many callers ask for it
+func (m *Manager) refreshSince(generation uint64) error {
+	m.flightMu.Lock()
+	if f := m.flight; f != nil {
+		m.refreshStats.Shared++
+		m.flightMu.Unlock()
+		<-f.done
+		return f.err
+	}
+	if m.generation != generation {
+		m.refreshStats.Shared++
+		err := m.lastFlightErr
+		m.flightMu.Unlock()
+		return err
+	}
+	f := &refreshFlight{done: make(chan struct{})}
+	m.flight = f
+	m.flightMu.Unlock()
+
+	m.mu.Lock()
+	f.err = m.refreshTokenRequest()
+	m.mu.Unlock()
+
+	m.flightMu.Lock()
+	m.flight = nil
+	m.generation++
+	m.lastFlightErr = f.err
+	m.refreshStats.Refreshes++
+	if f.err != nil {
+		m.refreshStats.Failures++
+	}
+	m.flightMu.Unlock()
+
+	close(f.done)
+	return f.err
+}
@@ -0,0 +1,120 @@
+// Package auth provides tests for shared token refreshes.
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// newRefreshServer returns a Kiro Desktop refresh endpoint that answers with
+// status after a short delay, counting its calls
+func newRefreshServer(t *testing.T, status int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(status)
+		if status == http.StatusOK {
+			w.Write([]byte(`{"accessToken":"fresh-token","expiresIn":3600}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+// newExpiredManager returns a manager whose token has expired, refreshing against url
+func newExpiredManager(url string) *Manager {
+	m := NewManager(&config.Config{RefreshToken: "refresh-token", TokenRefreshThreshold: 600})
+	m.refreshURL = url
+	m.accessToken = "stale-token"
+	m.expiresAt = time.Now().Add(-time.Minute)
+	return m
+}
+
+// concurrently runs fn from n goroutines at once
+func concurrently(n int, fn func()) {
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			fn()
+		}()
+	}
+	wg.Wait()
+}
+
+// =============================================================================
+// TestSharedRefresh
+// =============================================================================
+
+func TestSharedRefresh(t *testing.T) {
+	t.Run("concurrent requests share one refresh", func(t *testing.T) {
+		server, calls := newRefreshServer(t, http.StatusOK)
+		m := newExpiredManager(server.URL)
+
+		concurrently(10, func() {
+			token, err := m.GetAccessToken()
+			assert.NoError(t, err)
+			assert.Equal(t, "fresh-token", token)
+		})
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+		stats := m.RefreshStats()
+		assert.Equal(t, int64(1), stats.Refreshes)
+		assert.Zero(t, stats.Failures)
+	})
+
+	t.Run("a failed refresh is shared too", func(t *testing.T) {
+		server, calls := newRefreshServer(t, http.StatusBadRequest)
+		m := newExpiredManager(server.URL)
+
+		generation := m.RefreshGeneration()
+		concurrently(10, func() {
+			_, err := m.RefreshSince(generation)
+			assert.Error(t, err)
+		})
+
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+		stats := m.RefreshStats()
+		assert.Equal(t, int64(1), stats.Refreshes)
+		assert.Equal(t, int64(1), stats.Failures)
+		assert.Equal(t, int64(9), stats.Shared)
+	})
+
+	t.Run("rejected token is not refreshed twice", func(t *testing.T) {
+		server, calls := newRefreshServer(t, http.StatusOK)
+		m := newExpiredManager(server.URL)
+
+		generation := m.RefreshGeneration()
+		_, err := m.RefreshSince(generation)
+		assert.NoError(t, err)
+		token, err := m.RefreshSince(generation)
+		assert.NoError(t, err)
+		assert.Equal(t, "fresh-token", token)
+		assert.Equal(t, int32(1), atomic.LoadInt32(calls))
+
+		// A newer generation refreshes again
+		_, err = m.RefreshSince(m.RefreshGeneration())
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+
+	t.Run("ForceRefresh always refreshes", func(t *testing.T) {
+		server, calls := newRefreshServer(t, http.StatusOK)
+		m := newExpiredManager(server.URL)
+
+		_, err := m.ForceRefresh()
+		assert.NoError(t, err)
+		_, err = m.ForceRefresh()
+		assert.NoError(t, err)
+		assert.Equal(t, int32(2), atomic.LoadInt32(calls))
+	})
+}
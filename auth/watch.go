@@ -0,0 +1,76 @@
+// Package auth provides authentication management for Kiro API.
+package auth
+
+import (
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fileStamp identifies a version of a file by its size and modification time
+type fileStamp struct {
+	size    int64
+	modTime time.Time
+}
+
+// credentialsDBStamp returns the stamps of a kiro-cli database and its
+// write-ahead log, where SQLite keeps recent writes until a checkpoint.
+// Missing files have a zero stamp.
+func credentialsDBStamp(path string) [2]fileStamp {
+	var stamps [2]fileStamp
+	for i, name := range []string{path, path + "-wal"} {
+		if info, err := os.Stat(name); err == nil {
+			stamps[i] = fileStamp{size: info.Size(), modTime: info.ModTime()}
+		}
+	}
+	return stamps
+}
+
+// StartWatcher polls the kiro-cli database (KIRO_CLI_DB_FILE) every
+// KIRO_CLI_DB_WATCH_INTERVAL seconds and reloads the credentials when it
+// changes, so a refresh token rotated by kiro-cli is picked up right away
+// instead of after a refresh with the stale one fails.
+func (m *Manager) StartWatcher() {
+	interval := time.Duration(m.cfg.KiroCLIDBWatchInterval) * time.Second
+	if m.sqliteDB == "" || interval <= 0 {
+		return
+	}
+	if m.startWorker(&m.watcherStarted, func() { m.watchLoop(interval) }) {
+		log.Debugf("Watching %s for rotated credentials", m.sqliteDB)
+	}
+}
+
+func (m *Manager) watchLoop(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-m.stopCh:
+			log.Debug("kiro-cli database watcher stopped")
+			return
+		}
+		m.reloadIfDBChanged()
+	}
+}
+
+// reloadIfDBChanged reloads the credentials when the kiro-cli database differs
+// from the version last loaded or saved, reporting whether it did
+func (m *Manager) reloadIfDBChanged() bool {
+	stamp := credentialsDBStamp(expandPath(m.sqliteDB))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if stamp == m.sqliteStamp {
+		return false
+	}
+
+	log.Infof("kiro-cli database %s changed, reloading credentials", m.sqliteDB)
+	m.sqliteStamp = stamp
+	m.loadCredentialsFromSQLite(m.sqliteDB)
+	m.detectAuthType()
+	return true
+}
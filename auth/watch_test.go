@@ -0,0 +1,76 @@
+// Package auth provides tests for the kiro-cli database watcher.
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// =============================================================================
+// TestCredentialsDBWatcher
+// =============================================================================
+
+func TestCredentialsDBWatcher(t *testing.T) {
+	t.Run("reloads only when the database changes", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.sqlite3")
+		os.WriteFile(path, []byte("v1"), 0600)
+		manager := NewManager(&config.Config{KiroCLIDBFile: path})
+
+		assert.False(t, manager.reloadIfDBChanged())
+
+		os.WriteFile(path, []byte("version 2"), 0600)
+		assert.True(t, manager.reloadIfDBChanged())
+		assert.False(t, manager.reloadIfDBChanged())
+	})
+
+	t.Run("write-ahead log changes count", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.sqlite3")
+		os.WriteFile(path, []byte("v1"), 0600)
+		manager := NewManager(&config.Config{KiroCLIDBFile: path})
+
+		os.WriteFile(path+"-wal", []byte("frame"), 0600)
+		assert.True(t, manager.reloadIfDBChanged())
+	})
+
+	t.Run("database created after startup is picked up", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.sqlite3")
+		manager := NewManager(&config.Config{KiroCLIDBFile: path})
+		assert.False(t, manager.reloadIfDBChanged())
+
+		os.WriteFile(path, []byte("v1"), 0600)
+		assert.True(t, manager.reloadIfDBChanged())
+	})
+
+	t.Run("start and stop", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "data.sqlite3")
+		manager := NewManager(&config.Config{KiroCLIDBFile: path, KiroCLIDBWatchInterval: 1})
+
+		manager.StartWatcher()
+		manager.StartWatcher() // idempotent
+		manager.StartRefresher()
+
+		done := make(chan struct{})
+		go func() {
+			manager.Stop()
+			close(done)
+		}()
+
+		select {
+		case <-done:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Stop did not return")
+		}
+	})
+
+	t.Run("not started without a database", func(t *testing.T) {
+		manager := NewManager(&config.Config{RefreshToken: "refresh", KiroCLIDBWatchInterval: 1})
+
+		manager.StartWatcher()
+		assert.False(t, manager.watcherStarted)
+	})
+}
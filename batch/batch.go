@@ -0,0 +1,514 @@
+// Package batch runs Anthropic message batches.
+//
+// A batch is a list of Messages API requests, each with a client-chosen
+// custom_id. The Manager processes the requests in the background, at most
+// BATCH_CONCURRENCY at a time across all batches, and keeps one result per
+// request. Batches and results are persisted to BATCH_DIR so they survive a
+// restart; requests still pending when the gateway stopped are reported as
+// errored. Batches are only visible to the API key that created them, and ended
+// batches are deleted BATCH_RETENTION after they ended.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/usage"
+	"kiro-go-proxy/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Processing statuses
+const (
+	StatusInProgress = "in_progress"
+	StatusCanceling  = "canceling"
+	StatusEnded      = "ended"
+)
+
+// Result types
+const (
+	ResultSucceeded = "succeeded"
+	ResultErrored   = "errored"
+	ResultCanceled  = "canceled"
+	ResultExpired   = "expired"
+)
+
+// Expiry is how long a batch may process before unstarted requests expire
+const Expiry = 24 * time.Hour
+
+// sweepInterval is the least time between two sweeps for batches past retention
+const sweepInterval = time.Hour
+
+// interruptedMessage is the error of requests left pending by a restart
+const interruptedMessage = "Batch processing was interrupted by a gateway restart"
+
+var (
+	// ErrNotFound is returned for unknown batch IDs and batches of other API keys
+	ErrNotFound = errors.New("batch not found")
+	// ErrNotEnded is returned when results or deletion are requested before a batch has ended
+	ErrNotEnded = errors.New("batch has not finished processing")
+)
+
+var customIDPattern = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// Request is one entry of a batch
+type Request struct {
+	CustomID string          `json:"custom_id"`
+	Params   json.RawMessage `json:"params"`
+}
+
+// RequestCounts tallies the requests of a batch by state
+type RequestCounts struct {
+	Processing int `json:"processing"`
+	Succeeded  int `json:"succeeded"`
+	Errored    int `json:"errored"`
+	Canceled   int `json:"canceled"`
+	Expired    int `json:"expired"`
+}
+
+// Batch is the Anthropic message batch object
+type Batch struct {
+	ID                string        `json:"id"`
+	Type              string        `json:"type"`
+	ProcessingStatus  string        `json:"processing_status"`
+	RequestCounts     RequestCounts `json:"request_counts"`
+	EndedAt           *time.Time    `json:"ended_at"`
+	CreatedAt         time.Time     `json:"created_at"`
+	ExpiresAt         time.Time     `json:"expires_at"`
+	ArchivedAt        *time.Time    `json:"archived_at"`
+	CancelInitiatedAt *time.Time    `json:"cancel_initiated_at"`
+	ResultsURL        *string       `json:"results_url"`
+}
+
+// ErrorDetail is the inner object of an Anthropic error
+type ErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// Error is an Anthropic error response
+type Error struct {
+	Type  string      `json:"type"`
+	Error ErrorDetail `json:"error"`
+}
+
+// Result is the outcome of one request
+type Result struct {
+	Type    string          `json:"type"`
+	Message json.RawMessage `json:"message,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// Line is one entry of a batch's results
+type Line struct {
+	CustomID string `json:"custom_id"`
+	Result   Result `json:"result"`
+}
+
+// Succeeded returns the result of a request answered with message
+func Succeeded(message interface{}) Result {
+	data, err := json.Marshal(message)
+	if err != nil {
+		return Errored("api_error", fmt.Sprintf("Failed to encode message: %v", err))
+	}
+	return Result{Type: ResultSucceeded, Message: data}
+}
+
+// Errored returns the result of a failed request
+func Errored(errType, message string) Result {
+	return Result{Type: ResultErrored, Error: &Error{Type: "error", Error: ErrorDetail{Type: errType, Message: message}}}
+}
+
+// Processor answers one request's params on behalf of apiKey
+type Processor func(ctx context.Context, apiKey string, params json.RawMessage) Result
+
+// state is a batch with its requests and results
+type state struct {
+	Batch
+	Owner    string    `json:"owner"` // usage.KeyID of the creating API key
+	Requests []Request `json:"requests"`
+
+	results []*Result // nil until the request is processed
+	apiKey  string    // only known for batches created since startup
+}
+
+// snapshot returns a copy of the batch object. Caller must hold m.mu.
+func (st *state) snapshot() Batch {
+	return st.Batch
+}
+
+// Manager creates, processes and stores batches
+type Manager struct {
+	dir         string
+	maxRequests int
+	retention   time.Duration
+	process     Processor
+	now         func() time.Time
+
+	slots  chan struct{} // one per concurrently processed request
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	batches   map[string]*state
+	lastSweep time.Time
+	mu        sync.Mutex
+}
+
+// NewManager creates a manager from the BATCH_* settings and loads batches
+// persisted in BATCH_DIR
+func NewManager(cfg *config.Config, process Processor) *Manager {
+	concurrency := cfg.BatchConcurrency
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &Manager{
+		dir:         cfg.BatchDir,
+		maxRequests: cfg.BatchMaxRequests,
+		retention:   time.Duration(cfg.BatchRetention * float64(time.Second)),
+		process:     process,
+		now:         time.Now,
+		slots:       make(chan struct{}, concurrency),
+		ctx:         ctx,
+		cancel:      cancel,
+		batches:     make(map[string]*state),
+	}
+
+	if m.dir != "" {
+		if err := m.load(); err != nil {
+			log.Warnf("Failed to load message batches from %s: %v", m.dir, err)
+		}
+	}
+	m.mu.Lock()
+	m.sweep()
+	m.mu.Unlock()
+	return m
+}
+
+// Create validates the requests, stores a new batch and starts processing it.
+// Validation errors are returned as *ValidationError.
+func (m *Manager) Create(apiKey string, requests []Request) (Batch, error) {
+	if err := m.validate(requests); err != nil {
+		return Batch{}, err
+	}
+
+	now := m.now()
+	st := &state{
+		Batch: Batch{
+			ID:               utils.GenerateBatchID(),
+			Type:             "message_batch",
+			ProcessingStatus: StatusInProgress,
+			RequestCounts:    RequestCounts{Processing: len(requests)},
+			CreatedAt:        now,
+			ExpiresAt:        now.Add(Expiry),
+		},
+		Owner:    usage.KeyID(apiKey),
+		Requests: requests,
+		results:  make([]*Result, len(requests)),
+		apiKey:   apiKey,
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maybeSweep()
+	if err := m.save(st); err != nil {
+		return Batch{}, fmt.Errorf("failed to persist batch: %w", err)
+	}
+	m.batches[st.ID] = st
+
+	m.wg.Add(1)
+	go m.run(st)
+	return st.snapshot(), nil
+}
+
+// ValidationError describes a batch rejected by Create
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+func (m *Manager) validate(requests []Request) error {
+	if len(requests) == 0 {
+		return &ValidationError{"requests must contain at least one request"}
+	}
+	if m.maxRequests > 0 && len(requests) > m.maxRequests {
+		return &ValidationError{fmt.Sprintf("requests cannot contain more than %d requests", m.maxRequests)}
+	}
+	seen := make(map[string]bool, len(requests))
+	for i, req := range requests {
+		if !customIDPattern.MatchString(req.CustomID) {
+			return &ValidationError{fmt.Sprintf("requests.%d.custom_id must be 1-64 letters, digits, underscores or hyphens", i)}
+		}
+		if seen[req.CustomID] {
+			return &ValidationError{fmt.Sprintf("requests.%d.custom_id %q is not unique", i, req.CustomID)}
+		}
+		seen[req.CustomID] = true
+	}
+	return nil
+}
+
+// Get returns a batch owned by apiKey
+func (m *Manager) Get(apiKey, id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, err := m.lookup(apiKey, id)
+	if err != nil {
+		return Batch{}, err
+	}
+	return st.snapshot(), nil
+}
+
+// lookup finds a batch owned by apiKey. Caller must hold m.mu.
+func (m *Manager) lookup(apiKey, id string) (*state, error) {
+	st, ok := m.batches[id]
+	if !ok || st.Owner != usage.KeyID(apiKey) {
+		return nil, ErrNotFound
+	}
+	return st, nil
+}
+
+// List returns up to limit batches owned by apiKey, most recent first, and
+// whether more exist. beforeID and afterID are cursors: the page ends just
+// before (newer than) or starts just after (older than) that batch.
+func (m *Manager) List(apiKey string, limit int, beforeID, afterID string) ([]Batch, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maybeSweep()
+
+	owner := usage.KeyID(apiKey)
+	var owned []*state
+	for _, st := range m.batches {
+		if st.Owner == owner {
+			owned = append(owned, st)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		if owned[i].CreatedAt.Equal(owned[j].CreatedAt) {
+			return owned[i].ID > owned[j].ID
+		}
+		return owned[i].CreatedAt.After(owned[j].CreatedAt)
+	})
+
+	start, end := 0, len(owned)
+	for i, st := range owned {
+		if st.ID == afterID {
+			start = i + 1
+		}
+		if st.ID == beforeID {
+			end = i
+		}
+	}
+	if start > end {
+		start = end
+	}
+	page := owned[start:end]
+
+	hasMore := false
+	if len(page) > limit {
+		hasMore = true
+		if beforeID != "" && afterID == "" {
+			page = page[len(page)-limit:]
+		} else {
+			page = page[:limit]
+		}
+	}
+
+	batches := make([]Batch, len(page))
+	for i, st := range page {
+		batches[i] = st.snapshot()
+	}
+	return batches, hasMore
+}
+
+// Results returns the result of every request of an ended batch, in request order
+func (m *Manager) Results(apiKey, id string) ([]Line, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, err := m.lookup(apiKey, id)
+	if err != nil {
+		return nil, err
+	}
+	if st.ProcessingStatus != StatusEnded {
+		return nil, ErrNotEnded
+	}
+
+	lines := make([]Line, len(st.Requests))
+	for i, req := range st.Requests {
+		lines[i] = Line{CustomID: req.CustomID, Result: *st.results[i]}
+	}
+	return lines, nil
+}
+
+// Cancel stops a batch from starting more requests. Requests already running
+// finish; the rest are reported as canceled once the batch ends.
+func (m *Manager) Cancel(apiKey, id string) (Batch, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, err := m.lookup(apiKey, id)
+	if err != nil {
+		return Batch{}, err
+	}
+	if st.ProcessingStatus == StatusInProgress {
+		now := m.now()
+		st.CancelInitiatedAt = &now
+		st.ProcessingStatus = StatusCanceling
+		if err := m.save(st); err != nil {
+			log.Warnf("Failed to persist batch %s: %v", st.ID, err)
+		}
+	}
+	return st.snapshot(), nil
+}
+
+// Delete removes an ended batch and its results
+func (m *Manager) Delete(apiKey, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	st, err := m.lookup(apiKey, id)
+	if err != nil {
+		return err
+	}
+	if st.ProcessingStatus != StatusEnded {
+		return ErrNotEnded
+	}
+	delete(m.batches, id)
+	return m.remove(id)
+}
+
+// maybeSweep sweeps if the last sweep is over sweepInterval ago. Caller must
+// hold m.mu.
+func (m *Manager) maybeSweep() {
+	if m.now().Sub(m.lastSweep) > sweepInterval {
+		m.sweep()
+	}
+}
+
+// sweep deletes the batches that ended more than BATCH_RETENTION ago, with
+// their files. Caller must hold m.mu.
+func (m *Manager) sweep() {
+	m.lastSweep = m.now()
+	if m.retention <= 0 {
+		return
+	}
+	for id, st := range m.batches {
+		if st.EndedAt == nil || m.now().Sub(*st.EndedAt) <= m.retention {
+			continue
+		}
+		delete(m.batches, id)
+		if err := m.remove(id); err != nil {
+			log.Warnf("Failed to delete expired batch %s: %v", id, err)
+			continue
+		}
+		log.Debugf("Deleted message batch %s past BATCH_RETENTION", id)
+	}
+}
+
+// Stop stops processing and waits for running requests to return. Their
+// batches stay in progress and are ended as interrupted on the next start.
+func (m *Manager) Stop() {
+	m.cancel()
+	m.wg.Wait()
+}
+
+// run processes the pending requests of a batch, then ends it
+func (m *Manager) run(st *state) {
+	defer m.wg.Done()
+
+	var running sync.WaitGroup
+	for i := range st.Requests {
+		select {
+		case m.slots <- struct{}{}:
+		case <-m.ctx.Done():
+			running.Wait()
+			return
+		}
+
+		m.mu.Lock()
+		canceling := st.CancelInitiatedAt != nil
+		expired := !m.now().Before(st.ExpiresAt)
+		m.mu.Unlock()
+		if canceling || expired {
+			<-m.slots
+			resultType := ResultCanceled
+			if !canceling {
+				resultType = ResultExpired
+			}
+			m.finish(st, i, Result{Type: resultType})
+			continue
+		}
+
+		running.Add(1)
+		go func(i int) {
+			defer running.Done()
+			defer func() { <-m.slots }()
+
+			result := m.process(m.ctx, st.apiKey, st.Requests[i].Params)
+			if m.ctx.Err() != nil {
+				// Shutting down: the result is likely an artifact of the cancellation
+				return
+			}
+			m.finish(st, i, result)
+		}(i)
+	}
+	running.Wait()
+
+	if m.ctx.Err() == nil {
+		m.end(st)
+	}
+}
+
+// finish records the result of request i
+func (m *Manager) finish(st *state, i int, result Result) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.record(st, i, &result)
+	if err := m.appendResult(st.ID, i, &result); err != nil {
+		log.Warnf("Failed to persist result of batch %s: %v", st.ID, err)
+	}
+}
+
+// record stores a result and updates the counts. Caller must hold m.mu.
+func (m *Manager) record(st *state, i int, result *Result) {
+	st.results[i] = result
+	st.RequestCounts.Processing--
+	switch result.Type {
+	case ResultSucceeded:
+		st.RequestCounts.Succeeded++
+	case ResultErrored:
+		st.RequestCounts.Errored++
+	case ResultCanceled:
+		st.RequestCounts.Canceled++
+	case ResultExpired:
+		st.RequestCounts.Expired++
+	}
+}
+
+// end marks a batch whose requests all have results as ended
+func (m *Manager) end(st *state) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.markEnded(st)
+	if err := m.save(st); err != nil {
+		log.Warnf("Failed to persist batch %s: %v", st.ID, err)
+	}
+}
+
+// markEnded sets the ended status and results URL. Caller must hold m.mu.
+func (m *Manager) markEnded(st *state) {
+	now := m.now()
+	resultsURL := fmt.Sprintf("/v1/messages/batches/%s/results", st.ID)
+	st.ProcessingStatus = StatusEnded
+	st.EndedAt = &now
+	st.ResultsURL = &resultsURL
+}
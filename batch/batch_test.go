@@ -0,0 +1,328 @@
+// Package batch provides tests for message batch processing and persistence.
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// echoProcessor answers every request with its params as the message
+func echoProcessor(ctx context.Context, apiKey string, params json.RawMessage) Result {
+	return Succeeded(params)
+}
+
+// requests builds batch requests with the given custom IDs
+func requests(ids ...string) []Request {
+	reqs := make([]Request, len(ids))
+	for i, id := range ids {
+		reqs[i] = Request{CustomID: id, Params: json.RawMessage(`{"id":"` + id + `"}`)}
+	}
+	return reqs
+}
+
+// waitEnded polls until the batch has ended
+func waitEnded(t *testing.T, m *Manager, apiKey, id string) Batch {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		b, err := m.Get(apiKey, id)
+		assert.NoError(t, err)
+		if b.ProcessingStatus == StatusEnded {
+			return b
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("batch %s did not end", id)
+	return Batch{}
+}
+
+// =============================================================================
+// TestCreate
+// =============================================================================
+
+func TestCreate(t *testing.T) {
+	m := NewManager(&config.Config{BatchConcurrency: 2, BatchMaxRequests: 3}, echoProcessor)
+	defer m.Stop()
+
+	t.Run("processes every request", func(t *testing.T) {
+		created, err := m.Create("key", requests("a", "b", "c"))
+		assert.NoError(t, err)
+		assert.Regexp(t, `^msgbatch_[0-9a-f]{24}$`, created.ID)
+		assert.Equal(t, "message_batch", created.Type)
+		assert.Equal(t, created.CreatedAt.Add(Expiry), created.ExpiresAt)
+
+		ended := waitEnded(t, m, "key", created.ID)
+		assert.Equal(t, RequestCounts{Succeeded: 3}, ended.RequestCounts)
+		assert.NotNil(t, ended.EndedAt)
+		assert.Equal(t, "/v1/messages/batches/"+created.ID+"/results", *ended.ResultsURL)
+
+		lines, err := m.Results("key", created.ID)
+		assert.NoError(t, err)
+		assert.Len(t, lines, 3)
+		assert.Equal(t, "b", lines[1].CustomID)
+		assert.Equal(t, ResultSucceeded, lines[1].Result.Type)
+		assert.JSONEq(t, `{"id":"b"}`, string(lines[1].Result.Message))
+	})
+
+	t.Run("rejects invalid requests", func(t *testing.T) {
+		for name, reqs := range map[string][]Request{
+			"empty":        nil,
+			"too many":     requests("a", "b", "c", "d"),
+			"duplicate id": requests("a", "a"),
+			"bad id":       requests("has space"),
+		} {
+			_, err := m.Create("key", reqs)
+			var validationErr *ValidationError
+			assert.ErrorAs(t, err, &validationErr, name)
+		}
+	})
+
+	t.Run("batches are private to their API key", func(t *testing.T) {
+		created, _ := m.Create("key", requests("a"))
+		waitEnded(t, m, "key", created.ID)
+
+		_, err := m.Get("other", created.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = m.Results("other", created.ID)
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.ErrorIs(t, m.Delete("other", created.ID), ErrNotFound)
+	})
+}
+
+// =============================================================================
+// TestCancel
+// =============================================================================
+
+func TestCancel(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	m := NewManager(&config.Config{BatchConcurrency: 1}, func(ctx context.Context, apiKey string, params json.RawMessage) Result {
+		started <- struct{}{}
+		<-release
+		return Succeeded(params)
+	})
+	defer m.Stop()
+
+	created, _ := m.Create("key", requests("a", "b", "c"))
+	<-started
+
+	canceled, err := m.Cancel("key", created.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, StatusCanceling, canceled.ProcessingStatus)
+	assert.NotNil(t, canceled.CancelInitiatedAt)
+
+	_, err = m.Results("key", created.ID)
+	assert.ErrorIs(t, err, ErrNotEnded)
+	assert.ErrorIs(t, m.Delete("key", created.ID), ErrNotEnded)
+
+	// The running request finishes, the rest are canceled
+	close(release)
+	ended := waitEnded(t, m, "key", created.ID)
+	assert.Equal(t, RequestCounts{Succeeded: 1, Canceled: 2}, ended.RequestCounts)
+
+	assert.NoError(t, m.Delete("key", created.ID))
+	_, err = m.Get("key", created.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+// =============================================================================
+// TestExpiry
+// =============================================================================
+
+func TestExpiry(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	m := NewManager(&config.Config{BatchConcurrency: 1}, func(ctx context.Context, apiKey string, params json.RawMessage) Result {
+		started <- struct{}{}
+		<-release
+		return Succeeded(params)
+	})
+	defer m.Stop()
+
+	var elapsed atomic.Int64
+	start := time.Now()
+	m.now = func() time.Time { return start.Add(time.Duration(elapsed.Load())) }
+
+	created, _ := m.Create("key", requests("a", "b"))
+	<-started
+
+	// "b" is still waiting for a slot when the batch expires
+	elapsed.Store(int64(Expiry))
+	close(release)
+
+	lines := waitResults(t, m, "key", created.ID)
+	assert.Equal(t, ResultSucceeded, lines[0].Result.Type)
+	assert.Equal(t, ResultExpired, lines[1].Result.Type)
+}
+
+// =============================================================================
+// TestRetention
+// =============================================================================
+
+func TestRetention(t *testing.T) {
+	dir := t.TempDir()
+	m := NewManager(&config.Config{BatchDir: dir, BatchConcurrency: 1, BatchRetention: 3600}, echoProcessor)
+	defer m.Stop()
+
+	var elapsed atomic.Int64
+	start := time.Now()
+	m.now = func() time.Time { return start.Add(time.Duration(elapsed.Load())) }
+
+	ended, _ := m.Create("key", requests("a"))
+	waitEnded(t, m, "key", ended.ID)
+
+	elapsed.Store(int64(time.Hour + time.Minute))
+	kept, _ := m.Create("key", requests("a"))
+	waitEnded(t, m, "key", kept.ID)
+
+	_, err := m.Get("key", ended.ID)
+	assert.ErrorIs(t, err, ErrNotFound)
+	_, err = m.Get("key", kept.ID)
+	assert.NoError(t, err)
+
+	entries, _ := os.ReadDir(dir)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), ended.ID)
+	}
+	assert.FileExists(t, filepath.Join(dir, kept.ID+batchExt))
+}
+
+// waitResults waits for a batch to end and returns its results
+func waitResults(t *testing.T, m *Manager, apiKey, id string) []Line {
+	t.Helper()
+	waitEnded(t, m, apiKey, id)
+	lines, err := m.Results(apiKey, id)
+	assert.NoError(t, err)
+	return lines
+}
+
+// =============================================================================
+// TestList
+// =============================================================================
+
+func TestList(t *testing.T) {
+	m := NewManager(&config.Config{BatchConcurrency: 1}, echoProcessor)
+	defer m.Stop()
+	var elapsed atomic.Int64
+	start := time.Unix(1700000000, 0)
+	m.now = func() time.Time { return start.Add(time.Duration(elapsed.Load())) }
+
+	var ids []string
+	for i := 0; i < 5; i++ {
+		created, _ := m.Create("key", requests("a"))
+		ids = append(ids, created.ID)
+		elapsed.Add(int64(time.Second))
+	}
+	m.Create("other", requests("a"))
+
+	page, hasMore := m.List("key", 2, "", "")
+	assert.True(t, hasMore)
+	assert.Equal(t, []string{ids[4], ids[3]}, batchIDs(page))
+
+	page, hasMore = m.List("key", 2, "", ids[3])
+	assert.True(t, hasMore)
+	assert.Equal(t, []string{ids[2], ids[1]}, batchIDs(page))
+
+	page, hasMore = m.List("key", 2, "", ids[1])
+	assert.False(t, hasMore)
+	assert.Equal(t, []string{ids[0]}, batchIDs(page))
+
+	page, hasMore = m.List("key", 2, ids[1], "")
+	assert.True(t, hasMore)
+	assert.Equal(t, []string{ids[3], ids[2]}, batchIDs(page))
+}
+
+func batchIDs(batches []Batch) []string {
+	ids := make([]string, len(batches))
+	for i, b := range batches {
+		ids[i] = b.ID
+	}
+	return ids
+}
+
+// =============================================================================
+// TestPersistence
+// =============================================================================
+
+func TestPersistence(t *testing.T) {
+	t.Run("ended batches survive a restart", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := &config.Config{BatchDir: dir, BatchConcurrency: 1}
+
+		m := NewManager(cfg, echoProcessor)
+		created, _ := m.Create("key", requests("a", "b"))
+		waitEnded(t, m, "key", created.ID)
+		m.Stop()
+
+		reloaded := NewManager(cfg, echoProcessor)
+		defer reloaded.Stop()
+		b, err := reloaded.Get("key", created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, StatusEnded, b.ProcessingStatus)
+		assert.Equal(t, RequestCounts{Succeeded: 2}, b.RequestCounts)
+
+		lines, err := reloaded.Results("key", created.ID)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"id":"b"}`, string(lines[1].Result.Message))
+
+		assert.NoError(t, reloaded.Delete("key", created.ID))
+		entries, _ := os.ReadDir(dir)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("interrupted requests are errored on restart", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := &config.Config{BatchDir: dir, BatchConcurrency: 1}
+
+		started := make(chan struct{}, 1)
+		m := NewManager(cfg, func(ctx context.Context, apiKey string, params json.RawMessage) Result {
+			if string(params) == `{"id":"a"}` {
+				return Succeeded(params)
+			}
+			started <- struct{}{}
+			<-ctx.Done()
+			return Errored("api_error", ctx.Err().Error())
+		})
+		created, _ := m.Create("key", requests("a", "b", "c"))
+		<-started
+		m.Stop()
+
+		reloaded := NewManager(cfg, echoProcessor)
+		defer reloaded.Stop()
+		b, _ := reloaded.Get("key", created.ID)
+		assert.Equal(t, StatusEnded, b.ProcessingStatus)
+		assert.Equal(t, RequestCounts{Succeeded: 1, Errored: 2}, b.RequestCounts)
+
+		lines, _ := reloaded.Results("key", created.ID)
+		assert.Equal(t, ResultSucceeded, lines[0].Result.Type)
+		assert.Equal(t, interruptedMessage, lines[1].Result.Error.Error.Message)
+	})
+
+	t.Run("truncated result line is ignored", func(t *testing.T) {
+		dir := t.TempDir()
+		cfg := &config.Config{BatchDir: dir, BatchConcurrency: 1}
+
+		m := NewManager(cfg, echoProcessor)
+		created, _ := m.Create("key", requests("a"))
+		waitEnded(t, m, "key", created.ID)
+		m.Stop()
+
+		f, _ := os.OpenFile(filepath.Join(dir, created.ID+resultsExt), os.O_APPEND|os.O_WRONLY, 0600)
+		f.WriteString(`{"index":0,"res`)
+		f.Close()
+
+		reloaded := NewManager(cfg, echoProcessor)
+		defer reloaded.Stop()
+		lines, err := reloaded.Results("key", created.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, ResultSucceeded, lines[0].Result.Type)
+	})
+}
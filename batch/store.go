@@ -0,0 +1,168 @@
+package batch
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// File names within BATCH_DIR: {id}.json holds the batch and its requests,
+// {id}.results.jsonl gets one line per finished request
+const (
+	batchExt   = ".json"
+	resultsExt = ".results.jsonl"
+)
+
+// storedResult is one line of a results file
+type storedResult struct {
+	Index  int    `json:"index"`
+	Result Result `json:"result"`
+}
+
+// save writes the batch file atomically via a temp file. Caller must hold m.mu.
+func (m *Manager) save(st *state) error {
+	if m.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(st)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return err
+	}
+	path := filepath.Join(m.dir, st.ID+batchExt)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// appendResult adds request i's result to the results file. Caller must hold m.mu.
+func (m *Manager) appendResult(id string, i int, result *Result) error {
+	if m.dir == "" {
+		return nil
+	}
+	data, err := json.Marshal(storedResult{Index: i, Result: *result})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(m.dir, id+resultsExt), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// remove deletes a batch's files. Caller must hold m.mu.
+func (m *Manager) remove(id string) error {
+	if m.dir == "" {
+		return nil
+	}
+	for _, ext := range []string{batchExt, resultsExt} {
+		if err := os.Remove(filepath.Join(m.dir, id+ext)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the persisted batches. Requests of batches that were still in
+// progress have no one left to run them and are ended as errored.
+func (m *Manager) load() error {
+	entries, err := os.ReadDir(m.dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, batchExt) {
+			continue
+		}
+		st, err := m.loadBatch(strings.TrimSuffix(name, batchExt))
+		if err != nil {
+			log.Warnf("Skipping message batch %s: %v", name, err)
+			continue
+		}
+		m.batches[st.ID] = st
+	}
+	return nil
+}
+
+// loadBatch reads one batch and its results. Caller must hold m.mu.
+func (m *Manager) loadBatch(id string) (*state, error) {
+	data, err := os.ReadFile(filepath.Join(m.dir, id+batchExt))
+	if err != nil {
+		return nil, err
+	}
+	st := &state{}
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, err
+	}
+	st.results = make([]*Result, len(st.Requests))
+	st.RequestCounts = RequestCounts{Processing: len(st.Requests)}
+
+	if err := m.loadResults(st); err != nil {
+		return nil, err
+	}
+
+	for i, result := range st.results {
+		if result == nil {
+			interrupted := Errored("api_error", interruptedMessage)
+			m.record(st, i, &interrupted)
+			if err := m.appendResult(st.ID, i, &interrupted); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if st.ProcessingStatus != StatusEnded {
+		m.markEnded(st)
+		if err := m.save(st); err != nil {
+			return nil, err
+		}
+		log.Infof("Ended message batch %s interrupted by restart", st.ID)
+	}
+	return st, nil
+}
+
+// loadResults reads the results file of st. A truncated last line, left by a
+// crash mid-write, is ignored.
+func (m *Manager) loadResults(st *state) error {
+	f, err := os.Open(filepath.Join(m.dir, st.ID+resultsExt))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	for scanner.Scan() {
+		var line storedResult
+		if err := json.Unmarshal(scanner.Bytes(), &line); err != nil {
+			continue
+		}
+		if line.Index < 0 || line.Index >= len(st.results) || st.results[line.Index] != nil {
+			continue
+		}
+		result := line.Result
+		m.record(st, line.Index, &result)
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,284 @@
+// Package client provides HTTP client with retry logic for Kiro API.
+package client
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BreakerState is the state of the circuit breaker around the Kiro API
+type BreakerState string
+
+const (
+	// BreakerClosed lets every request through while counting failures
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen rejects requests without contacting Kiro
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets a few probe requests through to test recovery
+	BreakerHalfOpen BreakerState = "half_open"
+)
+
+// breakerBuckets is the number of buckets the failure-rate window is split into
+const breakerBuckets = 10
+
+// CircuitOpenError is returned without contacting Kiro while the breaker is open
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("Kiro API is unavailable (circuit breaker open), retry in %ds", RetryAfterSeconds(e.RetryAfter))
+}
+
+// RetryAfterSeconds rounds a wait up to whole seconds for a Retry-After header
+func RetryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// BreakerStatus is a snapshot of the circuit breaker for health and metrics
+type BreakerStatus struct {
+	Enabled     bool         `json:"enabled"`
+	State       BreakerState `json:"state"`
+	Requests    int          `json:"requests"`
+	Failures    int          `json:"failures"`
+	FailureRate float64      `json:"failure_rate"`
+	Opens       int64        `json:"opens"`
+	Rejected    int64        `json:"rejected"`
+	OpenedAt    *time.Time   `json:"opened_at,omitempty"`
+}
+
+// callResult is the outcome of a request admitted by Breaker.allow
+type callResult int
+
+const (
+	callSucceeded callResult = iota
+	callFailed
+	// callAbandoned is a request the client cancelled; it says nothing about Kiro
+	callAbandoned
+)
+
+// breakerBucket counts the requests finished during one slice of the window
+type breakerBucket struct {
+	start    time.Time
+	requests int
+	failures int
+}
+
+// Breaker is a failure-rate circuit breaker. While closed it counts failed and
+// slow requests over a rolling window and opens once the failure rate reaches
+// the threshold. After the open duration it turns half-open and admits a few
+// probes: if they all succeed it closes, if any fails it opens again.
+type Breaker struct {
+	failureRate      float64
+	minRequests      int
+	window           time.Duration
+	slowThreshold    time.Duration
+	openDuration     time.Duration
+	halfOpenRequests int
+
+	mu       sync.Mutex
+	state    BreakerState
+	buckets  [breakerBuckets]breakerBucket
+	openedAt time.Time
+	// generation changes on every state transition so late results of requests
+	// admitted in an earlier state are ignored
+	generation     uint64
+	probes         int
+	probeSuccesses int
+	opens          int64
+	rejected       int64
+
+	// now is replaced in tests
+	now func() time.Time
+}
+
+// NewBreaker creates a circuit breaker from the CIRCUIT_BREAKER_* settings
+func NewBreaker(cfg *config.Config) *Breaker {
+	b := &Breaker{
+		failureRate:      cfg.CircuitBreakerFailureRate,
+		minRequests:      cfg.CircuitBreakerMinRequests,
+		window:           time.Duration(cfg.CircuitBreakerWindow) * time.Second,
+		slowThreshold:    time.Duration(cfg.CircuitBreakerSlowThreshold * float64(time.Second)),
+		openDuration:     time.Duration(cfg.CircuitBreakerOpenDuration) * time.Second,
+		halfOpenRequests: cfg.CircuitBreakerHalfOpenRequests,
+		state:            BreakerClosed,
+		now:              time.Now,
+	}
+	if b.minRequests < 1 {
+		b.minRequests = 1
+	}
+	if b.window <= 0 {
+		b.window = time.Minute
+	}
+	if b.halfOpenRequests < 1 {
+		b.halfOpenRequests = 1
+	}
+	return b
+}
+
+// Enabled reports whether the breaker can open
+func (b *Breaker) Enabled() bool {
+	return b.failureRate > 0
+}
+
+// allow admits a request or returns a *CircuitOpenError. Admitted requests must
+// report their outcome through the returned function.
+func (b *Breaker) allow() (func(callResult), error) {
+	if !b.Enabled() {
+		return func(callResult) {}, nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	b.advance(now)
+
+	probe := false
+	switch b.state {
+	case BreakerOpen:
+		b.rejected++
+		return nil, &CircuitOpenError{RetryAfter: b.openedAt.Add(b.openDuration).Sub(now)}
+	case BreakerHalfOpen:
+		if b.probes+b.probeSuccesses >= b.halfOpenRequests {
+			b.rejected++
+			return nil, &CircuitOpenError{RetryAfter: time.Second}
+		}
+		b.probes++
+		probe = true
+	}
+
+	generation := b.generation
+	start := now
+	return func(result callResult) {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+
+		now := b.now()
+		if result == callSucceeded && b.slowThreshold > 0 && now.Sub(start) > b.slowThreshold {
+			result = callFailed
+		}
+		if generation != b.generation {
+			return
+		}
+		if probe {
+			b.probes--
+		}
+		b.record(now, result, probe)
+	}, nil
+}
+
+// record applies the outcome of a request admitted in the current state
+func (b *Breaker) record(now time.Time, result callResult, probe bool) {
+	if result == callAbandoned {
+		return
+	}
+
+	if probe {
+		if result == callFailed {
+			log.Warn("Kiro API probe failed, circuit breaker open again")
+			b.transition(BreakerOpen, now)
+			return
+		}
+		b.probeSuccesses++
+		if b.probeSuccesses >= b.halfOpenRequests {
+			log.Info("Kiro API recovered, circuit breaker closed")
+			b.transition(BreakerClosed, now)
+		}
+		return
+	}
+
+	bucket := b.bucket(now)
+	bucket.requests++
+	if result == callFailed {
+		bucket.failures++
+	}
+
+	requests, failures := b.counts(now)
+	if requests >= b.minRequests && float64(failures)/float64(requests) >= b.failureRate {
+		log.Warnf("Kiro API failing (%d of %d requests in the last %v), circuit breaker open for %v", failures, requests, b.window, b.openDuration)
+		b.transition(BreakerOpen, now)
+	}
+}
+
+// advance turns an open breaker half-open once the open duration has passed
+func (b *Breaker) advance(now time.Time) {
+	if b.state == BreakerOpen && now.Sub(b.openedAt) >= b.openDuration {
+		log.Info("Circuit breaker half-open, probing Kiro API")
+		b.transition(BreakerHalfOpen, now)
+	}
+}
+
+// transition moves to state, resetting the counters of the previous state
+func (b *Breaker) transition(state BreakerState, now time.Time) {
+	b.state = state
+	b.generation++
+	b.probes = 0
+	b.probeSuccesses = 0
+	switch state {
+	case BreakerOpen:
+		b.openedAt = now
+		b.opens++
+	case BreakerClosed:
+		b.buckets = [breakerBuckets]breakerBucket{}
+	}
+}
+
+// bucket returns the bucket for now, clearing it if it holds an older slice
+func (b *Breaker) bucket(now time.Time) *breakerBucket {
+	width := b.window / breakerBuckets
+	start := now.Truncate(width)
+	bucket := &b.buckets[(start.UnixNano()/int64(width))%breakerBuckets]
+	if !bucket.start.Equal(start) {
+		*bucket = breakerBucket{start: start}
+	}
+	return bucket
+}
+
+// counts sums the requests and failures finished within the window
+func (b *Breaker) counts(now time.Time) (requests, failures int) {
+	for _, bucket := range b.buckets {
+		if now.Sub(bucket.start) < b.window {
+			requests += bucket.requests
+			failures += bucket.failures
+		}
+	}
+	return requests, failures
+}
+
+// Status returns a snapshot of the breaker state and window counters
+func (b *Breaker) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if b.Enabled() {
+		b.advance(now)
+	}
+
+	status := BreakerStatus{
+		Enabled:  b.Enabled(),
+		State:    b.state,
+		Opens:    b.opens,
+		Rejected: b.rejected,
+	}
+	status.Requests, status.Failures = b.counts(now)
+	if status.Requests > 0 {
+		status.FailureRate = float64(status.Failures) / float64(status.Requests)
+	}
+	if b.state != BreakerClosed {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	return status
+}
@@ -0,0 +1,188 @@
+// Package client provides tests for the circuit breaker around the Kiro API.
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// newTestBreaker returns a breaker that opens at 50% failures over 4 requests,
+// with a clock the test advances by hand
+func newTestBreaker() (*Breaker, *time.Time) {
+	b := NewBreaker(&config.Config{
+		CircuitBreakerFailureRate:      0.5,
+		CircuitBreakerMinRequests:      4,
+		CircuitBreakerWindow:           60,
+		CircuitBreakerSlowThreshold:    5,
+		CircuitBreakerOpenDuration:     30,
+		CircuitBreakerHalfOpenRequests: 1,
+	})
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	b.now = func() time.Time { return now }
+	return b, &now
+}
+
+// call admits a request and reports result, failing the test if it is rejected
+func call(t *testing.T, b *Breaker, result callResult) {
+	t.Helper()
+	done, err := b.allow()
+	if assert.NoError(t, err) {
+		done(result)
+	}
+}
+
+// =============================================================================
+// TestBreaker
+// =============================================================================
+
+func TestBreaker(t *testing.T) {
+	t.Run("opens at the failure rate", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		call(t, b, callSucceeded)
+		call(t, b, callFailed)
+		call(t, b, callSucceeded)
+		assert.Equal(t, BreakerClosed, b.Status().State)
+
+		call(t, b, callFailed)
+		assert.Equal(t, BreakerOpen, b.Status().State)
+
+		_, err := b.allow()
+		var open *CircuitOpenError
+		assert.True(t, errors.As(err, &open))
+		assert.Equal(t, 30*time.Second, open.RetryAfter)
+
+		status := b.Status()
+		assert.Equal(t, int64(1), status.Opens)
+		assert.Equal(t, int64(1), status.Rejected)
+		assert.NotNil(t, status.OpenedAt)
+	})
+
+	t.Run("stays closed below the minimum request count", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		for i := 0; i < 3; i++ {
+			call(t, b, callFailed)
+		}
+		assert.Equal(t, BreakerClosed, b.Status().State)
+	})
+
+	t.Run("forgets failures outside the window", func(t *testing.T) {
+		b, now := newTestBreaker()
+		call(t, b, callFailed)
+		call(t, b, callFailed)
+		*now = now.Add(90 * time.Second)
+		call(t, b, callSucceeded)
+		call(t, b, callFailed)
+
+		status := b.Status()
+		assert.Equal(t, BreakerClosed, status.State)
+		assert.Equal(t, 2, status.Requests)
+		assert.Equal(t, 1, status.Failures)
+	})
+
+	t.Run("counts slow requests as failures", func(t *testing.T) {
+		b, now := newTestBreaker()
+		for i := 0; i < 4; i++ {
+			done, err := b.allow()
+			assert.NoError(t, err)
+			*now = now.Add(6 * time.Second)
+			done(callSucceeded)
+		}
+		assert.Equal(t, BreakerOpen, b.Status().State)
+	})
+
+	t.Run("ignores abandoned requests", func(t *testing.T) {
+		b, _ := newTestBreaker()
+		for i := 0; i < 4; i++ {
+			call(t, b, callAbandoned)
+		}
+		assert.Equal(t, 0, b.Status().Requests)
+	})
+
+	t.Run("half-open probe closes on success", func(t *testing.T) {
+		b, now := newTestBreaker()
+		for i := 0; i < 4; i++ {
+			call(t, b, callFailed)
+		}
+		*now = now.Add(30 * time.Second)
+		assert.Equal(t, BreakerHalfOpen, b.Status().State)
+
+		done, err := b.allow()
+		assert.NoError(t, err)
+		// Only one probe at a time
+		_, err = b.allow()
+		assert.Error(t, err)
+
+		done(callSucceeded)
+		status := b.Status()
+		assert.Equal(t, BreakerClosed, status.State)
+		assert.Equal(t, 0, status.Requests)
+	})
+
+	t.Run("half-open probe reopens on failure", func(t *testing.T) {
+		b, now := newTestBreaker()
+		for i := 0; i < 4; i++ {
+			call(t, b, callFailed)
+		}
+		*now = now.Add(30 * time.Second)
+		call(t, b, callFailed)
+
+		status := b.Status()
+		assert.Equal(t, BreakerOpen, status.State)
+		assert.Equal(t, int64(2), status.Opens)
+	})
+
+	t.Run("ignores results from before the breaker opened", func(t *testing.T) {
+		b, now := newTestBreaker()
+		late, err := b.allow()
+		assert.NoError(t, err)
+		for i := 0; i < 4; i++ {
+			call(t, b, callFailed)
+		}
+		*now = now.Add(30 * time.Second)
+		late(callSucceeded)
+		assert.Equal(t, BreakerHalfOpen, b.Status().State)
+	})
+
+	t.Run("disabled breaker never opens", func(t *testing.T) {
+		b := NewBreaker(&config.Config{CircuitBreakerMinRequests: 1})
+		for i := 0; i < 10; i++ {
+			call(t, b, callFailed)
+		}
+		status := b.Status()
+		assert.False(t, status.Enabled)
+		assert.Equal(t, BreakerClosed, status.State)
+	})
+}
+
+// =============================================================================
+// TestBreakerResult
+// =============================================================================
+
+func TestBreakerResult(t *testing.T) {
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+
+	assert.Equal(t, callSucceeded, breakerResult(ctx, &http.Response{StatusCode: http.StatusOK}, nil))
+	assert.Equal(t, callSucceeded, breakerResult(ctx, &http.Response{StatusCode: http.StatusTooManyRequests}, nil))
+	assert.Equal(t, callFailed, breakerResult(ctx, &http.Response{StatusCode: http.StatusBadGateway}, nil))
+	assert.Equal(t, callFailed, breakerResult(ctx, nil, errors.New("connection reset")))
+	assert.Equal(t, callAbandoned, breakerResult(cancelled, nil, context.Canceled))
+	assert.Equal(t, callAbandoned, breakerResult(ctx, nil, &permanentError{errors.New("failed to marshal payload")}))
+}
+
+// =============================================================================
+// TestCircuitOpenError
+// =============================================================================
+
+func TestCircuitOpenError(t *testing.T) {
+	assert.Equal(t, 2, RetryAfterSeconds(1500*time.Millisecond))
+	assert.Equal(t, 1, RetryAfterSeconds(0))
+	assert.Contains(t, (&CircuitOpenError{RetryAfter: 10 * time.Second}).Error(), "retry in 10s")
+}
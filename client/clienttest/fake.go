@@ -0,0 +1,154 @@
+// Package clienttest provides an in-memory Kiro client for handler tests.
+//
+// Fake replays canned Kiro responses instead of calling the API, so routes can be
+// exercised end to end:
+//
+//	fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hello"}`))
+//	server.HttpClient = fake
+//
+// Recorded streams, such as the kiro_stream.bin file of a DEBUG_MODE capture,
+// can be replayed with LoadStream.
+package clienttest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"kiro-go-proxy/client"
+	"kiro-go-proxy/model"
+)
+
+// Response is a canned Kiro response
+type Response struct {
+	// StatusCode defaults to 200
+	StatusCode int
+	Body       string
+	// Err is returned instead of a response when set
+	Err error
+	// Hang keeps the body open after Body, like a stalled Kiro stream, until the
+	// request context ends
+	Hang bool
+}
+
+// Stream returns a successful response whose body is the given Kiro stream events
+func Stream(events ...string) Response {
+	return Response{StatusCode: http.StatusOK, Body: strings.Join(events, "")}
+}
+
+// LoadStream reads a recorded Kiro stream from path
+func LoadStream(path string) (Response, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Response{}, err
+	}
+	return Response{StatusCode: http.StatusOK, Body: string(data)}, nil
+}
+
+// Request is a call received by the fake
+type Request struct {
+	Method  string
+	URL     string
+	Payload interface{}
+}
+
+// Fake is a client.KiroClient that answers requests with queued responses in order
+type Fake struct {
+	// Models is returned by ListModels, or ModelsErr when set
+	Models    []model.Info
+	ModelsErr error
+
+	mu        sync.Mutex
+	responses []Response
+	requests  []Request
+}
+
+var _ client.KiroClient = (*Fake)(nil)
+
+// NewFake creates a fake that answers with responses in order
+func NewFake(responses ...Response) *Fake {
+	return &Fake{responses: responses}
+}
+
+// Push queues more responses
+func (f *Fake) Push(responses ...Response) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.responses = append(f.responses, responses...)
+}
+
+// Requests returns the requests received so far
+func (f *Fake) Requests() []Request {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]Request(nil), f.requests...)
+}
+
+// PostStream answers with the next queued response
+func (f *Fake) PostStream(ctx context.Context, url string, payload interface{}) (*http.Response, error) {
+	return f.do(ctx, "POST", url, payload)
+}
+
+// Get answers with the next queued response
+func (f *Fake) Get(ctx context.Context, url string) (*http.Response, error) {
+	return f.do(ctx, "GET", url, nil)
+}
+
+// ListModels returns Models
+func (f *Fake) ListModels(ctx context.Context) ([]model.Info, error) {
+	if f.ModelsErr != nil {
+		return nil, f.ModelsErr
+	}
+	return f.Models, nil
+}
+
+func (f *Fake) do(ctx context.Context, method, url string, payload interface{}) (*http.Response, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, Request{Method: method, URL: url, Payload: payload})
+	if len(f.responses) == 0 {
+		return nil, fmt.Errorf("clienttest: no response queued for %s %s", method, url)
+	}
+
+	resp := f.responses[0]
+	f.responses = f.responses[1:]
+	if resp.Err != nil {
+		return nil, resp.Err
+	}
+
+	status := resp.StatusCode
+	if status == 0 {
+		status = http.StatusOK
+	}
+	var body io.Reader = strings.NewReader(resp.Body)
+	if resp.Hang {
+		body = &hangingReader{data: body, ctx: ctx}
+	}
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": []string{"application/vnd.amazon.eventstream"}},
+		Body:       io.NopCloser(body),
+		Request:    (&http.Request{Method: method}).WithContext(ctx),
+	}, nil
+}
+
+// hangingReader returns its data and then blocks until ctx ends
+type hangingReader struct {
+	data io.Reader
+	ctx  context.Context
+}
+
+func (r *hangingReader) Read(p []byte) (int, error) {
+	n, err := r.data.Read(p)
+	if err != io.EOF || n > 0 {
+		return n, err
+	}
+	<-r.ctx.Done()
+	return 0, r.ctx.Err()
+}
@@ -0,0 +1,101 @@
+// Package clienttest provides tests for the in-memory Kiro client.
+package clienttest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/model"
+)
+
+// =============================================================================
+// TestFake
+// =============================================================================
+
+func TestFake(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replays responses in order", func(t *testing.T) {
+		fake := NewFake(Stream(`{"content":"one"}`), Response{StatusCode: http.StatusTooManyRequests, Body: "slow down"})
+
+		resp, err := fake.PostStream(ctx, "https://kiro/generate", map[string]string{"a": "b"})
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, `{"content":"one"}`, string(body))
+
+		resp, err = fake.Get(ctx, "https://kiro/other")
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	})
+
+	t.Run("errors once the queue is empty", func(t *testing.T) {
+		fake := NewFake()
+
+		_, err := fake.PostStream(ctx, "https://kiro/generate", nil)
+		assert.Error(t, err)
+
+		fake.Push(Stream(`{"content":"late"}`))
+		_, err = fake.PostStream(ctx, "https://kiro/generate", nil)
+		assert.NoError(t, err)
+	})
+
+	t.Run("returns queued errors", func(t *testing.T) {
+		fake := NewFake(Response{Err: errors.New("connection reset")})
+
+		_, err := fake.PostStream(ctx, "https://kiro/generate", nil)
+		assert.EqualError(t, err, "connection reset")
+	})
+
+	t.Run("records requests", func(t *testing.T) {
+		fake := NewFake(Stream(), Stream())
+		fake.PostStream(ctx, "https://kiro/a", "payload")
+		fake.Get(ctx, "https://kiro/b")
+
+		requests := fake.Requests()
+		assert.Len(t, requests, 2)
+		assert.Equal(t, Request{Method: "POST", URL: "https://kiro/a", Payload: "payload"}, requests[0])
+		assert.Equal(t, "GET", requests[1].Method)
+	})
+
+	t.Run("lists models", func(t *testing.T) {
+		fake := NewFake()
+		fake.Models = []model.Info{{ModelID: "claude-sonnet-4"}}
+
+		models, err := fake.ListModels(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, models, 1)
+
+		fake.ModelsErr = errors.New("unauthorized")
+		_, err = fake.ListModels(ctx)
+		assert.Error(t, err)
+	})
+}
+
+// =============================================================================
+// TestLoadStream
+// =============================================================================
+
+func TestLoadStream(t *testing.T) {
+	t.Run("reads a recorded stream", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "kiro_stream.bin")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"content":"recorded"}`), 0600))
+
+		resp, err := LoadStream(path)
+
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `{"content":"recorded"}`, resp.Body)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := LoadStream(filepath.Join(t.TempDir(), "missing.bin"))
+		assert.Error(t, err)
+	})
+}
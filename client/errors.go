@@ -0,0 +1,217 @@
+// Package client provides HTTP client with retry logic for Kiro API.
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"kiro-go-proxy/redact"
+)
+
+// maxErrorBodyBytes bounds how much of an error response is read
+const maxErrorBodyBytes = 64 * 1024
+
+// ErrorKind classifies a Kiro error response
+type ErrorKind int
+
+const (
+	ErrorUnknown ErrorKind = iota
+	ErrorInvalidRequest
+	ErrorContextTooLong
+	ErrorContentFiltered
+	ErrorModelNotFound
+	ErrorThrottled
+	ErrorQuotaExceeded
+	ErrorAuth
+	ErrorUnavailable
+)
+
+// UpstreamError is an error response from the Kiro API, classified so handlers
+// can answer with a meaningful status and error type instead of the raw body
+type UpstreamError struct {
+	// StatusCode is the status Kiro returned
+	StatusCode int
+	Kind       ErrorKind
+	// Type is the AWS exception name, e.g. ThrottlingException
+	Type string
+	// Reason is Kiro's machine-readable reason, e.g. CONTENT_LENGTH_EXCEEDS_THRESHOLD
+	Reason  string
+	Message string
+}
+
+func (e *UpstreamError) Error() string {
+	detail := e.Type
+	if e.Reason != "" {
+		detail = e.Reason
+	}
+	if detail == "" {
+		return fmt.Sprintf("Kiro API returned %d: %s", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("Kiro API returned %d (%s): %s", e.StatusCode, detail, e.Message)
+}
+
+// Status returns the HTTP status to answer the client with. Kiro rejecting the
+// proxy's own credentials is a 502: the client's API key is fine.
+func (e *UpstreamError) Status() int {
+	switch e.Kind {
+	case ErrorInvalidRequest, ErrorContextTooLong, ErrorContentFiltered:
+		return http.StatusBadRequest
+	case ErrorModelNotFound:
+		return http.StatusNotFound
+	case ErrorThrottled, ErrorQuotaExceeded:
+		return http.StatusTooManyRequests
+	case ErrorAuth:
+		return http.StatusBadGateway
+	}
+	if e.StatusCode >= 400 {
+		return e.StatusCode
+	}
+	return http.StatusBadGateway
+}
+
+// ErrorType returns the OpenAI error type for the error
+func (e *UpstreamError) ErrorType() string {
+	switch e.Kind {
+	case ErrorInvalidRequest, ErrorContextTooLong, ErrorContentFiltered, ErrorModelNotFound:
+		return "invalid_request_error"
+	case ErrorThrottled:
+		return "rate_limit_error"
+	case ErrorQuotaExceeded:
+		return "insufficient_quota"
+	}
+	return "api_error"
+}
+
+// Code returns the OpenAI error code for the error, or "" when there is none
+func (e *UpstreamError) Code() string {
+	switch e.Kind {
+	case ErrorContextTooLong:
+		return "context_length_exceeded"
+	case ErrorContentFiltered:
+		return "content_policy_violation"
+	case ErrorModelNotFound:
+		return "model_not_found"
+	case ErrorThrottled:
+		return "rate_limit_exceeded"
+	case ErrorQuotaExceeded:
+		return "insufficient_quota"
+	}
+	return ""
+}
+
+// ReadUpstreamError reads and closes the body of a non-200 Kiro response and
+// classifies it
+func ReadUpstreamError(resp *http.Response) *UpstreamError {
+	return ParseUpstreamError(resp.StatusCode, resp.Header.Get("x-amzn-ErrorType"), readErrorBody(resp))
+}
+
+// readErrorBody reads and closes the body of an error response
+func readErrorBody(resp *http.Response) []byte {
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes))
+	return body
+}
+
+// ParseUpstreamError classifies a Kiro error response. Kiro answers with
+// {"message", "reason"} or the AWS {"__type", "message"} shape; the exception
+// name may also come in the x-amzn-ErrorType header. Bodies that are not JSON
+// become the message as they are.
+func ParseUpstreamError(status int, errorTypeHeader string, body []byte) *UpstreamError {
+	var parsed struct {
+		Type         string `json:"__type"`
+		Message      string `json:"message"`
+		MessageUpper string `json:"Message"`
+		Reason       string `json:"reason"`
+	}
+	e := &UpstreamError{StatusCode: status}
+	if json.Unmarshal(body, &parsed) == nil {
+		e.Type = parsed.Type
+		e.Message = parsed.Message
+		if e.Message == "" {
+			e.Message = parsed.MessageUpper
+		}
+		e.Reason = parsed.Reason
+	}
+	if e.Message == "" {
+		e.Message = strings.TrimSpace(string(body))
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(status)
+	}
+	e.Message = redact.Message(e.Message)
+	if e.Type == "" {
+		e.Type = errorTypeHeader
+	}
+	// "com.amazon.aws.codewhisperer#ThrottlingException" and
+	// "ThrottlingException:http://internal.amazon.com/..." both name the exception
+	if i := strings.LastIndex(e.Type, "#"); i >= 0 {
+		e.Type = e.Type[i+1:]
+	}
+	if i := strings.Index(e.Type, ":"); i >= 0 {
+		e.Type = e.Type[:i]
+	}
+
+	e.Kind = classifyUpstreamError(e)
+	return e
+}
+
+// classifyUpstreamError picks the kind from the reason and exception name first,
+// then from the message, then from the status
+func classifyUpstreamError(e *UpstreamError) ErrorKind {
+	reason := strings.ToUpper(e.Reason)
+	message := strings.ToLower(e.Message)
+
+	switch {
+	case reason == "CONTENT_LENGTH_EXCEEDS_THRESHOLD",
+		strings.Contains(message, "input is too long"),
+		strings.Contains(message, "context length"):
+		return ErrorContextTooLong
+	case reason == "MONTHLY_REQUEST_COUNT",
+		e.Type == "ServiceQuotaExceededException",
+		strings.Contains(message, "quota"):
+		return ErrorQuotaExceeded
+	case e.Type == "ThrottlingException",
+		strings.Contains(message, "too many requests"),
+		strings.Contains(message, "rate exceeded"):
+		return ErrorThrottled
+	case strings.Contains(reason, "CONTENT_FILTER"),
+		strings.Contains(reason, "GUARDRAIL"),
+		strings.Contains(message, "content filter"),
+		strings.Contains(message, "content policy"):
+		return ErrorContentFiltered
+	case reason == "INVALID_MODEL_ID",
+		strings.Contains(message, "invalid model"),
+		strings.Contains(message, "model not found"),
+		strings.Contains(message, "model is not supported"):
+		return ErrorModelNotFound
+	case e.Type == "ExpiredTokenException",
+		e.Type == "UnrecognizedClientException",
+		isTokenMessage(message) && (strings.Contains(message, "expired") || strings.Contains(message, "invalid")):
+		return ErrorAuth
+	}
+
+	switch {
+	case e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden:
+		return ErrorAuth
+	case e.StatusCode == http.StatusTooManyRequests:
+		return ErrorThrottled
+	case e.StatusCode >= 500 || e.Type == "InternalServerException" || e.Type == "ServiceUnavailableException":
+		return ErrorUnavailable
+	case e.StatusCode == http.StatusBadRequest || e.Type == "ValidationException":
+		return ErrorInvalidRequest
+	}
+	return ErrorUnknown
+}
+
+// isTokenMessage reports whether a lowercased message is about the access token
+func isTokenMessage(message string) bool {
+	for _, name := range []string{"bearer token", "security token", "access token"} {
+		if strings.Contains(message, name) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+// Package client provides tests for Kiro error translation.
+package client
+
+import (
+	"net/http"
+	"testing"
+
+	"kiro-go-proxy/redact"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestParseUpstreamError
+// =============================================================================
+
+func TestParseUpstreamError(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		header  string
+		body    string
+		kind    ErrorKind
+		want    int
+		errType string
+		code    string
+	}{
+		{
+			name:    "context too long",
+			status:  http.StatusBadRequest,
+			body:    `{"message":"Input is too long for requested model.","reason":"CONTENT_LENGTH_EXCEEDS_THRESHOLD"}`,
+			kind:    ErrorContextTooLong,
+			want:    http.StatusBadRequest,
+			errType: "invalid_request_error",
+			code:    "context_length_exceeded",
+		},
+		{
+			name:    "throttling from AWS type",
+			status:  http.StatusBadRequest,
+			body:    `{"__type":"com.amazon.aws.codewhisperer#ThrottlingException","message":"Rate exceeded"}`,
+			kind:    ErrorThrottled,
+			want:    http.StatusTooManyRequests,
+			errType: "rate_limit_error",
+			code:    "rate_limit_exceeded",
+		},
+		{
+			name:    "throttling from header",
+			status:  http.StatusBadRequest,
+			header:  "ThrottlingException:http://internal.amazon.com/coral/",
+			body:    `slow down`,
+			kind:    ErrorThrottled,
+			want:    http.StatusTooManyRequests,
+			errType: "rate_limit_error",
+			code:    "rate_limit_exceeded",
+		},
+		{
+			name:    "monthly quota",
+			status:  http.StatusBadRequest,
+			body:    `{"message":"You have reached the limit.","reason":"MONTHLY_REQUEST_COUNT"}`,
+			kind:    ErrorQuotaExceeded,
+			want:    http.StatusTooManyRequests,
+			errType: "insufficient_quota",
+			code:    "insufficient_quota",
+		},
+		{
+			name:    "content filter",
+			status:  http.StatusBadRequest,
+			body:    `{"message":"Blocked","reason":"CONTENT_FILTERED"}`,
+			kind:    ErrorContentFiltered,
+			want:    http.StatusBadRequest,
+			errType: "invalid_request_error",
+			code:    "content_policy_violation",
+		},
+		{
+			name:    "model not found",
+			status:  http.StatusBadRequest,
+			body:    `{"message":"Invalid model. Please select a different model to continue.","reason":"INVALID_MODEL_ID"}`,
+			kind:    ErrorModelNotFound,
+			want:    http.StatusNotFound,
+			errType: "invalid_request_error",
+			code:    "model_not_found",
+		},
+		{
+			name:    "expired token",
+			status:  http.StatusForbidden,
+			body:    `{"message":"The bearer token included in the request is invalid."}`,
+			kind:    ErrorAuth,
+			want:    http.StatusBadGateway,
+			errType: "api_error",
+		},
+		{
+			name:    "expired token with other status",
+			status:  http.StatusBadRequest,
+			body:    `{"__type":"ExpiredTokenException","message":"The security token included in the request is expired"}`,
+			kind:    ErrorAuth,
+			want:    http.StatusBadGateway,
+			errType: "api_error",
+		},
+		{
+			name:    "server error",
+			status:  http.StatusInternalServerError,
+			body:    `internal failure`,
+			kind:    ErrorUnavailable,
+			want:    http.StatusInternalServerError,
+			errType: "api_error",
+		},
+		{
+			name:    "plain bad request",
+			status:  http.StatusBadRequest,
+			body:    `{"message":"Improperly formed request."}`,
+			kind:    ErrorInvalidRequest,
+			want:    http.StatusBadRequest,
+			errType: "invalid_request_error",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := ParseUpstreamError(tt.status, tt.header, []byte(tt.body))
+			assert.Equal(t, tt.kind, e.Kind)
+			assert.Equal(t, tt.want, e.Status())
+			assert.Equal(t, tt.errType, e.ErrorType())
+			assert.Equal(t, tt.code, e.Code())
+		})
+	}
+
+	t.Run("keeps Kiro's message", func(t *testing.T) {
+		e := ParseUpstreamError(http.StatusBadRequest, "", []byte(`{"__type":"ValidationException","Message":"bad input"}`))
+		assert.Equal(t, "ValidationException", e.Type)
+		assert.Equal(t, "bad input", e.Message)
+		assert.Contains(t, e.Error(), "ValidationException")
+	})
+
+	t.Run("non-JSON body becomes the message", func(t *testing.T) {
+		e := ParseUpstreamError(http.StatusBadGateway, "", []byte(" upstream down \n"))
+		assert.Equal(t, "upstream down", e.Message)
+	})
+
+	t.Run("empty body falls back to the status text", func(t *testing.T) {
+		e := ParseUpstreamError(http.StatusServiceUnavailable, "", nil)
+		assert.Equal(t, "Service Unavailable", e.Message)
+	})
+
+	t.Run("masks credentials with PARANOID_LOGGING", func(t *testing.T) {
+		redact.SetParanoid(true)
+		defer redact.SetParanoid(false)
+
+		e := ParseUpstreamError(http.StatusForbidden, "", []byte(`{"message":"Invalid token: Bearer aoaAAAAAGabcdefghijklmnopqrstuvwxyz"}`))
+		assert.Equal(t, "Invalid token: Bearer [REDACTED]", e.Message)
+	})
+}
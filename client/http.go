@@ -3,17 +3,20 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
 	"kiro-go-proxy/auth"
 	"kiro-go-proxy/config"
+	"kiro-go-proxy/debug"
+	"kiro-go-proxy/tracing"
+	"kiro-go-proxy/transport"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -22,108 +25,182 @@ import (
 type Client struct {
 	httpClient     *http.Client
 	cfg            *config.Config
-	authManager    *auth.Manager
-	proxyURL       string
+	pool           *auth.Pool
+	breaker        *Breaker
 }
 
-// NewClient creates a new HTTP client
-func NewClient(cfg *config.Config, authManager *auth.Manager) *Client {
-	// Configure transport
-	transport := &http.Transport{
-		MaxIdleConns:        100,
-		MaxIdleConnsPerHost: 20,
-		IdleConnTimeout:     30 * time.Second,
-	}
-
-	// Configure proxy if set
-	proxyURL := cfg.VPNProxyURL
-	if proxyURL != "" {
-		if !strings.Contains(proxyURL, "://") {
-			proxyURL = "http://" + proxyURL
-		}
-		if proxy, err := url.Parse(proxyURL); err == nil {
-			transport.Proxy = http.ProxyURL(proxy)
-			log.Infof("Proxy configured: %s", proxyURL)
-		}
-	}
+// profileArnSetter is implemented by payloads that embed the account's profile ARN
+type profileArnSetter interface {
+	SetProfileArn(profileArn string)
+}
 
+// NewClient creates a new HTTP client that rotates requests across the credential pool
+func NewClient(cfg *config.Config, pool *auth.Pool) *Client {
 	return &Client{
-		httpClient: &http.Client{
-			Transport: transport,
-			Timeout:   time.Duration(cfg.StreamingReadTimeout) * time.Second,
-		},
-		cfg:         cfg,
-		authManager: authManager,
-		proxyURL:    proxyURL,
+		httpClient: transport.NewClient(cfg, time.Duration(cfg.StreamingReadTimeout)*time.Second),
+		cfg:        cfg,
+		pool:       pool,
+		breaker:    NewBreaker(cfg),
 	}
 }
 
-// RequestWithRetry makes an HTTP request with retry logic
+// RequestWithRetry makes an HTTP request with retry logic.
+// Connection errors, 408/429 and 5xx responses are retried with exponential backoff and
+// jitter (honoring Retry-After); 401/403 force a token refresh before retrying.
+// Failing over to a different account is immediate. While the circuit breaker is
+// open requests fail with *CircuitOpenError without contacting Kiro.
 func (c *Client) RequestWithRetry(ctx context.Context, method, url string, payload interface{}, stream bool) (*http.Response, error) {
 	var lastErr error
+	var lastAccount *auth.Account
+	var delay time.Duration
 
 	for attempt := 0; attempt < c.cfg.MaxRetries; attempt++ {
-		if attempt > 0 {
-			delay := time.Duration(c.cfg.BaseRetryDelay*float64(int(1)<<uint(attempt))) * time.Second
-			log.Warnf("Retry attempt %d/%d after %v", attempt+1, c.cfg.MaxRetries, delay)
-			time.Sleep(delay)
+		account := c.pool.Next()
+		if account == nil {
+			return nil, fmt.Errorf("no Kiro accounts configured")
+		}
+
+		// Only wait when retrying on the same account; failover is immediate
+		if attempt > 0 && account == lastAccount {
+			if delay > 0 {
+				log.Warnf("Retry attempt %d/%d after %v", attempt+1, c.cfg.MaxRetries, delay)
+				select {
+				case <-time.After(delay):
+				case <-ctx.Done():
+					return nil, context.Cause(ctx)
+				}
+			} else {
+				log.Warnf("Retry attempt %d/%d", attempt+1, c.cfg.MaxRetries)
+			}
+		} else if attempt > 0 {
+			log.Warnf("Retry attempt %d/%d failing over to account '%s'", attempt+1, c.cfg.MaxRetries, account.Name)
 		}
+		lastAccount = account
+		delay = backoffDelay(c.cfg.BaseRetryDelay, attempt+1)
 
-		resp, err := c.doRequest(ctx, method, url, payload, stream)
+		done, err := c.breaker.allow()
 		if err != nil {
-			lastErr = err
-			continue
+			log.Warnf("Request to Kiro rejected: %v", err)
+			debug.FromContext(ctx).MarkError(err)
+			return nil, err
 		}
 
-		// Check for retryable status codes
-		if resp.StatusCode == http.StatusForbidden {
-			log.Info("Received 403, attempting token refresh...")
-			if _, refreshErr := c.authManager.ForceRefresh(); refreshErr != nil {
-				log.Errorf("Token refresh failed: %v", refreshErr)
+		attemptCtx, span := tracing.StartSpan(ctx, "kiro.request", tracing.SpanKindClient)
+		span.SetAttribute("http.method", method)
+		span.SetAttribute("http.url", url)
+		span.SetAttribute("kiro.account", account.Name)
+		span.SetAttribute("kiro.attempt", attempt+1)
+		// Requests rejected for the same expired token share one refresh
+		generation := account.Manager.RefreshGeneration()
+		resp, err := c.doRequest(attemptCtx, account.Manager, method, c.accountURL(url, account.Manager), payload, stream)
+		done(breakerResult(ctx, resp, err))
+		if err != nil {
+			span.RecordError(err)
+		} else {
+			span.SetAttribute("http.status_code", resp.StatusCode)
+			if resp.StatusCode != http.StatusOK {
+				span.RecordError(fmt.Errorf("received %d from Kiro API", resp.StatusCode))
 			}
-			resp.Body.Close()
-			continue
 		}
-
-		if resp.StatusCode == http.StatusTooManyRequests {
-			log.Warn("Rate limited (429), waiting before retry...")
-			resp.Body.Close()
+		span.End()
+		if err != nil {
+			// Client went away; not the account's fault and not worth retrying
+			if ctx.Err() != nil {
+				return nil, context.Cause(ctx)
+			}
+			if !isRetryableError(err) {
+				debug.FromContext(ctx).MarkError(err)
+				return nil, err
+			}
+			lastErr = err
+			log.Warnf("Request to Kiro failed: %v", err)
+			c.pool.MarkFailure(account, err.Error())
 			continue
 		}
 
-		if resp.StatusCode >= 500 {
-			log.Warnf("Server error (%d), retrying...", resp.StatusCode)
-			resp.Body.Close()
-			continue
+		if resp.StatusCode != http.StatusOK {
+			body := readErrorBody(resp)
+			upstream := ParseUpstreamError(resp.StatusCode, resp.Header.Get("x-amzn-ErrorType"), body)
+
+			// Kiro reports some expired tokens with other statuses; the body says so
+			if isAuthStatus(resp.StatusCode) || upstream.Kind == ErrorAuth {
+				log.Infof("Received %d, attempting token refresh...", resp.StatusCode)
+				if _, refreshErr := account.Manager.RefreshSince(generation); refreshErr != nil {
+					log.Errorf("Token refresh failed: %v", refreshErr)
+					c.pool.MarkFailure(account, fmt.Sprintf("%d unauthorized", resp.StatusCode))
+				} else {
+					// Fresh token: retry right away
+					delay = 0
+				}
+				lastErr = upstream
+				continue
+			}
+
+			if isRetryableStatus(resp.StatusCode) {
+				if retryAfter := parseRetryAfter(resp.Header.Get("Retry-After")); retryAfter > delay {
+					delay = retryAfter
+				}
+				if resp.StatusCode == http.StatusTooManyRequests {
+					log.Warn("Rate limited (429), waiting before retry...")
+					c.pool.MarkFailure(account, "429 rate limited")
+				} else {
+					log.Warnf("Server error (%d), retrying...", resp.StatusCode)
+				}
+				lastErr = upstream
+				continue
+			}
+
+			// Not retryable: the caller reads the error from the body
+			resp.Body = io.NopCloser(bytes.NewReader(body))
 		}
 
+		c.pool.MarkSuccess(account)
+		resp.Body = debug.FromContext(ctx).WrapBody(debug.KiroStreamFile, resp.Body)
 		return resp, nil
 	}
 
-	return nil, fmt.Errorf("all %d retry attempts failed: %w", c.cfg.MaxRetries, lastErr)
+	err := fmt.Errorf("all %d retry attempts failed: %w", c.cfg.MaxRetries, lastErr)
+	debug.FromContext(ctx).MarkError(err)
+	return nil, err
+}
+
+// accountURL rewrites url to target the account's API host when it differs from the primary
+func (c *Client) accountURL(url string, manager *auth.Manager) string {
+	primary := c.pool.Primary()
+	if primary == nil || primary == manager || primary.APIHost() == manager.APIHost() {
+		return url
+	}
+	if strings.HasPrefix(url, primary.APIHost()) {
+		return manager.APIHost() + strings.TrimPrefix(url, primary.APIHost())
+	}
+	return url
 }
 
-func (c *Client) doRequest(ctx context.Context, method, url string, payload interface{}, stream bool) (*http.Response, error) {
+func (c *Client) doRequest(ctx context.Context, authManager *auth.Manager, method, url string, payload interface{}, stream bool) (*http.Response, error) {
 	// Get access token
-	token, err := c.authManager.GetAccessToken()
+	token, err := authManager.GetAccessToken()
 	if err != nil {
 		return nil, fmt.Errorf("failed to get access token: %w", err)
 	}
 
+	// Embed the account's profile ARN in the payload
+	if setter, ok := payload.(profileArnSetter); ok && authManager.ProfileArn() != "" {
+		setter.SetProfileArn(authManager.ProfileArn())
+	}
+
 	// Prepare request body
 	var body io.Reader
 	if payload != nil {
-		jsonData, err := json.Marshal(payload)
+		body, err = c.requestBody(ctx, payload, stream)
 		if err != nil {
-			return nil, fmt.Errorf("failed to marshal payload: %w", err)
+			return nil, err
 		}
-		body = bytes.NewReader(jsonData)
 	}
 
 	// Create request
 	req, err := http.NewRequestWithContext(ctx, method, url, body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, &permanentError{fmt.Errorf("failed to create request: %w", err)}
 	}
 
 	// Set headers
@@ -131,18 +208,26 @@ func (c *Client) doRequest(ctx context.Context, method, url string, payload inte
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("Accept", "application/json, text/event-stream")
 	req.Header.Set("User-Agent", fmt.Sprintf("KiroGateway-Go/%s", config.AppVersion))
+	tracing.Inject(ctx, req.Header)
+	if payload != nil && c.cfg.HTTPRequestCompression == "gzip" {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 
 	if stream {
 		req.Header.Set("Accept", "text/event-stream")
 	}
 
 	// Add profile ARN if available
-	if c.authManager.ProfileArn() != "" {
-		req.Header.Set("X-Amz-Profile-Arn", c.authManager.ProfileArn())
+	if authManager.ProfileArn() != "" {
+		req.Header.Set("X-Amz-Profile-Arn", authManager.ProfileArn())
 	}
 
 	// Execute request
 	resp, err := c.httpClient.Do(req)
+	if streamed, ok := body.(*streamedBody); ok && (err != nil || resp.StatusCode != http.StatusOK) {
+		// A retry may set another profile ARN in the payload being encoded
+		streamed.abort()
+	}
 	if err != nil {
 		return nil, fmt.Errorf("request failed: %w", err)
 	}
@@ -150,9 +235,88 @@ func (c *Client) doRequest(ctx context.Context, method, url string, payload inte
 	return resp, nil
 }
 
-// DoRequest performs a simple HTTP request without retry logic
+// requestBody encodes payload as the request body, gzip-compressed with
+// HTTP_REQUEST_COMPRESSION=gzip. Streaming requests are encoded into a pipe while
+// they are sent when HTTP_STREAM_REQUESTS is on, so a payload full of images is
+// never held in memory a second time as JSON; the body is then sent chunked
+// without a Content-Length, which is why it is off by default. While debug capture is on the body is
+// marshalled up front so it can be captured.
+func (c *Client) requestBody(ctx context.Context, payload interface{}, stream bool) (io.Reader, error) {
+	compress := c.cfg.HTTPRequestCompression == "gzip"
+	capture := debug.FromContext(ctx)
+
+	if stream && c.cfg.HTTPStreamRequests && capture == nil {
+		return newStreamedBody(payload, compress), nil
+	}
+
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, &permanentError{fmt.Errorf("failed to marshal payload: %w", err)}
+	}
+	capture.Set(debug.KiroRequestFile, jsonData)
+	if !compress {
+		return bytes.NewReader(jsonData), nil
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(jsonData)
+	gz.Close()
+	return bytes.NewReader(buf.Bytes()), nil
+}
+
+// streamedBody is a request body encoded by a goroutine as it is read. Its
+// length is unknown, so it is sent chunked (HTTP/1.1) or as a stream of frames
+// (HTTP/2).
+type streamedBody struct {
+	*io.PipeReader
+	done chan struct{}
+}
+
+func newStreamedBody(payload interface{}, compress bool) *streamedBody {
+	pr, pw := io.Pipe()
+	body := &streamedBody{PipeReader: pr, done: make(chan struct{})}
+	go func() {
+		defer close(body.done)
+		var w io.Writer = pw
+		var gz *gzip.Writer
+		if compress {
+			gz = gzip.NewWriter(pw)
+			w = gz
+		}
+		err := json.NewEncoder(w).Encode(payload)
+		if err != nil {
+			err = &permanentError{fmt.Errorf("failed to marshal payload: %w", err)}
+		} else if gz != nil {
+			err = gz.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	return body
+}
+
+// abort stops the encoder and waits for it to exit
+func (b *streamedBody) abort() {
+	b.PipeReader.Close()
+	<-b.done
+}
+
+// DoRequest performs a simple HTTP request without retry logic using the primary account
 func (c *Client) DoRequest(ctx context.Context, method, url string, payload interface{}) (*http.Response, error) {
-	return c.doRequest(ctx, method, url, payload, false)
+	primary := c.pool.Primary()
+	if primary == nil {
+		return nil, fmt.Errorf("no Kiro accounts configured")
+	}
+	return c.doRequest(ctx, primary, method, url, payload, false)
+}
+
+// Breaker returns the circuit breaker guarding requests to Kiro
+func (c *Client) Breaker() *Breaker {
+	return c.breaker
+}
+
+// Pool returns the credential pool used by the client
+func (c *Client) Pool() *auth.Pool {
+	return c.pool
 }
 
 // Get performs a GET request
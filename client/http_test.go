@@ -0,0 +1,99 @@
+// Package client provides tests for request body encoding.
+package client
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/debug"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newBodyTestClient(compression string) *Client {
+	return &Client{cfg: &config.Config{HTTPStreamRequests: true, HTTPRequestCompression: compression}}
+}
+
+func readBody(t *testing.T, body io.Reader, compressed bool) string {
+	if compressed {
+		gz, err := gzip.NewReader(body)
+		assert.NoError(t, err)
+		body = gz
+	}
+	data, err := io.ReadAll(body)
+	assert.NoError(t, err)
+	return string(data)
+}
+
+// =============================================================================
+// TestRequestBody
+// =============================================================================
+
+func TestRequestBody(t *testing.T) {
+	payload := map[string]string{"content": "Hello"}
+
+	t.Run("streams streaming requests", func(t *testing.T) {
+		body, err := newBodyTestClient("none").requestBody(context.Background(), payload, true)
+
+		assert.NoError(t, err)
+		assert.IsType(t, &streamedBody{}, body)
+		assert.JSONEq(t, `{"content":"Hello"}`, readBody(t, body, false))
+	})
+
+	t.Run("compresses streamed bodies", func(t *testing.T) {
+		body, err := newBodyTestClient("gzip").requestBody(context.Background(), payload, true)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"content":"Hello"}`, readBody(t, body, true))
+	})
+
+	t.Run("buffers other requests", func(t *testing.T) {
+		body, err := newBodyTestClient("gzip").requestBody(context.Background(), payload, false)
+
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"content":"Hello"}`, readBody(t, body, true))
+	})
+
+	t.Run("buffers bodies while debug capture is on", func(t *testing.T) {
+		capture := debug.NewCapture(&config.Config{DebugMode: debug.ModeAll, DebugDir: t.TempDir()})
+		ctx := debug.WithCapture(context.Background(), capture)
+
+		body, err := newBodyTestClient("none").requestBody(ctx, payload, true)
+
+		assert.NoError(t, err)
+		_, streamed := body.(*streamedBody)
+		assert.False(t, streamed)
+	})
+
+	t.Run("can be disabled", func(t *testing.T) {
+		c := newBodyTestClient("none")
+		c.cfg.HTTPStreamRequests = false
+
+		body, _ := c.requestBody(context.Background(), payload, true)
+
+		_, streamed := body.(*streamedBody)
+		assert.False(t, streamed)
+	})
+
+	t.Run("reports encoding errors as permanent", func(t *testing.T) {
+		body, _ := newBodyTestClient("none").requestBody(context.Background(), map[string]interface{}{"bad": make(chan int)}, true)
+
+		_, err := io.ReadAll(body)
+
+		var permanent *permanentError
+		assert.True(t, errors.As(err, &permanent))
+	})
+
+	t.Run("abort stops the encoder", func(t *testing.T) {
+		body := newStreamedBody(payload, false)
+
+		body.abort()
+
+		_, err := body.Read(make([]byte, 1))
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+}
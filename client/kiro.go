@@ -0,0 +1,63 @@
+// Package client provides HTTP client with retry logic for Kiro API.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"kiro-go-proxy/model"
+)
+
+// modelListTimeout bounds a ListAvailableModels call
+const modelListTimeout = 30 * time.Second
+
+// KiroClient is the Kiro API client used by the HTTP handlers. *Client talks to
+// Kiro; clienttest.Fake replays recorded streams in tests.
+type KiroClient interface {
+	// PostStream sends a request and returns the streaming response
+	PostStream(ctx context.Context, url string, payload interface{}) (*http.Response, error)
+	// Get sends a GET request
+	Get(ctx context.Context, url string) (*http.Response, error)
+	// ListModels fetches the models available to the primary account
+	ListModels(ctx context.Context) ([]model.Info, error)
+}
+
+var _ KiroClient = (*Client)(nil)
+
+// ListModels fetches the model list from the Kiro API using the primary account
+func (c *Client) ListModels(ctx context.Context) ([]model.Info, error) {
+	primary := c.pool.Primary()
+	if primary == nil {
+		return nil, fmt.Errorf("no Kiro accounts configured")
+	}
+
+	url := fmt.Sprintf("%s/ListAvailableModels?origin=AI_EDITOR", primary.QHost())
+	if primary.ProfileArn() != "" {
+		url += "&profileArn=" + primary.ProfileArn()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, modelListTimeout)
+	defer cancel()
+
+	resp, err := c.DoRequest(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Models []model.Info `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to parse model list: %w", err)
+	}
+
+	return result.Models, nil
+}
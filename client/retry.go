@@ -0,0 +1,107 @@
+// Package client provides HTTP client with retry logic for Kiro API.
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryDelay caps backoff and Retry-After waits between attempts
+const maxRetryDelay = 30 * time.Second
+
+// permanentError marks request errors that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// isRetryableError reports whether a request error is worth retrying.
+// Connection resets, timeouts and token refresh failures are transient; malformed
+// requests and cancelled requests are not.
+func isRetryableError(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var permanent *permanentError
+	if errors.As(err, &permanent) {
+		return false
+	}
+
+	return !errors.Is(err, context.Canceled)
+}
+
+// isRetryableStatus reports whether an upstream status code is transient
+func isRetryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// isAuthStatus reports whether an upstream status code indicates a stale or invalid token
+func isAuthStatus(code int) bool {
+	return code == http.StatusUnauthorized || code == http.StatusForbidden
+}
+
+// breakerResult classifies an attempt for the circuit breaker. Connection errors,
+// timeouts and 5xx responses count against Kiro; cancelled requests and
+// malformed payloads say nothing about its health.
+func breakerResult(ctx context.Context, resp *http.Response, err error) callResult {
+	if err != nil {
+		if ctx.Err() != nil || !isRetryableError(err) {
+			return callAbandoned
+		}
+		return callFailed
+	}
+	if resp.StatusCode == http.StatusRequestTimeout || resp.StatusCode >= 500 {
+		return callFailed
+	}
+	return callSucceeded
+}
+
+// backoffDelay returns the exponential backoff with jitter before the given retry attempt (1-based)
+func backoffDelay(baseDelay float64, attempt int) time.Duration {
+	if baseDelay <= 0 {
+		return 0
+	}
+	if attempt < 1 {
+		attempt = 1
+	}
+
+	// Computed in seconds so a large attempt cannot overflow the duration
+	delay := maxRetryDelay
+	if seconds := baseDelay * math.Pow(2, float64(attempt-1)); seconds < maxRetryDelay.Seconds() {
+		delay = time.Duration(seconds * float64(time.Second))
+	}
+
+	// Equal jitter: half fixed, half random, so concurrent clients spread out
+	half := delay / 2
+	return half + time.Duration(rand.Int63n(int64(half)+1))
+}
+
+// parseRetryAfter parses a Retry-After header given as seconds or an HTTP date
+func parseRetryAfter(header string) time.Duration {
+	if header == "" {
+		return 0
+	}
+
+	var delay time.Duration
+	if seconds, err := strconv.Atoi(header); err == nil {
+		delay = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(header); err == nil {
+		delay = time.Until(t)
+	}
+
+	if delay < 0 {
+		return 0
+	}
+	if delay > maxRetryDelay {
+		return maxRetryDelay
+	}
+	return delay
+}
@@ -0,0 +1,114 @@
+// Package client provides tests for retry classification and backoff.
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestIsRetryableError
+// =============================================================================
+
+func TestIsRetryableError(t *testing.T) {
+	t.Run("retries connection errors", func(t *testing.T) {
+		assert.True(t, isRetryableError(fmt.Errorf("request failed: %w", syscall.ECONNRESET)))
+		assert.True(t, isRetryableError(fmt.Errorf("request failed: %w", io.ErrUnexpectedEOF)))
+	})
+
+	t.Run("does not retry permanent errors", func(t *testing.T) {
+		assert.False(t, isRetryableError(&permanentError{errors.New("failed to marshal payload")}))
+	})
+
+	t.Run("does not retry cancelled requests", func(t *testing.T) {
+		assert.False(t, isRetryableError(fmt.Errorf("request failed: %w", context.Canceled)))
+	})
+
+	t.Run("nil is not retryable", func(t *testing.T) {
+		assert.False(t, isRetryableError(nil))
+	})
+}
+
+// =============================================================================
+// TestStatusClassification
+// =============================================================================
+
+func TestStatusClassification(t *testing.T) {
+	t.Run("retryable statuses", func(t *testing.T) {
+		for _, code := range []int{408, 429, 500, 502, 503} {
+			assert.True(t, isRetryableStatus(code), code)
+		}
+		for _, code := range []int{200, 400, 401, 403, 404} {
+			assert.False(t, isRetryableStatus(code), code)
+		}
+	})
+
+	t.Run("auth statuses", func(t *testing.T) {
+		assert.True(t, isAuthStatus(http.StatusUnauthorized))
+		assert.True(t, isAuthStatus(http.StatusForbidden))
+		assert.False(t, isAuthStatus(http.StatusTooManyRequests))
+	})
+}
+
+// =============================================================================
+// TestBackoffDelay
+// =============================================================================
+
+func TestBackoffDelay(t *testing.T) {
+	t.Run("grows exponentially with jitter", func(t *testing.T) {
+		for attempt := 1; attempt <= 3; attempt++ {
+			full := time.Duration(1<<uint(attempt-1)) * time.Second
+			delay := backoffDelay(1.0, attempt)
+
+			assert.GreaterOrEqual(t, delay, full/2)
+			assert.LessOrEqual(t, delay, full)
+		}
+	})
+
+	t.Run("caps at max delay", func(t *testing.T) {
+		assert.LessOrEqual(t, backoffDelay(1.0, 20), maxRetryDelay)
+		assert.GreaterOrEqual(t, backoffDelay(1.0, 200), maxRetryDelay/2)
+	})
+
+	t.Run("zero base delay retries at once", func(t *testing.T) {
+		for attempt := 1; attempt <= 100; attempt += 33 {
+			assert.Equal(t, time.Duration(0), backoffDelay(0, attempt))
+		}
+	})
+}
+
+// =============================================================================
+// TestParseRetryAfter
+// =============================================================================
+
+func TestParseRetryAfter(t *testing.T) {
+	t.Run("parses seconds", func(t *testing.T) {
+		assert.Equal(t, 5*time.Second, parseRetryAfter("5"))
+	})
+
+	t.Run("parses HTTP date", func(t *testing.T) {
+		date := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+		delay := parseRetryAfter(date)
+
+		assert.Greater(t, delay, 8*time.Second)
+		assert.LessOrEqual(t, delay, 10*time.Second)
+	})
+
+	t.Run("caps long waits", func(t *testing.T) {
+		assert.Equal(t, maxRetryDelay, parseRetryAfter("3600"))
+	})
+
+	t.Run("ignores missing or invalid values", func(t *testing.T) {
+		assert.Equal(t, time.Duration(0), parseRetryAfter(""))
+		assert.Equal(t, time.Duration(0), parseRetryAfter("soon"))
+		assert.Equal(t, time.Duration(0), parseRetryAfter("-3"))
+	})
+}
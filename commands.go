@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"kiro-go-proxy/api"
+	"kiro-go-proxy/auth"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/stream"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// loadCommandConfig loads the configuration for a subcommand other than serve.
+// Informational logs are silenced unless LOG_LEVEL=DEBUG so they do not mix with
+// the command's output.
+func loadCommandConfig(configFile string) *config.Config {
+	cfg, err := config.LoadFile(configFile)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	setupLogging(cfg.LogLevel, cfg.LogFormat, cfg.ParanoidLogging)
+	if cfg.LogLevel != "DEBUG" && cfg.LogLevel != "ERROR" {
+		log.SetLevel(log.WarnLevel)
+	}
+	return cfg
+}
+
+// newCommandServer creates the API server a subcommand inspects, exiting when no
+// credentials are configured
+func newCommandServer(cfg *config.Config) *api.Server {
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
+	return api.NewServerWithPool(cfg, auth.NewPool(cfg))
+}
+
+// runModels implements the "models" subcommand
+func runModels(args []string) {
+	fs := flag.NewFlagSet("models", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to a YAML/JSON config file (overrides CONFIG_FILE)")
+	asJSON := fs.Bool("json", false, "Print the /v1/models response as JSON")
+	fs.Parse(args)
+
+	cfg := loadCommandConfig(*configFile)
+	server := newCommandServer(cfg)
+	if _, _, _, err := server.ModelRefresher.Refresh(); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to fetch models from Kiro API, showing fallback list: %v\n", err)
+	}
+
+	models := server.ModelResolver.GetAvailableModelDetails()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(stream.CreateOpenAIModelsResponse(models))
+		return
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MODEL\tKIRO ID\tSOURCE\tCONTEXT\tMAX OUTPUT\tVISION\tTOOLS")
+	for _, m := range models {
+		resolution := server.ModelResolver.Resolve(m.ID)
+		fmt.Fprintf(w, "%s\t%s\t%s\t%d\t%d\t%t\t%t\n",
+			m.ID, resolution.InternalID, resolution.Source, m.ContextWindow, m.MaxOutputTokens, m.SupportsVision, m.SupportsTools)
+	}
+	w.Flush()
+}
+
+// runToken implements the "token" subcommand
+func runToken(args []string) {
+	fs := flag.NewFlagSet("token", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to a YAML/JSON config file (overrides CONFIG_FILE)")
+	refresh := fs.Bool("refresh", false, "Refresh every account's access token now (rotated tokens are saved)")
+	fs.Parse(args)
+
+	cfg := loadCommandConfig(*configFile)
+	pool := newCommandServer(cfg).CredentialPool
+
+	failed := false
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ACCOUNT\tAUTH\tREGION\tEXPIRES\tREMAINING")
+	statuses := pool.Status()
+	for i, manager := range pool.Managers() {
+		if *refresh {
+			if _, err := manager.ForceRefresh(); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: refresh failed: %v\n", statuses[i].Name, err)
+				failed = true
+			}
+		}
+
+		expires, remaining := "-", "no access token"
+		if expiresAt := manager.ExpiresAt(); !expiresAt.IsZero() && manager.AccessToken() != "" {
+			expires = expiresAt.Local().Format("2006-01-02 15:04:05")
+			if left := time.Until(expiresAt); left > 0 {
+				remaining = left.Round(time.Second).String()
+			} else {
+				remaining = "expired"
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", statuses[i].Name, statuses[i].AuthType, statuses[i].Region, expires, remaining)
+	}
+	w.Flush()
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
+// runEncrypt implements the "encrypt" subcommand: it encrypts plaintext
+// credentials files in place. The gateway never does so on its own, since Kiro
+// Desktop and kiro-cli cannot read an encrypted file.
+func runEncrypt(args []string) {
+	fs := flag.NewFlagSet("encrypt", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to a YAML/JSON config file (overrides CONFIG_FILE)")
+	credsFile := fs.String("creds-file", "", "Credentials file to encrypt (default: KIRO_CREDS_FILE and KIRO_CREDS_FILES)")
+	fs.Parse(args)
+
+	cfg := loadCommandConfig(*configFile)
+	files := append([]string{cfg.KiroCredsFile}, cfg.KiroCredsFiles...)
+	if *credsFile != "" {
+		files = []string{*credsFile}
+	}
+
+	c, err := auth.NewCredsCipher(cfg)
+	if err != nil {
+		log.Fatalf("Credentials encryption unavailable: %v", err)
+	}
+	if c == nil {
+		log.Fatal("No encryption key. Set CREDS_ENCRYPTION_KEY or CREDS_ENCRYPTION_KEYRING")
+	}
+
+	failed, found := false, false
+	for _, file := range files {
+		if file == "" {
+			continue
+		}
+		found = true
+		migrated, err := auth.EncryptCredentialsFile(file, c)
+		switch {
+		case err != nil:
+			fmt.Fprintf(os.Stderr, "%s: %v\n", file, err)
+			failed = true
+		case migrated:
+			fmt.Printf("%s: encrypted\n", file)
+		default:
+			fmt.Printf("%s: already encrypted\n", file)
+		}
+	}
+	if !found {
+		log.Fatal("No credentials file. Pass --creds-file, or set KIRO_CREDS_FILE or KIRO_CREDS_FILES")
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
@@ -3,10 +3,19 @@
 package config
 
 import (
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path"
+	"regexp"
 	"strconv"
 	"strings"
+	"text/template"
+
+	"kiro-go-proxy/parser"
+	"kiro-go-proxy/piifilter"
 
 	"github.com/joho/godotenv"
 )
@@ -18,94 +27,450 @@ const (
 	AppDescription = "Proxy gateway for Kiro API (Amazon Q Developer / AWS CodeWhisperer). OpenAI and Anthropic compatible."
 )
 
+// RateLimit holds the request limits applied to one API key
+type RateLimit struct {
+	RPM        int `yaml:"rpm"`
+	Concurrent int `yaml:"concurrent"`
+}
+
+// PIIPolicy holds the outbound PII filter settings applied to one API key
+type PIIPolicy struct {
+	Mode     string   `yaml:"mode"`
+	Patterns []string `yaml:"patterns"`
+}
+
 // Config holds all configuration settings
 type Config struct {
 	// Server settings
-	ServerHost string
-	ServerPort int
+	ServerHost string `yaml:"server_host"`
+	ServerPort int    `yaml:"server_port"`
+
+	// Proxy settings. ProxyAPIKeys are further client keys; each key has its own
+	// usage, quotas and per-key settings (rate_limit_keys, audit_keys, ...).
+	ProxyAPIKey  string   `yaml:"proxy_api_key"`
+	ProxyAPIKeys []string `yaml:"proxy_api_keys"`
+	AdminAPIKey  string   `yaml:"admin_api_key"`
+	VPNProxyURL  string   `yaml:"vpn_proxy_url"`
+	VPNNoProxy   []string `yaml:"vpn_no_proxy"`
+
+	// Listener TLS (disabled unless a certificate or TLS_SELF_SIGNED is configured)
+	TLSCertFile     string `yaml:"tls_cert_file"`
+	TLSKeyFile      string `yaml:"tls_key_file"`
+	TLSSelfSigned   bool   `yaml:"tls_self_signed"`
+	TLSClientCAFile string `yaml:"tls_client_ca_file"`
+
+	// Per API key rate limits (0 disables)
+	RateLimitRPM        int                  `yaml:"rate_limit_rpm"`
+	RateLimitConcurrent int                  `yaml:"rate_limit_concurrent"`
+	RateLimitKeys       map[string]RateLimit `yaml:"rate_limit_keys"`
+
+	// Failed API key attempts per client IP: after AuthMaxFailures within
+	// AuthFailureWindow seconds the IP is locked out for AuthLockoutDuration
+	// seconds, valid key or not. Off by default (0 failures): behind a reverse proxy
+	// without TrustedProxies every client shares one IP.
+	AuthMaxFailures     int `yaml:"auth_max_failures"`
+	AuthFailureWindow   int `yaml:"auth_failure_window"`
+	AuthLockoutDuration int `yaml:"auth_lockout_duration"`
+
+	// Routes open without PROXY_API_KEY: paths as registered ("/v1/models/:id"),
+	// request paths, or prefixes ending in "*". HealthAuth also requires the key on
+	// / and /health for internet-exposed instances.
+	AuthExemptPaths []string `yaml:"auth_exempt_paths"`
+	HealthAuth      bool     `yaml:"health_auth"`
+
+	// Reverse proxies (IPs or CIDRs) whose X-Forwarded-For is trusted for the
+	// client IP. Empty uses the connection's address, so clients cannot spoof it.
+	TrustedProxies []string `yaml:"trusted_proxies"`
+
+	// /ws/chat: browser origins allowed to open a socket ("*" allows any; requests
+	// without an Origin header are always allowed) and the seconds a socket may sit
+	// idle before it is closed
+	WebSocketOrigins     []string `yaml:"websocket_origins"`
+	WebSocketIdleTimeout float64  `yaml:"websocket_idle_timeout"`
 
-	// Proxy settings
-	ProxyAPIKey string
-	VPNProxyURL string
+	// Usage accounting and per API key quotas (0 disables a quota)
+	UsageFile     string `yaml:"usage_file"`
+	QuotaRequests int    `yaml:"quota_requests"`
+	QuotaTokens   int    `yaml:"quota_tokens"`
+	QuotaCredits  int    `yaml:"quota_credits"`
 
 	// Kiro credentials
-	RefreshToken  string
-	ProfileArn    string
-	Region        string
-	KiroCredsFile string
-	KiroCLIDBFile string
+	RefreshToken  string `yaml:"refresh_token"`
+	ProfileArn    string `yaml:"profile_arn"`
+	Region        string `yaml:"kiro_region"`
+	KiroCredsFile string `yaml:"kiro_creds_file"`
+	KiroCLIDBFile string `yaml:"kiro_cli_db_file"`
+	// Profile in ~/.aws/config whose `aws sso login` session token is read from
+	// ~/.aws/sso/cache
+	AWSSSOProfile string `yaml:"aws_sso_profile"`
+
+	// Additional accounts for the credential pool
+	RefreshTokens   []string `yaml:"refresh_tokens"`
+	KiroCredsFiles  []string `yaml:"kiro_creds_files"`
+	KiroCLIDBFiles  []string `yaml:"kiro_cli_db_files"`
+	AccountCooldown int      `yaml:"account_cooldown"`
+
+	// Encryption of KIRO_CREDS_FILE(S) at rest: a passphrase, or a key kept in the
+	// OS keychain. Plaintext files are encrypted the first time they are loaded.
+	CredsEncryptionKey     string `yaml:"creds_encryption_key"`
+	CredsEncryptionKeyring bool   `yaml:"creds_encryption_keyring"`
 
 	// Token settings
-	TokenRefreshThreshold int
+	TokenRefreshThreshold  int  `yaml:"token_refresh_threshold"`
+	TokenRefreshBackground bool `yaml:"token_refresh_background"`
+
+	// Seconds between checks of KIRO_CLI_DB_FILE(S) for credentials rotated by
+	// kiro-cli (0 disables)
+	KiroCLIDBWatchInterval int `yaml:"kiro_cli_db_watch_interval"`
 
 	// Retry configuration
-	MaxRetries     int
-	BaseRetryDelay float64
+	MaxRetries     int     `yaml:"max_retries"`
+	BaseRetryDelay float64 `yaml:"base_retry_delay"`
+
+	// Circuit breaker around Kiro: opens when the share of failed (or slower than
+	// the slow threshold, in seconds) requests in the window reaches the failure
+	// rate, then lets half-open probes through after the open duration.
+	// A failure rate of 0 disables it.
+	CircuitBreakerFailureRate      float64 `yaml:"circuit_breaker_failure_rate"`
+	CircuitBreakerMinRequests      int     `yaml:"circuit_breaker_min_requests"`
+	CircuitBreakerWindow           int     `yaml:"circuit_breaker_window"`
+	CircuitBreakerSlowThreshold    float64 `yaml:"circuit_breaker_slow_threshold"`
+	CircuitBreakerOpenDuration     int     `yaml:"circuit_breaker_open_duration"`
+	CircuitBreakerHalfOpenRequests int     `yaml:"circuit_breaker_half_open_requests"`
 
 	// Model settings
-	HiddenModels    map[string]string
-	ModelAliases    map[string]string
-	HiddenFromList  []string
-	FallbackModels  []ModelInfo
-	ModelCacheTTL   int
-	MaxInputTokens  int
+	HiddenModels   map[string]string `yaml:"hidden_models"`
+	ModelAliases   map[string]string `yaml:"model_aliases"`
+	HiddenFromList []string          `yaml:"hidden_from_list"`
+	FallbackModels []ModelInfo       `yaml:"fallback_models"`
+	ModelCacheTTL  int               `yaml:"model_cache_ttl"`
+	MaxInputTokens int               `yaml:"default_max_input_tokens"`
+
+	// Models to retry with, in order, when Kiro rejects a request for a model.
+	// Keys are resolved model IDs or globs ("claude-opus-*", "*"). Config file only.
+	ModelFallbackChains map[string][]string `yaml:"model_fallback_chains"`
+
+	// Per-model request defaults and clamps, keyed like model_fallback_chains.
+	// Config file only.
+	ModelProfiles map[string]ModelProfile `yaml:"model_profiles"`
+
+	// What to do when a request is estimated to exceed the model's max input
+	// tokens: "drop" the oldest history turns, "truncate_middle" to keep the first
+	// exchange and drop the turns after it, "error" to reject it, or "off"
+	ContextTrimStrategy string `yaml:"context_trim_strategy"`
+	// Summarize older history turns with SummarizeModel once the prompt exceeds
+	// this percentage of the model's max input tokens (0 disables), keeping the
+	// last SummarizeKeepMessages messages verbatim
+	SummarizeThreshold    float64 `yaml:"summarize_threshold"`
+	SummarizeModel        string  `yaml:"summarize_model"`
+	SummarizeKeepMessages int     `yaml:"summarize_keep_messages"`
+
+	// How the Kiro conversation ID is chosen: "random" per request, "header" to
+	// reuse the client's x-conversation-id, or "auto" to also derive one from the
+	// system prompt and first user message
+	ConversationIDMode string `yaml:"conversation_id_mode"`
 
 	// Tool settings
-	ToolDescriptionMaxLength int
+	ToolDescriptionMaxLength int `yaml:"tool_description_max_length"`
+
+	// Forward temperature/top_p/max_tokens to Kiro as inferenceConfiguration
+	ForwardInferenceConfig bool `yaml:"kiro_inference_config"`
+
+	// Extra attempts when JSON mode output fails validation
+	JSONModeMaxRetries int `yaml:"json_mode_max_retries"`
+
+	// Experimental draft routing: small non-streaming chat completions for
+	// sonnet/opus models are answered by DraftModel first and only escalated
+	// to the requested model when the draft fails. Can be set per API key.
+	DraftRouting         bool            `yaml:"draft_routing"`
+	DraftRoutingKeys     map[string]bool `yaml:"draft_routing_keys"`
+	DraftModel           string          `yaml:"draft_model"`
+	DraftMaxPromptTokens int             `yaml:"draft_max_prompt_tokens"`
 
 	// Truncation recovery
-	TruncationRecovery bool
+	TruncationRecovery bool `yaml:"truncation_recovery"`
 
 	// Logging
-	LogLevel string
+	LogLevel  string `yaml:"log_level"`
+	LogFormat string `yaml:"log_format"`
+	// Mask tokens and secrets in every log line and in upstream error messages
+	ParanoidLogging bool `yaml:"paranoid_logging"`
+	// Seconds after which a finished request is logged as slow, with its
+	// conversation ID and payload sizes (0 disables)
+	SlowRequestThreshold float64 `yaml:"slow_request_threshold"`
+
+	// OpenTelemetry tracing over OTLP/HTTP (disabled unless an endpoint is set)
+	OTelEndpoint       string   `yaml:"otel_exporter_otlp_endpoint"`
+	OTelTracesEndpoint string   `yaml:"otel_exporter_otlp_traces_endpoint"`
+	OTelHeaders        []string `yaml:"otel_exporter_otlp_headers"`
+	OTelServiceName    string   `yaml:"otel_service_name"`
+
+	// Shared HTTP transport settings (seconds for timeouts)
+	HTTPMaxIdleConns        int     `yaml:"http_max_idle_conns"`
+	HTTPMaxIdleConnsPerHost int     `yaml:"http_max_idle_conns_per_host"`
+	HTTPMaxConnsPerHost     int     `yaml:"http_max_conns_per_host"`
+	HTTPIdleConnTimeout     float64 `yaml:"http_idle_conn_timeout"`
+	HTTPDialTimeout         float64 `yaml:"http_dial_timeout"`
+	HTTPKeepAlive           float64 `yaml:"http_keepalive"`
+	HTTPTLSHandshakeTimeout float64 `yaml:"http_tls_handshake_timeout"`
+	HTTPTLSMinVersion       string  `yaml:"http_tls_min_version"`
+	HTTP2Enabled            bool    `yaml:"http2"`
+	// Request bodies to Kiro: streamed while encoded, and "gzip" compression
+	HTTPStreamRequests     bool   `yaml:"http_stream_requests"`
+	HTTPRequestCompression string `yaml:"http_request_compression"`
 
 	// Timeout settings
-	FirstTokenTimeout    float64
-	StreamingReadTimeout float64
-	FirstTokenMaxRetries int
+	FirstTokenTimeout    float64 `yaml:"first_token_timeout"`
+	StreamingReadTimeout float64 `yaml:"streaming_read_timeout"`
+	FirstTokenMaxRetries int     `yaml:"first_token_max_retries"`
+
+	// Seconds a request may take in total before it fails with a 504, unless the
+	// client sets its own budget in x-request-timeout (0 disables)
+	RequestTimeout float64 `yaml:"request_timeout"`
+
+	// Seconds of stream inactivity before a keep-alive is sent (0 disables)
+	StreamingKeepAliveInterval float64 `yaml:"streaming_keepalive_interval"`
+
+	// Seconds a streaming client may take to accept a write before the request is
+	// cancelled (0 disables)
+	StreamingWriteTimeout float64 `yaml:"streaming_write_timeout"`
 
 	// Debug settings
-	DebugMode string
-	DebugDir  string
+	DebugMode        string `yaml:"debug_mode"`
+	DebugDir         string `yaml:"debug_dir"`
+	DebugMaxBytes    int    `yaml:"debug_max_bytes"`
+	DebugMaxCaptures int    `yaml:"debug_max_captures"`
+
+	// Directory to record Kiro exchanges to as replayable fixtures (empty disables)
+	FixtureRecordDir string `yaml:"fixture_record_dir"`
+
+	// Remote image fetching for OpenAI image_url (disabled by default)
+	ImageFetchEnabled      bool     `yaml:"image_fetch_enabled"`
+	ImageFetchMaxBytes     int      `yaml:"image_fetch_max_bytes"`
+	ImageFetchTimeout      float64  `yaml:"image_fetch_timeout"`
+	ImageFetchAllowedTypes []string `yaml:"image_fetch_allowed_types"`
+
+	// Builtin tools the proxy runs itself for agentic chat completions ("web_fetch",
+	// "shell"), and the bounds of one agentic request
+	AgenticTools         []string `yaml:"agentic_tools"`
+	AgenticMaxSteps      int      `yaml:"agentic_max_steps"`
+	AgenticToolTimeout   float64  `yaml:"agentic_tool_timeout"`
+	AgenticToolMaxOutput int      `yaml:"agentic_tool_max_output"`
+
+	// Cache for identical non-streaming requests (0 TTL disables)
+	ResponseCacheTTL        float64 `yaml:"response_cache_ttl"`
+	ResponseCacheMaxEntries int     `yaml:"response_cache_max_entries"`
+
+	// Responses kept for requests with an Idempotency-Key (0 TTL disables)
+	IdempotencyTTL        float64 `yaml:"idempotency_ttl"`
+	IdempotencyMaxEntries int     `yaml:"idempotency_max_entries"`
+
+	// /v1/embeddings backend: "" disables, "proxy" forwards to an OpenAI-compatible
+	// provider, "lexical" computes hashed bag-of-words embeddings in process
+	EmbeddingsBackend    string  `yaml:"embeddings_backend"`
+	EmbeddingsURL        string  `yaml:"embeddings_url"`
+	EmbeddingsAPIKey     string  `yaml:"embeddings_api_key"`
+	EmbeddingsModel      string  `yaml:"embeddings_model"`
+	EmbeddingsDimensions int     `yaml:"embeddings_dimensions"`
+	EmbeddingsTimeout    float64 `yaml:"embeddings_timeout"`
+
+	// Anthropic message batches: results are persisted to BatchDir ("" keeps them
+	// in memory), BatchConcurrency requests run at once across all batches and
+	// ended batches are deleted after BatchRetention seconds (0 keeps them)
+	BatchDir         string  `yaml:"batch_dir"`
+	BatchConcurrency int     `yaml:"batch_concurrency"`
+	BatchMaxRequests int     `yaml:"batch_max_requests"`
+	BatchRetention   float64 `yaml:"batch_retention"`
+
+	// Server-side conversation histories for clients that send only their latest
+	// message with x-conversation-id ("" disables), kept for ConversationStoreTTL
+	// seconds after the last turn
+	ConversationStoreDir string  `yaml:"conversation_store_dir"`
+	ConversationStoreTTL float64 `yaml:"conversation_store_ttl"`
+
+	// Local record of each answered chat request, appended per day and
+	// conversation to TranscriptDir ("" disables) as "markdown", "jsonl" or "both"
+	TranscriptDir    string `yaml:"transcript_dir"`
+	TranscriptFormat string `yaml:"transcript_format"`
+
+	// Request size limits (0 disables a limit). Bodies over MaxRequestBodyBytes are
+	// rejected with 413 before being parsed; the others are checked on the converted
+	// request before the Kiro payload is built. MaxImageBytes is the decoded size.
+	MaxRequestBodyBytes int `yaml:"max_request_body_bytes"`
+	MaxMessages         int `yaml:"max_messages"`
+	MaxPromptChars      int `yaml:"max_prompt_chars"`
+	MaxTools            int `yaml:"max_tools"`
+	MaxImageBytes       int `yaml:"max_image_bytes"`
+
+	// Images attached to a Kiro request across the current message and history
+	// (0 is unlimited). The oldest images are dropped first.
+	MaxImages int `yaml:"max_images"`
+
+	// Strict validation rejects request fields the API does not define and
+	// malformed values (roles, tool names, sampling ranges, content part types)
+	// that are otherwise ignored or passed through
+	StrictValidation bool `yaml:"strict_validation"`
+
+	// What to do with OpenAI parameters Kiro cannot honor (logprobs, seed,
+	// penalties, audio output): "silent" ignores them, "warn" lists them in the
+	// x-kiro-ignored-params response header and "reject" answers 400
+	UnsupportedParams string `yaml:"unsupported_params"`
+
+	// System prompt policy applied to every request: matches of the strip patterns
+	// are removed from the client's system prompt, a per-model template may wrap it,
+	// and the prefix and suffix guardrails are always added around the result
+	SystemPromptPrefix        string            `yaml:"system_prompt_prefix"`
+	SystemPromptSuffix        string            `yaml:"system_prompt_suffix"`
+	SystemPromptStripPatterns []string          `yaml:"system_prompt_strip_patterns"`
+	SystemPromptTemplates     map[string]string `yaml:"system_prompt_templates"`
+
+	// Outbound PII filter: matches of the patterns (builtin names or keys of the
+	// custom patterns) in message content, tool arguments and tool results are
+	// redacted, block the request or are only logged before it is sent to Kiro.
+	// Per API key policies replace the global mode and patterns. Custom patterns
+	// are config file only.
+	PIIFilterMode           string               `yaml:"pii_filter_mode"`
+	PIIFilterPatterns       []string             `yaml:"pii_filter_patterns"`
+	PIIFilterCustomPatterns map[string]string    `yaml:"pii_filter_custom_patterns"`
+	PIIFilterKeys           map[string]PIIPolicy `yaml:"pii_filter_keys"`
+
+	// Audit log of prompts and completions (empty file disables). The redaction
+	// level ("metadata", "pii" or "full") can be set per API key; "off" skips a key.
+	AuditFile      string            `yaml:"audit_file"`
+	AuditRedaction string            `yaml:"audit_redaction"`
+	AuditKeys      map[string]string `yaml:"audit_keys"`
 
 	// Fake reasoning settings
-	FakeReasoningEnabled    bool
-	FakeReasoningMaxTokens  int
-	FakeReasoningHandling   string
-	FakeReasoningOpenTags   []string
-	FakeReasoningBufferSize int
+	FakeReasoningEnabled    bool     `yaml:"fake_reasoning"`
+	FakeReasoningMaxTokens  int      `yaml:"fake_reasoning_max_tokens"`
+	FakeReasoningHandling   string   `yaml:"fake_reasoning_handling"`
+	FakeReasoningOpenTags   []string `yaml:"fake_reasoning_open_tags"`
+	FakeReasoningBufferSize int      `yaml:"fake_reasoning_initial_buffer_size"`
+	// End thinking at FakeReasoningMaxTokens instead of only asking the model to
+	// stay within it; the rest of the thinking is sent as content, after the
+	// optional marker
+	FakeReasoningEnforceBudget bool   `yaml:"fake_reasoning_enforce_budget"`
+	FakeReasoningBudgetMarker  string `yaml:"fake_reasoning_budget_marker"`
+	// Drop the reasoning of earlier assistant turns from the history (thinking
+	// blocks, reasoning_content) instead of sending it back to Kiro
+	StripHistoryReasoning bool `yaml:"strip_history_reasoning"`
 }
 
 // ModelInfo represents model information
 type ModelInfo struct {
-	ModelID string `json:"modelId"`
+	ModelID string `json:"modelId" yaml:"model_id"`
+}
+
+// ModelProfile holds the request settings of a model_profiles entry, applied on
+// top of what the client sent
+type ModelProfile struct {
+	// DefaultMaxTokens is the output budget of requests that set none
+	DefaultMaxTokens int `yaml:"default_max_tokens" json:"default_max_tokens,omitempty"`
+	// MaxOutputTokens caps the output budget clients may ask for
+	MaxOutputTokens int `yaml:"max_output_tokens" json:"max_output_tokens,omitempty"`
+	// Thinking forces fake reasoning on or off regardless of FAKE_REASONING and reasoning_effort
+	Thinking *bool `yaml:"thinking" json:"thinking,omitempty"`
+	// DisableImages drops image content before the request is sent
+	DisableImages bool `yaml:"disable_images" json:"disable_images,omitempty"`
+	// ThinkingTags replaces fake_reasoning_open_tags for the model, for models that
+	// delimit their reasoning with other tags
+	ThinkingTags []string `yaml:"thinking_tags" json:"thinking_tags,omitempty"`
 }
 
+// String formats the profile for the config reload log
+func (p ModelProfile) String() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+// DefaultProxyAPIKey is the documented example PROXY_API_KEY, unsafe to keep in production
+const DefaultProxyAPIKey = "my-super-secret-password-123"
+
 // Default values
 var defaults = &Config{
 	ServerHost:               "0.0.0.0",
 	ServerPort:               8000,
-	ProxyAPIKey:              "my-super-secret-password-123",
+	ProxyAPIKey:              DefaultProxyAPIKey,
 	VPNProxyURL:              "",
 	Region:                   "us-east-1",
 	TokenRefreshThreshold:    600,
+	TokenRefreshBackground:   true,
+	KiroCLIDBWatchInterval:   5,
+	AccountCooldown:          60,
+	AuthFailureWindow:        300,
+	AuthLockoutDuration:      900,
 	MaxRetries:               3,
 	BaseRetryDelay:           1.0,
+	CircuitBreakerFailureRate: 0.5,
+	CircuitBreakerMinRequests: 10,
+	CircuitBreakerWindow:     60,
+	CircuitBreakerOpenDuration: 30,
+	CircuitBreakerHalfOpenRequests: 1,
 	ModelCacheTTL:            3600,
 	MaxInputTokens:           200000,
+	ContextTrimStrategy:      "drop",
+	SummarizeModel:           "claude-haiku-4.5",
+	SummarizeKeepMessages:    6,
+	ConversationIDMode:       "random",
+	UnsupportedParams:        "silent",
 	ToolDescriptionMaxLength: 10000,
+	JSONModeMaxRetries:       1,
+	DraftModel:               "claude-haiku-4.5",
+	DraftMaxPromptTokens:     4000,
 	TruncationRecovery:       true,
 	LogLevel:                 "INFO",
+	LogFormat:                "text",
+	OTelServiceName:          "kiro-gateway",
+	HTTPMaxIdleConns:         100,
+	HTTPMaxIdleConnsPerHost:  20,
+	HTTPIdleConnTimeout:      90,
+	HTTPDialTimeout:          30,
+	HTTPKeepAlive:            30,
+	HTTPTLSHandshakeTimeout:  10,
+	HTTPTLSMinVersion:        "1.2",
+	HTTP2Enabled:             true,
+	HTTPStreamRequests:       false,
+	HTTPRequestCompression:   "none",
 	FirstTokenTimeout:        15,
 	StreamingReadTimeout:     300,
+	StreamingKeepAliveInterval: 15,
+	StreamingWriteTimeout:      30,
 	FirstTokenMaxRetries:     3,
 	DebugMode:                "off",
 	DebugDir:                 "debug_logs",
+	DebugMaxBytes:            10 * 1024 * 1024,
+	DebugMaxCaptures:         100,
+	UsageFile:                "usage.json",
+	ImageFetchMaxBytes:       5 * 1024 * 1024,
+	ImageFetchTimeout:        10,
+	ImageFetchAllowedTypes:   []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+	PIIFilterMode:            "off",
+	PIIFilterPatterns:        []string{"email", "aws_access_key", "aws_secret_key", "credit_card"},
+	AuditRedaction:           "pii",
+	AgenticMaxSteps:          10,
+	AgenticToolTimeout:       30,
+	AgenticToolMaxOutput:     64 * 1024,
+	ResponseCacheMaxEntries:  1000,
+	IdempotencyTTL:           86400,
+	WebSocketIdleTimeout:     300,
+	IdempotencyMaxEntries:    1000,
+	EmbeddingsDimensions:     256,
+	EmbeddingsTimeout:        30,
+	BatchDir:                 "batches",
+	MaxRequestBodyBytes:      32 * 1024 * 1024,
+	MaxImageBytes:            5 * 1024 * 1024,
+	MaxImages:                20,
+	BatchConcurrency:         4,
+	BatchMaxRequests:         10000,
+	BatchRetention:           2505600,
+	ConversationStoreTTL:     86400,
+	TranscriptFormat:         "markdown",
 	FakeReasoningEnabled:     true,
 	FakeReasoningMaxTokens:   4000,
 	FakeReasoningHandling:    "as_reasoning_content",
-	FakeReasoningOpenTags:    []string{"<thinking>", "alettek", "<reasoning>", "<thought>"},
+	FakeReasoningOpenTags:    append([]string(nil), parser.DefaultThinkingTags...),
 	FakeReasoningBufferSize:  20,
+	StripHistoryReasoning:    true,
 	HiddenModels: map[string]string{
 		"claude-3.7-sonnet": "CLAUDE_3_7_SONNET_20250219_V1_0",
 	},
@@ -124,72 +489,205 @@ var defaults = &Config{
 
 var globalConfig *Config
 
-// Load loads configuration from environment and .env file
-func Load() *Config {
+// Load loads configuration from environment, .env file and the CONFIG_FILE config file
+func Load() (*Config, error) {
+	return LoadFile("")
+}
+
+// LoadFile loads configuration from defaults, then the YAML/JSON config file at path
+// (or CONFIG_FILE when path is empty), then environment variables and .env file.
+// Environment variables take precedence over the config file.
+func LoadFile(path string) (*Config, error) {
 	// Load .env file if exists
 	godotenv.Load()
 
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+
+	// Config file values replace the defaults the environment falls back to
+	base := defaults.clone()
+	if path != "" {
+		if err := base.applyFile(path); err != nil {
+			return nil, err
+		}
+	}
+
 	cfg := &Config{
-		ServerHost:               getEnvString("SERVER_HOST", defaults.ServerHost),
-		ServerPort:               getEnvInt("SERVER_PORT", defaults.ServerPort),
-		ProxyAPIKey:              getEnvString("PROXY_API_KEY", defaults.ProxyAPIKey),
-		VPNProxyURL:              getEnvString("VPN_PROXY_URL", defaults.VPNProxyURL),
-		RefreshToken:             getEnvString("REFRESH_TOKEN", ""),
-		ProfileArn:               getEnvString("PROFILE_ARN", ""),
-		Region:                   getEnvString("KIRO_REGION", defaults.Region),
-		KiroCredsFile:            getEnvString("KIRO_CREDS_FILE", ""),
-		KiroCLIDBFile:            getEnvString("KIRO_CLI_DB_FILE", ""),
-		TokenRefreshThreshold:    getEnvInt("TOKEN_REFRESH_THRESHOLD", defaults.TokenRefreshThreshold),
-		MaxRetries:               getEnvInt("MAX_RETRIES", defaults.MaxRetries),
-		BaseRetryDelay:           getEnvFloat("BASE_RETRY_DELAY", defaults.BaseRetryDelay),
-		ModelCacheTTL:            getEnvInt("MODEL_CACHE_TTL", defaults.ModelCacheTTL),
-		MaxInputTokens:           getEnvInt("DEFAULT_MAX_INPUT_TOKENS", defaults.MaxInputTokens),
-		ToolDescriptionMaxLength: getEnvInt("TOOL_DESCRIPTION_MAX_LENGTH", defaults.ToolDescriptionMaxLength),
-		TruncationRecovery:       getEnvBool("TRUNCATION_RECOVERY", defaults.TruncationRecovery),
-		LogLevel:                 getEnvString("LOG_LEVEL", defaults.LogLevel),
-		FirstTokenTimeout:        getEnvFloat("FIRST_TOKEN_TIMEOUT", defaults.FirstTokenTimeout),
-		StreamingReadTimeout:     getEnvFloat("STREAMING_READ_TIMEOUT", defaults.StreamingReadTimeout),
-		FirstTokenMaxRetries:     getEnvInt("FIRST_TOKEN_MAX_RETRIES", defaults.FirstTokenMaxRetries),
-		DebugMode:                getEnvString("DEBUG_MODE", defaults.DebugMode),
-		DebugDir:                 getEnvString("DEBUG_DIR", defaults.DebugDir),
-		FakeReasoningEnabled:     getEnvBool("FAKE_REASONING", defaults.FakeReasoningEnabled),
-		FakeReasoningMaxTokens:   getEnvInt("FAKE_REASONING_MAX_TOKENS", defaults.FakeReasoningMaxTokens),
-		FakeReasoningHandling:    getEnvString("FAKE_REASONING_HANDLING", defaults.FakeReasoningHandling),
-		FakeReasoningBufferSize:  getEnvInt("FAKE_REASONING_INITIAL_BUFFER_SIZE", defaults.FakeReasoningBufferSize),
-	}
-
-	// Copy maps and slices
-	cfg.HiddenModels = make(map[string]string)
-	for k, v := range defaults.HiddenModels {
-		cfg.HiddenModels[k] = v
-	}
-	cfg.ModelAliases = make(map[string]string)
-	for k, v := range defaults.ModelAliases {
-		cfg.ModelAliases[k] = v
-	}
-	cfg.HiddenFromList = make([]string, len(defaults.HiddenFromList))
-	copy(cfg.HiddenFromList, defaults.HiddenFromList)
-	cfg.FallbackModels = make([]ModelInfo, len(defaults.FallbackModels))
-	copy(cfg.FallbackModels, defaults.FallbackModels)
-	cfg.FakeReasoningOpenTags = make([]string, len(defaults.FakeReasoningOpenTags))
-	copy(cfg.FakeReasoningOpenTags, defaults.FakeReasoningOpenTags)
+		ServerHost:               getEnvString("SERVER_HOST", base.ServerHost),
+		ServerPort:               getEnvInt("SERVER_PORT", base.ServerPort),
+		ProxyAPIKey:              getEnvString("PROXY_API_KEY", base.ProxyAPIKey),
+		ProxyAPIKeys:             getEnvStrings("PROXY_API_KEYS", base.ProxyAPIKeys),
+		AdminAPIKey:              getEnvString("ADMIN_API_KEY", base.AdminAPIKey),
+		AuthExemptPaths:          getEnvStrings("AUTH_EXEMPT_PATHS", base.AuthExemptPaths),
+		HealthAuth:               getEnvBool("HEALTH_AUTH", base.HealthAuth),
+		VPNProxyURL:              getEnvString("VPN_PROXY_URL", base.VPNProxyURL),
+		VPNNoProxy:               getEnvStrings("VPN_NO_PROXY", base.VPNNoProxy),
+		TLSCertFile:              getEnvString("TLS_CERT_FILE", base.TLSCertFile),
+		TLSKeyFile:               getEnvString("TLS_KEY_FILE", base.TLSKeyFile),
+		TLSSelfSigned:            getEnvBool("TLS_SELF_SIGNED", base.TLSSelfSigned),
+		TLSClientCAFile:          getEnvString("TLS_CLIENT_CA_FILE", base.TLSClientCAFile),
+		RateLimitRPM:             getEnvInt("RATE_LIMIT_RPM", base.RateLimitRPM),
+		RateLimitConcurrent:      getEnvInt("RATE_LIMIT_CONCURRENT", base.RateLimitConcurrent),
+		RateLimitKeys:            getEnvRateLimits("RATE_LIMIT_KEYS", base.RateLimitKeys),
+		AuthMaxFailures:          getEnvInt("AUTH_MAX_FAILURES", base.AuthMaxFailures),
+		AuthFailureWindow:        getEnvInt("AUTH_FAILURE_WINDOW", base.AuthFailureWindow),
+		AuthLockoutDuration:      getEnvInt("AUTH_LOCKOUT_DURATION", base.AuthLockoutDuration),
+		TrustedProxies:           getEnvStrings("TRUSTED_PROXIES", base.TrustedProxies),
+		WebSocketOrigins:         getEnvStrings("WEBSOCKET_ORIGINS", base.WebSocketOrigins),
+		WebSocketIdleTimeout:     getEnvFloat("WEBSOCKET_IDLE_TIMEOUT", base.WebSocketIdleTimeout),
+		UsageFile:                getEnvString("USAGE_FILE", base.UsageFile),
+		QuotaRequests:            getEnvInt("QUOTA_REQUESTS", base.QuotaRequests),
+		QuotaTokens:              getEnvInt("QUOTA_TOKENS", base.QuotaTokens),
+		QuotaCredits:             getEnvInt("QUOTA_CREDITS", base.QuotaCredits),
+		RefreshToken:             getEnvString("REFRESH_TOKEN", base.RefreshToken),
+		ProfileArn:               getEnvString("PROFILE_ARN", base.ProfileArn),
+		Region:                   getEnvString("KIRO_REGION", base.Region),
+		KiroCredsFile:            getEnvString("KIRO_CREDS_FILE", base.KiroCredsFile),
+		KiroCLIDBFile:            getEnvString("KIRO_CLI_DB_FILE", base.KiroCLIDBFile),
+		AWSSSOProfile:            getEnvString("AWS_SSO_PROFILE", base.AWSSSOProfile),
+		RefreshTokens:            getEnvStrings("REFRESH_TOKENS", base.RefreshTokens),
+		KiroCredsFiles:           getEnvStrings("KIRO_CREDS_FILES", base.KiroCredsFiles),
+		KiroCLIDBFiles:           getEnvStrings("KIRO_CLI_DB_FILES", base.KiroCLIDBFiles),
+		CredsEncryptionKey:       getEnvString("CREDS_ENCRYPTION_KEY", base.CredsEncryptionKey),
+		CredsEncryptionKeyring:   getEnvBool("CREDS_ENCRYPTION_KEYRING", base.CredsEncryptionKeyring),
+		AccountCooldown:          getEnvInt("ACCOUNT_COOLDOWN", base.AccountCooldown),
+		TokenRefreshThreshold:    getEnvInt("TOKEN_REFRESH_THRESHOLD", base.TokenRefreshThreshold),
+		TokenRefreshBackground:   getEnvBool("TOKEN_REFRESH_BACKGROUND", base.TokenRefreshBackground),
+		KiroCLIDBWatchInterval:   getEnvInt("KIRO_CLI_DB_WATCH_INTERVAL", base.KiroCLIDBWatchInterval),
+		MaxRetries:               getEnvInt("MAX_RETRIES", base.MaxRetries),
+		BaseRetryDelay:           getEnvFloat("BASE_RETRY_DELAY", base.BaseRetryDelay),
+		CircuitBreakerFailureRate: getEnvFloat("CIRCUIT_BREAKER_FAILURE_RATE", base.CircuitBreakerFailureRate),
+		CircuitBreakerMinRequests: getEnvInt("CIRCUIT_BREAKER_MIN_REQUESTS", base.CircuitBreakerMinRequests),
+		CircuitBreakerWindow:     getEnvInt("CIRCUIT_BREAKER_WINDOW", base.CircuitBreakerWindow),
+		CircuitBreakerSlowThreshold: getEnvFloat("CIRCUIT_BREAKER_SLOW_THRESHOLD", base.CircuitBreakerSlowThreshold),
+		CircuitBreakerOpenDuration: getEnvInt("CIRCUIT_BREAKER_OPEN_DURATION", base.CircuitBreakerOpenDuration),
+		CircuitBreakerHalfOpenRequests: getEnvInt("CIRCUIT_BREAKER_HALF_OPEN_REQUESTS", base.CircuitBreakerHalfOpenRequests),
+		ModelCacheTTL:            getEnvInt("MODEL_CACHE_TTL", base.ModelCacheTTL),
+		MaxInputTokens:           getEnvInt("DEFAULT_MAX_INPUT_TOKENS", base.MaxInputTokens),
+		ContextTrimStrategy:      getEnvString("CONTEXT_TRIM_STRATEGY", base.ContextTrimStrategy),
+		SummarizeThreshold:       getEnvFloat("SUMMARIZE_THRESHOLD", base.SummarizeThreshold),
+		SummarizeModel:           getEnvString("SUMMARIZE_MODEL", base.SummarizeModel),
+		SummarizeKeepMessages:    getEnvInt("SUMMARIZE_KEEP_MESSAGES", base.SummarizeKeepMessages),
+		ConversationIDMode:       getEnvString("CONVERSATION_ID_MODE", base.ConversationIDMode),
+		ToolDescriptionMaxLength: getEnvInt("TOOL_DESCRIPTION_MAX_LENGTH", base.ToolDescriptionMaxLength),
+		ForwardInferenceConfig:   getEnvBool("KIRO_INFERENCE_CONFIG", base.ForwardInferenceConfig),
+		JSONModeMaxRetries:       getEnvInt("JSON_MODE_MAX_RETRIES", base.JSONModeMaxRetries),
+		DraftRouting:             getEnvBool("DRAFT_ROUTING", base.DraftRouting),
+		DraftRoutingKeys:         getEnvKeyBools("DRAFT_ROUTING_KEYS", base.DraftRoutingKeys),
+		DraftModel:               getEnvString("DRAFT_MODEL", base.DraftModel),
+		DraftMaxPromptTokens:     getEnvInt("DRAFT_MAX_PROMPT_TOKENS", base.DraftMaxPromptTokens),
+		TruncationRecovery:       getEnvBool("TRUNCATION_RECOVERY", base.TruncationRecovery),
+		LogLevel:                 getEnvString("LOG_LEVEL", base.LogLevel),
+		LogFormat:                getEnvString("LOG_FORMAT", base.LogFormat),
+		ParanoidLogging:          getEnvBool("PARANOID_LOGGING", base.ParanoidLogging),
+		SlowRequestThreshold:     getEnvFloat("SLOW_REQUEST_THRESHOLD", base.SlowRequestThreshold),
+		OTelEndpoint:             getEnvString("OTEL_EXPORTER_OTLP_ENDPOINT", base.OTelEndpoint),
+		OTelTracesEndpoint:       getEnvString("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT", base.OTelTracesEndpoint),
+		OTelHeaders:              getEnvStrings("OTEL_EXPORTER_OTLP_HEADERS", base.OTelHeaders),
+		OTelServiceName:          getEnvString("OTEL_SERVICE_NAME", base.OTelServiceName),
+		HTTPMaxIdleConns:         getEnvInt("HTTP_MAX_IDLE_CONNS", base.HTTPMaxIdleConns),
+		HTTPMaxIdleConnsPerHost:  getEnvInt("HTTP_MAX_IDLE_CONNS_PER_HOST", base.HTTPMaxIdleConnsPerHost),
+		HTTPMaxConnsPerHost:      getEnvInt("HTTP_MAX_CONNS_PER_HOST", base.HTTPMaxConnsPerHost),
+		HTTPIdleConnTimeout:      getEnvFloat("HTTP_IDLE_CONN_TIMEOUT", base.HTTPIdleConnTimeout),
+		HTTPDialTimeout:          getEnvFloat("HTTP_DIAL_TIMEOUT", base.HTTPDialTimeout),
+		HTTPKeepAlive:            getEnvFloat("HTTP_KEEPALIVE", base.HTTPKeepAlive),
+		HTTPTLSHandshakeTimeout:  getEnvFloat("HTTP_TLS_HANDSHAKE_TIMEOUT", base.HTTPTLSHandshakeTimeout),
+		HTTPTLSMinVersion:        getEnvString("HTTP_TLS_MIN_VERSION", base.HTTPTLSMinVersion),
+		HTTP2Enabled:             getEnvBool("HTTP2", base.HTTP2Enabled),
+		HTTPStreamRequests:       getEnvBool("HTTP_STREAM_REQUESTS", base.HTTPStreamRequests),
+		HTTPRequestCompression:   getEnvString("HTTP_REQUEST_COMPRESSION", base.HTTPRequestCompression),
+		FirstTokenTimeout:        getEnvFloat("FIRST_TOKEN_TIMEOUT", base.FirstTokenTimeout),
+		StreamingReadTimeout:     getEnvFloat("STREAMING_READ_TIMEOUT", base.StreamingReadTimeout),
+		StreamingKeepAliveInterval: getEnvFloat("STREAMING_KEEPALIVE_INTERVAL", base.StreamingKeepAliveInterval),
+		StreamingWriteTimeout:      getEnvFloat("STREAMING_WRITE_TIMEOUT", base.StreamingWriteTimeout),
+		FirstTokenMaxRetries:     getEnvInt("FIRST_TOKEN_MAX_RETRIES", base.FirstTokenMaxRetries),
+		RequestTimeout:           getEnvFloat("REQUEST_TIMEOUT", base.RequestTimeout),
+		DebugMode:                getEnvString("DEBUG_MODE", base.DebugMode),
+		DebugDir:                 getEnvString("DEBUG_DIR", base.DebugDir),
+		DebugMaxBytes:            getEnvInt("DEBUG_MAX_BYTES", base.DebugMaxBytes),
+		DebugMaxCaptures:         getEnvInt("DEBUG_MAX_CAPTURES", base.DebugMaxCaptures),
+		FixtureRecordDir:         getEnvString("FIXTURE_RECORD_DIR", base.FixtureRecordDir),
+		ImageFetchEnabled:        getEnvBool("IMAGE_FETCH_ENABLED", base.ImageFetchEnabled),
+		ImageFetchMaxBytes:       getEnvInt("IMAGE_FETCH_MAX_BYTES", base.ImageFetchMaxBytes),
+		ImageFetchTimeout:        getEnvFloat("IMAGE_FETCH_TIMEOUT", base.ImageFetchTimeout),
+		ImageFetchAllowedTypes:   getEnvStrings("IMAGE_FETCH_ALLOWED_TYPES", base.ImageFetchAllowedTypes),
+		AgenticTools:             getEnvStrings("AGENTIC_TOOLS", base.AgenticTools),
+		AgenticMaxSteps:          getEnvInt("AGENTIC_MAX_STEPS", base.AgenticMaxSteps),
+		AgenticToolTimeout:       getEnvFloat("AGENTIC_TOOL_TIMEOUT", base.AgenticToolTimeout),
+		AgenticToolMaxOutput:     getEnvInt("AGENTIC_TOOL_MAX_OUTPUT", base.AgenticToolMaxOutput),
+		ResponseCacheTTL:         getEnvFloat("RESPONSE_CACHE_TTL", base.ResponseCacheTTL),
+		ResponseCacheMaxEntries:  getEnvInt("RESPONSE_CACHE_MAX_ENTRIES", base.ResponseCacheMaxEntries),
+		IdempotencyTTL:           getEnvFloat("IDEMPOTENCY_TTL", base.IdempotencyTTL),
+		IdempotencyMaxEntries:    getEnvInt("IDEMPOTENCY_MAX_ENTRIES", base.IdempotencyMaxEntries),
+		EmbeddingsBackend:        getEnvString("EMBEDDINGS_BACKEND", base.EmbeddingsBackend),
+		EmbeddingsURL:            getEnvString("EMBEDDINGS_URL", base.EmbeddingsURL),
+		EmbeddingsAPIKey:         getEnvString("EMBEDDINGS_API_KEY", base.EmbeddingsAPIKey),
+		EmbeddingsModel:          getEnvString("EMBEDDINGS_MODEL", base.EmbeddingsModel),
+		EmbeddingsDimensions:     getEnvInt("EMBEDDINGS_DIMENSIONS", base.EmbeddingsDimensions),
+		EmbeddingsTimeout:        getEnvFloat("EMBEDDINGS_TIMEOUT", base.EmbeddingsTimeout),
+		BatchDir:                 getEnvString("BATCH_DIR", base.BatchDir),
+		BatchConcurrency:         getEnvInt("BATCH_CONCURRENCY", base.BatchConcurrency),
+		BatchMaxRequests:         getEnvInt("BATCH_MAX_REQUESTS", base.BatchMaxRequests),
+		BatchRetention:           getEnvFloat("BATCH_RETENTION", base.BatchRetention),
+		ConversationStoreDir:     getEnvString("CONVERSATION_STORE_DIR", base.ConversationStoreDir),
+		ConversationStoreTTL:     getEnvFloat("CONVERSATION_STORE_TTL", base.ConversationStoreTTL),
+		TranscriptDir:            getEnvString("TRANSCRIPT_DIR", base.TranscriptDir),
+		TranscriptFormat:         getEnvString("TRANSCRIPT_FORMAT", base.TranscriptFormat),
+		MaxRequestBodyBytes:      getEnvInt("MAX_REQUEST_BODY_BYTES", base.MaxRequestBodyBytes),
+		MaxMessages:              getEnvInt("MAX_MESSAGES", base.MaxMessages),
+		MaxPromptChars:           getEnvInt("MAX_PROMPT_CHARS", base.MaxPromptChars),
+		MaxTools:                 getEnvInt("MAX_TOOLS", base.MaxTools),
+		MaxImageBytes:            getEnvInt("MAX_IMAGE_BYTES", base.MaxImageBytes),
+		MaxImages:                getEnvInt("MAX_IMAGES", base.MaxImages),
+		StrictValidation:         getEnvBool("STRICT_VALIDATION", base.StrictValidation),
+		UnsupportedParams:        getEnvString("UNSUPPORTED_PARAMS", base.UnsupportedParams),
+		SystemPromptPrefix:       getEnvString("SYSTEM_PROMPT_PREFIX", base.SystemPromptPrefix),
+		SystemPromptSuffix:       getEnvString("SYSTEM_PROMPT_SUFFIX", base.SystemPromptSuffix),
+		PIIFilterMode:            getEnvString("PII_FILTER_MODE", base.PIIFilterMode),
+		PIIFilterPatterns:        getEnvStrings("PII_FILTER_PATTERNS", base.PIIFilterPatterns),
+		PIIFilterKeys:            getEnvPIIPolicies("PII_FILTER_KEYS", base.PIIFilterKeys),
+		AuditFile:                getEnvString("AUDIT_FILE", base.AuditFile),
+		AuditRedaction:           getEnvString("AUDIT_REDACTION", base.AuditRedaction),
+		AuditKeys:                getEnvKeyValues("AUDIT_KEYS", base.AuditKeys),
+		FakeReasoningEnabled:     getEnvBool("FAKE_REASONING", base.FakeReasoningEnabled),
+		FakeReasoningMaxTokens:   getEnvInt("FAKE_REASONING_MAX_TOKENS", base.FakeReasoningMaxTokens),
+		FakeReasoningHandling:    getEnvString("FAKE_REASONING_HANDLING", base.FakeReasoningHandling),
+		FakeReasoningOpenTags:    getEnvStrings("FAKE_REASONING_OPEN_TAGS", base.FakeReasoningOpenTags),
+		FakeReasoningBufferSize:  getEnvInt("FAKE_REASONING_INITIAL_BUFFER_SIZE", base.FakeReasoningBufferSize),
+		FakeReasoningEnforceBudget: getEnvBool("FAKE_REASONING_ENFORCE_BUDGET", base.FakeReasoningEnforceBudget),
+		FakeReasoningBudgetMarker:  getEnvString("FAKE_REASONING_BUDGET_MARKER", base.FakeReasoningBudgetMarker),
+		StripHistoryReasoning:    getEnvBool("STRIP_HISTORY_REASONING", base.StripHistoryReasoning),
+	}
+
+	// Maps and slices without an environment variable come from the config file or defaults
+	cfg.HiddenModels = base.HiddenModels
+	cfg.ModelAliases = base.ModelAliases
+	cfg.HiddenFromList = base.HiddenFromList
+	cfg.FallbackModels = base.FallbackModels
+	cfg.SystemPromptStripPatterns = base.SystemPromptStripPatterns
+	cfg.SystemPromptTemplates = base.SystemPromptTemplates
+	cfg.PIIFilterCustomPatterns = base.PIIFilterCustomPatterns
+	cfg.ModelFallbackChains = base.ModelFallbackChains
+	cfg.ModelProfiles = base.ModelProfiles
 
 	globalConfig = cfg
-	return cfg
+	return cfg, nil
 }
 
-// Get returns the global configuration
-func Get() *Config {
+// Get returns the global configuration, loading it on first use
+func Get() (*Config, error) {
 	if globalConfig == nil {
 		return Load()
 	}
-	return globalConfig
+	return globalConfig, nil
 }
 
 // URL templates
 const (
 	KiroRefreshURLTemplate = "https://prod.{region}.auth.desktop.kiro.dev/refreshToken"
 	AWSSSOOIDCURLTemplate  = "https://oidc.{region}.amazonaws.com/token"
+	AWSSSOOIDCHostTemplate = "https://oidc.{region}.amazonaws.com"
 	KiroAPIHostTemplate    = "https://q.{region}.amazonaws.com"
 	KiroQHostTemplate      = "https://q.{region}.amazonaws.com"
 )
@@ -224,6 +722,11 @@ func GetAWSSSOOIDCURLForRegion(region string) string {
 	return strings.ReplaceAll(AWSSSOOIDCURLTemplate, "{region}", region)
 }
 
+// GetAWSSSOOIDCHostForRegion returns the AWS SSO OIDC base URL used for device login
+func GetAWSSSOOIDCHostForRegion(region string) string {
+	return strings.ReplaceAll(AWSSSOOIDCHostTemplate, "{region}", region)
+}
+
 // GetKiroAPIHostForRegion returns the API host for a specific region
 func GetKiroAPIHostForRegion(region string) string {
 	return strings.ReplaceAll(KiroAPIHostTemplate, "{region}", region)
@@ -255,6 +758,195 @@ func getEnvFloat(key string, defaultValue float64) float64 {
 	return defaultValue
 }
 
+// getEnvStrings returns the comma-separated list in key, or defaultValue when it is unset
+func getEnvStrings(key string, defaultValue []string) []string {
+	if result := getEnvList(key); len(result) > 0 {
+		return result
+	}
+	return defaultValue
+}
+
+func getEnvList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getEnvRateLimits parses per-key limits in the form "key:rpm:concurrent,key2:rpm:concurrent",
+// returning defaultValue when key is unset
+func getEnvRateLimits(key string, defaultValue map[string]RateLimit) map[string]RateLimit {
+	if os.Getenv(key) == "" && defaultValue != nil {
+		return defaultValue
+	}
+	limits := make(map[string]RateLimit)
+	for _, item := range getEnvList(key) {
+		parts := strings.Split(item, ":")
+		if len(parts) != 3 {
+			continue
+		}
+		rpm, err1 := strconv.Atoi(strings.TrimSpace(parts[1]))
+		concurrent, err2 := strconv.Atoi(strings.TrimSpace(parts[2]))
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		limits[strings.TrimSpace(parts[0])] = RateLimit{RPM: rpm, Concurrent: concurrent}
+	}
+	return limits
+}
+
+// APIKeys returns the client API keys: PROXY_API_KEY and PROXY_API_KEYS
+func (c *Config) APIKeys() []string {
+	keys := []string{}
+	for _, key := range append([]string{c.ProxyAPIKey}, c.ProxyAPIKeys...) {
+		if key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// UnknownAPIKeys returns the per-key settings with entries for keys that are not
+// client API keys. Those entries never apply, usually because of a typo or a key
+// missing from PROXY_API_KEYS.
+func (c *Config) UnknownAPIKeys() []string {
+	known := make(map[string]bool)
+	for _, key := range c.APIKeys() {
+		known[key] = true
+	}
+	hasUnknown := func(keys []string) bool {
+		for _, key := range keys {
+			if !known[key] {
+				return true
+			}
+		}
+		return false
+	}
+
+	var settings []string
+	if hasUnknown(mapKeys(c.RateLimitKeys)) {
+		settings = append(settings, "RATE_LIMIT_KEYS")
+	}
+	if hasUnknown(mapKeys(c.PIIFilterKeys)) {
+		settings = append(settings, "PII_FILTER_KEYS")
+	}
+	if hasUnknown(mapKeys(c.AuditKeys)) {
+		settings = append(settings, "AUDIT_KEYS")
+	}
+	if hasUnknown(mapKeys(c.DraftRoutingKeys)) {
+		settings = append(settings, "DRAFT_ROUTING_KEYS")
+	}
+	return settings
+}
+
+func mapKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// RateLimitFor returns the limits for an API key, falling back to the global defaults
+func (c *Config) RateLimitFor(apiKey string) RateLimit {
+	if limit, ok := c.RateLimitKeys[apiKey]; ok {
+		return limit
+	}
+	return RateLimit{RPM: c.RateLimitRPM, Concurrent: c.RateLimitConcurrent}
+}
+
+// getEnvPIIPolicies parses per-key PII filter modes in the form "key:mode,key2:mode",
+// returning defaultValue when key is unset. The keys use the global patterns.
+func getEnvPIIPolicies(key string, defaultValue map[string]PIIPolicy) map[string]PIIPolicy {
+	if os.Getenv(key) == "" && defaultValue != nil {
+		return defaultValue
+	}
+	policies := make(map[string]PIIPolicy)
+	for _, item := range getEnvList(key) {
+		apiKey, mode, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		policies[strings.TrimSpace(apiKey)] = PIIPolicy{Mode: strings.TrimSpace(mode)}
+	}
+	return policies
+}
+
+// getEnvKeyValues parses per-key settings in the form "key:value,key2:value",
+// returning defaultValue when key is unset
+func getEnvKeyValues(key string, defaultValue map[string]string) map[string]string {
+	if os.Getenv(key) == "" && defaultValue != nil {
+		return defaultValue
+	}
+	values := make(map[string]string)
+	for _, item := range getEnvList(key) {
+		name, value, ok := strings.Cut(item, ":")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return values
+}
+
+// getEnvKeyBools parses per-key switches in the form "key:on,key2:off" (any
+// strconv.ParseBool value or on/off), returning defaultValue when key is unset
+func getEnvKeyBools(key string, defaultValue map[string]bool) map[string]bool {
+	if os.Getenv(key) == "" && defaultValue != nil {
+		return defaultValue
+	}
+	values := make(map[string]bool)
+	for name, value := range getEnvKeyValues(key, nil) {
+		switch strings.ToLower(value) {
+		case "on":
+			values[name] = true
+		case "off":
+			values[name] = false
+		default:
+			if enabled, err := strconv.ParseBool(value); err == nil {
+				values[name] = enabled
+			}
+		}
+	}
+	return values
+}
+
+// DraftRoutingFor reports whether draft routing applies to an API key, falling
+// back to DRAFT_ROUTING
+func (c *Config) DraftRoutingFor(apiKey string) bool {
+	if enabled, ok := c.DraftRoutingKeys[apiKey]; ok {
+		return enabled
+	}
+	return c.DraftRouting
+}
+
+// AuditRedactionFor returns the audit redaction level for an API key, falling
+// back to AUDIT_REDACTION
+func (c *Config) AuditRedactionFor(apiKey string) string {
+	if level, ok := c.AuditKeys[apiKey]; ok && level != "" {
+		return level
+	}
+	return c.AuditRedaction
+}
+
+// PIIPolicyFor returns the PII filter policy for an API key, falling back to the
+// global mode and patterns for the settings its policy leaves out
+func (c *Config) PIIPolicyFor(apiKey string) PIIPolicy {
+	policy := PIIPolicy{Mode: c.PIIFilterMode, Patterns: c.PIIFilterPatterns}
+	if keyPolicy, ok := c.PIIFilterKeys[apiKey]; ok {
+		if keyPolicy.Mode != "" {
+			policy.Mode = keyPolicy.Mode
+		}
+		if len(keyPolicy.Patterns) > 0 {
+			policy.Patterns = keyPolicy.Patterns
+		}
+	}
+	return policy
+}
+
 func getEnvBool(key string, defaultValue bool) bool {
 	if value := os.Getenv(key); value != "" {
 		lower := strings.ToLower(value)
@@ -268,9 +960,199 @@ func (c *Config) Validate() error {
 	hasRefreshToken := c.RefreshToken != ""
 	hasCredsFile := c.KiroCredsFile != ""
 	hasCLIDB := c.KiroCLIDBFile != ""
+	hasAWSProfile := c.AWSSSOProfile != ""
+	hasPool := len(c.RefreshTokens) > 0 || len(c.KiroCredsFiles) > 0 || len(c.KiroCLIDBFiles) > 0
 
-	if !hasRefreshToken && !hasCredsFile && !hasCLIDB {
-		return fmt.Errorf("no Kiro credentials configured. Set REFRESH_TOKEN, KIRO_CREDS_FILE, or KIRO_CLI_DB_FILE")
+	if !hasRefreshToken && !hasCredsFile && !hasCLIDB && !hasAWSProfile && !hasPool {
+		return fmt.Errorf("no Kiro credentials configured. Set REFRESH_TOKEN, KIRO_CREDS_FILE, KIRO_CLI_DB_FILE, or AWS_SSO_PROFILE")
+	}
+	return c.ValidateSettings()
+}
+
+// ValidateSettings checks everything Validate does except the Kiro credentials,
+// which mock mode runs without
+func (c *Config) ValidateSettings() error {
+	if _, err := c.GetVPNProxyURL(); err != nil {
+		return err
+	}
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		return fmt.Errorf("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+	if c.TLSClientCAFile != "" && !c.TLSEnabled() {
+		return fmt.Errorf("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE/TLS_KEY_FILE or TLS_SELF_SIGNED")
+	}
+	for _, pattern := range c.SystemPromptStripPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid system_prompt_strip_patterns entry %q: %v", pattern, err)
+		}
+	}
+	for name, text := range c.SystemPromptTemplates {
+		if _, err := template.New(name).Parse(text); err != nil {
+			return fmt.Errorf("invalid system_prompt_templates entry %q: %v", name, err)
+		}
+	}
+	for pattern := range c.ModelFallbackChains {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid model_fallback_chains key %q: %v", pattern, err)
+		}
+	}
+	for _, spec := range c.FakeReasoningOpenTags {
+		if _, _, err := parser.ParseThinkingTag(spec); err != nil {
+			return fmt.Errorf("FAKE_REASONING_OPEN_TAGS: %v", err)
+		}
+	}
+	for pattern, profile := range c.ModelProfiles {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid model_profiles key %q: %v", pattern, err)
+		}
+		if profile.DefaultMaxTokens < 0 || profile.MaxOutputTokens < 0 {
+			return fmt.Errorf("model_profiles entry %q: token limits must not be negative", pattern)
+		}
+		for _, spec := range profile.ThinkingTags {
+			if _, _, err := parser.ParseThinkingTag(spec); err != nil {
+				return fmt.Errorf("model_profiles entry %q: %v", pattern, err)
+			}
+		}
+	}
+	if err := c.validatePIIPolicy("PII_FILTER", PIIPolicy{Mode: c.PIIFilterMode, Patterns: c.PIIFilterPatterns}); err != nil {
+		return err
+	}
+	// The API keys are secrets and are left out of the error
+	for _, policy := range c.PIIFilterKeys {
+		if err := c.validatePIIPolicy("pii_filter_keys entry", policy); err != nil {
+			return err
+		}
+	}
+	for _, path := range c.AuthExemptPaths {
+		if !strings.HasPrefix(path, "/") {
+			return fmt.Errorf("invalid AUTH_EXEMPT_PATHS entry %q: expected a path starting with /", path)
+		}
+	}
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("invalid TRUSTED_PROXIES entry %q: expected an IP address or CIDR", proxy)
+		}
+	}
+	if c.WebSocketIdleTimeout < 0 {
+		return fmt.Errorf("WEBSOCKET_IDLE_TIMEOUT must not be negative, got %v", c.WebSocketIdleTimeout)
+	}
+	if c.RequestTimeout < 0 {
+		return fmt.Errorf("REQUEST_TIMEOUT must not be negative, got %v", c.RequestTimeout)
+	}
+	if c.BatchRetention < 0 {
+		return fmt.Errorf("BATCH_RETENTION must not be negative, got %v", c.BatchRetention)
+	}
+	if c.ConversationStoreTTL < 0 {
+		return fmt.Errorf("CONVERSATION_STORE_TTL must not be negative, got %v", c.ConversationStoreTTL)
+	}
+	switch c.TranscriptFormat {
+	case "", "markdown", "jsonl", "both":
+	default:
+		return fmt.Errorf("TRANSCRIPT_FORMAT must be \"markdown\", \"jsonl\" or \"both\", got %q", c.TranscriptFormat)
+	}
+	if c.SlowRequestThreshold < 0 {
+		return fmt.Errorf("SLOW_REQUEST_THRESHOLD must not be negative, got %v", c.SlowRequestThreshold)
+	}
+	if c.CircuitBreakerFailureRate < 0 || c.CircuitBreakerFailureRate > 1 {
+		return fmt.Errorf("CIRCUIT_BREAKER_FAILURE_RATE must be between 0 and 1, got %v", c.CircuitBreakerFailureRate)
+	}
+	if c.SummarizeThreshold < 0 || c.SummarizeThreshold > 100 {
+		return fmt.Errorf("SUMMARIZE_THRESHOLD must be between 0 and 100, got %v", c.SummarizeThreshold)
+	}
+	if c.SummarizeThreshold > 0 && c.SummarizeKeepMessages < 1 {
+		return fmt.Errorf("SUMMARIZE_KEEP_MESSAGES must be at least 1, got %d", c.SummarizeKeepMessages)
+	}
+	if c.DraftMaxPromptTokens < 0 {
+		return fmt.Errorf("DRAFT_MAX_PROMPT_TOKENS must not be negative, got %d", c.DraftMaxPromptTokens)
+	}
+	switch c.HTTPRequestCompression {
+	case "", "none", "gzip":
+	default:
+		return fmt.Errorf("HTTP_REQUEST_COMPRESSION must be \"none\" or \"gzip\", got %q", c.HTTPRequestCompression)
+	}
+	if !validAuditRedaction(c.AuditRedaction) {
+		return fmt.Errorf("AUDIT_REDACTION must be \"metadata\", \"pii\", \"full\" or \"off\", got %q", c.AuditRedaction)
+	}
+	for _, level := range c.AuditKeys {
+		if !validAuditRedaction(level) {
+			return fmt.Errorf("audit_keys levels must be \"metadata\", \"pii\", \"full\" or \"off\", got %q", level)
+		}
+	}
+	switch c.ContextTrimStrategy {
+	case "", "off", "drop", "truncate_middle", "error":
+	default:
+		return fmt.Errorf("CONTEXT_TRIM_STRATEGY must be \"drop\", \"truncate_middle\", \"error\" or \"off\", got %q", c.ContextTrimStrategy)
+	}
+	switch c.ConversationIDMode {
+	case "", "random", "header", "auto":
+	default:
+		return fmt.Errorf("CONVERSATION_ID_MODE must be \"random\", \"header\" or \"auto\", got %q", c.ConversationIDMode)
+	}
+	switch c.UnsupportedParams {
+	case "", "silent", "warn", "reject":
+	default:
+		return fmt.Errorf("UNSUPPORTED_PARAMS must be \"silent\", \"warn\" or \"reject\", got %q", c.UnsupportedParams)
+	}
+	switch c.EmbeddingsBackend {
+	case "", "lexical":
+	case "proxy":
+		if c.EmbeddingsURL == "" {
+			return fmt.Errorf("EMBEDDINGS_BACKEND=proxy requires EMBEDDINGS_URL")
+		}
+	default:
+		return fmt.Errorf("EMBEDDINGS_BACKEND must be \"proxy\" or \"lexical\", got %q", c.EmbeddingsBackend)
+	}
+	return nil
+}
+
+func validAuditRedaction(level string) bool {
+	switch level {
+	case "", "off", "metadata", "pii", "full":
+		return true
+	}
+	return false
+}
+
+// validatePIIPolicy checks the mode of a PII filter policy and that its patterns
+// are builtin or valid custom patterns
+func (c *Config) validatePIIPolicy(name string, policy PIIPolicy) error {
+	if !piifilter.ValidMode(policy.Mode) {
+		return fmt.Errorf("%s mode must be \"off\", \"redact\", \"block\" or \"log\", got %q", name, policy.Mode)
+	}
+	if _, err := piifilter.New(policy.Patterns, c.PIIFilterCustomPatterns); err != nil {
+		return fmt.Errorf("%s: %v", name, err)
 	}
 	return nil
 }
+
+// TLSEnabled reports whether the listener serves HTTPS
+func (c *Config) TLSEnabled() bool {
+	return c.TLSCertFile != "" || c.TLSSelfSigned
+}
+
+// GetVPNProxyURL parses VPN_PROXY_URL, defaulting to http:// when no scheme is given.
+// It returns nil when no proxy is configured.
+func (c *Config) GetVPNProxyURL() (*url.URL, error) {
+	if c.VPNProxyURL == "" {
+		return nil, nil
+	}
+
+	raw := c.VPNProxyURL
+	if !strings.Contains(raw, "://") {
+		raw = "http://" + raw
+	}
+	proxy, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VPN_PROXY_URL: %w", err)
+	}
+
+	switch proxy.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("invalid VPN_PROXY_URL: unsupported scheme '%s' (use http, https or socks5)", proxy.Scheme)
+	}
+	if proxy.Host == "" {
+		return nil, fmt.Errorf("invalid VPN_PROXY_URL: missing host")
+	}
+	return proxy, nil
+}
@@ -20,6 +20,7 @@ func TestConfigDefaults(t *testing.T) {
 		"SERVER_HOST", "SERVER_PORT", "PROXY_API_KEY", "KIRO_REGION",
 		"TOKEN_REFRESH_THRESHOLD", "MAX_RETRIES", "MODEL_CACHE_TTL",
 		"FIRST_TOKEN_TIMEOUT", "FAKE_REASONING",
+		"IMAGE_FETCH_ENABLED", "IMAGE_FETCH_ALLOWED_TYPES",
 	}
 	for _, key := range envKeys {
 		oldEnv[key] = os.Getenv(key)
@@ -37,7 +38,8 @@ func TestConfigDefaults(t *testing.T) {
 
 	// Reset global config
 	globalConfig = nil
-	cfg := Load()
+	cfg, err := Load()
+	assert.NoError(t, err)
 
 	t.Run("default server settings", func(t *testing.T) {
 		assert.Equal(t, "0.0.0.0", cfg.ServerHost)
@@ -50,16 +52,101 @@ func TestConfigDefaults(t *testing.T) {
 
 	t.Run("default timeout settings", func(t *testing.T) {
 		assert.Equal(t, 600, cfg.TokenRefreshThreshold)
+		assert.Equal(t, 5, cfg.KiroCLIDBWatchInterval)
+		assert.Equal(t, 30.0, cfg.StreamingWriteTimeout)
 		assert.Equal(t, 3, cfg.MaxRetries)
 		assert.Equal(t, 3600, cfg.ModelCacheTTL)
 		assert.Equal(t, 15.0, cfg.FirstTokenTimeout)
 	})
 
+	t.Run("auth lockout is off by default", func(t *testing.T) {
+		assert.Equal(t, 0, cfg.AuthMaxFailures)
+		assert.Equal(t, 300, cfg.AuthFailureWindow)
+	})
+
+	t.Run("default image limit", func(t *testing.T) {
+		assert.Equal(t, 20, cfg.MaxImages)
+	})
+
 	t.Run("default fake reasoning settings", func(t *testing.T) {
 		assert.True(t, cfg.FakeReasoningEnabled)
 		assert.Equal(t, 4000, cfg.FakeReasoningMaxTokens)
 		assert.Equal(t, "as_reasoning_content", cfg.FakeReasoningHandling)
 		assert.Equal(t, 20, cfg.FakeReasoningBufferSize)
+		assert.False(t, cfg.FakeReasoningEnforceBudget)
+		assert.Empty(t, cfg.FakeReasoningBudgetMarker)
+		assert.True(t, cfg.StripHistoryReasoning)
+	})
+
+	t.Run("default summarize settings", func(t *testing.T) {
+		assert.Equal(t, float64(0), cfg.SummarizeThreshold)
+		assert.Equal(t, "claude-haiku-4.5", cfg.SummarizeModel)
+		assert.Equal(t, 6, cfg.SummarizeKeepMessages)
+	})
+
+	t.Run("default image fetch settings", func(t *testing.T) {
+		assert.False(t, cfg.ImageFetchEnabled)
+		assert.Equal(t, 5*1024*1024, cfg.ImageFetchMaxBytes)
+		assert.Equal(t, []string{"image/jpeg", "image/png", "image/gif", "image/webp"}, cfg.ImageFetchAllowedTypes)
+	})
+
+	t.Run("default agentic settings", func(t *testing.T) {
+		// Builtin tools run on the proxy host and must be enabled explicitly
+		assert.Empty(t, cfg.AgenticTools)
+		assert.Equal(t, 10, cfg.AgenticMaxSteps)
+	})
+
+	t.Run("default idempotency settings", func(t *testing.T) {
+		assert.Equal(t, float64(86400), cfg.IdempotencyTTL)
+		assert.Equal(t, 1000, cfg.IdempotencyMaxEntries)
+	})
+
+	t.Run("default PII filter settings", func(t *testing.T) {
+		assert.Equal(t, "off", cfg.PIIFilterMode)
+		assert.Equal(t, []string{"email", "aws_access_key", "aws_secret_key", "credit_card"}, cfg.PIIFilterPatterns)
+	})
+
+	t.Run("paranoid logging is off by default", func(t *testing.T) {
+		assert.False(t, cfg.ParanoidLogging)
+	})
+
+	t.Run("default auth exemptions", func(t *testing.T) {
+		assert.Empty(t, cfg.AuthExemptPaths)
+		assert.False(t, cfg.HealthAuth)
+	})
+
+	t.Run("default request body settings", func(t *testing.T) {
+		assert.False(t, cfg.HTTPStreamRequests)
+		assert.Equal(t, "none", cfg.HTTPRequestCompression)
+	})
+
+	t.Run("default draft routing settings", func(t *testing.T) {
+		assert.False(t, cfg.DraftRouting)
+		assert.Equal(t, "claude-haiku-4.5", cfg.DraftModel)
+		assert.Equal(t, 4000, cfg.DraftMaxPromptTokens)
+	})
+
+	t.Run("ended batches are kept 29 days", func(t *testing.T) {
+		assert.Equal(t, 2505600.0, cfg.BatchRetention)
+	})
+
+	t.Run("conversation store is disabled by default", func(t *testing.T) {
+		assert.Equal(t, "", cfg.ConversationStoreDir)
+		assert.Equal(t, 86400.0, cfg.ConversationStoreTTL)
+	})
+
+	t.Run("transcripts are disabled by default", func(t *testing.T) {
+		assert.Equal(t, "", cfg.TranscriptDir)
+		assert.Equal(t, "markdown", cfg.TranscriptFormat)
+	})
+
+	t.Run("slow request log is disabled by default", func(t *testing.T) {
+		assert.Equal(t, 0.0, cfg.SlowRequestThreshold)
+	})
+
+	t.Run("default audit settings", func(t *testing.T) {
+		assert.Equal(t, "", cfg.AuditFile)
+		assert.Equal(t, "pii", cfg.AuditRedaction)
 	})
 
 	t.Run("default hidden models", func(t *testing.T) {
@@ -103,21 +190,32 @@ func TestConfigEnvOverride(t *testing.T) {
 	t.Run("overrides server host from env", func(t *testing.T) {
 		os.Setenv("SERVER_HOST", "127.0.0.1")
 		globalConfig = nil
-		cfg := Load()
+		cfg, err := Load()
+		assert.NoError(t, err)
 		assert.Equal(t, "127.0.0.1", cfg.ServerHost)
 	})
 
 	t.Run("overrides server port from env", func(t *testing.T) {
 		os.Setenv("SERVER_PORT", "3000")
 		globalConfig = nil
-		cfg := Load()
+		cfg, err := Load()
+		assert.NoError(t, err)
 		assert.Equal(t, 3000, cfg.ServerPort)
 	})
 
+	t.Run("overrides fake reasoning tags from env", func(t *testing.T) {
+		t.Setenv("FAKE_REASONING_OPEN_TAGS", "<think>, [[R]]|[[/R]]")
+		globalConfig = nil
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"<think>", "[[R]]|[[/R]]"}, cfg.FakeReasoningOpenTags)
+	})
+
 	t.Run("overrides region from env", func(t *testing.T) {
 		os.Setenv("KIRO_REGION", "eu-west-1")
 		globalConfig = nil
-		cfg := Load()
+		cfg, err := Load()
+		assert.NoError(t, err)
 		assert.Equal(t, "eu-west-1", cfg.Region)
 	})
 }
@@ -223,6 +321,133 @@ func TestConfigValidate(t *testing.T) {
 		err := cfg.Validate()
 		assert.NoError(t, err)
 	})
+
+	t.Run("settings validation skips credentials", func(t *testing.T) {
+		assert.NoError(t, (&Config{}).ValidateSettings())
+		assert.Error(t, (&Config{TLSCertFile: "cert.pem"}).ValidateSettings())
+	})
+
+	t.Run("embeddings backend", func(t *testing.T) {
+		assert.NoError(t, (&Config{EmbeddingsBackend: "lexical"}).ValidateSettings())
+		assert.NoError(t, (&Config{EmbeddingsBackend: "proxy", EmbeddingsURL: "https://api.openai.com/v1"}).ValidateSettings())
+		assert.Error(t, (&Config{EmbeddingsBackend: "proxy"}).ValidateSettings())
+		assert.Error(t, (&Config{EmbeddingsBackend: "remote"}).ValidateSettings())
+		assert.Error(t, (&Config{EmbeddingsBackend: "local"}).ValidateSettings())
+	})
+
+	t.Run("system prompt policy", func(t *testing.T) {
+		assert.NoError(t, (&Config{SystemPromptStripPatterns: []string{`(?i)ignore previous`}, SystemPromptTemplates: map[string]string{"*": "{{.System}}"}}).ValidateSettings())
+		assert.Error(t, (&Config{SystemPromptStripPatterns: []string{`(`}}).ValidateSettings())
+		assert.Error(t, (&Config{SystemPromptTemplates: map[string]string{"*": "{{.System"}}).ValidateSettings())
+	})
+
+	t.Run("context trim strategy", func(t *testing.T) {
+		for _, strategy := range []string{"", "off", "drop", "truncate_middle", "error"} {
+			assert.NoError(t, (&Config{ContextTrimStrategy: strategy}).ValidateSettings(), strategy)
+		}
+		assert.Error(t, (&Config{ContextTrimStrategy: "summarize"}).ValidateSettings())
+	})
+
+	t.Run("summarize settings", func(t *testing.T) {
+		assert.NoError(t, (&Config{SummarizeThreshold: 80, SummarizeKeepMessages: 6}).ValidateSettings())
+		assert.Error(t, (&Config{SummarizeThreshold: 120, SummarizeKeepMessages: 6}).ValidateSettings())
+		assert.Error(t, (&Config{SummarizeThreshold: -1}).ValidateSettings())
+		assert.Error(t, (&Config{SummarizeThreshold: 80}).ValidateSettings())
+	})
+
+	t.Run("conversation ID mode", func(t *testing.T) {
+		assert.NoError(t, (&Config{ConversationIDMode: "auto"}).ValidateSettings())
+		assert.Error(t, (&Config{ConversationIDMode: "sticky"}).ValidateSettings())
+	})
+
+	t.Run("unsupported params mode", func(t *testing.T) {
+		for _, mode := range []string{"", "silent", "warn", "reject"} {
+			assert.NoError(t, (&Config{UnsupportedParams: mode}).ValidateSettings(), mode)
+		}
+		assert.Error(t, (&Config{UnsupportedParams: "emulate"}).ValidateSettings())
+	})
+
+	t.Run("model fallback chains", func(t *testing.T) {
+		valid := &Config{ModelFallbackChains: map[string][]string{"claude-opus-*": {"claude-sonnet-4.5"}}}
+		assert.NoError(t, valid.ValidateSettings())
+		invalid := &Config{ModelFallbackChains: map[string][]string{"claude-[": {"claude-sonnet-4.5"}}}
+		assert.Error(t, invalid.ValidateSettings())
+	})
+
+	t.Run("model profiles", func(t *testing.T) {
+		valid := &Config{ModelProfiles: map[string]ModelProfile{"claude-opus-*": {DefaultMaxTokens: 4096, MaxOutputTokens: 8192}}}
+		assert.NoError(t, valid.ValidateSettings())
+		assert.Error(t, (&Config{ModelProfiles: map[string]ModelProfile{"claude-[": {}}}).ValidateSettings())
+		assert.Error(t, (&Config{ModelProfiles: map[string]ModelProfile{"*": {MaxOutputTokens: -1}}}).ValidateSettings())
+		assert.Error(t, (&Config{ModelProfiles: map[string]ModelProfile{"gemini-*": {ThinkingTags: []string{"<thought>|"}}}}).ValidateSettings())
+	})
+
+	t.Run("fake reasoning tags", func(t *testing.T) {
+		assert.NoError(t, (&Config{FakeReasoningOpenTags: []string{"<think>", "[[R]]|[[/R]]"}}).ValidateSettings())
+		assert.Error(t, (&Config{FakeReasoningOpenTags: []string{"|</think>"}}).ValidateSettings())
+	})
+
+	t.Run("PII filter", func(t *testing.T) {
+		assert.NoError(t, (&Config{PIIFilterMode: "redact", PIIFilterPatterns: []string{"email", "badge"},
+			PIIFilterCustomPatterns: map[string]string{"badge": `B-\d+`}}).ValidateSettings())
+		assert.Error(t, (&Config{PIIFilterMode: "mask"}).ValidateSettings())
+		assert.Error(t, (&Config{PIIFilterPatterns: []string{"phone"}}).ValidateSettings())
+		assert.Error(t, (&Config{PIIFilterPatterns: []string{"badge"}, PIIFilterCustomPatterns: map[string]string{"badge": "("}}).ValidateSettings())
+		assert.Error(t, (&Config{PIIFilterKeys: map[string]PIIPolicy{"team": {Mode: "drop"}}}).ValidateSettings())
+	})
+
+	t.Run("request compression", func(t *testing.T) {
+		assert.NoError(t, (&Config{HTTPRequestCompression: "gzip"}).ValidateSettings())
+		assert.Error(t, (&Config{HTTPRequestCompression: "br"}).ValidateSettings())
+	})
+
+	t.Run("draft max prompt tokens", func(t *testing.T) {
+		assert.NoError(t, (&Config{DraftMaxPromptTokens: 0}).ValidateSettings())
+		assert.Error(t, (&Config{DraftMaxPromptTokens: -1}).ValidateSettings())
+	})
+
+	t.Run("audit redaction", func(t *testing.T) {
+		assert.NoError(t, (&Config{AuditRedaction: "full", AuditKeys: map[string]string{"team": "off"}}).ValidateSettings())
+		assert.Error(t, (&Config{AuditRedaction: "partial"}).ValidateSettings())
+		assert.Error(t, (&Config{AuditKeys: map[string]string{"team": "all"}}).ValidateSettings())
+	})
+
+	t.Run("auth exempt paths", func(t *testing.T) {
+		assert.NoError(t, (&Config{AuthExemptPaths: []string{"/v1/models", "/v1/models/*"}}).ValidateSettings())
+		assert.Error(t, (&Config{AuthExemptPaths: []string{"v1/models"}}).ValidateSettings())
+	})
+
+	t.Run("trusted proxies", func(t *testing.T) {
+		assert.NoError(t, (&Config{TrustedProxies: []string{"127.0.0.1", "10.0.0.0/8", "::1"}}).ValidateSettings())
+		assert.Error(t, (&Config{TrustedProxies: []string{"proxy.internal"}}).ValidateSettings())
+	})
+
+	t.Run("batch retention", func(t *testing.T) {
+		assert.NoError(t, (&Config{BatchRetention: 0}).ValidateSettings())
+		assert.Error(t, (&Config{BatchRetention: -1}).ValidateSettings())
+	})
+
+	t.Run("conversation store TTL", func(t *testing.T) {
+		assert.NoError(t, (&Config{ConversationStoreTTL: 0}).ValidateSettings())
+		assert.Error(t, (&Config{ConversationStoreTTL: -1}).ValidateSettings())
+	})
+
+	t.Run("transcript format", func(t *testing.T) {
+		for _, format := range []string{"", "markdown", "jsonl", "both"} {
+			assert.NoError(t, (&Config{TranscriptFormat: format}).ValidateSettings(), format)
+		}
+		assert.Error(t, (&Config{TranscriptFormat: "html"}).ValidateSettings())
+	})
+
+	t.Run("slow request threshold", func(t *testing.T) {
+		assert.NoError(t, (&Config{SlowRequestThreshold: 30}).ValidateSettings())
+		assert.Error(t, (&Config{SlowRequestThreshold: -1}).ValidateSettings())
+	})
+
+	t.Run("circuit breaker failure rate", func(t *testing.T) {
+		assert.NoError(t, (&Config{CircuitBreakerFailureRate: 0.5}).ValidateSettings())
+		assert.Error(t, (&Config{CircuitBreakerFailureRate: 1.5}).ValidateSettings())
+	})
 }
 
 // =============================================================================
@@ -231,6 +456,18 @@ func TestConfigValidate(t *testing.T) {
 // =============================================================================
 
 func TestEnvHelpers(t *testing.T) {
+	t.Run("getEnvList splits and trims values", func(t *testing.T) {
+		os.Setenv("TEST_LIST", " a, b ,,c ")
+		defer os.Unsetenv("TEST_LIST")
+		result := getEnvList("TEST_LIST")
+		assert.Equal(t, []string{"a", "b", "c"}, result)
+	})
+
+	t.Run("getEnvList returns nil when not set", func(t *testing.T) {
+		os.Unsetenv("TEST_LIST")
+		assert.Nil(t, getEnvList("TEST_LIST"))
+	})
+
 	t.Run("getEnvString returns default when not set", func(t *testing.T) {
 		os.Unsetenv("TEST_STRING")
 		result := getEnvString("TEST_STRING", "default")
@@ -299,6 +536,153 @@ func TestEnvHelpers(t *testing.T) {
 		assert.False(t, result)
 		os.Unsetenv("TEST_BOOL")
 	})
+
+	t.Run("getEnvPIIPolicies parses per-key modes", func(t *testing.T) {
+		os.Setenv("TEST_PII_POLICIES", "key-a:block, key-b:off,bad")
+		defer os.Unsetenv("TEST_PII_POLICIES")
+		result := getEnvPIIPolicies("TEST_PII_POLICIES", nil)
+		assert.Equal(t, map[string]PIIPolicy{
+			"key-a": {Mode: "block"},
+			"key-b": {Mode: "off"},
+		}, result)
+	})
+
+	t.Run("getEnvKeyValues parses per-key values", func(t *testing.T) {
+		os.Setenv("TEST_KEY_VALUES", "key-a:full, key-b:off,bad")
+		defer os.Unsetenv("TEST_KEY_VALUES")
+		result := getEnvKeyValues("TEST_KEY_VALUES", nil)
+		assert.Equal(t, map[string]string{"key-a": "full", "key-b": "off"}, result)
+	})
+
+	t.Run("getEnvKeyBools parses per-key switches", func(t *testing.T) {
+		os.Setenv("TEST_KEY_BOOLS", "key-a:on, key-b:false,key-c:maybe,bad")
+		defer os.Unsetenv("TEST_KEY_BOOLS")
+		result := getEnvKeyBools("TEST_KEY_BOOLS", nil)
+		assert.Equal(t, map[string]bool{"key-a": true, "key-b": false}, result)
+	})
+
+	t.Run("getEnvRateLimits parses per-key limits", func(t *testing.T) {
+		os.Setenv("TEST_RATE_LIMITS", "key-a:60:2, key-b:0:5,bad,key-c:x:1")
+		defer os.Unsetenv("TEST_RATE_LIMITS")
+		result := getEnvRateLimits("TEST_RATE_LIMITS", nil)
+		assert.Equal(t, map[string]RateLimit{
+			"key-a": {RPM: 60, Concurrent: 2},
+			"key-b": {RPM: 0, Concurrent: 5},
+		}, result)
+	})
+}
+
+// =============================================================================
+// TestAPIKeys
+// Tests for the client API keys and per-key settings naming unknown keys
+// =============================================================================
+
+func TestAPIKeys(t *testing.T) {
+	t.Run("combines PROXY_API_KEY and PROXY_API_KEYS", func(t *testing.T) {
+		cfg := &Config{ProxyAPIKey: "main", ProxyAPIKeys: []string{"team-a", "", "team-b"}}
+		assert.Equal(t, []string{"main", "team-a", "team-b"}, cfg.APIKeys())
+		assert.Empty(t, (&Config{}).APIKeys())
+	})
+
+	t.Run("reports per-key settings for unknown keys", func(t *testing.T) {
+		cfg := &Config{
+			ProxyAPIKey:   "main",
+			ProxyAPIKeys:  []string{"team-a"},
+			RateLimitKeys: map[string]RateLimit{"team-a": {RPM: 10}},
+			AuditKeys:     map[string]string{"main": "full", "typo": "off"},
+		}
+		assert.Equal(t, []string{"AUDIT_KEYS"}, cfg.UnknownAPIKeys())
+	})
+
+	t.Run("reads PROXY_API_KEYS", func(t *testing.T) {
+		t.Setenv("PROXY_API_KEYS", "team-a, team-b")
+		cfg, err := Load()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"team-a", "team-b"}, cfg.ProxyAPIKeys)
+	})
+}
+
+// =============================================================================
+// TestRateLimitFor
+// Tests for per API key rate limit lookup
+// =============================================================================
+
+func TestRateLimitFor(t *testing.T) {
+	cfg := &Config{
+		RateLimitRPM:        10,
+		RateLimitConcurrent: 2,
+		RateLimitKeys:       map[string]RateLimit{"vip": {RPM: 100, Concurrent: 0}},
+	}
+
+	t.Run("returns per-key override", func(t *testing.T) {
+		assert.Equal(t, RateLimit{RPM: 100, Concurrent: 0}, cfg.RateLimitFor("vip"))
+	})
+
+	t.Run("falls back to global limits", func(t *testing.T) {
+		assert.Equal(t, RateLimit{RPM: 10, Concurrent: 2}, cfg.RateLimitFor("other"))
+	})
+}
+
+// =============================================================================
+// TestPIIPolicyFor
+// Tests for per API key PII filter policy lookup
+// =============================================================================
+
+func TestPIIPolicyFor(t *testing.T) {
+	cfg := &Config{
+		PIIFilterMode:     "log",
+		PIIFilterPatterns: []string{"email"},
+		PIIFilterKeys: map[string]PIIPolicy{
+			"strict":   {Mode: "block", Patterns: []string{"email", "credit_card"}},
+			"redacted": {Mode: "redact"},
+		},
+	}
+
+	t.Run("returns per-key policy", func(t *testing.T) {
+		assert.Equal(t, PIIPolicy{Mode: "block", Patterns: []string{"email", "credit_card"}}, cfg.PIIPolicyFor("strict"))
+	})
+
+	t.Run("fills in the global patterns", func(t *testing.T) {
+		assert.Equal(t, PIIPolicy{Mode: "redact", Patterns: []string{"email"}}, cfg.PIIPolicyFor("redacted"))
+	})
+
+	t.Run("falls back to the global policy", func(t *testing.T) {
+		assert.Equal(t, PIIPolicy{Mode: "log", Patterns: []string{"email"}}, cfg.PIIPolicyFor("other"))
+	})
+}
+
+// =============================================================================
+// TestDraftRoutingFor
+// Tests for per API key draft routing lookup
+// =============================================================================
+
+func TestDraftRoutingFor(t *testing.T) {
+	cfg := &Config{DraftRouting: true, DraftRoutingKeys: map[string]bool{"batch": false}}
+
+	t.Run("returns per-key setting", func(t *testing.T) {
+		assert.False(t, cfg.DraftRoutingFor("batch"))
+	})
+
+	t.Run("falls back to DRAFT_ROUTING", func(t *testing.T) {
+		assert.True(t, cfg.DraftRoutingFor("ide"))
+	})
+}
+
+// =============================================================================
+// TestAuditRedactionFor
+// Tests for per API key audit redaction lookup
+// =============================================================================
+
+func TestAuditRedactionFor(t *testing.T) {
+	cfg := &Config{AuditRedaction: "metadata", AuditKeys: map[string]string{"legal": "full"}}
+
+	t.Run("returns per-key level", func(t *testing.T) {
+		assert.Equal(t, "full", cfg.AuditRedactionFor("legal"))
+	})
+
+	t.Run("falls back to AUDIT_REDACTION", func(t *testing.T) {
+		assert.Equal(t, "metadata", cfg.AuditRedactionFor("other"))
+	})
 }
 
 // =============================================================================
@@ -309,14 +693,88 @@ func TestEnvHelpers(t *testing.T) {
 func TestGetGlobalConfig(t *testing.T) {
 	t.Run("Get returns loaded config", func(t *testing.T) {
 		globalConfig = nil
-		cfg := Get()
+		cfg, err := Get()
+		assert.NoError(t, err)
 		assert.NotNil(t, cfg)
 	})
 
+	t.Run("Get returns the config file error", func(t *testing.T) {
+		globalConfig = nil
+		t.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yaml", "server_port: [not, a, port]\n"))
+
+		cfg, err := Get()
+
+		assert.Error(t, err)
+		assert.Nil(t, cfg)
+	})
+
 	t.Run("Get returns same instance", func(t *testing.T) {
 		globalConfig = nil
-		cfg1 := Get()
-		cfg2 := Get()
+		cfg1, _ := Get()
+		cfg2, _ := Get()
 		assert.Equal(t, cfg1, cfg2)
 	})
 }
+
+// =============================================================================
+// TestGetVPNProxyURL
+// Tests for VPN_PROXY_URL parsing and validation
+// =============================================================================
+
+func TestGetVPNProxyURL(t *testing.T) {
+	t.Run("returns nil when not set", func(t *testing.T) {
+		proxy, err := (&Config{}).GetVPNProxyURL()
+		assert.NoError(t, err)
+		assert.Nil(t, proxy)
+	})
+
+	t.Run("defaults to http scheme", func(t *testing.T) {
+		proxy, err := (&Config{VPNProxyURL: "user:pass@proxy:8080"}).GetVPNProxyURL()
+		assert.NoError(t, err)
+		assert.Equal(t, "http", proxy.Scheme)
+		assert.Equal(t, "proxy:8080", proxy.Host)
+	})
+
+	t.Run("accepts https and socks5", func(t *testing.T) {
+		for _, raw := range []string{"https://proxy:443", "socks5://proxy:1080", "socks5h://proxy:1080"} {
+			_, err := (&Config{VPNProxyURL: raw}).GetVPNProxyURL()
+			assert.NoError(t, err, raw)
+		}
+	})
+
+	t.Run("rejects unsupported scheme", func(t *testing.T) {
+		_, err := (&Config{VPNProxyURL: "ftp://proxy:21"}).GetVPNProxyURL()
+		assert.Error(t, err)
+	})
+
+	t.Run("validate fails on invalid proxy", func(t *testing.T) {
+		err := (&Config{RefreshToken: "token", VPNProxyURL: "ftp://proxy:21"}).Validate()
+		assert.Error(t, err)
+	})
+}
+
+// =============================================================================
+// TestTLSSettings
+// Tests for listener TLS validation
+// =============================================================================
+
+func TestTLSSettings(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		assert.False(t, (&Config{}).TLSEnabled())
+	})
+
+	t.Run("enabled by certificate or self-signed", func(t *testing.T) {
+		assert.True(t, (&Config{TLSCertFile: "cert.pem", TLSKeyFile: "key.pem"}).TLSEnabled())
+		assert.True(t, (&Config{TLSSelfSigned: true}).TLSEnabled())
+	})
+
+	t.Run("requires cert and key together", func(t *testing.T) {
+		err := (&Config{RefreshToken: "token", TLSCertFile: "cert.pem"}).Validate()
+		assert.Error(t, err)
+	})
+
+	t.Run("client CA requires TLS", func(t *testing.T) {
+		err := (&Config{RefreshToken: "token", TLSClientCAFile: "ca.pem"}).Validate()
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,157 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// applyFile overlays the YAML or JSON config file at path onto c. Keys are the
+// snake_case names from the Config yaml tags; maps and lists replace the
+// defaults rather than adding to them, so a file can also drop a default entry.
+// Errors name the offending key.
+func (c *Config) applyFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	// JSON is a subset of YAML, so one parser handles both formats
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("invalid config file %s: top level must be a mapping of settings", path)
+	}
+
+	fields := fileFields()
+	target := reflect.ValueOf(c).Elem()
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valueNode := root.Content[i], root.Content[i+1]
+		index, ok := fields[keyNode.Value]
+		if !ok {
+			return fmt.Errorf("invalid config file %s: unknown key '%s' (line %d)", path, keyNode.Value, keyNode.Line)
+		}
+		field := target.Field(index)
+		if field.Kind() == reflect.Map {
+			// Decoding into the default map would merge into it
+			field.Set(reflect.Zero(field.Type()))
+		}
+		if err := decodeStrict(valueNode, field.Addr().Interface()); err != nil {
+			return fmt.Errorf("invalid config file %s: key '%s': %v", path, keyNode.Value, err)
+		}
+	}
+	return nil
+}
+
+// decodeStrict decodes node into out, rejecting unknown keys in nested structs
+func decodeStrict(node *yaml.Node, out interface{}) error {
+	data, err := yaml.Marshal(node)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	return decoder.Decode(out)
+}
+
+// fileFields maps config file keys to Config field indexes
+func fileFields() map[string]int {
+	t := reflect.TypeOf(Config{})
+	fields := make(map[string]int, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if key := t.Field(i).Tag.Get("yaml"); key != "" {
+			fields[key] = i
+		}
+	}
+	return fields
+}
+
+// clone returns a copy of c that shares no maps or slices with it
+func (c *Config) clone() *Config {
+	out := *c
+
+	out.HiddenModels = make(map[string]string, len(c.HiddenModels))
+	for k, v := range c.HiddenModels {
+		out.HiddenModels[k] = v
+	}
+	out.ModelAliases = make(map[string]string, len(c.ModelAliases))
+	for k, v := range c.ModelAliases {
+		out.ModelAliases[k] = v
+	}
+	if c.SystemPromptTemplates != nil {
+		out.SystemPromptTemplates = make(map[string]string, len(c.SystemPromptTemplates))
+		for k, v := range c.SystemPromptTemplates {
+			out.SystemPromptTemplates[k] = v
+		}
+	}
+	if c.ModelFallbackChains != nil {
+		out.ModelFallbackChains = make(map[string][]string, len(c.ModelFallbackChains))
+		for k, v := range c.ModelFallbackChains {
+			out.ModelFallbackChains[k] = append([]string(nil), v...)
+		}
+	}
+	if c.ModelProfiles != nil {
+		out.ModelProfiles = make(map[string]ModelProfile, len(c.ModelProfiles))
+		for k, v := range c.ModelProfiles {
+			v.ThinkingTags = append([]string(nil), v.ThinkingTags...)
+			out.ModelProfiles[k] = v
+		}
+	}
+	if c.PIIFilterCustomPatterns != nil {
+		out.PIIFilterCustomPatterns = make(map[string]string, len(c.PIIFilterCustomPatterns))
+		for k, v := range c.PIIFilterCustomPatterns {
+			out.PIIFilterCustomPatterns[k] = v
+		}
+	}
+	if c.DraftRoutingKeys != nil {
+		out.DraftRoutingKeys = make(map[string]bool, len(c.DraftRoutingKeys))
+		for k, v := range c.DraftRoutingKeys {
+			out.DraftRoutingKeys[k] = v
+		}
+	}
+	if c.AuditKeys != nil {
+		out.AuditKeys = make(map[string]string, len(c.AuditKeys))
+		for k, v := range c.AuditKeys {
+			out.AuditKeys[k] = v
+		}
+	}
+	if c.PIIFilterKeys != nil {
+		out.PIIFilterKeys = make(map[string]PIIPolicy, len(c.PIIFilterKeys))
+		for k, v := range c.PIIFilterKeys {
+			v.Patterns = append([]string(nil), v.Patterns...)
+			out.PIIFilterKeys[k] = v
+		}
+	}
+	if c.RateLimitKeys != nil {
+		out.RateLimitKeys = make(map[string]RateLimit, len(c.RateLimitKeys))
+		for k, v := range c.RateLimitKeys {
+			out.RateLimitKeys[k] = v
+		}
+	}
+
+	out.ProxyAPIKeys = append([]string(nil), c.ProxyAPIKeys...)
+	out.VPNNoProxy = append([]string(nil), c.VPNNoProxy...)
+	out.TrustedProxies = append([]string(nil), c.TrustedProxies...)
+	out.WebSocketOrigins = append([]string(nil), c.WebSocketOrigins...)
+	out.RefreshTokens = append([]string(nil), c.RefreshTokens...)
+	out.KiroCredsFiles = append([]string(nil), c.KiroCredsFiles...)
+	out.KiroCLIDBFiles = append([]string(nil), c.KiroCLIDBFiles...)
+	out.HiddenFromList = append([]string(nil), c.HiddenFromList...)
+	out.FallbackModels = append([]ModelInfo(nil), c.FallbackModels...)
+	out.OTelHeaders = append([]string(nil), c.OTelHeaders...)
+	out.ImageFetchAllowedTypes = append([]string(nil), c.ImageFetchAllowedTypes...)
+	out.AgenticTools = append([]string(nil), c.AgenticTools...)
+	out.FakeReasoningOpenTags = append([]string(nil), c.FakeReasoningOpenTags...)
+	out.SystemPromptStripPatterns = append([]string(nil), c.SystemPromptStripPatterns...)
+	out.PIIFilterPatterns = append([]string(nil), c.PIIFilterPatterns...)
+	return &out
+}
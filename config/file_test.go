@@ -0,0 +1,150 @@
+// Package config provides tests for YAML/JSON config file loading.
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeConfigFile writes content to a temporary config file and returns its path
+func writeConfigFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+// =============================================================================
+// TestLoadFile
+// Tests for config file loading and precedence
+// =============================================================================
+
+func TestLoadFile(t *testing.T) {
+	for _, key := range []string{"SERVER_PORT", "LOG_LEVEL", "REFRESH_TOKENS", "CONFIG_FILE"} {
+		t.Setenv(key, "")
+		os.Unsetenv(key)
+	}
+
+	t.Run("YAML file overrides defaults", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", `
+server_port: 9000
+log_level: DEBUG
+refresh_tokens: [token-a, token-b]
+model_aliases:
+  sonnet: claude-sonnet-4.5
+hidden_from_list: []
+fallback_models:
+  - model_id: claude-sonnet-4.5
+rate_limit_keys:
+  key-a: {rpm: 60, concurrent: 2}
+`)
+		cfg, err := LoadFile(path)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 9000, cfg.ServerPort)
+		assert.Equal(t, "DEBUG", cfg.LogLevel)
+		assert.Equal(t, []string{"token-a", "token-b"}, cfg.RefreshTokens)
+		assert.Equal(t, map[string]RateLimit{"key-a": {RPM: 60, Concurrent: 2}}, cfg.RateLimitKeys)
+		assert.Equal(t, []ModelInfo{{ModelID: "claude-sonnet-4.5"}}, cfg.FallbackModels)
+		assert.Empty(t, cfg.HiddenFromList)
+		// Maps replace the defaults
+		assert.Equal(t, map[string]string{"sonnet": "claude-sonnet-4.5"}, cfg.ModelAliases)
+		// Untouched settings keep their defaults
+		assert.Equal(t, "us-east-1", cfg.Region)
+	})
+
+	t.Run("JSON file is accepted", func(t *testing.T) {
+		path := writeConfigFile(t, "config.json", `{
+	"server_port": 9100,
+	"hidden_models": {"claude-3.5-sonnet": "CLAUDE_3_5_SONNET_V1_0"}
+}`)
+		cfg, err := LoadFile(path)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 9100, cfg.ServerPort)
+		assert.Equal(t, map[string]string{"claude-3.5-sonnet": "CLAUDE_3_5_SONNET_V1_0"}, cfg.HiddenModels)
+	})
+
+	t.Run("empty map removes the defaults", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "hidden_models: {}\n")
+
+		cfg, err := LoadFile(path)
+
+		assert.NoError(t, err)
+		assert.Empty(t, cfg.HiddenModels)
+		assert.NotEmpty(t, defaults.HiddenModels)
+	})
+
+	t.Run("environment overrides file", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "server_port: 9000\nrefresh_tokens: [from-file]\n")
+		t.Setenv("SERVER_PORT", "9200")
+		t.Setenv("REFRESH_TOKENS", "from-env")
+
+		cfg, err := LoadFile(path)
+
+		assert.NoError(t, err)
+		assert.Equal(t, 9200, cfg.ServerPort)
+		assert.Equal(t, []string{"from-env"}, cfg.RefreshTokens)
+	})
+
+	t.Run("CONFIG_FILE is used when no path is given", func(t *testing.T) {
+		t.Setenv("CONFIG_FILE", writeConfigFile(t, "config.yaml", "log_level: ERROR\n"))
+
+		cfg, err := LoadFile("")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ERROR", cfg.LogLevel)
+	})
+
+	t.Run("file does not leak into defaults", func(t *testing.T) {
+		path := writeConfigFile(t, "config.yaml", "model_aliases:\n  leaked: auto\n")
+		_, err := LoadFile(path)
+		assert.NoError(t, err)
+
+		cfg, err := LoadFile("")
+
+		assert.NoError(t, err)
+		assert.NotContains(t, cfg.ModelAliases, "leaked")
+	})
+
+	t.Run("errors name the bad key", func(t *testing.T) {
+		tests := []struct {
+			name    string
+			content string
+			key     string
+		}{
+			{"unknown key", "server_port: 9000\nserver_prot: 9001\n", "server_prot"},
+			{"wrong type", "server_port: eighty\n", "server_port"},
+			{"wrong map value", "model_aliases: [a, b]\n", "model_aliases"},
+			{"unknown nested key", "rate_limit_keys:\n  key-a: {rpm: 1, burst: 2}\n", "rate_limit_keys"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := LoadFile(writeConfigFile(t, "config.yaml", tt.content))
+
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), "'"+tt.key+"'")
+			})
+		}
+	})
+
+	t.Run("invalid files are rejected", func(t *testing.T) {
+		_, err := LoadFile(filepath.Join(t.TempDir(), "missing.yaml"))
+		assert.Error(t, err)
+
+		_, err = LoadFile(writeConfigFile(t, "config.yaml", "- not\n- a mapping\n"))
+		assert.Error(t, err)
+
+		_, err = LoadFile(writeConfigFile(t, "config.json", "{\"server_port\": "))
+		assert.Error(t, err)
+	})
+
+	t.Run("empty file keeps defaults", func(t *testing.T) {
+		cfg, err := LoadFile(writeConfigFile(t, "config.yaml", ""))
+
+		assert.NoError(t, err)
+		assert.Equal(t, 8000, cfg.ServerPort)
+	})
+}
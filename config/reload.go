@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+)
+
+// reloadableKeys are the settings a running server picks up on reload. Everything
+// else (listener, credentials sources, transport, limits) requires a restart.
+var reloadableKeys = []string{
+	"proxy_api_key",
+	"proxy_api_keys",
+	"admin_api_key",
+	"auth_exempt_paths",
+	"health_auth",
+	"log_level",
+	"paranoid_logging",
+	"slow_request_threshold",
+	"model_aliases",
+	"hidden_models",
+	"hidden_from_list",
+	"fake_reasoning",
+	"fake_reasoning_max_tokens",
+	"fake_reasoning_handling",
+	"fake_reasoning_open_tags",
+	"fake_reasoning_initial_buffer_size",
+	"fake_reasoning_enforce_budget",
+	"fake_reasoning_budget_marker",
+	"strip_history_reasoning",
+	"system_prompt_prefix",
+	"system_prompt_suffix",
+	"system_prompt_strip_patterns",
+	"system_prompt_templates",
+	"context_trim_strategy",
+	"conversation_id_mode",
+	"model_fallback_chains",
+	"model_profiles",
+	"strict_validation",
+	"unsupported_params",
+	"pii_filter_mode",
+	"pii_filter_patterns",
+	"pii_filter_custom_patterns",
+	"pii_filter_keys",
+	"audit_redaction",
+	"audit_keys",
+	"summarize_threshold",
+	"summarize_model",
+	"summarize_keep_messages",
+	"draft_routing",
+	"draft_routing_keys",
+	"draft_model",
+	"draft_max_prompt_tokens",
+}
+
+// secretKeys are reported as changed without their values
+var secretKeys = map[string]bool{
+	"proxy_api_key":  true,
+	"proxy_api_keys": true,
+	"admin_api_key":  true,
+	// Keyed by API keys
+	"pii_filter_keys":    true,
+	"audit_keys":         true,
+	"draft_routing_keys": true,
+}
+
+// Reload returns a copy of c with the reloadable settings taken from next, and a
+// description of each change. c itself is not modified, so requests holding it keep
+// their settings.
+func (c *Config) Reload(next *Config) (*Config, []string) {
+	out := c.clone()
+	fields := fileFields()
+	src := reflect.ValueOf(next).Elem()
+	dst := reflect.ValueOf(out).Elem()
+
+	var changes []string
+	for _, key := range reloadableKeys {
+		oldValue, newValue := dst.Field(fields[key]), src.Field(fields[key])
+		if equalSetting(oldValue, newValue) {
+			continue
+		}
+		changes = append(changes, describeChange(key, oldValue, newValue)...)
+		dst.Field(fields[key]).Set(newValue)
+	}
+	return out, changes
+}
+
+// equalSetting compares two values, treating nil and empty maps and slices as equal
+func equalSetting(a, b reflect.Value) bool {
+	switch a.Kind() {
+	case reflect.Map, reflect.Slice:
+		if a.Len() == 0 && b.Len() == 0 {
+			return true
+		}
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
+
+// describeChange formats a changed setting for the reload log
+func describeChange(key string, oldValue, newValue reflect.Value) []string {
+	if secretKeys[key] {
+		return []string{key + ": changed"}
+	}
+
+	oldMap, isMap := oldValue.Interface().(map[string]string)
+	if !isMap {
+		return []string{fmt.Sprintf("%s: %v -> %v", key, oldValue.Interface(), newValue.Interface())}
+	}
+	newMap := newValue.Interface().(map[string]string)
+
+	names := make([]string, 0, len(oldMap)+len(newMap))
+	for name := range oldMap {
+		names = append(names, name)
+	}
+	for name := range newMap {
+		if _, ok := oldMap[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []string
+	for _, name := range names {
+		before, hadBefore := oldMap[name]
+		after, hasAfter := newMap[name]
+		switch {
+		case !hadBefore:
+			changes = append(changes, fmt.Sprintf("%s[%s]: added '%s'", key, name, after))
+		case !hasAfter:
+			changes = append(changes, fmt.Sprintf("%s[%s]: removed '%s'", key, name, before))
+		case before != after:
+			changes = append(changes, fmt.Sprintf("%s[%s]: '%s' -> '%s'", key, name, before, after))
+		}
+	}
+	return changes
+}
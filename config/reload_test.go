@@ -0,0 +1,93 @@
+// Package config provides tests for configuration reload.
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestReload
+// Tests for applying reloadable settings and describing changes
+// =============================================================================
+
+func TestReload(t *testing.T) {
+	current := defaults.clone()
+	current.ProxyAPIKey = "old-key"
+	current.ServerPort = 8000
+
+	t.Run("applies reloadable settings and reports changes", func(t *testing.T) {
+		next := current.clone()
+		next.ProxyAPIKey = "new-key"
+		next.LogLevel = "DEBUG"
+		next.FakeReasoningEnabled = false
+		next.ModelAliases = map[string]string{"auto-kiro": "claude-sonnet-4.5", "sonnet": "claude-sonnet-4.5"}
+		next.HiddenModels = map[string]string{}
+
+		reloaded, changes := current.Reload(next)
+
+		assert.Equal(t, "new-key", reloaded.ProxyAPIKey)
+		assert.Equal(t, "DEBUG", reloaded.LogLevel)
+		assert.False(t, reloaded.FakeReasoningEnabled)
+		assert.Equal(t, next.ModelAliases, reloaded.ModelAliases)
+		assert.Equal(t, []string{
+			"proxy_api_key: changed",
+			"log_level: INFO -> DEBUG",
+			"model_aliases[auto-kiro]: 'auto' -> 'claude-sonnet-4.5'",
+			"model_aliases[sonnet]: added 'claude-sonnet-4.5'",
+			"hidden_models[claude-3.7-sonnet]: removed 'CLAUDE_3_7_SONNET_20250219_V1_0'",
+			"fake_reasoning: true -> false",
+		}, changes)
+
+		// The running config is left untouched for in-flight requests
+		assert.Equal(t, "old-key", current.ProxyAPIKey)
+		assert.Equal(t, "auto", current.ModelAliases["auto-kiro"])
+	})
+
+	t.Run("ignores settings that require a restart", func(t *testing.T) {
+		next := current.clone()
+		next.ServerPort = 9000
+		next.MaxRetries = 10
+		next.HTTPMaxIdleConns = 5
+		next.VPNProxyURL = "http://proxy:8080"
+
+		reloaded, changes := current.Reload(next)
+
+		assert.Empty(t, changes)
+		assert.Equal(t, 8000, reloaded.ServerPort)
+		assert.Equal(t, 3, reloaded.MaxRetries)
+		assert.Equal(t, current.HTTPMaxIdleConns, reloaded.HTTPMaxIdleConns)
+		assert.Empty(t, reloaded.VPNProxyURL)
+	})
+
+	t.Run("applies the thinking budget settings", func(t *testing.T) {
+		next := current.clone()
+		next.FakeReasoningEnforceBudget = true
+		next.FakeReasoningBudgetMarker = "[budget reached]"
+
+		reloaded, changes := current.Reload(next)
+
+		assert.True(t, reloaded.FakeReasoningEnforceBudget)
+		assert.Equal(t, "[budget reached]", reloaded.FakeReasoningBudgetMarker)
+		assert.Len(t, changes, 2)
+	})
+
+	t.Run("nil and empty lists are equal", func(t *testing.T) {
+		before := current.clone()
+		before.HiddenFromList = nil
+		next := current.clone()
+		next.HiddenFromList = []string{}
+
+		_, changes := before.Reload(next)
+
+		assert.Empty(t, changes)
+	})
+
+	t.Run("all reloadable keys exist", func(t *testing.T) {
+		fields := fileFields()
+		for _, key := range reloadableKeys {
+			assert.Contains(t, fields, key)
+		}
+	})
+}
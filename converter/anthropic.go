@@ -0,0 +1,472 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AnthropicRequest represents an Anthropic Messages API request
+type AnthropicRequest struct {
+	Model         string               `json:"model"`
+	Messages      []AnthropicMessage   `json:"messages"`
+	System        AnthropicContent     `json:"system,omitempty"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	TopP          *float64             `json:"top_p,omitempty"`
+	TopK          *int                 `json:"top_k,omitempty"`
+	StopSequences []string             `json:"stop_sequences,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	Tools         []AnthropicTool      `json:"tools,omitempty"`
+	ToolChoice    *AnthropicToolChoice `json:"tool_choice,omitempty"`
+	Thinking      *AnthropicThinking   `json:"thinking,omitempty"`
+	Metadata      *AnthropicMetadata   `json:"metadata,omitempty"`
+}
+
+// AnthropicMessage represents a message in Anthropic format
+type AnthropicMessage struct {
+	Role    string           `json:"role"`
+	Content AnthropicContent `json:"content"`
+}
+
+// AnthropicContent is message or system content. Anthropic accepts either a
+// plain string or a list of content blocks; a string is unmarshalled as a
+// single text block.
+type AnthropicContent []AnthropicContentBlock
+
+// UnmarshalJSON accepts a string or an array of content blocks
+func (c *AnthropicContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*c = AnthropicContent{{Type: "text", Text: text}}
+		return nil
+	}
+
+	var blocks []AnthropicContentBlock
+	if err := json.Unmarshal(data, &blocks); err != nil {
+		return fmt.Errorf("content must be a string or an array of content blocks: %w", err)
+	}
+	*c = blocks
+	return nil
+}
+
+// Text joins the text blocks of the content with sep
+func (c AnthropicContent) Text(sep string) string {
+	var parts []string
+	for _, block := range c {
+		if block.Type == "text" {
+			parts = append(parts, block.Text)
+		}
+	}
+	return strings.Join(parts, sep)
+}
+
+// AnthropicContentBlock is a single content block. Which fields are set depends on Type.
+type AnthropicContentBlock struct {
+	Type string `json:"type"`
+
+	// text
+	Text string `json:"text,omitempty"`
+
+	// image
+	Source *AnthropicImageSource `json:"source,omitempty"`
+
+	// tool_use
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty"`
+
+	// tool_result: content is a string or a list of content blocks
+	ToolUseID string      `json:"tool_use_id,omitempty"`
+	Content   interface{} `json:"content,omitempty"`
+	IsError   bool        `json:"is_error,omitempty"`
+
+	// thinking
+	Thinking  string `json:"thinking,omitempty"`
+	Signature string `json:"signature,omitempty"`
+
+	// Prompt caching breakpoint, allowed on any block
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+// AnthropicCacheControl marks the end of a cacheable prompt prefix. Kiro has no
+// prompt caching, so hints are validated and kept in the unified messages but
+// not sent upstream.
+type AnthropicCacheControl struct {
+	Type string `json:"type"`
+	TTL  string `json:"ttl,omitempty"`
+}
+
+// AnthropicImageSource represents the source of an image block
+type AnthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type,omitempty"`
+	Data      string `json:"data,omitempty"`
+	URL       string `json:"url,omitempty"`
+}
+
+// AnthropicTool represents a tool definition in Anthropic format
+type AnthropicTool struct {
+	Type        string                 `json:"type,omitempty"`
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	InputSchema map[string]interface{} `json:"input_schema,omitempty"`
+
+	CacheControl *AnthropicCacheControl `json:"cache_control,omitempty"`
+}
+
+// AnthropicToolChoice controls how the model uses tools
+type AnthropicToolChoice struct {
+	Type                   string `json:"type"`
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
+}
+
+// AnthropicThinking configures extended thinking
+type AnthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens,omitempty"`
+}
+
+// AnthropicMetadata carries request metadata
+type AnthropicMetadata struct {
+	UserID string `json:"user_id,omitempty"`
+}
+
+// Validate checks the request for missing or malformed fields
+func (r *AnthropicRequest) Validate() error {
+	if r.Model == "" {
+		return fmt.Errorf("model: field required")
+	}
+	if len(r.Messages) == 0 {
+		return fmt.Errorf("messages: at least one message is required")
+	}
+	if r.MaxTokens < 0 {
+		return fmt.Errorf("max_tokens: must not be negative")
+	}
+	if r.Temperature != nil && (*r.Temperature < 0 || *r.Temperature > 1) {
+		return fmt.Errorf("temperature: must be between 0 and 1")
+	}
+	if r.TopP != nil && (*r.TopP < 0 || *r.TopP > 1) {
+		return fmt.Errorf("top_p: must be between 0 and 1")
+	}
+
+	for i, msg := range r.Messages {
+		if msg.Role != "user" && msg.Role != "assistant" {
+			return fmt.Errorf("messages.%d.role: must be 'user' or 'assistant', got '%s'", i, msg.Role)
+		}
+		for j, block := range msg.Content {
+			if err := block.validate(); err != nil {
+				return fmt.Errorf("messages.%d.content.%d: %w", i, j, err)
+			}
+		}
+	}
+
+	for i, block := range r.System {
+		if block.Type != "text" {
+			return fmt.Errorf("system.%d.type: only text blocks are allowed in system", i)
+		}
+		if err := block.CacheControl.validate(); err != nil {
+			return fmt.Errorf("system.%d.%w", i, err)
+		}
+	}
+
+	for i, tool := range r.Tools {
+		if tool.Name == "" {
+			return fmt.Errorf("tools.%d.name: field required", i)
+		}
+		if err := tool.CacheControl.validate(); err != nil {
+			return fmt.Errorf("tools.%d.%w", i, err)
+		}
+	}
+
+	if tc := r.ToolChoice; tc != nil {
+		switch tc.Type {
+		case "auto", "any", "none":
+		case "tool":
+			if tc.Name == "" {
+				return fmt.Errorf("tool_choice.name: field required when type is 'tool'")
+			}
+		default:
+			return fmt.Errorf("tool_choice.type: must be one of 'auto', 'any', 'tool', 'none'")
+		}
+	}
+
+	if th := r.Thinking; th != nil {
+		switch th.Type {
+		case "enabled":
+			if th.BudgetTokens <= 0 {
+				return fmt.Errorf("thinking.budget_tokens: must be greater than 0 when thinking is enabled")
+			}
+		case "disabled":
+		default:
+			return fmt.Errorf("thinking.type: must be 'enabled' or 'disabled'")
+		}
+	}
+
+	return nil
+}
+
+func (b *AnthropicContentBlock) validate() error {
+	switch b.Type {
+	case "text", "thinking", "redacted_thinking", "document":
+	case "image":
+		if b.Source == nil {
+			return fmt.Errorf("image.source: field required")
+		}
+	case "tool_use":
+		if b.ID == "" || b.Name == "" {
+			return fmt.Errorf("tool_use: id and name are required")
+		}
+	case "tool_result":
+		if b.ToolUseID == "" {
+			return fmt.Errorf("tool_result.tool_use_id: field required")
+		}
+	case "":
+		return fmt.Errorf("type: field required")
+	default:
+		// Block types added to the API later are accepted and ignored by the
+		// conversion, so new clients keep working
+	}
+	return b.CacheControl.validate()
+}
+
+func (cc *AnthropicCacheControl) validate() error {
+	if cc == nil {
+		return nil
+	}
+	if cc.Type != "ephemeral" {
+		return fmt.Errorf("cache_control.type: must be 'ephemeral', got '%s'", cc.Type)
+	}
+	switch cc.TTL {
+	case "", "5m", "1h":
+	default:
+		return fmt.Errorf("cache_control.ttl: must be '5m' or '1h', got '%s'", cc.TTL)
+	}
+	return nil
+}
+
+// CacheBreakpoints counts the cache_control hints in the system prompt, tools and messages
+func (r *AnthropicRequest) CacheBreakpoints() int {
+	count := 0
+	for _, block := range r.System {
+		if block.CacheControl != nil {
+			count++
+		}
+	}
+	for _, tool := range r.Tools {
+		if tool.CacheControl != nil {
+			count++
+		}
+	}
+	for _, msg := range r.Messages {
+		for _, block := range msg.Content {
+			if block.CacheControl != nil {
+				count++
+			}
+		}
+	}
+	return count
+}
+
+// unifiedContent returns the text of the content as a string, or as a list of
+// text parts when it has several text blocks or a cache breakpoint, so block
+// boundaries and hints survive conversion. Text extraction joins the parts
+// without a separator either way.
+func (c AnthropicContent) unifiedContent() interface{} {
+	var parts []interface{}
+	keepParts := false
+	for _, block := range c {
+		if block.Type != "text" {
+			continue
+		}
+		part := map[string]interface{}{"type": "text", "text": block.Text}
+		if block.CacheControl != nil {
+			part["cache_control"] = block.CacheControl
+			keepParts = true
+		}
+		parts = append(parts, part)
+	}
+	if !keepParts && len(parts) < 2 {
+		return c.Text("")
+	}
+	return parts
+}
+
+// ConvertAnthropicToUnified converts an Anthropic request's messages to unified format
+func ConvertAnthropicToUnified(req *AnthropicRequest) ([]UnifiedMessage, string) {
+	systemPrompt := req.System.Text("\n")
+	if n := req.CacheBreakpoints(); n > 0 {
+		log.Debugf("Dropping %d cache_control hint(s): Kiro has no prompt caching", n)
+	}
+
+	var messages []UnifiedMessage
+	for _, msg := range req.Messages {
+		unifiedMsg := UnifiedMessage{
+			Role:    msg.Role,
+			Content: msg.Content.unifiedContent(),
+		}
+
+		for _, block := range msg.Content {
+			switch block.Type {
+			case "thinking":
+				unifiedMsg.Reasoning += block.Thinking
+
+			case "tool_use":
+				toolCall := ToolCall{ID: block.ID, Type: "function"}
+				toolCall.Function.Name = block.Name
+				toolCall.Function.Arguments = compactJSON(block.Input)
+				unifiedMsg.ToolCalls = append(unifiedMsg.ToolCalls, toolCall)
+
+			case "tool_result":
+				unifiedMsg.ToolResults = append(unifiedMsg.ToolResults, ToolResult{
+					ToolUseID: block.ToolUseID,
+					Content:   block.Content,
+				})
+				// Kiro tool results are text only: screenshots go with the user message
+				unifiedMsg.Images = append(unifiedMsg.Images, ExtractAnthropicToolResultImages(block.Content)...)
+
+			case "image":
+				if block.Source != nil && block.Source.Type == "base64" {
+					unifiedMsg.Images = append(unifiedMsg.Images, map[string]interface{}{
+						"media_type": block.Source.MediaType,
+						"data":       block.Source.Data,
+					})
+				}
+
+			case "text":
+
+			default:
+				log.Debugf("Ignoring '%s' content block: Kiro has no equivalent", block.Type)
+			}
+		}
+
+		messages = append(messages, unifiedMsg)
+	}
+
+	return messages, systemPrompt
+}
+
+// PrefillPrompt introduces an assistant prefill in the user turn before it. Kiro
+// cannot continue an assistant message, so the model is asked to write only
+// what follows the prefill.
+const PrefillPrompt = "Your reply has already begun with the text below. Continue it from exactly where it ends, without repeating it:"
+
+// SplitAssistantPrefill removes a final text-only assistant message, which
+// Anthropic treats as the start of the reply, and moves its text into the user
+// turn before it with PrefillPrompt. It returns the messages and the prefill,
+// which the caller prepends to the response; without a prefill the messages are
+// returned unchanged.
+func SplitAssistantPrefill(messages []UnifiedMessage) ([]UnifiedMessage, string) {
+	n := len(messages)
+	if n < 2 || messages[n-1].Role != "assistant" || messages[n-2].Role != "user" || len(messages[n-1].ToolCalls) > 0 {
+		return messages, ""
+	}
+	prefill := utils.ExtractTextContent(messages[n-1].Content)
+	if prefill == "" {
+		return messages, ""
+	}
+
+	user := messages[n-2]
+	instruction := PrefillPrompt + "\n\n" + prefill
+	if parts, ok := user.Content.([]interface{}); ok {
+		user.Content = append(parts[:len(parts):len(parts)], map[string]interface{}{"type": "text", "text": "\n\n" + instruction})
+	} else if text := utils.ExtractTextContent(user.Content); text != "" {
+		user.Content = text + "\n\n" + instruction
+	} else {
+		user.Content = instruction
+	}
+
+	result := append(messages[:n-2:n-2], user)
+	return result, prefill
+}
+
+// ApplyAnthropicThinking returns the config to use for a request with the given
+// thinking setting, as ApplyReasoningEffort does for OpenAI requests. Without
+// one cfg is returned unchanged; "disabled" disables fake reasoning and
+// "enabled" enables it with budget_tokens as its thinking budget, overriding
+// FAKE_REASONING and FAKE_REASONING_MAX_TOKENS for this request only.
+func ApplyAnthropicThinking(cfg *config.Config, thinking *AnthropicThinking) *config.Config {
+	if thinking == nil {
+		return cfg
+	}
+
+	reqCfg := *cfg
+	switch thinking.Type {
+	case "enabled":
+		reqCfg.FakeReasoningEnabled = true
+		reqCfg.FakeReasoningMaxTokens = thinking.BudgetTokens
+	case "disabled":
+		reqCfg.FakeReasoningEnabled = false
+	}
+	return &reqCfg
+}
+
+// ExtractAnthropicToolResultImages returns the base64 image blocks of tool_result
+// content, which is a string or a list of content blocks
+func ExtractAnthropicToolResultImages(content interface{}) []map[string]interface{} {
+	blocks, ok := content.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var images []map[string]interface{}
+	for _, item := range blocks {
+		block, ok := item.(map[string]interface{})
+		if !ok || block["type"] != "image" {
+			continue
+		}
+		source, _ := block["source"].(map[string]interface{})
+		if source == nil || source["type"] != "base64" {
+			continue
+		}
+		data, _ := source["data"].(string)
+		if data == "" {
+			continue
+		}
+		mediaType, _ := source["media_type"].(string)
+		images = append(images, map[string]interface{}{
+			"media_type": mediaType,
+			"data":       data,
+		})
+	}
+	return images
+}
+
+// ConvertAnthropicToolsToUnified converts Anthropic tools to unified format
+func ConvertAnthropicToolsToUnified(tools []AnthropicTool) []UnifiedTool {
+	var unified []UnifiedTool
+	for _, tool := range tools {
+		if tool.Name == "" {
+			continue
+		}
+		unified = append(unified, UnifiedTool{
+			Name:        tool.Name,
+			Description: tool.Description,
+			InputSchema: tool.InputSchema,
+		})
+	}
+	return unified
+}
+
+// compactJSON returns raw JSON without insignificant whitespace, or "" if empty
+func compactJSON(raw json.RawMessage) string {
+	if len(raw) == 0 || string(raw) == "null" {
+		return ""
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
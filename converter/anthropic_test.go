@@ -0,0 +1,318 @@
+// Package converter provides tests for Anthropic format conversion.
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseAnthropicRequest(t *testing.T, body string) *AnthropicRequest {
+	var req AnthropicRequest
+	assert.NoError(t, json.Unmarshal([]byte(body), &req))
+	return &req
+}
+
+// =============================================================================
+// TestAnthropicRequestUnmarshal
+// =============================================================================
+
+func TestAnthropicRequestUnmarshal(t *testing.T) {
+	t.Run("accepts string content", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m", "system": "Be brief.", "messages": [{"role": "user", "content": "Hi"}]}`)
+
+		assert.Equal(t, AnthropicContent{{Type: "text", Text: "Be brief."}}, req.System)
+		assert.Equal(t, AnthropicContent{{Type: "text", Text: "Hi"}}, req.Messages[0].Content)
+	})
+
+	t.Run("keeps all request fields", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{
+			"model": "m", "max_tokens": 1024, "stream": true, "top_k": 5,
+			"messages": [{"role": "user", "content": "Hi"}],
+			"stop_sequences": ["END"],
+			"metadata": {"user_id": "u-1"},
+			"tool_choice": {"type": "tool", "name": "get_weather"},
+			"thinking": {"type": "enabled", "budget_tokens": 2048}
+		}`)
+
+		assert.Equal(t, 1024, req.MaxTokens)
+		assert.True(t, req.Stream)
+		assert.Equal(t, 5, *req.TopK)
+		assert.Equal(t, []string{"END"}, req.StopSequences)
+		assert.Equal(t, "u-1", req.Metadata.UserID)
+		assert.Equal(t, "get_weather", req.ToolChoice.Name)
+		assert.Equal(t, 2048, req.Thinking.BudgetTokens)
+	})
+
+	t.Run("rejects invalid content", func(t *testing.T) {
+		var req AnthropicRequest
+		err := json.Unmarshal([]byte(`{"messages": [{"role": "user", "content": 42}]}`), &req)
+
+		assert.Error(t, err)
+	})
+}
+
+// =============================================================================
+// TestAnthropicRequestValidate
+// =============================================================================
+
+func TestAnthropicRequestValidate(t *testing.T) {
+	valid := `{"model": "m", "messages": [{"role": "user", "content": "Hi"}]}`
+
+	t.Run("accepts valid request", func(t *testing.T) {
+		assert.NoError(t, parseAnthropicRequest(t, valid).Validate())
+	})
+
+	t.Run("accepts cache_control hints", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m",
+			"system": [{"type": "text", "text": "S", "cache_control": {"type": "ephemeral"}}],
+			"tools": [{"name": "f", "cache_control": {"type": "ephemeral", "ttl": "1h"}}],
+			"messages": [{"role": "user", "content": [{"type": "text", "text": "Hi", "cache_control": {"type": "ephemeral", "ttl": "5m"}}]}]}`)
+
+		assert.NoError(t, req.Validate())
+		assert.Equal(t, 3, req.CacheBreakpoints())
+	})
+
+	t.Run("accepts unknown content block types", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m", "messages": [{"role": "user", "content": [
+			{"type": "search_result", "source": {"type": "url", "url": "https://example.com"}, "title": "Example"},
+			{"type": "text", "text": "Summarize it"}]}]}`)
+
+		assert.NoError(t, req.Validate())
+		messages, _ := ConvertAnthropicToUnified(req)
+		assert.Equal(t, "Summarize it", messages[0].Content)
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing model", `{"messages": [{"role": "user", "content": "Hi"}]}`, "model"},
+		{"missing messages", `{"model": "m"}`, "messages"},
+		{"bad role", `{"model": "m", "messages": [{"role": "system", "content": "Hi"}]}`, "messages.0.role"},
+		{"tool_use without id", `{"model": "m", "messages": [{"role": "assistant", "content": [{"type": "tool_use", "name": "f"}]}]}`, "messages.0.content.0"},
+		{"tool_result without id", `{"model": "m", "messages": [{"role": "user", "content": [{"type": "tool_result"}]}]}`, "tool_use_id"},
+		{"block without type", `{"model": "m", "messages": [{"role": "user", "content": [{"text": "Hi"}]}]}`, "type: field required"},
+		{"temperature out of range", `{"model": "m", "temperature": 2, "messages": [{"role": "user", "content": "Hi"}]}`, "temperature"},
+		{"tool without name", `{"model": "m", "tools": [{"description": "x"}], "messages": [{"role": "user", "content": "Hi"}]}`, "tools.0.name"},
+		{"tool_choice without name", `{"model": "m", "tool_choice": {"type": "tool"}, "messages": [{"role": "user", "content": "Hi"}]}`, "tool_choice.name"},
+		{"thinking without budget", `{"model": "m", "thinking": {"type": "enabled"}, "messages": [{"role": "user", "content": "Hi"}]}`, "budget_tokens"},
+		{"bad cache_control type", `{"model": "m", "messages": [{"role": "user", "content": [{"type": "text", "text": "Hi", "cache_control": {"type": "persistent"}}]}]}`, "messages.0.content.0: cache_control.type"},
+		{"bad cache_control ttl", `{"model": "m", "system": [{"type": "text", "text": "S", "cache_control": {"type": "ephemeral", "ttl": "1d"}}], "messages": [{"role": "user", "content": "Hi"}]}`, "system.0.cache_control.ttl"},
+		{"bad tool cache_control", `{"model": "m", "tools": [{"name": "f", "cache_control": {}}], "messages": [{"role": "user", "content": "Hi"}]}`, "tools.0.cache_control.type"},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			err := parseAnthropicRequest(t, tt.body).Validate()
+
+			assert.ErrorContains(t, err, tt.want)
+		})
+	}
+}
+
+// =============================================================================
+// TestConvertAnthropicToUnified
+// =============================================================================
+
+func TestConvertAnthropicToUnified(t *testing.T) {
+	t.Run("converts system and text content", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m",
+			"system": [{"type": "text", "text": "One"}, {"type": "text", "text": "Two"}],
+			"messages": [{"role": "user", "content": [{"type": "text", "text": "Hello "}, {"type": "text", "text": "world"}]}]}`)
+
+		messages, system := ConvertAnthropicToUnified(req)
+
+		assert.Equal(t, "One\nTwo", system)
+		assert.Len(t, messages, 1)
+		assert.Equal(t, "user", messages[0].Role)
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"type": "text", "text": "Hello "},
+			map[string]interface{}{"type": "text", "text": "world"},
+		}, messages[0].Content)
+		assert.Equal(t, "Hello world", utils.ExtractTextContent(messages[0].Content))
+	})
+
+	t.Run("keeps a single text block as a string", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m", "messages": [{"role": "user", "content": [{"type": "text", "text": "Hello"}]}]}`)
+
+		messages, _ := ConvertAnthropicToUnified(req)
+
+		assert.Equal(t, "Hello", messages[0].Content)
+	})
+
+	t.Run("keeps cache_control on text parts", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m", "messages": [{"role": "user", "content": [
+			{"type": "text", "text": "Long document", "cache_control": {"type": "ephemeral"}}
+		]}]}`)
+
+		messages, _ := ConvertAnthropicToUnified(req)
+
+		assert.Equal(t, []interface{}{
+			map[string]interface{}{"type": "text", "text": "Long document", "cache_control": &AnthropicCacheControl{Type: "ephemeral"}},
+		}, messages[0].Content)
+	})
+
+	t.Run("converts tool use and tool results", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m", "messages": [
+			{"role": "assistant", "content": [{"type": "tool_use", "id": "toolu_1", "name": "get_weather", "input": {"city": "London"}}]},
+			{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_1", "content": "Sunny"}]}
+		]}`)
+
+		messages, _ := ConvertAnthropicToUnified(req)
+
+		assert.Len(t, messages[0].ToolCalls, 1)
+		assert.Equal(t, "toolu_1", messages[0].ToolCalls[0].ID)
+		assert.Equal(t, "get_weather", messages[0].ToolCalls[0].Function.Name)
+		assert.Equal(t, `{"city":"London"}`, messages[0].ToolCalls[0].Function.Arguments)
+		assert.Equal(t, []ToolResult{{ToolUseID: "toolu_1", Content: "Sunny"}}, messages[1].ToolResults)
+	})
+
+	t.Run("extracts base64 images", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m", "messages": [{"role": "user", "content": [
+			{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "AAAA"}},
+			{"type": "image", "source": {"type": "url", "url": "https://example.com/a.png"}}
+		]}]}`)
+
+		messages, _ := ConvertAnthropicToUnified(req)
+
+		assert.Equal(t, []map[string]interface{}{{"media_type": "image/png", "data": "AAAA"}}, messages[0].Images)
+	})
+
+	t.Run("moves tool result images to the message", func(t *testing.T) {
+		req := parseAnthropicRequest(t, `{"model": "m", "messages": [{"role": "user", "content": [
+			{"type": "tool_result", "tool_use_id": "toolu_1", "content": [
+				{"type": "text", "text": "Screenshot taken"},
+				{"type": "image", "source": {"type": "base64", "media_type": "image/png", "data": "AAAA"}}
+			]},
+			{"type": "tool_result", "tool_use_id": "toolu_2", "content": "No image"}
+		]}]}`)
+
+		messages, _ := ConvertAnthropicToUnified(req)
+
+		assert.Len(t, messages[0].ToolResults, 2)
+		assert.Equal(t, []map[string]interface{}{{"media_type": "image/png", "data": "AAAA"}}, messages[0].Images)
+	})
+}
+
+// =============================================================================
+// TestSplitAssistantPrefill
+// =============================================================================
+
+func TestSplitAssistantPrefill(t *testing.T) {
+	t.Run("moves the prefill into the user turn", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "Reply in JSON."},
+			{Role: "assistant", Content: `{"answer":`},
+		}
+
+		result, prefill := SplitAssistantPrefill(messages)
+
+		assert.Equal(t, `{"answer":`, prefill)
+		assert.Len(t, result, 1)
+		assert.Equal(t, "Reply in JSON.\n\n"+PrefillPrompt+"\n\n"+`{"answer":`, result[0].Content)
+		assert.Equal(t, "Reply in JSON.", messages[0].Content)
+	})
+
+	t.Run("appends a part to block content", func(t *testing.T) {
+		parts := []interface{}{map[string]interface{}{"type": "text", "text": "One"}, map[string]interface{}{"type": "text", "text": "Two"}}
+		messages := []UnifiedMessage{
+			{Role: "user", Content: parts},
+			{Role: "assistant", Content: "Sure"},
+		}
+
+		result, _ := SplitAssistantPrefill(messages)
+
+		assert.Equal(t, "OneTwo\n\n"+PrefillPrompt+"\n\nSure", utils.ExtractTextContent(result[0].Content))
+		assert.Len(t, parts, 2)
+	})
+
+	t.Run("ignores other conversations", func(t *testing.T) {
+		withTools := []UnifiedMessage{
+			{Role: "user", Content: "Weather?"},
+			{Role: "assistant", ToolCalls: []ToolCall{{ID: "toolu_1"}}},
+		}
+		tests := map[string][]UnifiedMessage{
+			"user last":          {{Role: "user", Content: "Hi"}},
+			"assistant only":     {{Role: "assistant", Content: "Hi"}},
+			"empty prefill":      {{Role: "user", Content: "Hi"}, {Role: "assistant", Content: ""}},
+			"assistant tool use": withTools,
+		}
+		for name, messages := range tests {
+			result, prefill := SplitAssistantPrefill(messages)
+
+			assert.Equal(t, messages, result, name)
+			assert.Empty(t, prefill, name)
+		}
+	})
+}
+
+// =============================================================================
+// TestApplyAnthropicThinking
+// =============================================================================
+
+func TestApplyAnthropicThinking(t *testing.T) {
+	base := &config.Config{FakeReasoningEnabled: true, FakeReasoningMaxTokens: 4000}
+
+	t.Run("no thinking keeps global config", func(t *testing.T) {
+		assert.Same(t, base, ApplyAnthropicThinking(base, nil))
+	})
+
+	t.Run("enabled sets the thinking budget", func(t *testing.T) {
+		cfg := ApplyAnthropicThinking(&config.Config{FakeReasoningMaxTokens: 4000}, &AnthropicThinking{Type: "enabled", BudgetTokens: 1024})
+		assert.True(t, cfg.FakeReasoningEnabled)
+		assert.Equal(t, 1024, cfg.FakeReasoningMaxTokens)
+	})
+
+	t.Run("disabled disables fake reasoning", func(t *testing.T) {
+		cfg := ApplyAnthropicThinking(base, &AnthropicThinking{Type: "disabled"})
+		assert.False(t, cfg.FakeReasoningEnabled)
+		assert.True(t, base.FakeReasoningEnabled, "global config must not change")
+	})
+}
+
+// =============================================================================
+// TestExtractAnthropicToolResultImages
+// =============================================================================
+
+func TestExtractAnthropicToolResultImages(t *testing.T) {
+	t.Run("extracts base64 image blocks", func(t *testing.T) {
+		content := []interface{}{
+			map[string]interface{}{"type": "text", "text": "Screenshot"},
+			map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/jpeg", "data": "BBBB"}},
+		}
+
+		assert.Equal(t, []map[string]interface{}{{"media_type": "image/jpeg", "data": "BBBB"}}, ExtractAnthropicToolResultImages(content))
+	})
+
+	t.Run("ignores text, URL images and empty data", func(t *testing.T) {
+		assert.Empty(t, ExtractAnthropicToolResultImages("text result"))
+		assert.Empty(t, ExtractAnthropicToolResultImages(nil))
+		assert.Empty(t, ExtractAnthropicToolResultImages([]interface{}{
+			map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "url", "url": "https://example.com/a.png"}},
+			map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/png"}},
+		}))
+	})
+}
+
+// =============================================================================
+// TestConvertAnthropicToolsToUnified
+// =============================================================================
+
+func TestConvertAnthropicToolsToUnified(t *testing.T) {
+	tools := []AnthropicTool{
+		{Name: "get_weather", Description: "Get weather", InputSchema: map[string]interface{}{"type": "object"}},
+		{Name: ""},
+	}
+
+	result := ConvertAnthropicToolsToUnified(tools)
+
+	assert.Len(t, result, 1)
+	assert.Equal(t, "get_weather", result[0].Name)
+	assert.Equal(t, "Get weather", result[0].Description)
+	assert.Equal(t, map[string]interface{}{"type": "object"}, result[0].InputSchema)
+}
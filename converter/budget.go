@@ -0,0 +1,137 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"kiro-go-proxy/tokens"
+	"kiro-go-proxy/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Context trim strategies for CONTEXT_TRIM_STRATEGY
+const (
+	TrimOff            = "off"
+	TrimDrop           = "drop"
+	TrimTruncateMiddle = "truncate_middle"
+	TrimError          = "error"
+)
+
+// trimNoteTokens is an upper bound for the tokens of the omitted messages note
+const trimNoteTokens = 20
+
+// ContextLengthError is returned by BuildKiroPayload when a request is estimated
+// to exceed the model's max input tokens and cannot be trimmed to fit
+type ContextLengthError struct {
+	Tokens    int
+	MaxTokens int
+}
+
+func (e *ContextLengthError) Error() string {
+	return fmt.Sprintf("prompt is too long: %d tokens > %d maximum", e.Tokens, e.MaxTokens)
+}
+
+// fitContextBudget removes history turns until messages, systemPrompt and tools
+// are estimated to fit in maxInputTokens. messages must alternate roles starting
+// with user; the last message is the current one and is never removed.
+//
+// TrimDrop removes the oldest user/assistant pairs. TrimTruncateMiddle keeps the
+// first exchange, which usually states the task, and removes the pairs after it.
+// An exchange whose reply calls tools runs until a reply without tool calls, so
+// a kept tool call always keeps its result.
+// A note in the first kept user message tells the model history was omitted.
+func fitContextBudget(messages []UnifiedMessage, systemPrompt string, tools []UnifiedTool, maxInputTokens int, strategy string) ([]UnifiedMessage, error) {
+	if maxInputTokens <= 0 || strategy == "" || strategy == TrimOff {
+		return messages, nil
+	}
+
+	sizes := make([]int, len(messages))
+	total := estimateFixedTokens(systemPrompt, tools) + tokens.TokensPerReply
+	for i, msg := range messages {
+		sizes[i] = estimateMessageTokens(msg)
+		total += sizes[i]
+	}
+	estimated := tokens.ApplyCorrection(total)
+	if estimated <= maxInputTokens {
+		return messages, nil
+	}
+	if strategy == TrimError {
+		return nil, &ContextLengthError{Tokens: estimated, MaxTokens: maxInputTokens}
+	}
+
+	// Room for the note about omitted messages
+	total += trimNoteTokens
+
+	keep := 0
+	if strategy == TrimTruncateMiddle {
+		keep = 2
+		for keep < len(messages)-1 && len(messages[keep-1].ToolCalls) > 0 {
+			keep += 2
+		}
+	}
+	next := keep
+	for tokens.ApplyCorrection(total) > maxInputTokens && next+2 < len(messages) {
+		total -= sizes[next] + sizes[next+1]
+		next += 2
+	}
+	if tokens.ApplyCorrection(total) > maxInputTokens {
+		return nil, &ContextLengthError{Tokens: estimated, MaxTokens: maxInputTokens}
+	}
+
+	dropped := next - keep
+	log.Infof("Dropped %d history messages to fit %d input tokens (estimated %d)", dropped, maxInputTokens, estimated)
+
+	trimmed := make([]UnifiedMessage, 0, len(messages)-dropped)
+	trimmed = append(trimmed, messages[:keep]...)
+	trimmed = append(trimmed, messages[next:]...)
+
+	// The calls these results answer were dropped, so keep the results as text
+	first := trimmed[keep]
+	if len(first.ToolResults) > 0 {
+		stripped, _ := StripAllToolContent([]UnifiedMessage{first})
+		first = stripped[0]
+	}
+	note := fmt.Sprintf("[%d earlier messages were omitted to fit the context window]", dropped)
+	first.Content = note + "\n\n" + utils.ExtractTextContent(first.Content)
+	trimmed[keep] = first
+
+	return trimmed, nil
+}
+
+// estimateFixedTokens returns the raw token estimate of the system prompt and tools
+func estimateFixedTokens(systemPrompt string, tools []UnifiedTool) int {
+	total := 0
+	if systemPrompt != "" {
+		total += tokens.TokensPerMessage + tokens.CountRaw(systemPrompt)
+	}
+	for _, tool := range tools {
+		total += tokens.TokensPerTool
+		total += tokens.CountRaw(tool.Name)
+		total += tokens.CountRaw(tool.Description)
+		if tool.InputSchema != nil {
+			b, _ := json.Marshal(tool.InputSchema)
+			total += tokens.CountRaw(string(b))
+		}
+	}
+	return total
+}
+
+// estimateMessageTokens returns the raw token estimate of one message
+func estimateMessageTokens(msg UnifiedMessage) int {
+	total := tokens.TokensPerMessage
+	total += tokens.CountRaw(msg.Role)
+	total += tokens.CountRaw(utils.ExtractTextContent(msg.Content))
+	total += len(msg.Images) * tokens.TokensPerImage
+
+	for _, tc := range msg.ToolCalls {
+		total += tokens.TokensPerTool
+		total += tokens.CountRaw(tc.Function.Name)
+		total += tokens.CountRaw(tc.Function.Arguments)
+	}
+	for _, tr := range msg.ToolResults {
+		total += tokens.CountRaw(tr.ToolUseID)
+		total += tokens.CountRaw(utils.ExtractTextContent(tr.Content))
+	}
+	return total
+}
@@ -0,0 +1,145 @@
+// Package converter provides tests for context budget enforcement.
+package converter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// budgetConversation returns a conversation of turns user/assistant pairs of
+// about 100 tokens each, followed by a short current message
+func budgetConversation(turns int) []UnifiedMessage {
+	var messages []UnifiedMessage
+	for i := 0; i < turns; i++ {
+		messages = append(messages,
+			UnifiedMessage{Role: "user", Content: "question " + strings.Repeat("word ", 100)},
+			UnifiedMessage{Role: "assistant", Content: "answer " + strings.Repeat("word ", 100)},
+		)
+	}
+	return append(messages, UnifiedMessage{Role: "user", Content: "current"})
+}
+
+// =============================================================================
+// TestFitContextBudget
+// =============================================================================
+
+func TestFitContextBudget(t *testing.T) {
+	messages := budgetConversation(5)
+	total := EstimateInputTokens(messages, "", nil)
+
+	t.Run("fits unchanged", func(t *testing.T) {
+		got, err := fitContextBudget(messages, "", nil, total, TrimDrop)
+		assert.NoError(t, err)
+		assert.Equal(t, messages, got)
+	})
+
+	t.Run("off and zero budget skip the check", func(t *testing.T) {
+		got, err := fitContextBudget(messages, "", nil, 10, TrimOff)
+		assert.NoError(t, err)
+		assert.Len(t, got, 11)
+
+		got, err = fitContextBudget(messages, "", nil, 0, TrimError)
+		assert.NoError(t, err)
+		assert.Len(t, got, 11)
+	})
+
+	t.Run("drop removes the oldest turns", func(t *testing.T) {
+		got, err := fitContextBudget(messages, "", nil, total/2, TrimDrop)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, EstimateInputTokens(got, "", nil), total/2)
+		assert.Equal(t, "user", got[0].Role)
+		assert.True(t, strings.HasPrefix(got[0].Content.(string), "[6 earlier messages were omitted to fit the context window]\n\nquestion"))
+		assert.Equal(t, "current", got[len(got)-1].Content)
+		assert.Len(t, got, 5)
+	})
+
+	t.Run("truncate_middle keeps the first exchange", func(t *testing.T) {
+		got, err := fitContextBudget(messages, "", nil, total/2, TrimTruncateMiddle)
+		assert.NoError(t, err)
+		assert.Len(t, got, 5)
+		assert.Equal(t, messages[0], got[0])
+		assert.Equal(t, messages[1], got[1])
+		assert.True(t, strings.HasPrefix(got[2].Content.(string), "[6 earlier messages were omitted"))
+		assert.Equal(t, messages[len(messages)-1], got[len(got)-1])
+	})
+
+	t.Run("error rejects oversized requests", func(t *testing.T) {
+		_, err := fitContextBudget(messages, "", nil, total/2, TrimError)
+		assert.Equal(t, &ContextLengthError{Tokens: total, MaxTokens: total / 2}, err)
+		assert.EqualError(t, err, fmt.Sprintf("prompt is too long: %d tokens > %d maximum", total, total/2))
+	})
+
+	t.Run("errors when the current message alone does not fit", func(t *testing.T) {
+		_, err := fitContextBudget(messages, strings.Repeat("system ", 1000), nil, 500, TrimDrop)
+		assert.IsType(t, &ContextLengthError{}, err)
+	})
+
+	t.Run("orphaned tool results become text", func(t *testing.T) {
+		call := ToolCall{ID: "t1", Type: "function"}
+		call.Function.Name = "read"
+		call.Function.Arguments = `{}`
+		withTools := []UnifiedMessage{
+			{Role: "user", Content: strings.Repeat("word ", 200)},
+			{Role: "assistant", Content: "", ToolCalls: []ToolCall{call}},
+			{Role: "user", Content: "", ToolResults: []ToolResult{{ToolUseID: "t1", Content: "file contents"}}},
+			{Role: "assistant", Content: "done"},
+			{Role: "user", Content: "current"},
+		}
+
+		got, err := fitContextBudget(withTools, "", nil, 100, TrimDrop)
+		assert.NoError(t, err)
+		assert.Len(t, got, 3)
+		assert.Empty(t, got[0].ToolResults)
+		assert.Contains(t, got[0].Content, "[Tool Result (t1)]\nfile contents")
+	})
+
+	t.Run("truncate_middle keeps the tool results of the first exchange", func(t *testing.T) {
+		call := ToolCall{ID: "t1", Type: "function"}
+		call.Function.Name = "read"
+		call.Function.Arguments = `{}`
+		withTools := append([]UnifiedMessage{
+			{Role: "user", Content: "task"},
+			{Role: "assistant", Content: "", ToolCalls: []ToolCall{call}},
+			{Role: "user", Content: "", ToolResults: []ToolResult{{ToolUseID: "t1", Content: "file contents"}}},
+			{Role: "assistant", Content: "done"},
+		}, budgetConversation(5)...)
+		budget := EstimateInputTokens(withTools, "", nil) / 2
+
+		got, err := fitContextBudget(withTools, "", nil, budget, TrimTruncateMiddle)
+
+		assert.NoError(t, err)
+		assert.Equal(t, withTools[:4], got[:4])
+		assert.True(t, strings.HasPrefix(got[4].Content.(string), "[6 earlier messages were omitted"))
+		assert.Equal(t, "current", got[len(got)-1].Content)
+	})
+}
+
+// =============================================================================
+// TestBuildKiroPayloadContextBudget
+// =============================================================================
+
+func TestBuildKiroPayloadContextBudget(t *testing.T) {
+	messages := budgetConversation(5)
+	total := EstimateInputTokens(messages, "", nil)
+
+	t.Run("trims history", func(t *testing.T) {
+		cfg := &config.Config{ToolDescriptionMaxLength: 10000, ContextTrimStrategy: TrimDrop}
+
+		payload, err := BuildKiroPayload(messages, "", "claude-haiku-4.5", nil, "conv", "", total/2, cfg)
+		assert.NoError(t, err)
+		assert.Len(t, payload.ConversationState.History, 4)
+		assert.Equal(t, "current", payload.ConversationState.CurrentMessage.UserInputMessage.Content)
+	})
+
+	t.Run("returns ContextLengthError", func(t *testing.T) {
+		cfg := &config.Config{ToolDescriptionMaxLength: 10000, ContextTrimStrategy: TrimError}
+
+		payload, err := BuildKiroPayload(messages, "", "claude-haiku-4.5", nil, "conv", "", total/2, cfg)
+		assert.Nil(t, payload)
+		assert.IsType(t, &ContextLengthError{}, err)
+	})
+}
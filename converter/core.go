@@ -2,12 +2,14 @@
 package converter
 
 import (
+	"errors"
 	"encoding/json"
 	"fmt"
 	"strings"
 
 	"kiro-go-proxy/config"
 	"kiro-go-proxy/model"
+	"kiro-go-proxy/tokens"
 	"kiro-go-proxy/utils"
 
 	log "github.com/sirupsen/logrus"
@@ -20,6 +22,9 @@ type UnifiedMessage struct {
 	ToolCalls   []ToolCall               `json:"tool_calls,omitempty"`
 	ToolResults []ToolResult             `json:"tool_results,omitempty"`
 	Images      []map[string]interface{} `json:"images,omitempty"`
+	// Reasoning is an assistant turn's reasoning_content or thinking blocks, as
+	// clients send earlier turns back
+	Reasoning string `json:"reasoning,omitempty"`
 }
 
 // ToolCall represents a tool call in unified format
@@ -38,6 +43,34 @@ type ToolResult struct {
 	Content   interface{} `json:"content"`
 }
 
+// toolCallIDs pairs tool results with earlier tool calls for formats that
+// identify calls by function name only (Gemini, Ollama). IDs are generated
+// when missing and results consume them per name in call order.
+type toolCallIDs map[string][]string
+
+// call records a tool call and returns its ID, generating one if id is empty
+func (ids toolCallIDs) call(name, id string) string {
+	if id == "" {
+		id = utils.GenerateToolCallID()
+	}
+	ids[name] = append(ids[name], id)
+	return id
+}
+
+// result returns the ID of the oldest unanswered call to name, preferring an explicit id
+func (ids toolCallIDs) result(name, id string) string {
+	if queue := ids[name]; len(queue) > 0 {
+		if id == "" {
+			id = queue[0]
+		}
+		ids[name] = queue[1:]
+	}
+	if id == "" {
+		id = utils.GenerateToolCallID()
+	}
+	return id
+}
+
 // UnifiedTool represents a tool in unified format
 type UnifiedTool struct {
 	Name        string                 `json:"name"`
@@ -54,6 +87,115 @@ type KiroPayload struct {
 		History          []interface{} `json:"history,omitempty"`
 	} `json:"conversationState"`
 	ProfileArn string `json:"profileArn,omitempty"`
+
+	// ToolNames maps tool names changed for Kiro back to the client's names
+	// (see SanitizeToolNames); it is not sent
+	ToolNames map[string]string `json:"-"`
+}
+
+// SetProfileArn sets the profile ARN of the account the payload is sent with
+func (p *KiroPayload) SetProfileArn(profileArn string) {
+	p.ProfileArn = profileArn
+}
+
+// Clone returns a deep copy of the payload, for sending it several times at once
+func (p *KiroPayload) Clone() *KiroPayload {
+	data, _ := json.Marshal(p)
+	var clone KiroPayload
+	json.Unmarshal(data, &clone)
+	clone.ToolNames = p.ToolNames
+	return &clone
+}
+
+var (
+	// textKeys are the string fields holding user data: message content and the text of tool results
+	textKeys = map[string]bool{"content": true, "text": true}
+	// textTreeKeys hold user data throughout: tool arguments and JSON tool results
+	textTreeKeys = map[string]bool{"input": true, "json": true}
+)
+
+// RewriteText passes the user data of the payload (message content, tool
+// arguments and tool results, current message and history) through fn and
+// reports whether anything changed. Tool definitions, IDs and images are left alone.
+func (p *KiroPayload) RewriteText(fn func(string) string) bool {
+	data, _ := json.Marshal(p)
+	var tree interface{}
+	json.Unmarshal(data, &tree)
+	if !rewriteText(tree, false, fn) {
+		return false
+	}
+
+	data, _ = json.Marshal(tree)
+	var rewritten KiroPayload
+	json.Unmarshal(data, &rewritten)
+	rewritten.ToolNames = p.ToolNames
+	*p = rewritten
+	return true
+}
+
+// rewriteText rewrites the user data in node in place and reports whether any
+// changed. all is set inside textTreeKeys, where every string is user data.
+func rewriteText(node interface{}, all bool, fn func(string) string) bool {
+	changed := false
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if text, ok := child.(string); ok {
+				if all || textKeys[key] {
+					if rewritten := fn(text); rewritten != text {
+						v[key] = rewritten
+						changed = true
+					}
+				}
+				continue
+			}
+			if key == "tools" && !all {
+				continue
+			}
+			if rewriteText(child, all || textTreeKeys[key], fn) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for i, child := range v {
+			if text, ok := child.(string); ok {
+				if all {
+					if rewritten := fn(text); rewritten != text {
+						v[i] = rewritten
+						changed = true
+					}
+				}
+				continue
+			}
+			if rewriteText(child, all, fn) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
+
+// ModelID returns the model the payload is sent to
+func (p *KiroPayload) ModelID() string {
+	return p.ConversationState.CurrentMessage.UserInputMessage.ModelID
+}
+
+// SetModelID switches the payload, including its history, to another model
+func (p *KiroPayload) SetModelID(modelID string) {
+	p.ConversationState.CurrentMessage.UserInputMessage.ModelID = modelID
+	for i, entry := range p.ConversationState.History {
+		entryMap, ok := entry.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		switch msg := entryMap["userInputMessage"].(type) {
+		case map[string]interface{}:
+			msg["modelId"] = modelID
+		case UserInputMessage:
+			msg.ModelID = modelID
+			p.ConversationState.History[i] = map[string]interface{}{"userInputMessage": msg}
+		}
+	}
 }
 
 // CurrentMessage represents the current message in Kiro format
@@ -68,6 +210,19 @@ type UserInputMessage struct {
 	Origin                  string                   `json:"origin"`
 	Images                  []map[string]interface{} `json:"images,omitempty"`
 	UserInputMessageContext *UserInputMessageContext `json:"userInputMessageContext,omitempty"`
+	InferenceConfiguration  *InferenceConfiguration  `json:"inferenceConfiguration,omitempty"`
+}
+
+// InferenceConfiguration carries client sampling settings to Kiro
+type InferenceConfiguration struct {
+	MaxTokens   *int     `json:"maxTokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"topP,omitempty"`
+}
+
+// IsEmpty reports whether no inference parameters are set
+func (ic *InferenceConfiguration) IsEmpty() bool {
+	return ic == nil || (ic.MaxTokens == nil && ic.Temperature == nil && ic.TopP == nil)
 }
 
 // UserInputMessageContext contains tools and tool results
@@ -76,7 +231,11 @@ type UserInputMessageContext struct {
 	ToolResults []map[string]interface{} `json:"toolResults,omitempty"`
 }
 
-// BuildKiroPayload builds a Kiro API payload from unified messages
+// BuildKiroPayload builds a Kiro API payload from unified messages. History is
+// trimmed per CONTEXT_TRIM_STRATEGY when the request is estimated to exceed
+// maxInputTokens (0 disables the check); a *ContextLengthError is returned when
+// it cannot be made to fit. Images are dropped when the model's profile disables
+// them; its thinking setting is expected in cfg already (see ApplyModelProfile).
 func BuildKiroPayload(
 	messages []UnifiedMessage,
 	systemPrompt string,
@@ -84,16 +243,17 @@ func BuildKiroPayload(
 	tools []UnifiedTool,
 	conversationID string,
 	profileArn string,
+	maxInputTokens int,
 	cfg *config.Config,
-) *KiroPayload {
-	// Process tools with long descriptions
-	processedTools, toolDocs := ProcessToolsWithLongDescriptions(tools, cfg.ToolDescriptionMaxLength)
+) (*KiroPayload, error) {
+	// Give tools names Kiro accepts, remembering the client's names
+	processedTools, toolNames := SanitizeToolNames(tools)
 
-	// Validate tool names
-	ValidateToolNames(processedTools)
+	// Process tools with long descriptions
+	processedTools, toolDocs := ProcessToolsWithLongDescriptions(processedTools, cfg.ToolDescriptionMaxLength)
 
-	// Build full system prompt
-	fullSystemPrompt := systemPrompt
+	// Build full system prompt, starting with the operator's system prompt policy
+	fullSystemPrompt := ApplySystemPromptPolicy(systemPrompt, modelID, cfg)
 	if toolDocs != "" {
 		if fullSystemPrompt != "" {
 			fullSystemPrompt += toolDocs
@@ -112,6 +272,21 @@ func BuildKiroPayload(
 		}
 	}
 
+	// Send client tool call IDs to Kiro unchanged where possible
+	messages = MapToolUseIDs(messages)
+	renameToolCalls(messages, toolNames)
+
+	// Strip or keep the reasoning of earlier assistant turns
+	messages = prepareHistoryReasoning(messages, cfg)
+
+	// Drop images for models whose profile disables them
+	if _, profile, ok := ModelProfileFor(cfg, modelID); ok && profile.DisableImages {
+		stripImages(messages)
+	}
+
+	// Keep the newest MAX_IMAGES images of the conversation
+	limitImages(messages, cfg.MaxImages)
+
 	// Handle messages without tools
 	var convertedToolResults bool
 	if len(tools) == 0 {
@@ -132,7 +307,13 @@ func BuildKiroPayload(
 
 	if len(messages) == 0 {
 		log.Warn("No messages to send")
-		return nil
+		return nil, errors.New("no messages to send")
+	}
+
+	// Keep the request within the model's context window
+	messages, err := fitContextBudget(messages, fullSystemPrompt, processedTools, maxInputTokens, cfg.ContextTrimStrategy)
+	if err != nil {
+		return nil, err
 	}
 
 	// Build history (all except last)
@@ -217,8 +398,68 @@ func BuildKiroPayload(
 	if profileArn != "" {
 		payload.ProfileArn = profileArn
 	}
+	payload.ToolNames = toolNames
+
+	return payload, nil
+}
+
+// ContinuationPrompt asks the model to resume a response cut off by the output limit
+const ContinuationPrompt = "Continue"
+
+// BuildContinuationPayload builds the follow-up request for a truncated response: the
+// original request and the partial answer move into history, and the model is asked to
+// continue. Tools and inference settings carry over to the new current message.
+func BuildContinuationPayload(payload *KiroPayload, partial string) *KiroPayload {
+	current := payload.ConversationState.CurrentMessage.UserInputMessage
+
+	// History entries carry tool results but not tool definitions or settings
+	previous := current
+	previous.InferenceConfiguration = nil
+	previous.UserInputMessageContext = nil
+	if ctx := current.UserInputMessageContext; ctx != nil && len(ctx.ToolResults) > 0 {
+		previous.UserInputMessageContext = &UserInputMessageContext{ToolResults: ctx.ToolResults}
+	}
+
+	if partial == "" {
+		partial = "(empty)"
+	}
+
+	next := &KiroPayload{ProfileArn: payload.ProfileArn}
+	next.ConversationState.ChatTriggerType = payload.ConversationState.ChatTriggerType
+	next.ConversationState.ConversationID = payload.ConversationState.ConversationID
+	next.ConversationState.History = append(append([]interface{}{}, payload.ConversationState.History...),
+		map[string]interface{}{"userInputMessage": previous},
+		map[string]interface{}{"assistantResponseMessage": map[string]interface{}{"content": partial}},
+	)
+
+	next.ConversationState.CurrentMessage.UserInputMessage = UserInputMessage{
+		Content:                ContinuationPrompt,
+		ModelID:                current.ModelID,
+		Origin:                 current.Origin,
+		InferenceConfiguration: current.InferenceConfiguration,
+	}
+	if ctx := current.UserInputMessageContext; ctx != nil && len(ctx.Tools) > 0 {
+		next.ConversationState.CurrentMessage.UserInputMessage.UserInputMessageContext = &UserInputMessageContext{Tools: ctx.Tools}
+	}
+
+	return next
+}
+
+// ApplyInferenceConfig forwards sampling settings to Kiro when enabled in config.
+// Otherwise they are dropped (max_tokens and stop are still emulated by the stream layer).
+func ApplyInferenceConfig(payload *KiroPayload, inference *InferenceConfiguration, cfg *config.Config) {
+	if payload == nil || inference.IsEmpty() {
+		return
+	}
+
+	if !cfg.ForwardInferenceConfig {
+		if inference.Temperature != nil || inference.TopP != nil {
+			log.Debug("Kiro API does not accept temperature/top_p; set KIRO_INFERENCE_CONFIG=true to forward them")
+		}
+		return
+	}
 
-	return payload
+	payload.ConversationState.CurrentMessage.UserInputMessage.InferenceConfiguration = inference
 }
 
 // BuildKiroHistory builds Kiro history from messages
@@ -293,7 +534,7 @@ func ConvertToolsToKiroFormat(tools []UnifiedTool) []map[string]interface{} {
 	var result []map[string]interface{}
 
 	for _, tool := range tools {
-		sanitizedParams := utils.SanitizeJSONSchema(tool.InputSchema)
+		sanitizedParams := toolSchemas.sanitize(tool.InputSchema)
 
 		desc := tool.Description
 		if desc == "" {
@@ -319,10 +560,7 @@ func ConvertToolResultsToKiroFormat(results []ToolResult) []map[string]interface
 	var kiroResults []map[string]interface{}
 
 	for _, tr := range results {
-		content := utils.ExtractTextContent(tr.Content)
-		if content == "" {
-			content = "(empty result)"
-		}
+		content := toolResultText(tr.Content)
 
 		kiroResults = append(kiroResults, map[string]interface{}{
 			"content": []map[string]interface{}{
@@ -416,15 +654,6 @@ func ProcessToolsWithLongDescriptions(tools []UnifiedTool, maxLen int) ([]Unifie
 	return processed, toolDocs
 }
 
-// ValidateToolNames validates tool names against Kiro API limit
-func ValidateToolNames(tools []UnifiedTool) {
-	for _, tool := range tools {
-		if len(tool.Name) > 64 {
-			log.Warnf("Tool name '%s' exceeds 64 character limit (%d chars)", tool.Name, len(tool.Name))
-		}
-	}
-}
-
 // GetThinkingSystemPromptAddition returns system prompt addition for thinking mode
 func GetThinkingSystemPromptAddition() string {
 	return `
@@ -510,10 +739,7 @@ func ToolCallsToText(calls []ToolCall) string {
 func ToolResultsToText(results []ToolResult) string {
 	var parts []string
 	for _, tr := range results {
-		content := utils.ExtractTextContent(tr.Content)
-		if content == "" {
-			content = "(empty result)"
-		}
+		content := toolResultText(tr.Content)
 		if tr.ToolUseID != "" {
 			parts = append(parts, fmt.Sprintf("[Tool Result (%s)]\n%s", tr.ToolUseID, content))
 		} else {
@@ -523,6 +749,23 @@ func ToolResultsToText(results []ToolResult) string {
 	return strings.Join(parts, "\n\n")
 }
 
+// toolResultText returns the text of a tool result. Images in the result are
+// sent with the user message, so an image-only result says so instead of
+// looking empty.
+func toolResultText(content interface{}) string {
+	if text := utils.ExtractTextContent(content); text != "" {
+		return text
+	}
+	if items, ok := content.([]interface{}); ok {
+		for _, item := range items {
+			if m, ok := item.(map[string]interface{}); ok && (m["type"] == "image" || m["type"] == "image_url") {
+				return "(image attached)"
+			}
+		}
+	}
+	return "(empty result)"
+}
+
 // MergeAdjacentMessages merges adjacent messages with the same role
 func MergeAdjacentMessages(messages []UnifiedMessage) []UnifiedMessage {
 	if len(messages) == 0 {
@@ -552,6 +795,11 @@ func MergeAdjacentMessages(messages []UnifiedMessage) []UnifiedMessage {
 			if len(msg.ToolResults) > 0 {
 				last.ToolResults = append(last.ToolResults, msg.ToolResults...)
 			}
+
+			// Merge images
+			if len(msg.Images) > 0 {
+				last.Images = append(last.Images, msg.Images...)
+			}
 		} else {
 			merged = append(merged, msg)
 		}
@@ -605,3 +853,17 @@ func ResolveModel(resolver *model.Resolver, modelName string) string {
 	resolution := resolver.Resolve(modelName)
 	return resolution.InternalID
 }
+
+// EstimateInputTokens estimates prompt tokens for unified messages, system prompt and tools
+func EstimateInputTokens(messages []UnifiedMessage, systemPrompt string, tools []UnifiedTool) int {
+	total := estimateFixedTokens(systemPrompt, tools)
+	for _, msg := range messages {
+		total += estimateMessageTokens(msg)
+	}
+
+	if len(messages) > 0 {
+		total += tokens.TokensPerReply
+	}
+
+	return tokens.ApplyCorrection(total)
+}
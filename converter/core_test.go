@@ -2,6 +2,8 @@
 package converter
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -136,6 +138,20 @@ func TestConvertToolResultsToKiroFormat(t *testing.T) {
 
 		assert.Len(t, kiroResults, 2)
 	})
+
+	t.Run("notes images in image-only results", func(t *testing.T) {
+		results := []ToolResult{{
+			ToolUseID: "tool_1",
+			Content: []interface{}{
+				map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "AAAA"}},
+			},
+		}}
+
+		kiroResults := ConvertToolResultsToKiroFormat(results)
+
+		content := kiroResults[0]["content"].([]map[string]interface{})
+		assert.Equal(t, "(image attached)", content[0]["text"])
+	})
 }
 
 // =============================================================================
@@ -189,26 +205,6 @@ func TestProcessToolsWithLongDescriptions(t *testing.T) {
 	})
 }
 
-// =============================================================================
-// TestValidateToolNames
-// Tests for tool name validation
-// =============================================================================
-
-func TestValidateToolNames(t *testing.T) {
-	t.Run("accepts valid names", func(t *testing.T) {
-		tools := []UnifiedTool{
-			{Name: "get_weather"},
-			{Name: "search_web"},
-			{Name: "calculate_sum"},
-		}
-
-		// Should not panic
-		assert.NotPanics(t, func() {
-			ValidateToolNames(tools)
-		})
-	})
-}
-
 // =============================================================================
 // TestBuildKiroHistory
 // Original: /code/github/kiro-gateway/tests/unit/test_converters_core.py::TestBuildKiroHistory
@@ -328,7 +324,7 @@ func TestBuildKiroPayload(t *testing.T) {
 			{Role: "user", Content: "Hello"},
 		}
 
-		payload := BuildKiroPayload(messages, "You are helpful", "claude-haiku-4.5", nil, "conv-123", "arn:profile", cfg)
+		payload, _ := BuildKiroPayload(messages, "You are helpful", "claude-haiku-4.5", nil, "conv-123", "arn:profile", 0, cfg)
 
 		assert.Equal(t, "MANUAL", payload.ConversationState.ChatTriggerType)
 		assert.Equal(t, "conv-123", payload.ConversationState.ConversationID)
@@ -345,7 +341,7 @@ func TestBuildKiroPayload(t *testing.T) {
 			{Name: "get_weather", Description: "Get weather"},
 		}
 
-		payload := BuildKiroPayload(messages, "", "model", tools, "conv", "", cfg)
+		payload, _ := BuildKiroPayload(messages, "", "model", tools, "conv", "", 0, cfg)
 
 		context := payload.ConversationState.CurrentMessage.UserInputMessage.UserInputMessageContext
 		assert.NotNil(t, context)
@@ -359,11 +355,66 @@ func TestBuildKiroPayload(t *testing.T) {
 			{Role: "user", Content: "Second"},
 		}
 
-		payload := BuildKiroPayload(messages, "", "model", nil, "conv", "", cfg)
+		payload, _ := BuildKiroPayload(messages, "", "model", nil, "conv", "", 0, cfg)
 
 		// History should have 2 entries (first user + assistant)
 		assert.Len(t, payload.ConversationState.History, 2)
 	})
+
+	t.Run("attaches tool result images to the user message", func(t *testing.T) {
+		screenshot := []interface{}{
+			map[string]interface{}{"type": "text", "text": "Screenshot taken"},
+			map[string]interface{}{"type": "image", "source": map[string]interface{}{"type": "base64", "media_type": "image/png", "data": "AAAA"}},
+		}
+		toolCall := ToolCall{ID: "toolu_1", Type: "function"}
+		toolCall.Function.Name = "screenshot"
+		toolCall.Function.Arguments = "{}"
+		tools := []UnifiedTool{{Name: "screenshot", Description: "Take a screenshot"}}
+		images := ExtractAnthropicToolResultImages(screenshot)
+
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "Open the page"},
+			{Role: "assistant", ToolCalls: []ToolCall{toolCall}},
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "toolu_1", Content: screenshot}}, Images: images},
+		}
+
+		payload, _ := BuildKiroPayload(messages, "", "model", tools, "conv", "", 0, cfg)
+
+		current := payload.ConversationState.CurrentMessage.UserInputMessage
+		assert.Equal(t, []map[string]interface{}{{"format": "png", "source": map[string]interface{}{"bytes": "AAAA"}}}, current.Images)
+		results := current.UserInputMessageContext.ToolResults
+		assert.Equal(t, "Screenshot taken", results[0]["content"].([]map[string]interface{})[0]["text"])
+
+		// In history the images stay with the tool results too
+		messages = append(messages, UnifiedMessage{Role: "assistant", Content: "Done"}, UnifiedMessage{Role: "user", Content: "Thanks"})
+		payload, _ = BuildKiroPayload(messages, "", "model", tools, "conv", "", 0, cfg)
+
+		history := payload.ConversationState.History[2].(map[string]interface{})["userInputMessage"].(map[string]interface{})
+		assert.Len(t, history["images"], 1)
+		assert.Contains(t, history, "userInputMessageContext")
+	})
+
+	t.Run("keeps history images up to MAX_IMAGES", func(t *testing.T) {
+		image := func(data string) []map[string]interface{} {
+			return []map[string]interface{}{{"media_type": "image/png", "data": data}}
+		}
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "First", Images: image("AAAA")},
+			{Role: "assistant", Content: "A cat"},
+			{Role: "user", Content: "Second", Images: image("BBBB")},
+			{Role: "assistant", Content: "A dog"},
+			{Role: "user", Content: "Compare them", Images: image("CCCC")},
+		}
+
+		payload, _ := BuildKiroPayload(messages, "", "model", nil, "conv", "", 0, &config.Config{ToolDescriptionMaxLength: 10000, MaxImages: 2})
+
+		history := payload.ConversationState.History
+		assert.NotContains(t, history[0].(map[string]interface{})["userInputMessage"], "images")
+		second := history[2].(map[string]interface{})["userInputMessage"].(map[string]interface{})
+		assert.Equal(t, []map[string]interface{}{{"format": "png", "source": map[string]interface{}{"bytes": "BBBB"}}}, second["images"])
+		assert.Len(t, payload.ConversationState.CurrentMessage.UserInputMessage.Images, 1)
+		assert.Len(t, messages[0].Images, 1)
+	})
 }
 
 // =============================================================================
@@ -387,6 +438,19 @@ func TestMergeAdjacentMessages(t *testing.T) {
 		assert.Contains(t, merged[0].Content, "World")
 	})
 
+	t.Run("merges images", func(t *testing.T) {
+		image := map[string]interface{}{"media_type": "image/png", "data": "AAAA"}
+		messages := []UnifiedMessage{
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "tool_1", Content: "ok"}}, Images: []map[string]interface{}{image}},
+			{Role: "user", Content: "What do you see?"},
+		}
+
+		merged := MergeAdjacentMessages(messages)
+
+		assert.Len(t, merged, 1)
+		assert.Equal(t, []map[string]interface{}{image}, merged[0].Images)
+	})
+
 	t.Run("keeps different roles separate", func(t *testing.T) {
 		messages := []UnifiedMessage{
 			{Role: "user", Content: "Hello"},
@@ -514,3 +578,218 @@ func TestEnsureAlternatingRoles(t *testing.T) {
 		assert.Equal(t, "user", result[0].Role)
 	})
 }
+
+// =============================================================================
+// TestEstimateInputTokens
+// Original: /code/github/kiro-gateway/tests/unit/test_tokenizer.py::TestCountMessageTokens
+// =============================================================================
+
+func TestEstimateInputTokens(t *testing.T) {
+	t.Run("empty input returns zero", func(t *testing.T) {
+		assert.Equal(t, 0, EstimateInputTokens(nil, "", nil))
+	})
+
+	t.Run("counts messages", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "Hello, world!"},
+		}
+
+		result := EstimateInputTokens(messages, "", nil)
+
+		assert.Greater(t, result, 0)
+	})
+
+	t.Run("system prompt increases count", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "Hello"},
+		}
+
+		without := EstimateInputTokens(messages, "", nil)
+		with := EstimateInputTokens(messages, "You are a helpful assistant.", nil)
+
+		assert.Greater(t, with, without)
+	})
+
+	t.Run("tools increase count", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "Hello"},
+		}
+		tools := []UnifiedTool{
+			{
+				Name:        "get_weather",
+				Description: "Get weather for a city",
+				InputSchema: map[string]interface{}{
+					"type": "object",
+					"properties": map[string]interface{}{
+						"city": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		}
+
+		without := EstimateInputTokens(messages, "", nil)
+		with := EstimateInputTokens(messages, "", tools)
+
+		assert.Greater(t, with, without)
+	})
+
+	t.Run("images add fixed overhead", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "Hello"},
+		}
+		withImage := []UnifiedMessage{
+			{Role: "user", Content: "Hello", Images: []map[string]interface{}{{"media_type": "image/png", "data": "abc"}}},
+		}
+
+		assert.Greater(t, EstimateInputTokens(withImage, "", nil), EstimateInputTokens(messages, "", nil))
+	})
+}
+
+// =============================================================================
+// TestApplyInferenceConfig
+// =============================================================================
+
+func TestApplyInferenceConfig(t *testing.T) {
+	messages := []UnifiedMessage{
+		{Role: "user", Content: "Hello"},
+	}
+	temperature := 0.2
+	maxTokens := 100
+
+	t.Run("drops inference config by default", func(t *testing.T) {
+		cfg := &config.Config{}
+		payload, _ := BuildKiroPayload(messages, "", "claude-haiku-4.5", nil, "conv-123", "", 0, cfg)
+
+		ApplyInferenceConfig(payload, &InferenceConfiguration{Temperature: &temperature}, cfg)
+
+		assert.Nil(t, payload.ConversationState.CurrentMessage.UserInputMessage.InferenceConfiguration)
+	})
+
+	t.Run("forwards inference config when enabled", func(t *testing.T) {
+		cfg := &config.Config{ForwardInferenceConfig: true}
+		payload, _ := BuildKiroPayload(messages, "", "claude-haiku-4.5", nil, "conv-123", "", 0, cfg)
+
+		ApplyInferenceConfig(payload, &InferenceConfiguration{Temperature: &temperature, MaxTokens: &maxTokens}, cfg)
+
+		ic := payload.ConversationState.CurrentMessage.UserInputMessage.InferenceConfiguration
+		assert.NotNil(t, ic)
+		assert.Equal(t, 0.2, *ic.Temperature)
+		assert.Equal(t, 100, *ic.MaxTokens)
+	})
+
+	t.Run("ignores empty inference config", func(t *testing.T) {
+		cfg := &config.Config{ForwardInferenceConfig: true}
+		payload, _ := BuildKiroPayload(messages, "", "claude-haiku-4.5", nil, "conv-123", "", 0, cfg)
+
+		ApplyInferenceConfig(payload, &InferenceConfiguration{}, cfg)
+
+		assert.Nil(t, payload.ConversationState.CurrentMessage.UserInputMessage.InferenceConfiguration)
+	})
+}
+
+// =============================================================================
+// TestBuildContinuationPayload
+// =============================================================================
+
+func TestBuildContinuationPayload(t *testing.T) {
+	cfg := &config.Config{ToolDescriptionMaxLength: 10000}
+	messages := []UnifiedMessage{
+		{Role: "user", Content: "First"},
+		{Role: "assistant", Content: "Response"},
+		{Role: "user", Content: "Write an essay"},
+	}
+	tools := []UnifiedTool{{Name: "get_weather", Description: "Get weather"}}
+
+	t.Run("moves request and partial answer into history", func(t *testing.T) {
+		payload, _ := BuildKiroPayload(messages, "", "claude-haiku-4.5", tools, "conv-123", "arn:profile", 0, cfg)
+
+		next := BuildContinuationPayload(payload, "The essay begins")
+
+		assert.Equal(t, "conv-123", next.ConversationState.ConversationID)
+		assert.Equal(t, "arn:profile", next.ProfileArn)
+		assert.Len(t, next.ConversationState.History, 4)
+		assert.Len(t, payload.ConversationState.History, 2)
+
+		previous := next.ConversationState.History[2].(map[string]interface{})["userInputMessage"].(UserInputMessage)
+		assert.Equal(t, "Write an essay", previous.Content)
+		assert.Nil(t, previous.UserInputMessageContext)
+
+		assistant := next.ConversationState.History[3].(map[string]interface{})["assistantResponseMessage"].(map[string]interface{})
+		assert.Equal(t, "The essay begins", assistant["content"])
+	})
+
+	t.Run("asks to continue with the same model and tools", func(t *testing.T) {
+		payload, _ := BuildKiroPayload(messages, "", "claude-haiku-4.5", tools, "conv-123", "", 0, cfg)
+
+		next := BuildContinuationPayload(payload, "partial")
+
+		current := next.ConversationState.CurrentMessage.UserInputMessage
+		assert.Equal(t, ContinuationPrompt, current.Content)
+		assert.Equal(t, "claude-haiku-4.5", current.ModelID)
+		assert.Len(t, current.UserInputMessageContext.Tools, 1)
+	})
+
+	t.Run("keeps tool results in history", func(t *testing.T) {
+		withResults := append(messages[:2:2], UnifiedMessage{
+			Role:        "user",
+			ToolResults: []ToolResult{{ToolUseID: "call_1", Content: "sunny"}},
+		})
+		payload, _ := BuildKiroPayload(withResults, "", "model", tools, "conv", "", 0, cfg)
+
+		next := BuildContinuationPayload(payload, "partial")
+
+		previous := next.ConversationState.History[2].(map[string]interface{})["userInputMessage"].(UserInputMessage)
+		assert.Len(t, previous.UserInputMessageContext.ToolResults, 1)
+		assert.Empty(t, previous.UserInputMessageContext.Tools)
+	})
+}
+
+// =============================================================================
+// TestRewriteText
+// =============================================================================
+
+func TestRewriteText(t *testing.T) {
+	cfg := &config.Config{ToolDescriptionMaxLength: 10000}
+	call := ToolCall{ID: "secret_call", Type: "function"}
+	call.Function.Name = "lookup"
+	call.Function.Arguments = `{"query": "secret in args", "tags": ["secret tag"]}`
+	messages := []UnifiedMessage{
+		{Role: "user", Content: "First secret"},
+		{Role: "assistant", Content: "Looking it up", ToolCalls: []ToolCall{call}},
+		{Role: "user", Content: "Now the secret", ToolResults: []ToolResult{{ToolUseID: "secret_call", Content: "secret result"}}},
+	}
+	tools := []UnifiedTool{{Name: "lookup", Description: "Find a secret"}}
+	upper := func(text string) string { return strings.ReplaceAll(text, "secret", "SECRET") }
+
+	t.Run("rewrites content, tool arguments and tool results", func(t *testing.T) {
+		payload, _ := BuildKiroPayload(messages, "", "claude-haiku-4.5", tools, "conv-123", "", 0, cfg)
+		payload.ToolNames = map[string]string{"lookup": "lookup"}
+
+		changed := payload.RewriteText(upper)
+
+		assert.True(t, changed)
+		data, _ := json.Marshal(payload)
+		body := string(data)
+		assert.Contains(t, body, "First SECRET")
+		assert.Contains(t, body, `SECRET in args`)
+		assert.Contains(t, body, `SECRET tag`)
+		assert.Contains(t, body, "SECRET result")
+		assert.Contains(t, payload.ConversationState.CurrentMessage.UserInputMessage.Content, "Now the SECRET")
+
+		// Identifiers and tool definitions are not user data
+		assert.Contains(t, body, `"toolUseId":"secret_call"`)
+		assert.Contains(t, body, "Find a secret")
+		assert.Equal(t, "claude-haiku-4.5", payload.ModelID())
+		assert.Equal(t, map[string]string{"lookup": "lookup"}, payload.ToolNames)
+	})
+
+	t.Run("leaves the payload alone when nothing changes", func(t *testing.T) {
+		payload, _ := BuildKiroPayload(messages, "", "claude-haiku-4.5", tools, "conv-123", "", 0, cfg)
+		history := payload.ConversationState.History
+
+		changed := payload.RewriteText(func(text string) string { return text })
+
+		assert.False(t, changed)
+		assert.Equal(t, history, payload.ConversationState.History)
+	})
+}
@@ -0,0 +1,268 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// GeminiRequest represents a Google Gemini generateContent request
+type GeminiRequest struct {
+	Contents          []GeminiContent         `json:"contents"`
+	SystemInstruction *GeminiContent          `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool            `json:"tools,omitempty"`
+	GenerationConfig  *GeminiGenerationConfig `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent is a turn of the conversation made of parts
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is a single part of a turn. Exactly one of its fields is normally set.
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	Thought          bool                    `json:"thought,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiBlob is inline base64 data such as an image
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiFunctionCall is a tool call made by the model
+type GeminiFunctionCall struct {
+	ID   string                 `json:"id,omitempty"`
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// GeminiFunctionResponse is the result of a tool call sent back by the client
+type GeminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// GeminiTool groups function declarations
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+// GeminiFunctionDeclaration is a tool definition in Gemini format
+type GeminiFunctionDeclaration struct {
+	Name                 string                 `json:"name"`
+	Description          string                 `json:"description,omitempty"`
+	Parameters           map[string]interface{} `json:"parameters,omitempty"`
+	ParametersJSONSchema map[string]interface{} `json:"parametersJsonSchema,omitempty"`
+}
+
+// GeminiGenerationConfig holds sampling settings
+type GeminiGenerationConfig struct {
+	Temperature     *float64 `json:"temperature,omitempty"`
+	TopP            *float64 `json:"topP,omitempty"`
+	TopK            *int     `json:"topK,omitempty"`
+	MaxOutputTokens int      `json:"maxOutputTokens,omitempty"`
+	StopSequences   []string `json:"stopSequences,omitempty"`
+	CandidateCount  int      `json:"candidateCount,omitempty"`
+}
+
+// GeminiResponse is a generateContent response, or one chunk of a streamed response
+type GeminiResponse struct {
+	Candidates    []GeminiCandidate    `json:"candidates"`
+	UsageMetadata *GeminiUsageMetadata `json:"usageMetadata,omitempty"`
+	ModelVersion  string               `json:"modelVersion,omitempty"`
+	ResponseID    string               `json:"responseId,omitempty"`
+}
+
+// GeminiCandidate is one generated answer
+type GeminiCandidate struct {
+	Content      GeminiContent `json:"content"`
+	FinishReason string        `json:"finishReason,omitempty"`
+	Index        int           `json:"index"`
+}
+
+// GeminiUsageMetadata reports token usage
+type GeminiUsageMetadata struct {
+	PromptTokenCount     int `json:"promptTokenCount"`
+	CandidatesTokenCount int `json:"candidatesTokenCount"`
+	TotalTokenCount      int `json:"totalTokenCount"`
+}
+
+// Validate checks the request for missing or malformed fields
+func (r *GeminiRequest) Validate() error {
+	if len(r.Contents) == 0 {
+		return fmt.Errorf("contents: at least one content is required")
+	}
+
+	for i, content := range r.Contents {
+		switch content.Role {
+		case "", "user", "model", "function":
+		default:
+			return fmt.Errorf("contents[%d].role: must be 'user' or 'model', got '%s'", i, content.Role)
+		}
+		for j, part := range content.Parts {
+			if part.FunctionCall != nil && part.FunctionCall.Name == "" {
+				return fmt.Errorf("contents[%d].parts[%d].functionCall.name: field required", i, j)
+			}
+			if part.FunctionResponse != nil && part.FunctionResponse.Name == "" {
+				return fmt.Errorf("contents[%d].parts[%d].functionResponse.name: field required", i, j)
+			}
+		}
+	}
+
+	for i, tool := range r.Tools {
+		for j, decl := range tool.FunctionDeclarations {
+			if decl.Name == "" {
+				return fmt.Errorf("tools[%d].functionDeclarations[%d].name: field required", i, j)
+			}
+		}
+	}
+
+	if gc := r.GenerationConfig; gc != nil {
+		if gc.CandidateCount > 1 {
+			return fmt.Errorf("generationConfig.candidateCount: only 1 candidate is supported")
+		}
+		if gc.MaxOutputTokens < 0 {
+			return fmt.Errorf("generationConfig.maxOutputTokens: must not be negative")
+		}
+	}
+
+	return nil
+}
+
+// ConvertGeminiToUnified converts a Gemini request's contents to unified format.
+// Gemini function calls usually carry no ID, so IDs are generated and matched to
+// function responses by function name in call order.
+func ConvertGeminiToUnified(req *GeminiRequest) ([]UnifiedMessage, string) {
+	var systemPrompt string
+	if req.SystemInstruction != nil {
+		systemPrompt = geminiText(req.SystemInstruction.Parts, "\n")
+	}
+
+	pendingIDs := make(toolCallIDs)
+
+	var messages []UnifiedMessage
+	for _, content := range req.Contents {
+		role := "user"
+		if content.Role == "model" {
+			role = "assistant"
+		}
+
+		unifiedMsg := UnifiedMessage{
+			Role:    role,
+			Content: geminiText(content.Parts, ""),
+		}
+
+		for _, part := range content.Parts {
+			switch {
+			case part.FunctionCall != nil:
+				id := pendingIDs.call(part.FunctionCall.Name, part.FunctionCall.ID)
+				toolCall := ToolCall{ID: id, Type: "function"}
+				toolCall.Function.Name = part.FunctionCall.Name
+				toolCall.Function.Arguments = marshalArgs(part.FunctionCall.Args)
+				unifiedMsg.ToolCalls = append(unifiedMsg.ToolCalls, toolCall)
+
+			case part.FunctionResponse != nil:
+				id := pendingIDs.result(part.FunctionResponse.Name, part.FunctionResponse.ID)
+				unifiedMsg.ToolResults = append(unifiedMsg.ToolResults, ToolResult{
+					ToolUseID: id,
+					Content:   marshalArgs(part.FunctionResponse.Response),
+				})
+
+			case part.InlineData != nil && strings.HasPrefix(part.InlineData.MimeType, "image/"):
+				unifiedMsg.Images = append(unifiedMsg.Images, map[string]interface{}{
+					"media_type": part.InlineData.MimeType,
+					"data":       part.InlineData.Data,
+				})
+			}
+		}
+
+		messages = append(messages, unifiedMsg)
+	}
+
+	return messages, systemPrompt
+}
+
+// ConvertGeminiToolsToUnified converts Gemini function declarations to unified format
+func ConvertGeminiToolsToUnified(tools []GeminiTool) []UnifiedTool {
+	var unified []UnifiedTool
+	for _, tool := range tools {
+		for _, decl := range tool.FunctionDeclarations {
+			if decl.Name == "" {
+				continue
+			}
+			schema := decl.Parameters
+			if schema == nil {
+				schema = decl.ParametersJSONSchema
+			}
+			unified = append(unified, UnifiedTool{
+				Name:        decl.Name,
+				Description: decl.Description,
+				InputSchema: schema,
+			})
+		}
+	}
+	return unified
+}
+
+// CreateGeminiResponse creates a non-streaming Gemini response
+func CreateGeminiResponse(id, model string, content string, toolCalls []ToolCall, finishReason string, usage *GeminiUsageMetadata) *GeminiResponse {
+	parts := []GeminiPart{}
+	if content != "" {
+		parts = append(parts, GeminiPart{Text: content})
+	}
+	for _, tc := range toolCalls {
+		parts = append(parts, GeminiPart{
+			FunctionCall: GeminiFunctionCallFromToolCall(tc.ID, tc.Function.Name, tc.Function.Arguments),
+		})
+	}
+
+	return &GeminiResponse{
+		Candidates: []GeminiCandidate{{
+			Content:      GeminiContent{Role: "model", Parts: parts},
+			FinishReason: finishReason,
+			Index:        0,
+		}},
+		UsageMetadata: usage,
+		ModelVersion:  model,
+		ResponseID:    id,
+	}
+}
+
+// GeminiFunctionCallFromToolCall converts a tool call's JSON arguments to a Gemini function call
+func GeminiFunctionCallFromToolCall(id, name, arguments string) *GeminiFunctionCall {
+	args := map[string]interface{}{}
+	if arguments != "" {
+		json.Unmarshal([]byte(arguments), &args)
+	}
+	return &GeminiFunctionCall{ID: id, Name: name, Args: args}
+}
+
+// geminiText joins the non-thought text parts with sep
+func geminiText(parts []GeminiPart, sep string) string {
+	var texts []string
+	for _, part := range parts {
+		if part.Text != "" && !part.Thought {
+			texts = append(texts, part.Text)
+		}
+	}
+	return strings.Join(texts, sep)
+}
+
+// marshalArgs encodes function arguments or a function response as JSON
+func marshalArgs(value map[string]interface{}) string {
+	if value == nil {
+		return "{}"
+	}
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "{}"
+	}
+	return string(b)
+}
@@ -0,0 +1,173 @@
+// Package converter provides tests for Gemini format conversion.
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseGeminiRequest(t *testing.T, body string) *GeminiRequest {
+	var req GeminiRequest
+	assert.NoError(t, json.Unmarshal([]byte(body), &req))
+	return &req
+}
+
+// =============================================================================
+// TestGeminiRequestValidate
+// =============================================================================
+
+func TestGeminiRequestValidate(t *testing.T) {
+	t.Run("accepts a minimal request", func(t *testing.T) {
+		req := parseGeminiRequest(t, `{"contents": [{"parts": [{"text": "Hi"}]}]}`)
+		assert.NoError(t, req.Validate())
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"empty contents", `{"contents": []}`, "contents"},
+		{"unknown role", `{"contents": [{"role": "system", "parts": [{"text": "Hi"}]}]}`, "contents[0].role"},
+		{"unnamed function call", `{"contents": [{"role": "model", "parts": [{"functionCall": {"args": {}}}]}]}`, "functionCall.name"},
+		{"unnamed declaration", `{"contents": [{"parts": [{"text": "Hi"}]}], "tools": [{"functionDeclarations": [{}]}]}`, "functionDeclarations[0].name"},
+		{"several candidates", `{"contents": [{"parts": [{"text": "Hi"}]}], "generationConfig": {"candidateCount": 2}}`, "candidateCount"},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			err := parseGeminiRequest(t, tt.body).Validate()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+// =============================================================================
+// TestConvertGeminiToUnified
+// =============================================================================
+
+func TestConvertGeminiToUnified(t *testing.T) {
+	t.Run("maps roles and system instruction", func(t *testing.T) {
+		req := parseGeminiRequest(t, `{
+			"systemInstruction": {"parts": [{"text": "Be brief."}, {"text": "Be kind."}]},
+			"contents": [
+				{"role": "user", "parts": [{"text": "Hi"}]},
+				{"role": "model", "parts": [{"text": "Hello"}, {"text": "!"}]}
+			]
+		}`)
+
+		messages, system := ConvertGeminiToUnified(req)
+
+		assert.Equal(t, "Be brief.\nBe kind.", system)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, "user", messages[0].Role)
+		assert.Equal(t, "Hi", messages[0].Content)
+		assert.Equal(t, "assistant", messages[1].Role)
+		assert.Equal(t, "Hello!", messages[1].Content)
+	})
+
+	t.Run("pairs function responses with calls by name", func(t *testing.T) {
+		req := parseGeminiRequest(t, `{"contents": [
+			{"role": "user", "parts": [{"text": "Weather?"}]},
+			{"role": "model", "parts": [
+				{"functionCall": {"name": "get_weather", "args": {"city": "Paris"}}},
+				{"functionCall": {"name": "get_weather", "args": {"city": "Rome"}}}
+			]},
+			{"role": "user", "parts": [
+				{"functionResponse": {"name": "get_weather", "response": {"temp": 20}}},
+				{"functionResponse": {"name": "get_weather", "response": {"temp": 25}}}
+			]}
+		]}`)
+
+		messages, _ := ConvertGeminiToUnified(req)
+
+		calls := messages[1].ToolCalls
+		assert.Len(t, calls, 2)
+		assert.Equal(t, "get_weather", calls[0].Function.Name)
+		assert.JSONEq(t, `{"city": "Paris"}`, calls[0].Function.Arguments)
+		assert.NotEqual(t, calls[0].ID, calls[1].ID)
+
+		results := messages[2].ToolResults
+		assert.Len(t, results, 2)
+		assert.Equal(t, calls[0].ID, results[0].ToolUseID)
+		assert.Equal(t, calls[1].ID, results[1].ToolUseID)
+		assert.JSONEq(t, `{"temp": 25}`, results[1].Content.(string))
+	})
+
+	t.Run("keeps explicit call IDs", func(t *testing.T) {
+		req := parseGeminiRequest(t, `{"contents": [
+			{"role": "model", "parts": [{"functionCall": {"id": "call_1", "name": "search", "args": {}}}]},
+			{"role": "user", "parts": [{"functionResponse": {"id": "call_1", "name": "search", "response": {}}}]}
+		]}`)
+
+		messages, _ := ConvertGeminiToUnified(req)
+
+		assert.Equal(t, "call_1", messages[0].ToolCalls[0].ID)
+		assert.Equal(t, "call_1", messages[1].ToolResults[0].ToolUseID)
+	})
+
+	t.Run("converts inline images", func(t *testing.T) {
+		req := parseGeminiRequest(t, `{"contents": [{"parts": [
+			{"text": "What is this?"},
+			{"inlineData": {"mimeType": "image/png", "data": "aGVsbG8="}},
+			{"inlineData": {"mimeType": "application/pdf", "data": "cGRm"}}
+		]}]}`)
+
+		messages, _ := ConvertGeminiToUnified(req)
+
+		assert.Equal(t, []map[string]interface{}{
+			{"media_type": "image/png", "data": "aGVsbG8="},
+		}, messages[0].Images)
+	})
+}
+
+// =============================================================================
+// TestConvertGeminiToolsToUnified
+// =============================================================================
+
+func TestConvertGeminiToolsToUnified(t *testing.T) {
+	req := parseGeminiRequest(t, `{"contents": [], "tools": [{"functionDeclarations": [
+		{"name": "get_weather", "description": "Get weather", "parameters": {"type": "object"}},
+		{"name": "search", "parametersJsonSchema": {"type": "object", "required": ["q"]}}
+	]}]}`)
+
+	tools := ConvertGeminiToolsToUnified(req.Tools)
+
+	assert.Len(t, tools, 2)
+	assert.Equal(t, "get_weather", tools[0].Name)
+	assert.Equal(t, "Get weather", tools[0].Description)
+	assert.Equal(t, map[string]interface{}{"type": "object"}, tools[0].InputSchema)
+	assert.Equal(t, "search", tools[1].Name)
+	assert.Equal(t, []interface{}{"q"}, tools[1].InputSchema["required"])
+}
+
+// =============================================================================
+// TestCreateGeminiResponse
+// =============================================================================
+
+func TestCreateGeminiResponse(t *testing.T) {
+	toolCall := ToolCall{ID: "call_1", Type: "function"}
+	toolCall.Function.Name = "search"
+	toolCall.Function.Arguments = `{"q": "go"}`
+
+	resp := CreateGeminiResponse("conv-1", "gemini-pro", "Let me look", []ToolCall{toolCall}, "STOP",
+		&GeminiUsageMetadata{PromptTokenCount: 3, CandidatesTokenCount: 4, TotalTokenCount: 7})
+
+	b, err := json.Marshal(resp)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"candidates": [{
+			"content": {"role": "model", "parts": [
+				{"text": "Let me look"},
+				{"functionCall": {"id": "call_1", "name": "search", "args": {"q": "go"}}}
+			]},
+			"finishReason": "STOP",
+			"index": 0
+		}],
+		"usageMetadata": {"promptTokenCount": 3, "candidatesTokenCount": 4, "totalTokenCount": 7},
+		"modelVersion": "gemini-pro",
+		"responseId": "conv-1"
+	}`, string(b))
+}
@@ -0,0 +1,284 @@
+// Package converter handles conversion between API formats and Kiro format.
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Response format types supported by chat completions
+const (
+	ResponseFormatText       = "text"
+	ResponseFormatJSONObject = "json_object"
+	ResponseFormatJSONSchema = "json_schema"
+)
+
+// RequiresJSON reports whether the response format asks for JSON output
+func (f *OpenAIResponseFormat) RequiresJSON() bool {
+	return f != nil && (f.Type == ResponseFormatJSONObject || f.Type == ResponseFormatJSONSchema)
+}
+
+// GetResponseFormatSystemPromptAddition returns the system prompt addition for JSON mode
+func GetResponseFormatSystemPromptAddition(format *OpenAIResponseFormat) string {
+	if !format.RequiresJSON() {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("\n\n---\n# Response Format\n\n")
+	sb.WriteString("Respond ONLY with a single valid JSON value. Do not wrap it in markdown code fences and do not add any text before or after it.")
+
+	if format.Type == ResponseFormatJSONObject || format.JSONSchema == nil || format.JSONSchema.Schema == nil {
+		sb.WriteString(" The top-level value must be a JSON object.")
+		return sb.String()
+	}
+
+	schema, err := json.MarshalIndent(format.JSONSchema.Schema, "", "  ")
+	if err != nil {
+		return sb.String()
+	}
+
+	sb.WriteString(" The JSON must conform to the following JSON Schema")
+	if format.JSONSchema.Name != "" {
+		sb.WriteString(fmt.Sprintf(" (%s)", format.JSONSchema.Name))
+	}
+	sb.WriteString(":\n\n")
+	if format.JSONSchema.Description != "" {
+		sb.WriteString(format.JSONSchema.Description + "\n\n")
+	}
+	sb.WriteString(string(schema))
+	return sb.String()
+}
+
+// RepairJSONResponse extracts and validates JSON from model output.
+// Markdown fences, surrounding prose and trailing commas are removed.
+// Returns the cleaned JSON or an error describing why the output is unusable.
+func RepairJSONResponse(content string, format *OpenAIResponseFormat) (string, error) {
+	candidate := extractJSON(content)
+	if candidate == "" {
+		return "", fmt.Errorf("response contains no JSON")
+	}
+
+	var value interface{}
+	if err := json.Unmarshal([]byte(candidate), &value); err != nil {
+		candidate = removeTrailingCommas(candidate)
+		if err := json.Unmarshal([]byte(candidate), &value); err != nil {
+			return "", fmt.Errorf("invalid JSON: %w", err)
+		}
+	}
+
+	if format.Type == ResponseFormatJSONSchema && format.JSONSchema != nil && format.JSONSchema.Schema != nil {
+		if err := ValidateJSONSchema(value, format.JSONSchema.Schema); err != nil {
+			return "", fmt.Errorf("schema validation failed: %w", err)
+		}
+	} else if _, ok := value.(map[string]interface{}); !ok {
+		return "", fmt.Errorf("top-level JSON value must be an object")
+	}
+
+	return candidate, nil
+}
+
+// extractJSON strips code fences and surrounding text, returning the outermost JSON value
+func extractJSON(content string) string {
+	text := strings.TrimSpace(content)
+
+	if idx := strings.Index(text, "```"); idx != -1 {
+		rest := text[idx+3:]
+		if nl := strings.Index(rest, "\n"); nl != -1 {
+			rest = rest[nl+1:]
+		}
+		if end := strings.Index(rest, "```"); end != -1 {
+			rest = rest[:end]
+		}
+		text = strings.TrimSpace(rest)
+	}
+
+	start := strings.IndexAny(text, "{[")
+	if start == -1 {
+		return ""
+	}
+	closer := "}"
+	if text[start] == '[' {
+		closer = "]"
+	}
+	end := strings.LastIndex(text, closer)
+	if end < start {
+		return ""
+	}
+	return text[start : end+1]
+}
+
+// removeTrailingCommas drops commas directly preceding a closing brace or bracket
+func removeTrailingCommas(text string) string {
+	var sb strings.Builder
+	inString := false
+	escaped := false
+
+	for i := 0; i < len(text); i++ {
+		ch := text[i]
+		if inString {
+			sb.WriteByte(ch)
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\':
+				escaped = true
+			case ch == '"':
+				inString = false
+			}
+			continue
+		}
+
+		if ch == '"' {
+			inString = true
+		} else if ch == ',' {
+			j := i + 1
+			for j < len(text) && strings.ContainsRune(" \t\r\n", rune(text[j])) {
+				j++
+			}
+			if j < len(text) && (text[j] == '}' || text[j] == ']') {
+				continue
+			}
+		}
+		sb.WriteByte(ch)
+	}
+	return sb.String()
+}
+
+// ValidateJSONSchema validates a decoded JSON value against a subset of JSON Schema:
+// type, enum, const, properties, required, additionalProperties and items.
+func ValidateJSONSchema(value interface{}, schema map[string]interface{}) error {
+	return validateSchemaAt("$", value, schema)
+}
+
+func validateSchemaAt(path string, value interface{}, schema map[string]interface{}) error {
+	if schemaType, ok := schema["type"]; ok {
+		if !matchesSchemaType(value, schemaType) {
+			return fmt.Errorf("%s: expected type %v, got %s", path, schemaType, jsonTypeName(value))
+		}
+	}
+
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, allowed := range enum {
+			if jsonEqual(value, allowed) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("%s: value not in enum", path)
+		}
+	}
+
+	if constValue, ok := schema["const"]; ok && !jsonEqual(value, constValue) {
+		return fmt.Errorf("%s: value does not match const", path)
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		properties, _ := schema["properties"].(map[string]interface{})
+
+		if required, ok := schema["required"].([]interface{}); ok {
+			for _, r := range required {
+				name, _ := r.(string)
+				if _, present := v[name]; !present {
+					return fmt.Errorf("%s: missing required property '%s'", path, name)
+				}
+			}
+		}
+
+		if additional, ok := schema["additionalProperties"].(bool); ok && !additional {
+			keys := make([]string, 0, len(v))
+			for key := range v {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				if _, declared := properties[key]; !declared {
+					return fmt.Errorf("%s: unexpected property '%s'", path, key)
+				}
+			}
+		}
+
+		for name, propSchema := range properties {
+			propValue, present := v[name]
+			ps, ok := propSchema.(map[string]interface{})
+			if !present || !ok {
+				continue
+			}
+			if err := validateSchemaAt(path+"."+name, propValue, ps); err != nil {
+				return err
+			}
+		}
+
+	case []interface{}:
+		if items, ok := schema["items"].(map[string]interface{}); ok {
+			for i, item := range v {
+				if err := validateSchemaAt(fmt.Sprintf("%s[%d]", path, i), item, items); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// matchesSchemaType checks a value against a "type" keyword (string or list of strings)
+func matchesSchemaType(value interface{}, schemaType interface{}) bool {
+	switch t := schemaType.(type) {
+	case string:
+		return matchesTypeName(value, t)
+	case []interface{}:
+		for _, item := range t {
+			if name, ok := item.(string); ok && matchesTypeName(value, name) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+func matchesTypeName(value interface{}, name string) bool {
+	switch name {
+	case "integer":
+		n, ok := value.(float64)
+		return ok && n == math.Trunc(n)
+	case "number":
+		_, ok := value.(float64)
+		return ok
+	default:
+		return jsonTypeName(value) == name
+	}
+}
+
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "unknown"
+	}
+}
+
+func jsonEqual(a, b interface{}) bool {
+	aj, errA := json.Marshal(a)
+	bj, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(aj) == string(bj)
+}
@@ -0,0 +1,147 @@
+// Package converter provides tests for JSON mode support.
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestGetResponseFormatSystemPromptAddition
+// =============================================================================
+
+func TestGetResponseFormatSystemPromptAddition(t *testing.T) {
+	t.Run("returns empty for text format", func(t *testing.T) {
+		assert.Equal(t, "", GetResponseFormatSystemPromptAddition(nil))
+		assert.Equal(t, "", GetResponseFormatSystemPromptAddition(&OpenAIResponseFormat{Type: "text"}))
+	})
+
+	t.Run("requests object for json_object", func(t *testing.T) {
+		addition := GetResponseFormatSystemPromptAddition(&OpenAIResponseFormat{Type: "json_object"})
+
+		assert.Contains(t, addition, "valid JSON")
+		assert.Contains(t, addition, "JSON object")
+	})
+
+	t.Run("includes schema for json_schema", func(t *testing.T) {
+		addition := GetResponseFormatSystemPromptAddition(&OpenAIResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &OpenAIJSONSchema{
+				Name:   "weather",
+				Schema: map[string]interface{}{"type": "object", "required": []interface{}{"city"}},
+			},
+		})
+
+		assert.Contains(t, addition, "weather")
+		assert.Contains(t, addition, `"required"`)
+	})
+}
+
+// =============================================================================
+// TestRepairJSONResponse
+// =============================================================================
+
+func TestRepairJSONResponse(t *testing.T) {
+	jsonObject := &OpenAIResponseFormat{Type: "json_object"}
+
+	t.Run("accepts valid JSON", func(t *testing.T) {
+		result, err := RepairJSONResponse(`{"a": 1}`, jsonObject)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"a": 1}`, result)
+	})
+
+	t.Run("strips markdown fences and prose", func(t *testing.T) {
+		result, err := RepairJSONResponse("Here you go:\n```json\n{\"a\": 1}\n```\nDone.", jsonObject)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"a": 1}`, result)
+	})
+
+	t.Run("removes trailing commas", func(t *testing.T) {
+		result, err := RepairJSONResponse(`{"a": [1, 2,], "b": "x,}",}`, jsonObject)
+
+		assert.NoError(t, err)
+		assert.Equal(t, `{"a": [1, 2], "b": "x,}"}`, result)
+	})
+
+	t.Run("rejects non-JSON output", func(t *testing.T) {
+		_, err := RepairJSONResponse("I cannot do that.", jsonObject)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects non-object for json_object", func(t *testing.T) {
+		_, err := RepairJSONResponse(`[1, 2]`, jsonObject)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("validates against schema", func(t *testing.T) {
+		format := &OpenAIResponseFormat{
+			Type: "json_schema",
+			JSONSchema: &OpenAIJSONSchema{
+				Name: "person",
+				Schema: map[string]interface{}{
+					"type":     "object",
+					"required": []interface{}{"name"},
+					"properties": map[string]interface{}{
+						"name": map[string]interface{}{"type": "string"},
+					},
+				},
+			},
+		}
+
+		_, err := RepairJSONResponse(`{"name": "Ann"}`, format)
+		assert.NoError(t, err)
+
+		_, err = RepairJSONResponse(`{"age": 3}`, format)
+		assert.Error(t, err)
+	})
+}
+
+// =============================================================================
+// TestValidateJSONSchema
+// =============================================================================
+
+func TestValidateJSONSchema(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties": map[string]interface{}{
+			"count": map[string]interface{}{"type": "integer"},
+			"unit":  map[string]interface{}{"type": "string", "enum": []interface{}{"c", "f"}},
+			"tags":  map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string"}},
+			"note":  map[string]interface{}{"type": []interface{}{"string", "null"}},
+		},
+	}
+
+	t.Run("accepts matching value", func(t *testing.T) {
+		value := map[string]interface{}{"count": float64(3), "unit": "c", "tags": []interface{}{"a"}, "note": nil}
+
+		assert.NoError(t, ValidateJSONSchema(value, schema))
+	})
+
+	t.Run("rejects wrong type", func(t *testing.T) {
+		err := ValidateJSONSchema(map[string]interface{}{"count": 1.5}, schema)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "$.count")
+	})
+
+	t.Run("rejects value outside enum", func(t *testing.T) {
+		assert.Error(t, ValidateJSONSchema(map[string]interface{}{"unit": "k"}, schema))
+	})
+
+	t.Run("rejects invalid array item", func(t *testing.T) {
+		err := ValidateJSONSchema(map[string]interface{}{"tags": []interface{}{"a", float64(1)}}, schema)
+
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), "$.tags[1]")
+	})
+
+	t.Run("rejects additional properties", func(t *testing.T) {
+		assert.Error(t, ValidateJSONSchema(map[string]interface{}{"extra": true}, schema))
+	})
+}
@@ -0,0 +1,141 @@
+package converter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/tokens"
+	"kiro-go-proxy/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RequestLimitError is returned by CheckRequestLimits when a request exceeds one
+// of the MAX_* limits
+type RequestLimitError struct {
+	Message string
+}
+
+func (e *RequestLimitError) Error() string {
+	return e.Message
+}
+
+// CheckRequestLimits enforces MAX_MESSAGES, MAX_TOOLS, MAX_PROMPT_CHARS and
+// MAX_IMAGE_BYTES on a converted request. Prompt characters count the system
+// prompt, message text, tool call arguments and tool results.
+func CheckRequestLimits(messages []UnifiedMessage, systemPrompt string, tools []UnifiedTool, cfg *config.Config) error {
+	if cfg.MaxMessages > 0 && len(messages) > cfg.MaxMessages {
+		return &RequestLimitError{fmt.Sprintf("Request has %d messages, the limit is %d", len(messages), cfg.MaxMessages)}
+	}
+	if cfg.MaxTools > 0 && len(tools) > cfg.MaxTools {
+		return &RequestLimitError{fmt.Sprintf("Request has %d tools, the limit is %d", len(tools), cfg.MaxTools)}
+	}
+
+	chars := utf8.RuneCountInString(systemPrompt)
+	for i, msg := range messages {
+		chars += promptChars(msg)
+
+		if cfg.MaxImageBytes > 0 {
+			for j, img := range msg.Images {
+				data, _ := img["data"].(string)
+				if size := decodedImageSize(data); size > cfg.MaxImageBytes {
+					return &RequestLimitError{fmt.Sprintf("Image %d of message %d is %d bytes, the limit is %d", j, i, size, cfg.MaxImageBytes)}
+				}
+			}
+		}
+	}
+	if cfg.MaxPromptChars > 0 && chars > cfg.MaxPromptChars {
+		return &RequestLimitError{fmt.Sprintf("Request has %d prompt characters, the limit is %d", chars, cfg.MaxPromptChars)}
+	}
+	return nil
+}
+
+// promptChars counts the characters of a message that MAX_PROMPT_CHARS limits:
+// its text, tool call arguments and tool results
+func promptChars(msg UnifiedMessage) int {
+	chars := utf8.RuneCountInString(utils.ExtractTextContent(msg.Content))
+	for _, tc := range msg.ToolCalls {
+		chars += utf8.RuneCountInString(tc.Function.Arguments)
+	}
+	for _, tr := range msg.ToolResults {
+		chars += utf8.RuneCountInString(utils.ExtractTextContent(tr.Content))
+	}
+	return chars
+}
+
+// FitHistory returns the newest part of a stored history that, put ahead of
+// messages, keeps the request within MAX_MESSAGES, MAX_PROMPT_CHARS and
+// maxInputTokens (0 is unlimited). The part starts at a user message without
+// tool results, so no tool result is left without its call. Stored
+// conversations thus never outgrow what a request may carry.
+func FitHistory(history, messages []UnifiedMessage, systemPrompt string, tools []UnifiedTool, maxInputTokens int, cfg *config.Config) []UnifiedMessage {
+	count := len(messages)
+	chars := utf8.RuneCountInString(systemPrompt)
+	total := estimateFixedTokens(systemPrompt, tools) + tokens.TokensPerReply
+	for _, msg := range messages {
+		chars += promptChars(msg)
+		total += estimateMessageTokens(msg)
+	}
+
+	start := len(history)
+	for i := len(history) - 1; i >= 0; i-- {
+		count++
+		chars += promptChars(history[i])
+		total += estimateMessageTokens(history[i])
+		if (cfg.MaxMessages > 0 && count > cfg.MaxMessages) ||
+			(cfg.MaxPromptChars > 0 && chars > cfg.MaxPromptChars) ||
+			(maxInputTokens > 0 && tokens.ApplyCorrection(total) > maxInputTokens) {
+			break
+		}
+		if history[i].Role == "user" && len(history[i].ToolResults) == 0 {
+			start = i
+		}
+	}
+
+	if start > 0 {
+		log.Infof("Dropped %d of %d stored history messages to fit the request limits", start, len(history))
+	}
+	return history[start:]
+}
+
+// decodedImageSize returns the decoded size of base64 image data, which may
+// carry a data URL prefix
+func decodedImageSize(data string) int {
+	if strings.HasPrefix(data, "data:") {
+		if comma := strings.IndexByte(data, ','); comma >= 0 {
+			data = data[comma+1:]
+		}
+	}
+	return base64.RawStdEncoding.DecodedLen(len(strings.TrimRight(data, "=")))
+}
+
+// limitImages keeps the newest maxImages images across all messages (0 is
+// unlimited), so the current message keeps its images before any history turn
+func limitImages(messages []UnifiedMessage, maxImages int) {
+	if maxImages <= 0 {
+		return
+	}
+
+	remaining := maxImages
+	dropped := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		images := messages[i].Images
+		if len(images) <= remaining {
+			remaining -= len(images)
+			continue
+		}
+		dropped += len(images) - remaining
+		if remaining == 0 {
+			messages[i].Images = nil
+		} else {
+			messages[i].Images = images[:remaining:remaining]
+		}
+		remaining = 0
+	}
+	if dropped > 0 {
+		log.Infof("Dropped %d image(s) from the oldest messages to stay within %d images", dropped, maxImages)
+	}
+}
@@ -0,0 +1,160 @@
+// Package converter provides tests for request limit checks.
+package converter
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// =============================================================================
+// TestCheckRequestLimits
+// =============================================================================
+
+func TestCheckRequestLimits(t *testing.T) {
+	call := ToolCall{ID: "t1", Type: "function"}
+	call.Function.Name = "f"
+	call.Function.Arguments = `{"a":1}`
+	messages := []UnifiedMessage{
+		{Role: "user", Content: "Hello"},
+		{Role: "assistant", Content: "", ToolCalls: []ToolCall{call}},
+		{Role: "user", ToolResults: []ToolResult{{ToolUseID: "t1", Content: "done"}}},
+	}
+	tools := []UnifiedTool{{Name: "f"}, {Name: "g"}}
+
+	t.Run("zero limits are disabled", func(t *testing.T) {
+		assert.NoError(t, CheckRequestLimits(messages, strings.Repeat("s", 100000), tools, &config.Config{}))
+	})
+
+	t.Run("message count", func(t *testing.T) {
+		err := CheckRequestLimits(messages, "", tools, &config.Config{MaxMessages: 2})
+		assert.EqualError(t, err, "Request has 3 messages, the limit is 2")
+		assert.NoError(t, CheckRequestLimits(messages, "", tools, &config.Config{MaxMessages: 3}))
+	})
+
+	t.Run("tool count", func(t *testing.T) {
+		err := CheckRequestLimits(messages, "", tools, &config.Config{MaxTools: 1})
+		assert.IsType(t, &RequestLimitError{}, err)
+		assert.EqualError(t, err, "Request has 2 tools, the limit is 1")
+	})
+
+	t.Run("prompt characters count system, text, arguments and results", func(t *testing.T) {
+		// "sys" + "Hello" + `{"a":1}` + "done" = 3 + 5 + 7 + 4
+		assert.NoError(t, CheckRequestLimits(messages, "sys", tools, &config.Config{MaxPromptChars: 19}))
+		assert.EqualError(t, CheckRequestLimits(messages, "sys", tools, &config.Config{MaxPromptChars: 18}),
+			"Request has 19 prompt characters, the limit is 18")
+	})
+
+	t.Run("prompt characters count runes", func(t *testing.T) {
+		msgs := []UnifiedMessage{{Role: "user", Content: "héllo"}}
+		assert.NoError(t, CheckRequestLimits(msgs, "", nil, &config.Config{MaxPromptChars: 5}))
+	})
+
+	t.Run("image size", func(t *testing.T) {
+		data := base64.StdEncoding.EncodeToString(make([]byte, 1000))
+		msgs := []UnifiedMessage{{Role: "user", Content: "see", Images: []map[string]interface{}{{"media_type": "image/png", "data": data}}}}
+
+		assert.NoError(t, CheckRequestLimits(msgs, "", nil, &config.Config{MaxImageBytes: 1000}))
+		assert.EqualError(t, CheckRequestLimits(msgs, "", nil, &config.Config{MaxImageBytes: 999}),
+			"Image 0 of message 0 is 1000 bytes, the limit is 999")
+
+		msgs[0].Images[0]["data"] = "data:image/png;base64," + data
+		assert.Error(t, CheckRequestLimits(msgs, "", nil, &config.Config{MaxImageBytes: 999}))
+	})
+}
+
+// =============================================================================
+// TestFitHistory
+// =============================================================================
+
+func TestFitHistory(t *testing.T) {
+	call := ToolCall{ID: "t1", Type: "function"}
+	call.Function.Name = "f"
+	history := []UnifiedMessage{
+		{Role: "user", Content: "one"},
+		{Role: "assistant", Content: "two"},
+		{Role: "user", Content: "three"},
+		{Role: "assistant", ToolCalls: []ToolCall{call}},
+		{Role: "user", ToolResults: []ToolResult{{ToolUseID: "t1", Content: "four"}}},
+		{Role: "assistant", Content: "five"},
+	}
+	messages := []UnifiedMessage{{Role: "user", Content: "six"}}
+
+	t.Run("keeps a history within the limits", func(t *testing.T) {
+		assert.Equal(t, history, FitHistory(history, messages, "", nil, 0, &config.Config{MaxMessages: 7}))
+	})
+
+	t.Run("drops the oldest turns beyond MAX_MESSAGES", func(t *testing.T) {
+		assert.Equal(t, history[2:], FitHistory(history, messages, "", nil, 0, &config.Config{MaxMessages: 6}))
+	})
+
+	t.Run("never starts at a tool result", func(t *testing.T) {
+		// The last three messages fit, but the tool result needs its call
+		assert.Empty(t, FitHistory(history, messages, "", nil, 0, &config.Config{MaxMessages: 4}))
+	})
+
+	t.Run("drops the oldest turns beyond MAX_PROMPT_CHARS", func(t *testing.T) {
+		// "three" + "four" + "five" + "six" = 16 characters
+		assert.Equal(t, history[2:], FitHistory(history, messages, "", nil, 0, &config.Config{MaxPromptChars: 18}))
+	})
+
+	t.Run("drops the oldest turns beyond the input tokens", func(t *testing.T) {
+		long := []UnifiedMessage{{Role: "user", Content: strings.Repeat("word ", 2000)}, {Role: "assistant", Content: "ok"}}
+		fitted := FitHistory(append(long, history...), messages, "", nil, 1000, &config.Config{})
+		assert.Equal(t, history, fitted)
+	})
+}
+
+// =============================================================================
+// TestLimitImages
+// =============================================================================
+
+func TestLimitImages(t *testing.T) {
+	images := func(names ...string) []map[string]interface{} {
+		var result []map[string]interface{}
+		for _, name := range names {
+			result = append(result, map[string]interface{}{"media_type": "image/png", "data": name})
+		}
+		return result
+	}
+	newMessages := func() []UnifiedMessage {
+		return []UnifiedMessage{
+			{Role: "user", Content: "first", Images: images("a", "b")},
+			{Role: "assistant", Content: "ok"},
+			{Role: "user", Content: "second", Images: images("c", "d")},
+			{Role: "assistant", Content: "ok"},
+			{Role: "user", Content: "current", Images: images("e")},
+		}
+	}
+
+	t.Run("drops the oldest images", func(t *testing.T) {
+		messages := newMessages()
+
+		limitImages(messages, 2)
+
+		assert.Empty(t, messages[0].Images)
+		assert.Equal(t, images("c"), messages[2].Images)
+		assert.Equal(t, images("e"), messages[4].Images)
+	})
+
+	t.Run("keeps the current message first", func(t *testing.T) {
+		messages := newMessages()
+
+		limitImages(messages, 1)
+
+		assert.Empty(t, messages[0].Images)
+		assert.Empty(t, messages[2].Images)
+		assert.Equal(t, images("e"), messages[4].Images)
+	})
+
+	t.Run("zero is unlimited", func(t *testing.T) {
+		messages := newMessages()
+
+		limitImages(messages, 0)
+
+		assert.Equal(t, newMessages(), messages)
+	})
+}
@@ -0,0 +1,334 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// OllamaChatRequest represents an Ollama /api/chat request
+type OllamaChatRequest struct {
+	Model    string          `json:"model"`
+	Messages []OllamaMessage `json:"messages"`
+	Tools    []OpenAITool    `json:"tools,omitempty"`
+	Format   json.RawMessage `json:"format,omitempty"`
+	Options  *OllamaOptions  `json:"options,omitempty"`
+	Stream   *bool           `json:"stream,omitempty"`
+	Think    json.RawMessage `json:"think,omitempty"`
+}
+
+// OllamaGenerateRequest represents an Ollama /api/generate request
+type OllamaGenerateRequest struct {
+	Model   string          `json:"model"`
+	Prompt  string          `json:"prompt"`
+	System  string          `json:"system,omitempty"`
+	Images  []string        `json:"images,omitempty"`
+	Format  json.RawMessage `json:"format,omitempty"`
+	Options *OllamaOptions  `json:"options,omitempty"`
+	Stream  *bool           `json:"stream,omitempty"`
+	Think   json.RawMessage `json:"think,omitempty"`
+}
+
+// OllamaMessage is a chat message. Images are base64 strings without a data URL prefix.
+type OllamaMessage struct {
+	Role      string           `json:"role"`
+	Content   string           `json:"content"`
+	Thinking  string           `json:"thinking,omitempty"`
+	Images    []string         `json:"images,omitempty"`
+	ToolCalls []OllamaToolCall `json:"tool_calls,omitempty"`
+	ToolName  string           `json:"tool_name,omitempty"`
+}
+
+// OllamaToolCall is a tool call made by the model. Ollama tool calls carry no ID.
+type OllamaToolCall struct {
+	Function OllamaToolCallFunction `json:"function"`
+}
+
+// OllamaToolCallFunction holds the called function and its decoded arguments
+type OllamaToolCallFunction struct {
+	Name      string                 `json:"name"`
+	Arguments map[string]interface{} `json:"arguments"`
+}
+
+// OllamaOptions holds the model options supported by the proxy. Others are ignored.
+type OllamaOptions struct {
+	Temperature *float64 `json:"temperature,omitempty"`
+	TopP        *float64 `json:"top_p,omitempty"`
+	TopK        *int     `json:"top_k,omitempty"`
+	NumPredict  *int     `json:"num_predict,omitempty"`
+	Stop        []string `json:"stop,omitempty"`
+}
+
+// OllamaChatResponse is an /api/chat response, or one line of a streamed response
+type OllamaChatResponse struct {
+	Model     string         `json:"model"`
+	CreatedAt string         `json:"created_at"`
+	Message   *OllamaMessage `json:"message"`
+	OllamaDone
+}
+
+// OllamaGenerateResponse is an /api/generate response, or one line of a streamed response
+type OllamaGenerateResponse struct {
+	Model     string `json:"model"`
+	CreatedAt string `json:"created_at"`
+	Response  string `json:"response"`
+	Thinking  string `json:"thinking,omitempty"`
+	OllamaDone
+}
+
+// OllamaDone holds the completion fields. Only the final response sets more than Done.
+type OllamaDone struct {
+	Done            bool   `json:"done"`
+	DoneReason      string `json:"done_reason,omitempty"`
+	TotalDuration   int64  `json:"total_duration,omitempty"`
+	PromptEvalCount int    `json:"prompt_eval_count,omitempty"`
+	EvalCount       int    `json:"eval_count,omitempty"`
+}
+
+// OllamaTagsResponse is the /api/tags model list
+type OllamaTagsResponse struct {
+	Models []OllamaModel `json:"models"`
+}
+
+// OllamaModel describes an "installed" model
+type OllamaModel struct {
+	Name       string             `json:"name"`
+	Model      string             `json:"model"`
+	ModifiedAt string             `json:"modified_at"`
+	Size       int64              `json:"size"`
+	Digest     string             `json:"digest"`
+	Details    OllamaModelDetails `json:"details"`
+}
+
+// OllamaModelDetails holds model metadata. Kiro models are remote, so most fields are placeholders.
+type OllamaModelDetails struct {
+	Format            string   `json:"format"`
+	Family            string   `json:"family"`
+	Families          []string `json:"families"`
+	ParameterSize     string   `json:"parameter_size"`
+	QuantizationLevel string   `json:"quantization_level"`
+}
+
+// OllamaStreaming reports whether a response should be streamed. Ollama streams unless told not to.
+func OllamaStreaming(stream *bool) bool {
+	return stream == nil || *stream
+}
+
+// OllamaModelName strips the default ":latest" tag that Ollama clients append to model names
+func OllamaModelName(name string) string {
+	return strings.TrimSuffix(name, ":latest")
+}
+
+// Validate checks the request for missing or malformed fields
+func (r *OllamaChatRequest) Validate() error {
+	if r.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if len(r.Messages) == 0 {
+		return fmt.Errorf("messages: at least one message is required")
+	}
+	for i, msg := range r.Messages {
+		switch msg.Role {
+		case "system", "user", "assistant", "tool":
+		default:
+			return fmt.Errorf("messages[%d].role: must be 'system', 'user', 'assistant' or 'tool', got '%s'", i, msg.Role)
+		}
+	}
+	return nil
+}
+
+// Validate checks the request for missing or malformed fields
+func (r *OllamaGenerateRequest) Validate() error {
+	if r.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if r.Prompt == "" && len(r.Images) == 0 {
+		return fmt.Errorf("prompt is required")
+	}
+	return nil
+}
+
+// ConvertOllamaToUnified converts Ollama chat messages to unified format.
+// Tool results name the function they answer, so they are paired with the
+// generated call IDs by function name in call order.
+func ConvertOllamaToUnified(messages []OllamaMessage) ([]UnifiedMessage, string) {
+	var unified []UnifiedMessage
+	var systemParts []string
+	pendingIDs := make(toolCallIDs)
+
+	for _, msg := range messages {
+		switch msg.Role {
+		case "system":
+			systemParts = append(systemParts, msg.Content)
+
+		case "assistant":
+			unifiedMsg := UnifiedMessage{Role: "assistant", Content: msg.Content}
+			for _, tc := range msg.ToolCalls {
+				toolCall := ToolCall{ID: pendingIDs.call(tc.Function.Name, ""), Type: "function"}
+				toolCall.Function.Name = tc.Function.Name
+				toolCall.Function.Arguments = marshalArgs(tc.Function.Arguments)
+				unifiedMsg.ToolCalls = append(unifiedMsg.ToolCalls, toolCall)
+			}
+			unified = append(unified, unifiedMsg)
+
+		case "tool":
+			result := ToolResult{
+				ToolUseID: pendingIDs.result(msg.ToolName, ""),
+				Content:   msg.Content,
+			}
+			// Consecutive tool results belong to the same user turn
+			if len(unified) > 0 && unified[len(unified)-1].Role == "user" && len(unified[len(unified)-1].ToolResults) > 0 {
+				unified[len(unified)-1].ToolResults = append(unified[len(unified)-1].ToolResults, result)
+			} else {
+				unified = append(unified, UnifiedMessage{Role: "user", ToolResults: []ToolResult{result}})
+			}
+
+		default:
+			unified = append(unified, UnifiedMessage{
+				Role:    "user",
+				Content: msg.Content,
+				Images:  ConvertOllamaImages(msg.Images),
+			})
+		}
+	}
+
+	return unified, strings.Join(systemParts, "\n")
+}
+
+// ConvertOllamaGenerateToUnified converts an Ollama generate request to a single user message
+func ConvertOllamaGenerateToUnified(req *OllamaGenerateRequest) ([]UnifiedMessage, string) {
+	return []UnifiedMessage{{
+		Role:    "user",
+		Content: req.Prompt,
+		Images:  ConvertOllamaImages(req.Images),
+	}}, req.System
+}
+
+// ConvertOllamaImages converts raw base64 images to unified format, detecting the image type from its header
+func ConvertOllamaImages(images []string) []map[string]interface{} {
+	var result []map[string]interface{}
+	for _, data := range images {
+		if data == "" {
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"media_type": detectBase64ImageType(data),
+			"data":       data,
+		})
+	}
+	return result
+}
+
+// base64ImagePrefixes maps the base64 encoding of image file signatures to media types
+var base64ImagePrefixes = []struct {
+	prefix    string
+	mediaType string
+}{
+	{"iVBORw0KGgo", "image/png"},
+	{"/9j/", "image/jpeg"},
+	{"R0lGOD", "image/gif"},
+	{"UklGR", "image/webp"},
+}
+
+// detectBase64ImageType guesses the media type of base64 image data, defaulting to JPEG
+func detectBase64ImageType(data string) string {
+	for _, p := range base64ImagePrefixes {
+		if strings.HasPrefix(data, p.prefix) {
+			return p.mediaType
+		}
+	}
+	return "image/jpeg"
+}
+
+// OllamaResponseFormat converts the Ollama "format" field ("json" or a JSON schema) to a response format
+func OllamaResponseFormat(format json.RawMessage) (*OpenAIResponseFormat, error) {
+	if len(format) == 0 || string(format) == "null" || string(format) == `""` {
+		return nil, nil
+	}
+
+	var name string
+	if err := json.Unmarshal(format, &name); err == nil {
+		if name != "json" {
+			return nil, fmt.Errorf("format: must be 'json' or a JSON schema, got '%s'", name)
+		}
+		return &OpenAIResponseFormat{Type: ResponseFormatJSONObject}, nil
+	}
+
+	var schema map[string]interface{}
+	if err := json.Unmarshal(format, &schema); err != nil {
+		return nil, fmt.Errorf("format: must be 'json' or a JSON schema")
+	}
+	return &OpenAIResponseFormat{
+		Type:       ResponseFormatJSONSchema,
+		JSONSchema: &OpenAIJSONSchema{Name: "response", Schema: schema},
+	}, nil
+}
+
+// OllamaReasoningEffort converts the Ollama "think" field (a bool or "low"/"medium"/"high")
+// to a reasoning effort for ApplyReasoningEffort
+func OllamaReasoningEffort(think json.RawMessage) (string, error) {
+	if len(think) == 0 || string(think) == "null" {
+		return "", nil
+	}
+
+	var enabled bool
+	if err := json.Unmarshal(think, &enabled); err == nil {
+		if enabled {
+			return "medium", nil
+		}
+		return "none", nil
+	}
+
+	var level string
+	if err := json.Unmarshal(think, &level); err != nil {
+		return "", fmt.Errorf("think: must be a boolean or 'low', 'medium', 'high'")
+	}
+	switch level {
+	case "low", "medium", "high":
+		return level, nil
+	default:
+		return "", fmt.Errorf("think: must be a boolean or 'low', 'medium', 'high', got '%s'", level)
+	}
+}
+
+// GetMaxTokens returns the num_predict limit. Ollama uses negative values for "no limit".
+func (o *OllamaOptions) GetMaxTokens() *int {
+	if o == nil || o.NumPredict == nil || *o.NumPredict <= 0 {
+		return nil
+	}
+	return o.NumPredict
+}
+
+// OllamaInferenceConfig converts Ollama options to a Kiro inference configuration
+func OllamaInferenceConfig(options *OllamaOptions) *InferenceConfiguration {
+	if options == nil {
+		return &InferenceConfiguration{}
+	}
+	return &InferenceConfiguration{
+		MaxTokens:   options.GetMaxTokens(),
+		Temperature: options.Temperature,
+		TopP:        options.TopP,
+	}
+}
+
+// OllamaToolCallsFromUnified converts tool calls with JSON arguments to Ollama format
+func OllamaToolCallsFromUnified(calls []ToolCall) []OllamaToolCall {
+	if len(calls) == 0 {
+		return nil
+	}
+
+	result := make([]OllamaToolCall, len(calls))
+	for i, tc := range calls {
+		result[i] = NewOllamaToolCall(tc.Function.Name, tc.Function.Arguments)
+	}
+	return result
+}
+
+// NewOllamaToolCall creates an Ollama tool call from JSON-encoded arguments
+func NewOllamaToolCall(name, arguments string) OllamaToolCall {
+	args := map[string]interface{}{}
+	if arguments != "" {
+		json.Unmarshal([]byte(arguments), &args)
+	}
+	return OllamaToolCall{Function: OllamaToolCallFunction{Name: name, Arguments: args}}
+}
@@ -0,0 +1,199 @@
+// Package converter provides tests for Ollama format conversion.
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseOllamaChatRequest(t *testing.T, body string) *OllamaChatRequest {
+	var req OllamaChatRequest
+	assert.NoError(t, json.Unmarshal([]byte(body), &req))
+	return &req
+}
+
+// =============================================================================
+// TestOllamaChatRequestValidate
+// =============================================================================
+
+func TestOllamaChatRequestValidate(t *testing.T) {
+	t.Run("accepts a minimal request", func(t *testing.T) {
+		req := parseOllamaChatRequest(t, `{"model": "m", "messages": [{"role": "user", "content": "Hi"}]}`)
+		assert.NoError(t, req.Validate())
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing model", `{"messages": [{"role": "user", "content": "Hi"}]}`, "model"},
+		{"empty messages", `{"model": "m", "messages": []}`, "messages"},
+		{"unknown role", `{"model": "m", "messages": [{"role": "bot", "content": "Hi"}]}`, "messages[0].role"},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			err := parseOllamaChatRequest(t, tt.body).Validate()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+// =============================================================================
+// TestConvertOllamaToUnified
+// =============================================================================
+
+func TestConvertOllamaToUnified(t *testing.T) {
+	t.Run("maps roles and joins system messages", func(t *testing.T) {
+		req := parseOllamaChatRequest(t, `{"model": "m", "messages": [
+			{"role": "system", "content": "Be brief."},
+			{"role": "system", "content": "Be kind."},
+			{"role": "user", "content": "Hi", "images": ["iVBORw0KGgoAAAA"]},
+			{"role": "assistant", "content": "Hello"}
+		]}`)
+
+		messages, system := ConvertOllamaToUnified(req.Messages)
+
+		assert.Equal(t, "Be brief.\nBe kind.", system)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, "user", messages[0].Role)
+		assert.Equal(t, []map[string]interface{}{{"media_type": "image/png", "data": "iVBORw0KGgoAAAA"}}, messages[0].Images)
+		assert.Equal(t, "assistant", messages[1].Role)
+	})
+
+	t.Run("pairs tool results with calls by name", func(t *testing.T) {
+		req := parseOllamaChatRequest(t, `{"model": "m", "messages": [
+			{"role": "user", "content": "Weather?"},
+			{"role": "assistant", "content": "", "tool_calls": [
+				{"function": {"name": "get_weather", "arguments": {"city": "Paris"}}},
+				{"function": {"name": "get_time", "arguments": {}}}
+			]},
+			{"role": "tool", "tool_name": "get_time", "content": "noon"},
+			{"role": "tool", "tool_name": "get_weather", "content": "sunny"}
+		]}`)
+
+		messages, _ := ConvertOllamaToUnified(req.Messages)
+
+		assert.Len(t, messages, 3)
+		calls := messages[1].ToolCalls
+		assert.Len(t, calls, 2)
+		assert.JSONEq(t, `{"city": "Paris"}`, calls[0].Function.Arguments)
+
+		results := messages[2].ToolResults
+		assert.Len(t, results, 2)
+		assert.Equal(t, calls[1].ID, results[0].ToolUseID)
+		assert.Equal(t, "noon", results[0].Content)
+		assert.Equal(t, calls[0].ID, results[1].ToolUseID)
+	})
+}
+
+// =============================================================================
+// TestConvertOllamaImages
+// =============================================================================
+
+func TestConvertOllamaImages(t *testing.T) {
+	tests := []struct {
+		data string
+		want string
+	}{
+		{"iVBORw0KGgoAAAA", "image/png"},
+		{"/9j/4AAQ", "image/jpeg"},
+		{"R0lGODlh", "image/gif"},
+		{"UklGRiQA", "image/webp"},
+		{"AAAA", "image/jpeg"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			images := ConvertOllamaImages([]string{tt.data, ""})
+			assert.Len(t, images, 1)
+			assert.Equal(t, tt.want, images[0]["media_type"])
+		})
+	}
+}
+
+// =============================================================================
+// TestOllamaResponseFormat
+// =============================================================================
+
+func TestOllamaResponseFormat(t *testing.T) {
+	t.Run("maps json to a JSON object", func(t *testing.T) {
+		format, err := OllamaResponseFormat(json.RawMessage(`"json"`))
+		assert.NoError(t, err)
+		assert.Equal(t, ResponseFormatJSONObject, format.Type)
+	})
+
+	t.Run("maps a schema to json_schema", func(t *testing.T) {
+		format, err := OllamaResponseFormat(json.RawMessage(`{"type": "object"}`))
+		assert.NoError(t, err)
+		assert.Equal(t, ResponseFormatJSONSchema, format.Type)
+		assert.Equal(t, map[string]interface{}{"type": "object"}, format.JSONSchema.Schema)
+	})
+
+	t.Run("returns nil without format", func(t *testing.T) {
+		format, err := OllamaResponseFormat(nil)
+		assert.NoError(t, err)
+		assert.Nil(t, format)
+	})
+
+	t.Run("rejects other values", func(t *testing.T) {
+		_, err := OllamaResponseFormat(json.RawMessage(`"yaml"`))
+		assert.Error(t, err)
+	})
+}
+
+// =============================================================================
+// TestOllamaReasoningEffort
+// =============================================================================
+
+func TestOllamaReasoningEffort(t *testing.T) {
+	tests := []struct {
+		think string
+		want  string
+	}{
+		{"", ""},
+		{"true", "medium"},
+		{"false", "none"},
+		{`"high"`, "high"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.think, func(t *testing.T) {
+			effort, err := OllamaReasoningEffort(json.RawMessage(tt.think))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, effort)
+		})
+	}
+
+	t.Run("rejects unknown levels", func(t *testing.T) {
+		_, err := OllamaReasoningEffort(json.RawMessage(`"max"`))
+		assert.Error(t, err)
+	})
+}
+
+// =============================================================================
+// TestOllamaOptions
+// =============================================================================
+
+func TestOllamaOptions(t *testing.T) {
+	t.Run("ignores non-positive num_predict", func(t *testing.T) {
+		unlimited := -1
+		assert.Nil(t, (&OllamaOptions{NumPredict: &unlimited}).GetMaxTokens())
+		assert.Nil(t, (*OllamaOptions)(nil).GetMaxTokens())
+	})
+
+	t.Run("maps options to inference config", func(t *testing.T) {
+		maxTokens := 128
+		temperature := 0.2
+		inference := OllamaInferenceConfig(&OllamaOptions{NumPredict: &maxTokens, Temperature: &temperature})
+
+		assert.Equal(t, 128, *inference.MaxTokens)
+		assert.Equal(t, 0.2, *inference.Temperature)
+	})
+
+	t.Run("strips the latest tag", func(t *testing.T) {
+		assert.Equal(t, "claude-sonnet-4.5", OllamaModelName("claude-sonnet-4.5:latest"))
+		assert.Equal(t, "claude-sonnet-4.5", OllamaModelName("claude-sonnet-4.5"))
+	})
+}
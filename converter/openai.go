@@ -3,7 +3,10 @@ package converter
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 
+	"kiro-go-proxy/config"
 	"kiro-go-proxy/utils"
 
 	log "github.com/sirupsen/logrus"
@@ -19,11 +22,37 @@ type OpenAIRequest struct {
 	Tools            []OpenAITool       `json:"tools,omitempty"`
 	Temperature      *float64           `json:"temperature,omitempty"`
 	MaxTokens        *int               `json:"max_tokens,omitempty"`
+	MaxCompletionTokens *int            `json:"max_completion_tokens,omitempty"`
 	TopP             *float64           `json:"top_p,omitempty"`
 	FrequencyPenalty *float64           `json:"frequency_penalty,omitempty"`
 	PresencePenalty  *float64           `json:"presence_penalty,omitempty"`
 	Stop             interface{}        `json:"stop,omitempty"`
 	N                *int               `json:"n,omitempty"`
+	ResponseFormat   *OpenAIResponseFormat `json:"response_format,omitempty"`
+	ReasoningEffort  string             `json:"reasoning_effort,omitempty"`
+	ParallelToolCalls *bool             `json:"parallel_tool_calls,omitempty"`
+
+	// Accepted but not supported by Kiro; see UnsupportedParams
+	Logprobs    bool               `json:"logprobs,omitempty"`
+	TopLogprobs *int               `json:"top_logprobs,omitempty"`
+	Seed        *int64             `json:"seed,omitempty"`
+	LogitBias   map[string]float64 `json:"logit_bias,omitempty"`
+	Modalities  []string           `json:"modalities,omitempty"`
+	Audio       json.RawMessage    `json:"audio,omitempty"`
+}
+
+// OpenAIResponseFormat represents the response_format request field
+type OpenAIResponseFormat struct {
+	Type       string            `json:"type"`
+	JSONSchema *OpenAIJSONSchema `json:"json_schema,omitempty"`
+}
+
+// OpenAIJSONSchema represents a json_schema structured output definition
+type OpenAIJSONSchema struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      *bool                  `json:"strict,omitempty"`
 }
 
 // OpenAIMessage represents an OpenAI message
@@ -33,6 +62,9 @@ type OpenAIMessage struct {
 	Name      string          `json:"name,omitempty"`
 	ToolCalls []OpenAIToolCall `json:"tool_calls,omitempty"`
 	ToolCallID string          `json:"tool_call_id,omitempty"`
+	// ReasoningContent carries fake reasoning in responses, as DeepSeek does, and
+	// the reasoning of earlier assistant turns in requests
+	ReasoningContent string `json:"reasoning_content,omitempty"`
 }
 
 // OpenAIToolCall represents a tool call in OpenAI format
@@ -54,6 +86,29 @@ type OpenAITool struct {
 	Function OpenAIFunctionDef  `json:"function"`
 }
 
+// Builtin tool types. Declared as {"type": "web_fetch"} in tools, they are run by
+// the proxy itself instead of being returned to the client (see the agent package).
+const (
+	BuiltinToolWebFetch = "web_fetch"
+	BuiltinToolShell    = "shell"
+)
+
+// IsBuiltinTool reports whether toolType is a builtin tool type
+func IsBuiltinTool(toolType string) bool {
+	return toolType == BuiltinToolWebFetch || toolType == BuiltinToolShell
+}
+
+// BuiltinTools returns the builtin tool types declared in the request, in order
+func (r *OpenAIRequest) BuiltinTools() []string {
+	var builtin []string
+	for _, tool := range r.Tools {
+		if IsBuiltinTool(tool.Type) {
+			builtin = append(builtin, tool.Type)
+		}
+	}
+	return builtin
+}
+
 // OpenAIFunctionDef represents a function definition
 type OpenAIFunctionDef struct {
 	Name        string                 `json:"name"`
@@ -69,6 +124,11 @@ type OpenAIResponse struct {
 	Model   string           `json:"model"`
 	Choices []OpenAIChoice   `json:"choices"`
 	Usage   *OpenAIUsage     `json:"usage,omitempty"`
+	// KiroCredits is the Kiro credit cost of the request, an extension field
+	KiroCredits float64 `json:"x_kiro_credits,omitempty"`
+	// KiroModel is the Kiro model that served the request when it differs from
+	// Model, e.g. for "auto", an extension field
+	KiroModel string `json:"x_kiro_model,omitempty"`
 }
 
 // OpenAIChoice represents a choice in the response
@@ -97,16 +157,159 @@ type OpenAIUsage struct {
 
 // OpenAIModelsResponse represents the models list response
 type OpenAIModelsResponse struct {
-	Object string            `json:"object"`
-	Data   []OpenAIModelData `json:"data"`
+	Object  string            `json:"object"`
+	Data    []OpenAIModelData `json:"data"`
+	FirstID string            `json:"first_id,omitempty"`
+	LastID  string            `json:"last_id,omitempty"`
+	HasMore bool              `json:"has_more"`
 }
 
 // OpenAIModelData represents a model in the list
 type OpenAIModelData struct {
-	ID      string `json:"id"`
-	Object  string `json:"object"`
-	Created int64  `json:"created"`
-	OwnedBy string `json:"owned_by"`
+	ID              string `json:"id"`
+	Object          string `json:"object"`
+	Created         int64  `json:"created"`
+	OwnedBy         string `json:"owned_by"`
+	ContextWindow   int    `json:"context_window"`
+	MaxOutputTokens int    `json:"max_output_tokens"`
+	SupportsVision  bool   `json:"supports_vision"`
+	SupportsTools   bool   `json:"supports_tools"`
+
+	// Profile is only set by /v1/models/{id}
+	Profile *OpenAIModelProfile `json:"profile,omitempty"`
+}
+
+// OpenAIModelProfile is the effective request profile of a model: the settings
+// the proxy applies to its requests after model_profiles
+type OpenAIModelProfile struct {
+	// Match is the model_profiles key that applies, empty when none does
+	Match            string `json:"match,omitempty"`
+	DefaultMaxTokens int    `json:"default_max_tokens,omitempty"`
+	MaxOutputTokens  int    `json:"max_output_tokens"`
+	Thinking         bool   `json:"thinking"`
+	Images           bool   `json:"images"`
+	// ThinkingTags are the profile's thinking tags, empty when the global ones apply
+	ThinkingTags     []string `json:"thinking_tags,omitempty"`
+}
+
+// MaxChoices is the largest n accepted for a chat completion; each choice is a
+// separate Kiro request
+const MaxChoices = 8
+
+// Choices returns the number of choices requested with n, 1 when unset
+func (r *OpenAIRequest) Choices() int {
+	if r.N == nil {
+		return 1
+	}
+	return *r.N
+}
+
+// Validate checks the fields the proxy cannot serve as given. Several choices
+// are generated by separate requests, which cannot be streamed as one response.
+func (r *OpenAIRequest) Validate() error {
+	if n := r.Choices(); n < 1 || n > MaxChoices {
+		return fieldErrorf("n", "must be between 1 and %d, got %d", MaxChoices, n)
+	}
+	if r.Stream && r.Choices() > 1 {
+		return fieldErrorf("n", "only 1 choice is supported with stream=true, got %d", r.Choices())
+	}
+	return nil
+}
+
+// UnsupportedParams returns the request parameters Kiro cannot honor, in
+// request field order. Penalties of 0 are the defaults and do not count.
+func (r *OpenAIRequest) UnsupportedParams() []string {
+	var params []string
+	if r.FrequencyPenalty != nil && *r.FrequencyPenalty != 0 {
+		params = append(params, "frequency_penalty")
+	}
+	if r.PresencePenalty != nil && *r.PresencePenalty != 0 {
+		params = append(params, "presence_penalty")
+	}
+	if r.Logprobs {
+		params = append(params, "logprobs")
+	}
+	if r.TopLogprobs != nil {
+		params = append(params, "top_logprobs")
+	}
+	if r.Seed != nil {
+		params = append(params, "seed")
+	}
+	if len(r.LogitBias) > 0 {
+		params = append(params, "logit_bias")
+	}
+	for _, modality := range r.Modalities {
+		if modality == "audio" {
+			params = append(params, "modalities")
+			break
+		}
+	}
+	if len(r.Audio) > 0 && string(r.Audio) != "null" {
+		params = append(params, "audio")
+	}
+	return params
+}
+
+// GetMaxTokens returns the requested completion limit, preferring max_completion_tokens
+func (r *OpenAIRequest) GetMaxTokens() *int {
+	if r.MaxCompletionTokens != nil {
+		return r.MaxCompletionTokens
+	}
+	return r.MaxTokens
+}
+
+// reasoningEffortScale maps reasoning_effort to a multiplier of FAKE_REASONING_MAX_TOKENS.
+// "medium" is OpenAI's default effort, so it keeps the configured budget.
+var reasoningEffortScale = map[string]float64{
+	"minimal": 0.25,
+	"low":     0.5,
+	"medium":  1,
+	"high":    2,
+}
+
+// ApplyReasoningEffort returns the config to use for a request with the given
+// reasoning_effort. An empty effort returns cfg unchanged; "none" disables fake
+// reasoning and any other effort enables it with a scaled thinking budget,
+// overriding FAKE_REASONING for this request only.
+func ApplyReasoningEffort(cfg *config.Config, effort string) (*config.Config, error) {
+	if effort == "" {
+		return cfg, nil
+	}
+
+	reqCfg := *cfg
+	if effort == "none" {
+		reqCfg.FakeReasoningEnabled = false
+		return &reqCfg, nil
+	}
+
+	scale, ok := reasoningEffortScale[effort]
+	if !ok {
+		return nil, fmt.Errorf("reasoning_effort: must be one of 'none', 'minimal', 'low', 'medium', 'high', got '%s'", effort)
+	}
+	reqCfg.FakeReasoningEnabled = true
+	reqCfg.FakeReasoningMaxTokens = int(float64(cfg.FakeReasoningMaxTokens) * scale)
+	return &reqCfg, nil
+}
+
+// ParseStopSequences normalizes the OpenAI "stop" field (string or list) to a slice
+func ParseStopSequences(stop interface{}) []string {
+	switch v := stop.(type) {
+	case string:
+		if v != "" {
+			return []string{v}
+		}
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			if seq, ok := item.(string); ok && seq != "" {
+				result = append(result, seq)
+			}
+		}
+		return result
+	case []string:
+		return v
+	}
+	return nil
 }
 
 // ConvertOpenAIToUnified converts OpenAI messages to unified format
@@ -135,8 +338,9 @@ func ConvertOpenAIToUnified(messages []OpenAIMessage) ([]UnifiedMessage, string)
 			unified = append(unified, unifiedMsg)
 		case "assistant":
 			unifiedMsg := UnifiedMessage{
-				Role:    "assistant",
-				Content: msg.Content,
+				Role:      "assistant",
+				Content:   msg.Content,
+				Reasoning: msg.ReasoningContent,
 			}
 			// Convert tool calls
 			if len(msg.ToolCalls) > 0 {
@@ -156,12 +360,15 @@ func ConvertOpenAIToUnified(messages []OpenAIMessage) ([]UnifiedMessage, string)
 			}
 			unified = append(unified, unifiedMsg)
 		case "tool":
-			// Tool result - add to previous user message or create new one
+			// Tool result - add to previous user message or create new one.
+			// Images in the result go with the user message.
+			images := ExtractImagesFromOpenAIContent(msg.Content)
 			if len(unified) > 0 && unified[len(unified)-1].Role == "user" {
 				unified[len(unified)-1].ToolResults = append(unified[len(unified)-1].ToolResults, ToolResult{
 					ToolUseID: msg.ToolCallID,
 					Content:   msg.Content,
 				})
+				unified[len(unified)-1].Images = append(unified[len(unified)-1].Images, images...)
 			} else {
 				unified = append(unified, UnifiedMessage{
 					Role: "user",
@@ -169,6 +376,7 @@ func ConvertOpenAIToUnified(messages []OpenAIMessage) ([]UnifiedMessage, string)
 						ToolUseID: msg.ToolCallID,
 						Content:   msg.Content,
 					}},
+					Images: images,
 				})
 			}
 		default:
@@ -176,6 +384,7 @@ func ConvertOpenAIToUnified(messages []OpenAIMessage) ([]UnifiedMessage, string)
 			unified = append(unified, UnifiedMessage{
 				Role:    "user",
 				Content: msg.Content,
+				Images:  ExtractImagesFromOpenAIContent(msg.Content),
 			})
 		}
 	}
@@ -240,6 +449,11 @@ func ExtractImagesFromOpenAIContent(content interface{}) []map[string]interface{
 					"data":       parts[1],
 				})
 			}
+		} else if strings.HasPrefix(url, "http://") || strings.HasPrefix(url, "https://") {
+			// Remote image: downloaded later by the image fetcher, if enabled
+			images = append(images, map[string]interface{}{
+				"url": url,
+			})
 		}
 	}
 
@@ -320,6 +534,27 @@ func convertToolCallsToOpenAI(calls []ToolCall) []OpenAIToolCall {
 	return result
 }
 
+// StubLogprobs gives every choice an empty logprobs object, for clients that
+// requested logprobs and expect the field; Kiro does not report token probabilities
+func (r *OpenAIResponse) StubLogprobs() {
+	for i := range r.Choices {
+		r.Choices[i].LogProbs = map[string]interface{}{"content": nil}
+	}
+}
+
+// AddChoice appends another choice to a response created by CreateOpenAIResponse
+func (r *OpenAIResponse) AddChoice(content string, toolCalls []ToolCall, finishReason string) {
+	r.Choices = append(r.Choices, OpenAIChoice{
+		Index: len(r.Choices),
+		Message: &OpenAIMessage{
+			Role:      "assistant",
+			Content:   content,
+			ToolCalls: convertToolCallsToOpenAI(toolCalls),
+		},
+		FinishReason: finishReason,
+	})
+}
+
 // ToJSON converts response to JSON
 func (r *OpenAIResponse) ToJSON() string {
 	b, _ := json.Marshal(r)
@@ -2,8 +2,12 @@
 package converter
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
 
+	"kiro-go-proxy/config"
+
 	"github.com/stretchr/testify/assert"
 )
 
@@ -94,6 +98,24 @@ func TestConvertOpenAIToUnified(t *testing.T) {
 		assert.Equal(t, "call_123", unified[0].ToolResults[0].ToolUseID)
 	})
 
+	t.Run("moves tool result images to the user message", func(t *testing.T) {
+		messages := []OpenAIMessage{
+			{Role: "assistant", ToolCalls: []OpenAIToolCall{{ID: "call_1", Type: "function", Function: OpenAIFunction{Name: "screenshot"}}}},
+			{
+				Role: "tool",
+				Content: []interface{}{
+					map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64,AAAA"}},
+				},
+				ToolCallID: "call_1",
+			},
+		}
+
+		unified, _ := ConvertOpenAIToUnified(messages)
+
+		assert.Len(t, unified, 2)
+		assert.Equal(t, []map[string]interface{}{{"media_type": "image/png", "data": "AAAA"}}, unified[1].Images)
+	})
+
 	t.Run("handles multiple messages", func(t *testing.T) {
 		messages := []OpenAIMessage{
 			{Role: "system", Content: "Be helpful"},
@@ -108,6 +130,26 @@ func TestConvertOpenAIToUnified(t *testing.T) {
 		assert.Equal(t, "Be helpful", systemPrompt)
 		assert.Len(t, unified, 4) // system not in unified
 	})
+
+	t.Run("extracts images of every user turn", func(t *testing.T) {
+		image := func(data string) []interface{} {
+			return []interface{}{
+				map[string]interface{}{"type": "text", "text": "Look"},
+				map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "data:image/png;base64," + data}},
+			}
+		}
+		messages := []OpenAIMessage{
+			{Role: "user", Content: image("AAAA")},
+			{Role: "assistant", Content: "A cat"},
+			{Role: "developer", Content: image("BBBB")},
+			{Role: "user", Content: "And now?"},
+		}
+
+		unified, _ := ConvertOpenAIToUnified(messages)
+
+		assert.Equal(t, []map[string]interface{}{{"media_type": "image/png", "data": "AAAA"}}, unified[0].Images)
+		assert.Equal(t, []map[string]interface{}{{"media_type": "image/png", "data": "BBBB"}}, unified[2].Images)
+	})
 }
 
 // =============================================================================
@@ -231,6 +273,24 @@ func TestExtractImagesFromOpenAIContent(t *testing.T) {
 		assert.Len(t, images, 1)
 		assert.Equal(t, "image/jpeg", images[0]["media_type"])
 	})
+
+	t.Run("keeps remote image URLs for fetching", func(t *testing.T) {
+		content := []interface{}{
+			map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": "https://example.com/cat.png"},
+			},
+			map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": "file:///etc/passwd"},
+			},
+		}
+
+		images := ExtractImagesFromOpenAIContent(content)
+
+		assert.Len(t, images, 1)
+		assert.Equal(t, "https://example.com/cat.png", images[0]["url"])
+	})
 }
 
 // =============================================================================
@@ -285,6 +345,16 @@ func TestCreateOpenAIResponse(t *testing.T) {
 		assert.Equal(t, 50, response.Usage.CompletionTokens)
 		assert.Equal(t, 150, response.Usage.TotalTokens)
 	})
+
+	t.Run("adds choices with their own index", func(t *testing.T) {
+		response := CreateOpenAIResponse("msg_123", "model", "First", nil, "stop", nil)
+		response.AddChoice("Second", nil, "length")
+
+		assert.Len(t, response.Choices, 2)
+		assert.Equal(t, 1, response.Choices[1].Index)
+		assert.Equal(t, "Second", response.Choices[1].Message.Content)
+		assert.Equal(t, "length", response.Choices[1].FinishReason)
+	})
 }
 
 // =============================================================================
@@ -334,3 +404,151 @@ func TestOpenAITypes(t *testing.T) {
 		assert.Equal(t, 0, usage.TotalTokens)
 	})
 }
+
+// =============================================================================
+// TestParseStopSequences
+// =============================================================================
+
+func TestParseStopSequences(t *testing.T) {
+	t.Run("parses single string", func(t *testing.T) {
+		assert.Equal(t, []string{"END"}, ParseStopSequences("END"))
+	})
+
+	t.Run("parses list", func(t *testing.T) {
+		assert.Equal(t, []string{"a", "b"}, ParseStopSequences([]interface{}{"a", "", "b", 1}))
+	})
+
+	t.Run("returns nil for missing value", func(t *testing.T) {
+		assert.Nil(t, ParseStopSequences(nil))
+		assert.Nil(t, ParseStopSequences(""))
+	})
+}
+
+// =============================================================================
+// TestGetMaxTokens
+// =============================================================================
+
+func TestGetMaxTokens(t *testing.T) {
+	a, b := 10, 20
+
+	t.Run("prefers max_completion_tokens", func(t *testing.T) {
+		req := OpenAIRequest{MaxTokens: &a, MaxCompletionTokens: &b}
+		assert.Equal(t, 20, *req.GetMaxTokens())
+	})
+
+	t.Run("falls back to max_tokens", func(t *testing.T) {
+		req := OpenAIRequest{MaxTokens: &a}
+		assert.Equal(t, 10, *req.GetMaxTokens())
+	})
+}
+
+// =============================================================================
+// TestOpenAIRequestValidate
+// =============================================================================
+
+func TestOpenAIRequestValidate(t *testing.T) {
+	n := func(v int) *int { return &v }
+
+	t.Run("accepts up to MaxChoices", func(t *testing.T) {
+		assert.NoError(t, (&OpenAIRequest{}).Validate())
+		assert.NoError(t, (&OpenAIRequest{N: n(MaxChoices)}).Validate())
+		assert.NoError(t, (&OpenAIRequest{N: n(1), Stream: true}).Validate())
+	})
+
+	t.Run("rejects n out of range", func(t *testing.T) {
+		for _, value := range []int{0, -1, MaxChoices + 1} {
+			var fieldErr *FieldError
+			assert.ErrorAs(t, (&OpenAIRequest{N: n(value)}).Validate(), &fieldErr)
+			assert.Equal(t, "n", fieldErr.Field)
+		}
+	})
+
+	t.Run("rejects several streamed choices", func(t *testing.T) {
+		err := (&OpenAIRequest{N: n(2), Stream: true}).Validate()
+		assert.ErrorContains(t, err, "stream=true")
+	})
+}
+
+// =============================================================================
+// TestUnsupportedParams
+// =============================================================================
+
+func TestUnsupportedParams(t *testing.T) {
+	t.Run("none requested", func(t *testing.T) {
+		zero := 0.0
+		req := &OpenAIRequest{FrequencyPenalty: &zero, Modalities: []string{"text"}, Audio: json.RawMessage("null")}
+		assert.Empty(t, req.UnsupportedParams())
+	})
+
+	t.Run("lists every requested parameter", func(t *testing.T) {
+		penalty := 0.5
+		seed := int64(42)
+		top := 3
+		req := &OpenAIRequest{
+			PresencePenalty: &penalty,
+			Logprobs:        true,
+			TopLogprobs:     &top,
+			Seed:            &seed,
+			LogitBias:       map[string]float64{"50256": -100},
+			Modalities:      []string{"text", "audio"},
+			Audio:           json.RawMessage(`{"voice":"alloy","format":"wav"}`),
+		}
+		assert.Equal(t, []string{"presence_penalty", "logprobs", "top_logprobs", "seed", "logit_bias", "modalities", "audio"}, req.UnsupportedParams())
+	})
+}
+
+// =============================================================================
+// TestStubLogprobs
+// =============================================================================
+
+func TestStubLogprobs(t *testing.T) {
+	response := CreateOpenAIResponse("id", "model", "Hi", nil, "stop", nil)
+	response.AddChoice("Hello", nil, "stop")
+	response.StubLogprobs()
+
+	data, err := json.Marshal(response)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, strings.Count(string(data), `"logprobs":{"content":null}`))
+}
+
+// =============================================================================
+// TestApplyReasoningEffort
+// =============================================================================
+
+func TestApplyReasoningEffort(t *testing.T) {
+	base := &config.Config{FakeReasoningEnabled: true, FakeReasoningMaxTokens: 4000}
+
+	t.Run("empty effort keeps global config", func(t *testing.T) {
+		cfg, err := ApplyReasoningEffort(base, "")
+		assert.NoError(t, err)
+		assert.Same(t, base, cfg)
+	})
+
+	t.Run("none disables fake reasoning", func(t *testing.T) {
+		cfg, err := ApplyReasoningEffort(base, "none")
+		assert.NoError(t, err)
+		assert.False(t, cfg.FakeReasoningEnabled)
+		assert.True(t, base.FakeReasoningEnabled, "global config must not change")
+	})
+
+	t.Run("scales max thinking tokens", func(t *testing.T) {
+		for effort, expected := range map[string]int{"minimal": 1000, "low": 2000, "medium": 4000, "high": 8000} {
+			cfg, err := ApplyReasoningEffort(base, effort)
+			assert.NoError(t, err)
+			assert.True(t, cfg.FakeReasoningEnabled)
+			assert.Equal(t, expected, cfg.FakeReasoningMaxTokens, effort)
+		}
+		assert.Equal(t, 4000, base.FakeReasoningMaxTokens)
+	})
+
+	t.Run("enables reasoning when globally disabled", func(t *testing.T) {
+		cfg, err := ApplyReasoningEffort(&config.Config{FakeReasoningMaxTokens: 4000}, "high")
+		assert.NoError(t, err)
+		assert.True(t, cfg.FakeReasoningEnabled)
+	})
+
+	t.Run("rejects unknown effort", func(t *testing.T) {
+		_, err := ApplyReasoningEffort(base, "extreme")
+		assert.Error(t, err)
+	})
+}
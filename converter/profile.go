@@ -0,0 +1,87 @@
+package converter
+
+import (
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// ModelProfileFor returns the model_profiles entry for a resolved model ID and its
+// key. An exact ID wins over the longest matching glob, which wins over "*".
+func ModelProfileFor(cfg *config.Config, modelID string) (string, config.ModelProfile, bool) {
+	return model.MatchPattern(modelID, cfg.ModelProfiles)
+}
+
+// ApplyModelProfile returns the config to use for a request to modelID. A
+// profile's thinking setting overrides FAKE_REASONING and reasoning_effort, so
+// it is applied after ApplyReasoningEffort; its thinking_tags replace
+// FAKE_REASONING_OPEN_TAGS. Without either cfg is returned unchanged.
+func ApplyModelProfile(cfg *config.Config, modelID string) *config.Config {
+	_, profile, ok := ModelProfileFor(cfg, modelID)
+	if !ok {
+		return cfg
+	}
+	forceThinking := profile.Thinking != nil && *profile.Thinking != cfg.FakeReasoningEnabled
+	if !forceThinking && len(profile.ThinkingTags) == 0 {
+		return cfg
+	}
+
+	reqCfg := *cfg
+	if forceThinking {
+		reqCfg.FakeReasoningEnabled = *profile.Thinking
+	}
+	if len(profile.ThinkingTags) > 0 {
+		reqCfg.FakeReasoningOpenTags = profile.ThinkingTags
+	}
+	return &reqCfg
+}
+
+// ProfileMaxTokens returns the output budget of a request to modelID: the
+// client's maxTokens capped at the profile's max_output_tokens, or the profile's
+// default_max_tokens when the client set none. nil means no limit.
+func ProfileMaxTokens(cfg *config.Config, modelID string, maxTokens *int) *int {
+	_, profile, ok := ModelProfileFor(cfg, modelID)
+	if !ok {
+		return maxTokens
+	}
+
+	if maxTokens == nil && profile.DefaultMaxTokens > 0 {
+		value := profile.DefaultMaxTokens
+		maxTokens = &value
+	}
+	if profile.MaxOutputTokens > 0 && (maxTokens == nil || *maxTokens > profile.MaxOutputTokens) {
+		value := profile.MaxOutputTokens
+		maxTokens = &value
+	}
+	return maxTokens
+}
+
+// EffectiveModelProfile describes the settings requests to modelID get, given
+// the model's own output limit and image support
+func EffectiveModelProfile(cfg *config.Config, modelID string, maxOutputTokens int, supportsVision bool) *OpenAIModelProfile {
+	name, profile, _ := ModelProfileFor(cfg, modelID)
+	effective := &OpenAIModelProfile{
+		Match:            name,
+		DefaultMaxTokens: profile.DefaultMaxTokens,
+		MaxOutputTokens:  maxOutputTokens,
+		Thinking:         ApplyModelProfile(cfg, modelID).FakeReasoningEnabled,
+		Images:           supportsVision && !profile.DisableImages,
+		ThinkingTags:     profile.ThinkingTags,
+	}
+	if profile.MaxOutputTokens > 0 && (maxOutputTokens <= 0 || profile.MaxOutputTokens < maxOutputTokens) {
+		effective.MaxOutputTokens = profile.MaxOutputTokens
+	}
+	return effective
+}
+
+// stripImages drops the images of every message, for models whose profile
+// disables them
+func stripImages(messages []UnifiedMessage) {
+	for i := range messages {
+		if len(messages[i].Images) > 0 {
+			log.Debugf("Dropping %d image(s): images are disabled for this model", len(messages[i].Images))
+			messages[i].Images = nil
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Package converter provides tests for per-model request profiles.
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// =============================================================================
+// TestModelProfileFor
+// =============================================================================
+
+func TestModelProfileFor(t *testing.T) {
+	cfg := &config.Config{ModelProfiles: map[string]config.ModelProfile{
+		"claude-opus-4.5": {MaxOutputTokens: 1},
+		"claude-opus-*":   {MaxOutputTokens: 2},
+		"claude-*":        {MaxOutputTokens: 3},
+		"*":               {MaxOutputTokens: 4},
+	}}
+
+	tests := []struct {
+		modelID string
+		match   string
+		limit   int
+	}{
+		{"claude-opus-4.5", "claude-opus-4.5", 1},
+		{"claude-opus-4.1", "claude-opus-*", 2},
+		{"claude-sonnet-4.5", "claude-*", 3},
+		{"auto", "*", 4},
+	}
+	for _, tt := range tests {
+		name, profile, ok := ModelProfileFor(cfg, tt.modelID)
+		assert.True(t, ok, tt.modelID)
+		assert.Equal(t, tt.match, name, tt.modelID)
+		assert.Equal(t, tt.limit, profile.MaxOutputTokens, tt.modelID)
+	}
+
+	_, _, ok := ModelProfileFor(&config.Config{}, "claude-opus-4.5")
+	assert.False(t, ok)
+}
+
+// =============================================================================
+// TestApplyModelProfile
+// =============================================================================
+
+func TestApplyModelProfile(t *testing.T) {
+	on, off := true, false
+	cfg := &config.Config{FakeReasoningEnabled: true, ModelProfiles: map[string]config.ModelProfile{
+		"claude-opus-*":    {Thinking: &on},
+		"claude-haiku-4.5": {Thinking: &off},
+		"claude-sonnet-*":  {MaxOutputTokens: 100},
+		"gemini-*":         {ThinkingTags: []string{"<thought>"}},
+	}}
+
+	t.Run("forces thinking off", func(t *testing.T) {
+		reqCfg := ApplyModelProfile(cfg, "claude-haiku-4.5")
+		assert.False(t, reqCfg.FakeReasoningEnabled)
+		assert.True(t, cfg.FakeReasoningEnabled)
+	})
+
+	t.Run("forces thinking on after reasoning_effort", func(t *testing.T) {
+		reqCfg, err := ApplyReasoningEffort(cfg, "none")
+		assert.NoError(t, err)
+		assert.True(t, ApplyModelProfile(reqCfg, "claude-opus-4.5").FakeReasoningEnabled)
+	})
+
+	t.Run("replaces the thinking tags", func(t *testing.T) {
+		reqCfg := ApplyModelProfile(cfg, "gemini-2.5-pro")
+		assert.Equal(t, []string{"<thought>"}, reqCfg.FakeReasoningOpenTags)
+		assert.True(t, reqCfg.FakeReasoningEnabled)
+		assert.Empty(t, cfg.FakeReasoningOpenTags)
+	})
+
+	t.Run("keeps the config without a thinking setting", func(t *testing.T) {
+		assert.Same(t, cfg, ApplyModelProfile(cfg, "claude-sonnet-4.5"))
+		assert.Same(t, cfg, ApplyModelProfile(cfg, "auto"))
+	})
+}
+
+// =============================================================================
+// TestProfileMaxTokens
+// =============================================================================
+
+func TestProfileMaxTokens(t *testing.T) {
+	n := func(v int) *int { return &v }
+	cfg := &config.Config{ModelProfiles: map[string]config.ModelProfile{
+		"claude-opus-*":   {DefaultMaxTokens: 4096, MaxOutputTokens: 8192},
+		"claude-sonnet-*": {MaxOutputTokens: 2048},
+	}}
+
+	assert.Equal(t, n(4096), ProfileMaxTokens(cfg, "claude-opus-4.5", nil))
+	assert.Equal(t, n(1000), ProfileMaxTokens(cfg, "claude-opus-4.5", n(1000)))
+	assert.Equal(t, n(8192), ProfileMaxTokens(cfg, "claude-opus-4.5", n(32000)))
+	assert.Equal(t, n(2048), ProfileMaxTokens(cfg, "claude-sonnet-4.5", nil))
+	assert.Nil(t, ProfileMaxTokens(cfg, "auto", nil))
+	assert.Equal(t, n(32000), ProfileMaxTokens(cfg, "auto", n(32000)))
+}
+
+// =============================================================================
+// TestBuildKiroPayloadProfile
+// =============================================================================
+
+func TestBuildKiroPayloadProfile(t *testing.T) {
+	image := map[string]interface{}{"media_type": "image/png", "data": "abc"}
+	messages := func() []UnifiedMessage {
+		return []UnifiedMessage{
+			{Role: "user", Content: "What is this?", Images: []map[string]interface{}{image}},
+			{Role: "assistant", Content: "A cat"},
+			{Role: "user", Content: "And this?", Images: []map[string]interface{}{image}},
+		}
+	}
+	cfg := &config.Config{ModelProfiles: map[string]config.ModelProfile{
+		"claude-haiku-*": {DisableImages: true},
+	}}
+
+	payload, err := BuildKiroPayload(messages(), "", "claude-haiku-4.5", nil, "conv", "", 0, cfg)
+	assert.NoError(t, err)
+	assert.Empty(t, payload.ConversationState.CurrentMessage.UserInputMessage.Images)
+	first := payload.ConversationState.History[0].(map[string]interface{})["userInputMessage"]
+	assert.NotContains(t, first, "images")
+
+	payload, err = BuildKiroPayload(messages(), "", "claude-sonnet-4.5", nil, "conv", "", 0, cfg)
+	assert.NoError(t, err)
+	assert.Len(t, payload.ConversationState.CurrentMessage.UserInputMessage.Images, 1)
+}
+
+// =============================================================================
+// TestEffectiveModelProfile
+// =============================================================================
+
+func TestEffectiveModelProfile(t *testing.T) {
+	on := true
+	cfg := &config.Config{ModelProfiles: map[string]config.ModelProfile{
+		"claude-opus-*": {DefaultMaxTokens: 4096, MaxOutputTokens: 8192, Thinking: &on, DisableImages: true},
+	}}
+
+	assert.Equal(t, &OpenAIModelProfile{
+		Match:            "claude-opus-*",
+		DefaultMaxTokens: 4096,
+		MaxOutputTokens:  8192,
+		Thinking:         true,
+		Images:           false,
+	}, EffectiveModelProfile(cfg, "claude-opus-4.5", 64000, true))
+
+	assert.Equal(t, &OpenAIModelProfile{MaxOutputTokens: 64000, Images: true}, EffectiveModelProfile(cfg, "claude-sonnet-4.5", 64000, true))
+}
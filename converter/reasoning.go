@@ -0,0 +1,74 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/parser"
+	"kiro-go-proxy/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// prepareHistoryReasoning handles the reasoning of earlier assistant turns that
+// agent clients send back. With STRIP_HISTORY_REASONING it is dropped: thinking
+// blocks within the text (per FAKE_REASONING_OPEN_TAGS) are removed, and
+// reasoning_content and Anthropic thinking blocks are not sent. Otherwise the
+// reasoning is kept as a <thinking> block ahead of the turn's text.
+// The messages are copied, so the caller's are left as they were.
+func prepareHistoryReasoning(messages []UnifiedMessage, cfg *config.Config) []UnifiedMessage {
+	result := make([]UnifiedMessage, len(messages))
+	copy(result, messages)
+
+	var strip []*regexp.Regexp
+	if cfg.StripHistoryReasoning {
+		strip = thinkingBlockPatterns(cfg.FakeReasoningOpenTags)
+	}
+	stripped := 0
+	for i := range result {
+		msg := &result[i]
+		if msg.Role != "assistant" {
+			continue
+		}
+		if !cfg.StripHistoryReasoning {
+			if msg.Reasoning != "" {
+				msg.Content = "<thinking>" + msg.Reasoning + "</thinking>\n\n" + utils.ExtractTextContent(msg.Content)
+			}
+			continue
+		}
+
+		text := utils.ExtractTextContent(msg.Content)
+		cleaned := text
+		for _, re := range strip {
+			cleaned = re.ReplaceAllString(cleaned, "")
+		}
+		if cleaned != text {
+			msg.Content = strings.TrimSpace(cleaned)
+			stripped++
+		} else if msg.Reasoning != "" {
+			stripped++
+		}
+	}
+	if stripped > 0 {
+		log.Debugf("Stripped reasoning from %d assistant message(s) in history", stripped)
+	}
+	return result
+}
+
+// thinkingBlockPatterns match the thinking blocks of the given tag specs, with
+// the whitespace that follows them
+func thinkingBlockPatterns(specs []string) []*regexp.Regexp {
+	if len(specs) == 0 {
+		specs = parser.DefaultThinkingTags
+	}
+	patterns := make([]*regexp.Regexp, 0, len(specs))
+	for _, spec := range specs {
+		open, closeTag, err := parser.ParseThinkingTag(spec)
+		if err != nil {
+			continue
+		}
+		patterns = append(patterns, regexp.MustCompile(`(?s)`+regexp.QuoteMeta(open)+`.*?`+regexp.QuoteMeta(closeTag)+`\s*`))
+	}
+	return patterns
+}
@@ -0,0 +1,95 @@
+// Package converter provides tests for reasoning in history messages.
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"kiro-go-proxy/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestPrepareHistoryReasoning
+// =============================================================================
+
+func TestPrepareHistoryReasoning(t *testing.T) {
+	history := func() []UnifiedMessage {
+		return []UnifiedMessage{
+			{Role: "user", Content: "What is 2+2?"},
+			{Role: "assistant", Content: "<thinking>Two plus two.</thinking>\n\n4", Reasoning: "Adding numbers."},
+			{Role: "user", Content: "And 3+3?"},
+		}
+	}
+
+	t.Run("strips thinking blocks and reasoning", func(t *testing.T) {
+		messages := history()
+
+		result := prepareHistoryReasoning(messages, &config.Config{StripHistoryReasoning: true})
+
+		assert.Equal(t, "4", result[1].Content)
+		assert.Equal(t, "What is 2+2?", result[0].Content)
+		// The caller's messages are left as they were
+		assert.Equal(t, "<thinking>Two plus two.</thinking>\n\n4", messages[1].Content)
+	})
+
+	t.Run("strips the configured tags", func(t *testing.T) {
+		messages := []UnifiedMessage{{Role: "assistant", Content: "[[plan]]Look it up.[[/plan]] Paris."}}
+
+		result := prepareHistoryReasoning(messages, &config.Config{StripHistoryReasoning: true, FakeReasoningOpenTags: []string{"[[plan]]|[[/plan]]"}})
+
+		assert.Equal(t, "Paris.", result[0].Content)
+	})
+
+	t.Run("keeps reasoning as a thinking block when disabled", func(t *testing.T) {
+		messages := []UnifiedMessage{{Role: "assistant", Content: "4", Reasoning: "Adding numbers."}}
+
+		result := prepareHistoryReasoning(messages, &config.Config{})
+
+		assert.Equal(t, "<thinking>Adding numbers.</thinking>\n\n4", result[0].Content)
+	})
+}
+
+// =============================================================================
+// TestHistoryReasoningConversion
+// =============================================================================
+
+func TestHistoryReasoningConversion(t *testing.T) {
+	t.Run("OpenAI reasoning_content", func(t *testing.T) {
+		messages, _ := ConvertOpenAIToUnified([]OpenAIMessage{
+			{Role: "user", Content: "Hi"},
+			{Role: "assistant", Content: "Hello!", ReasoningContent: "Greet back."},
+		})
+
+		assert.Equal(t, "Greet back.", messages[1].Reasoning)
+	})
+
+	t.Run("Anthropic thinking blocks", func(t *testing.T) {
+		messages, _ := ConvertAnthropicToUnified(&AnthropicRequest{Messages: []AnthropicMessage{
+			{Role: "user", Content: AnthropicContent{{Type: "text", Text: "Hi"}}},
+			{Role: "assistant", Content: AnthropicContent{{Type: "thinking", Thinking: "Greet back."}, {Type: "text", Text: "Hello!"}}},
+		}})
+
+		assert.Equal(t, "Greet back.", messages[1].Reasoning)
+		assert.Equal(t, "Hello!", messages[1].Content)
+	})
+
+	t.Run("history sent to Kiro", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "Hi"},
+			{Role: "assistant", Content: "<thinking>Greet back.</thinking>Hello!"},
+			{Role: "user", Content: "Bye"},
+		}
+
+		historyJSON := func(cfg *config.Config) string {
+			payload, err := BuildKiroPayload(messages, "", "claude-sonnet-4.5", nil, "conv", "", 0, cfg)
+			assert.NoError(t, err)
+			data, _ := json.Marshal(payload.ConversationState.History)
+			return string(data)
+		}
+
+		assert.NotContains(t, historyJSON(&config.Config{StripHistoryReasoning: true}), "Greet back.")
+		assert.Contains(t, historyJSON(&config.Config{}), "Greet back.")
+	})
+}
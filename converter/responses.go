@@ -0,0 +1,500 @@
+package converter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ResponsesRequest represents an OpenAI Responses API request (POST /v1/responses)
+type ResponsesRequest struct {
+	Model              string              `json:"model"`
+	Input              ResponsesInput      `json:"input"`
+	Instructions       string              `json:"instructions,omitempty"`
+	Tools              []ResponsesTool     `json:"tools,omitempty"`
+	ToolChoice         interface{}         `json:"tool_choice,omitempty"`
+	ParallelToolCalls  *bool               `json:"parallel_tool_calls,omitempty"`
+	MaxOutputTokens    *int                `json:"max_output_tokens,omitempty"`
+	Temperature        *float64            `json:"temperature,omitempty"`
+	TopP               *float64            `json:"top_p,omitempty"`
+	Reasoning          *ResponsesReasoning `json:"reasoning,omitempty"`
+	Text               *ResponsesText      `json:"text,omitempty"`
+	Stream             bool                `json:"stream,omitempty"`
+	PreviousResponseID string              `json:"previous_response_id,omitempty"`
+	Store              *bool               `json:"store,omitempty"`
+	Metadata           map[string]string   `json:"metadata,omitempty"`
+}
+
+// ResponsesInput is the request input. The API accepts either a plain string or
+// a list of input items; a string is unmarshalled as a single user message.
+type ResponsesInput []ResponsesInputItem
+
+// UnmarshalJSON accepts a string or an array of input items
+func (in *ResponsesInput) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*in = ResponsesInput{{Type: "message", Role: "user", Content: ResponsesContent{{Type: "input_text", Text: text}}}}
+		return nil
+	}
+
+	var items []ResponsesInputItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("input must be a string or an array of input items: %w", err)
+	}
+	*in = items
+	return nil
+}
+
+// ResponsesInputItem is a single input item. Which fields are set depends on Type:
+// "message" (the default), "function_call", "function_call_output" or "reasoning".
+type ResponsesInputItem struct {
+	Type string `json:"type,omitempty"`
+	ID   string `json:"id,omitempty"`
+
+	// message
+	Role    string           `json:"role,omitempty"`
+	Content ResponsesContent `json:"content,omitempty"`
+
+	// function_call and function_call_output
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+	// Output is the function result, a string or an array of content parts
+	Output interface{} `json:"output,omitempty"`
+}
+
+// ResponsesContent is message content: a plain string or a list of content
+// parts. A string is unmarshalled as a single input_text part.
+type ResponsesContent []ResponsesContentPart
+
+// UnmarshalJSON accepts a string or an array of content parts
+func (c *ResponsesContent) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+		*c = ResponsesContent{{Type: "input_text", Text: text}}
+		return nil
+	}
+
+	var parts []ResponsesContentPart
+	if err := json.Unmarshal(data, &parts); err != nil {
+		return fmt.Errorf("content must be a string or an array of content parts: %w", err)
+	}
+	*c = parts
+	return nil
+}
+
+// Text joins the text parts of the content
+func (c ResponsesContent) Text() string {
+	var parts []string
+	for _, part := range c {
+		switch part.Type {
+		case "input_text", "output_text":
+			parts = append(parts, part.Text)
+		}
+	}
+	return strings.Join(parts, "")
+}
+
+// ResponsesContentPart is one part of message content: "input_text",
+// "output_text", "input_image" or "refusal"
+type ResponsesContentPart struct {
+	Type     string `json:"type"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Refusal  string `json:"refusal,omitempty"`
+}
+
+// ResponsesTool is a tool definition. Only function tools are supported.
+type ResponsesTool struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+	Strict      *bool                  `json:"strict,omitempty"`
+}
+
+// ResponsesReasoning holds the reasoning options
+type ResponsesReasoning struct {
+	Effort  string `json:"effort,omitempty"`
+	Summary string `json:"summary,omitempty"`
+}
+
+// ResponsesText holds the text output options
+type ResponsesText struct {
+	Format *ResponsesTextFormat `json:"format,omitempty"`
+}
+
+// ResponsesTextFormat is the output format: "text", "json_object" or "json_schema"
+type ResponsesTextFormat struct {
+	Type        string                 `json:"type"`
+	Name        string                 `json:"name,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Schema      map[string]interface{} `json:"schema,omitempty"`
+	Strict      *bool                  `json:"strict,omitempty"`
+}
+
+// ResponsesResponse is a Responses API response object
+type ResponsesResponse struct {
+	ID                string                      `json:"id"`
+	Object            string                      `json:"object"`
+	CreatedAt         int64                       `json:"created_at"`
+	Status            string                      `json:"status"`
+	Error             *ResponsesError             `json:"error"`
+	IncompleteDetails *ResponsesIncompleteDetails `json:"incomplete_details"`
+	Instructions      *string                     `json:"instructions"`
+	MaxOutputTokens   *int                        `json:"max_output_tokens"`
+	Model             string                      `json:"model"`
+	// Output holds *ResponsesMessageItem, *ResponsesFunctionCallItem and *ResponsesReasoningItem values
+	Output            []interface{}       `json:"output"`
+	ParallelToolCalls bool                `json:"parallel_tool_calls"`
+	Temperature       *float64            `json:"temperature"`
+	ToolChoice        interface{}         `json:"tool_choice"`
+	Tools             []ResponsesTool     `json:"tools"`
+	TopP              *float64            `json:"top_p"`
+	Text              *ResponsesText      `json:"text,omitempty"`
+	Reasoning         *ResponsesReasoning `json:"reasoning,omitempty"`
+	Metadata          map[string]string   `json:"metadata"`
+	Usage             *ResponsesUsage     `json:"usage"`
+	// KiroCredits is the Kiro credit cost of the request, an extension field
+	KiroCredits float64 `json:"x_kiro_credits,omitempty"`
+	// KiroModel is the Kiro model that served the request when it differs from
+	// Model, e.g. for "auto", an extension field
+	KiroModel string `json:"x_kiro_model,omitempty"`
+}
+
+// ResponsesError describes why a response failed
+type ResponsesError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// ResponsesIncompleteDetails explains why a response is incomplete
+type ResponsesIncompleteDetails struct {
+	Reason string `json:"reason"`
+}
+
+// ResponsesUsage holds the token counts of a response
+type ResponsesUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// ResponsesMessageItem is an assistant message output item
+type ResponsesMessageItem struct {
+	Type    string                `json:"type"`
+	ID      string                `json:"id"`
+	Status  string                `json:"status"`
+	Role    string                `json:"role"`
+	Content []ResponsesOutputText `json:"content"`
+}
+
+// ResponsesOutputText is the text part of an output message
+type ResponsesOutputText struct {
+	Type        string        `json:"type"`
+	Text        string        `json:"text"`
+	Annotations []interface{} `json:"annotations"`
+}
+
+// ResponsesFunctionCallItem is a function call output item
+type ResponsesFunctionCallItem struct {
+	Type      string `json:"type"`
+	ID        string `json:"id"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+	Status    string `json:"status"`
+}
+
+// ResponsesReasoningItem is a reasoning output item carrying the thinking as a summary
+type ResponsesReasoningItem struct {
+	Type    string                 `json:"type"`
+	ID      string                 `json:"id"`
+	Summary []ResponsesSummaryText `json:"summary"`
+}
+
+// ResponsesSummaryText is one part of a reasoning summary
+type ResponsesSummaryText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// NewResponsesMessageItem creates an assistant message item with one output_text part
+func NewResponsesMessageItem(id, status, text string) *ResponsesMessageItem {
+	return &ResponsesMessageItem{
+		Type:    "message",
+		ID:      id,
+		Status:  status,
+		Role:    "assistant",
+		Content: []ResponsesOutputText{NewResponsesOutputText(text)},
+	}
+}
+
+// NewResponsesOutputText creates an output_text part
+func NewResponsesOutputText(text string) ResponsesOutputText {
+	return ResponsesOutputText{Type: "output_text", Text: text, Annotations: []interface{}{}}
+}
+
+// NewResponsesReasoningItem creates a reasoning item with the text as its summary
+func NewResponsesReasoningItem(id, text string) *ResponsesReasoningItem {
+	return &ResponsesReasoningItem{
+		Type:    "reasoning",
+		ID:      id,
+		Summary: []ResponsesSummaryText{{Type: "summary_text", Text: text}},
+	}
+}
+
+// NewResponsesResponse creates a response object for req that echoes its settings.
+// Status is "in_progress", "completed", "incomplete" or "failed".
+func NewResponsesResponse(id string, createdAt int64, status string, req *ResponsesRequest, output []interface{}, usage *ResponsesUsage) *ResponsesResponse {
+	resp := &ResponsesResponse{
+		ID:                id,
+		Object:            "response",
+		CreatedAt:         createdAt,
+		Status:            status,
+		MaxOutputTokens:   req.MaxOutputTokens,
+		Model:             req.Model,
+		Output:            output,
+		ParallelToolCalls: req.ParallelToolCalls == nil || *req.ParallelToolCalls,
+		Temperature:       req.Temperature,
+		ToolChoice:        req.ToolChoice,
+		Tools:             req.Tools,
+		TopP:              req.TopP,
+		Text:              req.Text,
+		Reasoning:         req.Reasoning,
+		Metadata:          req.Metadata,
+		Usage:             usage,
+	}
+	if resp.Output == nil {
+		resp.Output = []interface{}{}
+	}
+	if resp.ToolChoice == nil {
+		resp.ToolChoice = "auto"
+	}
+	if resp.Tools == nil {
+		resp.Tools = []ResponsesTool{}
+	}
+	if req.Instructions != "" {
+		resp.Instructions = &req.Instructions
+	}
+	if status == "incomplete" {
+		resp.IncompleteDetails = &ResponsesIncompleteDetails{Reason: "max_output_tokens"}
+	}
+	return resp
+}
+
+// Validate checks the request for missing, malformed or unsupported fields
+func (r *ResponsesRequest) Validate() error {
+	if r.Model == "" {
+		return fmt.Errorf("model is required")
+	}
+	if len(r.Input) == 0 {
+		return fmt.Errorf("input is required")
+	}
+	if r.PreviousResponseID != "" {
+		return fmt.Errorf("previous_response_id: responses are not stored, send the whole conversation in input")
+	}
+
+	for i, item := range r.Input {
+		switch item.Type {
+		case "", "message":
+			switch item.Role {
+			case "user", "assistant", "system", "developer":
+			default:
+				return fmt.Errorf("input[%d].role: must be 'user', 'assistant', 'system' or 'developer', got '%s'", i, item.Role)
+			}
+			for j, part := range item.Content {
+				switch part.Type {
+				case "input_text", "output_text", "refusal":
+				case "input_image":
+					if part.ImageURL == "" {
+						return fmt.Errorf("input[%d].content[%d]: only input_image with image_url is supported", i, j)
+					}
+				default:
+					return fmt.Errorf("input[%d].content[%d].type: unsupported content type '%s'", i, j, part.Type)
+				}
+			}
+		case "function_call":
+			if item.CallID == "" || item.Name == "" {
+				return fmt.Errorf("input[%d]: function_call requires call_id and name", i)
+			}
+		case "function_call_output":
+			if item.CallID == "" {
+				return fmt.Errorf("input[%d]: function_call_output requires call_id", i)
+			}
+		case "reasoning":
+		default:
+			return fmt.Errorf("input[%d].type: unsupported input item type '%s'", i, item.Type)
+		}
+	}
+
+	for i, tool := range r.Tools {
+		if tool.Type != "function" {
+			return fmt.Errorf("tools[%d].type: only function tools are supported, got '%s'", i, tool.Type)
+		}
+		if tool.Name == "" {
+			return fmt.Errorf("tools[%d].name is required", i)
+		}
+	}
+
+	if r.Text != nil && r.Text.Format != nil {
+		switch r.Text.Format.Type {
+		case ResponseFormatText, ResponseFormatJSONObject, ResponseFormatJSONSchema:
+		default:
+			return fmt.Errorf("text.format.type: must be 'text', 'json_object' or 'json_schema', got '%s'", r.Text.Format.Type)
+		}
+	}
+	return nil
+}
+
+// ConvertResponsesToOpenAI maps a Responses request onto a chat completions
+// request, so it runs through the same conversion pipeline. Instructions and
+// system/developer messages become the system prompt; function calls following
+// an assistant message join it, as chat completions expect. Reasoning items
+// are dropped: Kiro cannot take them back.
+func ConvertResponsesToOpenAI(req *ResponsesRequest) *OpenAIRequest {
+	var systemParts []string
+	if req.Instructions != "" {
+		systemParts = append(systemParts, req.Instructions)
+	}
+
+	var messages []OpenAIMessage
+	for _, item := range req.Input {
+		switch item.Type {
+		case "function_call":
+			call := OpenAIToolCall{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: OpenAIFunction{Name: item.Name, Arguments: item.Arguments},
+			}
+			if n := len(messages); n > 0 && messages[n-1].Role == "assistant" {
+				messages[n-1].ToolCalls = append(messages[n-1].ToolCalls, call)
+			} else {
+				messages = append(messages, OpenAIMessage{Role: "assistant", Content: "", ToolCalls: []OpenAIToolCall{call}})
+			}
+
+		case "function_call_output":
+			messages = append(messages, OpenAIMessage{
+				Role:       "tool",
+				ToolCallID: item.CallID,
+				Content:    responsesOutputText(item.Output),
+			})
+
+		case "reasoning":
+
+		default:
+			switch item.Role {
+			case "system", "developer":
+				systemParts = append(systemParts, item.Content.Text())
+			case "assistant":
+				messages = append(messages, OpenAIMessage{Role: "assistant", Content: item.Content.Text()})
+			default:
+				messages = append(messages, OpenAIMessage{Role: "user", Content: responsesUserContent(item.Content)})
+			}
+		}
+	}
+
+	if len(systemParts) > 0 {
+		messages = append([]OpenAIMessage{{Role: "system", Content: strings.Join(systemParts, "\n\n")}}, messages...)
+	}
+
+	chatReq := &OpenAIRequest{
+		Model:               req.Model,
+		Messages:            messages,
+		Stream:              req.Stream,
+		Temperature:         req.Temperature,
+		MaxCompletionTokens: req.MaxOutputTokens,
+		TopP:                req.TopP,
+		ParallelToolCalls:   req.ParallelToolCalls,
+	}
+	for _, tool := range req.Tools {
+		chatReq.Tools = append(chatReq.Tools, OpenAITool{
+			Type: "function",
+			Function: OpenAIFunctionDef{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			},
+		})
+	}
+	if req.Reasoning != nil {
+		chatReq.ReasoningEffort = req.Reasoning.Effort
+	}
+	if req.Text != nil && req.Text.Format != nil && req.Text.Format.Type != ResponseFormatText {
+		format := req.Text.Format
+		chatReq.ResponseFormat = &OpenAIResponseFormat{Type: format.Type}
+		if format.Type == ResponseFormatJSONSchema {
+			chatReq.ResponseFormat.JSONSchema = &OpenAIJSONSchema{
+				Name:        format.Name,
+				Description: format.Description,
+				Schema:      format.Schema,
+				Strict:      format.Strict,
+			}
+		}
+	}
+	return chatReq
+}
+
+// responsesUserContent converts user content to chat completions content: a
+// string, or content parts when the message has images
+func responsesUserContent(content ResponsesContent) interface{} {
+	hasImage := false
+	for _, part := range content {
+		if part.Type == "input_image" {
+			hasImage = true
+			break
+		}
+	}
+	if !hasImage {
+		return content.Text()
+	}
+
+	parts := make([]interface{}, 0, len(content))
+	for _, part := range content {
+		switch part.Type {
+		case "input_text", "output_text":
+			parts = append(parts, map[string]interface{}{"type": "text", "text": part.Text})
+		case "input_image":
+			parts = append(parts, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": part.ImageURL},
+			})
+		}
+	}
+	return parts
+}
+
+// responsesOutputText returns the text of a function_call_output: a string or
+// an array of content parts
+func responsesOutputText(output interface{}) string {
+	switch v := output.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []interface{}:
+		var parts []string
+		for _, item := range v {
+			if m, ok := item.(map[string]interface{}); ok {
+				if text, ok := m["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+		return strings.Join(parts, "")
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
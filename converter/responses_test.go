@@ -0,0 +1,220 @@
+// Package converter provides tests for Responses API conversion.
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parseResponsesRequest(t *testing.T, body string) *ResponsesRequest {
+	var req ResponsesRequest
+	assert.NoError(t, json.Unmarshal([]byte(body), &req))
+	return &req
+}
+
+// =============================================================================
+// TestResponsesInput
+// =============================================================================
+
+func TestResponsesInput(t *testing.T) {
+	t.Run("string input is one user message", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{"model": "m", "input": "Hi"}`)
+
+		assert.Len(t, req.Input, 1)
+		assert.Equal(t, "user", req.Input[0].Role)
+		assert.Equal(t, "Hi", req.Input[0].Content.Text())
+	})
+
+	t.Run("string content is one input_text part", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{"model": "m", "input": [{"role": "user", "content": "Hi"}]}`)
+
+		assert.Equal(t, ResponsesContent{{Type: "input_text", Text: "Hi"}}, req.Input[0].Content)
+	})
+
+	t.Run("rejects other input types", func(t *testing.T) {
+		var req ResponsesRequest
+		assert.Error(t, json.Unmarshal([]byte(`{"model": "m", "input": 42}`), &req))
+	})
+}
+
+// =============================================================================
+// TestResponsesRequestValidate
+// =============================================================================
+
+func TestResponsesRequestValidate(t *testing.T) {
+	t.Run("accepts a minimal request", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{"model": "m", "input": "Hi"}`)
+		assert.NoError(t, req.Validate())
+	})
+
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{"missing model", `{"input": "Hi"}`, "model"},
+		{"missing input", `{"model": "m"}`, "input"},
+		{"previous_response_id", `{"model": "m", "input": "Hi", "previous_response_id": "resp_1"}`, "previous_response_id"},
+		{"unknown role", `{"model": "m", "input": [{"role": "bot", "content": "Hi"}]}`, "input[0].role"},
+		{"unknown item type", `{"model": "m", "input": [{"type": "web_search_call"}]}`, "input[0].type"},
+		{"file content", `{"model": "m", "input": [{"role": "user", "content": [{"type": "input_file", "file_id": "f"}]}]}`, "input[0].content[0]"},
+		{"image by file ID", `{"model": "m", "input": [{"role": "user", "content": [{"type": "input_image", "file_id": "f"}]}]}`, "image_url"},
+		{"function call without call_id", `{"model": "m", "input": [{"type": "function_call", "name": "f"}]}`, "call_id"},
+		{"built-in tools", `{"model": "m", "input": "Hi", "tools": [{"type": "web_search"}]}`, "tools[0].type"},
+		{"bad text format", `{"model": "m", "input": "Hi", "text": {"format": {"type": "xml"}}}`, "text.format.type"},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			err := parseResponsesRequest(t, tt.body).Validate()
+			assert.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+// =============================================================================
+// TestConvertResponsesToOpenAI
+// =============================================================================
+
+func TestConvertResponsesToOpenAI(t *testing.T) {
+	t.Run("joins instructions and system messages", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{
+			"model": "m",
+			"instructions": "Be brief.",
+			"input": [
+				{"role": "developer", "content": "Answer in French."},
+				{"role": "user", "content": "Hi"}
+			]
+		}`)
+
+		chatReq := ConvertResponsesToOpenAI(req)
+
+		assert.Len(t, chatReq.Messages, 2)
+		assert.Equal(t, OpenAIMessage{Role: "system", Content: "Be brief.\n\nAnswer in French."}, chatReq.Messages[0])
+		assert.Equal(t, OpenAIMessage{Role: "user", Content: "Hi"}, chatReq.Messages[1])
+	})
+
+	t.Run("attaches function calls to the assistant turn", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{
+			"model": "m",
+			"input": [
+				{"role": "user", "content": "Weather in Paris?"},
+				{"type": "reasoning", "id": "rs_1", "summary": []},
+				{"type": "message", "role": "assistant", "content": [{"type": "output_text", "text": "Checking."}]},
+				{"type": "function_call", "call_id": "call_1", "name": "get_weather", "arguments": "{\"city\":\"Paris\"}"},
+				{"type": "function_call_output", "call_id": "call_1", "output": "Sunny"}
+			]
+		}`)
+
+		chatReq := ConvertResponsesToOpenAI(req)
+
+		assert.Len(t, chatReq.Messages, 3)
+		assistant := chatReq.Messages[1]
+		assert.Equal(t, "Checking.", assistant.Content)
+		assert.Equal(t, []OpenAIToolCall{{
+			ID:       "call_1",
+			Type:     "function",
+			Function: OpenAIFunction{Name: "get_weather", Arguments: `{"city":"Paris"}`},
+		}}, assistant.ToolCalls)
+		assert.Equal(t, OpenAIMessage{Role: "tool", ToolCallID: "call_1", Content: "Sunny"}, chatReq.Messages[2])
+	})
+
+	t.Run("function calls without an assistant message get one", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{
+			"model": "m",
+			"input": [
+				{"role": "user", "content": "Hi"},
+				{"type": "function_call", "call_id": "call_1", "name": "f", "arguments": "{}"},
+				{"type": "function_call_output", "call_id": "call_1", "output": [{"type": "input_text", "text": "done"}]}
+			]
+		}`)
+
+		chatReq := ConvertResponsesToOpenAI(req)
+
+		assert.Equal(t, "assistant", chatReq.Messages[1].Role)
+		assert.Len(t, chatReq.Messages[1].ToolCalls, 1)
+		assert.Equal(t, "done", chatReq.Messages[2].Content)
+	})
+
+	t.Run("converts images to image_url parts", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{
+			"model": "m",
+			"input": [{"role": "user", "content": [
+				{"type": "input_text", "text": "What is this?"},
+				{"type": "input_image", "image_url": "data:image/png;base64,AAAA"}
+			]}]
+		}`)
+
+		chatReq := ConvertResponsesToOpenAI(req)
+
+		images := ExtractImagesFromOpenAIContent(chatReq.Messages[0].Content)
+		assert.Len(t, images, 1)
+		unified, _ := ConvertOpenAIToUnified(chatReq.Messages)
+		assert.Len(t, unified[0].Images, 1)
+	})
+
+	t.Run("maps tools and options", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{
+			"model": "m",
+			"input": "Hi",
+			"stream": true,
+			"max_output_tokens": 100,
+			"parallel_tool_calls": false,
+			"reasoning": {"effort": "high"},
+			"text": {"format": {"type": "json_schema", "name": "answer", "schema": {"type": "object"}}},
+			"tools": [{"type": "function", "name": "f", "description": "does f", "parameters": {"type": "object"}}]
+		}`)
+
+		chatReq := ConvertResponsesToOpenAI(req)
+
+		assert.True(t, chatReq.Stream)
+		assert.Equal(t, 100, *chatReq.GetMaxTokens())
+		assert.False(t, *chatReq.ParallelToolCalls)
+		assert.Equal(t, "high", chatReq.ReasoningEffort)
+		assert.Equal(t, ResponseFormatJSONSchema, chatReq.ResponseFormat.Type)
+		assert.Equal(t, "answer", chatReq.ResponseFormat.JSONSchema.Name)
+		assert.Equal(t, []OpenAITool{{
+			Type:     "function",
+			Function: OpenAIFunctionDef{Name: "f", Description: "does f", Parameters: map[string]interface{}{"type": "object"}},
+		}}, chatReq.Tools)
+	})
+
+	t.Run("plain text format needs no response format", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{"model": "m", "input": "Hi", "text": {"format": {"type": "text"}}}`)
+		assert.Nil(t, ConvertResponsesToOpenAI(req).ResponseFormat)
+	})
+}
+
+// =============================================================================
+// TestNewResponsesResponse
+// =============================================================================
+
+func TestNewResponsesResponse(t *testing.T) {
+	t.Run("echoes request settings with defaults", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{"model": "m", "input": "Hi", "instructions": "Be brief."}`)
+
+		resp := NewResponsesResponse("resp_1", 1700000000, "completed", req, nil, nil)
+
+		data, err := json.Marshal(resp)
+		assert.NoError(t, err)
+		var body map[string]interface{}
+		assert.NoError(t, json.Unmarshal(data, &body))
+		assert.Equal(t, "response", body["object"])
+		assert.Equal(t, "Be brief.", body["instructions"])
+		assert.Equal(t, "auto", body["tool_choice"])
+		assert.Equal(t, true, body["parallel_tool_calls"])
+		assert.Equal(t, []interface{}{}, body["output"])
+		assert.Equal(t, []interface{}{}, body["tools"])
+		assert.Nil(t, body["incomplete_details"])
+	})
+
+	t.Run("incomplete responses explain why", func(t *testing.T) {
+		req := parseResponsesRequest(t, `{"model": "m", "input": "Hi"}`)
+
+		resp := NewResponsesResponse("resp_1", 1700000000, "incomplete", req, nil, nil)
+
+		assert.Equal(t, "max_output_tokens", resp.IncompleteDetails.Reason)
+	})
+}
@@ -0,0 +1,147 @@
+package converter
+
+import (
+	"container/list"
+	"fmt"
+	"hash/maphash"
+	"math"
+	"sync"
+
+	"kiro-go-proxy/utils"
+)
+
+// toolSchemaCacheSize bounds the number of sanitized tool schemas kept in memory
+const toolSchemaCacheSize = 512
+
+// schemaKey is a 128-bit hash of a schema, built from two independently seeded hashes
+type schemaKey [2]uint64
+
+// schemaCacheEntry is a sanitized schema and the hash of its original
+type schemaCacheEntry struct {
+	key    schemaKey
+	schema map[string]interface{}
+}
+
+// schemaCache memoizes SanitizeJSONSchema by the hash of the original schema.
+// Agent frameworks send the same tool definitions every turn, so most lookups
+// hit. Cached schemas are shared between requests and must not be modified.
+type schemaCache struct {
+	maxEntries int
+	seeds      [2]maphash.Seed
+
+	order   *list.List // front is most recently used
+	entries map[schemaKey]*list.Element
+	mu      sync.Mutex
+}
+
+func newSchemaCache(maxEntries int) *schemaCache {
+	return &schemaCache{
+		maxEntries: maxEntries,
+		seeds:      [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()},
+		order:      list.New(),
+		entries:    make(map[schemaKey]*list.Element),
+	}
+}
+
+// toolSchemas is the process-wide cache used by ConvertToolsToKiroFormat
+var toolSchemas = newSchemaCache(toolSchemaCacheSize)
+
+// sanitize returns the sanitized schema, from the cache when the same schema was seen before
+func (c *schemaCache) sanitize(schema map[string]interface{}) map[string]interface{} {
+	key := c.hash(schema)
+
+	c.mu.Lock()
+	if el, ok := c.entries[key]; ok {
+		c.order.MoveToFront(el)
+		c.mu.Unlock()
+		return el.Value.(*schemaCacheEntry).schema
+	}
+	c.mu.Unlock()
+
+	sanitized := utils.SanitizeJSONSchema(schema)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if el, ok := c.entries[key]; ok {
+		// Another request sanitized the same schema meanwhile
+		c.order.MoveToFront(el)
+		return el.Value.(*schemaCacheEntry).schema
+	}
+	c.entries[key] = c.order.PushFront(&schemaCacheEntry{key: key, schema: sanitized})
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*schemaCacheEntry).key)
+	}
+	return sanitized
+}
+
+// hash walks a decoded JSON value and hashes it without allocating. Map entries
+// are combined by addition so key order does not matter.
+func (c *schemaCache) hash(value interface{}) schemaKey {
+	return schemaKey{hashValue(c.seeds[0], value), hashValue(c.seeds[1], value)}
+}
+
+// Type tags keep values of different kinds from hashing alike
+const (
+	tagNull uint64 = iota + 1
+	tagString
+	tagTrue
+	tagFalse
+	tagFloat
+	tagInt
+	tagArray
+	tagObject
+	tagOther
+)
+
+func hashValue(seed maphash.Seed, value interface{}) uint64 {
+	switch v := value.(type) {
+	case nil:
+		return mixHash(tagNull, 0)
+	case string:
+		return mixHash(tagString, maphash.String(seed, v))
+	case bool:
+		if v {
+			return mixHash(tagTrue, 0)
+		}
+		return mixHash(tagFalse, 0)
+	case float64:
+		return mixHash(tagFloat, math.Float64bits(v))
+	case int:
+		return mixHash(tagInt, uint64(v))
+	case []interface{}:
+		h := mixHash(tagArray, uint64(len(v)))
+		for _, item := range v {
+			h = mixHash(h, hashValue(seed, item))
+		}
+		return h
+	case map[string]interface{}:
+		var sum uint64
+		for k, item := range v {
+			sum += mixHash(maphash.String(seed, k), hashValue(seed, item))
+		}
+		return mixHash(mixHash(tagObject, uint64(len(v))), sum)
+	default:
+		// Schemas come from decoded JSON, so this is only reached by hand-built ones
+		return mixHash(tagOther, maphash.String(seed, fmt.Sprintf("%T:%v", v, v)))
+	}
+}
+
+// mixHash combines two hashes, finishing with the splitmix64 finalizer
+func mixHash(a, b uint64) uint64 {
+	x := a*0x9e3779b97f4a7c15 ^ b
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// len returns the number of cached schemas
+func (c *schemaCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
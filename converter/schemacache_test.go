@@ -0,0 +1,166 @@
+// Package converter provides tests for the tool schema cache.
+package converter
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/utils"
+)
+
+// benchmarkTools builds n tools with schemas shaped like typical agent tools
+func benchmarkTools(n int) []UnifiedTool {
+	tools := make([]UnifiedTool, n)
+	for i := range tools {
+		tools[i] = UnifiedTool{
+			Name:        fmt.Sprintf("tool_%d", i),
+			Description: "Performs an operation on the workspace",
+			InputSchema: map[string]interface{}{
+				"type":                 "object",
+				"additionalProperties": false,
+				"required":             []interface{}{"path"},
+				"properties": map[string]interface{}{
+					"path":    map[string]interface{}{"type": "string", "description": fmt.Sprintf("Path for tool %d", i)},
+					"recurse": map[string]interface{}{"type": "boolean", "default": false},
+					"limit":   map[string]interface{}{"type": "integer", "minimum": 1, "maximum": 1000},
+					"filters": map[string]interface{}{
+						"type": "array",
+						"items": map[string]interface{}{
+							"type":                 "object",
+							"additionalProperties": false,
+							"required":             []interface{}{},
+							"properties": map[string]interface{}{
+								"field": map[string]interface{}{"type": "string", "enum": []interface{}{"name", "size", "mtime"}},
+								"value": map[string]interface{}{"type": "string"},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+	return tools
+}
+
+// =============================================================================
+// TestSchemaCache
+// =============================================================================
+
+func TestSchemaCache(t *testing.T) {
+	t.Run("returns the same result as sanitizing directly", func(t *testing.T) {
+		cache := newSchemaCache(8)
+		schema := benchmarkTools(1)[0].InputSchema
+
+		first := cache.sanitize(schema)
+		second := cache.sanitize(schema)
+
+		assert.Equal(t, utils.SanitizeJSONSchema(schema), first)
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, cache.len())
+	})
+
+	t.Run("equal schemas share an entry", func(t *testing.T) {
+		cache := newSchemaCache(8)
+
+		cache.sanitize(map[string]interface{}{"type": "object", "required": []interface{}{"a"}})
+		cache.sanitize(map[string]interface{}{"required": []interface{}{"a"}, "type": "object"})
+
+		assert.Equal(t, 1, cache.len())
+	})
+
+	t.Run("different schemas get different results", func(t *testing.T) {
+		cache := newSchemaCache(8)
+
+		a := cache.sanitize(map[string]interface{}{"type": "object"})
+		b := cache.sanitize(map[string]interface{}{"type": "string"})
+
+		assert.Equal(t, "object", a["type"])
+		assert.Equal(t, "string", b["type"])
+	})
+
+	t.Run("nil schema becomes an empty object", func(t *testing.T) {
+		cache := newSchemaCache(8)
+		assert.Equal(t, map[string]interface{}{}, cache.sanitize(nil))
+	})
+
+	t.Run("evicts the least recently used schema", func(t *testing.T) {
+		cache := newSchemaCache(2)
+		a := map[string]interface{}{"type": "object"}
+		b := map[string]interface{}{"type": "string"}
+		c := map[string]interface{}{"type": "number"}
+
+		cache.sanitize(a)
+		cache.sanitize(b)
+		cache.sanitize(a) // a is now more recent than b
+		cache.sanitize(c)
+
+		assert.Equal(t, 2, cache.len())
+		assert.NotContains(t, cache.entries, cache.hash(b))
+		assert.Contains(t, cache.entries, cache.hash(a))
+	})
+}
+
+// =============================================================================
+// TestSchemaCacheHash
+// =============================================================================
+
+func TestSchemaCacheHash(t *testing.T) {
+	cache := newSchemaCache(1)
+
+	t.Run("ignores key order", func(t *testing.T) {
+		a := map[string]interface{}{"a": "x", "b": map[string]interface{}{"c": 1.0, "d": true}}
+		b := map[string]interface{}{"b": map[string]interface{}{"d": true, "c": 1.0}, "a": "x"}
+		assert.Equal(t, cache.hash(a), cache.hash(b))
+	})
+
+	t.Run("distinguishes values", func(t *testing.T) {
+		values := []interface{}{
+			nil,
+			"1",
+			1.0,
+			2.0,
+			true,
+			false,
+			[]interface{}{"a", "b"},
+			[]interface{}{"b", "a"},
+			[]interface{}{"ab"},
+			map[string]interface{}{"a": "b"},
+			map[string]interface{}{"b": "a"},
+			map[string]interface{}{"a": map[string]interface{}{}},
+			map[string]interface{}{"a": []interface{}{}},
+		}
+		seen := make(map[schemaKey]interface{})
+		for _, v := range values {
+			key := cache.hash(v)
+			assert.NotContains(t, seen, key, "%#v collides with %#v", v, seen[key])
+			seen[key] = v
+		}
+	})
+}
+
+// =============================================================================
+// BenchmarkConvertToolsToKiroFormat
+// =============================================================================
+
+func BenchmarkConvertToolsToKiroFormat(b *testing.B) {
+	tools := benchmarkTools(30)
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, tool := range tools {
+				utils.SanitizeJSONSchema(tool.InputSchema)
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, tool := range tools {
+				toolSchemas.sanitize(tool.InputSchema)
+			}
+		}
+	})
+}
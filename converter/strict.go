@@ -0,0 +1,282 @@
+package converter
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// FieldError is a validation failure of a single request field. Field is the
+// path in the API's own notation, e.g. "messages[0].role".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+func (e *FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// fieldErrorf returns a *FieldError for field
+func fieldErrorf(field, format string, args ...interface{}) error {
+	return &FieldError{Field: field, Message: fmt.Sprintf(format, args...)}
+}
+
+// FieldSet is the set of top-level fields an API defines for a request
+type FieldSet map[string]struct{}
+
+func newFieldSet(fields ...string) FieldSet {
+	set := make(FieldSet, len(fields))
+	for _, field := range fields {
+		set[field] = struct{}{}
+	}
+	return set
+}
+
+// Top-level request fields of each API, including ones the proxy accepts but ignores
+var (
+	OpenAIRequestFields = newFieldSet(
+		"model", "messages", "stream", "stream_options", "tools", "tool_choice",
+		"temperature", "top_p", "max_tokens", "max_completion_tokens", "n", "stop",
+		"frequency_penalty", "presence_penalty", "logit_bias", "logprobs", "top_logprobs",
+		"response_format", "reasoning_effort", "parallel_tool_calls", "seed", "user",
+		"metadata", "store", "service_tier", "modalities", "audio", "prediction",
+		"functions", "function_call", "web_search_options", "verbosity",
+		"prompt_cache_key", "safety_identifier",
+	)
+	AnthropicRequestFields = newFieldSet(
+		"model", "messages", "system", "max_tokens", "temperature", "top_p", "top_k",
+		"stop_sequences", "stream", "tools", "tool_choice", "thinking", "metadata",
+		"service_tier", "container", "mcp_servers", "context_management",
+	)
+	ResponsesRequestFields = newFieldSet(
+		"model", "input", "instructions", "tools", "tool_choice", "parallel_tool_calls",
+		"max_output_tokens", "max_tool_calls", "temperature", "top_p", "top_logprobs",
+		"reasoning", "text", "stream", "stream_options", "previous_response_id",
+		"conversation", "store", "metadata", "user", "truncation", "include",
+		"background", "service_tier", "prompt", "prompt_cache_key", "safety_identifier",
+	)
+	GeminiRequestFields = newFieldSet(
+		"contents", "systemInstruction", "tools", "toolConfig", "generationConfig",
+		"safetySettings", "cachedContent",
+	)
+	OllamaChatRequestFields = newFieldSet(
+		"model", "messages", "tools", "format", "options", "stream", "keep_alive", "think",
+	)
+	OllamaGenerateRequestFields = newFieldSet(
+		"model", "prompt", "suffix", "images", "format", "options", "system", "template",
+		"stream", "raw", "keep_alive", "context", "think",
+	)
+)
+
+// CheckUnknownFields returns a *FieldError for the first top-level field of the
+// JSON object body that known does not contain, in alphabetical order.
+// Misspelled fields that differ only in case or underscores get a suggestion.
+func CheckUnknownFields(body []byte, known FieldSet) error {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return err
+	}
+
+	var unknown []string
+	for field := range fields {
+		if _, ok := known[field]; !ok {
+			unknown = append(unknown, field)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+
+	field := unknown[0]
+	for candidate := range known {
+		if normalizeFieldName(candidate) == normalizeFieldName(field) {
+			return fieldErrorf(field, "unknown field, did you mean '%s'?", candidate)
+		}
+	}
+	return fieldErrorf(field, "unknown field")
+}
+
+// normalizeFieldName folds case and drops underscores so maxTokens matches max_tokens
+func normalizeFieldName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// checkRange returns a *FieldError when value is set and outside [min, max]
+func checkRange(field string, value *float64, min, max float64) error {
+	if value != nil && (*value < min || *value > max) {
+		return fieldErrorf(field, "must be between %g and %g, got %g", min, max, *value)
+	}
+	return nil
+}
+
+// validateOpenAITools checks that every tool is a named function, or a builtin
+// tool when allowBuiltin is set
+func validateOpenAITools(tools []OpenAITool, allowBuiltin bool) error {
+	for i, tool := range tools {
+		if allowBuiltin && IsBuiltinTool(tool.Type) {
+			continue
+		}
+		if tool.Type != "function" {
+			return fieldErrorf(fmt.Sprintf("tools[%d].type", i), "must be 'function', got '%s'", tool.Type)
+		}
+		if tool.Function.Name == "" {
+			return fieldErrorf(fmt.Sprintf("tools[%d].function.name", i), "field required")
+		}
+	}
+	return nil
+}
+
+// ValidateStrict checks the values that are passed through unchecked by default
+func (r *OpenAIRequest) ValidateStrict() error {
+	if r.Model == "" {
+		return fieldErrorf("model", "field required")
+	}
+	if len(r.Messages) == 0 {
+		return fieldErrorf("messages", "at least one message is required")
+	}
+
+	for i, msg := range r.Messages {
+		path := fmt.Sprintf("messages[%d]", i)
+		switch msg.Role {
+		case "system", "developer", "user", "assistant":
+		case "tool":
+			if msg.ToolCallID == "" {
+				return fieldErrorf(path+".tool_call_id", "field required for tool messages")
+			}
+		default:
+			return fieldErrorf(path+".role", "must be one of 'system', 'developer', 'user', 'assistant', 'tool', got '%s'", msg.Role)
+		}
+		if err := validateOpenAIContent(path+".content", msg.Content); err != nil {
+			return err
+		}
+		for j, call := range msg.ToolCalls {
+			if call.Function.Name == "" {
+				return fieldErrorf(fmt.Sprintf("%s.tool_calls[%d].function.name", path, j), "field required")
+			}
+		}
+	}
+
+	// Builtin tools are run by the proxy itself (see OpenAIRequest.BuiltinTools)
+	if err := validateOpenAITools(r.Tools, true); err != nil {
+		return err
+	}
+
+	if err := checkRange("temperature", r.Temperature, 0, 2); err != nil {
+		return err
+	}
+	if err := checkRange("top_p", r.TopP, 0, 1); err != nil {
+		return err
+	}
+	if err := checkRange("frequency_penalty", r.FrequencyPenalty, -2, 2); err != nil {
+		return err
+	}
+	if err := checkRange("presence_penalty", r.PresencePenalty, -2, 2); err != nil {
+		return err
+	}
+
+	if rf := r.ResponseFormat; rf != nil {
+		switch rf.Type {
+		case "text", ResponseFormatJSONObject:
+		case ResponseFormatJSONSchema:
+			if rf.JSONSchema == nil || rf.JSONSchema.Name == "" {
+				return fieldErrorf("response_format.json_schema.name", "field required when type is 'json_schema'")
+			}
+		default:
+			return fieldErrorf("response_format.type", "must be one of 'text', 'json_object', 'json_schema', got '%s'", rf.Type)
+		}
+	}
+
+	return nil
+}
+
+// validateOpenAIContent checks that message content is a string, null or a list
+// of text and image_url parts
+func validateOpenAIContent(path string, content interface{}) error {
+	parts, ok := content.([]interface{})
+	if !ok {
+		switch content.(type) {
+		case nil, string:
+			return nil
+		}
+		return fieldErrorf(path, "must be a string or an array of content parts")
+	}
+
+	for i, part := range parts {
+		partPath := fmt.Sprintf("%s[%d]", path, i)
+		partMap, ok := part.(map[string]interface{})
+		if !ok {
+			return fieldErrorf(partPath, "must be an object")
+		}
+		switch partType, _ := partMap["type"].(string); partType {
+		case "text":
+			if _, ok := partMap["text"].(string); !ok {
+				return fieldErrorf(partPath+".text", "field required")
+			}
+		case "image_url":
+			imageURL, _ := partMap["image_url"].(map[string]interface{})
+			if url, _ := imageURL["url"].(string); url == "" {
+				return fieldErrorf(partPath+".image_url.url", "field required")
+			}
+		case "":
+			return fieldErrorf(partPath+".type", "field required")
+		default:
+			return fieldErrorf(partPath+".type", "unsupported content part type '%s', expected 'text' or 'image_url'", partType)
+		}
+	}
+	return nil
+}
+
+// ValidateStrict checks the values that are passed through unchecked by default
+func (r *ResponsesRequest) ValidateStrict() error {
+	if err := checkRange("temperature", r.Temperature, 0, 2); err != nil {
+		return err
+	}
+	if err := checkRange("top_p", r.TopP, 0, 1); err != nil {
+		return err
+	}
+	for i, tool := range r.Tools {
+		if tool.Name == "" {
+			return fieldErrorf(fmt.Sprintf("tools[%d].name", i), "field required")
+		}
+	}
+	return nil
+}
+
+// ValidateStrict checks the values that are passed through unchecked by default
+func (r *GeminiRequest) ValidateStrict() error {
+	if gc := r.GenerationConfig; gc != nil {
+		if err := checkRange("generationConfig.temperature", gc.Temperature, 0, 2); err != nil {
+			return err
+		}
+		if err := checkRange("generationConfig.topP", gc.TopP, 0, 1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ValidateStrict checks the values that are passed through unchecked by default
+func (r *OllamaChatRequest) ValidateStrict() error {
+	if err := validateOpenAITools(r.Tools, false); err != nil {
+		return err
+	}
+	return validateOllamaOptions(r.Options)
+}
+
+// ValidateStrict checks the values that are passed through unchecked by default
+func (r *OllamaGenerateRequest) ValidateStrict() error {
+	return validateOllamaOptions(r.Options)
+}
+
+func validateOllamaOptions(options *OllamaOptions) error {
+	if options == nil {
+		return nil
+	}
+	if err := checkRange("options.temperature", options.Temperature, 0, 2); err != nil {
+		return err
+	}
+	return checkRange("options.top_p", options.TopP, 0, 1)
+}
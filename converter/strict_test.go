@@ -0,0 +1,131 @@
+// Package converter provides tests for strict request validation.
+package converter
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestCheckUnknownFields
+// =============================================================================
+
+func TestCheckUnknownFields(t *testing.T) {
+	t.Run("accepts defined fields", func(t *testing.T) {
+		body := `{"model": "m", "messages": [], "user": "u", "seed": 1, "tool_choice": "auto"}`
+		assert.NoError(t, CheckUnknownFields([]byte(body), OpenAIRequestFields))
+	})
+
+	t.Run("rejects the first unknown field", func(t *testing.T) {
+		err := CheckUnknownFields([]byte(`{"model": "m", "zeta": 1, "alpha": 2}`), OpenAIRequestFields)
+
+		var fieldErr *FieldError
+		assert.ErrorAs(t, err, &fieldErr)
+		assert.Equal(t, "alpha", fieldErr.Field)
+		assert.Equal(t, "alpha: unknown field", err.Error())
+	})
+
+	t.Run("suggests the field for case and underscore mix-ups", func(t *testing.T) {
+		err := CheckUnknownFields([]byte(`{"maxTokens": 10}`), AnthropicRequestFields)
+		assert.EqualError(t, err, "maxTokens: unknown field, did you mean 'max_tokens'?")
+
+		err = CheckUnknownFields([]byte(`{"system_instruction": {}}`), GeminiRequestFields)
+		assert.EqualError(t, err, "system_instruction: unknown field, did you mean 'systemInstruction'?")
+	})
+
+	t.Run("rejects a body that is not an object", func(t *testing.T) {
+		assert.Error(t, CheckUnknownFields([]byte(`[]`), OpenAIRequestFields))
+	})
+}
+
+// =============================================================================
+// TestOpenAIRequestValidateStrict
+// =============================================================================
+
+func TestOpenAIRequestValidateStrict(t *testing.T) {
+	parse := func(t *testing.T, body string) *OpenAIRequest {
+		var req OpenAIRequest
+		assert.NoError(t, json.Unmarshal([]byte(body), &req))
+		return &req
+	}
+
+	t.Run("accepts a valid request", func(t *testing.T) {
+		req := parse(t, `{
+			"model": "m",
+			"temperature": 2,
+			"messages": [
+				{"role": "developer", "content": "Be brief."},
+				{"role": "user", "content": [
+					{"type": "text", "text": "What is this?"},
+					{"type": "image_url", "image_url": {"url": "data:image/png;base64,AAAA"}}
+				]},
+				{"role": "assistant", "content": null, "tool_calls": [{"id": "c1", "type": "function", "function": {"name": "f", "arguments": "{}"}}]},
+				{"role": "tool", "tool_call_id": "c1", "content": "done"}
+			],
+			"tools": [{"type": "function", "function": {"name": "f"}}, {"type": "web_fetch"}],
+			"response_format": {"type": "json_schema", "json_schema": {"name": "answer"}}
+		}`)
+		assert.NoError(t, req.ValidateStrict())
+	})
+
+	tests := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"missing model", `{"messages": [{"role": "user", "content": "Hi"}]}`, "model"},
+		{"no messages", `{"model": "m", "messages": []}`, "messages"},
+		{"bad role", `{"model": "m", "messages": [{"role": "human", "content": "Hi"}]}`, "messages[0].role"},
+		{"tool message without tool_call_id", `{"model": "m", "messages": [{"role": "tool", "content": "x"}]}`, "messages[0].tool_call_id"},
+		{"content of the wrong type", `{"model": "m", "messages": [{"role": "user", "content": 42}]}`, "messages[0].content"},
+		{"unknown content part", `{"model": "m", "messages": [{"role": "user", "content": [{"type": "input_audio"}]}]}`, "messages[0].content[0].type"},
+		{"image without url", `{"model": "m", "messages": [{"role": "user", "content": [{"type": "image_url", "image_url": {}}]}]}`, "messages[0].content[0].image_url.url"},
+		{"unnamed tool call", `{"model": "m", "messages": [{"role": "assistant", "tool_calls": [{"id": "c1", "function": {}}]}]}`, "messages[0].tool_calls[0].function.name"},
+		{"unnamed tool", `{"model": "m", "messages": [{"role": "user", "content": "Hi"}], "tools": [{"type": "function", "function": {}}]}`, "tools[0].function.name"},
+		{"non-function tool", `{"model": "m", "messages": [{"role": "user", "content": "Hi"}], "tools": [{"type": "web_search"}]}`, "tools[0].type"},
+		{"temperature out of range", `{"model": "m", "messages": [{"role": "user", "content": "Hi"}], "temperature": 2.5}`, "temperature"},
+		{"top_p out of range", `{"model": "m", "messages": [{"role": "user", "content": "Hi"}], "top_p": -0.1}`, "top_p"},
+		{"penalty out of range", `{"model": "m", "messages": [{"role": "user", "content": "Hi"}], "presence_penalty": 3}`, "presence_penalty"},
+		{"bad response format", `{"model": "m", "messages": [{"role": "user", "content": "Hi"}], "response_format": {"type": "xml"}}`, "response_format.type"},
+	}
+	for _, tt := range tests {
+		t.Run("rejects "+tt.name, func(t *testing.T) {
+			err := parse(t, tt.body).ValidateStrict()
+
+			var fieldErr *FieldError
+			assert.ErrorAs(t, err, &fieldErr)
+			assert.Equal(t, tt.field, fieldErr.Field)
+		})
+	}
+}
+
+// =============================================================================
+// TestValidateStrict
+// =============================================================================
+
+func TestValidateStrict(t *testing.T) {
+	two := 2.0
+	half := 0.5
+
+	t.Run("responses ranges and tool names", func(t *testing.T) {
+		assert.NoError(t, (&ResponsesRequest{Temperature: &two, TopP: &half}).ValidateStrict())
+		assert.EqualError(t, (&ResponsesRequest{TopP: &two}).ValidateStrict(), "top_p: must be between 0 and 1, got 2")
+		assert.EqualError(t, (&ResponsesRequest{Tools: []ResponsesTool{{Type: "function"}}}).ValidateStrict(), "tools[0].name: field required")
+	})
+
+	t.Run("gemini generation config ranges", func(t *testing.T) {
+		three := 3.0
+		req := &GeminiRequest{GenerationConfig: &GeminiGenerationConfig{Temperature: &three}}
+		assert.EqualError(t, req.ValidateStrict(), "generationConfig.temperature: must be between 0 and 2, got 3")
+	})
+
+	t.Run("ollama tools and options", func(t *testing.T) {
+		chat := &OllamaChatRequest{Tools: []OpenAITool{{Type: "function"}}}
+		assert.EqualError(t, chat.ValidateStrict(), "tools[0].function.name: field required")
+
+		generate := &OllamaGenerateRequest{Options: &OllamaOptions{TopP: &two}}
+		assert.EqualError(t, generate.ValidateStrict(), "options.top_p: must be between 0 and 1, got 2")
+	})
+}
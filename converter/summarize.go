@@ -0,0 +1,66 @@
+package converter
+
+import (
+	"fmt"
+	"strings"
+
+	"kiro-go-proxy/utils"
+)
+
+// summaryInstruction asks the summarization model to compress the transcript
+const summaryInstruction = `Summarize the conversation above for an assistant that will continue it without seeing it. Keep the user's goals and instructions, decisions made, facts and figures, file names and code identifiers, tool results that are still relevant, and open questions. Write only the summary, without a preamble.`
+
+// summaryPrefix introduces the summary that replaces the earlier messages
+const summaryPrefix = "[Summary of the earlier conversation]"
+
+// SummarySplit returns the index of the first message kept verbatim when the
+// messages before it are summarized: at least the last keep messages, starting
+// with a user message that holds no tool results, whose calls would be in the
+// summary. It returns 0 when fewer than two messages would be summarized.
+func SummarySplit(messages []UnifiedMessage, keep int) int {
+	for split := len(messages) - keep; split >= 2; split-- {
+		if msg := messages[split]; msg.Role == "user" && len(msg.ToolResults) == 0 {
+			return split
+		}
+	}
+	return 0
+}
+
+// SummaryPrompt renders messages as a transcript followed by the instruction to
+// summarize it. Images are noted but not sent.
+func SummaryPrompt(messages []UnifiedMessage) string {
+	var b strings.Builder
+	b.WriteString("<conversation>\n")
+	for _, msg := range messages {
+		for _, tr := range msg.ToolResults {
+			fmt.Fprintf(&b, "Tool result (%s): %s\n\n", tr.ToolUseID, utils.ExtractTextContent(tr.Content))
+		}
+		text := utils.ExtractTextContent(msg.Content)
+		if text == "" && len(msg.Images) == 0 && len(msg.ToolCalls) == 0 {
+			continue
+		}
+		role := "User"
+		if msg.Role == "assistant" {
+			role = "Assistant"
+		}
+		fmt.Fprintf(&b, "%s: %s", role, text)
+		for range msg.Images {
+			b.WriteString(" [image]")
+		}
+		for _, tc := range msg.ToolCalls {
+			fmt.Fprintf(&b, "\n[Tool call %s (%s): %s]", tc.Function.Name, tc.ID, tc.Function.Arguments)
+		}
+		b.WriteString("\n\n")
+	}
+	b.WriteString("</conversation>\n\n")
+	b.WriteString(summaryInstruction)
+	return b.String()
+}
+
+// WithSummary replaces the messages before split by a user message holding the
+// summary. It is merged with the first kept message when the payload is built.
+func WithSummary(messages []UnifiedMessage, split int, summary string) []UnifiedMessage {
+	result := make([]UnifiedMessage, 0, len(messages)-split+1)
+	result = append(result, UnifiedMessage{Role: "user", Content: summaryPrefix + "\n" + strings.TrimSpace(summary)})
+	return append(result, messages[split:]...)
+}
@@ -0,0 +1,76 @@
+// Package converter provides tests for history summarization.
+package converter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// summaryHistory returns a conversation with a tool call in its second turn
+func summaryHistory() []UnifiedMessage {
+	call := ToolCall{ID: "call_1", Type: "function"}
+	call.Function.Name = "get_weather"
+	call.Function.Arguments = `{"city":"Paris"}`
+	return []UnifiedMessage{
+		{Role: "user", Content: "I am planning a trip to Paris."},
+		{Role: "assistant", Content: "Great, how can I help?"},
+		{Role: "user", Content: "What is the weather there?"},
+		{Role: "assistant", ToolCalls: []ToolCall{call}},
+		{Role: "user", ToolResults: []ToolResult{{ToolUseID: "call_1", Content: "Sunny, 24C"}}},
+		{Role: "assistant", Content: "It is sunny and 24C."},
+		{Role: "user", Content: "What should I pack?"},
+	}
+}
+
+// =============================================================================
+// TestSummarySplit
+// =============================================================================
+
+func TestSummarySplit(t *testing.T) {
+	t.Run("keeps the last messages from a user turn", func(t *testing.T) {
+		assert.Equal(t, 6, SummarySplit(summaryHistory(), 1))
+	})
+
+	t.Run("does not split a tool call from its result", func(t *testing.T) {
+		// messages[4] holds the tool result, so the split moves back to messages[2]
+		assert.Equal(t, 2, SummarySplit(summaryHistory(), 3))
+	})
+
+	t.Run("needs at least two messages to summarize", func(t *testing.T) {
+		assert.Equal(t, 0, SummarySplit(summaryHistory(), 6))
+		assert.Equal(t, 0, SummarySplit(summaryHistory(), 10))
+		assert.Equal(t, 0, SummarySplit(nil, 1))
+	})
+}
+
+// =============================================================================
+// TestSummaryPrompt
+// =============================================================================
+
+func TestSummaryPrompt(t *testing.T) {
+	prompt := SummaryPrompt(summaryHistory()[:6])
+
+	assert.Contains(t, prompt, "User: I am planning a trip to Paris.")
+	assert.Contains(t, prompt, "Assistant: Great, how can I help?")
+	assert.Contains(t, prompt, `[Tool call get_weather (call_1): {"city":"Paris"}]`)
+	assert.Contains(t, prompt, "Tool result (call_1): Sunny, 24C")
+	assert.NotContains(t, prompt, "What should I pack?")
+	assert.Contains(t, prompt, summaryInstruction)
+}
+
+// =============================================================================
+// TestWithSummary
+// =============================================================================
+
+func TestWithSummary(t *testing.T) {
+	messages := summaryHistory()
+
+	result := WithSummary(messages, 6, "  The user plans a trip to Paris, where it is sunny.\n")
+
+	assert.Len(t, result, 2)
+	assert.Equal(t, "user", result[0].Role)
+	assert.Equal(t, summaryPrefix+"\nThe user plans a trip to Paris, where it is sunny.", result[0].Content)
+	assert.Equal(t, "What should I pack?", result[1].Content)
+	assert.Len(t, messages, 7)
+}
@@ -0,0 +1,101 @@
+package converter
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// SystemPromptData is the data available to system_prompt_templates
+type SystemPromptData struct {
+	// System is the client's system prompt after the strip patterns were applied
+	System string
+	// Model is the resolved Kiro model ID
+	Model string
+	// Date is the current date as YYYY-MM-DD
+	Date string
+}
+
+// compiledPatterns caches strip patterns by source so each is compiled once
+var compiledPatterns sync.Map
+
+// ApplySystemPromptPolicy applies the operator's system prompt policy to the
+// client's system prompt, in this order:
+//
+//  1. Text matching any SYSTEM_PROMPT_STRIP_PATTERNS regexp is removed.
+//  2. The template for modelID, if any, is rendered with the remaining text as
+//     {{.System}}. An exact model ID wins over the longest matching glob
+//     ("claude-sonnet-*"), which wins over "*".
+//  3. SYSTEM_PROMPT_PREFIX and SYSTEM_PROMPT_SUFFIX are added around the result,
+//     so neither client text nor templates can drop them.
+//
+// Tool documentation and other gateway additions are appended afterwards.
+func ApplySystemPromptPolicy(systemPrompt, modelID string, cfg *config.Config) string {
+	prompt := stripSystemPrompt(systemPrompt, cfg.SystemPromptStripPatterns)
+
+	if name, text, ok := model.MatchPattern(modelID, cfg.SystemPromptTemplates); ok {
+		rendered, err := renderSystemPrompt(name, text, SystemPromptData{
+			System: prompt,
+			Model:  modelID,
+			Date:   time.Now().Format("2006-01-02"),
+		})
+		if err != nil {
+			log.Warnf("System prompt template %q failed, using the client system prompt: %v", name, err)
+		} else {
+			prompt = rendered
+		}
+	}
+
+	var parts []string
+	for _, part := range []string{cfg.SystemPromptPrefix, prompt, cfg.SystemPromptSuffix} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, "\n\n")
+}
+
+// stripSystemPrompt removes every match of the patterns from prompt
+func stripSystemPrompt(prompt string, patterns []string) string {
+	for _, pattern := range patterns {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			log.Warnf("Skipping invalid system prompt strip pattern %q: %v", pattern, err)
+			continue
+		}
+		prompt = re.ReplaceAllString(prompt, "")
+	}
+	return strings.TrimSpace(prompt)
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := compiledPatterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	compiledPatterns.Store(pattern, re)
+	return re, nil
+}
+
+// renderSystemPrompt executes a system prompt template
+func renderSystemPrompt(name, text string, data SystemPromptData) (string, error) {
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return "", err
+	}
+	var sb strings.Builder
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+	return sb.String(), nil
+}
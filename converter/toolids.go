@@ -0,0 +1,94 @@
+package converter
+
+import (
+	"regexp"
+
+	"kiro-go-proxy/utils"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// kiroToolUseIDPattern is the toolUseId format Kiro accepts
+var kiroToolUseIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// toolUseIDs maps client tool call IDs to the toolUseIds sent to Kiro. Client
+// IDs are kept whenever Kiro accepts them, so the IDs in Kiro's history are the
+// ones the client sees and sends back on the next turn; a new ID is generated
+// only for a call without one, or one Kiro would reject or confuse with an
+// earlier call. Results are paired with the oldest unanswered call of the same
+// client ID, or the oldest unanswered call at all when they have no ID.
+type toolUseIDs struct {
+	used map[string]bool
+	open []toolUseIDCall
+}
+
+// toolUseIDCall is a tool call that has not been answered yet
+type toolUseIDCall struct {
+	clientID string
+	kiroID   string
+}
+
+// call records a tool call and returns its Kiro ID
+func (ids *toolUseIDs) call(clientID string) string {
+	kiroID := clientID
+	if clientID == "" || ids.used[clientID] || !kiroToolUseIDPattern.MatchString(clientID) {
+		kiroID = ids.generate()
+		if clientID != "" {
+			log.Debugf("Sending tool call '%s' to Kiro as '%s'", clientID, kiroID)
+		}
+	}
+	ids.used[kiroID] = true
+	ids.open = append(ids.open, toolUseIDCall{clientID: clientID, kiroID: kiroID})
+	return kiroID
+}
+
+// result returns the Kiro ID of the call a tool result answers. A result for a
+// call outside the request keeps its client ID.
+func (ids *toolUseIDs) result(clientID string) string {
+	for i, call := range ids.open {
+		if clientID == "" || call.clientID == clientID {
+			ids.open = append(ids.open[:i:i], ids.open[i+1:]...)
+			return call.kiroID
+		}
+	}
+	if clientID == "" {
+		return ids.generate()
+	}
+	return clientID
+}
+
+// generate returns a new toolUseId that is not used in the request
+func (ids *toolUseIDs) generate() string {
+	for {
+		if id := utils.GenerateToolUseID(); !ids.used[id] {
+			return id
+		}
+	}
+}
+
+// MapToolUseIDs returns messages with tool call and tool result IDs replaced by
+// the toolUseIds sent to Kiro (see toolUseIDs). The input messages are not modified.
+func MapToolUseIDs(messages []UnifiedMessage) []UnifiedMessage {
+	ids := &toolUseIDs{used: make(map[string]bool)}
+	mapped := make([]UnifiedMessage, len(messages))
+	for i, msg := range messages {
+		if len(msg.ToolCalls) > 0 {
+			calls := make([]ToolCall, len(msg.ToolCalls))
+			for j, tc := range msg.ToolCalls {
+				calls[j] = tc
+				calls[j].ID = ids.call(tc.ID)
+			}
+			msg.ToolCalls = calls
+		}
+		if len(msg.ToolResults) > 0 {
+			results := make([]ToolResult, len(msg.ToolResults))
+			for j, tr := range msg.ToolResults {
+				results[j] = tr
+				results[j].ToolUseID = ids.result(tr.ToolUseID)
+			}
+			msg.ToolResults = results
+		}
+		mapped[i] = msg
+	}
+	return mapped
+}
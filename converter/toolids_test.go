@@ -0,0 +1,123 @@
+// Package converter provides tests for tool call ID mapping.
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"kiro-go-proxy/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func toolCall(id, name string) ToolCall {
+	tc := ToolCall{ID: id, Type: "function"}
+	tc.Function.Name = name
+	tc.Function.Arguments = "{}"
+	return tc
+}
+
+// =============================================================================
+// TestMapToolUseIDs
+// =============================================================================
+
+func TestMapToolUseIDs(t *testing.T) {
+	t.Run("keeps client IDs", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "user", Content: "Weather?"},
+			{Role: "assistant", ToolCalls: []ToolCall{toolCall("toolu_01A", "get_weather"), toolCall("call_b", "get_time")}},
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "call_b", Content: "noon"}, {ToolUseID: "toolu_01A", Content: "sunny"}}},
+		}
+
+		mapped := MapToolUseIDs(messages)
+
+		assert.Equal(t, messages, mapped)
+	})
+
+	t.Run("generates missing IDs and pairs results in call order", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "assistant", ToolCalls: []ToolCall{toolCall("", "get_weather"), toolCall("", "get_time")}},
+			{Role: "user", ToolResults: []ToolResult{{Content: "sunny"}, {Content: "noon"}}},
+		}
+
+		mapped := MapToolUseIDs(messages)
+
+		first, second := mapped[0].ToolCalls[0].ID, mapped[0].ToolCalls[1].ID
+		assert.True(t, strings.HasPrefix(first, "toolu_"))
+		assert.NotEqual(t, first, second)
+		assert.Equal(t, first, mapped[1].ToolResults[0].ToolUseID)
+		assert.Equal(t, second, mapped[1].ToolResults[1].ToolUseID)
+	})
+
+	t.Run("renames reused and invalid IDs", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "assistant", ToolCalls: []ToolCall{toolCall("call_1", "search")}},
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "call_1", Content: "first"}}},
+			{Role: "assistant", ToolCalls: []ToolCall{toolCall("call_1", "search"), toolCall("functions.search:0", "search")}},
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "functions.search:0", Content: "third"}, {ToolUseID: "call_1", Content: "second"}}},
+		}
+
+		mapped := MapToolUseIDs(messages)
+
+		assert.Equal(t, "call_1", mapped[0].ToolCalls[0].ID)
+		assert.Equal(t, "call_1", mapped[1].ToolResults[0].ToolUseID)
+		reused, invalid := mapped[2].ToolCalls[0].ID, mapped[2].ToolCalls[1].ID
+		assert.NotEqual(t, "call_1", reused)
+		assert.Regexp(t, kiroToolUseIDPattern, invalid)
+		assert.Equal(t, invalid, mapped[3].ToolResults[0].ToolUseID)
+		assert.Equal(t, reused, mapped[3].ToolResults[1].ToolUseID)
+	})
+
+	t.Run("keeps results for calls outside the request", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "user", ToolResults: []ToolResult{{ToolUseID: "toolu_earlier", Content: "done"}}},
+		}
+
+		assert.Equal(t, "toolu_earlier", MapToolUseIDs(messages)[0].ToolResults[0].ToolUseID)
+	})
+
+	t.Run("does not modify the input", func(t *testing.T) {
+		messages := []UnifiedMessage{
+			{Role: "assistant", ToolCalls: []ToolCall{toolCall("", "get_weather")}},
+			{Role: "user", ToolResults: []ToolResult{{Content: "sunny"}}},
+		}
+
+		MapToolUseIDs(messages)
+
+		assert.Empty(t, messages[0].ToolCalls[0].ID)
+		assert.Empty(t, messages[1].ToolResults[0].ToolUseID)
+	})
+}
+
+// =============================================================================
+// TestBuildKiroPayloadToolLoop
+// =============================================================================
+
+func TestBuildKiroPayloadToolLoop(t *testing.T) {
+	cfg := &config.Config{ToolDescriptionMaxLength: 10000}
+	tools := []UnifiedTool{{Name: "get_weather"}}
+
+	// Two rounds of an Anthropic tool loop, as the client resends them
+	req := parseAnthropicRequest(t, `{"model": "m", "messages": [
+		{"role": "user", "content": "Weather in Paris and London?"},
+		{"role": "assistant", "content": [{"type": "tool_use", "id": "tooluse_kiro1", "name": "get_weather", "input": {"city": "Paris"}}]},
+		{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "tooluse_kiro1", "content": "Sunny"}]},
+		{"role": "assistant", "content": [{"type": "text", "text": "Now London."}, {"type": "tool_use", "id": "toolu_01XyZ", "name": "get_weather", "input": {"city": "London"}}]},
+		{"role": "user", "content": [{"type": "tool_result", "tool_use_id": "toolu_01XyZ", "content": "Rain"}]}
+	]}`)
+	messages, _ := ConvertAnthropicToUnified(req)
+
+	payload, err := BuildKiroPayload(messages, "", "model", tools, "conv", "", 0, cfg)
+
+	assert.NoError(t, err)
+	history := payload.ConversationState.History
+	assert.Len(t, history, 4)
+	firstUses := history[1].(map[string]interface{})["assistantResponseMessage"].(map[string]interface{})["toolUses"].([]map[string]interface{})
+	assert.Equal(t, "tooluse_kiro1", firstUses[0]["toolUseId"])
+	firstResults := history[2].(map[string]interface{})["userInputMessage"].(map[string]interface{})["userInputMessageContext"].(map[string]interface{})["toolResults"].([]map[string]interface{})
+	assert.Equal(t, "tooluse_kiro1", firstResults[0]["toolUseId"])
+	secondUses := history[3].(map[string]interface{})["assistantResponseMessage"].(map[string]interface{})["toolUses"].([]map[string]interface{})
+	assert.Equal(t, "toolu_01XyZ", secondUses[0]["toolUseId"])
+	current := payload.ConversationState.CurrentMessage.UserInputMessage.UserInputMessageContext
+	assert.Equal(t, "toolu_01XyZ", current.ToolResults[0]["toolUseId"])
+}
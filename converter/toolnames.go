@@ -0,0 +1,98 @@
+package converter
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Kiro accepts tool names of up to 64 letters, digits, underscores and hyphens
+const maxKiroToolNameLength = 64
+
+var (
+	kiroToolNamePattern  = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+	invalidToolNameChars = regexp.MustCompile(`[^A-Za-z0-9_-]`)
+)
+
+// SanitizeToolNames returns tools with names Kiro accepts and a map from each
+// changed name back to the client's name, for translating tool calls in the
+// response. Invalid characters become underscores; long names are cut and end in
+// a hash of the original, so names sharing a prefix stay distinct.
+func SanitizeToolNames(tools []UnifiedTool) ([]UnifiedTool, map[string]string) {
+	var clientNames map[string]string
+	used := make(map[string]bool, len(tools))
+	for _, tool := range tools {
+		if kiroToolNamePattern.MatchString(tool.Name) {
+			used[tool.Name] = true
+		}
+	}
+
+	sanitized := make([]UnifiedTool, len(tools))
+	for i, tool := range tools {
+		sanitized[i] = tool
+		if kiroToolNamePattern.MatchString(tool.Name) {
+			continue
+		}
+
+		name := sanitizeToolName(tool.Name)
+		if used[name] {
+			// Another tool already has the name: make it unique with the hash
+			name = hashedToolName(invalidToolNameChars.ReplaceAllString(tool.Name, "_"), tool.Name)
+		}
+		used[name] = true
+		log.Debugf("Sending tool '%s' to Kiro as '%s'", tool.Name, name)
+
+		if clientNames == nil {
+			clientNames = make(map[string]string)
+		}
+		clientNames[name] = tool.Name
+		sanitized[i].Name = name
+	}
+	return sanitized, clientNames
+}
+
+// sanitizeToolName returns a name Kiro accepts for name
+func sanitizeToolName(name string) string {
+	if kiroToolNamePattern.MatchString(name) {
+		return name
+	}
+	clean := invalidToolNameChars.ReplaceAllString(name, "_")
+	if clean == "" || len(clean) > maxKiroToolNameLength {
+		return hashedToolName(clean, name)
+	}
+	return clean
+}
+
+// hashedToolName cuts prefix to fit a hash of original after it
+func hashedToolName(prefix, original string) string {
+	sum := sha256.Sum256([]byte(original))
+	suffix := "_" + hex.EncodeToString(sum[:4])
+	if len(prefix) > maxKiroToolNameLength-len(suffix) {
+		prefix = prefix[:maxKiroToolNameLength-len(suffix)]
+	}
+	if prefix == "" {
+		prefix = "tool"
+	}
+	return prefix + suffix
+}
+
+// renameToolCalls gives the tool calls of messages the names sent to Kiro.
+// clientNames maps Kiro names to client names, as returned by SanitizeToolNames.
+func renameToolCalls(messages []UnifiedMessage, clientNames map[string]string) {
+	kiroNames := make(map[string]string, len(clientNames))
+	for kiroName, clientName := range clientNames {
+		kiroNames[clientName] = kiroName
+	}
+
+	for i := range messages {
+		for j, tc := range messages[i].ToolCalls {
+			name, ok := kiroNames[tc.Function.Name]
+			if !ok {
+				name = sanitizeToolName(tc.Function.Name)
+			}
+			messages[i].ToolCalls[j].Function.Name = name
+		}
+	}
+}
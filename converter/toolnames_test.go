@@ -0,0 +1,85 @@
+// Package converter provides tests for tool name sanitization.
+package converter
+
+import (
+	"strings"
+	"testing"
+
+	"kiro-go-proxy/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestSanitizeToolNames
+// =============================================================================
+
+func TestSanitizeToolNames(t *testing.T) {
+	t.Run("keeps valid names", func(t *testing.T) {
+		tools := []UnifiedTool{{Name: "get_weather"}, {Name: "mcp__github__create_issue"}}
+
+		sanitized, clientNames := SanitizeToolNames(tools)
+
+		assert.Equal(t, tools, sanitized)
+		assert.Nil(t, clientNames)
+	})
+
+	t.Run("replaces invalid characters", func(t *testing.T) {
+		sanitized, clientNames := SanitizeToolNames([]UnifiedTool{{Name: "mcp.fs/read file"}})
+
+		assert.Equal(t, "mcp_fs_read_file", sanitized[0].Name)
+		assert.Equal(t, map[string]string{"mcp_fs_read_file": "mcp.fs/read file"}, clientNames)
+	})
+
+	t.Run("shortens long names and keeps them distinct", func(t *testing.T) {
+		prefix := "mcp__server__" + strings.Repeat("very_long_tool_name_", 3)
+		tools := []UnifiedTool{{Name: prefix + "list"}, {Name: prefix + "read"}}
+
+		sanitized, clientNames := SanitizeToolNames(tools)
+
+		assert.Len(t, sanitized[0].Name, 64)
+		assert.Len(t, sanitized[1].Name, 64)
+		assert.NotEqual(t, sanitized[0].Name, sanitized[1].Name)
+		assert.Regexp(t, kiroToolNamePattern, sanitized[0].Name)
+		assert.Equal(t, prefix+"list", clientNames[sanitized[0].Name])
+		assert.Equal(t, prefix+"read", clientNames[sanitized[1].Name])
+	})
+
+	t.Run("avoids names of other tools", func(t *testing.T) {
+		tools := []UnifiedTool{{Name: "read_file"}, {Name: "read.file"}, {Name: "read/file"}}
+
+		sanitized, clientNames := SanitizeToolNames(tools)
+
+		assert.Equal(t, "read_file", sanitized[0].Name)
+		assert.NotEqual(t, "read_file", sanitized[1].Name)
+		assert.NotEqual(t, sanitized[1].Name, sanitized[2].Name)
+		assert.Len(t, clientNames, 2)
+	})
+}
+
+// =============================================================================
+// TestBuildKiroPayloadToolNames
+// =============================================================================
+
+func TestBuildKiroPayloadToolNames(t *testing.T) {
+	cfg := &config.Config{ToolDescriptionMaxLength: 10000}
+	call := ToolCall{ID: "toolu_1", Type: "function"}
+	call.Function.Name = "mcp.fs/read"
+	call.Function.Arguments = `{"path":"a.txt"}`
+	messages := []UnifiedMessage{
+		{Role: "user", Content: "Read a.txt"},
+		{Role: "assistant", ToolCalls: []ToolCall{call}},
+		{Role: "user", ToolResults: []ToolResult{{ToolUseID: "toolu_1", Content: "hello"}}},
+	}
+
+	payload, err := BuildKiroPayload(messages, "", "model", []UnifiedTool{{Name: "mcp.fs/read"}}, "conv", "", 0, cfg)
+
+	assert.NoError(t, err)
+	tools := payload.ConversationState.CurrentMessage.UserInputMessage.UserInputMessageContext.Tools
+	assert.Equal(t, "mcp_fs_read", tools[0]["toolSpecification"].(map[string]interface{})["name"])
+	toolUses := payload.ConversationState.History[1].(map[string]interface{})["assistantResponseMessage"].(map[string]interface{})["toolUses"].([]map[string]interface{})
+	assert.Equal(t, "mcp_fs_read", toolUses[0]["name"])
+	assert.Equal(t, map[string]string{"mcp_fs_read": "mcp.fs/read"}, payload.ToolNames)
+	assert.Equal(t, payload.ToolNames, payload.Clone().ToolNames)
+	assert.Equal(t, "mcp.fs/read", messages[1].ToolCalls[0].Function.Name)
+}
@@ -0,0 +1,369 @@
+// Package convstore keeps conversation histories on the server for clients that
+// send only their latest message.
+//
+// With CONVERSATION_STORE_DIR set, each conversation named by a client's
+// x-conversation-id is saved as one JSON file holding its messages, including
+// the assistant's replies. The next request of the conversation gets the stored
+// messages put ahead of its own before the Kiro payload is built. Turns of one
+// conversation run one at a time, so concurrent requests cannot overwrite each
+// other's replies. Conversations expire CONVERSATION_STORE_TTL seconds after
+// their last turn.
+package convstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/parser"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// fileExt is the extension of conversation files within CONVERSATION_STORE_DIR
+const fileExt = ".json"
+
+// sweepInterval is the least time between two sweeps for expired conversations
+const sweepInterval = time.Hour
+
+// conversation is the content of a conversation file
+type conversation struct {
+	ID        string                     `json:"id"`
+	UpdatedAt time.Time                  `json:"updated_at"`
+	Messages  []converter.UnifiedMessage `json:"messages"`
+}
+
+// Store persists conversations. A nil *Store is valid and stores nothing, so
+// callers need no checks when the store is disabled.
+type Store struct {
+	dir string
+	ttl time.Duration
+	now func() time.Time
+
+	lastSweep time.Time
+	mu        sync.Mutex
+
+	turns map[string]*turnLock // conversations with a turn in progress
+}
+
+// turnLock is held by the turn in progress of a conversation; waiting counts the
+// turns queued for it
+type turnLock struct {
+	held    chan struct{}
+	waiting int
+}
+
+// NewStore opens the store at CONVERSATION_STORE_DIR, removing expired
+// conversations, or returns nil when CONVERSATION_STORE_DIR is empty
+func NewStore(cfg *config.Config) *Store {
+	if cfg.ConversationStoreDir == "" {
+		return nil
+	}
+	s := &Store{
+		dir:   cfg.ConversationStoreDir,
+		ttl:   time.Duration(cfg.ConversationStoreTTL * float64(time.Second)),
+		now:   time.Now,
+		turns: make(map[string]*turnLock),
+	}
+	s.mu.Lock()
+	s.sweep()
+	s.mu.Unlock()
+	return s
+}
+
+// validID accepts the IDs used as file names: letters, digits and dashes, such
+// as the UUIDs sent to Kiro
+func validID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	return strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-") == ""
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+fileExt)
+}
+
+// expired reports whether a conversation last updated at updatedAt has expired
+func (s *Store) expired(updatedAt time.Time) bool {
+	return s.ttl > 0 && s.now().Sub(updatedAt) > s.ttl
+}
+
+// Load returns the messages of conversation id, or nil if it is unknown or expired
+func (s *Store) Load(id string) []converter.UnifiedMessage {
+	if s == nil || !validID(id) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conv, err := s.read(id)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("Failed to read conversation %s: %v", id, err)
+		}
+		return nil
+	}
+	if s.expired(conv.UpdatedAt) {
+		s.remove(id)
+		return nil
+	}
+	return conv.Messages
+}
+
+// read loads a conversation file. Caller must hold s.mu.
+func (s *Store) read(id string) (*conversation, error) {
+	data, err := os.ReadFile(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	conv := &conversation{}
+	if err := json.Unmarshal(data, conv); err != nil {
+		return nil, err
+	}
+	return conv, nil
+}
+
+// Save replaces the messages of conversation id, writing its file atomically via
+// a temp file
+func (s *Store) Save(id string, messages []converter.UnifiedMessage) error {
+	if s == nil || !validID(id) {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	data, err := json.Marshal(conversation{ID: id, UpdatedAt: s.now().UTC(), Messages: messages})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return err
+	}
+	tmp := s.path(id) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(id)); err != nil {
+		return err
+	}
+
+	if s.now().Sub(s.lastSweep) > sweepInterval {
+		s.sweep()
+	}
+	return nil
+}
+
+// Lock waits until no other turn of conversation id is in progress, then returns
+// the function ending this one. A turn holds the lock from Load to Save. It
+// gives up with ctx's error when ctx is done first.
+func (s *Store) Lock(ctx context.Context, id string) (func(), error) {
+	if s == nil {
+		return func() {}, nil
+	}
+	s.mu.Lock()
+	lock, ok := s.turns[id]
+	if !ok {
+		lock = &turnLock{held: make(chan struct{}, 1)}
+		s.turns[id] = lock
+	}
+	lock.waiting++
+	s.mu.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+	case <-ctx.Done():
+		s.leave(id, lock)
+		return nil, ctx.Err()
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-lock.held
+			s.leave(id, lock)
+		})
+	}, nil
+}
+
+// leave drops a turn from the queue of conversation id, forgetting the lock
+// once no turn needs it
+func (s *Store) leave(id string, lock *turnLock) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	lock.waiting--
+	if lock.waiting == 0 {
+		delete(s.turns, id)
+	}
+}
+
+// Delete removes conversation id, reporting whether it existed
+func (s *Store) Delete(id string) (bool, error) {
+	if s == nil || !validID(id) {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	err := os.Remove(s.path(id))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// remove deletes a conversation file, logging failures. Caller must hold s.mu.
+func (s *Store) remove(id string) {
+	if err := os.Remove(s.path(id)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		log.Warnf("Failed to remove conversation %s: %v", id, err)
+	}
+}
+
+// sweep removes expired conversations. Caller must hold s.mu.
+func (s *Store) sweep() {
+	s.lastSweep = s.now()
+	if s.ttl <= 0 {
+		return
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Warnf("Failed to read conversation store %s: %v", s.dir, err)
+		}
+		return
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), fileExt)
+		if entry.IsDir() || !ok || !validID(id) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !s.expired(info.ModTime()) {
+			continue
+		}
+		s.remove(id)
+	}
+}
+
+// Turn collects the messages and reply of one request to a stored
+// conversation. A nil *Turn is valid and ignores all calls.
+type Turn struct {
+	id       string
+	history  []converter.UnifiedMessage
+	messages []converter.UnifiedMessage
+	reply    *converter.UnifiedMessage
+	unlock   func() // ends the conversation's turn, from Store.Lock
+	mu       sync.Mutex
+}
+
+// NewTurn creates an empty turn
+func NewTurn() *Turn {
+	return &Turn{}
+}
+
+type contextKey struct{}
+
+// WithTurn returns a context carrying the turn
+func WithTurn(ctx context.Context, t *Turn) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the turn stored in ctx, or nil
+func FromContext(ctx context.Context) *Turn {
+	t, _ := ctx.Value(contextKey{}).(*Turn)
+	return t
+}
+
+// SetHistory records the conversation, the stored history its messages follow
+// and the function ending the turn, called by Release
+func (t *Turn) SetHistory(id string, history []converter.UnifiedMessage, unlock func()) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.id = id
+	t.history = history
+	t.unlock = unlock
+}
+
+// Release ends the turn, letting the next turn of the conversation load it. It
+// is called once the turn is saved or has failed.
+func (t *Turn) Release() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	unlock := t.unlock
+	t.unlock = nil
+	t.mu.Unlock()
+	if unlock != nil {
+		unlock()
+	}
+}
+
+// History returns the history recorded by SetHistory, and whether it was called.
+// Requests that send several Kiro requests, such as agentic ones, load the
+// history once.
+func (t *Turn) History() ([]converter.UnifiedMessage, bool) {
+	if t == nil {
+		return nil, false
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.history, t.id != ""
+}
+
+// SetMessages records the messages sent to Kiro, stored history included
+func (t *Turn) SetMessages(messages []converter.UnifiedMessage) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.messages = messages
+}
+
+// SetReply records the assistant's reply. With several replies, as with n > 1
+// or retries, the last one is kept.
+func (t *Turn) SetReply(content string, toolCalls []parser.ToolCall) {
+	if t == nil {
+		return
+	}
+	reply := &converter.UnifiedMessage{Role: "assistant", Content: content}
+	for _, call := range toolCalls {
+		tc := converter.ToolCall{ID: call.ID, Type: "function"}
+		tc.Function.Name = call.Function.Name
+		tc.Function.Arguments = call.Function.Arguments
+		reply.ToolCalls = append(reply.ToolCalls, tc)
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.reply = reply
+}
+
+// Result returns the conversation ID and its messages with the reply, or ""
+// if the turn did not complete
+func (t *Turn) Result() (string, []converter.UnifiedMessage) {
+	if t == nil {
+		return "", nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.id == "" || t.reply == nil {
+		return "", nil
+	}
+	messages := append(append([]converter.UnifiedMessage(nil), t.messages...), *t.reply)
+	return t.id, messages
+}
@@ -0,0 +1,219 @@
+// Package convstore provides tests for the server-side conversation store.
+package convstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testID = "5b1e7c2a-8f43-4d6e-9a0b-3c7d2e1f4a96"
+
+// newTestStore creates a store in a temp dir with a controllable clock
+func newTestStore(t *testing.T, ttl float64) (*Store, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	s := NewStore(&config.Config{ConversationStoreDir: t.TempDir(), ConversationStoreTTL: ttl})
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+func userMessage(text string) converter.UnifiedMessage {
+	return converter.UnifiedMessage{Role: "user", Content: text}
+}
+
+// =============================================================================
+// TestStore
+// =============================================================================
+
+func TestStore(t *testing.T) {
+	t.Run("disabled without a directory", func(t *testing.T) {
+		s := NewStore(&config.Config{})
+
+		assert.Nil(t, s)
+		assert.NoError(t, s.Save(testID, []converter.UnifiedMessage{userMessage("Hi")}))
+		assert.Nil(t, s.Load(testID))
+	})
+
+	t.Run("saves and loads messages", func(t *testing.T) {
+		s, _ := newTestStore(t, 3600)
+		messages := []converter.UnifiedMessage{userMessage("Hi"), {Role: "assistant", Content: "Hello!"}}
+
+		assert.NoError(t, s.Save(testID, messages))
+		loaded := s.Load(testID)
+
+		assert.Len(t, loaded, 2)
+		assert.Equal(t, "assistant", loaded[1].Role)
+		assert.Equal(t, "Hello!", loaded[1].Content)
+		assert.Nil(t, s.Load("00000000-0000-0000-0000-000000000000"))
+	})
+
+	t.Run("expires conversations after the TTL", func(t *testing.T) {
+		s, now := newTestStore(t, 3600)
+		assert.NoError(t, s.Save(testID, []converter.UnifiedMessage{userMessage("Hi")}))
+
+		*now = now.Add(time.Hour - time.Second)
+		assert.Len(t, s.Load(testID), 1)
+
+		*now = now.Add(2 * time.Second)
+		assert.Nil(t, s.Load(testID))
+		_, err := os.Stat(s.path(testID))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("sweeps expired conversations on open", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, testID+fileExt)
+		assert.NoError(t, os.WriteFile(path, []byte(`{"messages":[]}`), 0600))
+		old := time.Now().Add(-2 * time.Hour)
+		assert.NoError(t, os.Chtimes(path, old, old))
+
+		NewStore(&config.Config{ConversationStoreDir: dir, ConversationStoreTTL: 3600})
+
+		_, err := os.Stat(path)
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("deletes conversations", func(t *testing.T) {
+		s, _ := newTestStore(t, 3600)
+		assert.NoError(t, s.Save(testID, []converter.UnifiedMessage{userMessage("Hi")}))
+
+		deleted, err := s.Delete(testID)
+		assert.NoError(t, err)
+		assert.True(t, deleted)
+
+		deleted, err = s.Delete(testID)
+		assert.NoError(t, err)
+		assert.False(t, deleted)
+		assert.Nil(t, s.Load(testID))
+	})
+
+	t.Run("rejects IDs that are not file names", func(t *testing.T) {
+		s, _ := newTestStore(t, 3600)
+
+		assert.NoError(t, s.Save("../escape", []converter.UnifiedMessage{userMessage("Hi")}))
+		_, err := os.Stat(filepath.Join(filepath.Dir(s.dir), "escape"+fileExt))
+		assert.True(t, os.IsNotExist(err))
+		assert.Nil(t, s.Load("../escape"))
+	})
+}
+
+// =============================================================================
+// TestLock
+// =============================================================================
+
+func TestLock(t *testing.T) {
+	t.Run("turns of a conversation take turns", func(t *testing.T) {
+		s, _ := newTestStore(t, 3600)
+		unlock, err := s.Lock(context.Background(), testID)
+		assert.NoError(t, err)
+
+		locked := make(chan struct{})
+		go func() {
+			next, err := s.Lock(context.Background(), testID)
+			assert.NoError(t, err)
+			close(locked)
+			next()
+		}()
+
+		select {
+		case <-locked:
+			t.Fatal("second turn started while the first was in progress")
+		case <-time.After(20 * time.Millisecond):
+		}
+		unlock()
+		unlock() // ending a turn twice is harmless
+		<-locked
+
+		other, err := s.Lock(context.Background(), "00000000-0000-0000-0000-000000000000")
+		assert.NoError(t, err)
+		other()
+	})
+
+	t.Run("gives up when the context is done", func(t *testing.T) {
+		s, _ := newTestStore(t, 3600)
+		unlock, _ := s.Lock(context.Background(), testID)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := s.Lock(ctx, testID)
+		assert.ErrorIs(t, err, context.Canceled)
+
+		unlock()
+		assert.Empty(t, s.turns)
+	})
+
+	t.Run("turns release the lock once", func(t *testing.T) {
+		s, _ := newTestStore(t, 3600)
+		unlock, _ := s.Lock(context.Background(), testID)
+		turn := NewTurn()
+		turn.SetHistory(testID, nil, unlock)
+
+		turn.Release()
+		turn.Release()
+
+		next, err := s.Lock(context.Background(), testID)
+		assert.NoError(t, err)
+		next()
+		assert.Empty(t, s.turns)
+	})
+}
+
+// =============================================================================
+// TestTurn
+// =============================================================================
+
+func TestTurn(t *testing.T) {
+	t.Run("returns the messages with the last reply", func(t *testing.T) {
+		turn := NewTurn()
+		ctx := WithTurn(context.Background(), turn)
+
+		_, loaded := FromContext(ctx).History()
+		assert.False(t, loaded)
+
+		FromContext(ctx).SetHistory(testID, []converter.UnifiedMessage{userMessage("Hi")}, nil)
+		FromContext(ctx).SetMessages([]converter.UnifiedMessage{userMessage("Hi"), userMessage("Weather?")})
+		FromContext(ctx).SetReply("draft", nil)
+		FromContext(ctx).SetReply("", []parser.ToolCall{{ID: "toolu_1", Function: parser.ToolCallFunction{Name: "get_weather", Arguments: `{}`}}})
+
+		history, loaded := turn.History()
+		assert.True(t, loaded)
+		assert.Len(t, history, 1)
+
+		id, messages := turn.Result()
+		assert.Equal(t, testID, id)
+		assert.Len(t, messages, 3)
+		assert.Equal(t, "assistant", messages[2].Role)
+		assert.Equal(t, "get_weather", messages[2].ToolCalls[0].Function.Name)
+	})
+
+	t.Run("is incomplete without a reply", func(t *testing.T) {
+		turn := NewTurn()
+		turn.SetHistory(testID, nil, nil)
+		turn.SetMessages([]converter.UnifiedMessage{userMessage("Hi")})
+
+		id, messages := turn.Result()
+		assert.Empty(t, id)
+		assert.Nil(t, messages)
+	})
+
+	t.Run("nil turn is a no-op", func(t *testing.T) {
+		turn := FromContext(context.Background())
+
+		turn.SetHistory(testID, nil, nil)
+		turn.SetMessages(nil)
+		turn.SetReply("Hello", nil)
+		turn.Release()
+
+		assert.Nil(t, turn)
+		id, _ := turn.Result()
+		assert.Empty(t, id)
+	})
+}
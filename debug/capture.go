@@ -0,0 +1,266 @@
+// Package debug captures request/response traffic to disk for troubleshooting.
+//
+// Controlled by DEBUG_MODE: "off" disables capture, "errors" keeps only failed
+// requests, "all" keeps every request. Each capture is written to its own
+// directory under DEBUG_DIR, named by timestamp and conversation ID.
+package debug
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/redact"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Debug modes
+const (
+	ModeOff    = "off"
+	ModeErrors = "errors"
+	ModeAll    = "all"
+)
+
+// Capture file names
+const (
+	ClientRequestFile  = "client_request.json"
+	KiroRequestFile    = "kiro_request.json"
+	KiroStreamFile     = "kiro_stream.bin"
+	ClientResponseFile = "client_response.txt"
+	ErrorFile          = "error.txt"
+)
+
+const truncatedMarker = "\n[truncated]\n"
+
+// Redact replaces tokens and secrets in data with a placeholder
+func Redact(data []byte) []byte {
+	return redact.Bytes(data)
+}
+
+// Capture buffers the traffic of a single request and writes it to disk on Finish.
+// A nil *Capture is valid and ignores all calls, so callers need no mode checks.
+type Capture struct {
+	dir            string
+	mode           string
+	maxBytes       int
+	maxCaptures    int
+	started        time.Time
+	conversationID string
+	failure        string
+
+	files map[string]*bytes.Buffer
+	mu    sync.Mutex
+}
+
+// NewCapture creates a capture for one request, or nil when DEBUG_MODE is off
+func NewCapture(cfg *config.Config) *Capture {
+	if cfg.DebugMode != ModeErrors && cfg.DebugMode != ModeAll {
+		return nil
+	}
+	return &Capture{
+		dir:         cfg.DebugDir,
+		mode:        cfg.DebugMode,
+		maxBytes:    cfg.DebugMaxBytes,
+		maxCaptures: cfg.DebugMaxCaptures,
+		started:     time.Now(),
+		files:       make(map[string]*bytes.Buffer),
+	}
+}
+
+type contextKey struct{}
+
+// WithCapture returns a context carrying the capture
+func WithCapture(ctx context.Context, c *Capture) context.Context {
+	if c == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, c)
+}
+
+// FromContext returns the capture stored in ctx, or nil
+func FromContext(ctx context.Context) *Capture {
+	c, _ := ctx.Value(contextKey{}).(*Capture)
+	return c
+}
+
+// SetConversationID names the capture directory after the conversation
+func (c *Capture) SetConversationID(id string) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.conversationID = id
+}
+
+// MarkError records a failure so the capture is kept in "errors" mode
+func (c *Capture) MarkError(err error) {
+	if c == nil || err == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.failure != "" {
+		c.failure += "\n"
+	}
+	c.failure += err.Error()
+}
+
+// Write appends data to the named capture file, redacting secrets and honoring the size cap
+func (c *Capture) Write(name string, data []byte) {
+	if c == nil {
+		return
+	}
+	c.write(name, Redact(data))
+}
+
+// Set replaces the contents of the named capture file (e.g. on retry)
+func (c *Capture) Set(name string, data []byte) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	delete(c.files, name)
+	c.mu.Unlock()
+	c.write(name, Redact(data))
+}
+
+func (c *Capture) write(name string, data []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	buf, ok := c.files[name]
+	if !ok {
+		buf = &bytes.Buffer{}
+		c.files[name] = buf
+	}
+
+	if c.maxBytes <= 0 {
+		buf.Write(data)
+		return
+	}
+	remaining := c.maxBytes - buf.Len()
+	if remaining <= 0 {
+		return
+	}
+	if len(data) > remaining {
+		buf.Write(data[:remaining])
+		buf.WriteString(truncatedMarker)
+		return
+	}
+	buf.Write(data)
+}
+
+// WrapBody tees everything read from body into the named capture file.
+// Raw stream bytes are not redacted since Kiro never echoes credentials.
+func (c *Capture) WrapBody(name string, body io.ReadCloser) io.ReadCloser {
+	if c == nil {
+		return body
+	}
+	return &teeReadCloser{ReadCloser: body, capture: c, name: name}
+}
+
+type teeReadCloser struct {
+	io.ReadCloser
+	capture *Capture
+	name    string
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		t.capture.write(t.name, p[:n])
+	}
+	return n, err
+}
+
+// Finish writes the capture to disk. In "errors" mode only failed requests
+// (status >= 400 or MarkError called) are kept.
+func (c *Capture) Finish(status int) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	failed := status >= 400 || c.failure != ""
+	if c.mode == ModeErrors && !failed {
+		return
+	}
+
+	name := c.started.Format("20060102-150405.000")
+	if c.conversationID != "" {
+		name += "_" + sanitizeName(c.conversationID)
+	}
+	dir := filepath.Join(c.dir, name)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		log.Warnf("Failed to create debug capture directory: %v", err)
+		return
+	}
+
+	if failed {
+		errorInfo := fmt.Sprintf("status: %d\n", status)
+		if c.failure != "" {
+			errorInfo += c.failure + "\n"
+		}
+		c.files[ErrorFile] = bytes.NewBufferString(errorInfo)
+	}
+
+	for file, buf := range c.files {
+		if err := os.WriteFile(filepath.Join(dir, file), buf.Bytes(), 0600); err != nil {
+			log.Warnf("Failed to write debug capture %s: %v", file, err)
+		}
+	}
+	log.Debugf("Debug capture written to %s", dir)
+
+	prune(c.dir, c.maxCaptures)
+}
+
+// prune removes the oldest capture directories beyond maxCaptures
+func prune(root string, maxCaptures int) {
+	if maxCaptures <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+
+	var dirs []string
+	for _, e := range entries {
+		if e.IsDir() {
+			dirs = append(dirs, e.Name())
+		}
+	}
+	if len(dirs) <= maxCaptures {
+		return
+	}
+
+	// Names start with a timestamp, so lexical order is chronological
+	sort.Strings(dirs)
+	for _, d := range dirs[:len(dirs)-maxCaptures] {
+		if err := os.RemoveAll(filepath.Join(root, d)); err != nil {
+			log.Warnf("Failed to remove old debug capture %s: %v", d, err)
+		}
+	}
+}
+
+func sanitizeName(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, s)
+}
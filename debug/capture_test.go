@@ -0,0 +1,163 @@
+// Package debug provides tests for debug capture.
+package debug
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+func newTestConfig(dir, mode string) *config.Config {
+	return &config.Config{
+		DebugMode:        mode,
+		DebugDir:         dir,
+		DebugMaxBytes:    1024,
+		DebugMaxCaptures: 10,
+	}
+}
+
+// readCapture returns the files of the only capture directory under dir
+func readCapture(t *testing.T, dir string) map[string]string {
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	files := make(map[string]string)
+	captureDir := filepath.Join(dir, entries[0].Name())
+	fileEntries, _ := os.ReadDir(captureDir)
+	for _, f := range fileEntries {
+		data, _ := os.ReadFile(filepath.Join(captureDir, f.Name()))
+		files[f.Name()] = string(data)
+	}
+	return files
+}
+
+// =============================================================================
+// TestRedact
+// =============================================================================
+
+func TestRedact(t *testing.T) {
+	t.Run("redacts JSON credentials", func(t *testing.T) {
+		data := `{"accessToken": "secret-1", "refreshToken":"secret-2", "content": "hello"}`
+
+		result := string(Redact([]byte(data)))
+
+		assert.NotContains(t, result, "secret-1")
+		assert.NotContains(t, result, "secret-2")
+		assert.Contains(t, result, `"accessToken": "[REDACTED]"`)
+		assert.Contains(t, result, "hello")
+	})
+
+	t.Run("redacts bearer tokens", func(t *testing.T) {
+		result := string(Redact([]byte("Authorization: Bearer abc.def-123")))
+
+		assert.Equal(t, "Authorization: Bearer [REDACTED]", result)
+	})
+}
+
+// =============================================================================
+// TestCapture
+// =============================================================================
+
+func TestCapture(t *testing.T) {
+	t.Run("disabled when mode is off", func(t *testing.T) {
+		c := NewCapture(newTestConfig(t.TempDir(), ModeOff))
+
+		assert.Nil(t, c)
+
+		// Nil capture methods are no-ops
+		c.Write(ClientRequestFile, []byte("x"))
+		c.SetConversationID("conv")
+		c.MarkError(errors.New("boom"))
+		c.Finish(500)
+		body := io.NopCloser(strings.NewReader("data"))
+		assert.Equal(t, body, c.WrapBody(KiroStreamFile, body))
+	})
+
+	t.Run("writes all files in all mode", func(t *testing.T) {
+		dir := t.TempDir()
+		c := NewCapture(newTestConfig(dir, ModeAll))
+		c.SetConversationID("conv-123")
+
+		c.Write(ClientRequestFile, []byte(`{"api_key": "k"}`))
+		c.Set(KiroRequestFile, []byte(`{"attempt": 1}`))
+		c.Set(KiroRequestFile, []byte(`{"attempt": 2}`))
+		body, _ := io.ReadAll(c.WrapBody(KiroStreamFile, io.NopCloser(strings.NewReader("raw bytes"))))
+		c.Write(ClientResponseFile, []byte("data: hi\n\n"))
+		c.Finish(200)
+
+		assert.Equal(t, "raw bytes", string(body))
+		files := readCapture(t, dir)
+		assert.Equal(t, `{"api_key": "[REDACTED]"}`, files[ClientRequestFile])
+		assert.Equal(t, `{"attempt": 2}`, files[KiroRequestFile])
+		assert.Equal(t, "raw bytes", files[KiroStreamFile])
+		assert.Equal(t, "data: hi\n\n", files[ClientResponseFile])
+		assert.NotContains(t, files, ErrorFile)
+
+		entries, _ := os.ReadDir(dir)
+		assert.True(t, strings.HasSuffix(entries[0].Name(), "_conv-123"))
+	})
+
+	t.Run("skips successful requests in errors mode", func(t *testing.T) {
+		dir := t.TempDir()
+		c := NewCapture(newTestConfig(dir, ModeErrors))
+		c.Write(ClientRequestFile, []byte("{}"))
+		c.Finish(200)
+
+		entries, _ := os.ReadDir(dir)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("keeps failed requests in errors mode", func(t *testing.T) {
+		dir := t.TempDir()
+		c := NewCapture(newTestConfig(dir, ModeErrors))
+		c.MarkError(errors.New("stream broke"))
+		c.Finish(200)
+
+		files := readCapture(t, dir)
+		assert.Contains(t, files[ErrorFile], "stream broke")
+	})
+
+	t.Run("caps file size", func(t *testing.T) {
+		dir := t.TempDir()
+		c := NewCapture(newTestConfig(dir, ModeAll))
+		c.Write(KiroStreamFile, []byte(strings.Repeat("a", 2000)))
+		c.Write(KiroStreamFile, []byte("more"))
+		c.Finish(200)
+
+		files := readCapture(t, dir)
+		assert.Equal(t, strings.Repeat("a", 1024)+truncatedMarker, files[KiroStreamFile])
+	})
+
+	t.Run("round trips through context", func(t *testing.T) {
+		c := NewCapture(newTestConfig(t.TempDir(), ModeAll))
+
+		assert.Same(t, c, FromContext(WithCapture(context.Background(), c)))
+		assert.Nil(t, FromContext(context.Background()))
+	})
+}
+
+// =============================================================================
+// TestPrune
+// =============================================================================
+
+func TestPrune(t *testing.T) {
+	dir := t.TempDir()
+	for i := 0; i < 5; i++ {
+		os.Mkdir(filepath.Join(dir, fmt.Sprintf("20240101-00000%d.000_conv", i)), 0700)
+	}
+
+	prune(dir, 3)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "20240101-000002.000_conv", entries[0].Name())
+}
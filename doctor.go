@@ -0,0 +1,154 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/auth"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/servertls"
+)
+
+// doctorTimeout bounds the Kiro reachability check
+const doctorTimeout = 30 * time.Second
+
+// doctorReport prints check results and counts failures
+type doctorReport struct {
+	failures int
+}
+
+func (r *doctorReport) ok(format string, args ...interface{}) {
+	fmt.Printf("  ✓ %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) warn(format string, args ...interface{}) {
+	fmt.Printf("  ! %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) fail(format string, args ...interface{}) {
+	r.failures++
+	fmt.Printf("  ✗ %s\n", fmt.Sprintf(format, args...))
+}
+
+func (r *doctorReport) section(name string) {
+	fmt.Println()
+	fmt.Println(name)
+}
+
+// runDoctor implements the "doctor" subcommand: it checks the configuration, each
+// account's credentials and whether Kiro answers, and exits 1 if anything failed
+func runDoctor(args []string) {
+	fs := flag.NewFlagSet("doctor", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to a YAML/JSON config file (overrides CONFIG_FILE)")
+	fs.Parse(args)
+
+	cfg := loadCommandConfig(*configFile)
+	report := &doctorReport{}
+
+	report.section("Configuration")
+	checkConfig(report, cfg)
+	if report.failures > 0 {
+		fmt.Println()
+		os.Exit(1)
+	}
+
+	server := newCommandServer(cfg)
+
+	report.section("Credentials")
+	statuses := server.CredentialPool.Status()
+	for i, manager := range server.CredentialPool.Managers() {
+		name := statuses[i].Name
+		if _, err := manager.GetAccessToken(); err != nil {
+			report.fail("%s (%s): %v", name, statuses[i].AuthType, err)
+			continue
+		}
+		report.ok("%s (%s): token valid until %s", name, statuses[i].AuthType, manager.ExpiresAt().Local().Format("2006-01-02 15:04:05"))
+	}
+
+	report.section("Kiro API")
+	ctx, cancel := context.WithTimeout(context.Background(), doctorTimeout)
+	defer cancel()
+	start := time.Now()
+	models, err := server.HttpClient.ListModels(ctx)
+	if err != nil {
+		report.fail("ListAvailableModels at %s: %v", server.AuthManager.QHost(), err)
+	} else {
+		report.ok("ListAvailableModels at %s: %d models in %s", server.AuthManager.QHost(), len(models), time.Since(start).Round(time.Millisecond))
+	}
+
+	fmt.Println()
+	if report.failures > 0 {
+		fmt.Printf("  %d check(s) failed\n\n", report.failures)
+		os.Exit(1)
+	}
+	fmt.Println("  All checks passed")
+	fmt.Println()
+}
+
+// checkConfig reports configuration problems that would stop or weaken the server
+func checkConfig(report *doctorReport, cfg *config.Config) {
+	if err := cfg.Validate(); err != nil {
+		report.fail("%v", err)
+		return
+	}
+	report.ok("configuration is valid")
+
+	// Credential sources that do not exist only produce warnings at startup
+	var files []string
+	for _, file := range append([]string{cfg.KiroCredsFile, cfg.KiroCLIDBFile}, append(cfg.KiroCredsFiles, cfg.KiroCLIDBFiles...)...) {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	if cfg.AWSSSOProfile != "" {
+		if file, err := auth.AWSSSOCacheFile(cfg.AWSSSOProfile); err != nil {
+			report.fail("AWS profile %s: %v", cfg.AWSSSOProfile, err)
+		} else {
+			files = append(files, file)
+		}
+	}
+	for _, file := range files {
+		if _, err := os.Stat(expandHome(file)); err != nil {
+			report.fail("credentials source %s: %v", file, err)
+		} else {
+			report.ok("credentials source %s exists", file)
+		}
+	}
+
+	if _, err := servertls.Load(cfg); err != nil {
+		report.fail("TLS: %v", err)
+	} else if cfg.TLSEnabled() {
+		report.ok("TLS listener configured")
+	}
+
+	if proxyURL, _ := cfg.GetVPNProxyURL(); proxyURL != nil {
+		report.ok("outbound proxy %s", proxyURL.Redacted())
+	}
+
+	if cfg.ProxyAPIKey == config.DefaultProxyAPIKey {
+		report.warn("PROXY_API_KEY is the default value; set your own before exposing the server")
+	}
+	for _, setting := range cfg.UnknownAPIKeys() {
+		report.warn("%s has entries for keys not in PROXY_API_KEY or PROXY_API_KEYS; they never apply", setting)
+	}
+	if cfg.DebugMode == "all" {
+		report.warn("DEBUG_MODE=all captures every request to %s", cfg.DebugDir)
+	}
+	if cfg.FixtureRecordDir != "" {
+		report.warn("FIXTURE_RECORD_DIR records every Kiro exchange to %s", cfg.FixtureRecordDir)
+	}
+}
+
+// expandHome expands a leading ~ like the credential loaders do
+func expandHome(path string) string {
+	if strings.HasPrefix(path, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			return home + path[1:]
+		}
+	}
+	return path
+}
@@ -0,0 +1,58 @@
+// Package embeddings computes vectors for the /v1/embeddings endpoint.
+//
+// Kiro has no embeddings API, so the endpoint is served by one of two
+// backends selected with EMBEDDINGS_BACKEND: "proxy" forwards requests to an
+// OpenAI-compatible provider (EMBEDDINGS_URL, EMBEDDINGS_API_KEY), "lexical"
+// computes hashed bag-of-words vectors in process without any network calls.
+// The lexical backend is not a language model: vectors are close when texts
+// share words, not when they mean the same thing.
+package embeddings
+
+import (
+	"context"
+	"fmt"
+
+	"kiro-go-proxy/config"
+)
+
+// Backend names accepted by EMBEDDINGS_BACKEND
+const (
+	BackendProxy   = "proxy"
+	BackendLexical = "lexical"
+)
+
+// Result holds one vector per input, in input order
+type Result struct {
+	Vectors      [][]float32
+	Model        string
+	PromptTokens int
+}
+
+// Backend computes embeddings for a batch of inputs. dimensions is the requested
+// vector size, or 0 for the backend's default.
+type Backend interface {
+	Embed(ctx context.Context, model string, inputs []string, dimensions int) (*Result, error)
+}
+
+// UpstreamError is returned by the proxy backend when the provider answers with
+// a non-2xx status
+type UpstreamError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *UpstreamError) Error() string {
+	return fmt.Sprintf("embeddings provider returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// NewBackend creates the backend selected by EMBEDDINGS_BACKEND, or returns nil
+// when embeddings are disabled
+func NewBackend(cfg *config.Config) Backend {
+	switch cfg.EmbeddingsBackend {
+	case BackendProxy:
+		return newProxyBackend(cfg)
+	case BackendLexical:
+		return newLexicalBackend(cfg)
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+// Package embeddings provides tests for the embeddings backends.
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// cosine returns the cosine similarity of two normalized vectors
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return dot
+}
+
+// =============================================================================
+// TestNewBackend
+// =============================================================================
+
+func TestNewBackend(t *testing.T) {
+	t.Run("disabled by default", func(t *testing.T) {
+		assert.Nil(t, NewBackend(&config.Config{}))
+	})
+
+	t.Run("selects backend", func(t *testing.T) {
+		assert.IsType(t, &lexicalBackend{}, NewBackend(&config.Config{EmbeddingsBackend: BackendLexical}))
+		assert.IsType(t, &proxyBackend{}, NewBackend(&config.Config{EmbeddingsBackend: BackendProxy, EmbeddingsURL: "http://localhost"}))
+	})
+
+	t.Run("proxy URL gets /embeddings once", func(t *testing.T) {
+		assert.Equal(t, "https://api.openai.com/v1/embeddings", newProxyBackend(&config.Config{EmbeddingsURL: "https://api.openai.com/v1/"}).url)
+		assert.Equal(t, "https://api.openai.com/v1/embeddings", newProxyBackend(&config.Config{EmbeddingsURL: "https://api.openai.com/v1/embeddings"}).url)
+	})
+}
+
+// =============================================================================
+// TestLexicalBackend
+// =============================================================================
+
+func TestLexicalBackend(t *testing.T) {
+	backend := newLexicalBackend(&config.Config{EmbeddingsDimensions: 256})
+
+	t.Run("normalized vectors of configured size", func(t *testing.T) {
+		result, err := backend.Embed(context.Background(), "", []string{"hello world", "other text"}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, LexicalModel, result.Model)
+		assert.Len(t, result.Vectors, 2)
+		assert.Len(t, result.Vectors[0], 256)
+		assert.InDelta(t, 1.0, cosine(result.Vectors[0], result.Vectors[0]), 1e-5)
+		assert.Greater(t, result.PromptTokens, 0)
+	})
+
+	t.Run("deterministic and case insensitive", func(t *testing.T) {
+		result, err := backend.Embed(context.Background(), "m", []string{"The quick brown fox", "the QUICK brown fox!"}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "m", result.Model)
+		assert.Equal(t, result.Vectors[0], result.Vectors[1])
+	})
+
+	t.Run("shared words are more similar", func(t *testing.T) {
+		result, _ := backend.Embed(context.Background(), "", []string{
+			"refresh the access token before it expires",
+			"the access token expires soon, refresh it",
+			"bananas are yellow fruit",
+		}, 0)
+		assert.Greater(t, cosine(result.Vectors[0], result.Vectors[1]), cosine(result.Vectors[0], result.Vectors[2]))
+	})
+
+	t.Run("requested dimensions", func(t *testing.T) {
+		result, err := backend.Embed(context.Background(), "", []string{"text"}, 64)
+		assert.NoError(t, err)
+		assert.Len(t, result.Vectors[0], 64)
+
+		_, err = backend.Embed(context.Background(), "", []string{"text"}, maxLexicalDimensions+1)
+		assert.Error(t, err)
+	})
+
+	t.Run("text without words is a zero vector", func(t *testing.T) {
+		result, _ := backend.Embed(context.Background(), "", []string{"..."}, 8)
+		assert.Equal(t, make([]float32, 8), result.Vectors[0])
+		assert.False(t, math.IsNaN(float64(result.Vectors[0][0])))
+	})
+}
+
+// =============================================================================
+// TestProxyBackend
+// =============================================================================
+
+func TestProxyBackend(t *testing.T) {
+	t.Run("forwards request and orders vectors by index", func(t *testing.T) {
+		var got proxyRequest
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, "/v1/embeddings", r.URL.Path)
+			assert.Equal(t, "Bearer provider-key", r.Header.Get("Authorization"))
+			json.NewDecoder(r.Body).Decode(&got)
+			w.Write([]byte(`{"object":"list","model":"text-embedding-3-small","data":[
+				{"object":"embedding","index":1,"embedding":[0.3,0.4]},
+				{"object":"embedding","index":0,"embedding":[0.1,0.2]}],
+				"usage":{"prompt_tokens":7,"total_tokens":7}}`))
+		}))
+		defer upstream.Close()
+
+		backend := NewBackend(&config.Config{EmbeddingsBackend: BackendProxy, EmbeddingsURL: upstream.URL + "/v1", EmbeddingsAPIKey: "provider-key", EmbeddingsTimeout: 5})
+		result, err := backend.Embed(context.Background(), "text-embedding-3-small", []string{"a", "b"}, 2)
+		assert.NoError(t, err)
+		assert.Equal(t, proxyRequest{Model: "text-embedding-3-small", Input: []string{"a", "b"}, Dimensions: 2}, got)
+		assert.Equal(t, [][]float32{{0.1, 0.2}, {0.3, 0.4}}, result.Vectors)
+		assert.Equal(t, 7, result.PromptTokens)
+		assert.Equal(t, "text-embedding-3-small", result.Model)
+	})
+
+	t.Run("configured model overrides client model", func(t *testing.T) {
+		var got proxyRequest
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewDecoder(r.Body).Decode(&got)
+			w.Write([]byte(`{"data":[{"index":0,"embedding":[1]}]}`))
+		}))
+		defer upstream.Close()
+
+		backend := NewBackend(&config.Config{EmbeddingsBackend: BackendProxy, EmbeddingsURL: upstream.URL, EmbeddingsModel: "nomic-embed-text", EmbeddingsTimeout: 5})
+		result, err := backend.Embed(context.Background(), "text-embedding-ada-002", []string{"a"}, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, "nomic-embed-text", got.Model)
+		assert.Equal(t, "nomic-embed-text", result.Model)
+	})
+
+	t.Run("provider error status", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"bad model"}}`))
+		}))
+		defer upstream.Close()
+
+		backend := NewBackend(&config.Config{EmbeddingsBackend: BackendProxy, EmbeddingsURL: upstream.URL, EmbeddingsTimeout: 5})
+		_, err := backend.Embed(context.Background(), "x", []string{"a"}, 0)
+		var upstreamErr *UpstreamError
+		assert.ErrorAs(t, err, &upstreamErr)
+		assert.Equal(t, http.StatusBadRequest, upstreamErr.StatusCode)
+		assert.Contains(t, upstreamErr.Body, "bad model")
+	})
+
+	t.Run("missing vector", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"data":[{"index":0,"embedding":[1]}]}`))
+		}))
+		defer upstream.Close()
+
+		backend := NewBackend(&config.Config{EmbeddingsBackend: BackendProxy, EmbeddingsURL: upstream.URL, EmbeddingsTimeout: 5})
+		_, err := backend.Embed(context.Background(), "x", []string{"a", "b"}, 0)
+		assert.ErrorContains(t, err, "no vector for input 1")
+	})
+}
@@ -0,0 +1,95 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/tokens"
+)
+
+// LexicalModel is reported when the client does not name a model
+const LexicalModel = "lexical-hash"
+
+// maxLexicalDimensions bounds the vector size a client can request
+const maxLexicalDimensions = 4096
+
+// bigramWeight is the weight of adjacent word pairs relative to single words
+const bigramWeight = 0.5
+
+// lexicalBackend embeds text by feature hashing: every word and adjacent word pair
+// is hashed to a signed bucket and the vector is L2-normalized. Similarity is
+// lexical (shared words), not semantic, but it needs no model download or
+// network access and the same text always maps to the same vector.
+type lexicalBackend struct {
+	dimensions int
+}
+
+func newLexicalBackend(cfg *config.Config) *lexicalBackend {
+	return &lexicalBackend{dimensions: cfg.EmbeddingsDimensions}
+}
+
+// Embed hashes each input into a vector of the requested or configured size
+func (b *lexicalBackend) Embed(ctx context.Context, model string, inputs []string, dimensions int) (*Result, error) {
+	if dimensions <= 0 {
+		dimensions = b.dimensions
+	}
+	if dimensions <= 0 || dimensions > maxLexicalDimensions {
+		return nil, fmt.Errorf("dimensions must be between 1 and %d", maxLexicalDimensions)
+	}
+	if model == "" {
+		model = LexicalModel
+	}
+
+	result := &Result{Vectors: make([][]float32, len(inputs)), Model: model}
+	for i, input := range inputs {
+		result.Vectors[i] = hashVector(input, dimensions)
+		result.PromptTokens += tokens.Count(input)
+	}
+	return result, nil
+}
+
+// hashVector returns the normalized feature hash of text's words and word pairs
+func hashVector(text string, dimensions int) []float32 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	sums := make([]float64, dimensions)
+	for i, word := range words {
+		addFeature(sums, word, 1)
+		if i > 0 {
+			addFeature(sums, words[i-1]+" "+word, bigramWeight)
+		}
+	}
+
+	var norm float64
+	for _, v := range sums {
+		norm += v * v
+	}
+	vector := make([]float32, dimensions)
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i, v := range sums {
+		vector[i] = float32(v / norm)
+	}
+	return vector
+}
+
+// addFeature adds weight to the bucket of feature; the top hash bit picks the
+// sign so that collisions tend to cancel out instead of accumulating
+func addFeature(sums []float64, feature string, weight float64) {
+	h := fnv.New64a()
+	h.Write([]byte(feature))
+	sum := h.Sum64()
+	if sum>>63 == 1 {
+		weight = -weight
+	}
+	sums[sum%uint64(len(sums))] += weight
+}
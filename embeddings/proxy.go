@@ -0,0 +1,116 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/transport"
+)
+
+// maxErrorBody caps how much of a provider error response is kept
+const maxErrorBody = 4096
+
+// proxyBackend forwards requests to an OpenAI-compatible embeddings API
+type proxyBackend struct {
+	url    string
+	apiKey string
+	model  string
+	client *http.Client
+}
+
+func newProxyBackend(cfg *config.Config) *proxyBackend {
+	// EMBEDDINGS_URL is a base URL like https://api.openai.com/v1; a full
+	// .../embeddings URL is accepted as well
+	url := strings.TrimSuffix(cfg.EmbeddingsURL, "/")
+	if !strings.HasSuffix(url, "/embeddings") {
+		url += "/embeddings"
+	}
+	return &proxyBackend{
+		url:    url,
+		apiKey: cfg.EmbeddingsAPIKey,
+		model:  cfg.EmbeddingsModel,
+		client: transport.NewClient(cfg, time.Duration(cfg.EmbeddingsTimeout*float64(time.Second))),
+	}
+}
+
+// proxyRequest is the upstream request; vectors are always requested as floats
+// and re-encoded for the client if it asked for base64
+type proxyRequest struct {
+	Model      string   `json:"model"`
+	Input      []string `json:"input"`
+	Dimensions int      `json:"dimensions,omitempty"`
+}
+
+type proxyResponse struct {
+	Data []struct {
+		Index     int       `json:"index"`
+		Embedding []float32 `json:"embedding"`
+	} `json:"data"`
+	Model string `json:"model"`
+	Usage struct {
+		PromptTokens int `json:"prompt_tokens"`
+	} `json:"usage"`
+}
+
+// Embed sends the inputs to the provider. EMBEDDINGS_MODEL, when set, replaces
+// the model named by the client.
+func (b *proxyBackend) Embed(ctx context.Context, model string, inputs []string, dimensions int) (*Result, error) {
+	if b.model != "" {
+		model = b.model
+	}
+	body, err := json.Marshal(proxyRequest{Model: model, Input: inputs, Dimensions: dimensions})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, b.url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if b.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+b.apiKey)
+	}
+
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("embeddings provider request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		errBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+		return nil, &UpstreamError{StatusCode: resp.StatusCode, Body: string(errBody)}
+	}
+
+	var parsed proxyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&parsed); err != nil {
+		return nil, fmt.Errorf("invalid embeddings provider response: %w", err)
+	}
+
+	// Providers return data with an index; place vectors by it rather than by position
+	vectors := make([][]float32, len(inputs))
+	for _, item := range parsed.Data {
+		if item.Index < 0 || item.Index >= len(inputs) {
+			return nil, fmt.Errorf("embeddings provider returned index %d for %d inputs", item.Index, len(inputs))
+		}
+		vectors[item.Index] = item.Embedding
+	}
+	for i, vector := range vectors {
+		if vector == nil {
+			return nil, fmt.Errorf("embeddings provider returned no vector for input %d", i)
+		}
+	}
+
+	if parsed.Model != "" {
+		model = parsed.Model
+	}
+	return &Result{Vectors: vectors, Model: model, PromptTokens: parsed.Usage.PromptTokens}, nil
+}
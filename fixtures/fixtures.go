@@ -0,0 +1,139 @@
+// Package fixtures records Kiro exchanges to disk and replays them offline.
+//
+// With FIXTURE_RECORD_DIR set, every request sent to Kiro is saved to that
+// directory as a pair of files: NAME.json holds the request payload and the
+// response status, NAME.stream holds the raw response body. The model list is
+// saved to models.json. A Replayer serves those files back, so converter and
+// stream regressions can be reproduced in tests and handlers can be developed
+// with the --mock server mode, which needs no Kiro credentials.
+package fixtures
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Fixture file names
+const (
+	MetaExt    = ".json"
+	StreamExt  = ".stream"
+	ModelsFile = "models.json"
+)
+
+// Fixture is one recorded Kiro exchange
+type Fixture struct {
+	// Name is the file name without extension
+	Name       string          `json:"-"`
+	Key        string          `json:"key"`
+	URL        string          `json:"url"`
+	StatusCode int             `json:"status_code"`
+	Request    json.RawMessage `json:"request"`
+	RecordedAt time.Time       `json:"recorded_at"`
+	// Body is the raw response, stored in NAME.stream
+	Body []byte `json:"-"`
+}
+
+// Normalize returns the JSON encoding of a Kiro payload without the fields that
+// differ between otherwise identical requests: the conversation ID and the
+// profile ARN of the account it was sent with
+func Normalize(payload interface{}) (json.RawMessage, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var m map[string]interface{}
+	if err := json.Unmarshal(data, &m); err != nil {
+		// Not an object, nothing to strip
+		return data, nil
+	}
+	delete(m, "profileArn")
+	if state, ok := m["conversationState"].(map[string]interface{}); ok {
+		delete(state, "conversationId")
+	}
+
+	// Map keys are encoded in sorted order, so equal payloads encode identically
+	return json.Marshal(m)
+}
+
+// Key identifies a normalized request
+func Key(request json.RawMessage) string {
+	sum := sha256.Sum256(request)
+	return hex.EncodeToString(sum[:])
+}
+
+// prompt returns the current user message of a normalized request
+func prompt(request json.RawMessage) string {
+	var payload struct {
+		ConversationState struct {
+			CurrentMessage struct {
+				UserInputMessage struct {
+					Content string `json:"content"`
+				} `json:"userInputMessage"`
+			} `json:"currentMessage"`
+		} `json:"conversationState"`
+	}
+	if err := json.Unmarshal(request, &payload); err != nil {
+		return ""
+	}
+	return payload.ConversationState.CurrentMessage.UserInputMessage.Content
+}
+
+// Save writes a fixture to dir, creating the directory if needed
+func Save(dir string, f *Fixture) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	meta, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(dir, f.Name+StreamExt), f.Body, 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, f.Name+MetaExt), meta, 0644)
+}
+
+// Load reads every fixture in dir, oldest first
+func Load(dir string) ([]*Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+MetaExt))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+
+	var fixtures []*Fixture
+	for _, path := range paths {
+		if filepath.Base(path) == ModelsFile {
+			continue
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		f := &Fixture{Name: strings.TrimSuffix(filepath.Base(path), MetaExt)}
+		if err := json.Unmarshal(data, f); err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", path, err)
+		}
+		f.Body, err = os.ReadFile(filepath.Join(dir, f.Name+StreamExt))
+		if err != nil {
+			return nil, fmt.Errorf("fixture %s: %w", path, err)
+		}
+		fixtures = append(fixtures, f)
+	}
+
+	// File names start with the recording time, but keep the order if files were renamed
+	sort.SliceStable(fixtures, func(i, j int) bool {
+		return fixtures[i].RecordedAt.Before(fixtures[j].RecordedAt)
+	})
+	return fixtures, nil
+}
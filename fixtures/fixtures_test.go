@@ -0,0 +1,94 @@
+// Package fixtures provides tests for fixture encoding and storage.
+package fixtures
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testPayload builds a Kiro payload with the given conversation ID and prompt
+func testPayload(conversationID, content string) map[string]interface{} {
+	return map[string]interface{}{
+		"conversationState": map[string]interface{}{
+			"conversationId": conversationID,
+			"currentMessage": map[string]interface{}{
+				"userInputMessage": map[string]interface{}{"content": content, "modelId": "claude-sonnet-4"},
+			},
+		},
+		"profileArn": "arn:aws:codewhisperer:us-east-1:123:profile/" + conversationID,
+	}
+}
+
+// =============================================================================
+// TestNormalize
+// =============================================================================
+
+func TestNormalize(t *testing.T) {
+	t.Run("ignores conversation ID and profile ARN", func(t *testing.T) {
+		a, err := Normalize(testPayload("conv-1", "Hello"))
+		assert.NoError(t, err)
+		b, err := Normalize(testPayload("conv-2", "Hello"))
+		assert.NoError(t, err)
+
+		assert.Equal(t, Key(a), Key(b))
+		assert.NotContains(t, string(a), "conv-1")
+		assert.NotContains(t, string(a), "profileArn")
+	})
+
+	t.Run("different prompts have different keys", func(t *testing.T) {
+		a, _ := Normalize(testPayload("conv", "Hello"))
+		b, _ := Normalize(testPayload("conv", "Goodbye"))
+		assert.NotEqual(t, Key(a), Key(b))
+	})
+
+	t.Run("extracts prompt", func(t *testing.T) {
+		request, _ := Normalize(testPayload("conv", "Hello"))
+		assert.Equal(t, "Hello", prompt(request))
+		assert.Equal(t, "", prompt(json.RawMessage(`[1]`)))
+	})
+}
+
+// =============================================================================
+// TestSaveLoad
+// =============================================================================
+
+func TestSaveLoad(t *testing.T) {
+	t.Run("round trips fixtures oldest first", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "fixtures")
+		start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		assert.NoError(t, Save(dir, &Fixture{Name: "b", Key: "k2", StatusCode: 200, RecordedAt: start.Add(time.Second), Body: []byte("second")}))
+		assert.NoError(t, Save(dir, &Fixture{Name: "a", Key: "k1", StatusCode: 429, RecordedAt: start, Body: []byte("first")}))
+
+		fixtures, err := Load(dir)
+		assert.NoError(t, err)
+		if assert.Len(t, fixtures, 2) {
+			assert.Equal(t, "a", fixtures[0].Name)
+			assert.Equal(t, 429, fixtures[0].StatusCode)
+			assert.Equal(t, "first", string(fixtures[0].Body))
+			assert.Equal(t, "k2", fixtures[1].Key)
+		}
+	})
+
+	t.Run("skips models file", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, ModelsFile), []byte(`[]`), 0644)
+
+		fixtures, err := Load(dir)
+		assert.NoError(t, err)
+		assert.Empty(t, fixtures)
+	})
+
+	t.Run("fails on missing stream file", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "orphan"+MetaExt), []byte(`{"key":"k"}`), 0644)
+
+		_, err := Load(dir)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,120 @@
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/client"
+	"kiro-go-proxy/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Recorder is a client.KiroClient that saves every exchange of the wrapped client as a fixture
+type Recorder struct {
+	next client.KiroClient
+	dir  string
+	now  func() time.Time
+}
+
+var _ client.KiroClient = (*Recorder)(nil)
+
+// NewRecorder wraps next, saving fixtures to dir
+func NewRecorder(next client.KiroClient, dir string) *Recorder {
+	return &Recorder{next: next, dir: dir, now: time.Now}
+}
+
+// PostStream sends the request and saves the response once its body has been read to the end.
+// Responses abandoned part way, such as cancelled streams, are not saved.
+func (r *Recorder) PostStream(ctx context.Context, url string, payload interface{}) (*http.Response, error) {
+	resp, err := r.next.PostStream(ctx, url, payload)
+	if err != nil {
+		return resp, err
+	}
+
+	request, err := Normalize(payload)
+	if err != nil {
+		log.Warnf("Fixture recording skipped, cannot encode request: %v", err)
+		return resp, nil
+	}
+
+	recordedAt := r.now()
+	key := Key(request)
+	f := &Fixture{
+		Name:       recordedAt.Format("20060102-150405.000") + "-" + key[:12],
+		Key:        key,
+		URL:        url,
+		StatusCode: resp.StatusCode,
+		Request:    request,
+		RecordedAt: recordedAt,
+	}
+	resp.Body = &recordingBody{ReadCloser: resp.Body, save: func(body []byte) {
+		f.Body = body
+		if err := Save(r.dir, f); err != nil {
+			log.Warnf("Failed to save fixture %s: %v", f.Name, err)
+			return
+		}
+		log.Debugf("Recorded fixture %s", f.Name)
+	}}
+	return resp, nil
+}
+
+// Get passes the request through without recording it
+func (r *Recorder) Get(ctx context.Context, url string) (*http.Response, error) {
+	return r.next.Get(ctx, url)
+}
+
+// ListModels fetches the model list and saves it to models.json
+func (r *Recorder) ListModels(ctx context.Context) ([]model.Info, error) {
+	models, err := r.next.ListModels(ctx)
+	if err != nil {
+		return models, err
+	}
+
+	data, err := json.MarshalIndent(models, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(r.dir, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(r.dir, ModelsFile), data, 0644)
+	}
+	if err != nil {
+		log.Warnf("Failed to save fixture %s: %v", ModelsFile, err)
+	}
+	return models, nil
+}
+
+// recordingBody copies everything read from a response body and hands it to save
+// when the body is closed after reaching EOF
+type recordingBody struct {
+	io.ReadCloser
+	buf  bytes.Buffer
+	eof  bool
+	save func(body []byte)
+	once sync.Once
+}
+
+func (b *recordingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.buf.Write(p[:n])
+	if err == io.EOF {
+		b.eof = true
+	}
+	return n, err
+}
+
+func (b *recordingBody) Close() error {
+	b.once.Do(func() {
+		if b.eof {
+			b.save(b.buf.Bytes())
+		}
+	})
+	return b.ReadCloser.Close()
+}
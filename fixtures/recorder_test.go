@@ -0,0 +1,98 @@
+// Package fixtures provides tests for fixture recording.
+package fixtures
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/client/clienttest"
+	"kiro-go-proxy/model"
+)
+
+// =============================================================================
+// TestRecorder
+// =============================================================================
+
+func TestRecorder(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("saves response after body is read", func(t *testing.T) {
+		dir := t.TempDir()
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hi"}`))
+		recorder := NewRecorder(fake, dir)
+		recorder.now = func() time.Time { return time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+		resp, err := recorder.PostStream(ctx, "https://kiro/generateAssistantResponse", testPayload("conv", "Hello"))
+		assert.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		assert.Equal(t, `{"content":"Hi"}`, string(body))
+		resp.Body.Close()
+
+		fixtures, err := Load(dir)
+		assert.NoError(t, err)
+		if assert.Len(t, fixtures, 1) {
+			f := fixtures[0]
+			assert.Contains(t, f.Name, "20260102-030405.000-")
+			assert.Equal(t, http.StatusOK, f.StatusCode)
+			assert.Equal(t, "https://kiro/generateAssistantResponse", f.URL)
+			assert.Equal(t, `{"content":"Hi"}`, string(f.Body))
+			assert.Equal(t, "Hello", prompt(f.Request))
+		}
+	})
+
+	t.Run("records error statuses", func(t *testing.T) {
+		dir := t.TempDir()
+		fake := clienttest.NewFake(clienttest.Response{StatusCode: http.StatusBadRequest, Body: "bad"})
+		recorder := NewRecorder(fake, dir)
+
+		resp, _ := recorder.PostStream(ctx, "https://kiro", testPayload("conv", "Hello"))
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		fixtures, _ := Load(dir)
+		if assert.Len(t, fixtures, 1) {
+			assert.Equal(t, http.StatusBadRequest, fixtures[0].StatusCode)
+		}
+	})
+
+	t.Run("skips abandoned responses", func(t *testing.T) {
+		dir := t.TempDir()
+		fake := clienttest.NewFake(clienttest.Stream(`{"content":"Hi"}`))
+		recorder := NewRecorder(fake, dir)
+
+		resp, _ := recorder.PostStream(ctx, "https://kiro", testPayload("conv", "Hello"))
+		resp.Body.Read(make([]byte, 4))
+		resp.Body.Close()
+
+		fixtures, _ := Load(dir)
+		assert.Empty(t, fixtures)
+	})
+
+	t.Run("passes through request errors", func(t *testing.T) {
+		fake := clienttest.NewFake(clienttest.Response{Err: errors.New("connection refused")})
+		recorder := NewRecorder(fake, t.TempDir())
+
+		_, err := recorder.PostStream(ctx, "https://kiro", testPayload("conv", "Hello"))
+		assert.EqualError(t, err, "connection refused")
+	})
+
+	t.Run("saves model list", func(t *testing.T) {
+		dir := t.TempDir()
+		fake := clienttest.NewFake()
+		fake.Models = []model.Info{{ModelID: "claude-sonnet-4"}}
+		recorder := NewRecorder(fake, dir)
+
+		models, err := recorder.ListModels(ctx)
+		assert.NoError(t, err)
+		assert.Len(t, models, 1)
+		_, err = os.Stat(filepath.Join(dir, ModelsFile))
+		assert.NoError(t, err)
+	})
+}
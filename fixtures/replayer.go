@@ -0,0 +1,119 @@
+package fixtures
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"kiro-go-proxy/client"
+	"kiro-go-proxy/model"
+)
+
+// NoMatchReply is the assistant text replayed when no fixture matches a request
+const NoMatchReply = "[mock] No recorded fixture matches this request."
+
+// Replayer is a client.KiroClient that answers requests with recorded fixtures.
+//
+// A request is answered with the latest fixture recorded for an identical
+// request, or else the latest fixture whose current user message is the same.
+// Anything else gets NoMatchReply, so every route works against an empty directory.
+type Replayer struct {
+	fixtures []*Fixture
+	models   []model.Info
+}
+
+var _ client.KiroClient = (*Replayer)(nil)
+
+// NewReplayer loads the fixtures recorded in dir
+func NewReplayer(dir string) (*Replayer, error) {
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	fixtures, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replayer{fixtures: fixtures}
+
+	data, err := os.ReadFile(filepath.Join(dir, ModelsFile))
+	if err == nil {
+		if err := json.Unmarshal(data, &r.models); err != nil {
+			return nil, fmt.Errorf("%s: %w", ModelsFile, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Len returns the number of loaded fixtures
+func (r *Replayer) Len() int {
+	return len(r.fixtures)
+}
+
+// Match returns the fixture that answers payload, or nil
+func (r *Replayer) Match(payload interface{}) *Fixture {
+	request, err := Normalize(payload)
+	if err != nil {
+		return nil
+	}
+
+	key := Key(request)
+	for i := len(r.fixtures) - 1; i >= 0; i-- {
+		if r.fixtures[i].Key == key {
+			return r.fixtures[i]
+		}
+	}
+
+	text := prompt(request)
+	if text == "" {
+		return nil
+	}
+	for i := len(r.fixtures) - 1; i >= 0; i-- {
+		if prompt(r.fixtures[i].Request) == text {
+			return r.fixtures[i]
+		}
+	}
+	return nil
+}
+
+// PostStream replays the fixture matching payload
+func (r *Replayer) PostStream(ctx context.Context, url string, payload interface{}) (*http.Response, error) {
+	f := r.Match(payload)
+	if f == nil {
+		reply, _ := json.Marshal(map[string]string{"content": NoMatchReply})
+		return response(ctx, "POST", http.StatusOK, reply), nil
+	}
+	return response(ctx, "POST", f.StatusCode, f.Body), nil
+}
+
+// Get is not recorded, so it always fails
+func (r *Replayer) Get(ctx context.Context, url string) (*http.Response, error) {
+	return nil, fmt.Errorf("fixtures: GET %s is not recorded", url)
+}
+
+// ListModels returns the recorded model list
+func (r *Replayer) ListModels(ctx context.Context) ([]model.Info, error) {
+	if r.models == nil {
+		return nil, fmt.Errorf("fixtures: no %s recorded", ModelsFile)
+	}
+	return r.models, nil
+}
+
+func response(ctx context.Context, method string, status int, body []byte) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Status:     fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Header:     http.Header{"Content-Type": []string{"application/vnd.amazon.eventstream"}},
+		Body:       io.NopCloser(bytes.NewReader(body)),
+		Request:    (&http.Request{Method: method}).WithContext(ctx),
+	}
+}
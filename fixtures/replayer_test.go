@@ -0,0 +1,111 @@
+// Package fixtures provides tests for fixture replay.
+package fixtures
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// saveTestFixture records body as the response to a request with the given prompt
+func saveTestFixture(t *testing.T, dir, name, content string, status int, body string, recordedAt time.Time) {
+	request, err := Normalize(testPayload("recorded", content))
+	assert.NoError(t, err)
+	assert.NoError(t, Save(dir, &Fixture{
+		Name:       name,
+		Key:        Key(request),
+		StatusCode: status,
+		Request:    request,
+		RecordedAt: recordedAt,
+		Body:       []byte(body),
+	}))
+}
+
+// =============================================================================
+// TestReplayer
+// =============================================================================
+
+func TestReplayer(t *testing.T) {
+	ctx := context.Background()
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	readBody := func(resp *http.Response) string {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	t.Run("replays identical request", func(t *testing.T) {
+		dir := t.TempDir()
+		saveTestFixture(t, dir, "hello", "Hello", http.StatusOK, `{"content":"Hi"}`, start)
+
+		replayer, err := NewReplayer(dir)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, replayer.Len())
+
+		resp, err := replayer.PostStream(ctx, "/generateAssistantResponse", testPayload("new-conv", "Hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, `{"content":"Hi"}`, readBody(resp))
+	})
+
+	t.Run("prefers latest recording", func(t *testing.T) {
+		dir := t.TempDir()
+		saveTestFixture(t, dir, "old", "Hello", http.StatusOK, `{"content":"old"}`, start)
+		saveTestFixture(t, dir, "new", "Hello", http.StatusTooManyRequests, "throttled", start.Add(time.Minute))
+
+		replayer, _ := NewReplayer(dir)
+		resp, _ := replayer.PostStream(ctx, "", testPayload("conv", "Hello"))
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "throttled", readBody(resp))
+	})
+
+	t.Run("falls back to same prompt", func(t *testing.T) {
+		dir := t.TempDir()
+		saveTestFixture(t, dir, "hello", "Hello", http.StatusOK, `{"content":"Hi"}`, start)
+
+		replayer, _ := NewReplayer(dir)
+		payload := testPayload("conv", "Hello")
+		payload["conversationState"].(map[string]interface{})["history"] = []interface{}{"earlier turn"}
+
+		assert.NotNil(t, replayer.Match(payload))
+		assert.Nil(t, replayer.Match(testPayload("conv", "Other")))
+	})
+
+	t.Run("answers unmatched requests with notice", func(t *testing.T) {
+		replayer, err := NewReplayer(t.TempDir())
+		assert.NoError(t, err)
+
+		resp, err := replayer.PostStream(ctx, "", testPayload("conv", "Hello"))
+		assert.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Contains(t, readBody(resp), NoMatchReply)
+	})
+
+	t.Run("loads model list", func(t *testing.T) {
+		dir := t.TempDir()
+		replayer, _ := NewReplayer(dir)
+		_, err := replayer.ListModels(ctx)
+		assert.Error(t, err)
+
+		os.WriteFile(filepath.Join(dir, ModelsFile), []byte(`[{"modelId":"claude-sonnet-4"}]`), 0644)
+		replayer, err = NewReplayer(dir)
+		assert.NoError(t, err)
+		models, err := replayer.ListModels(ctx)
+		assert.NoError(t, err)
+		if assert.Len(t, models, 1) {
+			assert.Equal(t, "claude-sonnet-4", models[0].ModelID)
+		}
+	})
+
+	t.Run("fails on missing directory", func(t *testing.T) {
+		_, err := NewReplayer(filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,179 @@
+// Package idempotency replays the response to a request sent again with the
+// same Idempotency-Key.
+//
+// A client that retries a non-streaming request after a network error cannot
+// tell whether Kiro already answered it. With IDEMPOTENCY_TTL set, the first
+// response for a key is kept and returned to every retry within the TTL, so
+// the retry costs no Kiro credits and an agent does not act twice. A retry
+// that arrives while the first request is still running waits for its result.
+package idempotency
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+)
+
+// ErrKeyReused is returned when a key is sent again with a different request
+var ErrKeyReused = errors.New("idempotency key was already used for a different request")
+
+// Response is a stored response
+type Response struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// entry is a key's request and, once it has completed, its response
+type entry struct {
+	key         string
+	fingerprint string
+	response    *Response
+	done        chan struct{}
+	expires     time.Time
+}
+
+// Store holds the responses of idempotent requests for a fixed TTL, evicting
+// the least recently used once IDEMPOTENCY_MAX_ENTRIES is reached.
+// A nil *Store is valid: every request runs.
+type Store struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+	mu      sync.Mutex
+}
+
+// NewStore creates a store from the IDEMPOTENCY_* settings, or nil when they
+// disable it
+func NewStore(cfg *config.Config) *Store {
+	if cfg.IdempotencyTTL <= 0 || cfg.IdempotencyMaxEntries <= 0 {
+		return nil
+	}
+	return &Store{
+		ttl:        time.Duration(cfg.IdempotencyTTL * float64(time.Second)),
+		maxEntries: cfg.IdempotencyMaxEntries,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Fingerprint identifies the request a key was sent with
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method + " " + path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Begin claims key for a request. It returns the stored response when the key
+// has completed, or nil when the caller runs the request and must then call
+// Complete or Release. While another request holds the key, Begin waits for
+// it. A different fingerprint returns ErrKeyReused.
+func (s *Store) Begin(ctx context.Context, key, fingerprint string) (*Response, error) {
+	if s == nil {
+		return nil, nil
+	}
+	for {
+		s.mu.Lock()
+		el, ok := s.entries[key]
+		if ok && s.expired(el.Value.(*entry)) {
+			s.remove(el)
+			ok = false
+		}
+		if !ok {
+			s.insert(&entry{key: key, fingerprint: fingerprint, done: make(chan struct{})})
+			s.mu.Unlock()
+			return nil, nil
+		}
+		e := el.Value.(*entry)
+		s.order.MoveToFront(el)
+		response := e.response
+		s.mu.Unlock()
+
+		if e.fingerprint != fingerprint {
+			return nil, ErrKeyReused
+		}
+		if response != nil {
+			return response, nil
+		}
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Complete stores the response of the request that holds key
+func (s *Store) Complete(key string, response *Response) {
+	s.finish(key, response)
+}
+
+// Release frees key without storing a response, e.g. after a server error,
+// so a retry runs the request again
+func (s *Store) Release(key string) {
+	s.finish(key, nil)
+}
+
+// Len returns the number of keys, including running and expired ones
+func (s *Store) Len() int {
+	if s == nil {
+		return 0
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.order.Len()
+}
+
+func (s *Store) finish(key string, response *Response) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	el, ok := s.entries[key]
+	if !ok || el.Value.(*entry).response != nil {
+		return
+	}
+	e := el.Value.(*entry)
+	if response == nil {
+		s.remove(el)
+	} else {
+		e.response = response
+		e.expires = s.now().Add(s.ttl)
+	}
+	close(e.done)
+}
+
+// insert adds e, evicting the least recently used completed entries when full.
+// Running requests are never evicted.
+func (s *Store) insert(e *entry) {
+	s.entries[e.key] = s.order.PushFront(e)
+	for el := s.order.Back(); el != nil && s.order.Len() > s.maxEntries; {
+		prev := el.Prev()
+		if el.Value.(*entry).response != nil {
+			s.remove(el)
+		}
+		el = prev
+	}
+}
+
+// expired reports whether e completed more than the TTL ago
+func (s *Store) expired(e *entry) bool {
+	return e.response != nil && !s.now().Before(e.expires)
+}
+
+func (s *Store) remove(el *list.Element) {
+	s.order.Remove(el)
+	delete(s.entries, el.Value.(*entry).key)
+}
@@ -0,0 +1,134 @@
+// Package idempotency provides tests for the idempotency key store.
+package idempotency
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"kiro-go-proxy/config"
+)
+
+// newTestStore creates a store with a controllable clock
+func newTestStore(ttl float64, maxEntries int) (*Store, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	s := NewStore(&config.Config{IdempotencyTTL: ttl, IdempotencyMaxEntries: maxEntries})
+	s.now = func() time.Time { return now }
+	return s, &now
+}
+
+// =============================================================================
+// TestNewStore
+// =============================================================================
+
+func TestNewStore(t *testing.T) {
+	t.Run("disabled when ttl is zero", func(t *testing.T) {
+		assert.Nil(t, NewStore(&config.Config{IdempotencyMaxEntries: 10}))
+	})
+
+	t.Run("nil store runs every request", func(t *testing.T) {
+		var s *Store
+		response, err := s.Begin(context.Background(), "key", "fp")
+		assert.NoError(t, err)
+		assert.Nil(t, response)
+		s.Complete("key", &Response{Status: 200})
+		s.Release("key")
+		assert.Equal(t, 0, s.Len())
+	})
+}
+
+// =============================================================================
+// TestStore
+// =============================================================================
+
+func TestStore(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("replays the completed response", func(t *testing.T) {
+		s, _ := newTestStore(60, 10)
+
+		response, err := s.Begin(ctx, "key", "fp")
+		assert.NoError(t, err)
+		assert.Nil(t, response)
+		s.Complete("key", &Response{Status: 200, Body: []byte("body")})
+
+		response, err = s.Begin(ctx, "key", "fp")
+		assert.NoError(t, err)
+		assert.Equal(t, "body", string(response.Body))
+	})
+
+	t.Run("rejects a key reused for another request", func(t *testing.T) {
+		s, _ := newTestStore(60, 10)
+		s.Begin(ctx, "key", "fp")
+		s.Complete("key", &Response{Status: 200})
+
+		_, err := s.Begin(ctx, "key", "other")
+
+		assert.ErrorIs(t, err, ErrKeyReused)
+	})
+
+	t.Run("released keys run again", func(t *testing.T) {
+		s, _ := newTestStore(60, 10)
+		s.Begin(ctx, "key", "fp")
+		s.Release("key")
+
+		response, err := s.Begin(ctx, "key", "fp")
+
+		assert.NoError(t, err)
+		assert.Nil(t, response)
+	})
+
+	t.Run("expires after the ttl", func(t *testing.T) {
+		s, now := newTestStore(60, 10)
+		s.Begin(ctx, "key", "fp")
+		s.Complete("key", &Response{Status: 200})
+
+		*now = now.Add(61 * time.Second)
+		response, err := s.Begin(ctx, "key", "other")
+
+		assert.NoError(t, err)
+		assert.Nil(t, response)
+	})
+
+	t.Run("waits for a running request", func(t *testing.T) {
+		s, _ := newTestStore(60, 10)
+		s.Begin(ctx, "key", "fp")
+
+		result := make(chan *Response)
+		go func() {
+			response, _ := s.Begin(ctx, "key", "fp")
+			result <- response
+		}()
+		time.Sleep(10 * time.Millisecond)
+		s.Complete("key", &Response{Status: 200, Body: []byte("body")})
+
+		assert.Equal(t, "body", string((<-result).Body))
+	})
+
+	t.Run("stops waiting when the context ends", func(t *testing.T) {
+		s, _ := newTestStore(60, 10)
+		s.Begin(ctx, "key", "fp")
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := s.Begin(canceled, "key", "fp")
+
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("evicts completed entries but not running ones", func(t *testing.T) {
+		s, _ := newTestStore(60, 2)
+		s.Begin(ctx, "running", "fp")
+		s.Begin(ctx, "done", "fp")
+		s.Complete("done", &Response{Status: 200})
+
+		s.Begin(ctx, "new", "fp")
+
+		assert.Equal(t, 2, s.Len())
+		response, err := s.Begin(ctx, "done", "fp")
+		assert.NoError(t, err)
+		assert.Nil(t, response)
+	})
+}
@@ -0,0 +1,234 @@
+// Package imagefetch downloads remote images referenced by URL and converts
+// them to the base64 form Kiro accepts.
+package imagefetch
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// maxRedirects is the number of redirects followed before giving up
+const maxRedirects = 3
+
+// ErrBlockedAddress is returned when a URL resolves to a private or local address
+var ErrBlockedAddress = errors.New("address is not publicly routable")
+
+// Fetcher downloads remote images with size, type and address restrictions
+type Fetcher struct {
+	client       *http.Client
+	maxBytes     int64
+	allowedTypes map[string]bool
+}
+
+// NewFetcher creates a fetcher from cfg, or returns nil when image fetching is
+// disabled. A nil Fetcher drops remote images.
+func NewFetcher(cfg *config.Config) *Fetcher {
+	if !cfg.ImageFetchEnabled {
+		return nil
+	}
+	return newFetcher(cfg, CheckPublicAddress)
+}
+
+func newFetcher(cfg *config.Config, checkAddress func(ip net.IP) error) *Fetcher {
+	dialer := NewDialer(checkAddress)
+
+	allowedTypes := make(map[string]bool, len(cfg.ImageFetchAllowedTypes))
+	for _, mediaType := range cfg.ImageFetchAllowedTypes {
+		allowedTypes[strings.ToLower(mediaType)] = true
+	}
+
+	return &Fetcher{
+		client: &http.Client{
+			Timeout: time.Duration(cfg.ImageFetchTimeout * float64(time.Second)),
+			Transport: &http.Transport{
+				// No proxy: the address check must apply to the image host itself
+				Proxy:       nil,
+				DialContext: dialer.DialContext,
+			},
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+				return checkScheme(req.URL)
+			},
+		},
+		maxBytes:     int64(cfg.ImageFetchMaxBytes),
+		allowedTypes: allowedTypes,
+	}
+}
+
+// NewDialer returns a dialer that refuses connections to addresses checkAddress
+// rejects, such as CheckPublicAddress
+func NewDialer(checkAddress func(ip net.IP) error) *net.Dialer {
+	return &net.Dialer{
+		Timeout: 10 * time.Second,
+		// Checked after DNS resolution, so rebinding to a private address is caught too
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("invalid address %q", host)
+			}
+			return checkAddress(ip)
+		},
+	}
+}
+
+// blockedNetworks are the special-purpose ranges (RFC 6890 and successors) that
+// the net.IP helpers do not cover, or cover only in part
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",       // "this network"
+	"100.64.0.0/10",   // shared address space (CGNAT)
+	"192.0.0.0/24",    // IETF protocol assignments
+	"192.0.2.0/24",    // documentation (TEST-NET-1)
+	"192.88.99.0/24",  // 6to4 relay anycast
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation (TEST-NET-2)
+	"203.0.113.0/24",  // documentation (TEST-NET-3)
+	"240.0.0.0/4",     // reserved, including broadcast
+	"64:ff9b::/96",    // NAT64, reaches any IPv4 address
+	"64:ff9b:1::/48",  // local-use NAT64
+	"100::/64",        // discard-only
+	"2001::/32",       // Teredo
+	"2001:db8::/32",   // documentation
+	"2002::/16",       // 6to4, reaches any IPv4 address
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks = append(networks, network)
+	}
+	return networks
+}
+
+// CheckPublicAddress rejects loopback, private, link-local, CGNAT, reserved and
+// other non-public addresses, including their IPv4-mapped IPv6 forms
+func CheckPublicAddress(ip net.IP) error {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return fmt.Errorf("%w: %s", ErrBlockedAddress, ip)
+		}
+	}
+	return nil
+}
+
+func checkScheme(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported URL scheme %q", u.Scheme)
+	}
+	return nil
+}
+
+// Fetch downloads an image and returns its media type and base64-encoded data
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (string, string, error) {
+	if f == nil {
+		return "", "", errors.New("image fetching is disabled")
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", "", fmt.Errorf("invalid image URL: %w", err)
+	}
+	if err := checkScheme(u); err != nil {
+		return "", "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", "", fmt.Errorf("request failed with status %d", resp.StatusCode)
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil {
+		return "", "", fmt.Errorf("missing or invalid content type: %w", err)
+	}
+	mediaType = strings.ToLower(mediaType)
+	if !f.allowedTypes[mediaType] {
+		return "", "", fmt.Errorf("content type %q is not allowed", mediaType)
+	}
+
+	if resp.ContentLength > f.maxBytes {
+		return "", "", fmt.Errorf("image is %d bytes, limit is %d", resp.ContentLength, f.maxBytes)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, f.maxBytes+1))
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read image: %w", err)
+	}
+	if int64(len(body)) > f.maxBytes {
+		return "", "", fmt.Errorf("image exceeds %d bytes", f.maxBytes)
+	}
+
+	return mediaType, base64.StdEncoding.EncodeToString(body), nil
+}
+
+// ResolveImages replaces remote image references in messages with downloaded
+// base64 images. Images that cannot be fetched are dropped with a warning.
+func (f *Fetcher) ResolveImages(ctx context.Context, messages []converter.UnifiedMessage) {
+	for i := range messages {
+		if len(messages[i].Images) == 0 {
+			continue
+		}
+
+		images := messages[i].Images[:0]
+		for _, img := range messages[i].Images {
+			rawURL, _ := img["url"].(string)
+			if rawURL == "" {
+				images = append(images, img)
+				continue
+			}
+
+			mediaType, data, err := f.Fetch(ctx, rawURL)
+			if err != nil {
+				log.Warnf("Dropping image %s: %v", rawURL, err)
+				continue
+			}
+			images = append(images, map[string]interface{}{
+				"media_type": mediaType,
+				"data":       data,
+			})
+		}
+		messages[i].Images = images
+	}
+}
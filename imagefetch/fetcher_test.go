@@ -0,0 +1,161 @@
+// Package imagefetch provides tests for remote image fetching.
+package imagefetch
+
+import (
+	"context"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+)
+
+func newTestConfig() *config.Config {
+	return &config.Config{
+		ImageFetchEnabled:      true,
+		ImageFetchMaxBytes:     16,
+		ImageFetchTimeout:      5,
+		ImageFetchAllowedTypes: []string{"image/png"},
+	}
+}
+
+// newTestFetcher allows loopback addresses so httptest servers are reachable
+func newTestFetcher() *Fetcher {
+	return newFetcher(newTestConfig(), func(net.IP) error { return nil })
+}
+
+func newImageServer(contentType, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		w.Write([]byte(body))
+	}))
+}
+
+// =============================================================================
+// TestFetch
+// =============================================================================
+
+func TestFetch(t *testing.T) {
+	t.Run("downloads and encodes image", func(t *testing.T) {
+		server := newImageServer("image/png", "PNGDATA")
+		defer server.Close()
+
+		mediaType, data, err := newTestFetcher().Fetch(context.Background(), server.URL+"/cat.png")
+
+		assert.NoError(t, err)
+		assert.Equal(t, "image/png", mediaType)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("PNGDATA")), data)
+	})
+
+	t.Run("rejects disallowed content type", func(t *testing.T) {
+		server := newImageServer("text/html", "<html>")
+		defer server.Close()
+
+		_, _, err := newTestFetcher().Fetch(context.Background(), server.URL)
+
+		assert.ErrorContains(t, err, "not allowed")
+	})
+
+	t.Run("rejects oversized image", func(t *testing.T) {
+		server := newImageServer("image/png", strings.Repeat("x", 17))
+		defer server.Close()
+
+		_, _, err := newTestFetcher().Fetch(context.Background(), server.URL)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("rejects non-http schemes", func(t *testing.T) {
+		_, _, err := newTestFetcher().Fetch(context.Background(), "file:///etc/passwd")
+
+		assert.ErrorContains(t, err, "unsupported URL scheme")
+	})
+
+	t.Run("blocks loopback addresses", func(t *testing.T) {
+		server := newImageServer("image/png", "PNGDATA")
+		defer server.Close()
+
+		_, _, err := NewFetcher(newTestConfig()).Fetch(context.Background(), server.URL)
+
+		assert.ErrorIs(t, err, ErrBlockedAddress)
+	})
+
+	t.Run("nil fetcher is disabled", func(t *testing.T) {
+		var f *Fetcher
+
+		_, _, err := f.Fetch(context.Background(), "https://example.com/cat.png")
+
+		assert.Error(t, err)
+		assert.Nil(t, NewFetcher(&config.Config{}))
+	})
+}
+
+// =============================================================================
+// TestCheckPublicAddress
+// =============================================================================
+
+func TestCheckPublicAddress(t *testing.T) {
+	blocked := []string{
+		"127.0.0.1", "10.0.0.1", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0",
+		"0.1.2.3",         // 0.0.0.0/8
+		"100.64.0.1",      // 100.64.0.0/10 (CGNAT)
+		"100.127.255.254", // 100.64.0.0/10 (CGNAT)
+		"192.0.0.8",       // 192.0.0.0/24
+		"192.0.2.1",       // 192.0.2.0/24
+		"192.88.99.1",     // 192.88.99.0/24
+		"198.18.0.1",      // 198.18.0.0/15
+		"198.19.255.1",    // 198.18.0.0/15
+		"198.51.100.1",    // 198.51.100.0/24
+		"203.0.113.1",     // 203.0.113.0/24
+		"224.0.0.1",       // multicast
+		"240.0.0.1",       // 240.0.0.0/4
+		"255.255.255.255", // 240.0.0.0/4
+		"::1", "::", "fd00::1", "fe80::1", "ff02::1",
+		"64:ff9b::a9fe:a9fe", // 64:ff9b::/96 (NAT64 of 169.254.169.254)
+		"64:ff9b:1::1",       // 64:ff9b:1::/48
+		"100::1",             // 100::/64
+		"2001::1",            // 2001::/32 (Teredo)
+		"2001:db8::1",        // 2001:db8::/32
+		"2002:a9fe:a9fe::1",  // 2002::/16 (6to4)
+		"::ffff:127.0.0.1",   // IPv4-mapped loopback
+		"::ffff:100.64.0.1",  // IPv4-mapped CGNAT
+		"::ffff:169.254.169.254",
+	}
+	for _, addr := range blocked {
+		assert.ErrorIs(t, CheckPublicAddress(net.ParseIP(addr)), ErrBlockedAddress, addr)
+	}
+	for _, addr := range []string{"93.184.216.34", "100.128.0.1", "198.20.0.1", "::ffff:93.184.216.34", "2606:4700::1111"} {
+		assert.NoError(t, CheckPublicAddress(net.ParseIP(addr)), addr)
+	}
+}
+
+// =============================================================================
+// TestResolveImages
+// =============================================================================
+
+func TestResolveImages(t *testing.T) {
+	server := newImageServer("image/png", "PNGDATA")
+	defer server.Close()
+
+	messages := []converter.UnifiedMessage{{
+		Role: "user",
+		Images: []map[string]interface{}{
+			{"media_type": "image/jpeg", "data": "inline"},
+			{"url": server.URL + "/cat.png"},
+			{"url": "https://127.0.0.1:1/unreachable.png"},
+		},
+	}}
+
+	newTestFetcher().ResolveImages(context.Background(), messages)
+
+	images := messages[0].Images
+	assert.Len(t, images, 2)
+	assert.Equal(t, "inline", images[0]["data"])
+	assert.Equal(t, "image/png", images[1]["media_type"])
+	assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("PNGDATA")), images[1]["data"])
+}
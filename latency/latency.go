@@ -0,0 +1,226 @@
+// Package latency tracks per-model request latencies for /metrics and the
+// status dashboard.
+//
+// Every finished model request adds its total duration and, when the response
+// had content, its time to the first upstream token. Cumulative histograms are
+// kept since start for Prometheus; p50/p95/p99 are computed over the samples of
+// the last five minutes so they follow the current state of the Kiro API.
+// Callers pass only models Kiro is known to serve, recording the rest as
+// OtherModel, and a model without requests for an hour is forgotten, so the
+// number of series stays bounded.
+package latency
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// window is how long samples count towards the percentiles
+	window = 5 * time.Minute
+	// maxSamples bounds the samples kept per model within the window
+	maxSamples = 1000
+	// idleExpiry is how long a model without requests keeps its series
+	idleExpiry = time.Hour
+)
+
+// OtherModel is the series of requests to models that are not known to Kiro
+const OtherModel = "other"
+
+// Buckets are the upper bounds, in seconds, of the histogram buckets
+var Buckets = []float64{0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300}
+
+// Histogram is a cumulative latency histogram
+type Histogram struct {
+	// Counts[i] is the number of observations of at most Buckets[i] seconds
+	Counts []int64
+	Count  int64
+	// Sum is the total of all observations in seconds
+	Sum float64
+}
+
+func newHistogram() *Histogram {
+	return &Histogram{Counts: make([]int64, len(Buckets))}
+}
+
+func (h *Histogram) observe(d time.Duration) {
+	seconds := d.Seconds()
+	for i, bound := range Buckets {
+		if seconds <= bound {
+			h.Counts[i]++
+		}
+	}
+	h.Count++
+	h.Sum += seconds
+}
+
+// Percentiles are latencies in milliseconds over the rolling window
+type Percentiles struct {
+	P50 int64 `json:"p50_ms"`
+	P95 int64 `json:"p95_ms"`
+	P99 int64 `json:"p99_ms"`
+
+	// Count and Sum (in seconds) cover the same samples, for Prometheus summaries
+	Count int     `json:"-"`
+	Sum   float64 `json:"-"`
+}
+
+// ModelStats is the latency of one model
+type ModelStats struct {
+	Model string `json:"model"`
+	// Requests is the number of requests within the window
+	Requests   int          `json:"requests"`
+	FirstToken *Percentiles `json:"first_token,omitempty"`
+	Total      Percentiles  `json:"total"`
+
+	// FirstTokenHistogram and TotalHistogram count every request since start
+	FirstTokenHistogram Histogram `json:"-"`
+	TotalHistogram      Histogram `json:"-"`
+}
+
+// sample is one finished request; firstToken is negative when there was none
+type sample struct {
+	at         time.Time
+	firstToken time.Duration
+	total      time.Duration
+}
+
+// series is the latency state of one model
+type series struct {
+	// samples is a ring of the latest samples; next is the slot written next
+	samples    []sample
+	next       int
+	firstToken *Histogram
+	total      *Histogram
+	lastSeen   time.Time
+}
+
+// Tracker records latencies per model. A nil *Tracker ignores all calls.
+type Tracker struct {
+	now    func() time.Time
+	models map[string]*series
+	mu     sync.Mutex
+}
+
+// NewTracker creates an empty tracker
+func NewTracker() *Tracker {
+	return &Tracker{now: time.Now, models: make(map[string]*series)}
+}
+
+// Observe records a finished request to model. hasFirstToken is false for
+// requests that never received a token, such as failed ones.
+func (t *Tracker) Observe(model string, firstToken time.Duration, hasFirstToken bool, total time.Duration) {
+	if t == nil || model == "" {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.evict()
+
+	s := t.models[model]
+	if s == nil {
+		s = &series{firstToken: newHistogram(), total: newHistogram()}
+		t.models[model] = s
+	}
+	if hasFirstToken {
+		s.firstToken.observe(firstToken)
+	} else {
+		firstToken = -1
+	}
+	s.total.observe(total)
+
+	s.lastSeen = t.now()
+	smp := sample{at: s.lastSeen, firstToken: firstToken, total: total}
+	if len(s.samples) < maxSamples {
+		s.samples = append(s.samples, smp)
+	} else {
+		s.samples[s.next] = smp
+	}
+	s.next = (s.next + 1) % maxSamples
+}
+
+// evict forgets the models without requests for idleExpiry. Caller must hold t.mu.
+func (t *Tracker) evict() {
+	idleSince := t.now().Add(-idleExpiry)
+	for model, s := range t.models {
+		if s.lastSeen.Before(idleSince) {
+			delete(t.models, model)
+		}
+	}
+}
+
+// Snapshot returns the latency of every model with requests in the last
+// idleExpiry, sorted by model
+func (t *Tracker) Snapshot() []ModelStats {
+	stats := []ModelStats{}
+	if t == nil {
+		return stats
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.evict()
+
+	since := t.now().Add(-window)
+	for model, s := range t.models {
+		var firstTokens, totals []time.Duration
+		for _, smp := range s.samples {
+			if smp.at.Before(since) {
+				continue
+			}
+			totals = append(totals, smp.total)
+			if smp.firstToken >= 0 {
+				firstTokens = append(firstTokens, smp.firstToken)
+			}
+		}
+
+		stat := ModelStats{
+			Model:               model,
+			Requests:            len(totals),
+			Total:               percentiles(totals),
+			FirstTokenHistogram: s.firstToken.copy(),
+			TotalHistogram:      s.total.copy(),
+		}
+		if len(firstTokens) > 0 {
+			p := percentiles(firstTokens)
+			stat.FirstToken = &p
+		}
+		stats = append(stats, stat)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Model < stats[j].Model })
+	return stats
+}
+
+func (h *Histogram) copy() Histogram {
+	c := *h
+	c.Counts = append([]int64(nil), h.Counts...)
+	return c
+}
+
+func percentiles(samples []time.Duration) Percentiles {
+	if len(samples) == 0 {
+		return Percentiles{}
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	var sum time.Duration
+	for _, d := range samples {
+		sum += d
+	}
+	return Percentiles{
+		P50:   percentile(samples, 0.50).Milliseconds(),
+		P95:   percentile(samples, 0.95).Milliseconds(),
+		P99:   percentile(samples, 0.99).Milliseconds(),
+		Count: len(samples),
+		Sum:   sum.Seconds(),
+	}
+}
+
+// percentile returns the nearest-rank percentile q of sorted samples
+func percentile(sorted []time.Duration, q float64) time.Duration {
+	rank := int(math.Ceil(q*float64(len(sorted)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	return sorted[rank]
+}
@@ -0,0 +1,97 @@
+// Package latency provides tests for per-model latency tracking.
+package latency
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestTracker creates a tracker with a controllable clock
+func newTestTracker() (*Tracker, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	t := NewTracker()
+	t.now = func() time.Time { return now }
+	return t, &now
+}
+
+// =============================================================================
+// TestTracker
+// =============================================================================
+
+func TestTracker(t *testing.T) {
+	t.Run("computes percentiles per model", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		for i := 1; i <= 100; i++ {
+			tracker.Observe("claude-sonnet-4.5", time.Duration(i)*10*time.Millisecond, true, time.Duration(i)*time.Second)
+		}
+		tracker.Observe("claude-haiku-4.5", 0, false, 500*time.Millisecond)
+
+		stats := tracker.Snapshot()
+
+		assert.Len(t, stats, 2)
+		assert.Equal(t, "claude-haiku-4.5", stats[0].Model)
+		assert.Nil(t, stats[0].FirstToken)
+		assert.Equal(t, Percentiles{P50: 500, P95: 500, P99: 500, Count: 1, Sum: 0.5}, stats[0].Total)
+
+		sonnet := stats[1]
+		assert.Equal(t, 100, sonnet.Requests)
+		assert.Equal(t, Percentiles{P50: 500, P95: 950, P99: 990, Count: 100, Sum: 50.5}, *sonnet.FirstToken)
+		assert.Equal(t, Percentiles{P50: 50000, P95: 95000, P99: 99000, Count: 100, Sum: 5050}, sonnet.Total)
+	})
+
+	t.Run("percentiles cover only the rolling window", func(t *testing.T) {
+		tracker, now := newTestTracker()
+		tracker.Observe("claude-sonnet-4.5", time.Second, true, 30*time.Second)
+		*now = now.Add(window + time.Second)
+		tracker.Observe("claude-sonnet-4.5", 100*time.Millisecond, true, time.Second)
+
+		stats := tracker.Snapshot()
+
+		assert.Equal(t, 1, stats[0].Requests)
+		assert.Equal(t, int64(1000), stats[0].Total.P99)
+		assert.Equal(t, int64(2), stats[0].TotalHistogram.Count)
+	})
+
+	t.Run("histograms are cumulative", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		tracker.Observe("claude-sonnet-4.5", 200*time.Millisecond, true, 3*time.Second)
+		tracker.Observe("claude-sonnet-4.5", 0, false, 400*time.Millisecond)
+
+		h := tracker.Snapshot()[0].TotalHistogram
+
+		assert.Equal(t, int64(2), h.Count)
+		assert.InDelta(t, 3.4, h.Sum, 0.001)
+		// Buckets: 0.1, 0.25, 0.5, 1, 2.5, 5, ...
+		assert.Equal(t, []int64{0, 0, 1, 1, 1, 2, 2, 2, 2, 2, 2}, h.Counts)
+		assert.Equal(t, int64(1), tracker.Snapshot()[0].FirstTokenHistogram.Count)
+	})
+
+	t.Run("forgets models without requests for an hour", func(t *testing.T) {
+		tracker, now := newTestTracker()
+		tracker.Observe("claude-haiku-4.5", 0, false, time.Second)
+		*now = now.Add(30 * time.Minute)
+		tracker.Observe("claude-sonnet-4.5", 0, false, time.Second)
+		*now = now.Add(31 * time.Minute)
+
+		stats := tracker.Snapshot()
+
+		assert.Len(t, stats, 1)
+		assert.Equal(t, "claude-sonnet-4.5", stats[0].Model)
+	})
+
+	t.Run("ignores requests without a model", func(t *testing.T) {
+		tracker, _ := newTestTracker()
+		tracker.Observe("", time.Second, true, time.Second)
+
+		assert.Empty(t, tracker.Snapshot())
+	})
+
+	t.Run("nil tracker is a no-op", func(t *testing.T) {
+		var tracker *Tracker
+		tracker.Observe("claude-sonnet-4.5", time.Second, true, time.Second)
+
+		assert.Empty(t, tracker.Snapshot())
+	})
+}
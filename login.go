@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"kiro-go-proxy/auth"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// runLogin implements the "login" subcommand: it signs in with AWS Builder ID or
+// IAM Identity Center through the OIDC device flow and saves the credentials
+// where the gateway reads them
+func runLogin(args []string) {
+	fs := flag.NewFlagSet("login", flag.ExitOnError)
+	configFile := fs.String("config", "", "Path to a YAML/JSON config file (overrides CONFIG_FILE)")
+	region := fs.String("region", "", "AWS SSO OIDC region (default: REGION)")
+	startURL := fs.String("start-url", "", "IAM Identity Center start URL (default: AWS Builder ID)")
+	credsFile := fs.String("creds-file", "", "Credentials file to write (default: KIRO_CREDS_FILE)")
+	dbFile := fs.String("db-file", "", "kiro-cli SQLite database to write (default: KIRO_CLI_DB_FILE)")
+	fs.Parse(args)
+
+	cfg := loadCommandConfig(*configFile)
+
+	// Explicit flags win; otherwise write where the gateway is configured to read
+	if *credsFile == "" && *dbFile == "" {
+		*dbFile = cfg.KiroCLIDBFile
+		if *dbFile == "" {
+			*credsFile = cfg.KiroCredsFile
+		}
+	}
+	if *credsFile == "" && *dbFile == "" {
+		log.Fatal("No credentials destination. Pass --creds-file or --db-file, or set KIRO_CREDS_FILE or KIRO_CLI_DB_FILE")
+	}
+	if *region == "" {
+		*region = cfg.Region
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	login := auth.NewDeviceLogin(cfg, *region, *startURL)
+	login.Prompt = func(authz *auth.DeviceAuthorization) {
+		fmt.Println()
+		fmt.Println("  Open this URL in a browser to sign in:")
+		if authz.VerificationURIComplete != "" {
+			fmt.Printf("  ➜  %s\n", authz.VerificationURIComplete)
+		} else {
+			fmt.Printf("  ➜  %s\n", authz.VerificationURI)
+		}
+		fmt.Printf("  Code: %s\n", authz.UserCode)
+		fmt.Println()
+		fmt.Println("  Waiting for approval...")
+	}
+
+	result, err := login.Run(ctx)
+	if err != nil {
+		log.Fatalf("Login failed: %v", err)
+	}
+
+	destination := *credsFile
+	if *dbFile != "" {
+		destination = *dbFile
+		err = auth.SaveLoginToSQLite(*dbFile, result)
+	} else {
+		var cipher *auth.CredsCipher
+		if cipher, err = auth.NewCredsCipher(cfg); err == nil {
+			err = auth.SaveLoginToFile(*credsFile, result, cipher)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Failed to save credentials: %v", err)
+	}
+	fmt.Printf("  ✓ Logged in, credentials saved to %s\n", destination)
+}
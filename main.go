@@ -5,30 +5,92 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"syscall"
 	"time"
 
 	"kiro-go-proxy/api"
 	"kiro-go-proxy/auth"
 	"kiro-go-proxy/config"
-	"kiro-go-proxy/model"
+	"kiro-go-proxy/fixtures"
+	"kiro-go-proxy/redact"
+	"kiro-go-proxy/servertls"
+	"kiro-go-proxy/tracing"
 
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 )
 
+// command is a kiro-gateway subcommand
+type command struct {
+	run     func(args []string)
+	summary string
+}
+
+// commands are the subcommands; running without one serves the gateway
+var commands = map[string]command{
+	"serve":   {runServe, "Run the gateway (default)"},
+	"login":   {runLogin, "Sign in with AWS Builder ID / IAM Identity Center and save the credentials"},
+	"models":  {runModels, "Print the model list as /v1/models resolves it"},
+	"token":   {runToken, "Show access token expiry per account, or refresh with --refresh"},
+	"doctor":  {runDoctor, "Check configuration, credentials and Kiro reachability"},
+	"encrypt": {runEncrypt, "Encrypt plaintext credentials files with CREDS_ENCRYPTION_KEY or the OS keychain key"},
+}
+
 func main() {
+	name, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+
+	if name == "help" {
+		printUsage(os.Stdout)
+		return
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		printUsage(os.Stderr)
+		os.Exit(2)
+	}
+	cmd.run(args)
+}
+
+func printUsage(w io.Writer) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(w, "Kiro Gateway v%s\n\n", config.AppVersion)
+	fmt.Fprintln(w, "Usage: kiro-gateway [command] [flags]")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Commands:")
+	for _, name := range names {
+		fmt.Fprintf(w, "  %-8s %s\n", name, commands[name].summary)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run 'kiro-gateway <command> -h' for the flags of a command.")
+}
+
+// runServe implements the "serve" subcommand
+func runServe(args []string) {
 	// Parse command line arguments
-	host := flag.String("host", "", "Server host address")
-	port := flag.Int("port", 0, "Server port")
-	showVersion := flag.Bool("version", false, "Show version")
-	flag.Parse()
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	host := fs.String("host", "", "Server host address")
+	port := fs.Int("port", 0, "Server port")
+	configFile := fs.String("config", "", "Path to a YAML/JSON config file (overrides CONFIG_FILE)")
+	mockDir := fs.String("mock", "", "Serve recorded fixtures from this directory instead of calling Kiro (no credentials needed)")
+	showVersion := fs.Bool("version", false, "Show version")
+	fs.Parse(args)
 
 	if *showVersion {
 		fmt.Printf("Kiro Gateway v%s\n", config.AppVersion)
@@ -36,7 +98,10 @@ func main() {
 	}
 
 	// Load configuration
-	cfg := config.Load()
+	cfg, err := config.LoadFile(*configFile)
+	if err != nil {
+		log.Fatalf("Configuration error: %v", err)
+	}
 
 	// Override with CLI arguments
 	if *host != "" {
@@ -47,56 +112,102 @@ func main() {
 	}
 
 	// Setup logging
-	setupLogging(cfg.LogLevel)
+	setupLogging(cfg.LogLevel, cfg.LogFormat, cfg.ParanoidLogging)
 
-	// Validate configuration
-	if err := cfg.Validate(); err != nil {
+	// Validate configuration; mock mode runs without Kiro credentials
+	validate := cfg.Validate
+	if *mockDir != "" {
+		validate = cfg.ValidateSettings
+	}
+	if err := validate(); err != nil {
 		log.Fatalf("Configuration error: %v", err)
 	}
+	for _, setting := range cfg.UnknownAPIKeys() {
+		log.Warnf("%s has entries for keys not in PROXY_API_KEY or PROXY_API_KEYS; they never apply", setting)
+	}
+
+	// Load listener TLS settings
+	tlsConfig, err := servertls.Load(cfg)
+	if err != nil {
+		log.Fatalf("TLS configuration error: %v", err)
+	}
 
 	// Print startup banner
-	printBanner(cfg.ServerHost, cfg.ServerPort)
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	printBanner(scheme, cfg.ServerHost, cfg.ServerPort)
 
-	// Initialize authentication manager
-	authManager := auth.NewManager(cfg)
+	// Export spans when an OTLP endpoint is configured
+	tracer := tracing.Setup(cfg)
 
 	// Create API server
-	server := api.NewServer(cfg, authManager)
+	var server *api.Server
+	if *mockDir != "" {
+		server, err = newMockServer(cfg, *mockDir)
+		if err != nil {
+			log.Fatalf("Mock mode error: %v", err)
+		}
+	} else {
+		server = api.NewServerWithPool(cfg, auth.NewPool(cfg))
+	}
+	server.ConfigFile = *configFile
 
 	// Load models from Kiro API
-	loadModels(server, authManager, cfg)
+	loadModels(server)
 
-	// Setup Gin router
+	// Re-fetch the model list whenever the cache TTL expires
+	server.ModelRefresher.Start()
+
+	// Start proactive token refresh
+	if cfg.TokenRefreshBackground && *mockDir == "" {
+		server.CredentialPool.StartRefreshers()
+	}
+
+	// Reload credentials kiro-cli rotates in its database
+	if *mockDir == "" {
+		server.CredentialPool.StartWatchers()
+	}
+
+	// Gin prints its route table and debug warnings only at DEBUG
 	if cfg.LogLevel == "DEBUG" {
 		gin.SetMode(gin.DebugMode)
 	} else {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
-	router := gin.New()
-	router.Use(gin.Recovery())
-	router.Use(corsMiddleware())
-
-	// Setup routes
-	server.SetupRoutes(router)
-
 	// Create HTTP server
 	addr := fmt.Sprintf("%s:%d", cfg.ServerHost, cfg.ServerPort)
 	srv := &http.Server{
 		Addr:         addr,
-		Handler:      router,
+		Handler:      server.Handler(),
 		ReadTimeout:  time.Duration(cfg.StreamingReadTimeout) * time.Second,
 		WriteTimeout: time.Duration(cfg.StreamingReadTimeout) * time.Second,
+		TLSConfig:    tlsConfig,
 	}
 
 	// Start server in goroutine
 	go func() {
-		log.Infof("Starting server on %s", addr)
-		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		log.Infof("Starting server on %s://%s", scheme, addr)
+		var err error
+		if tlsConfig != nil {
+			// Certificates come from TLSConfig
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
 
+	// Reload the config file and credentials on SIGHUP
+	go reloadOnSIGHUP(server)
+
+	// Bootstrap is done: /startupz passes and /readyz can pass
+	server.MarkStarted()
+
 	// Wait for interrupt signal
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -112,10 +223,22 @@ func main() {
 		log.Errorf("Server shutdown error: %v", err)
 	}
 
+	// Stop background token and model refresh
+	server.CredentialPool.Stop()
+	server.ModelRefresher.Stop()
+
+	// Stop message batches; unfinished requests are ended as interrupted on the next start
+	server.Batches.Stop()
+
+	// Flush remaining spans
+	tracer.Stop()
+
 	log.Info("Server stopped")
 }
 
-func setupLogging(level string) {
+func setupLogging(level, format string, paranoid bool) {
+	redact.SetParanoid(paranoid)
+
 	switch level {
 	case "DEBUG":
 		log.SetLevel(log.DebugLevel)
@@ -127,13 +250,21 @@ func setupLogging(level string) {
 		log.SetLevel(log.InfoLevel)
 	}
 
-	log.SetFormatter(&log.TextFormatter{
+	if format == "json" {
+		// One JSON object per line for log ingestion (Loki, ELK)
+		log.SetFormatter(&redact.Formatter{Formatter: &log.JSONFormatter{
+			TimestampFormat: time.RFC3339Nano,
+		}})
+		return
+	}
+
+	log.SetFormatter(&redact.Formatter{Formatter: &log.TextFormatter{
 		FullTimestamp:   true,
 		TimestampFormat: "2006-01-02 15:04:05",
-	})
+	}})
 }
 
-func printBanner(host string, port int) {
+func printBanner(scheme, host string, port int) {
 	displayHost := host
 	if host == "0.0.0.0" {
 		displayHost = "localhost"
@@ -143,10 +274,10 @@ func printBanner(host string, port int) {
 	fmt.Printf("  👻 Kiro Gateway v%s\n", config.AppVersion)
 	fmt.Println()
 	fmt.Println("  Server running at:")
-	fmt.Printf("  ➜  http://%s:%d\n", displayHost, port)
+	fmt.Printf("  ➜  %s://%s:%d\n", scheme, displayHost, port)
 	fmt.Println()
-	fmt.Printf("  API Docs:      http://%s:%d/docs\n", displayHost, port)
-	fmt.Printf("  Health Check:  http://%s:%d/health\n", displayHost, port)
+	fmt.Printf("  API Docs:      %s://%s:%d/docs\n", scheme, displayHost, port)
+	fmt.Printf("  Health Check:  %s://%s:%d/health\n", scheme, displayHost, port)
 	fmt.Println()
 	fmt.Println("  ────────────────────────────────────────────────────────")
 	fmt.Println("  💬 Found a bug? Need help? Have questions?")
@@ -155,78 +286,39 @@ func printBanner(host string, port int) {
 	fmt.Println()
 }
 
-func loadModels(server *api.Server, authManager *auth.Manager, cfg *config.Config) {
-	// Try to fetch models from Kiro API
-	token, err := authManager.GetAccessToken()
-	if err != nil {
-		log.Warnf("Failed to get token for model list: %v", err)
-		return
-	}
-
-	// Create temporary model cache for loading
-	modelCache := model.NewCache(cfg)
-
-	// Build request
-	url := fmt.Sprintf("%s/ListAvailableModels?origin=AI_EDITOR", authManager.QHost())
-	if authManager.ProfileArn() != "" {
-		url += "&profileArn=" + authManager.ProfileArn()
+// reloadOnSIGHUP applies configuration changes each time the process receives SIGHUP
+func reloadOnSIGHUP(server *api.Server) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	for range hup {
+		log.Info("Received SIGHUP, reloading configuration")
+		if _, err := server.ReloadConfig(); err != nil {
+			log.Errorf("Configuration reload failed, keeping current settings: %v", err)
+		}
 	}
+}
 
-	req, err := http.NewRequest("GET", url, nil)
+// newMockServer creates a server that answers from the fixtures recorded in dir
+// instead of calling Kiro
+func newMockServer(cfg *config.Config, dir string) (*api.Server, error) {
+	replayer, err := fixtures.NewReplayer(dir)
 	if err != nil {
-		log.Warnf("Failed to create model list request: %v", err)
-		return
+		return nil, err
 	}
+	log.Infof("Mock mode: replaying %d fixtures from %s", replayer.Len(), dir)
 
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Accept", "application/json")
+	server := api.NewServer(cfg, &auth.Manager{})
+	server.HttpClient = replayer
+	return server, nil
+}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
+func loadModels(server *api.Server) {
+	count, _, _, err := server.ModelRefresher.Refresh()
 	if err != nil {
 		log.Warnf("Failed to fetch models from Kiro API: %v", err)
 		log.Warn("Using fallback model list")
 		return
 	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		log.Warnf("Model list request failed with status %d", resp.StatusCode)
-		log.Warn("Using fallback model list")
-		return
-	}
-
-	var result struct {
-		Models []model.Info `json:"models"`
-	}
-
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		log.Warnf("Failed to parse model list: %v", err)
-		return
-	}
-
-	modelCache.Update(result.Models)
-	log.Infof("Loaded %d models from Kiro API", len(result.Models))
-
-	// Update server's cache with loaded models
-	for _, m := range modelCache.GetAllModelIDs() {
-		server.ModelCache.AddHiddenModel(m, m)
-	}
-}
-
-func corsMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-Requested-With, Accept")
-		c.Header("Access-Control-Allow-Credentials", "true")
-
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	}
+	log.Infof("Loaded %d models from Kiro API", count)
 }
 
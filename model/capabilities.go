@@ -0,0 +1,100 @@
+package model
+
+import "strings"
+
+// defaultMaxOutputTokens is assumed for models missing from the static table
+const defaultMaxOutputTokens = 64000
+
+// TokenLimits are the token limits reported by ListAvailableModels
+type TokenLimits struct {
+	MaxInputTokens  int `json:"maxInputTokens,omitempty"`
+	MaxOutputTokens int `json:"maxOutputTokens,omitempty"`
+}
+
+// Details describes a model and its capabilities for the models endpoints
+type Details struct {
+	ID              string
+	ContextWindow   int
+	MaxOutputTokens int
+	SupportsVision  bool
+	SupportsTools   bool
+	// Family is "haiku", "sonnet" or "opus", or empty for other models
+	Family string
+	// Verified is false for names that are only passed through to Kiro
+	Verified bool
+}
+
+// knownCapabilities is the static fallback for models whose limits Kiro does not report
+var knownCapabilities = map[string]Details{
+	"claude-opus-4":     {ContextWindow: 200000, MaxOutputTokens: 32000, SupportsVision: true, SupportsTools: true},
+	"claude-opus-4.1":   {ContextWindow: 200000, MaxOutputTokens: 32000, SupportsVision: true, SupportsTools: true},
+	"claude-opus-4.5":   {ContextWindow: 200000, MaxOutputTokens: 64000, SupportsVision: true, SupportsTools: true},
+	"claude-sonnet-4":   {ContextWindow: 200000, MaxOutputTokens: 64000, SupportsVision: true, SupportsTools: true},
+	"claude-sonnet-4.5": {ContextWindow: 200000, MaxOutputTokens: 64000, SupportsVision: true, SupportsTools: true},
+	"claude-haiku-4.5":  {ContextWindow: 200000, MaxOutputTokens: 64000, SupportsVision: true, SupportsTools: true},
+	"claude-3.7-sonnet": {ContextWindow: 200000, MaxOutputTokens: 64000, SupportsVision: true, SupportsTools: true},
+	"auto":              {ContextWindow: 200000, MaxOutputTokens: 64000, SupportsVision: true, SupportsTools: true},
+}
+
+// Capabilities returns the details of an internal model ID, preferring limits
+// reported by Kiro over the static table
+func (c *Cache) Capabilities(modelID string) Details {
+	details, ok := knownCapabilities[modelID]
+	if !ok {
+		// Unknown models: assume Claude defaults for Claude families, text-only otherwise
+		isClaude := ExtractModelFamily(modelID) != ""
+		details = Details{
+			ContextWindow:   c.GetMaxInputTokens(modelID),
+			MaxOutputTokens: defaultMaxOutputTokens,
+			SupportsVision:  isClaude,
+			SupportsTools:   true,
+		}
+	}
+	details.ID = modelID
+
+	c.mu.RLock()
+	info, ok := c.models[modelID]
+	c.mu.RUnlock()
+	if !ok {
+		return details
+	}
+
+	if info.TokenLimits != nil {
+		if info.TokenLimits.MaxInputTokens > 0 {
+			details.ContextWindow = info.TokenLimits.MaxInputTokens
+		}
+		if info.TokenLimits.MaxOutputTokens > 0 {
+			details.MaxOutputTokens = info.TokenLimits.MaxOutputTokens
+		}
+	}
+	if len(info.SupportedInputTypes) > 0 {
+		details.SupportsVision = false
+		for _, inputType := range info.SupportedInputTypes {
+			if strings.EqualFold(inputType, "IMAGE") {
+				details.SupportsVision = true
+			}
+		}
+	}
+	return details
+}
+
+// GetModelDetails returns the details of a public model name and whether it is a known model
+func (r *Resolver) GetModelDetails(modelName string) (Details, bool) {
+	resolution := r.Resolve(modelName)
+	details := r.cache.Capabilities(resolution.InternalID)
+	details.ID = modelName
+	details.Family = ExtractModelFamily(resolution.InternalID)
+	details.Verified = resolution.IsVerified
+	return details, resolution.IsVerified
+}
+
+// GetAvailableModelDetails returns details for every model listed by /v1/models
+func (r *Resolver) GetAvailableModelDetails() []Details {
+	models := r.GetAvailableModels()
+	result := make([]Details, 0, len(models))
+	for _, id := range models {
+		details, _ := r.GetModelDetails(id)
+		result = append(result, details)
+	}
+	return result
+}
@@ -0,0 +1,101 @@
+// Package model provides tests for model capability metadata.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestCapabilities
+// =============================================================================
+
+func TestCapabilities(t *testing.T) {
+	t.Run("uses static table for known models", func(t *testing.T) {
+		cache := NewCache(newTestConfig())
+
+		details := cache.Capabilities("claude-opus-4")
+
+		assert.Equal(t, "claude-opus-4", details.ID)
+		assert.Equal(t, 200000, details.ContextWindow)
+		assert.Equal(t, 32000, details.MaxOutputTokens)
+		assert.True(t, details.SupportsVision)
+		assert.True(t, details.SupportsTools)
+	})
+
+	t.Run("prefers limits reported by Kiro", func(t *testing.T) {
+		cache := NewCache(newTestConfig())
+		cache.Sync([]Info{{
+			ModelID:             "claude-sonnet-4.5",
+			TokenLimits:         &TokenLimits{MaxInputTokens: 1000000, MaxOutputTokens: 32000},
+			SupportedInputTypes: []string{"TEXT"},
+		}})
+
+		details := cache.Capabilities("claude-sonnet-4.5")
+
+		assert.Equal(t, 1000000, details.ContextWindow)
+		assert.Equal(t, 32000, details.MaxOutputTokens)
+		assert.False(t, details.SupportsVision)
+		assert.Equal(t, 1000000, cache.GetMaxInputTokens("claude-sonnet-4.5"))
+	})
+
+	t.Run("detects image input support", func(t *testing.T) {
+		cache := NewCache(newTestConfig())
+		cache.Sync([]Info{{ModelID: "qwen3-coder", SupportedInputTypes: []string{"TEXT", "IMAGE"}}})
+
+		assert.True(t, cache.Capabilities("qwen3-coder").SupportsVision)
+	})
+
+	t.Run("assumes text-only for unknown non-Claude models", func(t *testing.T) {
+		cache := NewCache(newTestConfig())
+
+		details := cache.Capabilities("some-model")
+
+		assert.False(t, details.SupportsVision)
+		assert.Equal(t, 200000, details.ContextWindow)
+		assert.Equal(t, defaultMaxOutputTokens, details.MaxOutputTokens)
+	})
+}
+
+// =============================================================================
+// TestGetModelDetails
+// =============================================================================
+
+func TestGetModelDetails(t *testing.T) {
+	t.Run("resolves aliases and keeps the public name", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.ModelAliases = map[string]string{"fast": "claude-haiku-4.5"}
+		cache := NewCache(cfg)
+		cache.Sync([]Info{{ModelID: "claude-haiku-4.5"}})
+		resolver := NewResolver(cache, cfg)
+
+		details, ok := resolver.GetModelDetails("fast")
+
+		assert.True(t, ok)
+		assert.Equal(t, "fast", details.ID)
+		assert.Equal(t, 64000, details.MaxOutputTokens)
+	})
+
+	t.Run("reports unknown models", func(t *testing.T) {
+		cfg := newTestConfig()
+		resolver := NewResolver(NewCache(cfg), cfg)
+
+		_, ok := resolver.GetModelDetails("gpt-4")
+
+		assert.False(t, ok)
+	})
+
+	t.Run("lists details for available models", func(t *testing.T) {
+		cfg := newTestConfig()
+		cache := NewCache(cfg)
+		cache.Sync([]Info{{ModelID: "claude-haiku-4.5"}, {ModelID: "claude-sonnet-4"}})
+		resolver := NewResolver(cache, cfg)
+
+		details := resolver.GetAvailableModelDetails()
+
+		assert.Len(t, details, 2)
+		assert.Equal(t, "claude-haiku-4.5", details[0].ID)
+		assert.True(t, details[0].SupportsTools)
+	})
+}
@@ -0,0 +1,19 @@
+package model
+
+// FallbackChain returns the models to retry with, in order, when modelID fails
+// upstream. chains maps model IDs or globs to their fallbacks: an exact ID wins
+// over the longest matching glob, which wins over "*". modelID itself and
+// duplicates are left out.
+func FallbackChain(modelID string, chains map[string][]string) []string {
+	_, chain, _ := MatchPattern(modelID, chains)
+
+	seen := map[string]bool{modelID: true}
+	var result []string
+	for _, fallback := range chain {
+		if fallback != "" && !seen[fallback] {
+			seen[fallback] = true
+			result = append(result, fallback)
+		}
+	}
+	return result
+}
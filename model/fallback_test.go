@@ -0,0 +1,39 @@
+// Package model provides tests for model fallback chains.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestFallbackChain
+// =============================================================================
+
+func TestFallbackChain(t *testing.T) {
+	chains := map[string][]string{
+		"claude-opus-4.5":   {"claude-sonnet-4.5", "claude-haiku-4.5"},
+		"claude-sonnet-*":   {"claude-haiku-4.5"},
+		"claude-sonnet-4.*": {"claude-sonnet-4", "claude-haiku-4.5"},
+		"*":                 {"claude-sonnet-4.5", "auto"},
+	}
+
+	t.Run("exact ID wins", func(t *testing.T) {
+		assert.Equal(t, []string{"claude-sonnet-4.5", "claude-haiku-4.5"}, FallbackChain("claude-opus-4.5", chains))
+	})
+
+	t.Run("longest glob wins", func(t *testing.T) {
+		assert.Equal(t, []string{"claude-sonnet-4", "claude-haiku-4.5"}, FallbackChain("claude-sonnet-4.5", chains))
+		assert.Equal(t, []string{"claude-haiku-4.5"}, FallbackChain("claude-sonnet-3", chains))
+	})
+
+	t.Run("star matches the rest without the model itself", func(t *testing.T) {
+		assert.Equal(t, []string{"claude-sonnet-4.5", "auto"}, FallbackChain("claude-haiku-4.5", chains))
+		assert.Equal(t, []string{"claude-sonnet-4.5"}, FallbackChain("auto", chains))
+	})
+
+	t.Run("no chains", func(t *testing.T) {
+		assert.Empty(t, FallbackChain("claude-opus-4.5", nil))
+	})
+}
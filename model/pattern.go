@@ -0,0 +1,41 @@
+package model
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// MatchPattern returns the entry of entries that applies to modelID and its
+// key. Keys are model IDs or globs: an exact ID wins over the longest matching
+// glob, which wins over "*".
+func MatchPattern[V any](modelID string, entries map[string]V) (string, V, bool) {
+	if value, ok := entries[modelID]; ok {
+		return modelID, value, true
+	}
+
+	var globs []string
+	for pattern := range entries {
+		if pattern != "*" && strings.ContainsAny(pattern, "*?[") {
+			globs = append(globs, pattern)
+		}
+	}
+	// Longest first; ties in name order so the choice does not depend on map order
+	sort.Slice(globs, func(i, j int) bool {
+		if len(globs[i]) != len(globs[j]) {
+			return len(globs[i]) > len(globs[j])
+		}
+		return globs[i] < globs[j]
+	})
+	for _, pattern := range globs {
+		if matched, _ := path.Match(pattern, modelID); matched {
+			return pattern, entries[pattern], true
+		}
+	}
+
+	if value, ok := entries["*"]; ok {
+		return "*", value, true
+	}
+	var zero V
+	return "", zero, false
+}
@@ -0,0 +1,54 @@
+// Package model provides tests for model ID pattern matching.
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestMatchPattern
+// =============================================================================
+
+func TestMatchPattern(t *testing.T) {
+	entries := map[string]int{
+		"claude-sonnet-4.5": 1,
+		"claude-*":          2,
+		"claude-sonnet-*":   3,
+		"claude-haiku-?.5":  4,
+		"claude-opus-[0-9]": 5,
+		"*":                 6,
+	}
+
+	tests := []struct {
+		modelID string
+		key     string
+		value   int
+	}{
+		{"claude-sonnet-4.5", "claude-sonnet-4.5", 1},
+		{"claude-sonnet-4", "claude-sonnet-*", 3},
+		{"claude-opus-4", "claude-opus-[0-9]", 5},
+		{"claude-haiku-4.5", "claude-haiku-?.5", 4},
+		{"claude-3", "claude-*", 2},
+		{"gpt-4o", "*", 6},
+	}
+	for _, tt := range tests {
+		key, value, ok := MatchPattern(tt.modelID, entries)
+		assert.True(t, ok, tt.modelID)
+		assert.Equal(t, tt.key, key, tt.modelID)
+		assert.Equal(t, tt.value, value, tt.modelID)
+	}
+
+	t.Run("ties are broken by name", func(t *testing.T) {
+		key, _, _ := MatchPattern("ab", map[string]int{"a?": 1, "?b": 2})
+		assert.Equal(t, "?b", key)
+	})
+
+	t.Run("no match without a catch-all", func(t *testing.T) {
+		key, value, ok := MatchPattern("gpt-4o", map[string]int{"claude-*": 1})
+		assert.False(t, ok)
+		assert.Equal(t, "", key)
+		assert.Equal(t, 0, value)
+	})
+}
@@ -0,0 +1,135 @@
+package model
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// refreshRetryDelay is how long to wait before retrying a failed model list fetch
+const refreshRetryDelay = time.Minute
+
+// Fetcher returns the current model list from the Kiro API
+type Fetcher func() ([]Info, error)
+
+// Refresher keeps the model cache in sync with Kiro, re-fetching the model
+// list whenever the cache TTL expires.
+type Refresher struct {
+	cache *Cache
+	fetch Fetcher
+
+	// Background refresh scheduler
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+	stopOnce sync.Once
+
+	// refreshMu serializes fetches; mu guards the scheduler channels
+	refreshMu sync.Mutex
+	mu        sync.Mutex
+}
+
+// NewRefresher creates a refresher that fills cache using fetch
+func NewRefresher(cache *Cache, fetch Fetcher) *Refresher {
+	return &Refresher{
+		cache: cache,
+		fetch: fetch,
+	}
+}
+
+// Refresh fetches the model list now and returns how many models Kiro reported,
+// plus the model IDs that appeared and disappeared
+func (r *Refresher) Refresh() (count int, added, removed []string, err error) {
+	r.refreshMu.Lock()
+	defer r.refreshMu.Unlock()
+
+	models, err := r.fetch()
+	if err != nil {
+		return 0, nil, nil, err
+	}
+
+	added, removed = r.cache.Sync(models)
+	if len(added) > 0 {
+		log.Infof("Models added: %v", added)
+	}
+	if len(removed) > 0 {
+		log.Infof("Models removed: %v", removed)
+	}
+	return len(models), added, removed, nil
+}
+
+// Start begins refreshing the model list in the background each time the cache
+// TTL expires. It does nothing when the TTL is zero.
+func (r *Refresher) Start() {
+	if r.cache.ttl <= 0 {
+		return
+	}
+
+	r.mu.Lock()
+	if r.stopCh != nil {
+		r.mu.Unlock()
+		return
+	}
+	r.stopCh = make(chan struct{})
+	r.doneCh = make(chan struct{})
+	r.mu.Unlock()
+
+	go r.refreshLoop()
+	log.Debug("Background model refresher started")
+}
+
+// Stop stops the background refresher and waits for it to exit
+func (r *Refresher) Stop() {
+	r.mu.Lock()
+	stopCh, doneCh := r.stopCh, r.doneCh
+	r.mu.Unlock()
+
+	if stopCh == nil {
+		return
+	}
+
+	r.stopOnce.Do(func() {
+		close(stopCh)
+	})
+	<-doneCh
+}
+
+func (r *Refresher) refreshLoop() {
+	defer close(r.doneCh)
+
+	for {
+		select {
+		case <-time.After(r.nextRefreshDelay()):
+		case <-r.stopCh:
+			log.Debug("Background model refresher stopped")
+			return
+		}
+
+		if !r.cache.IsStale() {
+			continue
+		}
+
+		log.Debug("Model cache stale, refreshing in background")
+		if _, _, _, err := r.Refresh(); err != nil {
+			log.Warnf("Background model refresh failed: %v", err)
+		}
+	}
+}
+
+// nextRefreshDelay returns how long until the cache goes stale, or the retry
+// delay if it is already stale (e.g. after a failed fetch)
+func (r *Refresher) nextRefreshDelay() time.Duration {
+	lastUpdate := r.cache.LastUpdateTime()
+	if lastUpdate.IsZero() {
+		return refreshRetryDelay
+	}
+
+	delay := time.Until(lastUpdate.Add(r.cache.ttl))
+	if delay <= 0 {
+		delay = refreshRetryDelay
+		if r.cache.ttl < delay {
+			delay = r.cache.ttl
+		}
+	}
+	return delay
+}
@@ -0,0 +1,130 @@
+// Package model provides tests for the background model refresher.
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// =============================================================================
+// TestCacheSync
+// =============================================================================
+
+func TestCacheSync(t *testing.T) {
+	t.Run("reports added and removed models", func(t *testing.T) {
+		cache := NewCache(newTestConfig())
+
+		added, removed := cache.Sync([]Info{{ModelID: "a"}, {ModelID: "b"}})
+		assert.Equal(t, []string{"a", "b"}, added)
+		assert.Empty(t, removed)
+		assert.False(t, cache.IsStale())
+
+		added, removed = cache.Sync([]Info{{ModelID: "b"}, {ModelID: "c"}})
+		assert.Equal(t, []string{"c"}, added)
+		assert.Equal(t, []string{"a"}, removed)
+		assert.False(t, cache.IsValidModel("a"))
+		assert.True(t, cache.IsValidModel("c"))
+	})
+
+	t.Run("keeps fallback models", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.FallbackModels = []config.ModelInfo{{ModelID: "fallback"}}
+		cache := NewCache(cfg)
+
+		added, _ := cache.Sync([]Info{{ModelID: "fallback"}})
+		assert.Empty(t, added)
+
+		_, removed := cache.Sync([]Info{})
+		assert.Empty(t, removed)
+		assert.True(t, cache.IsValidModel("fallback"))
+	})
+
+	t.Run("reports only models that no longer resolve as removed", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.FallbackModels = []config.ModelInfo{{ModelID: "fallback"}}
+		cache := NewCache(cfg)
+		cache.Sync([]Info{{ModelID: "fallback"}, {ModelID: "gone"}})
+
+		added, removed := cache.Sync([]Info{{ModelID: "new"}})
+
+		assert.Equal(t, []string{"new"}, added)
+		assert.Equal(t, []string{"gone"}, removed)
+		assert.True(t, cache.IsValidModel("fallback"))
+		assert.False(t, cache.IsValidModel("gone"))
+	})
+}
+
+// =============================================================================
+// TestRefresher
+// =============================================================================
+
+func TestRefresher(t *testing.T) {
+	t.Run("refresh updates resolver-visible models", func(t *testing.T) {
+		cfg := newTestConfig()
+		cache := NewCache(cfg)
+		resolver := NewResolver(cache, cfg)
+		refresher := NewRefresher(cache, func() ([]Info, error) {
+			return []Info{{ModelID: "claude-new-model"}}, nil
+		})
+
+		count, added, _, err := refresher.Refresh()
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, count)
+		assert.Equal(t, []string{"claude-new-model"}, added)
+		assert.Equal(t, "cache", resolver.Resolve("claude-new-model").Source)
+		assert.Contains(t, resolver.GetAvailableModels(), "claude-new-model")
+	})
+
+	t.Run("refresh error leaves cache untouched", func(t *testing.T) {
+		cache := NewCache(newTestConfig())
+		cache.Sync([]Info{{ModelID: "a"}})
+		refresher := NewRefresher(cache, func() ([]Info, error) {
+			return nil, errors.New("unavailable")
+		})
+
+		_, _, _, err := refresher.Refresh()
+
+		assert.Error(t, err)
+		assert.True(t, cache.IsValidModel("a"))
+	})
+
+	t.Run("background loop refreshes when the cache goes stale", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.ModelCacheTTL = 0
+		cache := NewCache(cfg)
+		cache.ttl = 20 * time.Millisecond
+
+		fetches := make(chan struct{}, 10)
+		refresher := NewRefresher(cache, func() ([]Info, error) {
+			fetches <- struct{}{}
+			return []Info{{ModelID: "a"}}, nil
+		})
+		refresher.Refresh()
+		<-fetches
+
+		refresher.Start()
+		defer refresher.Stop()
+
+		select {
+		case <-fetches:
+		case <-time.After(time.Second):
+			t.Fatal("expected background refresh")
+		}
+	})
+
+	t.Run("start does nothing without a TTL", func(t *testing.T) {
+		cfg := newTestConfig()
+		cfg.ModelCacheTTL = 0
+		refresher := NewRefresher(NewCache(cfg), func() ([]Info, error) { return nil, nil })
+
+		refresher.Start()
+		refresher.Stop()
+
+		assert.Nil(t, refresher.stopCh)
+	})
+}
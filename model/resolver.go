@@ -25,7 +25,11 @@ type Resolution struct {
 
 // Info represents model information
 type Info struct {
-	ModelID string `json:"modelId"`
+	ModelID             string       `json:"modelId"`
+	ModelName           string       `json:"modelName,omitempty"`
+	Description         string       `json:"description,omitempty"`
+	TokenLimits         *TokenLimits `json:"tokenLimits,omitempty"`
+	SupportedInputTypes []string     `json:"supportedInputTypes,omitempty"`
 }
 
 // Cache provides model information caching
@@ -36,6 +40,10 @@ type Cache struct {
 	lastUpdate   time.Time
 	ttl          time.Duration
 	hiddenModels map[string]string
+
+	// fallback models are always kept; fetched tracks models last reported by Kiro
+	fallback map[string]bool
+	fetched  map[string]bool
 }
 
 // NewCache creates a new model cache
@@ -45,12 +53,15 @@ func NewCache(cfg *config.Config) *Cache {
 		maxInput:     make(map[string]int),
 		ttl:          time.Duration(cfg.ModelCacheTTL) * time.Second,
 		hiddenModels: cfg.HiddenModels,
+		fallback:     make(map[string]bool),
+		fetched:      make(map[string]bool),
 	}
 
 	// Initialize with fallback models
 	for _, m := range cfg.FallbackModels {
 		c.models[m.ModelID] = Info{ModelID: m.ModelID}
 		c.maxInput[m.ModelID] = cfg.MaxInputTokens
+		c.fallback[m.ModelID] = true
 	}
 
 	return c
@@ -72,6 +83,52 @@ func (c *Cache) Update(models []Info) {
 	log.Debugf("Model cache updated with %d models", len(models))
 }
 
+// Sync merges the model list fetched from Kiro into the cache, keeping fallback
+// models, and returns the model IDs that appeared and disappeared since the last
+// sync. These compare the cached model IDs, so a model Kiro no longer lists but
+// that is kept as a fallback model still resolves and is not reported removed.
+func (c *Cache) Sync(models []Info) (added, removed []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	previous := make(map[string]bool, len(c.models))
+	for id := range c.models {
+		previous[id] = true
+	}
+
+	current := make(map[string]bool, len(models))
+	for _, m := range models {
+		current[m.ModelID] = true
+		c.models[m.ModelID] = m
+		if m.TokenLimits != nil && m.TokenLimits.MaxInputTokens > 0 {
+			c.maxInput[m.ModelID] = m.TokenLimits.MaxInputTokens
+		}
+	}
+	for id := range c.fetched {
+		if !current[id] && !c.fallback[id] {
+			delete(c.models, id)
+		}
+	}
+
+	for id := range c.models {
+		if !previous[id] {
+			added = append(added, id)
+		}
+	}
+	for id := range previous {
+		if _, ok := c.models[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+
+	c.fetched = current
+	c.lastUpdate = time.Now()
+
+	sort.Strings(added)
+	sort.Strings(removed)
+	return added, removed
+}
+
 // IsValidModel checks if a model exists in cache
 func (c *Cache) IsValidModel(modelID string) bool {
 	c.mu.RLock()
@@ -163,6 +220,10 @@ type Resolver struct {
 	hiddenModels   map[string]string
 	aliases        map[string]string
 	hiddenFromList map[string]bool
+
+	// mu guards hiddenModels, aliases and hiddenFromList, which can be changed at
+	// runtime via the admin API or a config reload
+	mu sync.RWMutex
 }
 
 // NewResolver creates a new model resolver
@@ -174,14 +235,86 @@ func NewResolver(cache *Cache, cfg *config.Config) *Resolver {
 
 	return &Resolver{
 		cache:          cache,
-		hiddenModels:   cfg.HiddenModels,
-		aliases:        cfg.ModelAliases,
+		hiddenModels:   copyMap(cfg.HiddenModels),
+		aliases:        copyMap(cfg.ModelAliases),
 		hiddenFromList: hiddenFromList,
 	}
 }
 
+// Reload replaces the aliases, hidden models and hidden-from-list IDs with those in cfg,
+// discarding changes made through the admin API
+func (r *Resolver) Reload(cfg *config.Config) {
+	hiddenFromList := make(map[string]bool)
+	for _, id := range cfg.HiddenFromList {
+		hiddenFromList[id] = true
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hiddenModels = copyMap(cfg.HiddenModels)
+	r.aliases = copyMap(cfg.ModelAliases)
+	r.hiddenFromList = hiddenFromList
+}
+
+func copyMap(m map[string]string) map[string]string {
+	result := make(map[string]string, len(m))
+	for k, v := range m {
+		result[k] = v
+	}
+	return result
+}
+
+// Aliases returns a copy of the model aliases
+func (r *Resolver) Aliases() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyMap(r.aliases)
+}
+
+// SetAlias adds or replaces a model alias
+func (r *Resolver) SetAlias(alias, target string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.aliases[alias] = target
+}
+
+// DeleteAlias removes a model alias, reporting whether it existed
+func (r *Resolver) DeleteAlias(alias string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.aliases[alias]
+	delete(r.aliases, alias)
+	return ok
+}
+
+// HiddenModels returns a copy of the hidden model mappings (display name -> internal ID)
+func (r *Resolver) HiddenModels() map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return copyMap(r.hiddenModels)
+}
+
+// SetHiddenModel adds or replaces a hidden model mapping
+func (r *Resolver) SetHiddenModel(displayName, internalID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.hiddenModels[displayName] = internalID
+}
+
+// DeleteHiddenModel removes a hidden model mapping, reporting whether it existed
+func (r *Resolver) DeleteHiddenModel(displayName string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.hiddenModels[displayName]
+	delete(r.hiddenModels, displayName)
+	return ok
+}
+
 // Resolve resolves external model name to internal Kiro ID
 func (r *Resolver) Resolve(externalModel string) *Resolution {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	// Layer 0: Resolve alias
 	resolvedModel := externalModel
 	if alias, ok := r.aliases[externalModel]; ok {
@@ -231,6 +364,9 @@ func (r *Resolver) Resolve(externalModel string) *Resolution {
 
 // GetAvailableModels returns all available model IDs for /v1/models endpoint
 func (r *Resolver) GetAvailableModels() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	models := make(map[string]bool)
 
 	// Add cache models
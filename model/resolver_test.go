@@ -448,6 +448,65 @@ func TestModelResolver(t *testing.T) {
 	})
 }
 
+// =============================================================================
+// TestResolverRuntimeUpdates
+// Tests for changing aliases and hidden models at runtime
+// =============================================================================
+
+func TestResolverRuntimeUpdates(t *testing.T) {
+	t.Run("sets and deletes alias", func(t *testing.T) {
+		cfg := newTestConfig()
+		resolver := NewResolver(NewCache(cfg), cfg)
+
+		resolver.SetAlias("fast", "claude-haiku-4.5")
+		assert.Equal(t, "claude-haiku-4.5", resolver.Resolve("fast").InternalID)
+		assert.Contains(t, resolver.GetAvailableModels(), "fast")
+
+		assert.True(t, resolver.DeleteAlias("fast"))
+		assert.False(t, resolver.DeleteAlias("fast"))
+		assert.Equal(t, "passthrough", resolver.Resolve("fast").Source)
+	})
+
+	t.Run("sets and deletes hidden model", func(t *testing.T) {
+		cfg := newTestConfig()
+		resolver := NewResolver(NewCache(cfg), cfg)
+
+		resolver.SetHiddenModel("display-name", "internal-id")
+		assert.Equal(t, "hidden", resolver.Resolve("display-name").Source)
+
+		assert.True(t, resolver.DeleteHiddenModel("display-name"))
+		assert.Empty(t, resolver.HiddenModels())
+	})
+
+	t.Run("does not modify config maps", func(t *testing.T) {
+		cfg := newTestConfig()
+		resolver := NewResolver(NewCache(cfg), cfg)
+
+		resolver.SetAlias("fast", "claude-haiku-4.5")
+
+		assert.Empty(t, cfg.ModelAliases)
+		aliases := resolver.Aliases()
+		aliases["other"] = "x"
+		assert.NotContains(t, resolver.Aliases(), "other")
+	})
+
+	t.Run("reload replaces runtime changes", func(t *testing.T) {
+		cfg := newTestConfig()
+		resolver := NewResolver(NewCache(cfg), cfg)
+		resolver.SetAlias("fast", "claude-haiku-4.5")
+
+		next := newTestConfig()
+		next.ModelAliases = map[string]string{"smart": "claude-opus-4.5"}
+		next.HiddenModels = map[string]string{"display-name": "internal-id"}
+		next.HiddenFromList = []string{"display-name"}
+		resolver.Reload(next)
+
+		assert.Equal(t, map[string]string{"smart": "claude-opus-4.5"}, resolver.Aliases())
+		assert.Equal(t, "hidden", resolver.Resolve("display-name").Source)
+		assert.NotContains(t, resolver.GetAvailableModels(), "display-name")
+	})
+}
+
 // =============================================================================
 // TestGetModelIDForKiro
 // Tests for GetModelIDForKiro helper function
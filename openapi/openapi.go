@@ -0,0 +1,266 @@
+// Package openapi builds OpenAPI 3 documents. Request and response schemas are
+// derived from Go types by reflection, so the spec follows the structs the
+// handlers actually decode and encode.
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+// Version is the OpenAPI version of the generated documents
+const Version = "3.0.3"
+
+// Schema is a JSON schema object
+type Schema = map[string]interface{}
+
+// Param is a path, query or header parameter of an operation
+type Param struct {
+	Name        string
+	In          string // "path", "query" or "header"
+	Description string
+	Required    bool
+}
+
+// Operation describes one method on one path
+type Operation struct {
+	Summary     string
+	Description string
+	Tags        []string
+	Params      []Param
+
+	// Request is a value of the JSON request body type, nil for no body
+	Request interface{}
+	// Response is a value of the 200 response body type
+	Response interface{}
+	// Streaming documents text/event-stream as an alternative 200 response
+	Streaming bool
+	// Error is a value of the error body type for non-2xx responses
+	Error interface{}
+
+	// Security lists the security schemes any one of which authorizes the call
+	Security []string
+}
+
+// Spec is an OpenAPI document under construction
+type Spec struct {
+	Title       string
+	Version     string
+	Description string
+	// Servers are base URLs; relative URLs resolve against the spec location
+	Servers []string
+	// SecuritySchemes are the components.securitySchemes entries by name
+	SecuritySchemes map[string]Schema
+
+	paths     map[string]map[string]Schema
+	schemas   map[string]Schema
+	overrides map[reflect.Type]Schema
+}
+
+// New creates an empty spec
+func New(title, version string) *Spec {
+	return &Spec{
+		Title:           title,
+		Version:         version,
+		SecuritySchemes: make(map[string]Schema),
+		paths:           make(map[string]map[string]Schema),
+		schemas:         make(map[string]Schema),
+		overrides:       make(map[reflect.Type]Schema),
+	}
+}
+
+// Override sets the schema used for the type of v, for types whose JSON form
+// differs from their Go structure (custom marshalling). Call it before Add.
+func (s *Spec) Override(v interface{}, schema Schema) {
+	s.overrides[reflect.TypeOf(v)] = schema
+}
+
+// Schema returns the schema of the type of v. Named structs are added to
+// components.schemas and referenced. A Schema value is returned unchanged, so
+// hand-written envelopes can embed generated schemas.
+func (s *Spec) Schema(v interface{}) Schema {
+	if schema, ok := v.(Schema); ok {
+		return schema
+	}
+	return s.schemaOf(reflect.TypeOf(v))
+}
+
+// Add documents an operation. method is an HTTP method, path uses {param}
+// placeholders.
+func (s *Spec) Add(method, path string, op Operation) {
+	operation := Schema{"summary": op.Summary}
+	if op.Description != "" {
+		operation["description"] = op.Description
+	}
+	if len(op.Tags) > 0 {
+		operation["tags"] = op.Tags
+	}
+	if len(op.Params) > 0 {
+		var params []Schema
+		for _, p := range op.Params {
+			param := Schema{"name": p.Name, "in": p.In, "required": p.Required || p.In == "path", "schema": Schema{"type": "string"}}
+			if p.Description != "" {
+				param["description"] = p.Description
+			}
+			params = append(params, param)
+		}
+		operation["parameters"] = params
+	}
+	if op.Request != nil {
+		operation["requestBody"] = Schema{
+			"required": true,
+			"content":  Schema{"application/json": Schema{"schema": s.Schema(op.Request)}},
+		}
+	}
+
+	ok := Schema{"description": "Successful response"}
+	content := Schema{}
+	if op.Response != nil {
+		content["application/json"] = Schema{"schema": s.Schema(op.Response)}
+	}
+	if op.Streaming {
+		content["text/event-stream"] = Schema{"schema": Schema{"type": "string", "description": "Server-sent events, sent when the request sets stream"}}
+	}
+	if len(content) > 0 {
+		ok["content"] = content
+	}
+	responses := Schema{"200": ok}
+	if op.Error != nil {
+		responses["default"] = Schema{
+			"description": "Error",
+			"content":     Schema{"application/json": Schema{"schema": s.Schema(op.Error)}},
+		}
+	}
+	operation["responses"] = responses
+
+	if len(op.Security) > 0 {
+		var security []Schema
+		for _, name := range op.Security {
+			security = append(security, Schema{name: []string{}})
+		}
+		operation["security"] = security
+	}
+
+	if s.paths[path] == nil {
+		s.paths[path] = make(map[string]Schema)
+	}
+	s.paths[path][strings.ToLower(method)] = operation
+}
+
+// MarshalJSON encodes the document
+func (s *Spec) MarshalJSON() ([]byte, error) {
+	info := Schema{"title": s.Title, "version": s.Version}
+	if s.Description != "" {
+		info["description"] = s.Description
+	}
+	doc := Schema{
+		"openapi":    Version,
+		"info":       info,
+		"paths":      s.paths,
+		"components": Schema{"schemas": s.schemas, "securitySchemes": s.SecuritySchemes},
+	}
+	if len(s.Servers) > 0 {
+		var servers []Schema
+		for _, url := range s.Servers {
+			servers = append(servers, Schema{"url": url})
+		}
+		doc["servers"] = servers
+	}
+	return json.Marshal(doc)
+}
+
+var rawMessageType = reflect.TypeOf(json.RawMessage(nil))
+
+// schemaOf returns the schema of t
+func (s *Spec) schemaOf(t reflect.Type) Schema {
+	if schema, ok := s.overrides[t]; ok {
+		return schema
+	}
+	if t == rawMessageType {
+		return Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return s.schemaOf(t.Elem())
+	case reflect.Interface:
+		// Any JSON value
+		return Schema{}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number"}
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": s.schemaOf(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": s.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.structSchema(t)
+		}
+		if _, ok := s.schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			s.schemas[t.Name()] = Schema{}
+			s.schemas[t.Name()] = s.structSchema(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return Schema{}
+}
+
+// structSchema returns the object schema of a struct from its json tags.
+// Fields without omitempty are required unless they are pointers, booleans,
+// maps or interfaces, whose zero value reads as absent.
+func (s *Spec) structSchema(t reflect.Type) Schema {
+	properties := Schema{}
+	var required []string
+	s.addFields(t, properties, &required)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// addFields adds the properties of the fields of t, flattening embedded structs
+func (s *Spec) addFields(t reflect.Type, properties Schema, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			s.addFields(field.Type, properties, required)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = s.schemaOf(field.Type)
+		if strings.Contains(opts, "omitempty") {
+			continue
+		}
+		switch field.Type.Kind() {
+		case reflect.Ptr, reflect.Bool, reflect.Map, reflect.Interface:
+		default:
+			*required = append(*required, name)
+		}
+	}
+}
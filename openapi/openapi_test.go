@@ -0,0 +1,105 @@
+// Package openapi provides tests for OpenAPI document generation.
+package openapi
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testItem struct {
+	Name  string   `json:"name"`
+	Tags  []string `json:"tags,omitempty"`
+	Count *int     `json:"count,omitempty"`
+	Next  *testItem
+	skip  string
+	Extra map[string]interface{} `json:"extra"`
+	Raw   json.RawMessage        `json:"raw,omitempty"`
+	Drop  string                 `json:"-"`
+	testEmbedded
+}
+
+type testEmbedded struct {
+	Inline int64 `json:"inline"`
+}
+
+type testCustom struct{}
+
+// =============================================================================
+// TestSchema
+// =============================================================================
+
+func TestSchema(t *testing.T) {
+	t.Run("named structs become components", func(t *testing.T) {
+		spec := New("test", "1")
+
+		assert.Equal(t, Schema{"$ref": "#/components/schemas/testItem"}, spec.Schema(testItem{}))
+		item := spec.schemas["testItem"]
+		properties := item["properties"].(Schema)
+		assert.Equal(t, Schema{"type": "string"}, properties["name"])
+		assert.Equal(t, Schema{"type": "array", "items": Schema{"type": "string"}}, properties["tags"])
+		assert.Equal(t, Schema{"type": "integer"}, properties["count"])
+		assert.Equal(t, Schema{"$ref": "#/components/schemas/testItem"}, properties["Next"])
+		assert.Equal(t, Schema{"type": "object", "additionalProperties": Schema{}}, properties["extra"])
+		assert.Equal(t, Schema{}, properties["raw"])
+		assert.Equal(t, Schema{"type": "integer", "format": "int64"}, properties["inline"])
+		assert.NotContains(t, properties, "skip")
+		assert.NotContains(t, properties, "Drop")
+		assert.Equal(t, []string{"name", "inline"}, item["required"])
+	})
+
+	t.Run("overrides replace a type's schema", func(t *testing.T) {
+		spec := New("test", "1")
+		spec.Override(testCustom{}, Schema{"type": "string"})
+
+		assert.Equal(t, Schema{"type": "string"}, spec.Schema(testCustom{}))
+		assert.Equal(t, Schema{"type": "array", "items": Schema{"type": "string"}}, spec.Schema([]testCustom{}))
+	})
+
+	t.Run("schemas pass through", func(t *testing.T) {
+		spec := New("test", "1")
+		assert.Equal(t, Schema{"type": "boolean"}, spec.Schema(Schema{"type": "boolean"}))
+	})
+}
+
+// =============================================================================
+// TestSpec
+// =============================================================================
+
+func TestSpec(t *testing.T) {
+	spec := New("Test API", "1.2.3")
+	spec.Servers = []string{"../"}
+	spec.SecuritySchemes["bearerAuth"] = Schema{"type": "http", "scheme": "bearer"}
+	spec.Add("POST", "/items/{id}", Operation{
+		Summary:   "Update an item",
+		Params:    []Param{{Name: "id", In: "path"}},
+		Request:   testItem{},
+		Response:  testItem{},
+		Streaming: true,
+		Error:     Schema{"type": "object"},
+		Security:  []string{"bearerAuth"},
+	})
+
+	data, err := json.Marshal(spec)
+	assert.NoError(t, err)
+
+	var doc map[string]interface{}
+	assert.NoError(t, json.Unmarshal(data, &doc))
+	assert.Equal(t, Version, doc["openapi"])
+	assert.Equal(t, "1.2.3", doc["info"].(map[string]interface{})["version"])
+	assert.Equal(t, "../", doc["servers"].([]interface{})[0].(map[string]interface{})["url"])
+
+	op := doc["paths"].(map[string]interface{})["/items/{id}"].(map[string]interface{})["post"].(map[string]interface{})
+	assert.Equal(t, "Update an item", op["summary"])
+	assert.Equal(t, true, op["parameters"].([]interface{})[0].(map[string]interface{})["required"])
+	content := op["responses"].(map[string]interface{})["200"].(map[string]interface{})["content"].(map[string]interface{})
+	assert.Contains(t, content, "application/json")
+	assert.Contains(t, content, "text/event-stream")
+	assert.Contains(t, op["responses"], "default")
+	assert.Equal(t, []interface{}{map[string]interface{}{"bearerAuth": []interface{}{}}}, op["security"])
+
+	components := doc["components"].(map[string]interface{})
+	assert.Contains(t, components["schemas"], "testItem")
+	assert.Contains(t, components["securitySchemes"], "bearerAuth")
+}
@@ -2,6 +2,7 @@
 package parser
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"regexp"
@@ -22,6 +23,7 @@ const (
 	EventTypeToolStop     EventType = "tool_stop"
 	EventTypeUsage        EventType = "usage"
 	EventTypeContextUsage EventType = "context_usage"
+	EventTypeModel        EventType = "model"
 )
 
 // Event represents a parsed event
@@ -48,9 +50,14 @@ type ToolInputData struct {
 	Input interface{}
 }
 
+// ToolStopData represents the end of a tool call
+type ToolStopData struct {
+	ToolUseID string
+}
+
 // UsageData represents usage event data
 type UsageData struct {
-	Credits int `json:"credits"`
+	Credits float64 `json:"credits"`
 }
 
 // ContextUsageData represents context usage percentage
@@ -58,11 +65,19 @@ type ContextUsageData struct {
 	Percentage float64
 }
 
+// ModelData identifies the model Kiro used, e.g. for the "auto" model
+type ModelData struct {
+	ModelID string
+}
+
 // ToolCall represents a completed tool call
 type ToolCall struct {
 	ID       string          `json:"id"`
 	Type     string          `json:"type"`
 	Function ToolCallFunction `json:"function"`
+
+	// Truncated is set when the arguments were cut off and had to be repaired
+	Truncated bool `json:"-"`
 }
 
 // ToolCallFunction represents function details
@@ -71,12 +86,47 @@ type ToolCallFunction struct {
 	Arguments string `json:"arguments"`
 }
 
+// eventPatterns are the JSON prefixes of the events in a Kiro stream
+var eventPatterns = []struct {
+	prefix []byte
+	t      EventType
+}{
+	{[]byte(`{"content":`), EventTypeContent},
+	{[]byte(`{"name":`), EventTypeToolStart},
+	{[]byte(`{"input":`), EventTypeToolInput},
+	{[]byte(`{"stop":`), EventTypeToolStop},
+	{[]byte(`{"usage":`), EventTypeUsage},
+	{[]byte(`{"contextUsagePercentage":`), EventTypeContextUsage},
+	{[]byte(`{"modelId":`), EventTypeModel},
+}
+
+// objectStart begins every event pattern
+var objectStart = []byte(`{"`)
+
+// compactThreshold is the number of consumed bytes after which Feed moves the
+// unparsed tail to the front of the buffer instead of growing it
+const compactThreshold = 64 * 1024
+
 // AwsEventStreamParser parses AWS Event Stream format
 type AwsEventStreamParser struct {
-	buffer          string
-	lastContent     *string
+	// buffer holds stream bytes; those before start are consumed
+	buffer []byte
+	start  int
+	// pending is set while the event at start is incomplete, with the brace
+	// scan resumed where the previous chunk ended
+	pending     bool
+	pendingType EventType
+	scan        braceScanner
+
+	lastContent *string
+	// partialRune holds the start of a multi-byte character cut off at the
+	// end of the last content event
+	partialRune     []byte
 	currentToolCall *ToolCall
 	toolCalls       []ToolCall
+
+	// When set, tool start/input/stop are also returned from Feed as they arrive
+	emitToolEvents bool
 }
 
 // NewAwsEventStreamParser creates a new parser
@@ -86,52 +136,45 @@ func NewAwsEventStreamParser() *AwsEventStreamParser {
 	}
 }
 
-// Feed adds a chunk to the buffer and returns parsed events
+// EnableToolEvents makes Feed return tool start/input/stop events for incremental streaming.
+// Tool calls are still collected and available from GetToolCalls.
+func (p *AwsEventStreamParser) EnableToolEvents() {
+	p.emitToolEvents = true
+}
+
+// Feed adds a chunk to the buffer and returns parsed events. Each byte is
+// scanned once, so the cost is linear in the stream length even when an event
+// spans many chunks.
 func (p *AwsEventStreamParser) Feed(chunk []byte) []Event {
-	p.buffer += string(chunk)
+	p.compact()
+	p.buffer = append(p.buffer, chunk...)
 	var events []Event
 
 	for {
-		// Find the earliest pattern
-		earliestPos := -1
-		var earliestType EventType
-
-		patterns := []struct {
-			pattern string
-			t       EventType
-		}{
-			{`{"content":`, EventTypeContent},
-			{`{"name":`, EventTypeToolStart},
-			{`{"input":`, EventTypeToolInput},
-			{`{"stop":`, EventTypeToolStop},
-			{`{"usage":`, EventTypeUsage},
-			{`{"contextUsagePercentage":`, EventTypeContextUsage},
-		}
-
-		for _, pat := range patterns {
-			pos := strings.Index(p.buffer, pat.pattern)
-			if pos != -1 && (earliestPos == -1 || pos < earliestPos) {
-				earliestPos = pos
-				earliestType = pat.t
+		if !p.pending {
+			pos, eventType, ok := findEvent(p.buffer[p.start:])
+			p.start += pos
+			if !ok {
+				break
 			}
-		}
-
-		if earliestPos == -1 {
-			break
+			p.pending = true
+			p.pendingType = eventType
+			p.scan = braceScanner{}
 		}
 
 		// Find JSON end
-		jsonEnd := FindMatchingBrace(p.buffer, earliestPos)
+		jsonEnd := p.scan.scan(p.buffer[p.start:])
 		if jsonEnd == -1 {
 			break // JSON not complete, wait for more data
 		}
 
-		jsonStr := p.buffer[earliestPos : jsonEnd+1]
-		p.buffer = p.buffer[jsonEnd+1:]
+		data := p.buffer[p.start : p.start+jsonEnd+1]
+		p.start += jsonEnd + 1
+		p.pending = false
 
-		event, err := p.processEvent(jsonStr, earliestType)
+		event, err := p.processEvent(data, p.pendingType)
 		if err != nil {
-			log.Warnf("Failed to parse JSON: %v (data: %.100s...)", err, jsonStr)
+			log.Warnf("Failed to parse JSON: %v (data: %.100s...)", err, data)
 			continue
 		}
 
@@ -143,32 +186,78 @@ func (p *AwsEventStreamParser) Feed(chunk []byte) []Event {
 	return events
 }
 
+// compact drops consumed bytes, moving the unparsed tail to the front of the
+// buffer once enough has been consumed for the copy to pay off
+func (p *AwsEventStreamParser) compact() {
+	if p.start == len(p.buffer) {
+		p.buffer = p.buffer[:0]
+		p.start = 0
+		return
+	}
+	if p.start >= compactThreshold || p.start > len(p.buffer)/2 {
+		p.buffer = p.buffer[:copy(p.buffer, p.buffer[p.start:])]
+		p.start = 0
+	}
+}
+
+// findEvent returns the offset and type of the first event in data. When none
+// is found, pos is where unparsed data starts: the beginning of a pattern split
+// across chunks, or len(data) when nothing needs to be kept.
+func findEvent(data []byte) (pos int, eventType EventType, ok bool) {
+	for i := 0; i < len(data); {
+		j := bytes.Index(data[i:], objectStart)
+		if j == -1 {
+			break
+		}
+		i += j
+
+		rest := data[i:]
+		for _, pat := range eventPatterns {
+			if bytes.HasPrefix(rest, pat.prefix) {
+				return i, pat.t, true
+			}
+			if len(rest) < len(pat.prefix) && bytes.HasPrefix(pat.prefix, rest) {
+				return i, "", false
+			}
+		}
+		i++
+	}
+
+	// A trailing '{' may be the start of the next event
+	if n := len(data); n > 0 && data[n-1] == '{' {
+		return n - 1, "", false
+	}
+	return len(data), "", false
+}
+
 // processEvent processes a parsed JSON event
-func (p *AwsEventStreamParser) processEvent(jsonStr string, eventType EventType) (*Event, error) {
+func (p *AwsEventStreamParser) processEvent(data []byte, eventType EventType) (*Event, error) {
 	switch eventType {
 	case EventTypeContent:
-		return p.processContentEvent(jsonStr)
+		return p.processContentEvent(data)
 	case EventTypeToolStart:
-		return p.processToolStartEvent(jsonStr)
+		return p.processToolStartEvent(data)
 	case EventTypeToolInput:
-		return p.processToolInputEvent(jsonStr)
+		return p.processToolInputEvent(data)
 	case EventTypeToolStop:
-		return p.processToolStopEvent(jsonStr)
+		return p.processToolStopEvent(data)
 	case EventTypeUsage:
-		return p.processUsageEvent(jsonStr)
+		return p.processUsageEvent(data)
 	case EventTypeContextUsage:
-		return p.processContextUsageEvent(jsonStr)
+		return p.processContextUsageEvent(data)
+	case EventTypeModel:
+		return p.processModelEvent(data)
 	}
 	return nil, nil
 }
 
-func (p *AwsEventStreamParser) processContentEvent(jsonStr string) (*Event, error) {
+func (p *AwsEventStreamParser) processContentEvent(raw []byte) (*Event, error) {
 	var data struct {
-		Content       string `json:"content"`
-		FollowupPrompt string `json:"followupPrompt"`
+		Content        json.RawMessage `json:"content"`
+		FollowupPrompt string          `json:"followupPrompt"`
 	}
 
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return nil, err
 	}
 
@@ -177,20 +266,47 @@ func (p *AwsEventStreamParser) processContentEvent(jsonStr string) (*Event, erro
 		return nil, nil
 	}
 
+	var content []byte
+	if len(data.Content) > 0 && data.Content[0] == '"' {
+		var err error
+		if content, err = decodeJSONString(data.Content); err != nil {
+			return nil, err
+		}
+	}
+
 	// Deduplicate repeating content
-	if p.lastContent != nil && data.Content == *p.lastContent {
+	if p.lastContent != nil && string(content) == *p.lastContent {
 		return nil, nil
 	}
+	lastContent := string(content)
+	p.lastContent = &lastContent
 
-	p.lastContent = &data.Content
+	// Kiro may split a multi-byte character across events: hold the start of
+	// an incomplete character until the rest arrives
+	content = append(p.partialRune, content...)
+	content, p.partialRune = splitIncompleteRune(content)
+	if len(content) == 0 {
+		return nil, nil
+	}
 
 	return &Event{
 		Type: EventTypeContent,
-		Data: ContentData{Content: data.Content},
+		Data: ContentData{Content: strings.ToValidUTF8(string(content), "\uFFFD")},
 	}, nil
 }
 
-func (p *AwsEventStreamParser) processToolStartEvent(jsonStr string) (*Event, error) {
+// Flush returns the content held back as an incomplete character, for the end
+// of the stream. The bytes are invalid UTF-8 and become a replacement character.
+func (p *AwsEventStreamParser) Flush() []Event {
+	if len(p.partialRune) == 0 {
+		return nil
+	}
+	content := strings.ToValidUTF8(string(p.partialRune), "\uFFFD")
+	p.partialRune = nil
+	return []Event{{Type: EventTypeContent, Data: ContentData{Content: content}}}
+}
+
+func (p *AwsEventStreamParser) processToolStartEvent(raw []byte) (*Event, error) {
 	// Finalize previous tool call if exists
 	if p.currentToolCall != nil {
 		p.finalizeToolCall()
@@ -203,7 +319,7 @@ func (p *AwsEventStreamParser) processToolStartEvent(jsonStr string) (*Event, er
 		Stop      bool        `json:"stop"`
 	}
 
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return nil, err
 	}
 
@@ -225,14 +341,30 @@ func (p *AwsEventStreamParser) processToolStartEvent(jsonStr string) (*Event, er
 		},
 	}
 
+	if p.emitToolEvents && p.currentToolCall.ID == "" {
+		p.currentToolCall.ID = utils.GenerateToolCallID()
+	}
+	toolUseID := p.currentToolCall.ID
+
 	if data.Stop {
 		p.finalizeToolCall()
 	}
 
-	return nil, nil
+	if !p.emitToolEvents {
+		return nil, nil
+	}
+	return &Event{
+		Type: EventTypeToolStart,
+		Data: ToolStartData{
+			Name:      data.Name,
+			ToolUseID: toolUseID,
+			Input:     inputStr,
+			Stop:      data.Stop,
+		},
+	}, nil
 }
 
-func (p *AwsEventStreamParser) processToolInputEvent(jsonStr string) (*Event, error) {
+func (p *AwsEventStreamParser) processToolInputEvent(raw []byte) (*Event, error) {
 	if p.currentToolCall == nil {
 		return nil, nil
 	}
@@ -241,7 +373,7 @@ func (p *AwsEventStreamParser) processToolInputEvent(jsonStr string) (*Event, er
 		Input interface{} `json:"input"`
 	}
 
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return nil, err
 	}
 
@@ -255,31 +387,47 @@ func (p *AwsEventStreamParser) processToolInputEvent(jsonStr string) (*Event, er
 	}
 
 	p.currentToolCall.Function.Arguments += inputStr
-	return nil, nil
+
+	if !p.emitToolEvents || inputStr == "" {
+		return nil, nil
+	}
+	return &Event{
+		Type: EventTypeToolInput,
+		Data: ToolInputData{Input: inputStr},
+	}, nil
 }
 
-func (p *AwsEventStreamParser) processToolStopEvent(jsonStr string) (*Event, error) {
+func (p *AwsEventStreamParser) processToolStopEvent(raw []byte) (*Event, error) {
 	var data struct {
 		Stop bool `json:"stop"`
 	}
 
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return nil, err
 	}
 
-	if p.currentToolCall != nil && data.Stop {
-		p.finalizeToolCall()
+	if p.currentToolCall == nil || !data.Stop {
+		return nil, nil
 	}
 
-	return nil, nil
+	toolUseID := p.currentToolCall.ID
+	p.finalizeToolCall()
+
+	if !p.emitToolEvents {
+		return nil, nil
+	}
+	return &Event{
+		Type: EventTypeToolStop,
+		Data: ToolStopData{ToolUseID: toolUseID},
+	}, nil
 }
 
-func (p *AwsEventStreamParser) processUsageEvent(jsonStr string) (*Event, error) {
+func (p *AwsEventStreamParser) processUsageEvent(raw []byte) (*Event, error) {
 	var data struct {
-		Usage int `json:"usage"`
+		Usage float64 `json:"usage"`
 	}
 
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return nil, err
 	}
 
@@ -289,12 +437,12 @@ func (p *AwsEventStreamParser) processUsageEvent(jsonStr string) (*Event, error)
 	}, nil
 }
 
-func (p *AwsEventStreamParser) processContextUsageEvent(jsonStr string) (*Event, error) {
+func (p *AwsEventStreamParser) processContextUsageEvent(raw []byte) (*Event, error) {
 	var data struct {
 		ContextUsagePercentage float64 `json:"contextUsagePercentage"`
 	}
 
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
+	if err := json.Unmarshal(raw, &data); err != nil {
 		return nil, err
 	}
 
@@ -304,6 +452,21 @@ func (p *AwsEventStreamParser) processContextUsageEvent(jsonStr string) (*Event,
 	}, nil
 }
 
+func (p *AwsEventStreamParser) processModelEvent(raw []byte) (*Event, error) {
+	var data struct {
+		ModelID string `json:"modelId"`
+	}
+
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, err
+	}
+
+	return &Event{
+		Type: EventTypeModel,
+		Data: ModelData{ModelID: data.ModelID},
+	}, nil
+}
+
 func (p *AwsEventStreamParser) finalizeToolCall() {
 	if p.currentToolCall == nil {
 		return
@@ -321,9 +484,14 @@ func (p *AwsEventStreamParser) finalizeToolCall() {
 			// Re-serialize to ensure valid JSON
 			b, _ := json.Marshal(parsed)
 			p.currentToolCall.Function.Arguments = string(b)
+		} else if repaired, ok := RepairTruncatedJSON(args); ok {
+			log.Warnf("Tool '%s' arguments were cut off after %d bytes and repaired", toolName, len(args))
+			p.currentToolCall.Function.Arguments = repaired
+			p.currentToolCall.Truncated = true
 		} else {
 			log.Warnf("Failed to parse tool '%s' arguments: %v", toolName, err)
 			p.currentToolCall.Function.Arguments = "{}"
+			p.currentToolCall.Truncated = true
 		}
 	} else {
 		p.currentToolCall.Function.Arguments = "{}"
@@ -348,8 +516,11 @@ func (p *AwsEventStreamParser) GetToolCalls() []ToolCall {
 
 // Reset resets the parser state
 func (p *AwsEventStreamParser) Reset() {
-	p.buffer = ""
+	p.buffer = p.buffer[:0]
+	p.start = 0
+	p.pending = false
 	p.lastContent = nil
+	p.partialRune = nil
 	p.currentToolCall = nil
 	p.toolCalls = make([]ToolCall, 0)
 }
@@ -360,35 +531,53 @@ func FindMatchingBrace(text string, startPos int) int {
 		return -1
 	}
 
-	braceCount := 0
-	inString := false
-	escapeNext := false
+	var scanner braceScanner
+	end := scanner.scan([]byte(text[startPos:]))
+	if end == -1 {
+		return -1
+	}
+	return startPos + end
+}
+
+// braceScanner finds the brace closing the JSON object at the start of its
+// input. State is kept between calls so an object arriving in pieces is scanned
+// once: each call continues from where the previous one stopped and must be
+// given the same input extended with new data.
+type braceScanner struct {
+	pos        int
+	braceCount int
+	inString   bool
+	escapeNext bool
+}
 
-	for i := startPos; i < len(text); i++ {
-		char := text[i]
+// scan returns the offset of the closing brace in data, or -1 if the object is
+// not complete yet
+func (s *braceScanner) scan(data []byte) int {
+	for ; s.pos < len(data); s.pos++ {
+		char := data[s.pos]
 
-		if escapeNext {
-			escapeNext = false
+		if s.escapeNext {
+			s.escapeNext = false
 			continue
 		}
 
-		if char == '\\' && inString {
-			escapeNext = true
+		if char == '\\' && s.inString {
+			s.escapeNext = true
 			continue
 		}
 
-		if char == '"' && !escapeNext {
-			inString = !inString
+		if char == '"' {
+			s.inString = !s.inString
 			continue
 		}
 
-		if !inString {
+		if !s.inString {
 			if char == '{' {
-				braceCount++
+				s.braceCount++
 			} else if char == '}' {
-				braceCount--
-				if braceCount == 0 {
-					return i
+				s.braceCount--
+				if s.braceCount == 0 {
+					return s.pos
 				}
 			}
 		}
@@ -457,6 +646,7 @@ func DeduplicateToolCalls(toolCalls []ToolCall) []ToolCall {
 
 	// Deduplicate by ID - keep tool call with non-empty arguments
 	byID := make(map[string]ToolCall)
+	var ids []string
 	for _, tc := range toolCalls {
 		if tc.ID == "" {
 			continue
@@ -465,6 +655,7 @@ func DeduplicateToolCalls(toolCalls []ToolCall) []ToolCall {
 		existing, ok := byID[tc.ID]
 		if !ok {
 			byID[tc.ID] = tc
+			ids = append(ids, tc.ID)
 		} else {
 			// Prefer non-empty arguments
 			if tc.Function.Arguments != "{}" && (existing.Function.Arguments == "{}" ||
@@ -474,10 +665,10 @@ func DeduplicateToolCalls(toolCalls []ToolCall) []ToolCall {
 		}
 	}
 
-	// Collect tool calls with ID
+	// Collect tool calls with ID, in the order they were made
 	var result []ToolCall
-	for _, tc := range byID {
-		result = append(result, tc)
+	for _, id := range ids {
+		result = append(result, byID[id])
 	}
 
 	// Add tool calls without ID
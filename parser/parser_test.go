@@ -2,7 +2,10 @@
 package parser
 
 import (
+	"encoding/json"
+	"strings"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -172,6 +175,20 @@ func TestDeduplicateToolCalls(t *testing.T) {
 		assert.Equal(t, "first", result[0].ID)
 	})
 
+	t.Run("keeps the order the calls were made", func(t *testing.T) {
+		toolCalls := []ToolCall{
+			{ID: "c", Function: ToolCallFunction{Name: "third", Arguments: "{}"}},
+			{ID: "a", Function: ToolCallFunction{Name: "first", Arguments: "{}"}},
+			{ID: "b", Function: ToolCallFunction{Name: "second", Arguments: "{}"}},
+			{ID: "a", Function: ToolCallFunction{Name: "first", Arguments: `{"x": 1}`}},
+		}
+		result := DeduplicateToolCalls(toolCalls)
+
+		assert.Len(t, result, 3)
+		assert.Equal(t, []string{"c", "a", "b"}, []string{result[0].ID, result[1].ID, result[2].ID})
+		assert.Contains(t, result[1].Function.Arguments, "x")
+	})
+
 	t.Run("handles empty list", func(t *testing.T) {
 		// Original: test_handles_empty_list
 		result := DeduplicateToolCalls(nil)
@@ -261,7 +278,7 @@ func TestAwsEventStreamParser_Initialization(t *testing.T) {
 	// Original: test_initialization_creates_empty_state
 	parser := NewAwsEventStreamParser()
 
-	assert.Equal(t, "", parser.buffer)
+	assert.Empty(t, parser.buffer[parser.start:])
 	assert.Nil(t, parser.lastContent)
 	assert.Nil(t, parser.currentToolCall)
 	assert.Empty(t, parser.toolCalls)
@@ -310,15 +327,14 @@ func TestAwsEventStreamParser_Feed(t *testing.T) {
 
 	t.Run("parses usage event", func(t *testing.T) {
 		// Original: test_parses_usage_event
-		// Note: Go version uses int for Credits, Python uses float
 		parser := NewAwsEventStreamParser()
-		chunk := []byte(`{"usage":42}`)
+		chunk := []byte(`{"usage":0.42}`)
 
 		events := parser.Feed(chunk)
 
 		assert.Len(t, events, 1)
 		assert.Equal(t, EventTypeUsage, events[0].Type)
-		assert.Equal(t, 42, events[0].Data.(UsageData).Credits)
+		assert.Equal(t, 0.42, events[0].Data.(UsageData).Credits)
 	})
 
 	t.Run("parses context usage event", func(t *testing.T) {
@@ -333,6 +349,17 @@ func TestAwsEventStreamParser_Feed(t *testing.T) {
 		assert.Equal(t, 25.5, events[0].Data.(ContextUsageData).Percentage)
 	})
 
+	t.Run("parses model event", func(t *testing.T) {
+		parser := NewAwsEventStreamParser()
+		chunk := []byte(`{"modelId":"claude-sonnet-4.5"}`)
+
+		events := parser.Feed(chunk)
+
+		assert.Len(t, events, 1)
+		assert.Equal(t, EventTypeModel, events[0].Type)
+		assert.Equal(t, "claude-sonnet-4.5", events[0].Data.(ModelData).ModelID)
+	})
+
 	t.Run("handles incomplete JSON", func(t *testing.T) {
 		// Original: test_handles_incomplete_json
 		parser := NewAwsEventStreamParser()
@@ -341,7 +368,7 @@ func TestAwsEventStreamParser_Feed(t *testing.T) {
 		events := parser.Feed(chunk)
 
 		assert.Len(t, events, 0) // Nothing parsed
-		assert.Contains(t, parser.buffer, "content")
+		assert.Contains(t, string(parser.buffer[parser.start:]), "content")
 	})
 
 	t.Run("completes JSON across chunks", func(t *testing.T) {
@@ -441,6 +468,30 @@ func TestAwsEventStreamParser_ToolCalls(t *testing.T) {
 		assert.Len(t, toolCalls, 1)
 		assert.Nil(t, parser.currentToolCall)
 	})
+
+	t.Run("repairs truncated arguments", func(t *testing.T) {
+		parser := NewAwsEventStreamParser()
+		parser.Feed([]byte(`{"name":"write_file","toolUseId":"call_1"}`))
+		parser.Feed([]byte(`{"input":"{\"path\": \"a.txt\", \"content\": \"hel"}`))
+
+		toolCalls := parser.GetToolCalls()
+
+		assert.Len(t, toolCalls, 1)
+		assert.True(t, toolCalls[0].Truncated)
+		assert.JSONEq(t, `{"path": "a.txt", "content": "hel"}`, toolCalls[0].Function.Arguments)
+	})
+
+	t.Run("complete arguments are not truncated", func(t *testing.T) {
+		parser := NewAwsEventStreamParser()
+		parser.Feed([]byte(`{"name":"func","toolUseId":"call_1"}`))
+		parser.Feed([]byte(`{"input":"{\"a\": 1}"}`))
+		parser.Feed([]byte(`{"stop":true}`))
+
+		toolCalls := parser.GetToolCalls()
+
+		assert.Len(t, toolCalls, 1)
+		assert.False(t, toolCalls[0].Truncated)
+	})
 }
 
 // =============================================================================
@@ -456,7 +507,7 @@ func TestAwsEventStreamParser_Reset(t *testing.T) {
 
 	parser.Reset()
 
-	assert.Equal(t, "", parser.buffer)
+	assert.Empty(t, parser.buffer[parser.start:])
 	assert.Nil(t, parser.lastContent)
 	assert.Nil(t, parser.currentToolCall)
 	assert.Empty(t, parser.toolCalls)
@@ -511,3 +562,169 @@ func TestAwsEventStreamParser_EdgeCases(t *testing.T) {
 		assert.Empty(t, events)
 	})
 }
+
+// =============================================================================
+// TestAwsEventStreamParserToolEvents
+// Tests for incremental tool events used by Anthropic streaming
+// =============================================================================
+
+func TestAwsEventStreamParser_ToolEvents(t *testing.T) {
+	t.Run("emits tool events when enabled", func(t *testing.T) {
+		parser := NewAwsEventStreamParser()
+		parser.EnableToolEvents()
+
+		events := parser.Feed([]byte(`{"name":"func","toolUseId":"call_1"}{"input":"{\"a\":"}{"input":"1}"}{"stop":true}`))
+
+		assert.Len(t, events, 4)
+		assert.Equal(t, EventTypeToolStart, events[0].Type)
+		assert.Equal(t, "call_1", events[0].Data.(ToolStartData).ToolUseID)
+		assert.Equal(t, "{\"a\":", events[1].Data.(ToolInputData).Input)
+		assert.Equal(t, EventTypeToolStop, events[3].Type)
+		assert.Len(t, parser.GetToolCalls(), 1)
+	})
+
+	t.Run("generates id at tool start", func(t *testing.T) {
+		parser := NewAwsEventStreamParser()
+		parser.EnableToolEvents()
+
+		events := parser.Feed([]byte(`{"name":"func"}{"stop":true}`))
+
+		assert.Len(t, events, 2)
+		id := events[0].Data.(ToolStartData).ToolUseID
+		assert.NotEmpty(t, id)
+		assert.Equal(t, id, events[1].Data.(ToolStopData).ToolUseID)
+		assert.Equal(t, id, parser.GetToolCalls()[0].ID)
+	})
+}
+
+// =============================================================================
+// BenchmarkAwsEventStreamParser_Feed
+// =============================================================================
+
+// benchmarkStream builds a Kiro response of events framed by binary headers
+func benchmarkStream(events []string) []byte {
+	var stream []byte
+	for _, event := range events {
+		stream = append(stream, "\x00\x00\x01\x0b\x00\x00\x00\x8b\r:event-type\x07\x00\x16assistantResponseEvent"...)
+		stream = append(stream, event...)
+		stream = append(stream, "\x8f\x1a\x3c\x02"...)
+	}
+	return stream
+}
+
+// feedChunks feeds stream to a new parser in chunks of size bytes
+func feedChunks(stream []byte, size int) int {
+	parser := NewAwsEventStreamParser()
+	events := 0
+	for start := 0; start < len(stream); start += size {
+		end := start + size
+		if end > len(stream) {
+			end = len(stream)
+		}
+		events += len(parser.Feed(stream[start:end]))
+	}
+	return events + len(parser.GetToolCalls())
+}
+
+func BenchmarkAwsEventStreamParser_Feed(b *testing.B) {
+	// A long text response: many small content events
+	var content []string
+	for i := 0; i < 5000; i++ {
+		content = append(content, `{"content":"token `+strings.Repeat("x", i%20)+` "}`)
+	}
+	contentStream := benchmarkStream(content)
+
+	// A large tool call whose arguments arrive as one event spanning many chunks
+	file, _ := json.Marshal(map[string]string{"path": "main.go", "content": strings.Repeat("func main() { fmt.Println(\"hi\") }\n", 25000)})
+	args, _ := json.Marshal(string(file))
+	toolStream := benchmarkStream([]string{`{"name":"write_file","toolUseId":"call_1"}`, `{"input":` + string(args) + `}`, `{"stop":true}`})
+
+	b.Run("many small events", func(b *testing.B) {
+		b.SetBytes(int64(len(contentStream)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			feedChunks(contentStream, 4096)
+		}
+	})
+
+	b.Run("one large event", func(b *testing.B) {
+		b.SetBytes(int64(len(toolStream)))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			feedChunks(toolStream, 4096)
+		}
+	})
+}
+
+// =============================================================================
+// TestAwsEventStreamParser_UTF8
+// Tests for multi-byte characters split across chunks and events
+// =============================================================================
+
+func TestAwsEventStreamParser_UTF8(t *testing.T) {
+	// contentOf joins the content of the events
+	contentOf := func(events []Event) string {
+		var b strings.Builder
+		for _, event := range events {
+			if data, ok := event.Data.(ContentData); ok {
+				b.WriteString(data.Content)
+			}
+		}
+		return b.String()
+	}
+
+	for _, text := range []string{"你好，世界", "こんにちは", "Hi 👋🏽 there 🎉"} {
+		t.Run("keeps characters split across chunks: "+text, func(t *testing.T) {
+			stream := []byte(`{"content":"` + text + `"}`)
+
+			// Every split point, including those inside a character
+			for i := 1; i < len(stream); i++ {
+				parser := NewAwsEventStreamParser()
+				events := parser.Feed(stream[:i])
+				events = append(events, parser.Feed(stream[i:])...)
+				events = append(events, parser.Flush()...)
+
+				assert.Equal(t, text, contentOf(events), "split at byte %d", i)
+			}
+		})
+
+		t.Run("joins characters split across events: "+text, func(t *testing.T) {
+			raw := []byte(text)
+
+			for i := 1; i < len(raw); i++ {
+				parser := NewAwsEventStreamParser()
+				var stream []byte
+				stream = append(stream, `{"content":"`...)
+				stream = append(stream, raw[:i]...)
+				stream = append(stream, `"}{"content":"`...)
+				stream = append(stream, raw[i:]...)
+				stream = append(stream, `"}`...)
+
+				events := append(parser.Feed(stream), parser.Flush()...)
+
+				assert.Equal(t, text, contentOf(events), "split at byte %d", i)
+				for _, event := range events {
+					assert.True(t, utf8.ValidString(event.Data.(ContentData).Content))
+				}
+			}
+		})
+	}
+
+	t.Run("flushes an incomplete character as a replacement", func(t *testing.T) {
+		parser := NewAwsEventStreamParser()
+
+		events := parser.Feed([]byte("{\"content\":\"ok\xe4\xbd\"}"))
+		assert.Equal(t, "ok", contentOf(events))
+
+		assert.Equal(t, "�", contentOf(parser.Flush()))
+		assert.Empty(t, parser.Flush())
+	})
+
+	t.Run("decodes escaped characters", func(t *testing.T) {
+		parser := NewAwsEventStreamParser()
+
+		events := parser.Feed([]byte(`{"content":"\u4f60\u597d \ud83c\udf89\n\"quoted\""}`))
+
+		assert.Equal(t, "你好 🎉\n\"quoted\"", contentOf(events))
+	})
+}
@@ -0,0 +1,130 @@
+// Package parser provides parsers for AWS Event Stream format and thinking blocks.
+package parser
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RepairTruncatedJSON completes JSON that was cut off mid-document, as happens when
+// Kiro hits its output limit while streaming tool arguments. Open strings are closed,
+// a dangling key gets a null value, partial literals are completed and open objects
+// and arrays are closed. It reports false when the result is still not valid JSON.
+func RepairTruncatedJSON(text string) (string, bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return "", false
+	}
+
+	var stack []byte
+	inString := false
+	escapeNext := false
+	// awaitingColon is set after an object key, until its ':' is seen
+	awaitingColon := false
+	// expectKey is set when the next string in the current object is a key
+	expectKey := false
+
+	for i := 0; i < len(text); i++ {
+		char := text[i]
+
+		if inString {
+			switch {
+			case escapeNext:
+				escapeNext = false
+			case char == '\\':
+				escapeNext = true
+			case char == '"':
+				inString = false
+				if expectKey {
+					expectKey = false
+					awaitingColon = true
+				}
+			}
+			continue
+		}
+
+		switch char {
+		case '"':
+			inString = true
+		case '{':
+			stack = append(stack, '{')
+			expectKey = true
+		case '[':
+			stack = append(stack, '[')
+			expectKey = false
+		case '}', ']':
+			if len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+			expectKey = false
+		case ':':
+			awaitingColon = false
+		case ',':
+			expectKey = len(stack) > 0 && stack[len(stack)-1] == '{'
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(text)
+
+	if inString {
+		repaired := text
+		if escapeNext {
+			repaired = repaired[:len(repaired)-1]
+		}
+		b.Reset()
+		b.WriteString(repaired)
+		b.WriteByte('"')
+		if expectKey {
+			awaitingColon = true
+		}
+	} else {
+		repaired := completeTrailingToken(text)
+		b.Reset()
+		b.WriteString(repaired)
+	}
+
+	repaired := strings.TrimRight(b.String(), " \t\r\n")
+	switch {
+	case awaitingColon:
+		repaired += ":null"
+	case strings.HasSuffix(repaired, ":"):
+		repaired += "null"
+	case strings.HasSuffix(repaired, ","):
+		repaired = strings.TrimSuffix(repaired, ",")
+	}
+
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == '{' {
+			repaired += "}"
+		} else {
+			repaired += "]"
+		}
+	}
+
+	if !json.Valid([]byte(repaired)) {
+		return "", false
+	}
+	return repaired, true
+}
+
+// completeTrailingToken finishes a partial true/false/null literal and strips an
+// incomplete number exponent or fraction at the end of text
+func completeTrailingToken(text string) string {
+	end := len(text)
+	start := end
+	for start > 0 && strings.IndexByte("abcdefghijklmnopqrstuvwxyz0123456789.+-E", text[start-1]) != -1 {
+		start--
+	}
+	token := text[start:end]
+	if token == "" {
+		return text
+	}
+
+	for _, literal := range []string{"true", "false", "null"} {
+		if strings.HasPrefix(literal, token) {
+			return text[:start] + literal
+		}
+	}
+	return text[:start] + strings.TrimRight(token, ".+-eE")
+}
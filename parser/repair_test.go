@@ -0,0 +1,45 @@
+// Package parser provides tests for truncated JSON repair.
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestRepairTruncatedJSON
+// =============================================================================
+
+func TestRepairTruncatedJSON(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+		ok    bool
+	}{
+		{name: "complete JSON unchanged", input: `{"a": 1}`, want: `{"a": 1}`, ok: true},
+		{name: "open string value", input: `{"path": "/tmp/fi`, want: `{"path": "/tmp/fi"}`, ok: true},
+		{name: "open string ending in escape", input: `{"s": "line\`, want: `{"s": "line"}`, ok: true},
+		{name: "open key", input: `{"a": 1, "pa`, want: `{"a": 1, "pa":null}`, ok: true},
+		{name: "key without colon", input: `{"a": 1, "path"`, want: `{"a": 1, "path":null}`, ok: true},
+		{name: "key with colon", input: `{"path":`, want: `{"path":null}`, ok: true},
+		{name: "trailing comma", input: `{"a": [1, 2,`, want: `{"a": [1, 2]}`, ok: true},
+		{name: "partial literal", input: `{"force": tr`, want: `{"force": true}`, ok: true},
+		{name: "partial number", input: `{"n": 1.`, want: `{"n": 1}`, ok: true},
+		{name: "nested containers", input: `{"a": {"b": [{"c": "d`, want: `{"a": {"b": [{"c": "d"}]}}`, ok: true},
+		{name: "string value in array", input: `["a", "b`, want: `["a", "b"]`, ok: true},
+		{name: "empty input", input: ``, ok: false},
+		{name: "not JSON", input: `hello world`, ok: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := RepairTruncatedJSON(tt.input)
+			assert.Equal(t, tt.ok, ok)
+			if tt.ok {
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@
 package parser
 
 import (
+	"fmt"
 	"strings"
 
 	log "github.com/sirupsen/logrus"
@@ -25,10 +26,36 @@ type ThinkingParseResult struct {
 	IsLastThinkingChunk    bool
 }
 
+// DefaultThinkingTags are the thinking tags recognized when none are configured
+var DefaultThinkingTags = []string{"<thinking>", "alettek", "<reasoning>", "<thought>"}
+
+// ParseThinkingTag splits a thinking tag spec into its open and close tags. A spec
+// is "open|close", or just the open tag when the close tag follows from it:
+// "<thought>" closes with "</thought>", a tag not starting with "<" with itself.
+func ParseThinkingTag(spec string) (string, string, error) {
+	open, closeTag, paired := strings.Cut(spec, "|")
+	if !paired {
+		closeTag = closeTagFor(open)
+	}
+	if open == "" || closeTag == "" || strings.Contains(closeTag, "|") {
+		return "", "", fmt.Errorf("invalid thinking tag %q: expected \"open\" or \"open|close\"", spec)
+	}
+	return open, closeTag, nil
+}
+
+// closeTagFor derives the close tag of a spec without one
+func closeTagFor(openTag string) string {
+	if strings.HasPrefix(openTag, "<") {
+		return "</" + openTag[1:]
+	}
+	return openTag
+}
+
 // ThinkingParser parses thinking/reasoning blocks from model output
 type ThinkingParser struct {
 	handlingMode      ThinkingHandlingMode
 	openTags          []string
+	closeTags         map[string]string
 	initialBufferSize int
 	foundThinking     bool
 
@@ -42,17 +69,35 @@ type ThinkingParser struct {
 	thinkingStarted   bool
 	thinkingEnded     bool
 	firstThinkingSent bool
+
+	// pending is the start of a close tag at the end of the last chunk, held back
+	// until the next chunk shows whether the tag is complete
+	pending string
 }
 
-// NewThinkingParser creates a new thinking parser
-func NewThinkingParser(handlingMode ThinkingHandlingMode, openTags []string, initialBufferSize int) *ThinkingParser {
-	if len(openTags) == 0 {
-		openTags = []string{"<thinking>", "alettek", "<reasoning>", "<thought>"}
+// NewThinkingParser creates a new thinking parser. tags are thinking tag specs
+// (see ParseThinkingTag); invalid ones are skipped.
+func NewThinkingParser(handlingMode ThinkingHandlingMode, tags []string, initialBufferSize int) *ThinkingParser {
+	if len(tags) == 0 {
+		tags = DefaultThinkingTags
+	}
+
+	openTags := make([]string, 0, len(tags))
+	closeTags := make(map[string]string, len(tags))
+	for _, spec := range tags {
+		open, closeTag, err := ParseThinkingTag(spec)
+		if err != nil {
+			log.Warnf("Skipping %v", err)
+			continue
+		}
+		openTags = append(openTags, open)
+		closeTags[open] = closeTag
 	}
 
 	return &ThinkingParser{
 		handlingMode:      handlingMode,
 		openTags:          openTags,
+		closeTags:         closeTags,
 		initialBufferSize: initialBufferSize,
 	}
 }
@@ -98,7 +143,7 @@ func (p *ThinkingParser) checkForThinkingTag(result *ThinkingParseResult) {
 			p.foundThinking = true
 			p.inThinking = true
 			p.thinkingTagOpen = tag
-			p.thinkingTagClose = p.getCloseTag(tag)
+			p.thinkingTagClose = p.closeTags[tag]
 
 			log.Debugf("Found thinking tag: %s", tag)
 
@@ -123,15 +168,19 @@ func (p *ThinkingParser) checkForThinkingTag(result *ThinkingParseResult) {
 		}
 	}
 
-	// No tag found, pass buffer through
-	result.RegularContent = p.buffer
-	p.buffer = ""
+	// No tag found, pass buffer through except the start of an open tag split
+	// across chunks, which waits for the rest
+	held := heldTagPrefix(p.buffer, p.openTags...)
+	result.RegularContent = p.buffer[:len(p.buffer)-held]
+	p.buffer = p.buffer[len(p.buffer)-held:]
 }
 
 func (p *ThinkingParser) processThinkingContent(content string, result *ThinkingParseResult) {
 	if p.thinkingTagClose == "" {
 		return
 	}
+	content = p.pending + content
+	p.pending = ""
 
 	// Check for closing tag
 	if strings.Contains(content, p.thinkingTagClose) {
@@ -152,6 +201,13 @@ func (p *ThinkingParser) processThinkingContent(content string, result *Thinking
 
 		log.Debug("Thinking block processing completed")
 	} else {
+		// The start of a close tag split across chunks waits for the rest
+		held := heldTagPrefix(content, p.thinkingTagClose)
+		content, p.pending = content[:len(content)-held], content[len(content)-held:]
+		if content == "" {
+			return
+		}
+
 		p.thinkingContent += content
 		result.ThinkingContent = p.processForOutput(content, !p.firstThinkingSent, false)
 		if !p.firstThinkingSent {
@@ -161,21 +217,6 @@ func (p *ThinkingParser) processThinkingContent(content string, result *Thinking
 	}
 }
 
-func (p *ThinkingParser) getCloseTag(openTag string) string {
-	switch openTag {
-	case "alettek":
-		return "alettek"
-	case "<thinking>":
-		return "</thinking>"
-	case "<reasoning>":
-		return "</reasoning>"
-	case "<thought>":
-		return "</thought>"
-	default:
-		return "</" + openTag[1:]
-	}
-}
-
 // processForOutput processes content for output based on handling mode
 func (p *ThinkingParser) processForOutput(content string, isFirst, isLast bool) string {
 	switch p.handlingMode {
@@ -210,9 +251,12 @@ func (p *ThinkingParser) Finalize() *ThinkingParseResult {
 		p.buffer = ""
 	}
 
-	// If we're still in thinking, close it
+	// If we're still in thinking, close it; held back text was not a close tag
+	// after all. Everything else was already returned by Feed.
 	if p.inThinking {
-		result.ThinkingContent = p.processForOutput(p.thinkingContent, !p.firstThinkingSent, true)
+		p.thinkingContent += p.pending
+		result.ThinkingContent = p.processForOutput(p.pending, !p.firstThinkingSent, true)
+		p.pending = ""
 		result.IsLastThinkingChunk = true
 		p.inThinking = false
 		p.thinkingEnded = true
@@ -221,6 +265,21 @@ func (p *ThinkingParser) Finalize() *ThinkingParseResult {
 	return result
 }
 
+// heldTagPrefix returns the length of the longest end of s that is the start of
+// one of tags, but not a whole tag
+func heldTagPrefix(s string, tags ...string) int {
+	held := 0
+	for _, tag := range tags {
+		for n := min(len(tag)-1, len(s)); n > held; n-- {
+			if strings.HasSuffix(s, tag[:n]) {
+				held = n
+				break
+			}
+		}
+	}
+	return held
+}
+
 // FoundThinkingBlock returns whether a thinking block was found
 func (p *ThinkingParser) FoundThinkingBlock() bool {
 	return p.foundThinking
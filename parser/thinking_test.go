@@ -150,15 +150,37 @@ func TestThinkingParser_FeedPreContent(t *testing.T) {
 
 	t.Run("completes partial tag", func(t *testing.T) {
 		// Original: test_completes_partial_tag
-		// Note: Go implementation requires the complete tag in a single feed call
-		// or the buffer to be large enough to accumulate all chunks before checking.
-		// This test documents that behavior - provide complete tag in one chunk.
 		parser := NewThinkingParser(ThinkingHandlingAsReasoningContent, nil, 1)
 
-		// Feed complete tag in one chunk
-		_ = parser.Feed("<thinking>Hello")
+		first := parser.Feed("<thin")
+		second := parser.Feed("king>Hello")
+
+		assert.Equal(t, "", first.RegularContent)
 		assert.True(t, parser.foundThinking)
 		assert.Equal(t, "<thinking>", parser.thinkingTagOpen)
+		assert.Equal(t, "Hello", second.ThinkingContent)
+	})
+
+	t.Run("holds a partial tag after the initial buffer", func(t *testing.T) {
+		parser := NewThinkingParser(ThinkingHandlingAsReasoningContent, nil, 5)
+
+		first := parser.Feed("\n\n<reaso")
+		second := parser.Feed("ning>Plan")
+
+		assert.Equal(t, "\n\n", first.RegularContent)
+		assert.Equal(t, "<reasoning>", parser.thinkingTagOpen)
+		assert.Equal(t, "Plan", second.ThinkingContent)
+	})
+
+	t.Run("releases held text that is not a tag", func(t *testing.T) {
+		parser := NewThinkingParser(ThinkingHandlingAsReasoningContent, nil, 1)
+
+		first := parser.Feed("a <")
+		second := parser.Feed("b> c")
+
+		assert.Equal(t, "a ", first.RegularContent)
+		assert.Equal(t, "<b> c", second.RegularContent)
+		assert.False(t, parser.foundThinking)
 	})
 
 	t.Run("no tag passes content through", func(t *testing.T) {
@@ -224,17 +246,43 @@ func TestThinkingParser_FeedInThinking(t *testing.T) {
 
 	t.Run("split closing tag", func(t *testing.T) {
 		// Original: test_split_closing_tag
-		// Note: Go implementation looks for exact closing tag match in content
-		// Provide complete closing tag in one chunk
 		parser := NewThinkingParser(ThinkingHandlingAsReasoningContent, nil, 1)
 		parser.Feed("<thinking>Hello")
 
-		// Feed complete closing tag with regular content
-		result := parser.Feed("</thinking>World")
+		first := parser.Feed(" there</thin")
+		second := parser.Feed("king>World")
 
+		assert.Equal(t, " there", first.ThinkingContent)
 		assert.False(t, parser.inThinking)
 		assert.True(t, parser.thinkingEnded)
-		assert.Equal(t, "World", result.RegularContent)
+		assert.True(t, second.IsLastThinkingChunk)
+		assert.Equal(t, "World", second.RegularContent)
+		assert.Equal(t, "Hello there", parser.thinkingContent)
+	})
+
+	t.Run("closing tag split over several chunks", func(t *testing.T) {
+		parser := NewThinkingParser(ThinkingHandlingPass, nil, 1)
+		var thinking, regular string
+		for _, chunk := range []string{"<thinking>Hm", "<", "/", "thinking", ">", "Done"} {
+			result := parser.Feed(chunk)
+			thinking += result.ThinkingContent
+			regular += result.RegularContent
+		}
+
+		assert.Equal(t, "<thinking>Hm</thinking>", thinking)
+		assert.Equal(t, "Done", regular)
+	})
+
+	t.Run("releases held text that is not the closing tag", func(t *testing.T) {
+		parser := NewThinkingParser(ThinkingHandlingAsReasoningContent, nil, 1)
+		parser.Feed("<thinking>Hello")
+
+		first := parser.Feed(" a </th")
+		second := parser.Feed("ird> b")
+
+		assert.Equal(t, " a ", first.ThinkingContent)
+		assert.Equal(t, "</third> b", second.ThinkingContent)
+		assert.True(t, parser.inThinking)
 	})
 }
 
@@ -276,11 +324,13 @@ func TestThinkingParser_Finalize(t *testing.T) {
 	t.Run("flushes thinking buffer", func(t *testing.T) {
 		// Original: test_flushes_thinking_buffer
 		parser := NewThinkingParser(ThinkingHandlingAsReasoningContent, nil, 1)
-		parser.Feed("<thinking>Incomplete thinking")
+		fed := parser.Feed("<thinking>Incomplete thinking</thi")
 
 		result := parser.Finalize()
 
-		assert.NotEmpty(t, result.ThinkingContent)
+		// Only the held partial close tag is new; the rest was returned by Feed
+		assert.Equal(t, "Incomplete thinking", fed.ThinkingContent)
+		assert.Equal(t, "</thi", result.ThinkingContent)
 		assert.True(t, result.IsLastThinkingChunk)
 	})
 
@@ -527,3 +577,39 @@ func TestThinkingParser_EdgeCases(t *testing.T) {
 		assert.Equal(t, " Third", result3.RegularContent)
 	})
 }
+
+// =============================================================================
+// TestParseThinkingTag
+// =============================================================================
+
+func TestParseThinkingTag(t *testing.T) {
+	tests := []struct {
+		spec, open, close string
+	}{
+		{"<thought>", "<thought>", "</thought>"},
+		{"<think>|</think>", "<think>", "</think>"},
+		{"[[REASON]]|[[/REASON]]", "[[REASON]]", "[[/REASON]]"},
+		{"alettek", "alettek", "alettek"},
+	}
+	for _, tt := range tests {
+		open, closeTag, err := ParseThinkingTag(tt.spec)
+		assert.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.open, open, tt.spec)
+		assert.Equal(t, tt.close, closeTag, tt.spec)
+	}
+
+	for _, spec := range []string{"", "|</think>", "<think>|", "<a>|<b>|<c>"} {
+		_, _, err := ParseThinkingTag(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestThinkingParser_CustomTagPairs(t *testing.T) {
+	parser := NewThinkingParser(ThinkingHandlingAsReasoningContent, []string{"[[REASON]]|[[/REASON]]", "<bad>|"}, 1)
+
+	result := parser.Feed("[[REASON]]Plan it[[/REASON]]Answer")
+
+	assert.Equal(t, []string{"[[REASON]]"}, parser.openTags)
+	assert.Equal(t, "Plan it", result.ThinkingContent)
+	assert.Equal(t, "Answer", result.RegularContent)
+}
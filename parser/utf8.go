@@ -0,0 +1,98 @@
+// Package parser provides parsers for AWS Event Stream format and thinking blocks.
+package parser
+
+import (
+	"errors"
+	"strconv"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// errInvalidJSONString is returned for a malformed JSON string literal
+var errInvalidJSONString = errors.New("invalid JSON string")
+
+// decodeJSONString decodes a JSON string literal. Unlike encoding/json it keeps
+// invalid UTF-8 bytes as they are, so a character split across two events can
+// be joined again instead of becoming two replacement characters.
+func decodeJSONString(raw []byte) ([]byte, error) {
+	if len(raw) < 2 || raw[0] != '"' || raw[len(raw)-1] != '"' {
+		return nil, errInvalidJSONString
+	}
+	s := raw[1 : len(raw)-1]
+	out := make([]byte, 0, len(s))
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			out = append(out, s[i])
+			continue
+		}
+		i++
+		if i == len(s) {
+			return nil, errInvalidJSONString
+		}
+		switch s[i] {
+		case '"', '\\', '/':
+			out = append(out, s[i])
+		case 'b':
+			out = append(out, '\b')
+		case 'f':
+			out = append(out, '\f')
+		case 'n':
+			out = append(out, '\n')
+		case 'r':
+			out = append(out, '\r')
+		case 't':
+			out = append(out, '\t')
+		case 'u':
+			r, ok := hexRune(s[i+1:])
+			if !ok {
+				return nil, errInvalidJSONString
+			}
+			i += 4
+			// Characters outside the BMP are escaped as a surrogate pair
+			if utf16.IsSurrogate(r) {
+				high := r
+				r = unicode.ReplacementChar
+				if i+6 < len(s) && s[i+1] == '\\' && s[i+2] == 'u' {
+					if low, ok := hexRune(s[i+3:]); ok {
+						if pair := utf16.DecodeRune(high, low); pair != unicode.ReplacementChar {
+							r = pair
+							i += 6
+						}
+					}
+				}
+			}
+			out = utf8.AppendRune(out, r)
+		default:
+			return nil, errInvalidJSONString
+		}
+	}
+	return out, nil
+}
+
+// hexRune parses the four hex digits of a \u escape at the start of s
+func hexRune(s []byte) (rune, bool) {
+	if len(s) < 4 {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(string(s[:4]), 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return rune(v), true
+}
+
+// splitIncompleteRune splits off a UTF-8 sequence cut short at the end of b
+func splitIncompleteRune(b []byte) (complete, partial []byte) {
+	for i := len(b) - 1; i >= 0 && i > len(b)-utf8.UTFMax; i-- {
+		if !utf8.RuneStart(b[i]) {
+			continue
+		}
+		if !utf8.FullRune(b[i:]) {
+			return b[:i], b[i:]
+		}
+		break
+	}
+	return b, nil
+}
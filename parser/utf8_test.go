@@ -0,0 +1,71 @@
+// Package parser provides tests for UTF-8 safe content decoding.
+package parser
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestDecodeJSONString
+// =============================================================================
+
+func TestDecodeJSONString(t *testing.T) {
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"plain text", `"Hello"`, "Hello"},
+		{"escapes", `"a\"b\\c\/d\n\t\r\b\f"`, "a\"b\\c/d\n\t\r\b\f"},
+		{"unicode escape", `"\u00e9\u4f60"`, "é你"},
+		{"surrogate pair", `"\ud83d\ude00"`, "😀"},
+		{"lone surrogate", `"\ud83dx"`, "�x"},
+		{"raw UTF-8", `"你好 🎉"`, "你好 🎉"},
+		{"invalid UTF-8 kept", "\"a\xe4\xbd\"", "a\xe4\xbd"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeJSONString([]byte(tt.raw))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+
+	for _, raw := range []string{``, `"`, `abc`, `"\"`, `"\x"`, `"\u12"`, `"\uzzzz"`} {
+		t.Run("rejects "+raw, func(t *testing.T) {
+			_, err := decodeJSONString([]byte(raw))
+			assert.Error(t, err)
+		})
+	}
+}
+
+// =============================================================================
+// TestSplitIncompleteRune
+// =============================================================================
+
+func TestSplitIncompleteRune(t *testing.T) {
+	tests := []struct {
+		name     string
+		in       string
+		complete string
+		partial  string
+	}{
+		{"ASCII", "abc", "abc", ""},
+		{"complete CJK", "你好", "你好", ""},
+		{"one byte of three", "a\xe4", "a", "\xe4"},
+		{"two bytes of three", "a\xe4\xbd", "a", "\xe4\xbd"},
+		{"three bytes of four", "a\xf0\x9f\x8e", "a", "\xf0\x9f\x8e"},
+		{"only a partial character", "\xf0\x9f", "", "\xf0\x9f"},
+		{"stray continuation byte", "a\xbd", "a\xbd", ""},
+		{"empty", "", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			complete, partial := splitIncompleteRune([]byte(tt.in))
+			assert.Equal(t, tt.complete, string(complete))
+			assert.Equal(t, tt.partial, string(partial))
+		})
+	}
+}
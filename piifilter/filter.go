@@ -0,0 +1,233 @@
+// Package piifilter finds sensitive data in text sent to the Kiro API.
+//
+// With PII_FILTER_MODE set, every request is scanned for the patterns in
+// PII_FILTER_PATTERNS before it leaves the proxy: builtin patterns for email
+// addresses, AWS credentials and credit card numbers, plus the operator's own
+// regular expressions. Matches are redacted, make the request fail or are only
+// logged, depending on the mode.
+package piifilter
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Filter modes
+const (
+	ModeOff    = "off"
+	ModeRedact = "redact"
+	ModeBlock  = "block"
+	ModeLog    = "log"
+)
+
+// builtinPatterns are the patterns PII_FILTER_PATTERNS can name without defining them
+var builtinPatterns = map[string]string{
+	"email":          `[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}`,
+	"aws_access_key": `\b(?:AKIA|ASIA|AGPA|AIDA|AROA|ANPA|ANVA|AIPA)[A-Z0-9]{16}\b`,
+	"aws_secret_key": `(?i)\baws_?secret_?access_?key\b["']?\s*[:=]\s*["']?[A-Za-z0-9/+]{40}\b`,
+	"credit_card":    `\b\d(?:[ -]?\d){12,18}\b`,
+}
+
+// checks rule out matches a pattern alone cannot
+var checks = map[string]func(string) bool{
+	"credit_card": cardNumber,
+}
+
+// cardGroups are the digit groups card numbers are written in, e.g. 4-4-4-4
+// for most cards and 4-6-5 for American Express
+var cardGroups = [][]int{{4, 4, 4, 4}, {4, 6, 5}, {4, 6, 4}, {4, 4, 4, 4, 3}}
+
+// cardPrefixes are the issuer prefix ranges (IINs) of the major card networks
+// with their number lengths
+var cardPrefixes = []struct {
+	low, high      string
+	minLen, maxLen int
+}{
+	{"4", "4", 13, 19},       // Visa
+	{"51", "55", 16, 16},     // Mastercard
+	{"2221", "2720", 16, 16}, // Mastercard
+	{"34", "34", 15, 15},     // American Express
+	{"37", "37", 15, 15},     // American Express
+	{"300", "305", 14, 19},   // Diners Club
+	{"36", "36", 14, 19},     // Diners Club
+	{"38", "39", 16, 19},     // Diners Club
+	{"6011", "6011", 16, 19}, // Discover
+	{"644", "649", 16, 19},   // Discover
+	{"65", "65", 16, 19},     // Discover
+	{"3528", "3589", 16, 19}, // JCB
+	{"62", "62", 16, 19},     // UnionPay
+}
+
+// compiled caches compiled patterns by source so each is compiled once
+var compiled sync.Map
+
+// BuiltinPatterns returns the names of the builtin patterns
+func BuiltinPatterns() []string {
+	names := make([]string, 0, len(builtinPatterns))
+	for name := range builtinPatterns {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidMode reports whether mode is a filter mode; empty means off
+func ValidMode(mode string) bool {
+	switch mode {
+	case "", ModeOff, ModeRedact, ModeBlock, ModeLog:
+		return true
+	}
+	return false
+}
+
+type pattern struct {
+	name  string
+	re    *regexp.Regexp
+	check func(string) bool
+}
+
+// Filter finds the matches of a set of patterns
+type Filter struct {
+	patterns []pattern
+}
+
+// New creates a filter for the named patterns. A name is looked up in custom
+// (name to regular expression) first, then in the builtin patterns.
+func New(names []string, custom map[string]string) (*Filter, error) {
+	f := &Filter{}
+	for _, name := range names {
+		source, ok := custom[name]
+		if !ok {
+			if source, ok = builtinPatterns[name]; !ok {
+				return nil, fmt.Errorf("unknown PII pattern %q", name)
+			}
+		}
+		re, err := compile(source)
+		if err != nil {
+			return nil, fmt.Errorf("invalid PII pattern %q: %v", name, err)
+		}
+		f.patterns = append(f.patterns, pattern{name: name, re: re, check: checks[name]})
+	}
+	return f, nil
+}
+
+func compile(source string) (*regexp.Regexp, error) {
+	if re, ok := compiled.Load(source); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(source)
+	if err != nil {
+		return nil, err
+	}
+	compiled.Store(source, re)
+	return re, nil
+}
+
+// Redact replaces every match in text with "[REDACTED:<pattern>]" and adds the
+// number of matches of each pattern to found
+func (f *Filter) Redact(text string, found map[string]int) string {
+	for _, p := range f.patterns {
+		text = p.re.ReplaceAllStringFunc(text, func(match string) string {
+			if p.check != nil && !p.check(match) {
+				return match
+			}
+			found[p.name]++
+			return "[REDACTED:" + p.name + "]"
+		})
+	}
+	return text
+}
+
+// BlockedError is returned for a request that PII_FILTER_MODE=block stopped
+type BlockedError struct {
+	// Patterns are the names of the patterns that matched
+	Patterns []string
+}
+
+func (e *BlockedError) Error() string {
+	return fmt.Sprintf("Request contains sensitive data (%s) and was blocked", strings.Join(e.Patterns, ", "))
+}
+
+// Names returns the pattern names in found, sorted
+func Names(found map[string]int) []string {
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// cardNumber reports whether a credit_card match is likely a card number: it
+// must pass the Luhn checksum and either be written in the digit groups of
+// cards or start with the prefix of a card network, so order numbers, phone
+// numbers and IDs that happen to pass the checksum are left alone
+func cardNumber(s string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, s)
+	return luhnValid(digits) && (cardGrouped(s) || cardPrefixed(digits))
+}
+
+// cardGrouped reports whether s is written in one of cardGroups with a single
+// kind of separator
+func cardGrouped(s string) bool {
+	for _, sep := range []string{" ", "-"} {
+		parts := strings.Split(s, sep)
+		for _, groups := range cardGroups {
+			if len(parts) != len(groups) {
+				continue
+			}
+			matches := true
+			for i, part := range parts {
+				if len(part) != groups[i] || strings.ContainsAny(part, " -") {
+					matches = false
+					break
+				}
+			}
+			if matches {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// cardPrefixed reports whether digits have the prefix and length of a card network
+func cardPrefixed(digits string) bool {
+	for _, p := range cardPrefixes {
+		if len(digits) < p.minLen || len(digits) > p.maxLen {
+			continue
+		}
+		prefix := digits[:len(p.low)]
+		if prefix >= p.low && prefix <= p.high {
+			return true
+		}
+	}
+	return false
+}
+
+// luhnValid reports whether the digits in s pass the Luhn checksum of card numbers
+func luhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		digit := int(s[i] - '0')
+		if double {
+			if digit *= 2; digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+)
+
+// lockoutPruneSize is the number of tracked clients above which expired entries are dropped
+const lockoutPruneSize = 1024
+
+// lockoutEntry tracks the failed attempts of one client
+type lockoutEntry struct {
+	failures    int
+	windowStart time.Time
+	lockedUntil time.Time
+}
+
+// Lockout counts failed authentication attempts per client (IP address) and
+// locks a client out once it reaches AUTH_MAX_FAILURES within
+// AUTH_FAILURE_WINDOW, for AUTH_LOCKOUT_DURATION
+type Lockout struct {
+	cfg     *config.Config
+	clients map[string]*lockoutEntry
+	now     func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewLockout creates a lockout tracker using the AUTH_* settings from cfg
+func NewLockout(cfg *config.Config) *Lockout {
+	return &Lockout{
+		cfg:     cfg,
+		clients: make(map[string]*lockoutEntry),
+		now:     time.Now,
+	}
+}
+
+// Locked reports whether the client is locked out and for how much longer
+func (l *Lockout) Locked(client string) (bool, time.Duration) {
+	if l.cfg.AuthMaxFailures <= 0 {
+		return false, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e, ok := l.clients[client]
+	if !ok {
+		return false, 0
+	}
+	if remaining := e.lockedUntil.Sub(l.now()); remaining > 0 {
+		return true, remaining
+	}
+	return false, 0
+}
+
+// Failure records a failed attempt. It returns the failures within the current
+// window and whether this attempt locked the client out.
+func (l *Lockout) Failure(client string) (int, bool) {
+	if l.cfg.AuthMaxFailures <= 0 {
+		return 0, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	window := time.Duration(l.cfg.AuthFailureWindow) * time.Second
+	if len(l.clients) >= lockoutPruneSize {
+		l.prune(now, window)
+	}
+
+	e, ok := l.clients[client]
+	if !ok || now.Sub(e.windowStart) >= window {
+		e = &lockoutEntry{windowStart: now}
+		l.clients[client] = e
+	}
+	e.failures++
+
+	if e.failures < l.cfg.AuthMaxFailures {
+		return e.failures, false
+	}
+	failures := e.failures
+	// The next window starts when the lockout ends
+	e.failures = 0
+	e.lockedUntil = now.Add(time.Duration(l.cfg.AuthLockoutDuration) * time.Second)
+	e.windowStart = e.lockedUntil
+	return failures, true
+}
+
+// Success clears the failed attempts of a client that authenticated
+func (l *Lockout) Success(client string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if e, ok := l.clients[client]; ok && !e.lockedUntil.After(l.now()) {
+		delete(l.clients, client)
+	}
+}
+
+// prune drops clients that are neither locked out nor within a failure window
+func (l *Lockout) prune(now time.Time, window time.Duration) {
+	for client, e := range l.clients {
+		if !e.lockedUntil.After(now) && now.Sub(e.windowStart) >= window {
+			delete(l.clients, client)
+		}
+	}
+}
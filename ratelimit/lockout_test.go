@@ -0,0 +1,103 @@
+// Package ratelimit provides tests for authentication failure lockout.
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// newTestLockout creates a lockout with a controllable clock
+func newTestLockout(cfg *config.Config) (*Lockout, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	l := NewLockout(cfg)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+// =============================================================================
+// TestLockout
+// =============================================================================
+
+func TestLockout(t *testing.T) {
+	cfg := &config.Config{AuthMaxFailures: 3, AuthFailureWindow: 60, AuthLockoutDuration: 300}
+
+	t.Run("locks out after max failures", func(t *testing.T) {
+		l, now := newTestLockout(cfg)
+
+		for i := 1; i < 3; i++ {
+			failures, locked := l.Failure("1.2.3.4")
+			assert.Equal(t, i, failures)
+			assert.False(t, locked)
+		}
+		failures, locked := l.Failure("1.2.3.4")
+		assert.Equal(t, 3, failures)
+		assert.True(t, locked)
+
+		locked, retryAfter := l.Locked("1.2.3.4")
+		assert.True(t, locked)
+		assert.Equal(t, 300*time.Second, retryAfter)
+
+		locked, _ = l.Locked("5.6.7.8")
+		assert.False(t, locked)
+
+		*now = now.Add(300 * time.Second)
+		locked, _ = l.Locked("1.2.3.4")
+		assert.False(t, locked)
+
+		// Counting starts over after the lockout
+		_, locked = l.Failure("1.2.3.4")
+		assert.False(t, locked)
+	})
+
+	t.Run("failures outside the window are forgotten", func(t *testing.T) {
+		l, now := newTestLockout(cfg)
+
+		l.Failure("1.2.3.4")
+		l.Failure("1.2.3.4")
+		*now = now.Add(61 * time.Second)
+		failures, locked := l.Failure("1.2.3.4")
+		assert.Equal(t, 1, failures)
+		assert.False(t, locked)
+	})
+
+	t.Run("success clears failures but not a lockout", func(t *testing.T) {
+		l, _ := newTestLockout(cfg)
+
+		l.Failure("1.2.3.4")
+		l.Failure("1.2.3.4")
+		l.Success("1.2.3.4")
+		failures, _ := l.Failure("1.2.3.4")
+		assert.Equal(t, 1, failures)
+
+		l.Failure("1.2.3.4")
+		l.Failure("1.2.3.4")
+		l.Success("1.2.3.4")
+		locked, _ := l.Locked("1.2.3.4")
+		assert.True(t, locked)
+	})
+
+	t.Run("disabled when max failures is zero", func(t *testing.T) {
+		l, _ := newTestLockout(&config.Config{})
+
+		for i := 0; i < 100; i++ {
+			_, locked := l.Failure("1.2.3.4")
+			assert.False(t, locked)
+		}
+		locked, _ := l.Locked("1.2.3.4")
+		assert.False(t, locked)
+	})
+
+	t.Run("prunes expired clients", func(t *testing.T) {
+		l, now := newTestLockout(cfg)
+
+		for i := 0; i < lockoutPruneSize; i++ {
+			l.Failure(time.Duration(i).String())
+		}
+		*now = now.Add(61 * time.Second)
+		l.Failure("1.2.3.4")
+		assert.Len(t, l.clients, 1)
+	})
+}
@@ -0,0 +1,98 @@
+// Package ratelimit provides per API key request rate and concurrency limiting.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+)
+
+// bucket is a token bucket refilled at rpm/60 tokens per second, holding at most rpm tokens
+type bucket struct {
+	tokens   float64
+	lastFill time.Time
+	inFlight int
+}
+
+// Limiter enforces requests-per-minute and concurrent request limits per API key
+type Limiter struct {
+	cfg     *config.Config
+	buckets map[string]*bucket
+	now     func() time.Time
+
+	mu sync.Mutex
+}
+
+// NewLimiter creates a limiter using the per-key limits from cfg
+func NewLimiter(cfg *config.Config) *Limiter {
+	return &Limiter{
+		cfg:     cfg,
+		buckets: make(map[string]*bucket),
+		now:     time.Now,
+	}
+}
+
+func (l *Limiter) bucketFor(apiKey string, limit config.RateLimit) *bucket {
+	b, ok := l.buckets[apiKey]
+	if !ok {
+		b = &bucket{tokens: float64(limit.RPM), lastFill: l.now()}
+		l.buckets[apiKey] = b
+	}
+	return b
+}
+
+// Allow consumes one request from the key's budget.
+// When the key is over its rate it returns false and how long until a request is allowed.
+func (l *Limiter) Allow(apiKey string) (bool, time.Duration) {
+	limit := l.cfg.RateLimitFor(apiKey)
+	if limit.RPM <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucketFor(apiKey, limit)
+	now := l.now()
+	rate := float64(limit.RPM) / 60
+
+	b.tokens = math.Min(float64(limit.RPM), b.tokens+now.Sub(b.lastFill).Seconds()*rate)
+	b.lastFill = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / rate * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// Acquire reserves a concurrent request slot for the key.
+// It returns a release func to call when the request finishes, or false when the key is at its limit.
+func (l *Limiter) Acquire(apiKey string) (func(), bool) {
+	limit := l.cfg.RateLimitFor(apiKey)
+	if limit.Concurrent <= 0 {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.bucketFor(apiKey, limit)
+	if b.inFlight >= limit.Concurrent {
+		return nil, false
+	}
+	b.inFlight++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.mu.Lock()
+			defer l.mu.Unlock()
+			b.inFlight--
+		})
+	}, true
+}
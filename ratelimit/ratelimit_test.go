@@ -0,0 +1,115 @@
+// Package ratelimit provides tests for per-key rate limiting.
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// newTestLimiter creates a limiter with a controllable clock
+func newTestLimiter(cfg *config.Config) (*Limiter, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	l := NewLimiter(cfg)
+	l.now = func() time.Time { return now }
+	return l, &now
+}
+
+// =============================================================================
+// TestAllow
+// =============================================================================
+
+func TestAllow(t *testing.T) {
+	t.Run("unlimited when rpm is zero", func(t *testing.T) {
+		l, _ := newTestLimiter(&config.Config{})
+
+		for i := 0; i < 100; i++ {
+			ok, _ := l.Allow("key")
+			assert.True(t, ok)
+		}
+	})
+
+	t.Run("allows burst up to rpm then rejects", func(t *testing.T) {
+		l, _ := newTestLimiter(&config.Config{RateLimitRPM: 3})
+
+		for i := 0; i < 3; i++ {
+			ok, _ := l.Allow("key")
+			assert.True(t, ok)
+		}
+
+		ok, retryAfter := l.Allow("key")
+		assert.False(t, ok)
+		assert.Equal(t, 20*time.Second, retryAfter)
+	})
+
+	t.Run("refills over time", func(t *testing.T) {
+		l, now := newTestLimiter(&config.Config{RateLimitRPM: 60})
+
+		for i := 0; i < 60; i++ {
+			l.Allow("key")
+		}
+		ok, _ := l.Allow("key")
+		assert.False(t, ok)
+
+		*now = now.Add(time.Second)
+		ok, _ = l.Allow("key")
+		assert.True(t, ok)
+	})
+
+	t.Run("tracks keys independently", func(t *testing.T) {
+		l, _ := newTestLimiter(&config.Config{RateLimitRPM: 1})
+
+		ok, _ := l.Allow("a")
+		assert.True(t, ok)
+		ok, _ = l.Allow("b")
+		assert.True(t, ok)
+		ok, _ = l.Allow("a")
+		assert.False(t, ok)
+	})
+
+	t.Run("uses per-key override", func(t *testing.T) {
+		l, _ := newTestLimiter(&config.Config{
+			RateLimitRPM:  1,
+			RateLimitKeys: map[string]config.RateLimit{"vip": {RPM: 0}},
+		})
+
+		for i := 0; i < 10; i++ {
+			ok, _ := l.Allow("vip")
+			assert.True(t, ok)
+		}
+	})
+}
+
+// =============================================================================
+// TestAcquire
+// =============================================================================
+
+func TestAcquire(t *testing.T) {
+	t.Run("limits concurrent requests", func(t *testing.T) {
+		l, _ := newTestLimiter(&config.Config{RateLimitConcurrent: 2})
+
+		release1, ok := l.Acquire("key")
+		assert.True(t, ok)
+		_, ok = l.Acquire("key")
+		assert.True(t, ok)
+		_, ok = l.Acquire("key")
+		assert.False(t, ok)
+
+		release1()
+		release1() // releasing twice has no effect
+		_, ok = l.Acquire("key")
+		assert.True(t, ok)
+		_, ok = l.Acquire("key")
+		assert.False(t, ok)
+	})
+
+	t.Run("unlimited when concurrent is zero", func(t *testing.T) {
+		l, _ := newTestLimiter(&config.Config{})
+
+		release, ok := l.Acquire("key")
+		assert.True(t, ok)
+		assert.NotNil(t, release)
+	})
+}
@@ -0,0 +1,82 @@
+// Package redact masks credentials in logs and error messages.
+//
+// Bearer tokens, Kiro access and refresh tokens, OAuth client secrets and API
+// keys are replaced with [REDACTED]. Debug captures are always redacted; with
+// PARANOID_LOGGING every log line and the upstream error messages passed on to
+// clients are redacted too.
+package redact
+
+import (
+	"regexp"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Placeholder replaces each masked secret
+const Placeholder = "[REDACTED]"
+
+// patterns match credentials; the first group is kept ahead of the placeholder
+// and the second after it
+var patterns = []*regexp.Regexp{
+	// JSON fields, as in token responses and captured requests, also when quoted
+	// again within a log line
+	regexp.MustCompile(`(?i)(\\?"(?:accessToken|refreshToken|access_token|refresh_token|id_token|clientSecret|client_secret|api_key|apiKey|authorization|x-api-key|password)\\?"\s*:\s*\\?")[^"\\]*(\\?")`),
+	// Form fields, query parameters and key=value log fields
+	regexp.MustCompile(`(?i)(\b(?:access_?token|refresh_?token|id_token|client_?secret|api_?key|password)=)[^\s&",]+()`),
+	regexp.MustCompile(`(?i)(Bearer\s+)[A-Za-z0-9\-._~+/=]+()`),
+	// Kiro access (aoa...) and refresh (aor...) tokens wherever they appear
+	regexp.MustCompile(`()\bao[ar][A-Za-z0-9\-_:.]{20,}()`),
+}
+
+var replacement = []byte("${1}" + Placeholder + "${2}")
+
+// Bytes masks the credentials in data
+func Bytes(data []byte) []byte {
+	for _, re := range patterns {
+		data = re.ReplaceAll(data, replacement)
+	}
+	return data
+}
+
+// String masks the credentials in s
+func String(s string) string {
+	return string(Bytes([]byte(s)))
+}
+
+var paranoid atomic.Bool
+
+// SetParanoid turns PARANOID_LOGGING on or off
+func SetParanoid(on bool) {
+	paranoid.Store(on)
+}
+
+// Paranoid reports whether PARANOID_LOGGING is on
+func Paranoid() bool {
+	return paranoid.Load()
+}
+
+// Message masks the credentials in an error message with PARANOID_LOGGING on,
+// and returns it unchanged otherwise
+func Message(s string) string {
+	if !Paranoid() {
+		return s
+	}
+	return String(s)
+}
+
+// Formatter wraps a logrus formatter, masking the credentials in each formatted
+// entry with PARANOID_LOGGING on. Masking the output rather than the fields
+// covers messages, fields and errors alike.
+type Formatter struct {
+	log.Formatter
+}
+
+// Format formats the entry with the wrapped formatter
+func (f *Formatter) Format(entry *log.Entry) ([]byte, error) {
+	out, err := f.Formatter.Format(entry)
+	if err != nil || !Paranoid() {
+		return out, err
+	}
+	return Bytes(out), nil
+}
@@ -0,0 +1,76 @@
+// Package redact provides tests for credential masking.
+package redact
+
+import (
+	"bytes"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestString
+// =============================================================================
+
+func TestString(t *testing.T) {
+	t.Run("masks JSON credentials", func(t *testing.T) {
+		result := String(`{"accessToken":"secret-1","refresh_token": "secret-2","clientSecret":"secret-3","content":"hello"}`)
+
+		assert.NotContains(t, result, "secret-")
+		assert.Contains(t, result, `"accessToken":"[REDACTED]"`)
+		assert.Contains(t, result, `"content":"hello"`)
+	})
+
+	t.Run("masks form fields and log fields", func(t *testing.T) {
+		result := String(`grant_type=refresh_token&refresh_token=secret-1&client_secret=secret-2 api_key=secret-3`)
+
+		assert.NotContains(t, result, "secret-")
+		assert.Contains(t, result, "grant_type=refresh_token&refresh_token=[REDACTED]")
+	})
+
+	t.Run("masks Bearer and Kiro tokens", func(t *testing.T) {
+		result := String("Authorization: Bearer abc.def-123, token aorAAAAAGabcdefghijklmnopqrstuvwxyz:MGUCMQ")
+
+		assert.Equal(t, "Authorization: Bearer [REDACTED], token [REDACTED]", result)
+	})
+
+	t.Run("leaves other text alone", func(t *testing.T) {
+		text := "Token refreshed via Kiro Desktop Auth, expires: 2026-01-01T00:00:00Z"
+
+		assert.Equal(t, text, String(text))
+	})
+}
+
+// =============================================================================
+// TestParanoid
+// =============================================================================
+
+func TestParanoid(t *testing.T) {
+	defer SetParanoid(false)
+
+	t.Run("messages are masked only in paranoid mode", func(t *testing.T) {
+		SetParanoid(false)
+		assert.Equal(t, `{"refreshToken":"secret"}`, Message(`{"refreshToken":"secret"}`))
+
+		SetParanoid(true)
+		assert.Equal(t, `{"refreshToken":"[REDACTED]"}`, Message(`{"refreshToken":"secret"}`))
+	})
+
+	t.Run("formatter masks messages and fields", func(t *testing.T) {
+		var out bytes.Buffer
+		logger := log.New()
+		logger.SetOutput(&out)
+		logger.SetFormatter(&Formatter{Formatter: &log.JSONFormatter{}})
+
+		SetParanoid(false)
+		logger.WithField("authorization", "Bearer secret-1").Info("plain")
+		assert.Contains(t, out.String(), "secret-1")
+
+		out.Reset()
+		SetParanoid(true)
+		logger.WithField("authorization", "Bearer secret-1").Infof("refresh failed: %s", `{"refreshToken":"secret-2"}`)
+		assert.NotContains(t, out.String(), "secret-")
+		assert.Contains(t, out.String(), "refresh failed")
+	})
+}
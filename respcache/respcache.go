@@ -0,0 +1,129 @@
+// Package respcache caches responses to identical non-streaming requests.
+//
+// Agent frameworks often retry by re-sending the exact same prompt. With
+// RESPONSE_CACHE_TTL set, the proxy answers such repeats from memory instead
+// of calling Kiro again. Entries expire after the TTL and the least recently
+// used entry is evicted once RESPONSE_CACHE_MAX_ENTRIES is reached.
+package respcache
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+)
+
+// entry is a cached response body
+type entry struct {
+	key     string
+	body    []byte
+	expires time.Time
+}
+
+// Cache is an LRU cache of response bodies with a fixed TTL.
+// A nil *Cache is valid: it never stores or returns anything.
+type Cache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	order   *list.List // front is most recently used
+	entries map[string]*list.Element
+	mu      sync.Mutex
+}
+
+// NewCache creates a cache from the RESPONSE_CACHE_* settings, or nil when caching is disabled
+func NewCache(cfg *config.Config) *Cache {
+	return New(time.Duration(cfg.ResponseCacheTTL*float64(time.Second)), cfg.ResponseCacheMaxEntries)
+}
+
+// New creates a cache keeping up to maxEntries bodies for ttl, or nil when
+// either is not positive
+func New(ttl time.Duration, maxEntries int) *Cache {
+	if ttl <= 0 || maxEntries <= 0 {
+		return nil
+	}
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Key hashes the JSON encoding of the parts that identify a request
+func Key(parts ...interface{}) string {
+	h := sha256.New()
+	enc := json.NewEncoder(h)
+	for _, part := range parts {
+		enc.Encode(part)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns the cached body for key, if present and not expired
+func (c *Cache) Get(key string) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	e := el.Value.(*entry)
+	if !c.now().Before(e.expires) {
+		c.remove(el)
+		return nil, false
+	}
+	c.order.MoveToFront(el)
+	return e.body, true
+}
+
+// Put stores body under key, evicting the least recently used entries when full
+func (c *Cache) Put(key string, body []byte) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if el, ok := c.entries[key]; ok {
+		e := el.Value.(*entry)
+		e.body = body
+		e.expires = expires
+		c.order.MoveToFront(el)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&entry{key: key, body: body, expires: expires})
+	for c.order.Len() > c.maxEntries {
+		c.remove(c.order.Back())
+	}
+}
+
+// Len returns the number of cached entries, including expired ones not yet evicted
+func (c *Cache) Len() int {
+	if c == nil {
+		return 0
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) remove(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.entries, el.Value.(*entry).key)
+}
@@ -0,0 +1,124 @@
+// Package respcache provides tests for the response cache.
+package respcache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// newTestCache creates a cache with a controllable clock
+func newTestCache(ttl float64, maxEntries int) (*Cache, *time.Time) {
+	now := time.Unix(1700000000, 0)
+	c := NewCache(&config.Config{ResponseCacheTTL: ttl, ResponseCacheMaxEntries: maxEntries})
+	c.now = func() time.Time { return now }
+	return c, &now
+}
+
+// =============================================================================
+// TestNewCache
+// =============================================================================
+
+func TestNewCache(t *testing.T) {
+	t.Run("disabled when ttl is zero", func(t *testing.T) {
+		assert.Nil(t, NewCache(&config.Config{ResponseCacheMaxEntries: 10}))
+	})
+
+	t.Run("disabled when max entries is zero", func(t *testing.T) {
+		assert.Nil(t, NewCache(&config.Config{ResponseCacheTTL: 60}))
+	})
+
+	t.Run("nil cache ignores all calls", func(t *testing.T) {
+		var c *Cache
+		c.Put("key", []byte("body"))
+
+		_, ok := c.Get("key")
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+	})
+}
+
+// =============================================================================
+// TestCache
+// =============================================================================
+
+func TestCache(t *testing.T) {
+	t.Run("returns stored body", func(t *testing.T) {
+		c, _ := newTestCache(60, 10)
+		c.Put("key", []byte("body"))
+
+		body, ok := c.Get("key")
+		assert.True(t, ok)
+		assert.Equal(t, "body", string(body))
+	})
+
+	t.Run("misses unknown key", func(t *testing.T) {
+		c, _ := newTestCache(60, 10)
+
+		_, ok := c.Get("key")
+		assert.False(t, ok)
+	})
+
+	t.Run("expires entries after ttl", func(t *testing.T) {
+		c, now := newTestCache(60, 10)
+		c.Put("key", []byte("body"))
+
+		*now = now.Add(59 * time.Second)
+		_, ok := c.Get("key")
+		assert.True(t, ok)
+
+		*now = now.Add(time.Second)
+		_, ok = c.Get("key")
+		assert.False(t, ok)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("evicts least recently used entry", func(t *testing.T) {
+		c, _ := newTestCache(60, 2)
+		c.Put("a", []byte("1"))
+		c.Put("b", []byte("2"))
+		c.Get("a")
+		c.Put("c", []byte("3"))
+
+		_, ok := c.Get("b")
+		assert.False(t, ok)
+		_, ok = c.Get("a")
+		assert.True(t, ok)
+		_, ok = c.Get("c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, c.Len())
+	})
+
+	t.Run("replaces existing entry and refreshes ttl", func(t *testing.T) {
+		c, now := newTestCache(60, 10)
+		c.Put("key", []byte("old"))
+		*now = now.Add(30 * time.Second)
+		c.Put("key", []byte("new"))
+		*now = now.Add(45 * time.Second)
+
+		body, ok := c.Get("key")
+		assert.True(t, ok)
+		assert.Equal(t, "new", string(body))
+		assert.Equal(t, 1, c.Len())
+	})
+}
+
+// =============================================================================
+// TestKey
+// =============================================================================
+
+func TestKey(t *testing.T) {
+	t.Run("same parts give same key", func(t *testing.T) {
+		req := map[string]interface{}{"model": "claude", "messages": []string{"hi"}}
+		assert.Equal(t, Key("/v1/messages", req), Key("/v1/messages", req))
+	})
+
+	t.Run("different parts give different keys", func(t *testing.T) {
+		a := map[string]interface{}{"model": "claude", "temperature": 0.1}
+		b := map[string]interface{}{"model": "claude", "temperature": 0.2}
+		assert.NotEqual(t, Key("/v1/messages", a), Key("/v1/messages", b))
+		assert.NotEqual(t, Key("/v1/messages", a), Key("/v1/chat/completions", a))
+	})
+}
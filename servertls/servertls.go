@@ -0,0 +1,121 @@
+// Package servertls builds the TLS configuration for the gateway listener.
+package servertls
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"time"
+
+	"kiro-go-proxy/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// selfSignedValidity is how long a generated certificate is valid
+const selfSignedValidity = 365 * 24 * time.Hour
+
+// Load returns the listener TLS configuration, or nil when TLS is not enabled.
+// A certificate is loaded from TLS_CERT_FILE/TLS_KEY_FILE, or generated in
+// memory when TLS_SELF_SIGNED is set. TLS_CLIENT_CA_FILE enables mTLS.
+func Load(cfg *config.Config) (*tls.Config, error) {
+	if !cfg.TLSEnabled() {
+		return nil, nil
+	}
+
+	var cert tls.Certificate
+	var err error
+	if cfg.TLSCertFile != "" {
+		cert, err = tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		log.Infof("TLS enabled with certificate %s", cfg.TLSCertFile)
+	} else {
+		hosts := []string{"localhost", "127.0.0.1", "::1"}
+		if cfg.ServerHost != "" && cfg.ServerHost != "0.0.0.0" && cfg.ServerHost != "::" {
+			hosts = append(hosts, cfg.ServerHost)
+		}
+		cert, err = GenerateSelfSigned(hosts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate self-signed certificate: %w", err)
+		}
+		log.Warnf("TLS enabled with a generated self-signed certificate for %v; clients must skip verification or trust it explicitly", hosts)
+	}
+
+	tlsCfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.TLSClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.TLSClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read TLS client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in TLS client CA file %s", cfg.TLSClientCAFile)
+		}
+		tlsCfg.ClientCAs = pool
+		tlsCfg.ClientAuth = tls.RequireAndVerifyClientCert
+		log.Infof("mTLS enabled: client certificates must be signed by %s", cfg.TLSClientCAFile)
+	}
+
+	return tlsCfg, nil
+}
+
+// GenerateSelfSigned creates an in-memory ECDSA certificate for the given host names and IPs.
+// It is a server leaf, not a CA, so trusting it does not let it sign other certificates.
+func GenerateSelfSigned(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{config.AppTitle}},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(selfSignedValidity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	for _, host := range hosts {
+		if ip := net.ParseIP(host); ip != nil {
+			template.IPAddresses = append(template.IPAddresses, ip)
+		} else {
+			template.DNSNames = append(template.DNSNames, host)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{
+		Certificate: [][]byte{der},
+		PrivateKey:  key,
+		Leaf:        leaf,
+	}, nil
+}
@@ -0,0 +1,104 @@
+// Package servertls provides tests for the listener TLS configuration.
+package servertls
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// writeCertFiles writes a generated certificate and key as PEM files
+func writeCertFiles(t *testing.T, dir string) (string, string) {
+	cert, err := GenerateSelfSigned([]string{"localhost"})
+	assert.NoError(t, err)
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	assert.NoError(t, err)
+
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]}), 0600)
+	os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0600)
+	return certFile, keyFile
+}
+
+// =============================================================================
+// TestLoad
+// =============================================================================
+
+func TestLoad(t *testing.T) {
+	t.Run("returns nil when TLS is disabled", func(t *testing.T) {
+		tlsCfg, err := Load(&config.Config{})
+
+		assert.NoError(t, err)
+		assert.Nil(t, tlsCfg)
+	})
+
+	t.Run("generates self-signed certificate", func(t *testing.T) {
+		tlsCfg, err := Load(&config.Config{TLSSelfSigned: true, ServerHost: "gateway.local"})
+
+		assert.NoError(t, err)
+		assert.Len(t, tlsCfg.Certificates, 1)
+		leaf := tlsCfg.Certificates[0].Leaf
+		assert.Contains(t, leaf.DNSNames, "localhost")
+		assert.Contains(t, leaf.DNSNames, "gateway.local")
+		assert.NoError(t, leaf.VerifyHostname("127.0.0.1"))
+		assert.Equal(t, tls.NoClientCert, tlsCfg.ClientAuth)
+	})
+
+	t.Run("self-signed certificate is a server leaf", func(t *testing.T) {
+		cert, err := GenerateSelfSigned([]string{"localhost"})
+		assert.NoError(t, err)
+
+		leaf := cert.Leaf
+		assert.False(t, leaf.IsCA)
+		assert.Zero(t, leaf.KeyUsage&x509.KeyUsageCertSign)
+		assert.Equal(t, []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}, leaf.ExtKeyUsage)
+
+		// Clients can still trust it directly
+		roots := x509.NewCertPool()
+		roots.AddCert(leaf)
+		_, err = leaf.Verify(x509.VerifyOptions{DNSName: "localhost", Roots: roots})
+		assert.NoError(t, err)
+	})
+
+	t.Run("loads certificate files", func(t *testing.T) {
+		certFile, keyFile := writeCertFiles(t, t.TempDir())
+
+		tlsCfg, err := Load(&config.Config{TLSCertFile: certFile, TLSKeyFile: keyFile})
+
+		assert.NoError(t, err)
+		assert.Len(t, tlsCfg.Certificates, 1)
+	})
+
+	t.Run("fails on missing certificate file", func(t *testing.T) {
+		_, err := Load(&config.Config{TLSCertFile: "/nonexistent/cert.pem", TLSKeyFile: "/nonexistent/key.pem"})
+
+		assert.Error(t, err)
+	})
+
+	t.Run("requires client certificates with a client CA", func(t *testing.T) {
+		caFile, _ := writeCertFiles(t, t.TempDir())
+
+		tlsCfg, err := Load(&config.Config{TLSSelfSigned: true, TLSClientCAFile: caFile})
+
+		assert.NoError(t, err)
+		assert.Equal(t, tls.RequireAndVerifyClientCert, tlsCfg.ClientAuth)
+		assert.NotNil(t, tlsCfg.ClientCAs)
+	})
+
+	t.Run("fails on client CA without certificates", func(t *testing.T) {
+		caFile := filepath.Join(t.TempDir(), "ca.pem")
+		os.WriteFile(caFile, []byte("not a certificate"), 0600)
+
+		_, err := Load(&config.Config{TLSSelfSigned: true, TLSClientCAFile: caFile})
+
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,244 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import (
+	"context"
+	"net/http"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+	"kiro-go-proxy/parser"
+	"kiro-go-proxy/utils"
+)
+
+// Anthropic Streaming
+
+// AnthropicStopReason maps a stream stop reason to an Anthropic stop_reason
+func AnthropicStopReason(stopReason string) string {
+	switch stopReason {
+	case StopReasonLength:
+		return "max_tokens"
+	case StopReasonStopSequence:
+		return "stop_sequence"
+	case StopReasonToolUse:
+		return "tool_use"
+	default:
+		return "end_turn"
+	}
+}
+
+// AnthropicUsage builds an Anthropic usage object. Kiro has no prompt caching, so
+// the cache counters are always 0; SDKs expect them to be present.
+func AnthropicUsage(inputTokens, outputTokens int) map[string]interface{} {
+	return map[string]interface{}{
+		"input_tokens":                inputTokens,
+		"output_tokens":               outputTokens,
+		"cache_creation_input_tokens": 0,
+		"cache_read_input_tokens":     0,
+	}
+}
+
+// AnthropicThinkingSignature returns the signature of a thinking block. Kiro does
+// not sign its thinking, so this is only an opaque placeholder for clients that
+// require one.
+func AnthropicThinkingSignature() string {
+	return utils.GenerateConversationID()
+}
+
+// StreamToAnthropic converts Kiro stream to Anthropic Messages SSE format.
+// Tool inputs are streamed as incremental input_json_delta chunks. A non-empty
+// prefill (the client's final assistant message) starts the first text block.
+func StreamToAnthropic(
+	ctx context.Context,
+	response *http.Response,
+	model string,
+	messageID string,
+	firstTokenTimeout float64,
+	enableThinkingParser bool,
+	cfg *config.Config,
+	modelCache *model.Cache,
+	promptTokens int,
+	limits Limits,
+	prefill string,
+) <-chan string {
+	output := make(chan string, 100)
+
+	go func() {
+		defer close(output)
+
+		events, errs := ParseKiroStreamIncremental(ctx, response, firstTokenTimeout, enableThinkingParser, cfg, limits)
+		w := &anthropicStreamWriter{
+			output:    output,
+			openIndex: -1,
+			thinking:  parser.ThinkingHandlingMode(cfg.FakeReasoningHandling),
+			prefill:   prefill,
+		}
+
+		w.send("message_start", map[string]interface{}{
+			"type": "message_start",
+			"message": map[string]interface{}{
+				"id":            messageID,
+				"type":          "message",
+				"role":          "assistant",
+				"content":       []interface{}{},
+				"model":         model,
+				"stop_reason":   nil,
+				"stop_sequence": nil,
+				"usage":         AnthropicUsage(promptTokens, 0),
+			},
+		})
+		w.send("ping", map[string]interface{}{"type": "ping"})
+
+		encodeStream(ctx, events, errs, w, modelCache, model, promptTokens)
+	}()
+
+	return output
+}
+
+// anthropicStreamWriter emits Anthropic SSE events, keeping at most one content block open
+type anthropicStreamWriter struct {
+	output    chan<- string
+	nextIndex int
+	openIndex int
+	openType  string
+	thinking  parser.ThinkingHandlingMode
+
+	// prefill is sent before the first text or tool block
+	prefill     string
+	prefillSent bool
+}
+
+func (w *anthropicStreamWriter) Content(text string) {
+	w.ensureBlock("text", map[string]interface{}{"type": "text", "text": ""})
+	w.delta(map[string]interface{}{"type": "text_delta", "text": text})
+}
+
+// Thinking is sent as a thinking block, or inline ahead of the answer with pass
+// and strip_tags
+func (w *anthropicStreamWriter) Thinking(text string) {
+	switch w.thinking {
+	case parser.ThinkingHandlingAsReasoningContent:
+		w.ensureBlock("thinking", map[string]interface{}{"type": "thinking", "thinking": ""})
+		w.delta(map[string]interface{}{"type": "thinking_delta", "thinking": text})
+	case parser.ThinkingHandlingPass, parser.ThinkingHandlingStripTags:
+		w.Content(text)
+	}
+}
+
+// ToolDelta gives each tool call its own block, streaming the input as partial JSON
+func (w *anthropicStreamWriter) ToolDelta(delta ToolDelta) {
+	if delta.Start {
+		w.closeBlock()
+		w.ensureBlock("tool_use", map[string]interface{}{
+			"type":  "tool_use",
+			"id":    delta.ID,
+			"name":  delta.Name,
+			"input": map[string]interface{}{},
+		})
+	}
+	if w.openType != "tool_use" {
+		return
+	}
+	if delta.Arguments != "" {
+		w.delta(map[string]interface{}{"type": "input_json_delta", "partial_json": delta.Arguments})
+	}
+	if delta.Stop {
+		w.closeBlock()
+	}
+}
+
+func (w *anthropicStreamWriter) sentReply(result *StreamResult) (string, string) {
+	content, thinking := SplitThinking(w.thinking, result.Content, result.ThinkingContent)
+	return w.prefill + content, thinking
+}
+
+func (w *anthropicStreamWriter) Done(result *StreamResult, tokens TokenUsage) {
+	w.startPrefill()
+	w.closeBlock()
+
+	var stopSequence interface{}
+	if result.StopSequence != "" {
+		stopSequence = result.StopSequence
+	}
+	messageDelta := map[string]interface{}{
+		"type": "message_delta",
+		"delta": map[string]interface{}{
+			"stop_reason":   AnthropicStopReason(result.StopReason),
+			"stop_sequence": stopSequence,
+		},
+		"usage": AnthropicUsage(tokens.PromptTokens, tokens.CompletionTokens),
+	}
+	if tokens.Credits != 0 {
+		messageDelta["metadata"] = map[string]interface{}{"kiro_credits": tokens.Credits}
+	}
+	w.send("message_delta", messageDelta)
+	w.send("message_stop", map[string]interface{}{"type": "message_stop"})
+}
+
+func (w *anthropicStreamWriter) Error(err error) {
+	w.send("error", map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    "internal_error",
+			"message": err.Error(),
+		},
+	})
+}
+
+func (w *anthropicStreamWriter) send(eventType string, data map[string]interface{}) {
+	w.output <- encodeChunk("event: "+eventType+"\ndata: ", data, "\n\n")
+}
+
+// ensureBlock opens a content block of blockType, closing any block of a different type
+func (w *anthropicStreamWriter) ensureBlock(blockType string, contentBlock map[string]interface{}) {
+	if blockType != "thinking" {
+		w.startPrefill()
+	}
+	if w.openType == blockType {
+		return
+	}
+	w.closeBlock()
+
+	w.openIndex = w.nextIndex
+	w.openType = blockType
+	w.nextIndex++
+	w.send("content_block_start", map[string]interface{}{
+		"type":          "content_block_start",
+		"index":         w.openIndex,
+		"content_block": contentBlock,
+	})
+}
+
+// startPrefill opens a text block with the prefill, unless it was sent already
+func (w *anthropicStreamWriter) startPrefill() {
+	if w.prefill == "" || w.prefillSent {
+		return
+	}
+	w.prefillSent = true
+	w.ensureBlock("text", map[string]interface{}{"type": "text", "text": ""})
+	w.delta(map[string]interface{}{"type": "text_delta", "text": w.prefill})
+}
+
+func (w *anthropicStreamWriter) delta(delta map[string]interface{}) {
+	w.send("content_block_delta", map[string]interface{}{
+		"type":  "content_block_delta",
+		"index": w.openIndex,
+		"delta": delta,
+	})
+}
+
+// closeBlock closes the open block; a thinking block gets its signature first
+func (w *anthropicStreamWriter) closeBlock() {
+	if w.openType == "" {
+		return
+	}
+	if w.openType == "thinking" {
+		w.delta(map[string]interface{}{"type": "signature_delta", "signature": AnthropicThinkingSignature()})
+	}
+	w.send("content_block_stop", map[string]interface{}{
+		"type":  "content_block_stop",
+		"index": w.openIndex,
+	})
+	w.openType = ""
+	w.openIndex = -1
+}
@@ -0,0 +1,213 @@
+// Package stream provides tests for Anthropic streaming.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+)
+
+// newKiroResponse builds a fake Kiro response from raw event JSON payloads
+func newKiroResponse(payloads ...string) *http.Response {
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(strings.Join(payloads, ""))),
+	}
+}
+
+// collectAnthropicEvents runs StreamToAnthropic and decodes the emitted SSE events
+func collectAnthropicEvents(t *testing.T, resp *http.Response, limits Limits) ([]string, []map[string]interface{}) {
+	return collectAnthropicPrefillEvents(t, resp, limits, "")
+}
+
+// collectAnthropicPrefillEvents is collectAnthropicEvents for a request with an assistant prefill
+func collectAnthropicPrefillEvents(t *testing.T, resp *http.Response, limits Limits, prefill string) ([]string, []map[string]interface{}) {
+	cfg := &config.Config{}
+	var types []string
+	var data []map[string]interface{}
+
+	for event := range StreamToAnthropic(context.Background(), resp, "claude-sonnet-4.5", "msg_1", 15, false, cfg, model.NewCache(cfg), 10, limits, prefill) {
+		lines := strings.SplitN(strings.TrimSpace(event), "\n", 2)
+		assert.Len(t, lines, 2)
+
+		var parsed map[string]interface{}
+		err := json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &parsed)
+		assert.NoError(t, err)
+
+		types = append(types, strings.TrimPrefix(lines[0], "event: "))
+		data = append(data, parsed)
+	}
+	return types, data
+}
+
+// =============================================================================
+// TestAnthropicStopReason
+// =============================================================================
+
+func TestAnthropicStopReason(t *testing.T) {
+	assert.Equal(t, "max_tokens", AnthropicStopReason(StopReasonLength))
+	assert.Equal(t, "stop_sequence", AnthropicStopReason(StopReasonStopSequence))
+	assert.Equal(t, "tool_use", AnthropicStopReason(StopReasonToolUse))
+	assert.Equal(t, "end_turn", AnthropicStopReason(""))
+}
+
+// =============================================================================
+// TestStreamToAnthropic
+// =============================================================================
+
+func TestStreamToAnthropic(t *testing.T) {
+	t.Run("streams text with message envelope", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hello"}`, `{"content":" world"}`)
+
+		types, data := collectAnthropicEvents(t, resp, Limits{})
+
+		assert.Equal(t, []string{
+			"message_start",
+			"ping",
+			"content_block_start",
+			"content_block_delta",
+			"content_block_delta",
+			"content_block_stop",
+			"message_delta",
+			"message_stop",
+		}, types)
+
+		delta := data[6]
+		assert.Equal(t, "end_turn", delta["delta"].(map[string]interface{})["stop_reason"])
+		assert.Greater(t, delta["usage"].(map[string]interface{})["output_tokens"], float64(0))
+
+		// Kiro has no prompt caching: the counters are reported as 0
+		startUsage := data[0]["message"].(map[string]interface{})["usage"].(map[string]interface{})
+		assert.Equal(t, float64(0), startUsage["cache_creation_input_tokens"])
+		assert.Equal(t, float64(0), startUsage["cache_read_input_tokens"])
+		assert.Equal(t, float64(0), delta["usage"].(map[string]interface{})["cache_creation_input_tokens"])
+	})
+
+	t.Run("reports estimated token usage", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hello"}`, `{"content":" world"}`)
+
+		_, data := collectAnthropicEvents(t, resp, Limits{})
+
+		startUsage := data[0]["message"].(map[string]interface{})["usage"].(map[string]interface{})
+		assert.Equal(t, float64(10), startUsage["input_tokens"])
+		assert.Equal(t, float64(0), startUsage["output_tokens"])
+		deltaUsage := data[6]["usage"].(map[string]interface{})
+		assert.Equal(t, float64(10), deltaUsage["input_tokens"])
+		assert.Equal(t, float64(CountCompletionTokens("Hello world", "", nil)), deltaUsage["output_tokens"])
+	})
+
+	t.Run("reports input tokens from Kiro context usage", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hello"}`, `{"contextUsagePercentage":1}`)
+
+		_, data := collectAnthropicEvents(t, resp, Limits{})
+
+		// 1% of the default 200000 token context, less the output
+		outputTokens := CountCompletionTokens("Hello", "", nil)
+		deltaUsage := data[len(data)-2]["usage"].(map[string]interface{})
+		assert.Equal(t, float64(2000-outputTokens), deltaUsage["input_tokens"])
+		assert.Equal(t, float64(outputTokens), deltaUsage["output_tokens"])
+	})
+
+	t.Run("streams tool input incrementally", func(t *testing.T) {
+		resp := newKiroResponse(
+			`{"content":"Checking"}`,
+			`{"name":"get_weather","toolUseId":"toolu_1"}`,
+			`{"input":"{\"city\":"}`,
+			`{"input":"\"Paris\"}"}`,
+			`{"stop":true}`,
+		)
+
+		types, data := collectAnthropicEvents(t, resp, Limits{})
+
+		assert.Equal(t, []string{
+			"message_start",
+			"ping",
+			"content_block_start",
+			"content_block_delta",
+			"content_block_stop",
+			"content_block_start",
+			"content_block_delta",
+			"content_block_delta",
+			"content_block_stop",
+			"message_delta",
+			"message_stop",
+		}, types)
+
+		toolBlock := data[5]["content_block"].(map[string]interface{})
+		assert.Equal(t, "tool_use", toolBlock["type"])
+		assert.Equal(t, "toolu_1", toolBlock["id"])
+		assert.Equal(t, "get_weather", toolBlock["name"])
+		assert.Equal(t, float64(1), data[5]["index"])
+
+		var partial strings.Builder
+		for _, d := range data[6:8] {
+			inputDelta := d["delta"].(map[string]interface{})
+			assert.Equal(t, "input_json_delta", inputDelta["type"])
+			partial.WriteString(inputDelta["partial_json"].(string))
+		}
+		assert.JSONEq(t, `{"city":"Paris"}`, partial.String())
+		assert.Equal(t, "tool_use", data[9]["delta"].(map[string]interface{})["stop_reason"])
+	})
+
+	t.Run("closes unfinished tool block at end", func(t *testing.T) {
+		resp := newKiroResponse(`{"name":"search","toolUseId":"toolu_2"}`, `{"input":"{}"}`)
+
+		types, _ := collectAnthropicEvents(t, resp, Limits{})
+
+		assert.Equal(t, "content_block_stop", types[len(types)-3])
+	})
+
+	t.Run("reports stop sequence", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"one END two"}`)
+
+		types, data := collectAnthropicEvents(t, resp, Limits{StopSequences: []string{"END"}})
+
+		delta := data[len(types)-2]["delta"].(map[string]interface{})
+		assert.Equal(t, "stop_sequence", delta["stop_reason"])
+		assert.Equal(t, "END", delta["stop_sequence"])
+	})
+
+	t.Run("starts the text with the prefill", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":" 42}"}`)
+
+		types, data := collectAnthropicPrefillEvents(t, resp, Limits{}, `{"answer":`)
+
+		assert.Equal(t, []string{
+			"message_start",
+			"ping",
+			"content_block_start",
+			"content_block_delta",
+			"content_block_delta",
+			"content_block_stop",
+			"message_delta",
+			"message_stop",
+		}, types)
+		assert.Equal(t, `{"answer":`, data[3]["delta"].(map[string]interface{})["text"])
+		assert.Equal(t, " 42}", data[4]["delta"].(map[string]interface{})["text"])
+	})
+
+	t.Run("sends the prefill before a tool call", func(t *testing.T) {
+		resp := newKiroResponse(`{"name":"search","toolUseId":"toolu_3"}`, `{"input":"{}"}`, `{"stop":true}`)
+
+		types, data := collectAnthropicPrefillEvents(t, resp, Limits{}, "Let me search.")
+
+		assert.Equal(t, "content_block_start", types[2])
+		assert.Equal(t, "text", data[2]["content_block"].(map[string]interface{})["type"])
+		assert.Equal(t, "Let me search.", data[3]["delta"].(map[string]interface{})["text"])
+		assert.Equal(t, "tool_use", data[5]["content_block"].(map[string]interface{})["type"])
+	})
+
+	t.Run("sends the prefill of an empty response", func(t *testing.T) {
+		types, data := collectAnthropicPrefillEvents(t, newKiroResponse(), Limits{}, "Sure")
+
+		assert.Equal(t, "content_block_delta", types[3])
+		assert.Equal(t, "Sure", data[3]["delta"].(map[string]interface{})["text"])
+	})
+}
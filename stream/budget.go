@@ -0,0 +1,77 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import (
+	"strings"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/tokens"
+)
+
+// thinkingBudget enforces FAKE_REASONING_MAX_TOKENS on the thinking of a
+// response: once the thinking reaches the budget its block is closed, and the
+// rest of it is sent as content, after FAKE_REASONING_BUDGET_MARKER if set.
+type thinkingBudget struct {
+	maxTokens int
+	marker    string
+	tokens    int
+	exceeded  bool
+}
+
+// newThinkingBudget returns the thinking budget of a request, or nil when
+// FAKE_REASONING_ENFORCE_BUDGET is off
+func newThinkingBudget(cfg *config.Config) *thinkingBudget {
+	if !cfg.FakeReasoningEnforceBudget || cfg.FakeReasoningMaxTokens <= 0 {
+		return nil
+	}
+	return &thinkingBudget{maxTokens: cfg.FakeReasoningMaxTokens, marker: cfg.FakeReasoningBudgetMarker}
+}
+
+// apply passes thinking events within the budget and turns the thinking past it
+// into content events. A nil budget returns events unchanged.
+func (b *thinkingBudget) apply(events []KiroEvent) []KiroEvent {
+	if b == nil {
+		return events
+	}
+
+	var result []KiroEvent
+	for _, event := range events {
+		if event.Type != "thinking" {
+			result = append(result, event)
+			continue
+		}
+		if b.exceeded {
+			result = append(result, KiroEvent{Type: "content", Content: event.ThinkingContent})
+			continue
+		}
+
+		n := tokens.Count(event.ThinkingContent)
+		if b.tokens+n <= b.maxTokens {
+			b.tokens += n
+			result = append(result, event)
+			continue
+		}
+
+		// The budget ends within this chunk: close the thinking at the cutoff
+		head := tokens.Truncate(event.ThinkingContent, b.maxTokens-b.tokens)
+		tail := event.ThinkingContent
+		if strings.HasPrefix(tail, head) {
+			tail = tail[len(head):]
+		}
+		b.tokens = b.maxTokens
+		b.exceeded = true
+
+		if head != "" {
+			event.ThinkingContent = head
+			event.IsLastThinkingChunk = true
+			result = append(result, event)
+		}
+		if b.marker != "" {
+			result = append(result, KiroEvent{Type: "content", Content: b.marker + "\n\n"})
+		}
+		if tail != "" {
+			result = append(result, KiroEvent{Type: "content", Content: tail})
+		}
+	}
+	return result
+}
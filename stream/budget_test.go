@@ -0,0 +1,68 @@
+// Package stream provides tests for thinking budget enforcement.
+package stream
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/tokens"
+)
+
+// =============================================================================
+// TestThinkingBudget
+// =============================================================================
+
+func TestThinkingBudget(t *testing.T) {
+	t.Run("disabled unless enforced", func(t *testing.T) {
+		assert.Nil(t, newThinkingBudget(&config.Config{FakeReasoningMaxTokens: 10}))
+		events := []KiroEvent{{Type: "thinking", ThinkingContent: strings.Repeat("word ", 50)}}
+		assert.Equal(t, events, newThinkingBudget(&config.Config{}).apply(events))
+	})
+
+	t.Run("moves thinking past the budget to content", func(t *testing.T) {
+		budget := newThinkingBudget(&config.Config{FakeReasoningEnforceBudget: true, FakeReasoningMaxTokens: 10, FakeReasoningBudgetMarker: "[thinking budget exceeded]"})
+		text := strings.Repeat("word ", 20)
+
+		events := budget.apply([]KiroEvent{{Type: "thinking", ThinkingContent: text}})
+		events = append(events, budget.apply([]KiroEvent{{Type: "thinking", ThinkingContent: "more"}, {Type: "content", Content: "Answer"}})...)
+
+		assert.Equal(t, "thinking", events[0].Type)
+		assert.True(t, events[0].IsLastThinkingChunk)
+		assert.LessOrEqual(t, tokens.Count(events[0].ThinkingContent), 10)
+		assert.Equal(t, KiroEvent{Type: "content", Content: "[thinking budget exceeded]\n\n"}, events[1])
+		assert.Equal(t, text, events[0].ThinkingContent+events[2].Content)
+		assert.Equal(t, KiroEvent{Type: "content", Content: "more"}, events[3])
+		assert.Equal(t, KiroEvent{Type: "content", Content: "Answer"}, events[4])
+	})
+
+	t.Run("passes thinking within the budget", func(t *testing.T) {
+		budget := newThinkingBudget(&config.Config{FakeReasoningEnforceBudget: true, FakeReasoningMaxTokens: 100})
+		events := []KiroEvent{{Type: "thinking", ThinkingContent: "Plan it."}}
+
+		assert.Equal(t, events, budget.apply(events))
+	})
+
+	t.Run("enforced on the parsed stream", func(t *testing.T) {
+		thinking := strings.Repeat("step ", 40)
+		resp := newKiroResponse(`{"content":"<thinking>` + thinking + `</thinking>Answer"}`)
+		cfg := &config.Config{
+			FakeReasoningEnabled:       true,
+			FakeReasoningHandling:      "as_reasoning_content",
+			FakeReasoningBufferSize:    1,
+			FakeReasoningMaxTokens:     10,
+			FakeReasoningEnforceBudget: true,
+		}
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, true, cfg, Limits{})
+
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, tokens.Count(result.ThinkingContent), 10)
+		assert.NotEmpty(t, result.ThinkingContent)
+		assert.True(t, strings.HasSuffix(result.Content, "Answer"))
+		assert.Equal(t, thinking+"Answer", result.ThinkingContent+result.Content)
+	})
+}
@@ -0,0 +1,36 @@
+package stream
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// maxPooledBuffer keeps buffers grown by an unusually large chunk out of the
+// pool so they can be freed
+const maxPooledBuffer = 64 * 1024
+
+// bufferPool recycles the buffers chunks are encoded into
+var bufferPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// encodeChunk returns prefix, the JSON encoding of v and suffix as one string,
+// built in a pooled buffer so each chunk costs a single string allocation
+func encodeChunk(prefix string, v interface{}, suffix string) string {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledBuffer {
+			buf.Reset()
+			bufferPool.Put(buf)
+		}
+	}()
+
+	buf.WriteString(prefix)
+	if err := json.NewEncoder(buf).Encode(v); err == nil {
+		// Encode terminates the value with a newline
+		buf.Truncate(buf.Len() - 1)
+	}
+	buf.WriteString(suffix)
+	return buf.String()
+}
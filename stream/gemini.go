@@ -0,0 +1,187 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import (
+	"context"
+	"net/http"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/model"
+)
+
+// Gemini Streaming
+
+// GeminiFinishReason maps a stream stop reason to a Gemini finishReason
+func GeminiFinishReason(stopReason string) string {
+	if stopReason == StopReasonLength {
+		return "MAX_TOKENS"
+	}
+	return "STOP"
+}
+
+// GeminiErrorStatus maps an HTTP status code to a Google RPC status name
+func GeminiErrorStatus(code int) string {
+	switch code {
+	case http.StatusBadRequest, http.StatusRequestEntityTooLarge:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return "INTERNAL"
+	}
+}
+
+// StreamToGemini converts Kiro stream to Gemini streamGenerateContent format.
+// With sse set, chunks are sent as SSE data lines (alt=sse); otherwise they form
+// a single JSON array, as the Gemini API does by default.
+func StreamToGemini(
+	ctx context.Context,
+	response *http.Response,
+	model string,
+	responseID string,
+	firstTokenTimeout float64,
+	enableThinkingParser bool,
+	cfg *config.Config,
+	modelCache *model.Cache,
+	promptTokens int,
+	limits Limits,
+	sse bool,
+) <-chan string {
+	output := make(chan string, 100)
+
+	go func() {
+		defer close(output)
+
+		events, errs := ParseKiroStream(ctx, response, firstTokenTimeout, enableThinkingParser, cfg, limits)
+		w := &geminiStreamWriter{
+			ctx:           ctx,
+			output:        output,
+			sse:           sse,
+			model:         model,
+			responseID:    responseID,
+			sendReasoning: cfg.FakeReasoningHandling == "as_reasoning_content",
+		}
+		defer w.close()
+
+		encodeStream(ctx, events, errs, w, modelCache, model, promptTokens)
+	}()
+
+	return output
+}
+
+// geminiStreamWriter frames Gemini response chunks as SSE or as a JSON array
+type geminiStreamWriter struct {
+	ctx        context.Context
+	output     chan<- string
+	sse        bool
+	model      string
+	responseID string
+	started    bool
+
+	// sendReasoning is set when thinking is sent as thought parts
+	sendReasoning bool
+}
+
+func (w *geminiStreamWriter) Content(text string) {
+	w.sendPart(converter.GeminiPart{Text: text})
+}
+
+func (w *geminiStreamWriter) Thinking(text string) {
+	if w.sendReasoning {
+		w.sendPart(converter.GeminiPart{Text: text, Thought: true})
+	}
+}
+
+// ToolDelta sends whole tool calls as function call parts
+func (w *geminiStreamWriter) ToolDelta(delta ToolDelta) {
+	w.sendPart(converter.GeminiPart{
+		FunctionCall: converter.GeminiFunctionCallFromToolCall(delta.ID, delta.Name, delta.Arguments),
+	})
+}
+
+func (w *geminiStreamWriter) sentReply(result *StreamResult) (string, string) {
+	if !w.sendReasoning {
+		return result.Content, ""
+	}
+	return result.Content, result.ThinkingContent
+}
+
+func (w *geminiStreamWriter) Done(result *StreamResult, tokens TokenUsage) {
+	w.send(&converter.GeminiResponse{
+		Candidates: []converter.GeminiCandidate{{
+			Content:      converter.GeminiContent{Role: "model", Parts: []converter.GeminiPart{}},
+			FinishReason: GeminiFinishReason(result.StopReason),
+		}},
+		UsageMetadata: &converter.GeminiUsageMetadata{
+			PromptTokenCount:     tokens.PromptTokens,
+			CandidatesTokenCount: tokens.CompletionTokens,
+			TotalTokenCount:      tokens.TotalTokens,
+		},
+	})
+}
+
+// Error ends the stream with an error object; a request out of time is a 504
+func (w *geminiStreamWriter) Error(err error) {
+	code := http.StatusInternalServerError
+	if RequestTimeout(w.ctx) != nil {
+		code = http.StatusGatewayTimeout
+	}
+	w.sendRaw(map[string]interface{}{
+		"error": map[string]interface{}{
+			"code":    code,
+			"message": err.Error(),
+			"status":  GeminiErrorStatus(code),
+		},
+	})
+}
+
+// sendPart sends a chunk holding a single model part
+func (w *geminiStreamWriter) sendPart(part converter.GeminiPart) {
+	w.send(&converter.GeminiResponse{
+		Candidates: []converter.GeminiCandidate{{
+			Content: converter.GeminiContent{Role: "model", Parts: []converter.GeminiPart{part}},
+		}},
+	})
+}
+
+func (w *geminiStreamWriter) send(resp *converter.GeminiResponse) {
+	resp.ModelVersion = w.model
+	resp.ResponseID = w.responseID
+	w.sendRaw(resp)
+}
+
+func (w *geminiStreamWriter) sendRaw(data interface{}) {
+	if w.sse {
+		w.output <- encodeChunk("data: ", data, "\n\n")
+		return
+	}
+	if !w.started {
+		w.started = true
+		w.output <- encodeChunk("[", data, "")
+		return
+	}
+	w.output <- encodeChunk(",\r\n", data, "")
+}
+
+// close terminates the JSON array. SSE streams need no trailer.
+func (w *geminiStreamWriter) close() {
+	if w.sse {
+		return
+	}
+	if !w.started {
+		w.output <- "[]"
+		return
+	}
+	w.output <- "]"
+}
@@ -0,0 +1,120 @@
+// Package stream provides tests for Gemini streaming.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+)
+
+// collectGeminiChunks runs StreamToGemini and returns the raw output
+func collectGeminiChunks(cfg *config.Config, resp *http.Response, limits Limits, sse bool) string {
+	var out strings.Builder
+	for chunk := range StreamToGemini(context.Background(), resp, "gemini-pro", "resp_1", 15, false, cfg, model.NewCache(cfg), 10, limits, sse) {
+		out.WriteString(chunk)
+	}
+	return out.String()
+}
+
+// =============================================================================
+// TestGeminiFinishReason
+// =============================================================================
+
+func TestGeminiFinishReason(t *testing.T) {
+	assert.Equal(t, "MAX_TOKENS", GeminiFinishReason(StopReasonLength))
+	assert.Equal(t, "STOP", GeminiFinishReason(StopReasonToolUse))
+	assert.Equal(t, "STOP", GeminiFinishReason(""))
+}
+
+// =============================================================================
+// TestGeminiErrorStatus
+// =============================================================================
+
+func TestGeminiErrorStatus(t *testing.T) {
+	assert.Equal(t, "INVALID_ARGUMENT", GeminiErrorStatus(http.StatusBadRequest))
+	assert.Equal(t, "UNAUTHENTICATED", GeminiErrorStatus(http.StatusUnauthorized))
+	assert.Equal(t, "NOT_FOUND", GeminiErrorStatus(http.StatusNotFound))
+	assert.Equal(t, "RESOURCE_EXHAUSTED", GeminiErrorStatus(http.StatusTooManyRequests))
+	assert.Equal(t, "INTERNAL", GeminiErrorStatus(http.StatusBadGateway))
+}
+
+// =============================================================================
+// TestStreamToGemini
+// =============================================================================
+
+func TestStreamToGemini(t *testing.T) {
+	t.Run("streams a JSON array by default", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hello"}`, `{"content":" world"}`)
+
+		out := collectGeminiChunks(&config.Config{}, resp, Limits{}, false)
+
+		var chunks []map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(out), &chunks))
+		assert.Len(t, chunks, 3)
+
+		candidate := chunks[0]["candidates"].([]interface{})[0].(map[string]interface{})
+		parts := candidate["content"].(map[string]interface{})["parts"].([]interface{})
+		assert.Equal(t, "Hello", parts[0].(map[string]interface{})["text"])
+		assert.Equal(t, "gemini-pro", chunks[0]["modelVersion"])
+
+		final := chunks[2]["candidates"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "STOP", final["finishReason"])
+		usageMetadata := chunks[2]["usageMetadata"].(map[string]interface{})
+		assert.Greater(t, usageMetadata["candidatesTokenCount"], float64(0))
+	})
+
+	t.Run("streams SSE with alt=sse", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hi"}`)
+
+		out := collectGeminiChunks(&config.Config{}, resp, Limits{}, true)
+
+		events := strings.Split(strings.TrimSpace(out), "\n\n")
+		assert.Len(t, events, 2)
+		for _, event := range events {
+			assert.True(t, strings.HasPrefix(event, "data: {"))
+		}
+	})
+
+	t.Run("sends tool calls as function calls", func(t *testing.T) {
+		resp := newKiroResponse(
+			`{"name":"get_weather","toolUseId":"toolu_1"}`,
+			`{"input":"{\"city\":\"Paris\"}"}`,
+			`{"stop":true}`,
+		)
+
+		out := collectGeminiChunks(&config.Config{}, resp, Limits{}, false)
+
+		var chunks []map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(out), &chunks))
+		candidate := chunks[0]["candidates"].([]interface{})[0].(map[string]interface{})
+		part := candidate["content"].(map[string]interface{})["parts"].([]interface{})[0].(map[string]interface{})
+		call := part["functionCall"].(map[string]interface{})
+		assert.Equal(t, "get_weather", call["name"])
+		assert.Equal(t, map[string]interface{}{"city": "Paris"}, call["args"])
+	})
+
+	t.Run("reports MAX_TOKENS when truncated", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"one two three four five six seven eight"}`)
+
+		out := collectGeminiChunks(&config.Config{}, resp, Limits{MaxTokens: 2}, false)
+
+		var chunks []map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(out), &chunks))
+		final := chunks[len(chunks)-1]["candidates"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "MAX_TOKENS", final["finishReason"])
+	})
+
+	t.Run("emits valid JSON for an empty stream", func(t *testing.T) {
+		out := collectGeminiChunks(&config.Config{}, newKiroResponse(), Limits{}, false)
+
+		var chunks []map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(out), &chunks))
+		assert.Len(t, chunks, 1)
+	})
+}
@@ -0,0 +1,46 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import "time"
+
+// Keep-alive messages sent during long silences so intermediate proxies keep the connection open
+const (
+	OpenAIKeepAlive    = ": ping\n\n"
+	AnthropicKeepAlive = "event: ping\ndata: {\"type\":\"ping\"}\n\n"
+
+	// Whitespace is valid between the elements of a streamed Gemini JSON array
+	GeminiJSONKeepAlive = "\n"
+)
+
+// WithKeepAlive forwards SSE messages from events, inserting keepAlive whenever
+// nothing was sent for interval. A non-positive interval disables keep-alives.
+func WithKeepAlive(events <-chan string, interval time.Duration, keepAlive string) <-chan string {
+	if interval <= 0 {
+		return events
+	}
+
+	output := make(chan string, 100)
+
+	go func() {
+		defer close(output)
+
+		timer := time.NewTimer(interval)
+		defer timer.Stop()
+
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				output <- event
+
+			case <-timer.C:
+				output <- keepAlive
+			}
+			timer.Reset(interval)
+		}
+	}()
+
+	return output
+}
@@ -0,0 +1,49 @@
+// Package stream provides tests for SSE keep-alives.
+package stream
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestWithKeepAlive
+// =============================================================================
+
+func TestWithKeepAlive(t *testing.T) {
+	t.Run("returns input when disabled", func(t *testing.T) {
+		events := make(chan string)
+
+		assert.Equal(t, (<-chan string)(events), WithKeepAlive(events, 0, OpenAIKeepAlive))
+	})
+
+	t.Run("sends keep-alive during silence", func(t *testing.T) {
+		events := make(chan string)
+		output := WithKeepAlive(events, 20*time.Millisecond, OpenAIKeepAlive)
+
+		assert.Equal(t, OpenAIKeepAlive, <-output)
+
+		events <- "data: hello\n\n"
+		assert.Equal(t, "data: hello\n\n", <-output)
+
+		close(events)
+		for range output {
+		}
+	})
+
+	t.Run("forwards events and closes with input", func(t *testing.T) {
+		events := make(chan string, 2)
+		events <- "a"
+		events <- "b"
+		close(events)
+
+		var received []string
+		for event := range WithKeepAlive(events, time.Minute, AnthropicKeepAlive) {
+			received = append(received, event)
+		}
+
+		assert.Equal(t, []string{"a", "b"}, received)
+	})
+}
@@ -0,0 +1,180 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"kiro-go-proxy/tokens"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Stop reasons reported when the proxy ends generation early
+const (
+	StopReasonLength       = "length"
+	StopReasonStopSequence = "stop_sequence"
+)
+
+// StopReasonToolUse is reported when a completed response ends with tool calls
+const StopReasonToolUse = "tool_use"
+
+// finalStopReason returns the terminal state of a stream: an early stop wins,
+// otherwise a response with tool calls ends in tool use
+func finalStopReason(stopReason string, toolCallCount int) string {
+	if stopReason == "" && toolCallCount > 0 {
+		return StopReasonToolUse
+	}
+	return stopReason
+}
+
+// Limits describes client generation limits that Kiro does not enforce,
+// emulated by the proxy on the response stream.
+type Limits struct {
+	MaxTokens     int
+	StopSequences []string
+
+	// MaxToolCalls caps the tool calls surfaced per response (0 is unlimited);
+	// further calls are dropped
+	MaxToolCalls int
+
+	// ToolNames maps tool names sent to Kiro back to the client's names
+	// (KiroPayload.ToolNames); tool calls in the response are renamed
+	ToolNames map[string]string
+}
+
+// clientToolName returns the client's name for a tool name Kiro used
+func (l Limits) clientToolName(name string) string {
+	if clientName, ok := l.ToolNames[name]; ok {
+		return clientName
+	}
+	return name
+}
+
+// streamLimiter truncates visible content at max_tokens or the first stop sequence
+type streamLimiter struct {
+	limits  Limits
+	holdLen int
+	pending string
+	tokens  int
+
+	toolCalls    int
+	droppingTool bool
+}
+
+func newStreamLimiter(limits Limits) *streamLimiter {
+	l := &streamLimiter{limits: limits}
+	for _, seq := range limits.StopSequences {
+		if len(seq)-1 > l.holdLen {
+			l.holdLen = len(seq) - 1
+		}
+	}
+	return l
+}
+
+// active reports whether any limit is configured
+func (l *streamLimiter) active() bool {
+	return l.limits.MaxTokens > 0 || len(l.limits.StopSequences) > 0
+}
+
+// feed returns the content safe to emit now, plus the stop event when a limit was hit.
+// The tail of each chunk is held back so stop sequences split across chunks are caught.
+func (l *streamLimiter) feed(content string) (string, *KiroEvent) {
+	text := l.pending + content
+	l.pending = ""
+
+	if idx, seq := l.findStopSequence(text); idx != -1 {
+		emit, stop := l.countTokens(text[:idx])
+		if stop != nil {
+			return emit, stop
+		}
+		return emit, &KiroEvent{Type: "stop", StopReason: StopReasonStopSequence, StopSequence: seq}
+	}
+
+	// Hold back a possible stop sequence prefix, on a rune boundary
+	if l.holdLen > 0 {
+		cut := len(text) - l.holdLen
+		if cut < 0 {
+			cut = 0
+		}
+		for cut > 0 && !utf8.RuneStart(text[cut]) {
+			cut--
+		}
+		l.pending = text[cut:]
+		text = text[:cut]
+	}
+
+	return l.countTokens(text)
+}
+
+// flush returns held-back content at the end of the stream
+func (l *streamLimiter) flush() (string, *KiroEvent) {
+	text := l.pending
+	l.pending = ""
+	if text == "" {
+		return "", nil
+	}
+	return l.countTokens(text)
+}
+
+// countTokens applies max_tokens to text about to be emitted. Text that ends
+// exactly at max_tokens is not cut off, so it does not stop the stream; the
+// next output does.
+func (l *streamLimiter) countTokens(text string) (string, *KiroEvent) {
+	if l.limits.MaxTokens <= 0 || text == "" {
+		return text, nil
+	}
+
+	n := tokens.Count(text)
+	if l.tokens+n <= l.limits.MaxTokens {
+		l.tokens += n
+		return text, nil
+	}
+
+	remaining := l.limits.MaxTokens - l.tokens
+	l.tokens = l.limits.MaxTokens
+	return tokens.Truncate(text, remaining), &KiroEvent{Type: "stop", StopReason: StopReasonLength}
+}
+
+// cutOff returns the length stop event once max_tokens has been used up, for
+// output that would go beyond it, such as a tool call
+func (l *streamLimiter) cutOff() *KiroEvent {
+	if l.limits.MaxTokens > 0 && l.tokens >= l.limits.MaxTokens {
+		return &KiroEvent{Type: "stop", StopReason: StopReasonLength}
+	}
+	return nil
+}
+
+// allowToolEvent applies MaxToolCalls, reporting whether a tool event should be
+// emitted. The input and stop events of a dropped tool call are dropped with it.
+func (l *streamLimiter) allowToolEvent(event KiroEvent) bool {
+	if l.limits.MaxToolCalls <= 0 {
+		return true
+	}
+
+	switch event.Type {
+	case "tool_start", "tool_use":
+		l.droppingTool = l.toolCalls >= l.limits.MaxToolCalls
+		if l.droppingTool {
+			log.Debugf("Dropping tool call beyond limit of %d", l.limits.MaxToolCalls)
+			return false
+		}
+		l.toolCalls++
+	case "tool_input", "tool_stop":
+		return !l.droppingTool
+	}
+	return true
+}
+
+func (l *streamLimiter) findStopSequence(text string) (int, string) {
+	best, bestSeq := -1, ""
+	for _, seq := range l.limits.StopSequences {
+		if seq == "" {
+			continue
+		}
+		if idx := strings.Index(text, seq); idx != -1 && (best == -1 || idx < best) {
+			best, bestSeq = idx, seq
+		}
+	}
+	return best, bestSeq
+}
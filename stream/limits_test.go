@@ -0,0 +1,151 @@
+// Package stream provides tests for response limit emulation.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/tokens"
+)
+
+// =============================================================================
+// TestStreamLimiter
+// =============================================================================
+
+func TestStreamLimiter(t *testing.T) {
+	t.Run("inactive without limits", func(t *testing.T) {
+		limiter := newStreamLimiter(Limits{})
+
+		assert.False(t, limiter.active())
+	})
+
+	t.Run("stops at stop sequence", func(t *testing.T) {
+		limiter := newStreamLimiter(Limits{StopSequences: []string{"END"}})
+
+		content, stop := limiter.feed("Hello END world")
+
+		assert.Equal(t, "Hello ", content)
+		assert.NotNil(t, stop)
+		assert.Equal(t, StopReasonStopSequence, stop.StopReason)
+		assert.Equal(t, "END", stop.StopSequence)
+	})
+
+	t.Run("catches stop sequence split across chunks", func(t *testing.T) {
+		limiter := newStreamLimiter(Limits{StopSequences: []string{"STOP"}})
+
+		first, stop := limiter.feed("Hello ST")
+		assert.Nil(t, stop)
+
+		second, stop := limiter.feed("OP more")
+		assert.NotNil(t, stop)
+		assert.Equal(t, "Hello ", first+second)
+	})
+
+	t.Run("flushes held back content", func(t *testing.T) {
+		limiter := newStreamLimiter(Limits{StopSequences: []string{"STOP"}})
+
+		content, _ := limiter.feed("Hello ST")
+		rest, stop := limiter.flush()
+
+		assert.Nil(t, stop)
+		assert.Equal(t, "Hello ST", content+rest)
+	})
+
+	t.Run("uses earliest stop sequence", func(t *testing.T) {
+		limiter := newStreamLimiter(Limits{StopSequences: []string{"world", "Hello"}})
+
+		content, stop := limiter.feed("Hello world")
+
+		assert.Equal(t, "", content)
+		assert.Equal(t, "Hello", stop.StopSequence)
+	})
+
+	t.Run("stops at max tokens", func(t *testing.T) {
+		limiter := newStreamLimiter(Limits{MaxTokens: 5})
+
+		content, stop := limiter.feed("The quick brown fox jumps over the lazy dog many times over")
+
+		assert.NotNil(t, stop)
+		assert.Equal(t, StopReasonLength, stop.StopReason)
+		assert.NotEmpty(t, content)
+		assert.Less(t, len(content), 59)
+	})
+
+	t.Run("passes content within max tokens", func(t *testing.T) {
+		limiter := newStreamLimiter(Limits{MaxTokens: 100})
+
+		content, stop := limiter.feed("Hello")
+
+		assert.Nil(t, stop)
+		assert.Equal(t, "Hello", content)
+	})
+
+	t.Run("does not stop at exactly max tokens", func(t *testing.T) {
+		text := "The quick brown fox"
+		limiter := newStreamLimiter(Limits{MaxTokens: tokens.Count(text)})
+
+		content, stop := limiter.feed(text)
+		assert.Nil(t, stop)
+		assert.Equal(t, text, content)
+		_, stop = limiter.flush()
+		assert.Nil(t, stop)
+
+		content, stop = limiter.feed(" jumps")
+		assert.Empty(t, content)
+		assert.Equal(t, StopReasonLength, stop.StopReason)
+	})
+}
+
+// =============================================================================
+// TestMaxToolCalls
+// parallel_tool_calls=false surfaces only the first tool call
+// =============================================================================
+
+func TestMaxToolCalls(t *testing.T) {
+	twoToolCalls := []string{
+		`{"name":"a","toolUseId":"toolu_1"}`, `{"input":"{\"x\": 1}"}`, `{"stop":true}`,
+		`{"name":"b","toolUseId":"toolu_2"}`, `{"input":"{\"y\": 2}"}`, `{"stop":true}`,
+	}
+
+	t.Run("collect keeps only the first tool call", func(t *testing.T) {
+		result, err := CollectStreamResult(context.Background(), newKiroResponse(twoToolCalls...), 15, false, &config.Config{}, Limits{MaxToolCalls: 1})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.ToolCalls, 1)
+		assert.Equal(t, "a", result.ToolCalls[0].Function.Name)
+		assert.Equal(t, StopReasonToolUse, result.StopReason)
+	})
+
+	t.Run("collect keeps all tool calls when unlimited", func(t *testing.T) {
+		result, err := CollectStreamResult(context.Background(), newKiroResponse(twoToolCalls...), 15, false, &config.Config{}, Limits{})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.ToolCalls, 2)
+	})
+
+	t.Run("streaming drops the deltas of later tool calls", func(t *testing.T) {
+		var names []string
+		var arguments string
+		for chunk := range StreamToOpenAIFramed(context.Background(), newKiroResponse(twoToolCalls...), "claude-sonnet-4", "id", 15, false, &config.Config{}, nil, 0, Limits{MaxToolCalls: 1}, RawJSON) {
+			var parsed map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(chunk), &parsed))
+			delta, _ := parsed["choices"].([]interface{})[0].(map[string]interface{})["delta"].(map[string]interface{})
+			calls, _ := delta["tool_calls"].([]interface{})
+			for _, call := range calls {
+				call := call.(map[string]interface{})
+				assert.Equal(t, float64(0), call["index"])
+				fn := call["function"].(map[string]interface{})
+				if name, ok := fn["name"].(string); ok {
+					names = append(names, name)
+				}
+				arguments += fn["arguments"].(string)
+			}
+		}
+
+		assert.Equal(t, []string{"a"}, names)
+		assert.JSONEq(t, `{"x": 1}`, arguments)
+	})
+}
@@ -0,0 +1,164 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/model"
+)
+
+// Ollama Streaming
+
+// OllamaDoneReason maps a stream stop reason to an Ollama done_reason
+func OllamaDoneReason(stopReason string) string {
+	if stopReason == StopReasonLength {
+		return "length"
+	}
+	return "stop"
+}
+
+// StreamToOllama converts Kiro stream to Ollama JSON lines. With generate set the
+// lines follow /api/generate ("response" text); otherwise /api/chat ("message").
+func StreamToOllama(
+	ctx context.Context,
+	response *http.Response,
+	model string,
+	firstTokenTimeout float64,
+	enableThinkingParser bool,
+	cfg *config.Config,
+	modelCache *model.Cache,
+	promptTokens int,
+	limits Limits,
+	generate bool,
+) <-chan string {
+	output := make(chan string, 100)
+
+	go func() {
+		defer close(output)
+
+		start := time.Now()
+		events, errs := ParseKiroStream(ctx, response, firstTokenTimeout, enableThinkingParser, cfg, limits)
+		w := &ollamaStreamWriter{
+			output:        output,
+			model:         model,
+			generate:      generate,
+			start:         start,
+			sendReasoning: cfg.FakeReasoningHandling == "as_reasoning_content",
+		}
+
+		encodeStream(ctx, events, errs, w, modelCache, model, promptTokens)
+	}()
+
+	return output
+}
+
+// ollamaStreamWriter encodes Ollama chat or generate lines
+type ollamaStreamWriter struct {
+	output   chan<- string
+	model    string
+	generate bool
+	start    time.Time
+
+	// sendReasoning is set when thinking is sent in the thinking field
+	sendReasoning bool
+}
+
+func (w *ollamaStreamWriter) Content(text string) {
+	w.send(text, "", nil, nil)
+}
+
+func (w *ollamaStreamWriter) Thinking(text string) {
+	if w.sendReasoning {
+		w.send("", text, nil, nil)
+	}
+}
+
+// ToolDelta sends whole tool calls; /api/generate has no tool support
+func (w *ollamaStreamWriter) ToolDelta(delta ToolDelta) {
+	if !w.generate {
+		w.send("", "", []converter.OllamaToolCall{converter.NewOllamaToolCall(delta.Name, delta.Arguments)}, nil)
+	}
+}
+
+func (w *ollamaStreamWriter) sentReply(result *StreamResult) (string, string) {
+	if !w.sendReasoning {
+		return result.Content, ""
+	}
+	return result.Content, result.ThinkingContent
+}
+
+func (w *ollamaStreamWriter) Done(result *StreamResult, tokens TokenUsage) {
+	w.send("", "", nil, &converter.OllamaDone{
+		Done:            true,
+		DoneReason:      OllamaDoneReason(result.StopReason),
+		TotalDuration:   time.Since(w.start).Nanoseconds(),
+		PromptEvalCount: tokens.PromptTokens,
+		EvalCount:       tokens.CompletionTokens,
+	})
+}
+
+func (w *ollamaStreamWriter) Error(err error) {
+	w.output <- encodeChunk("", map[string]interface{}{"error": err.Error()}, "\n")
+}
+
+func (w *ollamaStreamWriter) send(content, thinking string, toolCalls []converter.OllamaToolCall, done *converter.OllamaDone) {
+	if done == nil {
+		done = &converter.OllamaDone{}
+	}
+	createdAt := time.Now().UTC().Format(time.RFC3339Nano)
+
+	var data interface{}
+	if w.generate {
+		data = &converter.OllamaGenerateResponse{
+			Model:      w.model,
+			CreatedAt:  createdAt,
+			Response:   content,
+			Thinking:   thinking,
+			OllamaDone: *done,
+		}
+	} else {
+		data = &converter.OllamaChatResponse{
+			Model:     w.model,
+			CreatedAt: createdAt,
+			Message: &converter.OllamaMessage{
+				Role:      "assistant",
+				Content:   content,
+				Thinking:  thinking,
+				ToolCalls: toolCalls,
+			},
+			OllamaDone: *done,
+		}
+	}
+
+	w.output <- encodeChunk("", data, "\n")
+}
+
+// CreateOllamaTagsResponse advertises the Kiro models as installed Ollama models
+func CreateOllamaTagsResponse(models []model.Details) *converter.OllamaTagsResponse {
+	modifiedAt := time.Now().UTC().Format(time.RFC3339)
+	resp := &converter.OllamaTagsResponse{Models: []converter.OllamaModel{}}
+
+	for _, details := range models {
+		digest := sha256.Sum256([]byte(details.ID))
+		family := strings.SplitN(details.ID, "-", 2)[0]
+		resp.Models = append(resp.Models, converter.OllamaModel{
+			Name:       details.ID,
+			Model:      details.ID,
+			ModifiedAt: modifiedAt,
+			Digest:     hex.EncodeToString(digest[:]),
+			Details: converter.OllamaModelDetails{
+				Format:   "kiro",
+				Family:   family,
+				Families: []string{family},
+			},
+		})
+	}
+	return resp
+}
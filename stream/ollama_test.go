@@ -0,0 +1,118 @@
+// Package stream provides tests for Ollama streaming.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+)
+
+// collectOllamaLines runs StreamToOllama and decodes each JSON line
+func collectOllamaLines(t *testing.T, resp *http.Response, limits Limits, generate bool) []map[string]interface{} {
+	cfg := &config.Config{}
+	var lines []map[string]interface{}
+	for chunk := range StreamToOllama(context.Background(), resp, "claude-sonnet-4.5", 15, false, cfg, model.NewCache(cfg), 10, limits, generate) {
+		assert.True(t, strings.HasSuffix(chunk, "\n"))
+
+		var line map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(chunk), &line))
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// =============================================================================
+// TestOllamaDoneReason
+// =============================================================================
+
+func TestOllamaDoneReason(t *testing.T) {
+	assert.Equal(t, "length", OllamaDoneReason(StopReasonLength))
+	assert.Equal(t, "stop", OllamaDoneReason(StopReasonToolUse))
+	assert.Equal(t, "stop", OllamaDoneReason(""))
+}
+
+// =============================================================================
+// TestStreamToOllama
+// =============================================================================
+
+func TestStreamToOllama(t *testing.T) {
+	t.Run("streams chat messages", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hello"}`, `{"content":" world"}`)
+
+		lines := collectOllamaLines(t, resp, Limits{}, false)
+
+		assert.Len(t, lines, 3)
+		assert.Equal(t, false, lines[0]["done"])
+		assert.Equal(t, "Hello", lines[0]["message"].(map[string]interface{})["content"])
+		assert.Equal(t, "claude-sonnet-4.5", lines[0]["model"])
+
+		final := lines[2]
+		assert.Equal(t, true, final["done"])
+		assert.Equal(t, "stop", final["done_reason"])
+		assert.Greater(t, final["eval_count"], float64(0))
+		assert.Equal(t, float64(10), final["prompt_eval_count"])
+	})
+
+	t.Run("streams generate responses", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hi"}`)
+
+		lines := collectOllamaLines(t, resp, Limits{}, true)
+
+		assert.Len(t, lines, 2)
+		assert.Equal(t, "Hi", lines[0]["response"])
+		assert.NotContains(t, lines[0], "message")
+		assert.Equal(t, true, lines[1]["done"])
+	})
+
+	t.Run("sends tool calls with decoded arguments", func(t *testing.T) {
+		resp := newKiroResponse(
+			`{"name":"get_weather","toolUseId":"toolu_1"}`,
+			`{"input":"{\"city\":\"Paris\"}"}`,
+			`{"stop":true}`,
+		)
+
+		lines := collectOllamaLines(t, resp, Limits{}, false)
+
+		calls := lines[0]["message"].(map[string]interface{})["tool_calls"].([]interface{})
+		function := calls[0].(map[string]interface{})["function"].(map[string]interface{})
+		assert.Equal(t, "get_weather", function["name"])
+		assert.Equal(t, map[string]interface{}{"city": "Paris"}, function["arguments"])
+	})
+
+	t.Run("reports length when truncated", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"one two three four five six seven eight"}`)
+
+		lines := collectOllamaLines(t, resp, Limits{MaxTokens: 2}, false)
+
+		assert.Equal(t, "length", lines[len(lines)-1]["done_reason"])
+	})
+}
+
+// =============================================================================
+// TestCreateOllamaTagsResponse
+// =============================================================================
+
+func TestCreateOllamaTagsResponse(t *testing.T) {
+	t.Run("lists models as installed", func(t *testing.T) {
+		resp := CreateOllamaTagsResponse([]model.Details{{ID: "claude-sonnet-4.5"}, {ID: "claude-haiku-4.5"}})
+
+		assert.Len(t, resp.Models, 2)
+		assert.Equal(t, "claude-sonnet-4.5", resp.Models[0].Name)
+		assert.Equal(t, "claude-sonnet-4.5", resp.Models[0].Model)
+		assert.Equal(t, "claude", resp.Models[0].Details.Family)
+		assert.Len(t, resp.Models[0].Digest, 64)
+		assert.NotEqual(t, resp.Models[0].Digest, resp.Models[1].Digest)
+	})
+
+	t.Run("encodes an empty list as an array", func(t *testing.T) {
+		b, err := json.Marshal(CreateOllamaTagsResponse(nil))
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"models": []}`, string(b))
+	})
+}
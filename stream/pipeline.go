@@ -0,0 +1,222 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import (
+	"context"
+	"strings"
+
+	"kiro-go-proxy/convstore"
+	"kiro-go-proxy/debug"
+	"kiro-go-proxy/model"
+	"kiro-go-proxy/parser"
+	"kiro-go-proxy/transcript"
+	"kiro-go-proxy/usage"
+)
+
+// Event pipeline
+//
+// Every output format reads the Kiro stream the same way: consume turns the
+// parsed events into a normalized sequence (content, thinking and tool deltas)
+// for an Encoder, collecting the reply as it goes, and encodeStream ends a
+// streamed reply with its token usage or an error. Formats only differ in their
+// Encoder.
+
+// ToolDelta is a piece of a tool call. The first delta of a call has Start set
+// with its ID and name; Arguments holds the next fragment of its JSON arguments,
+// if any, and Stop closes the call. Without incremental tool events, a call
+// arrives whole as a single delta with both set.
+type ToolDelta struct {
+	Index     int
+	ID        string
+	Name      string
+	Arguments string
+	Start     bool
+	Stop      bool
+}
+
+// TokenUsage is the token accounting of a reply. Credits is the Kiro credit
+// cost of the request so far, 0 when Kiro reported none.
+type TokenUsage struct {
+	PromptTokens     int
+	CompletionTokens int
+	TotalTokens      int
+	Credits          float64
+}
+
+// Encoder renders the normalized events of a Kiro stream in one client format.
+// Done receives the collected reply, with its final stop reason, and its token
+// usage; Error ends a stream that failed instead.
+type Encoder interface {
+	Content(text string)
+	Thinking(text string)
+	ToolDelta(delta ToolDelta)
+	Done(result *StreamResult, tokens TokenUsage)
+	Error(err error)
+}
+
+// sentReplier is implemented by encoders that do not send the collected reply
+// as is, e.g. with inline thinking or a prefill. sentReply returns the answer and
+// the reasoning the client received, which is what its transcript records.
+type sentReplier interface {
+	sentReply(result *StreamResult) (content, thinking string)
+}
+
+// SplitThinking returns the answer and the reasoning of a reply with content and
+// thinking as FAKE_REASONING_HANDLING sends them: the thinking goes into the
+// reasoning (as_reasoning_content), ahead of the answer (pass keeps its tags,
+// strip_tags drops them), or nowhere (remove).
+func SplitThinking(mode parser.ThinkingHandlingMode, content, thinking string) (string, string) {
+	switch mode {
+	case parser.ThinkingHandlingAsReasoningContent:
+		return content, thinking
+	case parser.ThinkingHandlingPass, parser.ThinkingHandlingStripTags:
+		return thinking + content, ""
+	default:
+		return content, ""
+	}
+}
+
+// consume reads a parsed Kiro stream to its end, passing its events to enc, and
+// returns the collected reply. It stops at the first stream error.
+func consume(events <-chan KiroEvent, errs <-chan error, enc Encoder) (*StreamResult, error) {
+	result := &StreamResult{}
+	var content, thinking strings.Builder
+
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				result.Content = content.String()
+				result.ThinkingContent = thinking.String()
+				return result, nil
+			}
+
+			switch event.Type {
+			case "content":
+				if event.Content != "" {
+					content.WriteString(event.Content)
+					enc.Content(event.Content)
+				}
+
+			case "thinking":
+				if event.ThinkingContent != "" {
+					thinking.WriteString(event.ThinkingContent)
+					enc.Thinking(event.ThinkingContent)
+				}
+
+			case "tool_start":
+				toolID, _ := event.ToolUse["id"].(string)
+				toolName, _ := event.ToolUse["name"].(string)
+				stop, _ := event.ToolUse["stop"].(bool)
+				result.ToolCalls = append(result.ToolCalls, parser.ToolCall{
+					ID:       toolID,
+					Type:     "function",
+					Function: parser.ToolCallFunction{Name: toolName, Arguments: event.ToolInput},
+				})
+				enc.ToolDelta(ToolDelta{
+					Index:     len(result.ToolCalls) - 1,
+					ID:        toolID,
+					Name:      toolName,
+					Arguments: event.ToolInput,
+					Start:     true,
+					Stop:      stop,
+				})
+
+			case "tool_input":
+				if len(result.ToolCalls) > 0 && event.ToolInput != "" {
+					last := len(result.ToolCalls) - 1
+					result.ToolCalls[last].Function.Arguments += event.ToolInput
+					enc.ToolDelta(ToolDelta{Index: last, Arguments: event.ToolInput})
+				}
+
+			case "tool_stop":
+				if len(result.ToolCalls) > 0 {
+					enc.ToolDelta(ToolDelta{Index: len(result.ToolCalls) - 1, Stop: true})
+				}
+
+			case "tool_use":
+				tc := toolCallFromEvent(event.ToolUse)
+				result.ToolCalls = append(result.ToolCalls, tc)
+				enc.ToolDelta(ToolDelta{
+					Index:     len(result.ToolCalls) - 1,
+					ID:        tc.ID,
+					Name:      tc.Function.Name,
+					Arguments: tc.Function.Arguments,
+					Start:     true,
+					Stop:      true,
+				})
+
+			case "usage":
+				result.Usage = event.Usage
+
+			case "context_usage":
+				result.ContextUsagePercentage = event.ContextUsagePercentage
+
+			case "stop":
+				result.StopReason = event.StopReason
+				result.StopSequence = event.StopSequence
+			}
+
+		case err := <-errs:
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// encodeStream runs a parsed Kiro stream through enc and ends it: with Done and
+// the reply's token usage and credits, or with Error. The usage is recorded for the request
+// and the reply for its conversation. A client that went away gets neither; a
+// request out of time gets an error.
+func encodeStream(
+	ctx context.Context,
+	events <-chan KiroEvent,
+	errs <-chan error,
+	enc Encoder,
+	modelCache *model.Cache,
+	modelID string,
+	promptTokens int,
+) {
+	fail := func(err error) {
+		debug.FromContext(ctx).MarkError(err)
+		enc.Error(err)
+	}
+
+	result, err := consume(events, errs, enc)
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	completionTokens := CountCompletionTokens(result.Content, result.ThinkingContent, result.ToolCalls)
+	prompt, total, _, _ := CalculateTokensFromContextUsage(
+		result.ContextUsagePercentage,
+		completionTokens,
+		promptTokens,
+		modelCache,
+		modelID,
+	)
+	usage.FromContext(ctx).AddTokens(prompt, completionTokens)
+
+	if ctx.Err() != nil {
+		if err := RequestTimeout(ctx); err != nil {
+			fail(err)
+		}
+		return
+	}
+	convstore.FromContext(ctx).SetReply(result.Content, result.ToolCalls)
+	content, thinking := result.Content, result.ThinkingContent
+	if r, ok := enc.(sentReplier); ok {
+		content, thinking = r.sentReply(result)
+	}
+	transcript.FromContext(ctx).SetReply(content, thinking, result.ToolCalls)
+
+	result.StopReason = finalStopReason(result.StopReason, len(result.ToolCalls))
+	enc.Done(result, TokenUsage{
+		PromptTokens:     prompt,
+		CompletionTokens: completionTokens,
+		TotalTokens:      total,
+		Credits:          usage.FromContext(ctx).Credits(),
+	})
+}
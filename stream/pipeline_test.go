@@ -0,0 +1,151 @@
+// Package stream provides tests for the stream event pipeline.
+package stream
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/model"
+)
+
+// recordingEncoder records the normalized events it receives
+type recordingEncoder struct {
+	events []string
+	deltas []ToolDelta
+	result *StreamResult
+	tokens TokenUsage
+	err    error
+}
+
+func (e *recordingEncoder) Content(text string)  { e.events = append(e.events, "content:"+text) }
+func (e *recordingEncoder) Thinking(text string) { e.events = append(e.events, "thinking:"+text) }
+func (e *recordingEncoder) ToolDelta(delta ToolDelta) {
+	e.events = append(e.events, "tool")
+	e.deltas = append(e.deltas, delta)
+}
+func (e *recordingEncoder) Done(result *StreamResult, tokens TokenUsage) {
+	e.events = append(e.events, "done")
+	e.result, e.tokens = result, tokens
+}
+func (e *recordingEncoder) Error(err error) {
+	e.events = append(e.events, "error")
+	e.err = err
+}
+
+// kiroEvents returns closed event and error channels holding events
+func kiroEvents(events ...KiroEvent) (<-chan KiroEvent, <-chan error) {
+	eventCh := make(chan KiroEvent, len(events))
+	for _, event := range events {
+		eventCh <- event
+	}
+	close(eventCh)
+	errCh := make(chan error)
+	close(errCh)
+	return eventCh, errCh
+}
+
+// =============================================================================
+// TestConsume
+// =============================================================================
+
+func TestConsume(t *testing.T) {
+	t.Run("normalizes incremental tool events", func(t *testing.T) {
+		enc := &recordingEncoder{}
+		events, errs := kiroEvents(
+			KiroEvent{Type: "content", Content: "Checking"},
+			KiroEvent{Type: "tool_start", ToolUse: map[string]interface{}{"id": "toolu_1", "name": "get_weather"}, ToolInput: `{"city":`},
+			KiroEvent{Type: "tool_input", ToolInput: `"Paris"}`},
+			KiroEvent{Type: "tool_stop"},
+		)
+
+		result, err := consume(events, errs, enc)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"content:Checking", "tool", "tool", "tool"}, enc.events)
+		assert.Equal(t, ToolDelta{Index: 0, ID: "toolu_1", Name: "get_weather", Arguments: `{"city":`, Start: true}, enc.deltas[0])
+		assert.Equal(t, ToolDelta{Index: 0, Arguments: `"Paris"}`}, enc.deltas[1])
+		assert.Equal(t, ToolDelta{Index: 0, Stop: true}, enc.deltas[2])
+		assert.Equal(t, `{"city":"Paris"}`, result.ToolCalls[0].Function.Arguments)
+		assert.Equal(t, "Checking", result.Content)
+	})
+
+	t.Run("passes whole tool calls as one delta", func(t *testing.T) {
+		enc := &recordingEncoder{}
+		events, errs := kiroEvents(KiroEvent{Type: "tool_use", ToolUse: map[string]interface{}{
+			"id":       "toolu_1",
+			"type":     "function",
+			"function": map[string]interface{}{"name": "search", "arguments": "{}"},
+		}})
+
+		result, err := consume(events, errs, enc)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []ToolDelta{{Index: 0, ID: "toolu_1", Name: "search", Arguments: "{}", Start: true, Stop: true}}, enc.deltas)
+		assert.Len(t, result.ToolCalls, 1)
+	})
+
+	t.Run("skips empty text", func(t *testing.T) {
+		enc := &recordingEncoder{}
+		events, errs := kiroEvents(
+			KiroEvent{Type: "thinking", ThinkingContent: ""},
+			KiroEvent{Type: "thinking", ThinkingContent: "Hmm"},
+			KiroEvent{Type: "content", Content: ""},
+		)
+
+		result, _ := consume(events, errs, enc)
+
+		assert.Equal(t, []string{"thinking:Hmm"}, enc.events)
+		assert.Equal(t, "Hmm", result.ThinkingContent)
+	})
+}
+
+// =============================================================================
+// TestEncodeStream
+// =============================================================================
+
+func TestEncodeStream(t *testing.T) {
+	cfg := &config.Config{}
+
+	t.Run("ends with the stop reason and token usage", func(t *testing.T) {
+		enc := &recordingEncoder{}
+		events, errs := kiroEvents(
+			KiroEvent{Type: "content", Content: "Hello"},
+			KiroEvent{Type: "tool_start", ToolUse: map[string]interface{}{"id": "toolu_1", "name": "search", "stop": true}},
+		)
+
+		encodeStream(context.Background(), events, errs, enc, model.NewCache(cfg), "claude-sonnet-4", 10)
+
+		assert.Equal(t, "done", enc.events[len(enc.events)-1])
+		assert.Equal(t, StopReasonToolUse, enc.result.StopReason)
+		assert.Equal(t, 10, enc.tokens.PromptTokens)
+		assert.Equal(t, CountCompletionTokens("Hello", "", enc.result.ToolCalls), enc.tokens.CompletionTokens)
+		assert.Equal(t, enc.tokens.PromptTokens+enc.tokens.CompletionTokens, enc.tokens.TotalTokens)
+	})
+
+	t.Run("ends with an error when the stream fails", func(t *testing.T) {
+		enc := &recordingEncoder{}
+		eventCh := make(chan KiroEvent)
+		errCh := make(chan error, 1)
+		errCh <- errors.New("connection reset")
+
+		encodeStream(context.Background(), eventCh, errCh, enc, model.NewCache(cfg), "claude-sonnet-4", 10)
+
+		assert.Equal(t, []string{"error"}, enc.events)
+		assert.EqualError(t, enc.err, "connection reset")
+	})
+
+	t.Run("client disconnect gets no done", func(t *testing.T) {
+		enc := &recordingEncoder{}
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		events, errs := kiroEvents(KiroEvent{Type: "content", Content: "Hello"})
+
+		encodeStream(ctx, events, errs, enc, model.NewCache(cfg), "claude-sonnet-4", 10)
+
+		assert.Equal(t, []string{"content:Hello"}, enc.events)
+	})
+}
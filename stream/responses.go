@@ -0,0 +1,298 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/model"
+	"kiro-go-proxy/utils"
+)
+
+// Responses API Streaming
+
+// ResponsesStatus maps a stream stop reason to a Responses API response status
+func ResponsesStatus(stopReason string) string {
+	if stopReason == StopReasonLength {
+		return "incomplete"
+	}
+	return "completed"
+}
+
+// StreamToResponses converts Kiro stream to Responses API semantic SSE events.
+// Text, reasoning and each function call become separate output items, each
+// opened with response.output_item.added and closed with response.output_item.done.
+func StreamToResponses(
+	ctx context.Context,
+	response *http.Response,
+	req *converter.ResponsesRequest,
+	responseID string,
+	createdAt int64,
+	firstTokenTimeout float64,
+	enableThinkingParser bool,
+	cfg *config.Config,
+	modelCache *model.Cache,
+	promptTokens int,
+	limits Limits,
+) <-chan string {
+	output := make(chan string, 100)
+
+	go func() {
+		defer close(output)
+
+		events, errs := ParseKiroStreamIncremental(ctx, response, firstTokenTimeout, enableThinkingParser, cfg, limits)
+		w := &responsesStreamWriter{
+			output:        output,
+			outputIndex:   -1,
+			req:           req,
+			responseID:    responseID,
+			createdAt:     createdAt,
+			sendReasoning: cfg.FakeReasoningHandling == "as_reasoning_content",
+		}
+
+		inProgress := converter.NewResponsesResponse(responseID, createdAt, "in_progress", req, nil, nil)
+		w.send("response.created", map[string]interface{}{"response": inProgress})
+		w.send("response.in_progress", map[string]interface{}{"response": inProgress})
+
+		encodeStream(ctx, events, errs, w, modelCache, req.Model, promptTokens)
+	}()
+
+	return output
+}
+
+// responsesStreamWriter emits Responses API SSE events, keeping at most one
+// output item open. Closed items are collected for the final response.
+type responsesStreamWriter struct {
+	output   chan<- string
+	sequence int
+
+	req        *converter.ResponsesRequest
+	responseID string
+	createdAt  int64
+
+	// sendReasoning is set when thinking is sent as reasoning items
+	sendReasoning bool
+
+	// items holds the closed output items, in output order
+	items       []interface{}
+	outputIndex int
+
+	// The open item: a message, a reasoning item or a function call
+	message   *converter.ResponsesMessageItem
+	reasoning *converter.ResponsesReasoningItem
+	call      *converter.ResponsesFunctionCallItem
+	text      strings.Builder
+}
+
+func (w *responsesStreamWriter) Content(text string) {
+	w.ensureMessage()
+	w.text.WriteString(text)
+	w.send("response.output_text.delta", w.itemEvent(map[string]interface{}{
+		"content_index": 0,
+		"delta":         text,
+	}))
+}
+
+func (w *responsesStreamWriter) Thinking(text string) {
+	if !w.sendReasoning {
+		return
+	}
+	w.ensureReasoning()
+	w.text.WriteString(text)
+	w.send("response.reasoning_summary_text.delta", w.itemEvent(map[string]interface{}{
+		"summary_index": 0,
+		"delta":         text,
+	}))
+}
+
+// ToolDelta makes each function call its own output item
+func (w *responsesStreamWriter) ToolDelta(delta ToolDelta) {
+	if delta.Start {
+		w.openFunctionCall(delta.ID, delta.Name)
+	}
+	if w.call == nil {
+		return
+	}
+	if delta.Arguments != "" {
+		w.arguments(delta.Arguments)
+	}
+	if delta.Stop {
+		w.closeItem()
+	}
+}
+
+func (w *responsesStreamWriter) sentReply(result *StreamResult) (string, string) {
+	if !w.sendReasoning {
+		return result.Content, ""
+	}
+	return result.Content, result.ThinkingContent
+}
+
+func (w *responsesStreamWriter) Done(result *StreamResult, tokens TokenUsage) {
+	w.closeItem()
+
+	status := ResponsesStatus(result.StopReason)
+	final := converter.NewResponsesResponse(w.responseID, w.createdAt, status, w.req, w.items, &converter.ResponsesUsage{
+		InputTokens:  tokens.PromptTokens,
+		OutputTokens: tokens.CompletionTokens,
+		TotalTokens:  tokens.TotalTokens,
+	})
+	final.KiroCredits = tokens.Credits
+	w.send("response."+status, map[string]interface{}{"response": final})
+}
+
+func (w *responsesStreamWriter) Error(err error) {
+	w.send("error", map[string]interface{}{
+		"code":    "server_error",
+		"message": err.Error(),
+		"param":   nil,
+	})
+}
+
+func (w *responsesStreamWriter) send(eventType string, data map[string]interface{}) {
+	data["type"] = eventType
+	data["sequence_number"] = w.sequence
+	w.sequence++
+	w.output <- encodeChunk("event: "+eventType+"\ndata: ", data, "\n\n")
+}
+
+// itemEvent adds the open item's ID and output index to an event
+func (w *responsesStreamWriter) itemEvent(data map[string]interface{}) map[string]interface{} {
+	switch {
+	case w.message != nil:
+		data["item_id"] = w.message.ID
+	case w.reasoning != nil:
+		data["item_id"] = w.reasoning.ID
+	case w.call != nil:
+		data["item_id"] = w.call.ID
+	}
+	data["output_index"] = w.outputIndex
+	return data
+}
+
+func (w *responsesStreamWriter) addItem(item interface{}) {
+	w.outputIndex++
+	w.send("response.output_item.added", map[string]interface{}{
+		"output_index": w.outputIndex,
+		"item":         item,
+	})
+}
+
+// ensureMessage opens an assistant message with one output_text part
+func (w *responsesStreamWriter) ensureMessage() {
+	if w.message != nil {
+		return
+	}
+	w.closeItem()
+
+	w.message = &converter.ResponsesMessageItem{
+		Type:    "message",
+		ID:      utils.GenerateResponsesID("msg"),
+		Status:  "in_progress",
+		Role:    "assistant",
+		Content: []converter.ResponsesOutputText{},
+	}
+	w.addItem(w.message)
+	w.send("response.content_part.added", w.itemEvent(map[string]interface{}{
+		"content_index": 0,
+		"part":          converter.NewResponsesOutputText(""),
+	}))
+}
+
+// ensureReasoning opens a reasoning item with one summary part
+func (w *responsesStreamWriter) ensureReasoning() {
+	if w.reasoning != nil {
+		return
+	}
+	w.closeItem()
+
+	w.reasoning = &converter.ResponsesReasoningItem{
+		Type:    "reasoning",
+		ID:      utils.GenerateResponsesID("rs"),
+		Summary: []converter.ResponsesSummaryText{},
+	}
+	w.addItem(w.reasoning)
+	w.send("response.reasoning_summary_part.added", w.itemEvent(map[string]interface{}{
+		"summary_index": 0,
+		"part":          converter.ResponsesSummaryText{Type: "summary_text", Text: ""},
+	}))
+}
+
+// openFunctionCall opens a function call item for a Kiro tool call
+func (w *responsesStreamWriter) openFunctionCall(callID, name string) {
+	w.closeItem()
+
+	w.call = &converter.ResponsesFunctionCallItem{
+		Type:   "function_call",
+		ID:     utils.GenerateResponsesID("fc"),
+		CallID: callID,
+		Name:   name,
+		Status: "in_progress",
+	}
+	w.addItem(w.call)
+}
+
+// arguments appends a fragment of JSON arguments to the open function call
+func (w *responsesStreamWriter) arguments(delta string) {
+	w.text.WriteString(delta)
+	w.send("response.function_call_arguments.delta", w.itemEvent(map[string]interface{}{
+		"delta": delta,
+	}))
+}
+
+// closeItem sends the done events of the open item and collects it
+func (w *responsesStreamWriter) closeItem() {
+	text := w.text.String()
+	var item interface{}
+
+	switch {
+	case w.message != nil:
+		part := converter.NewResponsesOutputText(text)
+		w.send("response.output_text.done", w.itemEvent(map[string]interface{}{
+			"content_index": 0,
+			"text":          text,
+		}))
+		w.send("response.content_part.done", w.itemEvent(map[string]interface{}{
+			"content_index": 0,
+			"part":          part,
+		}))
+		w.message.Status = "completed"
+		w.message.Content = []converter.ResponsesOutputText{part}
+		item = w.message
+
+	case w.reasoning != nil:
+		part := converter.ResponsesSummaryText{Type: "summary_text", Text: text}
+		w.send("response.reasoning_summary_text.done", w.itemEvent(map[string]interface{}{
+			"summary_index": 0,
+			"text":          text,
+		}))
+		w.send("response.reasoning_summary_part.done", w.itemEvent(map[string]interface{}{
+			"summary_index": 0,
+			"part":          part,
+		}))
+		w.reasoning.Summary = []converter.ResponsesSummaryText{part}
+		item = w.reasoning
+
+	case w.call != nil:
+		w.send("response.function_call_arguments.done", w.itemEvent(map[string]interface{}{
+			"arguments": text,
+		}))
+		w.call.Status = "completed"
+		w.call.Arguments = text
+		item = w.call
+
+	default:
+		return
+	}
+
+	w.send("response.output_item.done", map[string]interface{}{
+		"output_index": w.outputIndex,
+		"item":         item,
+	})
+	w.items = append(w.items, item)
+	w.message, w.reasoning, w.call = nil, nil, nil
+	w.text.Reset()
+}
@@ -0,0 +1,142 @@
+// Package stream provides tests for Responses API streaming.
+package stream
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/model"
+)
+
+// collectResponsesEvents runs StreamToResponses and decodes the emitted SSE events
+func collectResponsesEvents(t *testing.T, resp *http.Response, cfg *config.Config, limits Limits) ([]string, []map[string]interface{}) {
+	req := &converter.ResponsesRequest{Model: "claude-sonnet-4.5"}
+	var types []string
+	var data []map[string]interface{}
+
+	for event := range StreamToResponses(context.Background(), resp, req, "resp_1", 1700000000, 15, true, cfg, model.NewCache(cfg), 10, limits) {
+		lines := strings.SplitN(strings.TrimSpace(event), "\n", 2)
+		assert.Len(t, lines, 2)
+
+		var parsed map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(lines[1], "data: ")), &parsed))
+
+		eventType := strings.TrimPrefix(lines[0], "event: ")
+		assert.Equal(t, eventType, parsed["type"])
+		assert.Equal(t, float64(len(types)), parsed["sequence_number"])
+		types = append(types, eventType)
+		data = append(data, parsed)
+	}
+	return types, data
+}
+
+// =============================================================================
+// TestResponsesStatus
+// =============================================================================
+
+func TestResponsesStatus(t *testing.T) {
+	assert.Equal(t, "incomplete", ResponsesStatus(StopReasonLength))
+	assert.Equal(t, "completed", ResponsesStatus(StopReasonToolUse))
+	assert.Equal(t, "completed", ResponsesStatus(""))
+}
+
+// =============================================================================
+// TestStreamToResponses
+// =============================================================================
+
+func TestStreamToResponses(t *testing.T) {
+	t.Run("streams text as a message item", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hello"}`, `{"content":" world"}`)
+
+		types, data := collectResponsesEvents(t, resp, &config.Config{}, Limits{})
+
+		assert.Equal(t, []string{
+			"response.created",
+			"response.in_progress",
+			"response.output_item.added",
+			"response.content_part.added",
+			"response.output_text.delta",
+			"response.output_text.delta",
+			"response.output_text.done",
+			"response.content_part.done",
+			"response.output_item.done",
+			"response.completed",
+		}, types)
+		assert.Equal(t, "in_progress", data[0]["response"].(map[string]interface{})["status"])
+		assert.Equal(t, "Hello", data[4]["delta"])
+		assert.Equal(t, data[2]["item"].(map[string]interface{})["id"], data[4]["item_id"])
+		assert.Equal(t, "Hello world", data[6]["text"])
+
+		final := data[9]["response"].(map[string]interface{})
+		assert.Equal(t, "resp_1", final["id"])
+		assert.Equal(t, "completed", final["status"])
+		output := final["output"].([]interface{})
+		assert.Len(t, output, 1)
+		message := output[0].(map[string]interface{})
+		assert.Equal(t, "completed", message["status"])
+		assert.Equal(t, "Hello world", message["content"].([]interface{})[0].(map[string]interface{})["text"])
+
+		usage := final["usage"].(map[string]interface{})
+		assert.Equal(t, float64(10), usage["input_tokens"])
+		assert.Greater(t, usage["output_tokens"], float64(0))
+	})
+
+	t.Run("streams function call arguments", func(t *testing.T) {
+		resp := newKiroResponse(
+			`{"content":"Checking."}`,
+			`{"name":"get_weather","toolUseId":"toolu_1"}`,
+			`{"input":"{\"city\":"}`,
+			`{"input":"\"Paris\"}"}`,
+			`{"stop":true}`,
+		)
+
+		types, data := collectResponsesEvents(t, resp, &config.Config{}, Limits{})
+
+		assert.Contains(t, types, "response.function_call_arguments.delta")
+		var done map[string]interface{}
+		for i, eventType := range types {
+			if eventType == "response.function_call_arguments.done" {
+				done = data[i]
+			}
+		}
+		assert.Equal(t, `{"city":"Paris"}`, done["arguments"])
+		assert.Equal(t, float64(1), done["output_index"])
+
+		final := data[len(data)-1]["response"].(map[string]interface{})
+		output := final["output"].([]interface{})
+		assert.Len(t, output, 2)
+		call := output[1].(map[string]interface{})
+		assert.Equal(t, "function_call", call["type"])
+		assert.Equal(t, "toolu_1", call["call_id"])
+		assert.Equal(t, "get_weather", call["name"])
+		assert.Equal(t, `{"city":"Paris"}`, call["arguments"])
+	})
+
+	t.Run("streams reasoning as a summary", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"<thinking>Let me think.</thinking>"}`, `{"content":"Answer"}`)
+		cfg := &config.Config{FakeReasoningEnabled: true, FakeReasoningHandling: "as_reasoning_content"}
+
+		types, data := collectResponsesEvents(t, resp, cfg, Limits{})
+
+		assert.Contains(t, types, "response.reasoning_summary_text.delta")
+		output := data[len(data)-1]["response"].(map[string]interface{})["output"].([]interface{})
+		assert.Equal(t, "reasoning", output[0].(map[string]interface{})["type"])
+		assert.Equal(t, "message", output[len(output)-1].(map[string]interface{})["type"])
+	})
+
+	t.Run("reports incomplete when truncated", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"one two three four five six seven eight"}`)
+
+		types, data := collectResponsesEvents(t, resp, &config.Config{}, Limits{MaxTokens: 2})
+
+		assert.Equal(t, "response.incomplete", types[len(types)-1])
+		final := data[len(data)-1]["response"].(map[string]interface{})
+		assert.Equal(t, "max_output_tokens", final["incomplete_details"].(map[string]interface{})["reason"])
+	})
+}
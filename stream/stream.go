@@ -4,17 +4,21 @@ package stream
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
+	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"strings"
 	"time"
 
+	"kiro-go-proxy/accesslog"
 	"kiro-go-proxy/config"
 	"kiro-go-proxy/converter"
 	"kiro-go-proxy/model"
 	"kiro-go-proxy/parser"
+	"kiro-go-proxy/tokens"
+	"kiro-go-proxy/tracing"
+	"kiro-go-proxy/usage"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -29,6 +33,9 @@ type KiroEvent struct {
 	ContextUsagePercentage *float64
 	IsFirstThinkingChunk   bool
 	IsLastThinkingChunk    bool
+	StopReason             string
+	StopSequence           string
+	ToolInput              string
 }
 
 // StreamResult represents the collected stream result
@@ -38,6 +45,11 @@ type StreamResult struct {
 	ToolCalls             []parser.ToolCall
 	Usage                 map[string]interface{}
 	ContextUsagePercentage *float64
+	StopReason            string
+	StopSequence          string
+
+	// Truncated is set when Kiro cut the response off at its output limit
+	Truncated bool
 }
 
 // FirstTokenTimeoutError is raised when first token timeout occurs
@@ -49,12 +61,42 @@ func (e *FirstTokenTimeoutError) Error() string {
 	return fmt.Sprintf("no response within %.0f seconds", e.Timeout)
 }
 
-// ParseKiroStream parses Kiro SSE stream and yields events
+// ParseKiroStream parses Kiro SSE stream and yields events.
+// When limits are hit, a "stop" event is sent and the stream ends early.
+// Cancelling ctx closes the upstream response and ends the stream without an error.
 func ParseKiroStream(
+	ctx context.Context,
+	response *http.Response,
+	firstTokenTimeout float64,
+	enableThinkingParser bool,
+	cfg *config.Config,
+	limits Limits,
+) (<-chan KiroEvent, <-chan error) {
+	return parseKiroStream(ctx, response, firstTokenTimeout, enableThinkingParser, cfg, limits, false)
+}
+
+// ParseKiroStreamIncremental is like ParseKiroStream but yields tool calls as they are
+// generated: "tool_start", "tool_input" (partial JSON in ToolInput) and "tool_stop" events
+// replace the final "tool_use" events.
+func ParseKiroStreamIncremental(
+	ctx context.Context,
+	response *http.Response,
+	firstTokenTimeout float64,
+	enableThinkingParser bool,
+	cfg *config.Config,
+	limits Limits,
+) (<-chan KiroEvent, <-chan error) {
+	return parseKiroStream(ctx, response, firstTokenTimeout, enableThinkingParser, cfg, limits, true)
+}
+
+func parseKiroStream(
+	ctx context.Context,
 	response *http.Response,
 	firstTokenTimeout float64,
 	enableThinkingParser bool,
 	cfg *config.Config,
+	limits Limits,
+	incrementalTools bool,
 ) (<-chan KiroEvent, <-chan error) {
 	events := make(chan KiroEvent, 100)
 	errs := make(chan error, 1)
@@ -63,7 +105,34 @@ func ParseKiroStream(
 		defer close(events)
 		defer close(errs)
 
+		// Covers reading and parsing the Kiro stream, from first byte to end
+		parseStart := time.Now()
+		_, span := tracing.StartSpan(ctx, "stream.parse", tracing.SpanKindInternal)
+		defer span.End()
+		eventCount := 0
+		defer func() { span.SetAttribute("stream.events", eventCount) }()
+
+		// Abort the upstream connection as soon as the client goes away
+		stopWatch := context.AfterFunc(ctx, func() {
+			response.Body.Close()
+		})
+		defer stopWatch()
+
+		// emit delivers an event unless the client has gone away
+		emit := func(event KiroEvent) bool {
+			select {
+			case events <- event:
+				eventCount++
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
 		awsParser := parser.NewAwsEventStreamParser()
+		if incrementalTools {
+			awsParser.EnableToolEvents()
+		}
 
 		var thinkingParser *parser.ThinkingParser
 		if cfg.FakeReasoningEnabled && enableThinkingParser {
@@ -75,6 +144,47 @@ func ParseKiroStream(
 			log.Debugf("Thinking parser initialized with mode: %s", cfg.FakeReasoningHandling)
 		}
 
+		limiter := newStreamLimiter(limits)
+		budget := newThinkingBudget(cfg)
+
+		// send applies limits to content events; returns false once generation must stop
+		send := func(event KiroEvent) bool {
+			if !limiter.allowToolEvent(event) {
+				return true
+			}
+			if event.Type == "tool_start" && limiter.active() {
+				// Release content held back for stop sequence detection before the tool call
+				content, stop := limiter.flush()
+				if content != "" && !emit(KiroEvent{Type: "content", Content: content}) {
+					return false
+				}
+				if stop != nil {
+					emit(*stop)
+					return false
+				}
+				if stop := limiter.cutOff(); stop != nil {
+					emit(*stop)
+					return false
+				}
+			}
+			if event.Type != "content" || !limiter.active() {
+				return emit(event)
+			}
+			content, stop := limiter.feed(event.Content)
+			if content != "" {
+				event.Content = content
+				if !emit(event) {
+					return false
+				}
+			}
+			if stop != nil {
+				log.Debugf("Stopping stream early: %s", stop.StopReason)
+				emit(*stop)
+				return false
+			}
+			return true
+		}
+
 		reader := bufio.NewReader(response.Body)
 
 		// Wait for first chunk with timeout
@@ -85,77 +195,154 @@ func ParseKiroStream(
 				log.Debug("Empty response from Kiro API")
 				return
 			}
-			errs <- fmt.Errorf("error reading first chunk: %w", err)
+			if ctx.Err() != nil {
+				log.Debug("Client disconnected before first chunk, aborting Kiro stream")
+				return
+			}
+			err = fmt.Errorf("error reading first chunk: %w", err)
+			span.RecordError(err)
+			errs <- err
 			return
 		}
 
 		log.Debug("First token received")
+		accesslog.FromContext(ctx).MarkFirstToken()
+		span.SetAttribute("stream.first_token_ms", time.Since(parseStart).Milliseconds())
+
+		// handle converts parsed events; returns false once generation must stop
+		handle := func(parsedEvents []parser.Event) bool {
+			for _, event := range parsedEvents {
+				if contentData, ok := event.Data.(parser.ContentData); ok && thinkingParser != nil {
+					for _, kiroEvent := range budget.apply(thinkingEvents(thinkingParser.Feed(contentData.Content))) {
+						if !send(kiroEvent) {
+							return false
+						}
+					}
+					continue
+				}
+				if modelData, ok := event.Data.(parser.ModelData); ok {
+					usage.FromContext(ctx).SetServedModel(modelData.ModelID)
+					continue
+				}
+				kiroEvent := processAwsEvent(event)
+				if kiroEvent != nil && kiroEvent.Type == "tool_start" {
+					name, _ := kiroEvent.ToolUse["name"].(string)
+					kiroEvent.ToolUse["name"] = limits.clientToolName(name)
+				}
+				if kiroEvent != nil && kiroEvent.Type == "usage" {
+					credits, _ := kiroEvent.Usage["credits"].(float64)
+					usage.FromContext(ctx).AddCredits(credits)
+				}
+				if kiroEvent != nil && !send(*kiroEvent) {
+					return false
+				}
+			}
+			return true
+		}
 
 		// Process chunks
 		buffer := firstChunk[:n]
 
 		for {
 			// Process current buffer
-			parsedEvents := awsParser.Feed(buffer)
-			for _, event := range parsedEvents {
-				kiroEvent := processAwsEvent(event, thinkingParser)
-				if kiroEvent != nil {
-					events <- *kiroEvent
-				}
+			if !handle(awsParser.Feed(buffer)) {
+				return
 			}
 
-			// Read next chunk
-			buffer = make([]byte, 4096)
+			// Read next chunk; the parser copies what it keeps, so the buffer is reused
+			buffer = firstChunk
 			n, err := reader.Read(buffer)
 			if err != nil {
 				if err == io.EOF {
 					break
 				}
-				errs <- fmt.Errorf("error reading stream: %w", err)
+				if ctx.Err() != nil {
+					log.Debug("Client disconnected, aborting Kiro stream")
+					return
+				}
+				err = fmt.Errorf("error reading stream: %w", err)
+				span.RecordError(err)
+				errs <- err
 				return
 			}
 			buffer = buffer[:n]
 		}
 
+		// Release a character left incomplete by the last content event
+		if !handle(awsParser.Flush()) {
+			return
+		}
+
 		// Finalize thinking parser
 		if thinkingParser != nil {
-			finalResult := thinkingParser.Finalize()
-			if finalResult.ThinkingContent != "" {
-				events <- KiroEvent{
-					Type:                 "thinking",
-					ThinkingContent:      finalResult.ThinkingContent,
-					IsFirstThinkingChunk: finalResult.IsFirstThinkingChunk,
-					IsLastThinkingChunk:  finalResult.IsLastThinkingChunk,
+			for _, kiroEvent := range budget.apply(thinkingEvents(thinkingParser.Finalize())) {
+				if !send(kiroEvent) {
+					return
 				}
 			}
-			if finalResult.RegularContent != "" {
-				events <- KiroEvent{
-					Type:    "content",
-					Content: finalResult.RegularContent,
-				}
+		}
+
+		// Flush content held back for stop sequence detection
+		if content, stop := limiter.flush(); content != "" || stop != nil {
+			if content != "" && !emit(KiroEvent{Type: "content", Content: content}) {
+				return
+			}
+			if stop != nil {
+				emit(*stop)
+				return
 			}
 		}
 
-		// Yield tool calls
-		for _, tc := range awsParser.GetToolCalls() {
-			events <- KiroEvent{
+		// Yield tool calls (already streamed in incremental mode)
+		if incrementalTools {
+			return
+		}
+		toolCalls := awsParser.GetToolCalls()
+		if stop := limiter.cutOff(); stop != nil && len(toolCalls) > 0 {
+			emit(*stop)
+			return
+		}
+		for _, tc := range toolCalls {
+			event := KiroEvent{
 				Type: "tool_use",
 				ToolUse: map[string]interface{}{
 					"id":   tc.ID,
 					"type": tc.Type,
 					"function": map[string]interface{}{
-						"name":      tc.Function.Name,
+						"name":      limits.clientToolName(tc.Function.Name),
 						"arguments": tc.Function.Arguments,
 					},
+					"truncated": tc.Truncated,
 				},
 			}
+			if limiter.allowToolEvent(event) && !emit(event) {
+				return
+			}
 		}
 	}()
 
 	return events, errs
 }
 
-func processAwsEvent(event parser.Event, thinkingParser *parser.ThinkingParser) *KiroEvent {
+// thinkingEvents converts a thinking parser result to events. One chunk may end
+// the thinking block and start the answer, so both can be present.
+func thinkingEvents(result *parser.ThinkingParseResult) []KiroEvent {
+	var events []KiroEvent
+	if result.ThinkingContent != "" {
+		events = append(events, KiroEvent{
+			Type:                 "thinking",
+			ThinkingContent:      result.ThinkingContent,
+			IsFirstThinkingChunk: result.IsFirstThinkingChunk,
+			IsLastThinkingChunk:  result.IsLastThinkingChunk,
+		})
+	}
+	if result.RegularContent != "" {
+		events = append(events, KiroEvent{Type: "content", Content: result.RegularContent})
+	}
+	return events
+}
+
+func processAwsEvent(event parser.Event) *KiroEvent {
 	switch event.Type {
 	case parser.EventTypeContent:
 		contentData, ok := event.Data.(parser.ContentData)
@@ -163,28 +350,46 @@ func processAwsEvent(event parser.Event, thinkingParser *parser.ThinkingParser)
 			return nil
 		}
 
-		if thinkingParser != nil {
-			result := thinkingParser.Feed(contentData.Content)
-			if result.ThinkingContent != "" {
-				return &KiroEvent{
-					Type:                 "thinking",
-					ThinkingContent:      result.ThinkingContent,
-					IsFirstThinkingChunk: result.IsFirstThinkingChunk,
-					IsLastThinkingChunk:  result.IsLastThinkingChunk,
-				}
-			}
-			if result.RegularContent != "" {
-				return &KiroEvent{
-					Type:    "content",
-					Content: result.RegularContent,
-				}
-			}
+		return &KiroEvent{
+			Type:    "content",
+			Content: contentData.Content,
+		}
+
+	case parser.EventTypeToolStart:
+		toolData, ok := event.Data.(parser.ToolStartData)
+		if !ok {
 			return nil
 		}
+		input, _ := toolData.Input.(string)
+		return &KiroEvent{
+			Type: "tool_start",
+			ToolUse: map[string]interface{}{
+				"id":   toolData.ToolUseID,
+				"name": toolData.Name,
+				"stop": toolData.Stop,
+			},
+			ToolInput: input,
+		}
 
+	case parser.EventTypeToolInput:
+		inputData, ok := event.Data.(parser.ToolInputData)
+		if !ok {
+			return nil
+		}
+		input, _ := inputData.Input.(string)
 		return &KiroEvent{
-			Type:    "content",
-			Content: contentData.Content,
+			Type:      "tool_input",
+			ToolInput: input,
+		}
+
+	case parser.EventTypeToolStop:
+		stopData, ok := event.Data.(parser.ToolStopData)
+		if !ok {
+			return nil
+		}
+		return &KiroEvent{
+			Type:    "tool_stop",
+			ToolUse: map[string]interface{}{"id": stopData.ToolUseID},
 		}
 
 	case parser.EventTypeUsage:
@@ -215,63 +420,79 @@ func processAwsEvent(event parser.Event, thinkingParser *parser.ThinkingParser)
 
 // CollectStreamResult collects full response from stream
 func CollectStreamResult(
+	ctx context.Context,
 	response *http.Response,
 	firstTokenTimeout float64,
 	enableThinkingParser bool,
 	cfg *config.Config,
+	limits Limits,
 ) (*StreamResult, error) {
-	events, errs := ParseKiroStream(response, firstTokenTimeout, enableThinkingParser, cfg)
-
-	result := &StreamResult{}
-	var fullContentForBracketTools strings.Builder
-
-	for {
-		select {
-		case event, ok := <-events:
-			if !ok {
-				// Check for bracket-style tool calls
-				bracketToolCalls := parser.ParseBracketToolCalls(fullContentForBracketTools.String())
-				if len(bracketToolCalls) > 0 {
-					result.ToolCalls = parser.DeduplicateToolCalls(append(result.ToolCalls, bracketToolCalls...))
-				}
-				return result, nil
-			}
+	events, errs := ParseKiroStream(ctx, response, firstTokenTimeout, enableThinkingParser, cfg, limits)
 
-			switch event.Type {
-			case "content":
-				result.Content += event.Content
-				fullContentForBracketTools.WriteString(event.Content)
-			case "thinking":
-				result.ThinkingContent += event.ThinkingContent
-				fullContentForBracketTools.WriteString(event.ThinkingContent)
-			case "tool_use":
-				tc := parser.ToolCall{
-					ID:   event.ToolUse["id"].(string),
-					Type: event.ToolUse["type"].(string),
-				}
-				if fn, ok := event.ToolUse["function"].(map[string]interface{}); ok {
-					tc.Function.Name = fn["name"].(string)
-					tc.Function.Arguments = fn["arguments"].(string)
-				}
-				result.ToolCalls = append(result.ToolCalls, tc)
-			case "usage":
-				result.Usage = event.Usage
-			case "context_usage":
-				result.ContextUsagePercentage = event.ContextUsagePercentage
-			}
+	enc := &resultEncoder{}
+	result, err := consume(events, errs, enc)
+	if err != nil {
+		return nil, err
+	}
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
 
-		case err := <-errs:
-			if err != nil {
-				return nil, err
-			}
+	// Generation was cut short: drop incomplete tool calls
+	if result.StopReason != "" {
+		result.ToolCalls = nil
+		return result, nil
+	}
+
+	// Check for bracket-style tool calls
+	bracketToolCalls := parser.ParseBracketToolCalls(enc.text.String())
+	for i := range bracketToolCalls {
+		bracketToolCalls[i].Function.Name = limits.clientToolName(bracketToolCalls[i].Function.Name)
+	}
+	if len(bracketToolCalls) > 0 {
+		result.ToolCalls = parser.DeduplicateToolCalls(append(result.ToolCalls, bracketToolCalls...))
+		if limits.MaxToolCalls > 0 && len(result.ToolCalls) > limits.MaxToolCalls {
+			result.ToolCalls = result.ToolCalls[:limits.MaxToolCalls]
 		}
 	}
+	result.ToolCalls = repairedToolCalls(result.ToolCalls, cfg.TruncationRecovery)
+	result.StopReason = finalStopReason(result.StopReason, len(result.ToolCalls))
+	result.Truncated = cfg.TruncationRecovery && isTruncated(result)
+	return result, nil
+}
+
+// resultEncoder is the encoder of non-streaming responses: the reply is built
+// from the collected result, so it only keeps the generated text, thinking
+// included, to look for bracket-style tool calls
+type resultEncoder struct {
+	text strings.Builder
+}
+
+func (e *resultEncoder) Content(text string)                          { e.text.WriteString(text) }
+func (e *resultEncoder) Thinking(text string)                         { e.text.WriteString(text) }
+func (e *resultEncoder) ToolDelta(delta ToolDelta)                    {}
+func (e *resultEncoder) Done(result *StreamResult, tokens TokenUsage) {}
+func (e *resultEncoder) Error(err error)                              {}
+
+// toolCallFromEvent converts a tool_use event payload back into a parser.ToolCall
+func toolCallFromEvent(toolUse map[string]interface{}) parser.ToolCall {
+	tc := parser.ToolCall{}
+	tc.ID, _ = toolUse["id"].(string)
+	tc.Type, _ = toolUse["type"].(string)
+	tc.Truncated, _ = toolUse["truncated"].(bool)
+	if fn, ok := toolUse["function"].(map[string]interface{}); ok {
+		tc.Function.Name, _ = fn["name"].(string)
+		tc.Function.Arguments, _ = fn["arguments"].(string)
+	}
+	return tc
 }
 
-// CalculateTokensFromContextUsage calculates token counts from context usage percentage
+// CalculateTokensFromContextUsage calculates token counts from context usage percentage.
+// Falls back to the tokenizer estimate of the request when Kiro reports no usage.
 func CalculateTokensFromContextUsage(
 	contextUsagePercentage *float64,
 	completionTokens int,
+	estimatedPromptTokens int,
 	modelCache *model.Cache,
 	model string,
 ) (promptTokens, totalTokens int, promptSource, totalSource string) {
@@ -285,107 +506,211 @@ func CalculateTokensFromContextUsage(
 		return promptTokens, totalTokens, "subtraction", "API Kiro"
 	}
 
-	return 0, completionTokens, "unknown", "tiktoken"
+	return estimatedPromptTokens, estimatedPromptTokens + completionTokens, "tiktoken", "tiktoken"
+}
+
+// CountCompletionTokens counts tokens generated in a response, including thinking and tool calls
+func CountCompletionTokens(content, thinkingContent string, toolCalls []parser.ToolCall) int {
+	total := tokens.CountRaw(content) + tokens.CountRaw(thinkingContent)
+	for _, tc := range toolCalls {
+		total += tokens.TokensPerTool
+		total += tokens.CountRaw(tc.Function.Name)
+		total += tokens.CountRaw(tc.Function.Arguments)
+	}
+	return tokens.ApplyCorrection(total)
 }
 
 // OpenAI Streaming
 
+// OpenAIFinishReason maps a stream stop reason to an OpenAI finish_reason
+func OpenAIFinishReason(stopReason string) string {
+	switch stopReason {
+	case StopReasonLength:
+		return "length"
+	case StopReasonToolUse:
+		return "tool_calls"
+	default:
+		return "stop"
+	}
+}
+
 // StreamToOpenAI converts Kiro stream to OpenAI SSE format
 func StreamToOpenAI(
+	ctx context.Context,
 	response *http.Response,
 	model string,
 	conversationID string,
 	firstTokenTimeout float64,
 	enableThinkingParser bool,
 	cfg *config.Config,
+	modelCache *model.Cache,
+	promptTokens int,
+	limits Limits,
+) <-chan string {
+	return StreamToOpenAIFramed(ctx, response, model, conversationID, firstTokenTimeout, enableThinkingParser, cfg, modelCache, promptTokens, limits, formatSSE)
+}
+
+// Framer wraps a serialized chunk for the transport that carries it
+type Framer func(data string) string
+
+// RawJSON leaves chunks unframed, for transports with their own message boundaries
+func RawJSON(data string) string {
+	return data
+}
+
+// StreamToOpenAIFramed converts Kiro stream to OpenAI chat.completion.chunk objects,
+// each wrapped by frame
+func StreamToOpenAIFramed(
+	ctx context.Context,
+	response *http.Response,
+	model string,
+	conversationID string,
+	firstTokenTimeout float64,
+	enableThinkingParser bool,
+	cfg *config.Config,
+	modelCache *model.Cache,
+	promptTokens int,
+	limits Limits,
+	frame Framer,
 ) <-chan string {
 	output := make(chan string, 100)
 
 	go func() {
 		defer close(output)
 
-		events, errs := ParseKiroStream(response, firstTokenTimeout, enableThinkingParser, cfg)
+		// Tool calls stream as an id/name delta followed by argument fragments
+		events, errs := ParseKiroStreamIncremental(ctx, response, firstTokenTimeout, enableThinkingParser, cfg, limits)
+		w := &openAIStreamWriter{
+			output:   output,
+			frame:    frame,
+			id:       conversationID,
+			model:    model,
+			thinking: parser.ThinkingHandlingMode(cfg.FakeReasoningHandling),
+		}
+		encodeStream(ctx, events, errs, w, modelCache, model, promptTokens)
+	}()
 
-		chunkIndex := 0
-		toolCallIndex := 0
+	return output
+}
 
-		for {
-			select {
-			case event, ok := <-events:
-				if !ok {
-					// Send finish chunk
-					finishChunk := createOpenAIFinishChunk(conversationID, model, chunkIndex)
-					output <- formatSSE(finishChunk)
-					return
-				}
+// openAIStreamWriter emits chat.completion.chunk objects, one per delta
+type openAIStreamWriter struct {
+	output     chan<- string
+	frame      Framer
+	id         string
+	model      string
+	thinking   parser.ThinkingHandlingMode
+	chunkIndex int
+	roleSent   bool
+}
 
-				chunkIndex++
-				var chunk string
+// send emits a delta chunk; the first one announces the assistant role
+func (w *openAIStreamWriter) send(delta map[string]interface{}) {
+	if !w.roleSent {
+		delta["role"] = "assistant"
+		w.roleSent = true
+	}
+	w.chunkIndex++
+	w.output <- w.frame(createOpenAIDeltaChunk(w.id, w.model, delta, w.chunkIndex, ""))
+}
 
-				switch event.Type {
-				case "content":
-					if event.Content != "" {
-						chunk = createOpenAIContentChunk(conversationID, model, event.Content, chunkIndex)
-					}
-				case "thinking":
-					if event.ThinkingContent != "" && cfg.FakeReasoningHandling == "as_reasoning_content" {
-						chunk = createOpenAIReasoningChunk(conversationID, model, event.ThinkingContent, chunkIndex)
-					}
-				case "tool_use":
-					chunk = createOpenAIToolCallChunk(conversationID, model, event.ToolUse, chunkIndex, toolCallIndex)
-					toolCallIndex++
-				}
+func (w *openAIStreamWriter) Content(text string) {
+	w.send(map[string]interface{}{"content": text})
+}
 
-				if chunk != "" {
-					output <- formatSSE(chunk)
-				}
+// Thinking is sent as reasoning_content, or inline ahead of the answer with pass
+// and strip_tags
+func (w *openAIStreamWriter) Thinking(text string) {
+	switch w.thinking {
+	case parser.ThinkingHandlingAsReasoningContent:
+		w.send(map[string]interface{}{"reasoning_content": text})
+	case parser.ThinkingHandlingPass, parser.ThinkingHandlingStripTags:
+		w.send(map[string]interface{}{"content": text})
+	}
+}
 
-			case err := <-errs:
-				if err != nil {
-					errorChunk := createOpenAIErrorChunk(err.Error())
-					output <- formatSSE(errorChunk)
-					return
-				}
-			}
-		}
-	}()
+// ToolDelta streams a tool call as an id/name delta followed by argument fragments
+func (w *openAIStreamWriter) ToolDelta(delta ToolDelta) {
+	if delta.Start {
+		w.send(openAIToolCallStartDelta(delta.Index, delta.ID, delta.Name))
+	}
+	if delta.Arguments != "" {
+		w.send(openAIToolCallArgumentsDelta(delta.Index, delta.Arguments))
+	}
+}
 
-	return output
+func (w *openAIStreamWriter) sentReply(result *StreamResult) (string, string) {
+	return SplitThinking(w.thinking, result.Content, result.ThinkingContent)
 }
 
-func createOpenAIContentChunk(id, model, content string, index int) string {
-	delta := map[string]interface{}{
-		"content": content,
+func (w *openAIStreamWriter) Done(result *StreamResult, tokens TokenUsage) {
+	usage := &converter.OpenAIUsage{
+		PromptTokens:     tokens.PromptTokens,
+		CompletionTokens: tokens.CompletionTokens,
+		TotalTokens:      tokens.TotalTokens,
 	}
-	return createOpenAIDeltaChunk(id, model, delta, index, "")
+	w.output <- w.frame(createOpenAIFinishChunk(w.id, w.model, w.chunkIndex, OpenAIFinishReason(result.StopReason), usage, tokens.Credits))
 }
 
-func createOpenAIReasoningChunk(id, model, content string, index int) string {
-	delta := map[string]interface{}{
-		"reasoning_content": content,
+func (w *openAIStreamWriter) Error(err error) {
+	w.output <- w.frame(createOpenAIErrorChunk(err.Error()))
+}
+
+// openAIToolCallStartDelta opens tool call toolCallIndex with its id and name
+func openAIToolCallStartDelta(toolCallIndex int, id, name string) map[string]interface{} {
+	return map[string]interface{}{
+		"tool_calls": []map[string]interface{}{
+			{
+				"index": toolCallIndex,
+				"id":    id,
+				"type":  "function",
+				"function": map[string]interface{}{
+					"name":      name,
+					"arguments": "",
+				},
+			},
+		},
 	}
-	return createOpenAIDeltaChunk(id, model, delta, index, "")
 }
 
-func createOpenAIToolCallChunk(id string, model string, toolUse map[string]interface{}, chunkIndex, toolCallIndex int) string {
-	delta := map[string]interface{}{
+// openAIToolCallArgumentsDelta appends a fragment of JSON arguments to tool call toolCallIndex
+func openAIToolCallArgumentsDelta(toolCallIndex int, arguments string) map[string]interface{} {
+	return map[string]interface{}{
 		"tool_calls": []map[string]interface{}{
 			{
 				"index": toolCallIndex,
-				"id":    toolUse["id"],
-				"type":  toolUse["type"],
 				"function": map[string]interface{}{
-					"name":      toolUse["function"].(map[string]interface{})["name"],
-					"arguments": toolUse["function"].(map[string]interface{})["arguments"],
+					"arguments": arguments,
 				},
 			},
 		},
 	}
-	return createOpenAIDeltaChunk(id, model, delta, chunkIndex, "")
 }
 
-func createOpenAIFinishChunk(id, model string, index int) string {
-	return createOpenAIDeltaChunk(id, model, map[string]interface{}{}, index, "stop")
+// createOpenAIFinishChunk ends a choice with its finish reason, the token usage
+// and the Kiro credits of the request, if any
+func createOpenAIFinishChunk(id, model string, index int, finishReason string, usage *converter.OpenAIUsage, credits float64) string {
+	chunk := map[string]interface{}{
+		"id":      id,
+		"object":  "chat.completion.chunk",
+		"created": time.Now().Unix(),
+		"model":   model,
+		"choices": []map[string]interface{}{
+			{
+				"index":         index,
+				"delta":         map[string]interface{}{},
+				"finish_reason": finishReason,
+			},
+		},
+	}
+	if usage != nil {
+		chunk["usage"] = usage
+	}
+	if credits != 0 {
+		chunk["x_kiro_credits"] = credits
+	}
+
+	return encodeChunk("", chunk, "")
 }
 
 func createOpenAIErrorChunk(message string) string {
@@ -395,8 +720,7 @@ func createOpenAIErrorChunk(message string) string {
 			"type":    "internal_error",
 		},
 	}
-	b, _ := json.Marshal(errorResp)
-	return string(b)
+	return encodeChunk("", errorResp, "")
 }
 
 func createOpenAIDeltaChunk(id, model string, delta map[string]interface{}, index int, finishReason string) string {
@@ -417,12 +741,11 @@ func createOpenAIDeltaChunk(id, model string, delta map[string]interface{}, inde
 		chunk["choices"].([]map[string]interface{})[0]["finish_reason"] = finishReason
 	}
 
-	b, _ := json.Marshal(chunk)
-	return string(b)
+	return encodeChunk("", chunk, "")
 }
 
 func formatSSE(data string) string {
-	return fmt.Sprintf("data: %s\n\n", data)
+	return "data: " + data + "\n\n"
 }
 
 // ParseSSE parses SSE data from reader
@@ -463,17 +786,10 @@ func ParseSSE(reader io.Reader) <-chan string {
 }
 
 // CreateOpenAIModelsResponse creates a models list response
-func CreateOpenAIModelsResponse(models []string) *converter.OpenAIModelsResponse {
+func CreateOpenAIModelsResponse(models []model.Details) *converter.OpenAIModelsResponse {
 	var data []converter.OpenAIModelData
-	now := time.Now().Unix()
-
-	for _, id := range models {
-		data = append(data, converter.OpenAIModelData{
-			ID:      id,
-			Object:  "model",
-			Created: now,
-			OwnedBy: "kiro",
-		})
+	for _, details := range models {
+		data = append(data, CreateOpenAIModelData(details))
 	}
 
 	return &converter.OpenAIModelsResponse{
@@ -481,3 +797,17 @@ func CreateOpenAIModelsResponse(models []string) *converter.OpenAIModelsResponse
 		Data:   data,
 	}
 }
+
+// CreateOpenAIModelData creates a single model entry with its capabilities
+func CreateOpenAIModelData(details model.Details) converter.OpenAIModelData {
+	return converter.OpenAIModelData{
+		ID:              details.ID,
+		Object:          "model",
+		Created:         time.Now().Unix(),
+		OwnedBy:         "kiro",
+		ContextWindow:   details.ContextWindow,
+		MaxOutputTokens: details.MaxOutputTokens,
+		SupportsVision:  details.SupportsVision,
+		SupportsTools:   details.SupportsTools,
+	}
+}
@@ -2,10 +2,20 @@
 package stream
 
 import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/model"
 	"kiro-go-proxy/parser"
+	"kiro-go-proxy/tokens"
 )
 
 // =============================================================================
@@ -279,7 +289,7 @@ func TestFormatSSE(t *testing.T) {
 
 func TestCreateOpenAIModelsResponse(t *testing.T) {
 	t.Run("creates models response", func(t *testing.T) {
-		models := []string{"model-1", "model-2", "model-3"}
+		models := []model.Details{{ID: "model-1"}, {ID: "model-2"}, {ID: "model-3"}}
 		response := CreateOpenAIModelsResponse(models)
 
 		assert.Equal(t, "list", response.Object)
@@ -290,7 +300,7 @@ func TestCreateOpenAIModelsResponse(t *testing.T) {
 	})
 
 	t.Run("creates empty models response", func(t *testing.T) {
-		models := []string{}
+		models := []model.Details{}
 		response := CreateOpenAIModelsResponse(models)
 
 		assert.Equal(t, "list", response.Object)
@@ -299,11 +309,27 @@ func TestCreateOpenAIModelsResponse(t *testing.T) {
 
 	t.Run("creates models response with created timestamp", func(t *testing.T) {
 		// Original: test_models_format_is_openai_compatible
-		models := []string{"claude-haiku-4.5"}
+		models := []model.Details{{ID: "claude-haiku-4.5"}}
 		response := CreateOpenAIModelsResponse(models)
 
 		assert.NotZero(t, response.Data[0].Created)
 	})
+
+	t.Run("includes model capabilities", func(t *testing.T) {
+		models := []model.Details{{
+			ID:              "claude-haiku-4.5",
+			ContextWindow:   200000,
+			MaxOutputTokens: 64000,
+			SupportsVision:  true,
+			SupportsTools:   true,
+		}}
+		response := CreateOpenAIModelsResponse(models)
+
+		assert.Equal(t, 200000, response.Data[0].ContextWindow)
+		assert.Equal(t, 64000, response.Data[0].MaxOutputTokens)
+		assert.True(t, response.Data[0].SupportsVision)
+		assert.True(t, response.Data[0].SupportsTools)
+	})
 }
 
 // =============================================================================
@@ -351,4 +377,434 @@ func TestCollectStreamResult(t *testing.T) {
 
 		assert.Equal(t, "Let me think...", result.ThinkingContent)
 	})
+
+	t.Run("joins characters split across content events", func(t *testing.T) {
+		resp := newKiroResponse("{\"content\":\"\xe4\xbd\"}", "{\"content\":\"\xa0\xe5\xa5\xbd \xf0\x9f\"}", "{\"content\":\"\x8e\x89\"}")
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "你好 🎉", result.Content)
+	})
+
+	t.Run("ends with a replacement for an incomplete character", func(t *testing.T) {
+		resp := newKiroResponse("{\"content\":\"ok\xf0\x9f\"}")
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "ok\uFFFD", result.Content)
+	})
+
+	t.Run("separates thinking with tags split across content events", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"<thin"}`, `{"content":"king>Plan it.</thi"}`, `{"content":"nking>Answer"}`)
+		cfg := &config.Config{FakeReasoningEnabled: true, FakeReasoningHandling: "as_reasoning_content", FakeReasoningBufferSize: 1}
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, true, cfg, Limits{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "Plan it.", result.ThinkingContent)
+		assert.Equal(t, "Answer", result.Content)
+	})
+
+	t.Run("renames tool calls to the client's names", func(t *testing.T) {
+		resp := newKiroResponse(`{"name":"mcp_fs_read","toolUseId":"toolu_1"}`, `{"input":"{}"}`, `{"stop":true}`)
+		limits := Limits{ToolNames: map[string]string{"mcp_fs_read": "mcp.fs/read"}}
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, limits)
+
+		assert.NoError(t, err)
+		assert.Len(t, result.ToolCalls, 1)
+		assert.Equal(t, "mcp.fs/read", result.ToolCalls[0].Function.Name)
+	})
+}
+
+// =============================================================================
+// TestCalculateTokensFallback
+// Tests for tokenizer fallback when Kiro reports no context usage
+// =============================================================================
+
+func TestCalculateTokensFallback(t *testing.T) {
+	t.Run("uses estimated prompt tokens without context usage", func(t *testing.T) {
+		cache := model.NewCache(&config.Config{MaxInputTokens: 200000})
+
+		prompt, total, promptSource, _ := CalculateTokensFromContextUsage(nil, 50, 120, cache, "claude-sonnet-4.5")
+
+		assert.Equal(t, 120, prompt)
+		assert.Equal(t, 170, total)
+		assert.Equal(t, "tiktoken", promptSource)
+	})
+
+	t.Run("prefers context usage when available", func(t *testing.T) {
+		cache := model.NewCache(&config.Config{MaxInputTokens: 200000})
+		percentage := 25.0
+
+		prompt, total, promptSource, _ := CalculateTokensFromContextUsage(&percentage, 1000, 120, cache, "claude-sonnet-4.5")
+
+		assert.Equal(t, 49000, prompt)
+		assert.Equal(t, 50000, total)
+		assert.Equal(t, "subtraction", promptSource)
+	})
+}
+
+// =============================================================================
+// TestCountCompletionTokens
+// Tests for completion token counting
+// =============================================================================
+
+func TestCountCompletionTokens(t *testing.T) {
+	t.Run("empty output returns zero", func(t *testing.T) {
+		assert.Equal(t, 0, CountCompletionTokens("", "", nil))
+	})
+
+	t.Run("counts content and thinking", func(t *testing.T) {
+		contentOnly := CountCompletionTokens("Hello, world!", "", nil)
+		withThinking := CountCompletionTokens("Hello, world!", "Let me think about this.", nil)
+
+		assert.Greater(t, contentOnly, 0)
+		assert.Greater(t, withThinking, contentOnly)
+	})
+
+	t.Run("counts tool calls", func(t *testing.T) {
+		toolCalls := []parser.ToolCall{
+			{
+				ID:       "call_123",
+				Type:     "function",
+				Function: parser.ToolCallFunction{Name: "get_weather", Arguments: `{"city": "London"}`},
+			},
+		}
+
+		assert.Greater(t, CountCompletionTokens("", "", toolCalls), 0)
+	})
+}
+
+// =============================================================================
+// TestCreateOpenAIFinishChunk
+// Tests for the final streaming chunk
+// =============================================================================
+
+func TestCreateOpenAIFinishChunk(t *testing.T) {
+	t.Run("includes usage", func(t *testing.T) {
+		usage := &converter.OpenAIUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15}
+		chunk := createOpenAIFinishChunk("chatcmpl-1", "claude-sonnet-4.5", 3, "stop", usage, 0)
+
+		var parsed map[string]interface{}
+		err := json.Unmarshal([]byte(chunk), &parsed)
+		assert.NoError(t, err)
+
+		choices := parsed["choices"].([]interface{})
+		assert.Equal(t, "stop", choices[0].(map[string]interface{})["finish_reason"])
+
+		usageMap := parsed["usage"].(map[string]interface{})
+		assert.Equal(t, float64(10), usageMap["prompt_tokens"])
+		assert.Equal(t, float64(5), usageMap["completion_tokens"])
+		assert.Equal(t, float64(15), usageMap["total_tokens"])
+	})
+
+	t.Run("omits usage when nil", func(t *testing.T) {
+		chunk := createOpenAIFinishChunk("chatcmpl-1", "claude-sonnet-4.5", 0, "length", nil, 0)
+
+		assert.NotContains(t, chunk, "usage")
+		assert.NotContains(t, chunk, "x_kiro_credits")
+	})
+
+	t.Run("includes Kiro credits", func(t *testing.T) {
+		chunk := createOpenAIFinishChunk("chatcmpl-1", "claude-sonnet-4.5", 0, "stop", nil, 0.25)
+
+		var parsed map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(chunk), &parsed))
+		assert.Equal(t, 0.25, parsed["x_kiro_credits"])
+	})
+}
+
+// =============================================================================
+// TestParseKiroStreamCancellation
+// Tests that client disconnects abort the upstream stream
+// =============================================================================
+
+func TestParseKiroStreamCancellation(t *testing.T) {
+	t.Run("stops reading when context is cancelled", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		resp := &http.Response{StatusCode: http.StatusOK, Body: pr}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		events, errs := ParseKiroStream(ctx, resp, 15, false, &config.Config{}, Limits{})
+
+		go pw.Write([]byte(`{"content":"Hello"}`))
+		event := <-events
+		assert.Equal(t, "Hello", event.Content)
+
+		cancel()
+
+		select {
+		case _, ok := <-events:
+			assert.False(t, ok)
+		case <-time.After(2 * time.Second):
+			t.Fatal("stream did not stop after cancellation")
+		}
+		assert.NoError(t, <-errs)
+	})
+
+	t.Run("collect returns context error", func(t *testing.T) {
+		pr, pw := io.Pipe()
+		defer pw.Close()
+		resp := &http.Response{StatusCode: http.StatusOK, Body: pr}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		result, err := CollectStreamResult(ctx, resp, 15, false, &config.Config{}, Limits{})
+
+		assert.Nil(t, result)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
+
+// =============================================================================
+// TestFinishReason
+// Tests that tool calls and limits are reported as the terminal state
+// =============================================================================
+
+func TestFinishReason(t *testing.T) {
+	t.Run("maps stop reasons to OpenAI finish_reason", func(t *testing.T) {
+		assert.Equal(t, "length", OpenAIFinishReason(StopReasonLength))
+		assert.Equal(t, "tool_calls", OpenAIFinishReason(StopReasonToolUse))
+		assert.Equal(t, "stop", OpenAIFinishReason(StopReasonStopSequence))
+		assert.Equal(t, "stop", OpenAIFinishReason(""))
+	})
+
+	t.Run("collect reports tool use", func(t *testing.T) {
+		resp := newKiroResponse(`{"name":"search","toolUseId":"toolu_1"}`, `{"input":"{}"}`, `{"stop":true}`)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.ToolCalls, 1)
+		assert.Equal(t, StopReasonToolUse, result.StopReason)
+	})
+
+	t.Run("collect reports plain completion", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hello"}`)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{})
+
+		assert.NoError(t, err)
+		assert.Empty(t, result.StopReason)
+	})
+
+	t.Run("ends normally at exactly max tokens", func(t *testing.T) {
+		text := "one two three"
+		resp := newKiroResponse(`{"content":"`+text+`"}`)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{MaxTokens: tokens.Count(text)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, text, result.Content)
+		assert.Empty(t, result.StopReason)
+	})
+
+	t.Run("tool calls after exactly max tokens are cut off", func(t *testing.T) {
+		text := "one two three"
+		resp := newKiroResponse(`{"content":"`+text+`"}`, `{"name":"search","toolUseId":"toolu_1"}`, `{"input":"{}"}`, `{"stop":true}`)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{MaxTokens: tokens.Count(text)})
+
+		assert.NoError(t, err)
+		assert.Equal(t, StopReasonLength, result.StopReason)
+		assert.Empty(t, result.ToolCalls)
+	})
+
+	t.Run("length wins over tool calls", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"one two three four five six"}`, `{"name":"search","toolUseId":"toolu_1"}`, `{"input":"{}"}`, `{"stop":true}`)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{MaxTokens: 2})
+
+		assert.NoError(t, err)
+		assert.Equal(t, StopReasonLength, result.StopReason)
+		assert.Empty(t, result.ToolCalls)
+	})
+
+	t.Run("streaming finish chunk reports tool_calls", func(t *testing.T) {
+		resp := newKiroResponse(`{"name":"search","toolUseId":"toolu_1"}`, `{"input":"{}"}`, `{"stop":true}`)
+
+		var last string
+		for chunk := range StreamToOpenAI(context.Background(), resp, "claude-sonnet-4", "id", 15, false, &config.Config{}, nil, 0, Limits{}) {
+			last = chunk
+		}
+
+		var finish map[string]interface{}
+		assert.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(strings.TrimSpace(last), "data: ")), &finish))
+		choice := finish["choices"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "tool_calls", choice["finish_reason"])
+	})
+}
+
+// =============================================================================
+// TestStreamToOpenAIFramed
+// Chunks can be framed for transports other than SSE
+// =============================================================================
+
+func TestStreamToOpenAIFramed(t *testing.T) {
+	t.Run("RawJSON leaves chunks unframed", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Hello"}`)
+
+		var chunks []string
+		for chunk := range StreamToOpenAIFramed(context.Background(), resp, "claude-sonnet-4", "id", 15, false, &config.Config{}, nil, 0, Limits{}, RawJSON) {
+			chunks = append(chunks, chunk)
+		}
+
+		assert.Len(t, chunks, 2)
+		for _, chunk := range chunks {
+			var parsed map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(chunk), &parsed))
+			assert.Equal(t, "chat.completion.chunk", parsed["object"])
+		}
+	})
+}
+
+// =============================================================================
+// TestStreamToOpenAIThinking
+// FAKE_REASONING_HANDLING decides which delta field carries thinking
+// =============================================================================
+
+func TestStreamToOpenAIThinking(t *testing.T) {
+	for _, tt := range []struct {
+		handling  string
+		content   string
+		reasoning string
+	}{
+		{"as_reasoning_content", "Answer", "Plan it."},
+		{"pass", "<thinking>Plan it.</thinking>Answer", ""},
+		{"remove", "Answer", ""},
+	} {
+		t.Run(tt.handling, func(t *testing.T) {
+			resp := newKiroResponse(`{"content":"<thinking>Plan it.</thinking>"}`, `{"content":"Answer"}`)
+			cfg := &config.Config{FakeReasoningEnabled: true, FakeReasoningHandling: tt.handling}
+
+			var content, reasoning string
+			for chunk := range StreamToOpenAIFramed(context.Background(), resp, "claude-sonnet-4", "id", 15, true, cfg, nil, 0, Limits{}, RawJSON) {
+				var parsed map[string]interface{}
+				assert.NoError(t, json.Unmarshal([]byte(chunk), &parsed))
+				delta, _ := parsed["choices"].([]interface{})[0].(map[string]interface{})["delta"].(map[string]interface{})
+				text, _ := delta["content"].(string)
+				content += text
+				text, _ = delta["reasoning_content"].(string)
+				reasoning += text
+			}
+
+			assert.Equal(t, tt.content, content)
+			assert.Equal(t, tt.reasoning, reasoning)
+		})
+	}
+}
+
+// =============================================================================
+// TestStreamToOpenAIToolCalls
+// Tool calls stream as an id/name delta followed by argument fragments
+// =============================================================================
+
+func TestStreamToOpenAIToolCalls(t *testing.T) {
+	// collectDeltas runs StreamToOpenAIFramed and returns the delta of each chunk before the finish chunk
+	collectDeltas := func(t *testing.T, payloads ...string) []map[string]interface{} {
+		var deltas []map[string]interface{}
+		for chunk := range StreamToOpenAIFramed(context.Background(), newKiroResponse(payloads...), "claude-sonnet-4", "id", 15, false, &config.Config{}, nil, 0, Limits{}, RawJSON) {
+			var parsed map[string]interface{}
+			assert.NoError(t, json.Unmarshal([]byte(chunk), &parsed))
+			choice := parsed["choices"].([]interface{})[0].(map[string]interface{})
+			if choice["finish_reason"] == nil {
+				deltas = append(deltas, choice["delta"].(map[string]interface{}))
+			}
+		}
+		return deltas
+	}
+
+	t.Run("emits id and name then argument fragments", func(t *testing.T) {
+		deltas := collectDeltas(t,
+			`{"name":"search","toolUseId":"toolu_1"}`,
+			`{"input":"{\"query\": "}`,
+			`{"input":"\"go\"}"}`,
+			`{"stop":true}`,
+		)
+
+		assert.Len(t, deltas, 3)
+
+		start := deltas[0]["tool_calls"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, float64(0), start["index"])
+		assert.Equal(t, "toolu_1", start["id"])
+		assert.Equal(t, "function", start["type"])
+		assert.Equal(t, "search", start["function"].(map[string]interface{})["name"])
+		assert.Equal(t, "", start["function"].(map[string]interface{})["arguments"])
+
+		var arguments string
+		for _, delta := range deltas[1:] {
+			call := delta["tool_calls"].([]interface{})[0].(map[string]interface{})
+			assert.Equal(t, float64(0), call["index"])
+			assert.Nil(t, call["id"])
+			arguments += call["function"].(map[string]interface{})["arguments"].(string)
+		}
+		assert.JSONEq(t, `{"query": "go"}`, arguments)
+	})
+
+	t.Run("numbers tool calls in order", func(t *testing.T) {
+		deltas := collectDeltas(t,
+			`{"name":"a","toolUseId":"toolu_1"}`, `{"input":"{}"}`, `{"stop":true}`,
+			`{"name":"b","toolUseId":"toolu_2"}`, `{"input":"{}"}`, `{"stop":true}`,
+		)
+
+		var indexes []float64
+		for _, delta := range deltas {
+			if call := delta["tool_calls"].([]interface{})[0].(map[string]interface{}); call["id"] != nil {
+				indexes = append(indexes, call["index"].(float64))
+			}
+		}
+		assert.Equal(t, []float64{0, 1}, indexes)
+	})
+
+	t.Run("first delta carries the assistant role", func(t *testing.T) {
+		deltas := collectDeltas(t, `{"content":"Let me search."}`, `{"name":"search","toolUseId":"toolu_1"}`, `{"stop":true}`)
+
+		assert.Equal(t, "assistant", deltas[0]["role"])
+		assert.Equal(t, "Let me search.", deltas[0]["content"])
+		assert.Nil(t, deltas[1]["role"])
+	})
+}
+
+// =============================================================================
+// BenchmarkStreamConverters
+// =============================================================================
+
+func BenchmarkStreamConverters(b *testing.B) {
+	payloads := make([]string, 5000)
+	size := 0
+	for i := range payloads {
+		payloads[i] = `{"content":"token ` + strings.Repeat("x", i%20) + ` "}`
+		size += len(payloads[i])
+	}
+
+	b.Run("CollectStreamResult", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			CollectStreamResult(context.Background(), newKiroResponse(payloads...), 15, false, &config.Config{}, Limits{})
+		}
+	})
+
+	b.Run("StreamToOpenAI", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for range StreamToOpenAI(context.Background(), newKiroResponse(payloads...), "claude-sonnet-4", "id", 15, false, &config.Config{}, nil, 0, Limits{}) {
+			}
+		}
+	})
+
+	b.Run("StreamToAnthropic", func(b *testing.B) {
+		b.SetBytes(int64(size))
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for range StreamToAnthropic(context.Background(), newKiroResponse(payloads...), "claude-sonnet-4", "msg", 15, false, &config.Config{}, nil, 0, Limits{}, "") {
+			}
+		}
+	})
 }
@@ -0,0 +1,39 @@
+package stream
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// RequestTimeoutError is the cause of a request context whose total time budget
+// (x-request-timeout or REQUEST_TIMEOUT) ran out
+type RequestTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e *RequestTimeoutError) Error() string {
+	return fmt.Sprintf("request timed out after %v", e.Timeout)
+}
+
+// Unwrap makes the error match context.DeadlineExceeded
+func (e *RequestTimeoutError) Unwrap() error {
+	return context.DeadlineExceeded
+}
+
+// WithRequestTimeout returns a context that ends after timeout with a
+// RequestTimeoutError as its cause
+func WithRequestTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	return context.WithTimeoutCause(ctx, timeout, &RequestTimeoutError{Timeout: timeout})
+}
+
+// RequestTimeout returns the RequestTimeoutError that ended ctx, or nil if ctx
+// is still running or ended for another reason (e.g. the client went away)
+func RequestTimeout(ctx context.Context) error {
+	var timeout *RequestTimeoutError
+	if errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	return nil
+}
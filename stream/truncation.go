@@ -0,0 +1,120 @@
+// Package stream provides streaming support for Kiro Gateway.
+package stream
+
+import (
+	"strings"
+
+	"kiro-go-proxy/parser"
+)
+
+// maxContinuationOverlap bounds the text compared when trimming a continuation
+// that repeats the end of the partial response
+const maxContinuationOverlap = 200
+
+// isTruncated reports whether Kiro cut the response off at its output limit: a tool
+// call's arguments were incomplete, or, without the context usage report Kiro
+// sends once a turn completes, the text stops inside a code block or JSON value.
+// How a sentence ends is no signal: replies ending in a URL, number or identifier
+// are complete, and each false positive costs continuation requests.
+func isTruncated(result *StreamResult) bool {
+	for _, tc := range result.ToolCalls {
+		if tc.Truncated {
+			return true
+		}
+	}
+	if result.ContextUsagePercentage != nil || len(result.ToolCalls) > 0 {
+		return false
+	}
+	return strings.Count(result.Content, "```")%2 == 1 || unclosedJSON(result.Content)
+}
+
+// unclosedJSON reports whether text is a JSON object or array that stops before
+// its closing bracket
+func unclosedJSON(text string) bool {
+	text = strings.TrimSpace(text)
+	if text == "" || (text[0] != '{' && text[0] != '[') {
+		return false
+	}
+
+	depth := 0
+	inString, escaped := false, false
+	for i := 0; i < len(text); i++ {
+		switch ch := text[i]; {
+		case escaped:
+			escaped = false
+		case inString && ch == '\\':
+			escaped = true
+		case ch == '"':
+			inString = !inString
+		case inString:
+		case ch == '{' || ch == '[':
+			depth++
+		case ch == '}' || ch == ']':
+			depth--
+		}
+	}
+	return inString || depth > 0
+}
+
+// MergeContinuation appends the result of a continuation request to a truncated
+// result. Text the model repeated from the end of the partial response is dropped,
+// and truncated tool calls are replaced by the calls the continuation made.
+func MergeContinuation(result, next *StreamResult) *StreamResult {
+	merged := *next
+	merged.Content = result.Content + trimOverlap(result.Content, next.Content)
+	merged.ThinkingContent = result.ThinkingContent + next.ThinkingContent
+
+	merged.ToolCalls = nil
+	for _, tc := range result.ToolCalls {
+		if !tc.Truncated || len(next.ToolCalls) == 0 {
+			merged.ToolCalls = append(merged.ToolCalls, tc)
+		}
+	}
+	merged.ToolCalls = append(merged.ToolCalls, next.ToolCalls...)
+	if merged.StopReason == "" {
+		merged.StopReason = finalStopReason("", len(merged.ToolCalls))
+	}
+
+	merged.Truncated = isTruncated(&merged)
+	return &merged
+}
+
+// trimOverlap strips the longest prefix of next that repeats the end of prev
+func trimOverlap(prev, next string) string {
+	n := len(next)
+	if n > maxContinuationOverlap {
+		n = maxContinuationOverlap
+	}
+	for ; n > 0; n-- {
+		if strings.HasSuffix(prev, next[:n]) {
+			return next[n:]
+		}
+	}
+	return next
+}
+
+// ContinuationLimits returns the limits for a continuation request, counting the
+// tokens already generated against max_tokens
+func ContinuationLimits(limits Limits, result *StreamResult) Limits {
+	if limits.MaxTokens > 0 {
+		limits.MaxTokens -= CountCompletionTokens(result.Content, "", nil)
+		if limits.MaxTokens < 1 {
+			limits.MaxTokens = 1
+		}
+	}
+	return limits
+}
+
+// repairedToolCalls drops the arguments of truncated tool calls when truncation
+// recovery is disabled, matching how unparseable arguments were always reported
+func repairedToolCalls(calls []parser.ToolCall, recover bool) []parser.ToolCall {
+	if recover {
+		return calls
+	}
+	for i := range calls {
+		if calls[i].Truncated {
+			calls[i].Function.Arguments = "{}"
+		}
+	}
+	return calls
+}
@@ -0,0 +1,193 @@
+// Package stream provides tests for truncation detection and continuation stitching.
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/parser"
+)
+
+// =============================================================================
+// TestIsTruncated
+// =============================================================================
+
+func TestIsTruncated(t *testing.T) {
+	usage := 12.5
+
+	t.Run("complete sentence is not truncated", func(t *testing.T) {
+		assert.False(t, isTruncated(&StreamResult{Content: "All done."}))
+	})
+
+	t.Run("mid-sentence text is not a truncation signal", func(t *testing.T) {
+		assert.False(t, isTruncated(&StreamResult{Content: "The answer is that the"}))
+		assert.False(t, isTruncated(&StreamResult{Content: "See https://example.com/docs"}))
+		assert.False(t, isTruncated(&StreamResult{Content: "The result is 1712345678901"}))
+	})
+
+	t.Run("unclosed JSON is truncated", func(t *testing.T) {
+		assert.True(t, isTruncated(&StreamResult{Content: `{"items": [{"name": "a"}, {"name": "b`}))
+		assert.True(t, isTruncated(&StreamResult{Content: `[1, 2, `}))
+		assert.False(t, isTruncated(&StreamResult{Content: `{"brace": "}{", "done": true}`}))
+	})
+
+	t.Run("mid-sentence with context usage is not truncated", func(t *testing.T) {
+		assert.False(t, isTruncated(&StreamResult{Content: "The answer is that the", ContextUsagePercentage: &usage}))
+	})
+
+	t.Run("unclosed code block is truncated", func(t *testing.T) {
+		assert.True(t, isTruncated(&StreamResult{Content: "Here:\n```go\nfunc main() {}\n"}))
+	})
+
+	t.Run("empty response is not truncated", func(t *testing.T) {
+		assert.False(t, isTruncated(&StreamResult{}))
+	})
+
+	t.Run("truncated tool call", func(t *testing.T) {
+		result := &StreamResult{
+			ContextUsagePercentage: &usage,
+			ToolCalls:              []parser.ToolCall{{ID: "call_1", Truncated: true}},
+		}
+		assert.True(t, isTruncated(result))
+	})
+
+	t.Run("complete tool call", func(t *testing.T) {
+		result := &StreamResult{
+			Content:   "Let me check",
+			ToolCalls: []parser.ToolCall{{ID: "call_1"}},
+		}
+		assert.False(t, isTruncated(result))
+	})
+}
+
+// =============================================================================
+// TestCollectStreamResultTruncation
+// =============================================================================
+
+func TestCollectStreamResultTruncation(t *testing.T) {
+	t.Run("marks truncated text", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Here:\n` + "```" + `go\nfunc main() {"}`)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{TruncationRecovery: true}, Limits{})
+
+		assert.NoError(t, err)
+		assert.True(t, result.Truncated)
+	})
+
+	t.Run("ignores truncation when recovery disabled", func(t *testing.T) {
+		resp := newKiroResponse(`{"content":"Here:\n` + "```" + `go\nfunc main() {"}`)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{})
+
+		assert.NoError(t, err)
+		assert.False(t, result.Truncated)
+	})
+
+	t.Run("returns repaired tool arguments", func(t *testing.T) {
+		resp := newKiroResponse(
+			`{"name":"write_file","toolUseId":"call_1"}`,
+			`{"input":"{\"path\": \"a.txt\", \"content\": \"hel"}`,
+		)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{TruncationRecovery: true}, Limits{})
+
+		assert.NoError(t, err)
+		assert.True(t, result.Truncated)
+		assert.Len(t, result.ToolCalls, 1)
+		assert.True(t, result.ToolCalls[0].Truncated)
+		assert.JSONEq(t, `{"path": "a.txt", "content": "hel"}`, result.ToolCalls[0].Function.Arguments)
+	})
+
+	t.Run("drops truncated tool arguments when recovery disabled", func(t *testing.T) {
+		resp := newKiroResponse(
+			`{"name":"write_file","toolUseId":"call_1"}`,
+			`{"input":"{\"path\": \"a.t"}`,
+		)
+
+		result, err := CollectStreamResult(context.Background(), resp, 15, false, &config.Config{}, Limits{})
+
+		assert.NoError(t, err)
+		assert.Equal(t, "{}", result.ToolCalls[0].Function.Arguments)
+	})
+}
+
+// =============================================================================
+// TestMergeContinuation
+// =============================================================================
+
+func TestMergeContinuation(t *testing.T) {
+	usage := 30.0
+
+	t.Run("stitches text", func(t *testing.T) {
+		result := &StreamResult{Content: "The first reason is", Truncated: true}
+		next := &StreamResult{Content: " speed.", ContextUsagePercentage: &usage}
+
+		merged := MergeContinuation(result, next)
+
+		assert.Equal(t, "The first reason is speed.", merged.Content)
+		assert.Equal(t, &usage, merged.ContextUsagePercentage)
+		assert.False(t, merged.Truncated)
+	})
+
+	t.Run("drops text repeated from the partial response", func(t *testing.T) {
+		result := &StreamResult{Content: "The first reason is"}
+		next := &StreamResult{Content: "reason is speed.", ContextUsagePercentage: &usage}
+
+		merged := MergeContinuation(result, next)
+
+		assert.Equal(t, "The first reason is speed.", merged.Content)
+	})
+
+	t.Run("still truncated when continuation is cut off", func(t *testing.T) {
+		merged := MergeContinuation(&StreamResult{Content: `{"items": [1,`}, &StreamResult{Content: ` 2,`})
+
+		assert.True(t, merged.Truncated)
+	})
+
+	t.Run("replaces truncated tool call with the retried call", func(t *testing.T) {
+		result := &StreamResult{ToolCalls: []parser.ToolCall{{ID: "call_1", Truncated: true}}}
+		next := &StreamResult{
+			ContextUsagePercentage: &usage,
+			ToolCalls:              []parser.ToolCall{{ID: "call_2"}},
+		}
+
+		merged := MergeContinuation(result, next)
+
+		assert.Len(t, merged.ToolCalls, 1)
+		assert.Equal(t, "call_2", merged.ToolCalls[0].ID)
+		assert.Equal(t, StopReasonToolUse, merged.StopReason)
+		assert.False(t, merged.Truncated)
+	})
+
+	t.Run("keeps repaired tool call when continuation makes none", func(t *testing.T) {
+		result := &StreamResult{ToolCalls: []parser.ToolCall{{ID: "call_1", Truncated: true}}}
+		next := &StreamResult{Content: "Done.", ContextUsagePercentage: &usage}
+
+		merged := MergeContinuation(result, next)
+
+		assert.Len(t, merged.ToolCalls, 1)
+		assert.Equal(t, "call_1", merged.ToolCalls[0].ID)
+	})
+}
+
+// =============================================================================
+// TestContinuationLimits
+// =============================================================================
+
+func TestContinuationLimits(t *testing.T) {
+	t.Run("no max tokens stays unlimited", func(t *testing.T) {
+		limits := ContinuationLimits(Limits{StopSequences: []string{"END"}}, &StreamResult{Content: "some text"})
+
+		assert.Equal(t, 0, limits.MaxTokens)
+		assert.Equal(t, []string{"END"}, limits.StopSequences)
+	})
+
+	t.Run("subtracts generated tokens", func(t *testing.T) {
+		limits := ContinuationLimits(Limits{MaxTokens: 1000}, &StreamResult{Content: "some text here"})
+
+		assert.Less(t, limits.MaxTokens, 1000)
+		assert.Greater(t, limits.MaxTokens, 0)
+	})
+}
@@ -0,0 +1,91 @@
+// Package tokens provides token counting for Kiro Gateway.
+// It uses the cl100k_base BPE encoding as an approximation of the Claude tokenizer.
+package tokens
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkoukk/tiktoken-go"
+	tiktokenloader "github.com/pkoukk/tiktoken-go-loader"
+	log "github.com/sirupsen/logrus"
+)
+
+// ClaudeCorrectionPercent compensates for Claude tokenizing ~15% more tokens than cl100k_base
+const ClaudeCorrectionPercent = 115
+
+// Per-item overhead used when counting structured messages and tools
+const (
+	TokensPerMessage = 4
+	TokensPerTool    = 4
+	TokensPerReply   = 3
+	TokensPerImage   = 100
+)
+
+var (
+	encoding     *tiktoken.Tiktoken
+	encodingOnce sync.Once
+)
+
+// getEncoding lazily loads the cl100k_base encoding from embedded BPE ranks
+func getEncoding() *tiktoken.Tiktoken {
+	encodingOnce.Do(func() {
+		tiktoken.SetBpeLoader(tiktokenloader.NewOfflineLoader())
+		enc, err := tiktoken.GetEncoding("cl100k_base")
+		if err != nil {
+			log.Warnf("Failed to load tokenizer, falling back to estimation: %v", err)
+			return
+		}
+		encoding = enc
+	})
+	return encoding
+}
+
+// Count returns the number of tokens in text with Claude correction applied
+func Count(text string) int {
+	return ApplyCorrection(CountRaw(text))
+}
+
+// CountRaw returns the number of cl100k_base tokens in text without correction
+func CountRaw(text string) int {
+	if text == "" {
+		return 0
+	}
+
+	enc := getEncoding()
+	if enc == nil {
+		return len(text)/4 + 1
+	}
+	return len(enc.Encode(text, nil, nil))
+}
+
+// ApplyCorrection applies the Claude correction factor to a raw token count
+func ApplyCorrection(count int) int {
+	return count * ClaudeCorrectionPercent / 100
+}
+
+// Truncate returns the longest prefix of text whose corrected token count does not exceed maxTokens
+func Truncate(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	if Count(text) <= maxTokens {
+		return text
+	}
+
+	rawLimit := maxTokens * 100 / ClaudeCorrectionPercent
+	enc := getEncoding()
+	if enc == nil {
+		if rawLimit*4 < len(text) {
+			return text[:rawLimit*4]
+		}
+		return text
+	}
+
+	encoded := enc.Encode(text, nil, nil)
+	if rawLimit >= len(encoded) {
+		return text
+	}
+	// Drop a partial multi-byte character left at the cut point
+	return strings.ToValidUTF8(enc.Decode(encoded[:rawLimit]), "")
+}
@@ -0,0 +1,77 @@
+// Package tokens provides tests for token counting.
+package tokens
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// =============================================================================
+// TestCount
+// Original: /code/github/kiro-gateway/tests/unit/test_tokenizer.py::TestCountTokens
+// =============================================================================
+
+func TestCount(t *testing.T) {
+	t.Run("empty string returns zero", func(t *testing.T) {
+		assert.Equal(t, 0, Count(""))
+		assert.Equal(t, 0, CountRaw(""))
+	})
+
+	t.Run("counts simple english text", func(t *testing.T) {
+		// "Hello, world!" is 4 tokens in cl100k_base
+		assert.Equal(t, 4, CountRaw("Hello, world!"))
+	})
+
+	t.Run("applies claude correction factor", func(t *testing.T) {
+		text := "The quick brown fox jumps over the lazy dog. " +
+			"The quick brown fox jumps over the lazy dog."
+		raw := CountRaw(text)
+		assert.Equal(t, raw*ClaudeCorrectionPercent/100, Count(text))
+		assert.Greater(t, Count(text), raw)
+	})
+
+	t.Run("counts CJK text more densely than len/4", func(t *testing.T) {
+		text := "你好世界，这是一个测试"
+		assert.Greater(t, CountRaw(text), 0)
+		assert.NotEqual(t, len(text)/4, CountRaw(text))
+	})
+}
+
+// =============================================================================
+// TestApplyCorrection
+// =============================================================================
+
+func TestApplyCorrection(t *testing.T) {
+	t.Run("zero stays zero", func(t *testing.T) {
+		assert.Equal(t, 0, ApplyCorrection(0))
+	})
+
+	t.Run("scales by correction factor", func(t *testing.T) {
+		assert.Equal(t, 115, ApplyCorrection(100))
+	})
+}
+
+// =============================================================================
+// TestTruncate
+// =============================================================================
+
+func TestTruncate(t *testing.T) {
+	text := "The quick brown fox jumps over the lazy dog. The quick brown fox jumps over the lazy dog."
+
+	t.Run("returns text unchanged when within limit", func(t *testing.T) {
+		assert.Equal(t, text, Truncate(text, 1000))
+	})
+
+	t.Run("truncates to token limit", func(t *testing.T) {
+		result := Truncate(text, 5)
+
+		assert.True(t, len(result) < len(text))
+		assert.Equal(t, text[:len(result)], result)
+		assert.LessOrEqual(t, Count(result), 5)
+	})
+
+	t.Run("zero limit returns empty string", func(t *testing.T) {
+		assert.Equal(t, "", Truncate(text, 0))
+	})
+}
@@ -0,0 +1,141 @@
+package tracing
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/transport"
+
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Export batching and retry
+const (
+	exportQueueSize     = 2048
+	exportBatchSize     = 512
+	exportInterval      = 5 * time.Second
+	exportClientTimeout = 10 * time.Second
+	exportRetryInitial  = time.Second
+	exportRetryMax      = 30 * time.Second
+	exportRetryElapsed  = time.Minute
+	shutdownTimeout     = 10 * time.Second
+)
+
+// Provider records spans and exports them in batches to an OTLP/HTTP collector.
+// Spans beyond exportQueueSize that are not yet exported are dropped; failed
+// exports are retried with backoff for up to exportRetryElapsed.
+type Provider struct {
+	endpoint string
+	provider *sdktrace.TracerProvider
+	tracer   trace.Tracer
+	stopOnce sync.Once
+}
+
+var (
+	provider   *Provider
+	providerMu sync.RWMutex
+)
+
+// setProvider installs the process-wide provider used by StartSpan
+func setProvider(p *Provider) {
+	providerMu.Lock()
+	defer providerMu.Unlock()
+	provider = p
+}
+
+func currentProvider() *Provider {
+	providerMu.RLock()
+	defer providerMu.RUnlock()
+	return provider
+}
+
+// Setup enables tracing when OTEL_EXPORTER_OTLP_ENDPOINT (or the traces-specific
+// endpoint) is set. It returns nil when tracing is disabled.
+func Setup(cfg *config.Config) *Provider {
+	endpoint := cfg.OTelTracesEndpoint
+	if endpoint == "" && cfg.OTelEndpoint != "" {
+		endpoint = strings.TrimRight(cfg.OTelEndpoint, "/") + "/v1/traces"
+	}
+	if endpoint == "" {
+		return nil
+	}
+
+	exporter, err := otlptracehttp.New(context.Background(),
+		otlptracehttp.WithEndpointURL(endpoint),
+		otlptracehttp.WithHeaders(parseHeaders(cfg.OTelHeaders)),
+		otlptracehttp.WithHTTPClient(transport.NewClient(cfg, exportClientTimeout)),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{
+			Enabled:         true,
+			InitialInterval: exportRetryInitial,
+			MaxInterval:     exportRetryMax,
+			MaxElapsedTime:  exportRetryElapsed,
+		}),
+	)
+	if err != nil {
+		log.Errorf("Tracing disabled: %v", err)
+		return nil
+	}
+	p := newProvider(endpoint, cfg.OTelServiceName, sdktrace.WithBatcher(exporter,
+		sdktrace.WithMaxQueueSize(exportQueueSize),
+		sdktrace.WithMaxExportBatchSize(exportBatchSize),
+		sdktrace.WithBatchTimeout(exportInterval),
+		sdktrace.WithExportTimeout(exportClientTimeout),
+	))
+	setProvider(p)
+
+	log.Infof("Tracing enabled, exporting spans to %s", endpoint)
+	return p
+}
+
+func newProvider(endpoint, serviceName string, options ...sdktrace.TracerProviderOption) *Provider {
+	res := resource.NewSchemaless(
+		attribute.String("service.name", serviceName),
+		attribute.String("service.version", config.AppVersion),
+	)
+	tp := sdktrace.NewTracerProvider(append(options, sdktrace.WithResource(res))...)
+	return &Provider{
+		endpoint: endpoint,
+		provider: tp,
+		tracer:   tp.Tracer(instrumentationName, trace.WithInstrumentationVersion(config.AppVersion)),
+	}
+}
+
+// parseHeaders parses OTEL_EXPORTER_OTLP_HEADERS entries of the form key=value
+func parseHeaders(items []string) map[string]string {
+	headers := make(map[string]string)
+	for _, item := range items {
+		key, value, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		if decoded, err := url.QueryUnescape(value); err == nil {
+			value = decoded
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// Stop flushes queued spans and shuts the exporter down. Safe to call on nil.
+func (p *Provider) Stop() {
+	if p == nil {
+		return
+	}
+	p.stopOnce.Do(func() {
+		setProvider(nil)
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := p.provider.Shutdown(ctx); err != nil {
+			log.Warnf("Failed to flush spans: %v", err)
+		}
+	})
+}
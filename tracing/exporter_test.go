@@ -0,0 +1,96 @@
+// Package tracing provides tests for the OTLP/HTTP exporter.
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	collectortrace "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+	"kiro-go-proxy/config"
+)
+
+// =============================================================================
+// TestSetup
+// =============================================================================
+
+func TestSetup(t *testing.T) {
+	t.Run("disabled without endpoint", func(t *testing.T) {
+		assert.Nil(t, Setup(&config.Config{}))
+		assert.False(t, Enabled())
+
+		var p *Provider
+		p.Stop()
+	})
+
+	t.Run("exports spans over OTLP on stop", func(t *testing.T) {
+		var body collectortrace.ExportTraceServiceRequest
+		var path, auth string
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			path = r.URL.Path
+			auth = r.Header.Get("Authorization")
+			data, _ := io.ReadAll(r.Body)
+			proto.Unmarshal(data, &body)
+		}))
+		defer collector.Close()
+
+		p := Setup(&config.Config{
+			OTelEndpoint:    collector.URL + "/",
+			OTelHeaders:     []string{"Authorization=Bearer%20secret"},
+			OTelServiceName: "kiro-test",
+		})
+		assert.True(t, Enabled())
+
+		ctx, root := StartSpan(context.Background(), "POST /v1/messages", SpanKindServer)
+		_, child := StartSpan(ctx, "kiro.request", SpanKindClient)
+		child.RecordError(errors.New("received 503 from Kiro API"))
+		child.End()
+		root.End()
+
+		p.Stop()
+		assert.False(t, Enabled())
+
+		assert.Equal(t, "/v1/traces", path)
+		assert.Equal(t, "Bearer secret", auth)
+		resourceSpans := body.GetResourceSpans()[0]
+		assert.Equal(t, "kiro-test", resourceSpans.GetResource().GetAttributes()[0].GetValue().GetStringValue())
+		spans := resourceSpans.GetScopeSpans()[0].GetSpans()
+		assert.Len(t, spans, 2)
+		assert.Equal(t, "kiro.request", spans[0].GetName())
+		assert.Equal(t, root.TraceID(), hex.EncodeToString(spans[0].GetTraceId()))
+	})
+
+	t.Run("retries a failed export", func(t *testing.T) {
+		var attempts atomic.Int32
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if attempts.Add(1) == 1 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+		defer collector.Close()
+
+		p := Setup(&config.Config{OTelTracesEndpoint: collector.URL + "/v1/traces"})
+		_, span := StartSpan(context.Background(), "op", SpanKindInternal)
+		span.End()
+		p.Stop()
+
+		assert.Equal(t, int32(2), attempts.Load())
+	})
+
+	t.Run("traces endpoint is used as-is", func(t *testing.T) {
+		p := Setup(&config.Config{
+			OTelEndpoint:       "http://ignored",
+			OTelTracesEndpoint: "http://collector.invalid/custom",
+		})
+		defer p.Stop()
+
+		assert.Equal(t, "http://collector.invalid/custom", p.endpoint)
+	})
+}
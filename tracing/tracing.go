@@ -0,0 +1,133 @@
+// Package tracing records request spans with the OpenTelemetry SDK and exports
+// them over OTLP/HTTP. Tracing is disabled unless OTEL_EXPORTER_OTLP_ENDPOINT is
+// set; spans are then nil and every call on them is a no-op.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// SpanKind mirrors the OTLP span kinds
+type SpanKind int
+
+// OTLP span kinds
+const (
+	SpanKindInternal SpanKind = SpanKind(trace.SpanKindInternal)
+	SpanKindServer   SpanKind = SpanKind(trace.SpanKindServer)
+	SpanKindClient   SpanKind = SpanKind(trace.SpanKindClient)
+)
+
+// instrumentationName is the scope of the spans recorded by the proxy
+const instrumentationName = "kiro-go-proxy"
+
+// propagator reads and writes W3C traceparent and tracestate headers
+var propagator = propagation.TraceContext{}
+
+// Span is a single timed operation. A nil *Span is valid and ignores all calls.
+type Span struct {
+	span trace.Span
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	return currentProvider() != nil
+}
+
+// StartSpan starts a span as a child of the span in ctx, or a new trace if there is none.
+// It returns nil when tracing is disabled.
+func StartSpan(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	p := currentProvider()
+	if p == nil {
+		return ctx, nil
+	}
+	ctx, span := p.tracer.Start(ctx, name, trace.WithSpanKind(trace.SpanKind(kind)))
+	return ctx, &Span{span: span}
+}
+
+// StartServerSpan starts the root span of an incoming request, continuing the
+// caller's trace when the request headers carry a W3C traceparent
+func StartServerSpan(ctx context.Context, name string, header http.Header) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+	return StartSpan(propagator.Extract(ctx, propagation.HeaderCarrier(header)), name, SpanKindServer)
+}
+
+// Inject writes the traceparent of the span in ctx to the headers of an
+// outgoing request, so the next service continues the trace
+func Inject(ctx context.Context, header http.Header) {
+	if FromContext(ctx) == nil {
+		return
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// FromContext returns the active span in ctx, or nil
+func FromContext(ctx context.Context) *Span {
+	if !Enabled() {
+		return nil
+	}
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() {
+		return nil
+	}
+	return &Span{span: span}
+}
+
+// SetAttribute records a string, bool, int, int64 or float64 attribute
+func (s *Span) SetAttribute(key string, value interface{}) {
+	if s == nil {
+		return
+	}
+	s.span.SetAttributes(toAttribute(key, value))
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End finishes the span and queues it for export. Only the first call counts.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.span.End()
+}
+
+// TraceID returns the hex trace ID, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.span.SpanContext().TraceID().String()
+}
+
+// toAttribute converts a span attribute value to its OpenTelemetry type
+func toAttribute(key string, value interface{}) attribute.KeyValue {
+	switch val := value.(type) {
+	case string:
+		return attribute.String(key, val)
+	case bool:
+		return attribute.Bool(key, val)
+	case int:
+		return attribute.Int(key, val)
+	case int64:
+		return attribute.Int64(key, val)
+	case float64:
+		return attribute.Float64(key, val)
+	default:
+		return attribute.String(key, fmt.Sprint(val))
+	}
+}
@@ -0,0 +1,105 @@
+// Package tracing provides tests for span recording.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// enableTestProvider installs a provider that records ended spans in memory
+func enableTestProvider(t *testing.T) *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	setProvider(newProvider("", "test", sdktrace.WithSyncer(exporter)))
+	t.Cleanup(func() { setProvider(nil) })
+	return exporter
+}
+
+// =============================================================================
+// TestStartSpan
+// =============================================================================
+
+func TestStartSpan(t *testing.T) {
+	t.Run("returns nil spans when disabled", func(t *testing.T) {
+		ctx, span := StartSpan(context.Background(), "op", SpanKindInternal)
+
+		assert.Nil(t, span)
+		assert.Nil(t, FromContext(ctx))
+
+		// All calls on a nil span are no-ops
+		span.SetAttribute("key", "value")
+		span.RecordError(errors.New("boom"))
+		span.End()
+		assert.Empty(t, span.TraceID())
+	})
+
+	t.Run("child spans share the trace", func(t *testing.T) {
+		exporter := enableTestProvider(t)
+
+		ctx, parent := StartSpan(context.Background(), "parent", SpanKindServer)
+		_, child := StartSpan(ctx, "child", SpanKindInternal)
+
+		assert.Equal(t, parent.TraceID(), FromContext(ctx).TraceID())
+		assert.Equal(t, parent.TraceID(), child.TraceID())
+
+		child.SetAttribute("http.status_code", 503)
+		child.RecordError(errors.New("received 503 from Kiro API"))
+		child.End()
+		child.End()
+		spans := exporter.GetSpans()
+		assert.Len(t, spans, 1)
+		assert.Equal(t, parent.span.SpanContext().SpanID(), spans[0].Parent.SpanID())
+		assert.Equal(t, codes.Error, spans[0].Status.Code)
+		assert.Equal(t, int64(503), spans[0].Attributes[0].Value.AsInt64())
+	})
+
+	t.Run("server span continues traceparent", func(t *testing.T) {
+		exporter := enableTestProvider(t)
+		header := http.Header{"Traceparent": {"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}}
+
+		_, span := StartServerSpan(context.Background(), "GET /health", header)
+		span.End()
+
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.TraceID())
+		assert.Equal(t, "00f067aa0ba902b7", exporter.GetSpans()[0].Parent.SpanID().String())
+	})
+
+	t.Run("server span ignores an invalid traceparent", func(t *testing.T) {
+		enableTestProvider(t)
+		header := http.Header{"Traceparent": {"00-00000000000000000000000000000000-00f067aa0ba902b7-01"}}
+
+		_, span := StartServerSpan(context.Background(), "GET /health", header)
+
+		assert.NotEqual(t, "00000000000000000000000000000000", span.TraceID())
+	})
+}
+
+// =============================================================================
+// TestInject
+// =============================================================================
+
+func TestInject(t *testing.T) {
+	t.Run("writes the traceparent of the active span", func(t *testing.T) {
+		enableTestProvider(t)
+		ctx, span := StartSpan(context.Background(), "kiro.request", SpanKindClient)
+		header := http.Header{}
+
+		Inject(ctx, header)
+
+		assert.Contains(t, header.Get("traceparent"), span.TraceID())
+	})
+
+	t.Run("writes nothing without a span", func(t *testing.T) {
+		header := http.Header{}
+
+		Inject(context.Background(), header)
+
+		assert.Empty(t, header)
+	})
+}
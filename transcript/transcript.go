@@ -0,0 +1,256 @@
+// Package transcript keeps a local record of the conversations routed via the
+// proxy.
+//
+// With TRANSCRIPT_DIR set, each answered chat request appends the user's latest
+// message and the reply the client received (text, thinking and tool calls) to
+// a file per conversation, under a directory per day:
+// TRANSCRIPT_DIR/2006-01-02/<conversation ID>.md and/or .jsonl, as set by
+// TRANSCRIPT_FORMAT.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/parser"
+	"kiro-go-proxy/utils"
+)
+
+// Formats of TRANSCRIPT_FORMAT
+const (
+	FormatMarkdown = "markdown"
+	FormatJSONL    = "jsonl"
+	FormatBoth     = "both"
+)
+
+// Record is one request and reply of a conversation
+type Record struct {
+	Time           time.Time         `json:"time"`
+	ConversationID string            `json:"conversation_id"`
+	RequestID      string            `json:"request_id"`
+	KeyID          string            `json:"key_id,omitempty"`
+	Path           string            `json:"path"`
+	Model          string            `json:"model,omitempty"`
+	Prompt         string            `json:"prompt,omitempty"`
+	Thinking       string            `json:"thinking,omitempty"`
+	Content        string            `json:"content,omitempty"`
+	ToolCalls      []parser.ToolCall `json:"tool_calls,omitempty"`
+}
+
+// Writer appends records to the transcript files. A nil *Writer is valid and
+// writes nothing, so callers need no checks when transcripts are disabled.
+type Writer struct {
+	dir      string
+	markdown bool
+	jsonl    bool
+	mu       sync.Mutex
+}
+
+// NewWriter creates a writer for TRANSCRIPT_DIR, or returns nil when it is empty
+func NewWriter(cfg *config.Config) *Writer {
+	if cfg.TranscriptDir == "" {
+		return nil
+	}
+	format := cfg.TranscriptFormat
+	if format == "" {
+		format = FormatMarkdown
+	}
+	return &Writer{
+		dir:      cfg.TranscriptDir,
+		markdown: format == FormatMarkdown || format == FormatBoth,
+		jsonl:    format == FormatJSONL || format == FormatBoth,
+	}
+}
+
+// fileName returns the file name for a conversation ID: the ID itself when it is
+// made of letters, digits, dashes and dots, such as the UUIDs sent to Kiro, and
+// its sanitized form otherwise
+func fileName(id string) string {
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '.' {
+			return r
+		}
+		return '_'
+	}, id)
+	name = strings.TrimLeft(name, ".")
+	if len(name) > 128 {
+		name = name[:128]
+	}
+	return name
+}
+
+// Write appends rec to its conversation's files
+func (w *Writer) Write(rec *Record) error {
+	if w == nil {
+		return nil
+	}
+	name := fileName(rec.ConversationID)
+	if name == "" {
+		name = fileName(rec.RequestID)
+	}
+	dir := filepath.Join(w.dir, rec.Time.Format("2006-01-02"))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	if w.jsonl {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		if err := appendFile(filepath.Join(dir, name+".jsonl"), append(line, '\n')); err != nil {
+			return err
+		}
+	}
+	if w.markdown {
+		path := filepath.Join(dir, name+".md")
+		var b strings.Builder
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			fmt.Fprintf(&b, "# Conversation %s\n\n", rec.ConversationID)
+		}
+		writeMarkdown(&b, rec)
+		if err := appendFile(path, []byte(b.String())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendFile appends data to the file at path, creating it readable by its
+// owner only
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// writeMarkdown renders rec as a section of a conversation's markdown file
+func writeMarkdown(b *strings.Builder, rec *Record) {
+	fmt.Fprintf(b, "## %s", rec.Time.UTC().Format(time.RFC3339))
+	if rec.Model != "" {
+		fmt.Fprintf(b, " · %s", rec.Model)
+	}
+	b.WriteString("\n\n")
+	if rec.Prompt != "" {
+		fmt.Fprintf(b, "**User**\n\n%s\n\n", rec.Prompt)
+	}
+	if rec.Thinking != "" {
+		b.WriteString("**Thinking**\n\n")
+		for _, line := range strings.Split(strings.TrimSpace(rec.Thinking), "\n") {
+			fmt.Fprintf(b, "> %s\n", line)
+		}
+		b.WriteString("\n")
+	}
+	if rec.Content != "" {
+		fmt.Fprintf(b, "**Assistant**\n\n%s\n\n", rec.Content)
+	}
+	for _, tc := range rec.ToolCalls {
+		fmt.Fprintf(b, "**Tool call** `%s` (`%s`)\n\n```json\n%s\n```\n\n", tc.Function.Name, tc.ID, tc.Function.Arguments)
+	}
+}
+
+// Turn collects the prompt and reply of one request. A nil *Turn is valid and
+// ignores all calls.
+type Turn struct {
+	prompt    string
+	content   string
+	thinking  string
+	toolCalls []parser.ToolCall
+	replied   bool
+	mu        sync.Mutex
+}
+
+// NewTurn creates an empty turn
+func NewTurn() *Turn {
+	return &Turn{}
+}
+
+type contextKey struct{}
+
+// WithTurn returns a context carrying the turn
+func WithTurn(ctx context.Context, t *Turn) context.Context {
+	if t == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// FromContext returns the turn stored in ctx, or nil
+func FromContext(ctx context.Context) *Turn {
+	t, _ := ctx.Value(contextKey{}).(*Turn)
+	return t
+}
+
+// SetMessages records the latest user message of the messages sent to Kiro as
+// the turn's prompt; tool results are noted by their call ID
+func (t *Turn) SetMessages(messages []converter.UnifiedMessage) {
+	if t == nil {
+		return
+	}
+	var prompt []string
+	for i := len(messages) - 1; i >= 0; i-- {
+		msg := messages[i]
+		if msg.Role != "user" {
+			continue
+		}
+		for _, tr := range msg.ToolResults {
+			prompt = append(prompt, fmt.Sprintf("[Tool result %s]\n%s", tr.ToolUseID, utils.ExtractTextContent(tr.Content)))
+		}
+		if text := utils.ExtractTextContent(msg.Content); text != "" {
+			prompt = append(prompt, text)
+		}
+		break
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.prompt = strings.Join(prompt, "\n\n")
+}
+
+// SetReply records the reply the client received. With several replies, as with
+// retries, the last one is kept.
+func (t *Turn) SetReply(content, thinking string, toolCalls []parser.ToolCall) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.content = content
+	t.thinking = thinking
+	t.toolCalls = toolCalls
+	t.replied = true
+}
+
+// Record returns the turn as a record to complete with the request's details,
+// or nil if there was no reply
+func (t *Turn) Record() *Record {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.replied {
+		return nil
+	}
+	return &Record{
+		Prompt:    t.prompt,
+		Thinking:  t.thinking,
+		Content:   t.content,
+		ToolCalls: t.toolCalls,
+	}
+}
@@ -0,0 +1,151 @@
+// Package transcript provides tests for the conversation transcripts.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"kiro-go-proxy/config"
+	"kiro-go-proxy/converter"
+	"kiro-go-proxy/parser"
+)
+
+// testRecord returns a record of a reply with thinking and a tool call
+func testRecord() *Record {
+	return &Record{
+		Time:           time.Date(2026, 3, 14, 9, 30, 0, 0, time.UTC),
+		ConversationID: "conv-1",
+		RequestID:      "req-1",
+		Path:           "/v1/chat/completions",
+		Model:          "claude-sonnet-4",
+		Prompt:         "What is the weather in Paris?",
+		Thinking:       "The user wants the weather.\nI should call the tool.",
+		Content:        "Let me check.",
+		ToolCalls: []parser.ToolCall{{ID: "call_1", Type: "function", Function: parser.ToolCallFunction{
+			Name: "get_weather", Arguments: `{"city":"Paris"}`,
+		}}},
+	}
+}
+
+// =============================================================================
+// TestWriter
+// =============================================================================
+
+func TestWriter(t *testing.T) {
+	t.Run("disabled without a directory", func(t *testing.T) {
+		w := NewWriter(&config.Config{TranscriptFormat: FormatBoth})
+
+		assert.Nil(t, w)
+		assert.NoError(t, w.Write(testRecord()))
+	})
+
+	t.Run("appends markdown per day and conversation", func(t *testing.T) {
+		dir := t.TempDir()
+		w := NewWriter(&config.Config{TranscriptDir: dir, TranscriptFormat: FormatMarkdown})
+
+		assert.NoError(t, w.Write(testRecord()))
+		assert.NoError(t, w.Write(testRecord()))
+
+		data, err := os.ReadFile(filepath.Join(dir, "2026-03-14", "conv-1.md"))
+		assert.NoError(t, err)
+		text := string(data)
+		assert.Equal(t, 1, strings.Count(text, "# Conversation conv-1"))
+		assert.Equal(t, 2, strings.Count(text, "## 2026-03-14T09:30:00Z · claude-sonnet-4"))
+		assert.Contains(t, text, "**User**\n\nWhat is the weather in Paris?")
+		assert.Contains(t, text, "> The user wants the weather.\n> I should call the tool.")
+		assert.Contains(t, text, "**Assistant**\n\nLet me check.")
+		assert.Contains(t, text, "**Tool call** `get_weather` (`call_1`)\n\n```json\n{\"city\":\"Paris\"}\n```")
+		_, err = os.Stat(filepath.Join(dir, "2026-03-14", "conv-1.jsonl"))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("appends JSON lines", func(t *testing.T) {
+		dir := t.TempDir()
+		w := NewWriter(&config.Config{TranscriptDir: dir, TranscriptFormat: FormatJSONL})
+
+		assert.NoError(t, w.Write(testRecord()))
+		assert.NoError(t, w.Write(testRecord()))
+
+		data, err := os.ReadFile(filepath.Join(dir, "2026-03-14", "conv-1.jsonl"))
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		assert.Len(t, lines, 2)
+		var rec Record
+		assert.NoError(t, json.Unmarshal([]byte(lines[0]), &rec))
+		assert.Equal(t, "Let me check.", rec.Content)
+		assert.Equal(t, "get_weather", rec.ToolCalls[0].Function.Name)
+	})
+
+	t.Run("sanitizes file names", func(t *testing.T) {
+		dir := t.TempDir()
+		w := NewWriter(&config.Config{TranscriptDir: dir, TranscriptFormat: FormatMarkdown})
+		rec := testRecord()
+		rec.ConversationID = "../../etc/passwd"
+
+		assert.NoError(t, w.Write(rec))
+
+		_, err := os.Stat(filepath.Join(dir, "2026-03-14", "_.._etc_passwd.md"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("falls back to the request ID", func(t *testing.T) {
+		dir := t.TempDir()
+		w := NewWriter(&config.Config{TranscriptDir: dir, TranscriptFormat: FormatBoth})
+		rec := testRecord()
+		rec.ConversationID = ""
+
+		assert.NoError(t, w.Write(rec))
+
+		for _, name := range []string{"req-1.md", "req-1.jsonl"} {
+			_, err := os.Stat(filepath.Join(dir, "2026-03-14", name))
+			assert.NoError(t, err, name)
+		}
+	})
+}
+
+// =============================================================================
+// TestTurn
+// =============================================================================
+
+func TestTurn(t *testing.T) {
+	t.Run("records the latest user message and reply", func(t *testing.T) {
+		turn := NewTurn()
+		ctx := WithTurn(context.Background(), turn)
+
+		FromContext(ctx).SetMessages([]converter.UnifiedMessage{
+			{Role: "user", Content: "Hi"},
+			{Role: "assistant", Content: "Hello!"},
+			{Role: "user", Content: "What is the weather?", ToolResults: []converter.ToolResult{{ToolUseID: "call_1", Content: "Sunny"}}},
+		})
+		FromContext(ctx).SetReply("First", "", nil)
+		FromContext(ctx).SetReply("It is sunny.", "Checking.", nil)
+
+		rec := turn.Record()
+		assert.Equal(t, "[Tool result call_1]\nSunny\n\nWhat is the weather?", rec.Prompt)
+		assert.Equal(t, "It is sunny.", rec.Content)
+		assert.Equal(t, "Checking.", rec.Thinking)
+	})
+
+	t.Run("no record without a reply", func(t *testing.T) {
+		turn := NewTurn()
+		turn.SetMessages([]converter.UnifiedMessage{{Role: "user", Content: "Hi"}})
+
+		assert.Nil(t, turn.Record())
+	})
+
+	t.Run("nil turn ignores all calls", func(t *testing.T) {
+		turn := FromContext(context.Background())
+
+		assert.Nil(t, turn)
+		turn.SetMessages(nil)
+		turn.SetReply("Hello!", "", nil)
+		assert.Nil(t, turn.Record())
+	})
+}
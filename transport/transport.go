@@ -0,0 +1,109 @@
+// Package transport provides the shared HTTP transport used for all Kiro traffic.
+package transport
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"kiro-go-proxy/config"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/http/httpproxy"
+)
+
+var (
+	shared     *http.Transport
+	sharedOnce sync.Once
+)
+
+// Shared returns the process-wide transport, built from cfg on first use.
+// Sharing one transport lets auth refreshes, model loading and streaming
+// requests reuse the same connection pool. Later calls ignore cfg: the HTTP_*
+// and VPN settings are not reloadable (see config.reloadableKeys) and need a
+// restart.
+func Shared(cfg *config.Config) *http.Transport {
+	sharedOnce.Do(func() {
+		shared = New(cfg)
+	})
+	return shared
+}
+
+// NewClient returns an http.Client with the given timeout backed by the shared transport
+func NewClient(cfg *config.Config, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Transport: Shared(cfg),
+		Timeout:   timeout,
+	}
+}
+
+// New builds a transport from the HTTP_* settings in cfg
+func New(cfg *config.Config) *http.Transport {
+	dialer := &net.Dialer{
+		Timeout:   seconds(cfg.HTTPDialTimeout),
+		KeepAlive: seconds(cfg.HTTPKeepAlive),
+	}
+
+	t := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         dialer.DialContext,
+		MaxIdleConns:        cfg.HTTPMaxIdleConns,
+		MaxIdleConnsPerHost: cfg.HTTPMaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.HTTPMaxConnsPerHost,
+		IdleConnTimeout:     seconds(cfg.HTTPIdleConnTimeout),
+		TLSHandshakeTimeout: seconds(cfg.HTTPTLSHandshakeTimeout),
+		ForceAttemptHTTP2:   cfg.HTTP2Enabled,
+		TLSClientConfig: &tls.Config{
+			MinVersion: tlsVersion(cfg.HTTPTLSMinVersion),
+		},
+	}
+	if !cfg.HTTP2Enabled {
+		// A non-nil empty map disables the automatic HTTP/2 upgrade
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+
+	proxy, err := cfg.GetVPNProxyURL()
+	if err != nil {
+		log.Warnf("Ignoring %v", err)
+	} else if proxy != nil {
+		t.Proxy = proxyFunc(proxy, cfg.VPNNoProxy)
+		log.Infof("Proxy configured: %s://%s (bypass: %s)", proxy.Scheme, proxy.Host, strings.Join(cfg.VPNNoProxy, ","))
+	}
+
+	return t
+}
+
+// proxyFunc routes every request through proxy except hosts matching noProxy,
+// which uses NO_PROXY syntax (host names, .domain suffixes, IPs, CIDRs or "*")
+func proxyFunc(proxy *url.URL, noProxy []string) func(*http.Request) (*url.URL, error) {
+	fn := (&httpproxy.Config{
+		HTTPProxy:  proxy.String(),
+		HTTPSProxy: proxy.String(),
+		NoProxy:    strings.Join(noProxy, ","),
+	}).ProxyFunc()
+
+	return func(req *http.Request) (*url.URL, error) {
+		return fn(req.URL)
+	}
+}
+
+// tlsVersion parses HTTP_TLS_MIN_VERSION, defaulting to TLS 1.2
+func tlsVersion(version string) uint16 {
+	switch version {
+	case "1.3":
+		return tls.VersionTLS13
+	case "1.2", "":
+		return tls.VersionTLS12
+	default:
+		log.Warnf("Unsupported HTTP_TLS_MIN_VERSION '%s', using 1.2", version)
+		return tls.VersionTLS12
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
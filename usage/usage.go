@@ -0,0 +1,306 @@
+// Package usage tracks per API key and per model usage and enforces quotas.
+//
+// Totals are kept in memory and persisted as JSON to USAGE_FILE after every
+// recorded request. API keys are never written to disk; they are stored by
+// fingerprint (see KeyID).
+package usage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"kiro-go-proxy/config"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Totals holds accumulated usage counters
+type Totals struct {
+	Requests         int     `json:"requests"`
+	PromptTokens     int     `json:"prompt_tokens"`
+	CompletionTokens int     `json:"completion_tokens"`
+	Credits          float64 `json:"credits"`
+}
+
+// Tokens returns prompt plus completion tokens
+func (t Totals) Tokens() int {
+	return t.PromptTokens + t.CompletionTokens
+}
+
+func (t *Totals) add(other Totals) {
+	t.Requests += other.Requests
+	t.PromptTokens += other.PromptTokens
+	t.CompletionTokens += other.CompletionTokens
+	t.Credits += other.Credits
+}
+
+// QuotaExceededError is returned when an API key has used up one of its quotas
+type QuotaExceededError struct {
+	Resource string
+	Used     float64
+	Limit    int
+}
+
+func (e *QuotaExceededError) Error() string {
+	return fmt.Sprintf("usage quota exceeded for this API key: %g/%d %s used", e.Used, e.Limit, e.Resource)
+}
+
+// KeyID returns a stable fingerprint of an API key, safe to persist and display
+func KeyID(apiKey string) string {
+	sum := sha256.Sum256([]byte(apiKey))
+	return hex.EncodeToString(sum[:8])
+}
+
+// Tracker accumulates usage per API key and model
+type Tracker struct {
+	cfg  *config.Config
+	path string
+
+	// keys maps key fingerprint -> model -> totals
+	keys map[string]map[string]*Totals
+	mu   sync.Mutex
+}
+
+// NewTracker creates a tracker, loading previously persisted usage from USAGE_FILE.
+// An empty USAGE_FILE keeps usage in memory only.
+func NewTracker(cfg *config.Config) *Tracker {
+	t := &Tracker{
+		cfg:  cfg,
+		path: cfg.UsageFile,
+		keys: make(map[string]map[string]*Totals),
+	}
+
+	if t.path != "" {
+		if err := t.load(); err != nil {
+			log.Warnf("Failed to load usage from %s: %v", t.path, err)
+		}
+	}
+	return t
+}
+
+func (t *Tracker) load() error {
+	data, err := os.ReadFile(t.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, &t.keys)
+}
+
+// save writes usage atomically via a temp file. Caller must hold t.mu.
+func (t *Tracker) save() error {
+	data, err := json.MarshalIndent(t.keys, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if dir := filepath.Dir(t.path); dir != "." {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, t.path)
+}
+
+// Record adds one request's usage for the key and model and persists it
+func (t *Tracker) Record(apiKey, model string, totals Totals) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	id := KeyID(apiKey)
+	models, ok := t.keys[id]
+	if !ok {
+		models = make(map[string]*Totals)
+		t.keys[id] = models
+	}
+	modelTotals, ok := models[model]
+	if !ok {
+		modelTotals = &Totals{}
+		models[model] = modelTotals
+	}
+	modelTotals.add(totals)
+
+	if t.path == "" {
+		return
+	}
+	if err := t.save(); err != nil {
+		log.Warnf("Failed to save usage to %s: %v", t.path, err)
+	}
+}
+
+// Models returns the key's usage broken down by model
+func (t *Tracker) Models(apiKey string) map[string]Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[string]Totals)
+	for model, totals := range t.keys[KeyID(apiKey)] {
+		result[model] = *totals
+	}
+	return result
+}
+
+// Total returns the key's usage across all models
+func (t *Tracker) Total(apiKey string) Totals {
+	var total Totals
+	for _, totals := range t.Models(apiKey) {
+		total.add(totals)
+	}
+	return total
+}
+
+// Keys returns the usage of every key across all models, by key fingerprint
+func (t *Tracker) Keys() map[string]Totals {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	result := make(map[string]Totals, len(t.keys))
+	for id, models := range t.keys {
+		var total Totals
+		for _, totals := range models {
+			total.add(*totals)
+		}
+		result[id] = total
+	}
+	return result
+}
+
+// CheckQuota returns a *QuotaExceededError if the key has used up any configured quota
+func (t *Tracker) CheckQuota(apiKey string) error {
+	total := t.Total(apiKey)
+
+	checks := []struct {
+		resource string
+		used     float64
+		limit    int
+	}{
+		{"requests", float64(total.Requests), t.cfg.QuotaRequests},
+		{"tokens", float64(total.Tokens()), t.cfg.QuotaTokens},
+		{"credits", total.Credits, t.cfg.QuotaCredits},
+	}
+	for _, check := range checks {
+		if check.limit > 0 && check.used >= float64(check.limit) {
+			return &QuotaExceededError{Resource: check.resource, Used: check.used, Limit: check.limit}
+		}
+	}
+	return nil
+}
+
+// Request accumulates the usage of a single client request.
+// A nil *Request is valid and ignores all calls, so callers need no checks.
+type Request struct {
+	model       string
+	servedModel string
+	totals      Totals
+	mu          sync.Mutex
+}
+
+// NewRequest creates an empty per-request accumulator
+func NewRequest() *Request {
+	return &Request{}
+}
+
+type contextKey struct{}
+
+// WithRequest returns a context carrying the request accumulator
+func WithRequest(ctx context.Context, r *Request) context.Context {
+	if r == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, contextKey{}, r)
+}
+
+// FromContext returns the request accumulator stored in ctx, or nil
+func FromContext(ctx context.Context) *Request {
+	r, _ := ctx.Value(contextKey{}).(*Request)
+	return r
+}
+
+// SetModel records which model served the request. Requests without a model
+// never reached Kiro and are not counted.
+func (r *Request) SetModel(model string) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.model = model
+}
+
+// SetServedModel records the Kiro model that answered the request, which
+// differs from the requested one for "auto", fallbacks and drafts. The last
+// Kiro request made for the client request sets it.
+func (r *Request) SetServedModel(model string) {
+	if r == nil || model == "" {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.servedModel = model
+}
+
+// ServedModel returns the Kiro model that answered the request, or "" if none did
+func (r *Request) ServedModel() string {
+	if r == nil {
+		return ""
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.servedModel
+}
+
+// AddTokens records prompt and completion tokens
+func (r *Request) AddTokens(promptTokens, completionTokens int) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totals.PromptTokens += promptTokens
+	r.totals.CompletionTokens += completionTokens
+}
+
+// AddCredits records Kiro credits reported by usage events
+func (r *Request) AddCredits(credits float64) {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.totals.Credits += credits
+}
+
+// Credits returns the Kiro credits recorded so far
+func (r *Request) Credits() float64 {
+	if r == nil {
+		return 0
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.totals.Credits
+}
+
+// Result returns the model and the request's totals, counting it as one request
+func (r *Request) Result() (string, Totals) {
+	if r == nil {
+		return "", Totals{}
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	totals := r.totals
+	totals.Requests = 1
+	return r.model, totals
+}
@@ -0,0 +1,147 @@
+// Package usage provides tests for usage accounting and quotas.
+package usage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"kiro-go-proxy/config"
+)
+
+// =============================================================================
+// TestTracker
+// =============================================================================
+
+func TestTracker(t *testing.T) {
+	t.Run("aggregates per key and model", func(t *testing.T) {
+		tracker := NewTracker(&config.Config{})
+
+		tracker.Record("key-a", "claude-sonnet-4", Totals{Requests: 1, PromptTokens: 10, CompletionTokens: 5, Credits: 1})
+		tracker.Record("key-a", "claude-sonnet-4", Totals{Requests: 1, PromptTokens: 20, CompletionTokens: 5})
+		tracker.Record("key-a", "claude-haiku-4.5", Totals{Requests: 1, PromptTokens: 1, CompletionTokens: 1})
+		tracker.Record("key-b", "claude-sonnet-4", Totals{Requests: 1})
+
+		models := tracker.Models("key-a")
+		assert.Len(t, models, 2)
+		assert.Equal(t, Totals{Requests: 2, PromptTokens: 30, CompletionTokens: 10, Credits: 1}, models["claude-sonnet-4"])
+		assert.Equal(t, Totals{Requests: 3, PromptTokens: 31, CompletionTokens: 11, Credits: 1}, tracker.Total("key-a"))
+		assert.Equal(t, 1, tracker.Total("key-b").Requests)
+		assert.Empty(t, tracker.Models("unknown"))
+
+		keys := tracker.Keys()
+		assert.Len(t, keys, 2)
+		assert.Equal(t, tracker.Total("key-a"), keys[KeyID("key-a")])
+		assert.Equal(t, 1, keys[KeyID("key-b")].Requests)
+	})
+
+	t.Run("persists and reloads without raw keys", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "usage.json")
+		cfg := &config.Config{UsageFile: path}
+
+		NewTracker(cfg).Record("secret-key", "claude-sonnet-4", Totals{Requests: 1, PromptTokens: 7})
+
+		data, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.False(t, strings.Contains(string(data), "secret-key"))
+		assert.Contains(t, string(data), KeyID("secret-key"))
+
+		reloaded := NewTracker(cfg)
+		assert.Equal(t, Totals{Requests: 1, PromptTokens: 7}, reloaded.Total("secret-key"))
+	})
+
+	t.Run("starts empty when file is missing", func(t *testing.T) {
+		tracker := NewTracker(&config.Config{UsageFile: filepath.Join(t.TempDir(), "missing.json")})
+
+		assert.Equal(t, Totals{}, tracker.Total("key"))
+	})
+}
+
+// =============================================================================
+// TestCheckQuota
+// =============================================================================
+
+func TestCheckQuota(t *testing.T) {
+	t.Run("no quota configured", func(t *testing.T) {
+		tracker := NewTracker(&config.Config{})
+		tracker.Record("key", "m", Totals{Requests: 1000, PromptTokens: 1000000})
+
+		assert.NoError(t, tracker.CheckQuota("key"))
+	})
+
+	t.Run("rejects once requests quota is used", func(t *testing.T) {
+		tracker := NewTracker(&config.Config{QuotaRequests: 2})
+		tracker.Record("key", "m", Totals{Requests: 1})
+		assert.NoError(t, tracker.CheckQuota("key"))
+
+		tracker.Record("key", "m", Totals{Requests: 1})
+		err := tracker.CheckQuota("key")
+
+		var quotaErr *QuotaExceededError
+		assert.ErrorAs(t, err, &quotaErr)
+		assert.Equal(t, "requests", quotaErr.Resource)
+		assert.Equal(t, "usage quota exceeded for this API key: 2/2 requests used", err.Error())
+	})
+
+	t.Run("counts prompt and completion tokens", func(t *testing.T) {
+		tracker := NewTracker(&config.Config{QuotaTokens: 100})
+		tracker.Record("key", "m", Totals{Requests: 1, PromptTokens: 60, CompletionTokens: 40})
+
+		assert.ErrorContains(t, tracker.CheckQuota("key"), "100/100 tokens")
+		assert.NoError(t, tracker.CheckQuota("other-key"))
+	})
+
+	t.Run("checks credits", func(t *testing.T) {
+		tracker := NewTracker(&config.Config{QuotaCredits: 5})
+		tracker.Record("key", "m", Totals{Requests: 1, Credits: 6})
+
+		assert.ErrorContains(t, tracker.CheckQuota("key"), "credits")
+	})
+}
+
+// =============================================================================
+// TestRequest
+// =============================================================================
+
+func TestRequest(t *testing.T) {
+	t.Run("accumulates through context", func(t *testing.T) {
+		r := NewRequest()
+		ctx := WithRequest(context.Background(), r)
+
+		FromContext(ctx).SetModel("claude-sonnet-4")
+		FromContext(ctx).AddTokens(10, 3)
+		FromContext(ctx).AddCredits(2)
+		FromContext(ctx).AddCredits(1)
+
+		model, totals := r.Result()
+		assert.Equal(t, "claude-sonnet-4", model)
+		assert.Equal(t, Totals{Requests: 1, PromptTokens: 10, CompletionTokens: 3, Credits: 3}, totals)
+	})
+
+	t.Run("records the last served model", func(t *testing.T) {
+		r := NewRequest()
+
+		r.SetServedModel("claude-haiku-4.5")
+		r.SetServedModel("claude-sonnet-4.5")
+		r.SetServedModel("")
+
+		assert.Equal(t, "claude-sonnet-4.5", r.ServedModel())
+	})
+
+	t.Run("nil request is a no-op", func(t *testing.T) {
+		r := FromContext(context.Background())
+
+		assert.Nil(t, r)
+		r.SetModel("m")
+		r.AddTokens(1, 1)
+		r.AddCredits(1)
+		r.SetServedModel("m")
+		assert.Empty(t, r.ServedModel())
+		model, totals := r.Result()
+		assert.Empty(t, model)
+		assert.Equal(t, Totals{}, totals)
+	})
+}
@@ -29,6 +29,17 @@ func GenerateConversationID() string {
 	return uuid.New().String()
 }
 
+// GenerateBatchID generates a unique message batch ID (Anthropic format)
+func GenerateBatchID() string {
+	return "msgbatch_" + strings.ReplaceAll(uuid.New().String(), "-", "")[:24]
+}
+
+// GenerateResponsesID generates a unique Responses API object ID with the given
+// prefix, such as "resp", "msg" or "fc"
+func GenerateResponsesID(prefix string) string {
+	return prefix + "_" + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
+
 // GetMachineFingerprint returns a unique machine fingerprint
 func GetMachineFingerprint() string {
 	hostname, _ := os.Hostname()